@@ -0,0 +1,243 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Metrics ルールで監視できるメトリクス（解析のmetricsの数値のキー）
+var Metrics = []string{
+	"entries", "chains", "length", "length_percent", "resolution", "umf",
+	"cis_num", "cis_dist_mean", "cis_dist_std", "mean_score", "mean_std",
+}
+
+// 条件
+const (
+	// ベースラインからの変化率（%）がしきい値を超えた
+	ConditionChangePercent = "change_percent"
+	// ベースラインからの変化量（絶対値）がしきい値を超えた
+	ConditionChangeAbs = "change_abs"
+	// 値がしきい値より大きい（ベースラインは使わない）
+	ConditionAbove = "above"
+	// 値がしきい値より小さい（ベースラインは使わない）
+	ConditionBelow = "below"
+)
+
+// Conditions 指定できる条件
+var Conditions = []string{ConditionChangePercent, ConditionChangeAbs, ConditionAbove, ConditionBelow}
+
+// ベースライン（変化率・変化量の比較対象）
+const (
+	// 再実行元の解析（再実行でない解析は評価しない、デフォルト）
+	BaselineRerunSource = "rerun_source"
+	// 同じセッションの同じUniProt IDで直前に完了した解析
+	BaselinePrevious = "previous"
+)
+
+// Baselines 指定できるベースライン
+var Baselines = []string{BaselineRerunSource, BaselinePrevious}
+
+// 変化の向き（変化率・変化量の条件のみ）
+const (
+	DirectionAny      = "any"
+	DirectionIncrease = "increase"
+	DirectionDecrease = "decrease"
+)
+
+// Directions 指定できる変化の向き
+var Directions = []string{DirectionAny, DirectionIncrease, DirectionDecrease}
+
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+// Rule アラートのルール
+// セッション（dsa_session_id）ごとに登録し、そのセッションの解析の完了時に評価する
+type Rule struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Metric string `json:"metric"`
+	// 条件（change_percent・change_abs・above・below）
+	Condition string  `json:"condition"`
+	Threshold float64 `json:"threshold"`
+	// 比較対象（変化率・変化量の条件のみ）
+	Baseline string `json:"baseline,omitempty"`
+	// 変化の向き（変化率・変化量の条件のみ、anyは増減どちらも）
+	Direction string `json:"direction,omitempty"`
+	// 対象のUniProt ID（空の場合はセッションのすべての解析）
+	UniProtID string    `json:"uniprot_id,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	SessionID string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RuleUpdate ルールの変更（nilのフィールドは変更しない）
+// メトリクス・条件・ベースラインを変えると履歴の意味が変わるため、変更する場合はルールを作り直す
+type RuleUpdate struct {
+	Name      *string
+	Threshold *float64
+	Direction *string
+	UniProtID *string
+	Enabled   *bool
+}
+
+// Evaluation ルールを1つの解析に対して評価した記録（ルールごとの履歴）
+type Evaluation struct {
+	ID         string `json:"id"`
+	RuleID     string `json:"rule_id"`
+	AnalysisID string `json:"analysis_id"`
+	UniProtID  string `json:"uniprot_id"`
+	// 評価したメトリクスの値（メトリクスがない場合はnil）
+	Value *float64 `json:"value"`
+	// ベースラインの解析とその値（変化率・変化量の条件のみ）
+	BaselineID    string   `json:"baseline_id,omitempty"`
+	BaselineValue *float64 `json:"baseline_value,omitempty"`
+	// ベースラインからの変化（change_percentは%、change_absは差）
+	Change    *float64 `json:"change,omitempty"`
+	Threshold float64  `json:"threshold"`
+	Triggered bool     `json:"triggered"`
+	// 評価できなかった理由（ベースラインがない等）
+	Skipped     string    `json:"skipped,omitempty"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// usesBaseline ベースラインと比較する条件か
+func usesBaseline(condition string) bool {
+	return condition == ConditionChangePercent || condition == ConditionChangeAbs
+}
+
+// Validate ルールを検証し、省略された値を補う
+func (r *Rule) Validate() error {
+	if !contains(Metrics, r.Metric) {
+		return fmt.Errorf("unknown metric: %s", r.Metric)
+	}
+	if !contains(Conditions, r.Condition) {
+		return fmt.Errorf("unknown condition: %s", r.Condition)
+	}
+	if math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+		return errors.New("threshold must be a finite number")
+	}
+	if !usesBaseline(r.Condition) {
+		if r.Baseline != "" || r.Direction != "" {
+			return fmt.Errorf("baseline and direction are not used with condition %s", r.Condition)
+		}
+		return nil
+	}
+	if r.Threshold < 0 {
+		return fmt.Errorf("threshold must be >= 0 for condition %s", r.Condition)
+	}
+	if r.Baseline == "" {
+		r.Baseline = BaselineRerunSource
+	}
+	if !contains(Baselines, r.Baseline) {
+		return fmt.Errorf("unknown baseline: %s", r.Baseline)
+	}
+	if r.Direction == "" {
+		r.Direction = DirectionAny
+	}
+	if !contains(Directions, r.Direction) {
+		return fmt.Errorf("unknown direction: %s", r.Direction)
+	}
+	return nil
+}
+
+// matches ルールの対象の解析か
+func (r *Rule) matches(uniprotID string) bool {
+	return r.UniProtID == "" || strings.EqualFold(r.UniProtID, uniprotID)
+}
+
+// check 値がルールの条件を満たすか（変化率・変化量の条件ではchangeも返す）
+// 変化率はベースラインが0の場合は計算できないため、変化がなければ0、あれば評価しない（nil）
+func (r *Rule) check(value float64, baseline *float64) (bool, *float64) {
+	switch r.Condition {
+	case ConditionAbove:
+		return value > r.Threshold, nil
+	case ConditionBelow:
+		return value < r.Threshold, nil
+	}
+	if baseline == nil {
+		return false, nil
+	}
+	change := value - *baseline
+	if r.Condition == ConditionChangePercent {
+		if *baseline == 0 {
+			if change != 0 {
+				return false, nil
+			}
+		} else {
+			change = change / math.Abs(*baseline) * 100
+		}
+	}
+	switch r.Direction {
+	case DirectionIncrease:
+		return change > r.Threshold, &change
+	case DirectionDecrease:
+		return -change > r.Threshold, &change
+	}
+	return math.Abs(change) > r.Threshold, &change
+}
+
+// metricValue メトリクスの数値（DBから読み込んだ値はfloat64、抽出した値はint・float64）
+func metricValue(metrics map[string]interface{}, name string) (float64, bool) {
+	switch v := metrics[name].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func randomID(prefix string, n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate random id: %v", err))
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+// readJSON ファイルが存在しない場合は何もしない
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON 一時ファイルに書き込んでからリネームする（書き込み途中で読まれないように）
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,475 @@
+package alerts
+
+import (
+	"context"
+	"dsa-api/jobs"
+	"dsa-api/logging"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ルールごとに保持する評価の履歴の件数
+	historyLimit = 100
+	// 1つの解析のルールを評価する時間の上限（メトリクス・ベースラインの取得を含む）
+	evaluateTimeout = 30 * time.Second
+	// 直前の解析を探す件数
+	previousScanLimit = 20
+	// 同じ完了の重複した通知を無視する期間（評価済みの解析はこの期間が過ぎたら忘れる）
+	evaluatedTTL = 10 * time.Minute
+)
+
+// Notifier 条件を満たしたルールを通知する（Webhookのalert.triggeredなど）
+type Notifier func(sessionID string, data interface{})
+
+// Manager アラートのルールと評価の履歴を管理する
+// ルールは解析の完了時（JobListener）にジョブの状態更新と切り離して評価し、条件を満たしたものをNotifierで通知する
+type Manager struct {
+	dir  string
+	jobs *jobs.Manager
+
+	mu       sync.Mutex
+	rules    map[string]*Rule
+	history  []*Evaluation
+	notifier Notifier
+}
+
+// storedRule 保存用（セッションIDも保存する）
+type storedRule struct {
+	Rule
+	SessionID string `json:"session_id"`
+}
+
+// NewManager dirに保存されたルールと履歴を読み込む
+func NewManager(dir string, jobManager *jobs.Manager) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create alert directory: %w", err)
+	}
+
+	m := &Manager{
+		dir:   dir,
+		jobs:  jobManager,
+		rules: make(map[string]*Rule),
+	}
+
+	var stored []storedRule
+	if err := readJSON(m.rulesPath(), &stored); err != nil {
+		return nil, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+	for _, s := range stored {
+		rule := s.Rule
+		rule.SessionID = s.SessionID
+		m.rules[rule.ID] = &rule
+	}
+	if err := readJSON(m.historyPath(), &m.history); err != nil {
+		return nil, fmt.Errorf("failed to load alert history: %w", err)
+	}
+	return m, nil
+}
+
+// SetNotifier 条件を満たしたルールの通知先を設定する（未設定の場合は履歴にのみ記録する）
+func (m *Manager) SetNotifier(notifier Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifier = notifier
+}
+
+func (m *Manager) rulesPath() string {
+	return filepath.Join(m.dir, "rules.json")
+}
+
+func (m *Manager) historyPath() string {
+	return filepath.Join(m.dir, "history.json")
+}
+
+// saveRules m.muを保持して呼ぶ
+func (m *Manager) saveRules() {
+	stored := make([]storedRule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		stored = append(stored, storedRule{Rule: *rule, SessionID: rule.SessionID})
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+	})
+	if err := writeJSON(m.rulesPath(), stored); err != nil {
+		logging.Warnf("Failed to save alert rules: %v", err)
+	}
+}
+
+// saveHistory m.muを保持して呼ぶ
+func (m *Manager) saveHistory() {
+	if err := writeJSON(m.historyPath(), m.history); err != nil {
+		logging.Warnf("Failed to save alert history: %v", err)
+	}
+}
+
+// CreateRule ルールを登録する
+func (m *Manager) CreateRule(sessionID string, rule Rule) (*Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rule.ID = randomID("alr_", 12)
+	rule.UniProtID = strings.TrimSpace(rule.UniProtID)
+	rule.SessionID = sessionID
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules[rule.ID] = &rule
+	m.saveRules()
+
+	created := rule
+	return &created, nil
+}
+
+// ownedRule セッションが所有するルールを返す（m.muを保持して呼ぶ）
+func (m *Manager) ownedRule(sessionID, id string) (*Rule, error) {
+	rule, ok := m.rules[id]
+	if !ok || rule.SessionID != sessionID {
+		return nil, ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+// GetRule セッションのルールを返す
+func (m *Manager) GetRule(sessionID, id string) (*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, err := m.ownedRule(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	copied := *rule
+	return &copied, nil
+}
+
+// ListRules セッションのルールの一覧（作成日時の古い順）
+func (m *Manager) ListRules(sessionID string) []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make([]Rule, 0)
+	for _, rule := range m.rules {
+		if rule.SessionID == sessionID {
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// UpdateRule ルールの名前・しきい値・変化の向き・対象・有効/無効を変更する
+func (m *Manager) UpdateRule(sessionID, id string, update RuleUpdate) (*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, err := m.ownedRule(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	updated := *rule
+	if update.Name != nil {
+		updated.Name = *update.Name
+	}
+	if update.Threshold != nil {
+		updated.Threshold = *update.Threshold
+	}
+	if update.Direction != nil {
+		updated.Direction = *update.Direction
+	}
+	if update.UniProtID != nil {
+		updated.UniProtID = strings.TrimSpace(*update.UniProtID)
+	}
+	if update.Enabled != nil {
+		updated.Enabled = *update.Enabled
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+	updated.UpdatedAt = time.Now()
+	*rule = updated
+	m.saveRules()
+
+	return &updated, nil
+}
+
+// DeleteRule ルールと評価の履歴を削除する
+func (m *Manager) DeleteRule(sessionID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ownedRule(sessionID, id); err != nil {
+		return err
+	}
+	delete(m.rules, id)
+	kept := m.history[:0]
+	for _, evaluation := range m.history {
+		if evaluation.RuleID != id {
+			kept = append(kept, evaluation)
+		}
+	}
+	m.history = kept
+	m.saveRules()
+	m.saveHistory()
+	return nil
+}
+
+// History ルールの評価の履歴（新しい順）、triggeredOnlyがtrueの場合は条件を満たしたもののみ
+func (m *Manager) History(sessionID, id string, triggeredOnly bool) ([]Evaluation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.ownedRule(sessionID, id); err != nil {
+		return nil, err
+	}
+	history := make([]Evaluation, 0)
+	for i := len(m.history) - 1; i >= 0; i-- {
+		evaluation := m.history[i]
+		if evaluation.RuleID != id || (triggeredOnly && !evaluation.Triggered) {
+			continue
+		}
+		history = append(history, *evaluation)
+	}
+	return history, nil
+}
+
+// JobListener 解析の完了時にセッションのルールを評価するリスナー
+func (m *Manager) JobListener() func(jobs.JobUpdate) {
+	// 同じ完了が複数回通知されても評価は1回にする（評価した時刻を覚え、evaluatedTTLを過ぎたものは削除する）
+	var mu sync.Mutex
+	evaluated := make(map[string]time.Time)
+
+	return func(update jobs.JobUpdate) {
+		if update.Status != jobs.StatusDone || update.SessionID == "" {
+			return
+		}
+		if len(m.activeRules(update.SessionID, update.UniProtID)) == 0 {
+			return
+		}
+		now := time.Now()
+		mu.Lock()
+		if at, ok := evaluated[update.JobID]; ok && now.Sub(at) <= evaluatedTTL {
+			mu.Unlock()
+			return
+		}
+		for id, at := range evaluated {
+			if now.Sub(at) > evaluatedTTL {
+				delete(evaluated, id)
+			}
+		}
+		evaluated[update.JobID] = now
+		mu.Unlock()
+		// メトリクス・ベースラインの取得にDBを使うため、ジョブの状態更新を待たせない
+		go m.Evaluate(update.JobID, update.UniProtID, update.SessionID)
+	}
+}
+
+// activeRules セッションの有効なルールのうち、解析が対象のもの
+func (m *Manager) activeRules(sessionID, uniprotID string) []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rules []Rule
+	for _, rule := range m.rules {
+		if rule.SessionID == sessionID && rule.Enabled && rule.matches(uniprotID) {
+			rules = append(rules, *rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// baselineValue ベースラインの解析のIDとメトリクス（見つからない場合は評価しない理由）
+type baselineValue struct {
+	id      string
+	metrics map[string]interface{}
+	skipped string
+}
+
+// Evaluate 完了した解析に対してセッションのルールを評価し、履歴に記録する（条件を満たしたものは通知する）
+func (m *Manager) Evaluate(analysisID, uniprotID, sessionID string) {
+	rules := m.activeRules(sessionID, uniprotID)
+	if len(rules) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), evaluateTimeout)
+	defer cancel()
+
+	metrics, err := m.jobs.AnalysisMetrics(ctx, analysisID)
+	if err != nil {
+		logging.Warnf("Failed to evaluate alert rules for %s: %v", analysisID, err)
+		return
+	}
+
+	// ベースラインは必要になったときに1回だけ取得する
+	baselines := make(map[string]*baselineValue)
+	baselineOf := func(kind string) *baselineValue {
+		if b, ok := baselines[kind]; ok {
+			return b
+		}
+		b := m.findBaseline(ctx, kind, analysisID, uniprotID, sessionID)
+		baselines[kind] = b
+		return b
+	}
+
+	now := time.Now()
+	var evaluations []*Evaluation
+	for i := range rules {
+		rule := &rules[i]
+		evaluation := &Evaluation{
+			ID:          randomID("ale_", 12),
+			RuleID:      rule.ID,
+			AnalysisID:  analysisID,
+			UniProtID:   uniprotID,
+			Threshold:   rule.Threshold,
+			EvaluatedAt: now,
+		}
+		evaluations = append(evaluations, evaluation)
+
+		value, ok := metricValue(metrics, rule.Metric)
+		if !ok {
+			evaluation.Skipped = fmt.Sprintf("metric %s not available", rule.Metric)
+			continue
+		}
+		evaluation.Value = &value
+
+		var baseline *float64
+		if usesBaseline(rule.Condition) {
+			b := baselineOf(rule.Baseline)
+			if b.skipped != "" {
+				evaluation.Skipped = b.skipped
+				continue
+			}
+			evaluation.BaselineID = b.id
+			v, ok := metricValue(b.metrics, rule.Metric)
+			if !ok {
+				evaluation.Skipped = fmt.Sprintf("metric %s not available for baseline %s", rule.Metric, b.id)
+				continue
+			}
+			baseline = &v
+			evaluation.BaselineValue = baseline
+		}
+
+		triggered, change := rule.check(value, baseline)
+		if usesBaseline(rule.Condition) && change == nil {
+			evaluation.Skipped = "baseline value is 0"
+			continue
+		}
+		evaluation.Change = change
+		evaluation.Triggered = triggered
+	}
+
+	m.mu.Lock()
+	// 評価中に削除されたルールは記録・通知しない
+	deleted := make(map[string]bool)
+	for _, evaluation := range evaluations {
+		if _, ok := m.rules[evaluation.RuleID]; !ok {
+			deleted[evaluation.RuleID] = true
+			continue
+		}
+		m.history = append(m.history, evaluation)
+	}
+	m.pruneHistory()
+	m.saveHistory()
+	notifier := m.notifier
+	m.mu.Unlock()
+
+	for i, evaluation := range evaluations {
+		if !evaluation.Triggered || deleted[evaluation.RuleID] {
+			continue
+		}
+		rule := &rules[i]
+		logging.Infof("Alert rule %s triggered by %s (%s)", rule.ID, analysisID, rule.Metric)
+		if notifier != nil {
+			notifier(sessionID, map[string]interface{}{
+				"rule":       rule,
+				"evaluation": evaluation,
+			})
+		}
+	}
+}
+
+// findBaseline ベースラインの解析を探し、メトリクスを取得する
+func (m *Manager) findBaseline(ctx context.Context, kind, analysisID, uniprotID, sessionID string) *baselineValue {
+	var baselineID string
+	switch kind {
+	case BaselineRerunSource:
+		source, err := m.jobs.RerunSource(ctx, analysisID)
+		if err != nil {
+			return &baselineValue{skipped: fmt.Sprintf("failed to find rerun source: %v", err)}
+		}
+		if source == "" {
+			return &baselineValue{skipped: "analysis is not a re-run"}
+		}
+		baselineID = source
+	case BaselinePrevious:
+		previous, err := m.previousAnalysis(ctx, analysisID, uniprotID, sessionID)
+		if err != nil {
+			return &baselineValue{skipped: fmt.Sprintf("failed to find previous analysis: %v", err)}
+		}
+		if previous == "" {
+			return &baselineValue{skipped: "no previous analysis"}
+		}
+		baselineID = previous
+	default:
+		return &baselineValue{skipped: fmt.Sprintf("unknown baseline: %s", kind)}
+	}
+
+	metrics, err := m.jobs.AnalysisMetrics(ctx, baselineID)
+	if err != nil {
+		return &baselineValue{id: baselineID, skipped: fmt.Sprintf("failed to load baseline %s: %v", baselineID, err)}
+	}
+	return &baselineValue{id: baselineID, metrics: metrics}
+}
+
+// previousAnalysis セッションの同じUniProt IDの完了した解析のうち、analysisIDより前に作成された最新のもの
+func (m *Manager) previousAnalysis(ctx context.Context, analysisID, uniprotID, sessionID string) (string, error) {
+	current, err := m.jobs.GetJob(analysisID)
+	if err != nil {
+		return "", err
+	}
+	page, err := m.jobs.ListJobs(ctx, jobs.JobListFilter{
+		Status:    jobs.StatusDone,
+		UniProtID: uniprotID,
+		SessionID: sessionID,
+		Limit:     previousScanLimit,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, job := range page.Jobs {
+		if job.ID != analysisID && job.CreatedAt.Before(current.CreatedAt) {
+			return job.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// pruneHistory ルールごとに古い評価の履歴を削除する（m.muを保持して呼ぶ）
+func (m *Manager) pruneHistory() {
+	counts := make(map[string]int)
+	for _, evaluation := range m.history {
+		counts[evaluation.RuleID]++
+	}
+	kept := m.history[:0]
+	for _, evaluation := range m.history {
+		if counts[evaluation.RuleID] > historyLimit {
+			counts[evaluation.RuleID]--
+			continue
+		}
+		kept = append(kept, evaluation)
+	}
+	m.history = kept
+}
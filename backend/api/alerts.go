@@ -0,0 +1,138 @@
+package api
+
+import (
+	"dsa-api/alerts"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateAlertRequest struct {
+	Name      string  `json:"name"`
+	Metric    string  `json:"metric"`
+	Condition string  `json:"condition"`
+	Threshold float64 `json:"threshold"`
+	// 比較対象（rerun_source・previous、省略時はrerun_source、変化率・変化量の条件のみ）
+	Baseline  string `json:"baseline"`
+	Direction string `json:"direction"`
+	UniProtID string `json:"uniprot_id"`
+	// 省略時は有効
+	Enabled *bool `json:"enabled"`
+}
+
+type UpdateAlertRequest struct {
+	Name      *string  `json:"name"`
+	Threshold *float64 `json:"threshold"`
+	Direction *string  `json:"direction"`
+	UniProtID *string  `json:"uniprot_id"`
+	Enabled   *bool    `json:"enabled"`
+}
+
+// SetAlerts アラートのルールを有効にする
+func (r *Routes) SetAlerts(manager *alerts.Manager) {
+	r.alerts = manager
+}
+
+// requireAlerts アラートが無効の場合は503を返す
+func (r *Routes) requireAlerts(c *fiber.Ctx) error {
+	if r.alerts == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Alerts not configured",
+		})
+	}
+	return c.Next()
+}
+
+// alertError アラートのエラーをレスポンスに変換する（ルールがない場合は404、それ以外は検証エラーとして400）
+func alertError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, alerts.ErrRuleNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(400).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// createAlert セッションの解析の完了時に評価するルールを登録する
+func (r *Routes) createAlert(c *fiber.Ctx) error {
+	var req CreateAlertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	rule, err := r.alerts.CreateRule(ensureSessionID(c), alerts.Rule{
+		Name:      req.Name,
+		Metric:    req.Metric,
+		Condition: req.Condition,
+		Threshold: req.Threshold,
+		Baseline:  req.Baseline,
+		Direction: req.Direction,
+		UniProtID: req.UniProtID,
+		Enabled:   enabled,
+	})
+	if err != nil {
+		return alertError(c, err)
+	}
+	return c.Status(201).JSON(rule)
+}
+
+func (r *Routes) listAlerts(c *fiber.Ctx) error {
+	return c.JSON(r.alerts.ListRules(c.Cookies("dsa_session_id")))
+}
+
+func (r *Routes) getAlert(c *fiber.Ctx) error {
+	rule, err := r.alerts.GetRule(c.Cookies("dsa_session_id"), c.Params("id"))
+	if err != nil {
+		return alertError(c, err)
+	}
+	return c.JSON(rule)
+}
+
+// updateAlert ルールの名前・しきい値・変化の向き・対象・有効/無効を変更する
+func (r *Routes) updateAlert(c *fiber.Ctx) error {
+	var req UpdateAlertRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	rule, err := r.alerts.UpdateRule(c.Cookies("dsa_session_id"), c.Params("id"), alerts.RuleUpdate{
+		Name:      req.Name,
+		Threshold: req.Threshold,
+		Direction: req.Direction,
+		UniProtID: req.UniProtID,
+		Enabled:   req.Enabled,
+	})
+	if err != nil {
+		return alertError(c, err)
+	}
+	return c.JSON(rule)
+}
+
+func (r *Routes) deleteAlert(c *fiber.Ctx) error {
+	if err := r.alerts.DeleteRule(c.Cookies("dsa_session_id"), c.Params("id")); err != nil {
+		return alertError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message":  "Alert rule deleted successfully",
+		"alert_id": c.Params("id"),
+	})
+}
+
+// listAlertHistory ルールの評価の履歴（新しい順、?triggered=trueで条件を満たしたもののみ）
+func (r *Routes) listAlertHistory(c *fiber.Ctx) error {
+	history, err := r.alerts.History(c.Cookies("dsa_session_id"), c.Params("id"), c.QueryBool("triggered"))
+	if err != nil {
+		return alertError(c, err)
+	}
+	return c.JSON(history)
+}
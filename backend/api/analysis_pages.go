@@ -0,0 +1,210 @@
+package api
+
+import (
+	"dsa-api/storage"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 解析一覧の1ページの件数（デフォルトと上限）
+const (
+	defaultAnalysisPageSize = 50
+	maxAnalysisPageSize     = 200
+)
+
+// 総件数のレスポンスヘッダー（?include_total=true の場合のみ）
+const totalCountHeader = "X-Total-Count"
+
+var errInvalidAnalysisCursor = errors.New("invalid cursor")
+
+// analysisCursor ページの最後の解析の位置（固定した解析が先、それぞれ作成日時の新しい順、同じ場合はIDの降順）
+type analysisCursor struct {
+	pinned    bool
+	createdAt time.Time
+	id        string
+}
+
+// follows 作成日時・IDがcreatedAt・idの解析がカーソルより後ろに並ぶか
+func (c analysisCursor) follows(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.createdAt) {
+		return createdAt.Before(c.createdAt)
+	}
+	return id < c.id
+}
+
+// encodeAnalysisCursor "<固定（1または0）>:<作成日時（ナノ秒）>:<ID>"
+func encodeAnalysisCursor(record *storage.AnalysisRecord, pinned bool) string {
+	section := "0"
+	if pinned {
+		section = "1"
+	}
+	raw := section + ":" + strconv.FormatInt(record.CreatedAt.UnixNano(), 10) + ":" + record.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeAnalysisCursor(cursor string) (*analysisCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidAnalysisCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || (parts[0] != "0" && parts[0] != "1") || parts[2] == "" {
+		return nil, errInvalidAnalysisCursor
+	}
+	n, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errInvalidAnalysisCursor
+	}
+	return &analysisCursor{pinned: parts[0] == "1", createdAt: time.Unix(0, n), id: parts[2]}, nil
+}
+
+// pageSections 固定した解析・固定していない解析のどちらから取得するか（pinnedで絞り込んだ場合はその一方、カーソルの位置より前は除く）
+func pageSections(filters map[string]interface{}, cursor *analysisCursor) []bool {
+	sections := []bool{true, false}
+	if pinned, ok := filters[storage.FilterPinned].(bool); ok {
+		sections = []bool{pinned}
+	}
+	if cursor != nil && !cursor.pinned {
+		for len(sections) > 0 && sections[0] {
+			sections = sections[1:]
+		}
+	}
+	return sections
+}
+
+// analysisPageSection 固定した（pinned）・固定していない解析のうち、カーソルより後ろの解析を作成日時の新しい順にwant件まで返す
+// cursorがnilの場合は先頭から（カーソルが別の区分の場合はnilを渡す）
+func (r *Routes) analysisPageSection(c *fiber.Ctx, filters map[string]interface{}, pinned bool, cursor *analysisCursor, want int) ([]*storage.AnalysisRecord, error) {
+	section := make(map[string]interface{}, len(filters)+2)
+	for key, value := range filters {
+		section[key] = value
+	}
+	section[storage.FilterPinned] = pinned
+	if cursor != nil {
+		// 前のページの最後の解析と同じ作成日時の解析も含めて取得し、IDで除く
+		section["to"] = cursor.createdAt.Format(time.RFC3339Nano)
+	}
+
+	// 同じ作成日時の解析が多く、前のページの分を除くと足りない場合は取得する件数を増やす
+	var page []*storage.AnalysisRecord
+	for limit := want; ; limit *= 2 {
+		section["limit"] = limit
+		records, err := r.listAnalysisRecords(c.UserContext(), section)
+		if err != nil {
+			return nil, err
+		}
+		page = page[:0]
+		for _, record := range records {
+			if cursor == nil || cursor.follows(record.CreatedAt, record.ID) {
+				page = append(page, record)
+			}
+		}
+		if len(page) >= want || len(records) < limit {
+			break
+		}
+	}
+	sort.SliceStable(page, func(i, j int) bool {
+		if !page[i].CreatedAt.Equal(page[j].CreatedAt) {
+			return page[i].CreatedAt.After(page[j].CreatedAt)
+		}
+		return page[i].ID > page[j].ID
+	})
+	if len(page) > want {
+		page = page[:want]
+	}
+	return page, nil
+}
+
+// listAnalysesPage GET /api/analyses?page_size=50&cursor=... 固定した解析を先に、それぞれ作成日時の新しい順に1ページを返す
+// offsetと違い、前のページの最後の解析の作成日時より前だけを取得するため、件数が増えても遅くならず、ページの間に追加された解析で重複・欠落しない
+// ?include_total=true の場合は絞り込みに一致する総件数をX-Total-Countとtotalで返す
+func (r *Routes) listAnalysesPage(c *fiber.Ctx, filters map[string]interface{}) error {
+	pageSize := c.QueryInt("page_size", defaultAnalysisPageSize)
+	if pageSize < 1 || pageSize > maxAnalysisPageSize {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("page_size must be between 1 and %d", maxAnalysisPageSize),
+		})
+	}
+	if _, ok := filters["offset"]; ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "offset cannot be used with cursor pagination",
+		})
+	}
+	// カーソルは固定・作成日時の順の位置のため、他の並び順とは併用できない
+	if _, ok := filters[storage.FilterSort]; ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "sort cannot be used with cursor pagination",
+		})
+	}
+	delete(filters, "limit")
+
+	var total *int
+	if c.QueryBool("include_total") {
+		count, err := r.db.CountAnalysesMatching(c.UserContext(), filters)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		total = &count
+	}
+
+	var cursor *analysisCursor
+	if v := c.Query("cursor"); v != "" {
+		decoded, err := decodeAnalysisCursor(v)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid cursor",
+			})
+		}
+		cursor = decoded
+	}
+
+	// 固定した解析、固定していない解析の順に取得する（次のページの有無を判定するため1件多く取得する）
+	var page []*storage.AnalysisRecord
+	// ページの解析ごとの区分（次のページのカーソル用）
+	var pinnedOf []bool
+	for _, pinned := range pageSections(filters, cursor) {
+		var after *analysisCursor
+		if cursor != nil && cursor.pinned == pinned {
+			after = cursor
+		}
+		records, err := r.analysisPageSection(c, filters, pinned, after, pageSize+1-len(page))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		for _, record := range records {
+			page = append(page, record)
+			pinnedOf = append(pinnedOf, pinned)
+		}
+		if len(page) > pageSize {
+			break
+		}
+	}
+
+	hasMore := len(page) > pageSize
+	if hasMore {
+		page = page[:pageSize]
+	}
+	response := fiber.Map{
+		"analyses": r.analysisSummaries(c, page),
+		"has_more": hasMore,
+	}
+	if hasMore {
+		response["next_cursor"] = encodeAnalysisCursor(page[len(page)-1], pinnedOf[len(page)-1])
+	}
+	if total != nil {
+		response["total"] = *total
+		c.Set(totalCountHeader, strconv.Itoa(*total))
+	}
+	return c.JSON(response)
+}
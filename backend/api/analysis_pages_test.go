@@ -0,0 +1,49 @@
+package api
+
+import (
+	"dsa-api/storage"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalysisCursorRoundTrip(t *testing.T) {
+	record := &storage.AnalysisRecord{ID: "id1", CreatedAt: time.Unix(1704067200, 123)}
+	for _, pinned := range []bool{true, false} {
+		cursor, err := decodeAnalysisCursor(encodeAnalysisCursor(record, pinned))
+		if err != nil {
+			t.Fatalf("decode(encode(pinned=%v)): %v", pinned, err)
+		}
+		if cursor.pinned != pinned || !cursor.createdAt.Equal(record.CreatedAt) || cursor.id != record.ID {
+			t.Errorf("cursor = %+v, want pinned=%v created_at=%s id=%s", cursor, pinned, record.CreatedAt, record.ID)
+		}
+	}
+	for _, invalid := range []string{"", "!!", "MTcwNDA2NzIwMDAwMDAwMDAwMDppZDE" /* 固定の区分のない形式 */} {
+		if _, err := decodeAnalysisCursor(invalid); err == nil {
+			t.Errorf("decodeAnalysisCursor(%q) succeeded, want error", invalid)
+		}
+	}
+}
+
+// 固定した解析のページが終わると固定していない解析に続き、固定していない解析のカーソルからは固定した解析に戻らない
+func TestPageSections(t *testing.T) {
+	pinnedCursor := &analysisCursor{pinned: true}
+	unpinnedCursor := &analysisCursor{pinned: false}
+	for _, tc := range []struct {
+		name    string
+		filters map[string]interface{}
+		cursor  *analysisCursor
+		want    []bool
+	}{
+		{"first page", map[string]interface{}{}, nil, []bool{true, false}},
+		{"after pinned", map[string]interface{}{}, pinnedCursor, []bool{true, false}},
+		{"after unpinned", map[string]interface{}{}, unpinnedCursor, []bool{false}},
+		{"pinned only", map[string]interface{}{storage.FilterPinned: true}, nil, []bool{true}},
+		{"unpinned only", map[string]interface{}{storage.FilterPinned: false}, nil, []bool{false}},
+		{"pinned only after unpinned", map[string]interface{}{storage.FilterPinned: true}, unpinnedCursor, []bool{}},
+	} {
+		if got := pageSections(tc.filters, tc.cursor); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: pageSections = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"dsa-api/auth"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// タグの最大の長さ
+	maxTagLength = 50
+	// 1回に追加・絞り込みに指定できるタグの数
+	maxTagsPerRequest = 20
+	// メモの最大の長さ
+	maxNoteLength = 10000
+)
+
+// addTagsSchema POST /api/analyses/:id/tags
+var addTagsSchema = objectSchema{
+	"tags": {Type: typeArray, Required: true, Items: &fieldSchema{Type: typeString, NonEmpty: true}},
+}
+
+// createNoteSchema POST /api/analyses/:id/notes
+var createNoteSchema = objectSchema{
+	"text": {Type: typeString, Required: true, NonEmpty: true},
+}
+
+type AddTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type CreateNoteRequest struct {
+	Text string `json:"text"`
+}
+
+// normalizeTags タグを正規化する（前後の空白を除き、連続する空白を1つにして小文字にする、重複は除く）
+func normalizeTags(values []string) ([]string, error) {
+	seen := make(map[string]bool)
+	tags := make([]string, 0, len(values))
+	for _, v := range values {
+		tag := strings.ToLower(strings.Join(strings.Fields(v), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("tag must be at most %d characters: %s", maxTagLength, tag)
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxTagsPerRequest {
+		return nil, fmt.Errorf("at most %d tags can be specified", maxTagsPerRequest)
+	}
+	return tags, nil
+}
+
+// analysisTags 解析ごとのタグ（取得できない場合は空、一覧・詳細のレスポンス用）
+func (r *Routes) analysisTags(ctx context.Context, ids []string) map[string][]string {
+	if r.db == nil {
+		return nil
+	}
+	tags, err := r.db.AnalysisTags(ctx, ids)
+	if err != nil {
+		logging.Warnf("Failed to get analysis tags (apply migrations/018_create_analysis_tags_notes.sql): %v", err)
+		return nil
+	}
+	return tags
+}
+
+// annotationError タグ・メモのDBのエラーを500で返す
+func annotationError(c *fiber.Ctx, action string, err error) error {
+	if ctxErr := c.UserContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	requestLog(c).Errorf("Failed to %s: %v", action, err)
+	return c.Status(500).JSON(fiber.Map{
+		"error": fmt.Sprintf("Failed to %s", action),
+	})
+}
+
+// analysisRecordNotFound DBに解析がない場合の404（タグ・メモはDBの解析にのみ付けられる）
+func analysisRecordNotFound(c *fiber.Ctx) error {
+	if ctxErr := c.UserContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return c.Status(404).JSON(fiber.Map{
+		"error": "Analysis not found in database",
+	})
+}
+
+// tagsResponse 解析のタグの一覧
+func (r *Routes) tagsResponse(c *fiber.Ctx, id string) error {
+	tags, err := r.db.AnalysisTags(c.UserContext(), []string{id})
+	if err != nil {
+		return annotationError(c, "get tags", err)
+	}
+	list := tags[id]
+	if list == nil {
+		list = []string{}
+	}
+	return c.JSON(fiber.Map{
+		"id":   id,
+		"tags": list,
+	})
+}
+
+// listAnalysisTags GET /api/analyses/:id/tags
+func (r *Routes) listAnalysisTags(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := r.getAnalysisRecord(c.UserContext(), id); err != nil {
+		return analysisRecordNotFound(c)
+	}
+	return r.tagsResponse(c, id)
+}
+
+// addAnalysisTags POST /api/analyses/:id/tags 解析にタグを追加する（付いているタグはそのまま、すべてのタグを返す）
+func (r *Routes) addAnalysisTags(c *fiber.Ctx) error {
+	var req AddTagsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(tags) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "tags is required",
+		})
+	}
+
+	id := c.Params("id")
+	if _, err := r.getAnalysisRecord(c.UserContext(), id); err != nil {
+		return analysisRecordNotFound(c)
+	}
+	if err := r.db.AddAnalysisTags(c.UserContext(), id, tags); err != nil {
+		return annotationError(c, "add tags", err)
+	}
+	return r.tagsResponse(c, id)
+}
+
+// removeAnalysisTag DELETE /api/analyses/:id/tags/:tag 解析からタグを外す
+func (r *Routes) removeAnalysisTag(c *fiber.Ctx) error {
+	id := c.Params("id")
+	raw, err := url.PathUnescape(c.Params("tag"))
+	if err != nil {
+		raw = c.Params("tag")
+	}
+	tags, err := normalizeTags([]string{raw})
+	if err != nil || len(tags) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid tag",
+		})
+	}
+
+	removed, err := r.db.RemoveAnalysisTag(c.UserContext(), id, tags[0])
+	if err != nil {
+		return annotationError(c, "remove tag", err)
+	}
+	if !removed {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Tag not found",
+		})
+	}
+	return r.tagsResponse(c, id)
+}
+
+// noteResponse メモのレスポンス（ownは削除できるか）
+// 作成者のメールアドレスは作成したセッション・管理者にのみ返す（解析を閲覧できる他のセッションに公開しない）
+func (r *Routes) noteResponse(c *fiber.Ctx, note *storage.AnalysisNote) fiber.Map {
+	own := r.canDeleteNote(c, note)
+	response := fiber.Map{
+		"id":         note.ID,
+		"text":       note.Body,
+		"created_at": formatTime(note.CreatedAt),
+		"own":        own,
+	}
+	if own && note.AuthorEmail != "" {
+		response["author_email"] = note.AuthorEmail
+	}
+	return response
+}
+
+// canDeleteNote メモを作成したセッション・管理者（セッションレスモードではすべてのリクエスト）のみ削除できる
+func (r *Routes) canDeleteNote(c *fiber.Ctx, note *storage.AnalysisNote) bool {
+	if r.sessionless || r.requestRole(c) == auth.RoleAdmin {
+		return true
+	}
+	sessionID := r.requestSessionID(c)
+	return sessionID != "" && note.SessionID == sessionID
+}
+
+// listAnalysisNotes GET /api/analyses/:id/notes 解析のメモ（古い順）
+func (r *Routes) listAnalysisNotes(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := r.getAnalysisRecord(c.UserContext(), id); err != nil {
+		return analysisRecordNotFound(c)
+	}
+	notes, err := r.db.ListAnalysisNotes(c.UserContext(), id)
+	if err != nil {
+		return annotationError(c, "list notes", err)
+	}
+	response := make([]fiber.Map, 0, len(notes))
+	for _, note := range notes {
+		response = append(response, r.noteResponse(c, note))
+	}
+	return c.JSON(fiber.Map{
+		"id":    id,
+		"notes": response,
+	})
+}
+
+// createAnalysisNote POST /api/analyses/:id/notes 解析にメモを追加する
+func (r *Routes) createAnalysisNote(c *fiber.Ctx) error {
+	var req CreateNoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" || len([]rune(text)) > maxNoteLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("text must be 1 to %d characters", maxNoteLength),
+		})
+	}
+
+	id := c.Params("id")
+	if _, err := r.getAnalysisRecord(c.UserContext(), id); err != nil {
+		return analysisRecordNotFound(c)
+	}
+	note := &storage.AnalysisNote{
+		ID:         uuid.New().String(),
+		AnalysisID: id,
+		Body:       text,
+		SessionID:  r.jobSessionID(c),
+	}
+	if claims := requestClaims(c); claims != nil {
+		note.UserID = claims.Subject
+		note.AuthorEmail = claims.Email
+	}
+	created, err := r.db.CreateAnalysisNote(c.UserContext(), note)
+	if err != nil {
+		return annotationError(c, "create note", err)
+	}
+	return c.Status(201).JSON(r.noteResponse(c, created))
+}
+
+// deleteAnalysisNote DELETE /api/analyses/:id/notes/:noteId メモを削除する（作成したセッション・管理者のみ）
+func (r *Routes) deleteAnalysisNote(c *fiber.Ctx) error {
+	id := c.Params("id")
+	noteID := c.Params("noteId")
+	note, err := r.db.GetAnalysisNote(c.UserContext(), id, noteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	}
+	if err != nil {
+		return annotationError(c, "delete note", err)
+	}
+	if !r.canDeleteNote(c, note) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Note belongs to another session",
+		})
+	}
+
+	if _, err := r.db.DeleteAnalysisNote(c.UserContext(), id, noteID); err != nil {
+		return annotationError(c, "delete note", err)
+	}
+	return c.JSON(fiber.Map{
+		"message": "Note deleted successfully",
+		"id":      id,
+		"note_id": noteID,
+	})
+}
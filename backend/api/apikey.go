@@ -0,0 +1,40 @@
+package api
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetAPIKeys 外部連携用のAPIキーを設定する（空の場合はAPIキーによる操作を受け付けない）
+func (r *Routes) SetAPIKeys(keys []string) {
+	r.apiKeys = make(map[string]bool)
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			r.apiKeys[key] = true
+		}
+	}
+}
+
+// requestAPIKey リクエストのAPIキー（X-API-Keyヘッダー、なければapi_keyクエリ）
+func requestAPIKey(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return c.Query("api_key")
+}
+
+// validAPIKey 登録されたAPIキーか（タイミング攻撃を避けるため定数時間で比較する）
+func (r *Routes) validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for known := range r.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// archiveAnalysis POST /api/analyses/:id/archive 成果物を長期保存先（S3 Glacier等）に移す
+// 移動はバックグラウンドで行い、開始した場合は202、既に移動中・長期保存中の場合は200を返す
+func (r *Routes) archiveAnalysis(c *fiber.Ctx) error {
+	id := c.Params("id")
+	archive, started, err := r.jobManager.ArchiveAnalysis(c.UserContext(), id)
+	if err != nil {
+		return r.archiveError(c, id, archive, err)
+	}
+	status := 200
+	if started {
+		status = 202
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"id":      id,
+		"archive": archiveResponse(archive),
+	})
+}
+
+// restoreAnalysis POST /api/analyses/:id/restore 長期保存中の成果物の復元を要求する
+// 復元にはストレージクラス・取り出し速度に応じて数時間かかるため、進捗はGET /api/analyses/:idのarchiveで確認する
+func (r *Routes) restoreAnalysis(c *fiber.Ctx) error {
+	id := c.Params("id")
+	archive, requested, err := r.jobManager.RequestArchiveRestore(c.UserContext(), id)
+	if err != nil {
+		return r.archiveError(c, id, archive, err)
+	}
+	status := 200
+	if requested {
+		status = 202
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"id":      id,
+		"archive": archiveResponse(archive),
+	})
+}
+
+func (r *Routes) archiveError(c *fiber.Ctx, id string, archive *storage.AnalysisArchive, err error) error {
+	if ctxErr := c.UserContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	switch {
+	case errors.Is(err, jobs.ErrArchiveUnavailable):
+		return c.Status(503).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, jobs.ErrAnalysisNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Analysis not found",
+		})
+	case errors.Is(err, jobs.ErrArchiveExpired):
+		if at := r.artifactsExpiredAt(c.UserContext(), id); at != nil {
+			return artifactsExpiredError(c, id, *at)
+		}
+		return c.Status(410).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, jobs.ErrArchiveNotFinished), errors.Is(err, jobs.ErrArchiveConflict):
+		response := fiber.Map{
+			"error": err.Error(),
+		}
+		if archive != nil {
+			response["archive"] = archiveResponse(archive)
+		}
+		return c.Status(409).JSON(response)
+	}
+	// 長期保存先への復元の要求に失敗した
+	return c.Status(502).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// archiveResponse 長期保存の状態（GET /api/analyses/:idのarchive）
+func archiveResponse(a *storage.AnalysisArchive) fiber.Map {
+	response := fiber.Map{
+		"status":        a.Status,
+		"storage_class": a.StorageClass,
+		"objects":       len(a.Objects),
+		"bytes":         a.Bytes,
+	}
+	if a.ArchivedAt != nil {
+		response["archived_at"] = formatTime(*a.ArchivedAt)
+	}
+	if a.RestoreTier != nil {
+		response["restore_tier"] = *a.RestoreTier
+	}
+	if a.RestoreRequestedAt != nil {
+		response["restore_requested_at"] = formatTime(*a.RestoreRequestedAt)
+	}
+	if estimate := jobs.RestoreEstimate(a); estimate != nil {
+		response["restore_estimated_at"] = formatTime(*estimate)
+	}
+	if a.RestoreCompletedAt != nil {
+		response["restore_completed_at"] = formatTime(*a.RestoreCompletedAt)
+	}
+	if a.RestoreExpiresAt != nil {
+		response["restore_expires_at"] = formatTime(*a.RestoreExpiresAt)
+	}
+	if a.Error != nil {
+		response["error"] = *a.Error
+	}
+	return response
+}
+
+// archivedError 長期保存中の成果物は復元するまで配信できないことを返す
+func archivedError(c *fiber.Ctx, id string, a *storage.AnalysisArchive) error {
+	message := fmt.Sprintf("Artifacts of analysis %s are in archive storage", id)
+	if a.Status == storage.ArchiveStatusRestoring {
+		message = fmt.Sprintf("Artifacts of analysis %s are being restored from archive storage", id)
+	}
+	return c.Status(409).JSON(fiber.Map{
+		"error":       message,
+		"archived":    true,
+		"archive":     archiveResponse(a),
+		"restore_url": fmt.Sprintf("/api/analyses/%s/restore", id),
+	})
+}
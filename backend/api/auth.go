@@ -0,0 +1,333 @@
+package api
+
+import (
+	"database/sql"
+	"dsa-api/auth"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// ログイン用のトークンの有効期限のデフォルト
+	defaultAuthTokenTTL = 7 * 24 * time.Hour
+	// ログイン用のトークンを保存するCookie（Authorization: Bearerでも指定できる）
+	authCookieName = "dsa_auth_token"
+	// パスワードの最小文字数
+	minPasswordLength = 8
+	// c.Localsに保存するログイン中のユーザーのキー
+	authClaimsKey = "auth_claims"
+)
+
+// AuthConfig ユーザーアカウントの設定
+type AuthConfig struct {
+	// ログイン用のトークンの署名鍵（未設定の場合は起動ごとにランダムに生成され、再起動でログインが無効になる）
+	Secret []byte
+	// トークンの有効期限（0の場合は7日）
+	TokenTTL time.Duration
+	// 登録時にadminのロールを与えるメールアドレス（小文字、最初の管理者の作成用）
+	AdminEmails []string
+	// ログインしていないリクエストのロール（viewer・analyst、空の場合はanonymousRoleのデフォルト）
+	AnonymousRole string
+}
+
+// SetAuthConfig ユーザーアカウントの設定を行う
+func (r *Routes) SetAuthConfig(cfg AuthConfig) {
+	if len(cfg.Secret) == 0 {
+		cfg.Secret = r.auth.Secret
+		if r.db != nil && !r.sessionless {
+			logging.Warnf("AUTH_SECRET is not set, logins will be invalidated on restart")
+		}
+	}
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultAuthTokenTTL
+	}
+	r.auth = cfg
+}
+
+// credentialsSchema POST /api/auth/register・login
+var credentialsSchema = objectSchema{
+	"email":    {Type: typeString, Required: true, NonEmpty: true},
+	"password": {Type: typeString, Required: true, NonEmpty: true},
+}
+
+type CredentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// requireAccounts ユーザーアカウントはDB設定時のみ（セッションレスモードではrequireSessionsで無効）
+func (r *Routes) requireAccounts(c *fiber.Ctx) error {
+	if r.db == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "User accounts require the database",
+		})
+	}
+	return c.Next()
+}
+
+// authenticate ログイン用のトークン（Authorization: Bearer、なければCookie）を検証し、ログイン中のユーザーを記録する
+// トークンがない場合は匿名のセッションとして扱う
+// Cookieのトークンが無効な場合はCookieを削除して匿名で続け、ヘッダーのトークンが無効な場合は401を返す
+// リモートワーカー用の内部API（AuthorizationはWORKER_TOKEN）は対象外
+func (r *Routes) authenticate(c *fiber.Ctx) error {
+	if r.sessionless || strings.HasPrefix(c.Path(), "/api/internal/") {
+		return c.Next()
+	}
+
+	token, fromHeader := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !fromHeader {
+		token = c.Cookies(authCookieName)
+	}
+	if token == "" {
+		return c.Next()
+	}
+	claims, err := auth.ParseToken(r.auth.Secret, token, time.Now())
+	if err != nil {
+		if fromHeader {
+			return c.Status(401).JSON(fiber.Map{
+				"error": "Invalid or expired token",
+			})
+		}
+		clearAuthCookie(c)
+		return c.Next()
+	}
+	c.Locals(authClaimsKey, claims)
+	return c.Next()
+}
+
+// requestClaims ログイン中のユーザー（匿名の場合はnil）
+func requestClaims(c *fiber.Ctx) *auth.Claims {
+	claims, _ := c.Locals(authClaimsKey).(*auth.Claims)
+	return claims
+}
+
+// linkAnalysisUser ログイン中に作成した解析をユーザーに紐付ける
+func (r *Routes) linkAnalysisUser(c *fiber.Ctx, id string) {
+	claims := requestClaims(c)
+	if claims == nil || r.db == nil {
+		return
+	}
+	if err := r.db.SetAnalysisUser(id, claims.Subject); err != nil {
+		requestLog(c).Warnf("Failed to link analysis %s to user %s (apply migrations/014_create_users.sql): %v", id, claims.Subject, err)
+	}
+}
+
+// normalizeEmail メールアドレスを検証し、小文字に正規化する
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", errors.New("Invalid email address")
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// register POST /api/auth/register ユーザーを登録してログインする
+func (r *Routes) register(c *fiber.Ctx) error {
+	var req CredentialsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len([]rune(req.Password)) < minPasswordLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLength),
+		})
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		requestLog(c).Errorf("Failed to hash password: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to register user",
+		})
+	}
+	role := auth.RoleAnalyst
+	for _, adminEmail := range r.auth.AdminEmails {
+		if adminEmail == email {
+			role = auth.RoleAdmin
+		}
+	}
+	user, err := r.db.CreateUser(c.UserContext(), &storage.User{
+		ID:           uuid.New().String(),
+		Email:        email,
+		PasswordHash: hash,
+		SessionID:    uuid.New().String(),
+		Role:         role,
+	})
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, storage.ErrEmailTaken) {
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Email is already registered",
+			})
+		}
+		requestLog(c).Errorf("Failed to create user: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to register user",
+		})
+	}
+	requestLog(c).Infof("Registered user %s (%s)", user.ID, user.Role)
+	return r.loginResponse(c.Status(201), user)
+}
+
+// login POST /api/auth/login メールアドレスとパスワードでログインする
+func (r *Routes) login(c *fiber.Ctx) error {
+	var req CredentialsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		auth.VerifyDummy(req.Password)
+		return invalidCredentials(c)
+	}
+
+	user, err := r.db.GetUserByEmail(c.UserContext(), email)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			auth.VerifyDummy(req.Password)
+			return invalidCredentials(c)
+		}
+		requestLog(c).Errorf("Failed to load user: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to log in",
+		})
+	}
+	if !auth.VerifyPassword(user.PasswordHash, req.Password) {
+		return invalidCredentials(c)
+	}
+	if !r.readOnly {
+		if err := r.db.TouchUserLogin(user.ID); err != nil {
+			requestLog(c).Warnf("Failed to record login of %s: %v", user.ID, err)
+		}
+	}
+	return r.loginResponse(c, user)
+}
+
+// invalidCredentials メールアドレス・パスワードのどちらが誤っているかは返さない
+func invalidCredentials(c *fiber.Ctx) error {
+	return c.Status(401).JSON(fiber.Map{
+		"error": "Invalid email or password",
+	})
+}
+
+// loginResponse トークンを発行してCookieに設定し、匿名のセッション（dsa_session_id）の解析をアカウントに引き継ぐ
+func (r *Routes) loginResponse(c *fiber.Ctx, user *storage.User) error {
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   user.ID,
+		Email:     user.Email,
+		SessionID: user.SessionID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(r.auth.TokenTTL).Unix(),
+	}
+	token, err := auth.SignToken(r.auth.Secret, claims)
+	if err != nil {
+		requestLog(c).Errorf("Failed to sign token: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to issue token",
+		})
+	}
+
+	var claimed int64
+	if sessionID := c.Cookies("dsa_session_id"); sessionID != "" && sessionID != user.SessionID && !r.readOnly {
+		claimed, err = r.db.ClaimSessionAnalyses(sessionID, user)
+		if err != nil {
+			requestLog(c).Warnf("Failed to move analyses of session to user %s: %v", user.ID, err)
+		} else if claimed > 0 {
+			requestLog(c).Infof("Moved %d analyses of anonymous session to user %s", claimed, user.ID)
+		}
+		r.jobManager.ReassignSession(sessionID, user.SessionID)
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     authCookieName,
+		Value:    token,
+		Expires:  claims.Expires(),
+		HTTPOnly: true,
+		SameSite: "Lax",
+		Path:     "/",
+	})
+	return c.JSON(fiber.Map{
+		"token":            token,
+		"expires_at":       formatTime(claims.Expires()),
+		"user":             userResponse(user),
+		"claimed_analyses": claimed,
+	})
+}
+
+func userResponse(user *storage.User) fiber.Map {
+	return fiber.Map{
+		"id":         user.ID,
+		"email":      user.Email,
+		"role":       user.Role,
+		"created_at": formatTime(user.CreatedAt),
+	}
+}
+
+// logout POST /api/auth/logout Cookieのトークンを削除する（トークン自体は有効期限まで有効）
+func (r *Routes) logout(c *fiber.Ctx) error {
+	clearAuthCookie(c)
+	return c.SendStatus(204)
+}
+
+func clearAuthCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     authCookieName,
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		SameSite: "Lax",
+		Path:     "/",
+	})
+}
+
+// getCurrentUser GET /api/auth/me ログイン中のユーザー（匿名の場合は401）
+func (r *Routes) getCurrentUser(c *fiber.Ctx) error {
+	claims := requestClaims(c)
+	if claims == nil {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Not logged in",
+		})
+	}
+	user, err := r.db.GetUser(c.UserContext(), claims.Subject)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			clearAuthCookie(c)
+			return c.Status(401).JSON(fiber.Map{
+				"error": "User not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load user",
+		})
+	}
+	response := userResponse(user)
+	response["expires_at"] = formatTime(claims.Expires())
+	return c.JSON(response)
+}
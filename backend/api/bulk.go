@@ -0,0 +1,252 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// filterで一括削除・キャンセルする解析の上限（超える場合はhas_moreを返し、繰り返し呼び出す）
+const maxBulkFilterMatches = 500
+
+// filterの解決時にリクエストのクエリから引き継ぐパラメータ
+var bulkFilterParams = []string{"all_sessions", "tz"}
+
+var minOlderThanDays = 1.0
+
+// BulkAnalysisFilter 一括操作の対象の絞り込み（GET /api/analysesのクエリと同じ意味、older_than_daysは作成日時が指定した日数より前）
+type BulkAnalysisFilter struct {
+	SavedSearchFilters
+	OlderThanDays int `json:"older_than_days,omitempty"`
+}
+
+// BulkAnalysesRequest POST /api/analyses/bulk-delete・bulk-cancel（idsまたはfilterのどちらか）
+type BulkAnalysesRequest struct {
+	IDs    []string            `json:"ids"`
+	Filter *BulkAnalysisFilter `json:"filter"`
+	DryRun bool                `json:"dry_run"`
+}
+
+// bulkFilterSchema 一括操作の絞り込み（並び順は使わない）
+var bulkFilterSchema = func() objectSchema {
+	schema := objectSchema{
+		"older_than_days": {Type: typeInteger, Min: &minOlderThanDays},
+	}
+	for key, field := range savedSearchFiltersSchema {
+		if key != "sort" {
+			schema[key] = field
+		}
+	}
+	return schema
+}()
+
+// bulkAnalysesSchema POST /api/analyses/bulk-delete・bulk-cancel
+var bulkAnalysesSchema = objectSchema{
+	"ids":     {Type: typeArray, Items: &fieldSchema{Type: typeString, NonEmpty: true}},
+	"filter":  {Type: typeObject, Properties: &bulkFilterSchema},
+	"dry_run": {Type: typeBoolean},
+}
+
+// empty 固定以外の条件が1つも指定されていないか（すべての解析を対象にしないように拒否する）
+func (f *BulkAnalysisFilter) empty() bool {
+	query := f.query()
+	query.Del("pinned")
+	return len(query) == 0 && f.OlderThanDays == 0
+}
+
+// bulkTargets 一括操作の対象の解析ID（filterの場合は作成日時の古い順にmaxBulkFilterMatches件まで、超える場合はtrue）
+// statusesを指定した場合、filterにstatusがなければそれぞれのステータスの解析を対象にする
+func (r *Routes) bulkTargets(c *fiber.Ctx, req *BulkAnalysesRequest, statuses []string) ([]string, bool, error) {
+	if (len(req.IDs) > 0) == (req.Filter != nil) {
+		return nil, false, fiber.NewError(400, "Exactly one of ids or filter is required")
+	}
+	if req.Filter == nil {
+		ids := make([]string, 0, len(req.IDs))
+		seen := make(map[string]bool)
+		for _, id := range req.IDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > maxBulkDelete {
+			return nil, false, fiber.NewError(400, fmt.Sprintf("Too many ids (max %d)", maxBulkDelete))
+		}
+		return ids, false, nil
+	}
+
+	if r.db == nil {
+		return nil, false, fiber.NewError(503, "Database not configured")
+	}
+	filter := req.Filter
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return nil, false, fiber.NewError(400, err.Error())
+	}
+	if err := filter.normalize(loc); err != nil {
+		return nil, false, fiber.NewError(400, err.Error())
+	}
+	if filter.empty() {
+		return nil, false, fiber.NewError(400, "filter must have at least one condition")
+	}
+
+	// 絞り込みはGET /api/analysesと同じクエリとして解釈する（セッションの絞り込み・日付のタイムゾーンを含む）
+	query := filter.query()
+	for _, key := range bulkFilterParams {
+		if v := c.Query(key); v != "" {
+			query.Set(key, v)
+		}
+	}
+	c.Request().URI().SetQueryString(query.Encode())
+	filters, err := r.analysisFilters(c)
+	if err != nil {
+		return nil, false, fiber.NewError(400, err.Error())
+	}
+	if filter.OlderThanDays > 0 {
+		// toも指定した場合は早い方まで
+		before := time.Now().UTC().AddDate(0, 0, -filter.OlderThanDays)
+		to, ok := filters["to"].(string)
+		if t, err := time.Parse(time.RFC3339Nano, to); !ok || err != nil || before.Before(t) {
+			filters["to"] = before.Format(time.RFC3339Nano)
+		}
+	}
+	// 古い順に処理する（繰り返し呼び出すと残りが対象になる）
+	filters[storage.FilterSort] = []storage.SortKey{{Field: "created_at"}}
+	filters["limit"] = maxBulkFilterMatches + 1
+
+	if filter.Status != "" || len(statuses) == 0 {
+		statuses = []string{filter.Status}
+	}
+	ids := make([]string, 0)
+	hasMore := false
+	for _, status := range statuses {
+		if hasMore {
+			break
+		}
+		if status != "" {
+			filters["status"] = status
+		}
+		records, err := r.listAnalysisRecords(c.UserContext(), filters)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, record := range records {
+			if len(ids) == maxBulkFilterMatches {
+				hasMore = true
+				break
+			}
+			ids = append(ids, record.ID)
+		}
+	}
+	return ids, hasMore, nil
+}
+
+// bulkError bulkTargetsのエラーをレスポンスに変換する
+func bulkError(c *fiber.Ctx, err error) error {
+	if e, ok := err.(*fiber.Error); ok {
+		return c.Status(e.Code).JSON(fiber.Map{
+			"error": e.Message,
+		})
+	}
+	if ctxErr := c.UserContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// bulkDeleteAnalyses POST /api/analyses/bulk-delete 解析をIDの一覧または絞り込み（例: 30日より前に失敗した解析）で一括削除する
+// 1件ずつ削除し、失敗した解析は理由とともにfailedで返す（dry_run=trueの場合は影響のみ返す）
+func (r *Routes) bulkDeleteAnalyses(c *fiber.Ctx) error {
+	var req BulkAnalysesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	// 絞り込みで削除する場合、固定した解析はpinned=trueを指定しない限り対象外
+	if req.Filter != nil && req.Filter.Pinned == nil {
+		unpinned := false
+		req.Filter.Pinned = &unpinned
+	}
+	ids, hasMore, err := r.bulkTargets(c, &req, nil)
+	if err != nil {
+		return bulkError(c, err)
+	}
+
+	if req.DryRun {
+		response, err := r.bulkDeleteDryRun(c, ids)
+		if err != nil {
+			return err
+		}
+		response["has_more"] = hasMore
+		return c.JSON(response)
+	}
+
+	deleted, failed := r.bulkDelete(c, ids)
+	return c.JSON(fiber.Map{
+		"message":  "Bulk delete completed",
+		"matched":  len(ids),
+		"deleted":  deleted,
+		"failed":   failed,
+		"has_more": hasMore,
+	})
+}
+
+// bulkCancelAnalyses POST /api/analyses/bulk-cancel キュー待ち・実行中の解析をIDの一覧または絞り込みで一括キャンセルする
+// 後処理の完了は待たない（状態はGET /api/analyses/:idで確認する）。キャンセルできない解析は理由とともにfailedで返す
+func (r *Routes) bulkCancelAnalyses(c *fiber.Ctx) error {
+	var req BulkAnalysesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.DryRun {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "dry_run is only supported for bulk-delete",
+		})
+	}
+	if req.Filter != nil && req.Filter.Status != "" &&
+		req.Filter.Status != string(jobs.StatusQueued) && req.Filter.Status != string(jobs.StatusRunning) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "filter.status must be queued or running",
+		})
+	}
+	ids, hasMore, err := r.bulkTargets(c, &req, []string{string(jobs.StatusQueued), string(jobs.StatusRunning)})
+	if err != nil {
+		return bulkError(c, err)
+	}
+
+	cancelled := make([]string, 0, len(ids))
+	failed := make([]fiber.Map, 0)
+	for _, id := range ids {
+		// 期限切れの場合は残りをキャンセルせずに結果を返す
+		if c.UserContext().Err() != nil {
+			failed = append(failed, fiber.Map{"analysis_id": id, "error": "Request timed out"})
+			continue
+		}
+		if !r.canModifyAnalysis(c, id) {
+			failed = append(failed, fiber.Map{"analysis_id": id, "error": "Analysis belongs to another session"})
+			continue
+		}
+		if err := r.jobManager.CancelJob(id); err != nil {
+			failed = append(failed, fiber.Map{"analysis_id": id, "error": err.Error()})
+			continue
+		}
+		cancelled = append(cancelled, id)
+	}
+
+	requestLog(c).Debugf("Bulk cancel: %d cancelled, %d failed", len(cancelled), len(failed))
+	return c.JSON(fiber.Map{
+		"message":   "Bulk cancel completed",
+		"matched":   len(ids),
+		"cancelled": cancelled,
+		"failed":    failed,
+		"has_more":  hasMore,
+	})
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"dsa-api/jobs"
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// downloadJobBundle GET /api/jobs/:id/download.zip 解析の成果物をまとめたzipを返す
+// ?include_pdb=true で作業ディレクトリのPDBファイル（pdb_files/*.cif）も含める
+// zipはローカルのジョブディレクトリ・R2から読み込みながら作って送る（終了していない解析は409）
+func (r *Routes) downloadJobBundle(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	status, ok := r.analysisStatus(c, id)
+	if !ok {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+	if !isFinishedStatus(status) {
+		return c.Status(409).JSON(fiber.Map{
+			"error":  "Job not finished",
+			"status": status,
+		})
+	}
+
+	opts := jobs.BundleOptions{IncludePDB: c.QueryBool("include_pdb")}
+	// 長さが分からないストリームのため、ダウンロード量は送った分をここで集計する
+	keys := r.egressKeys(c)
+	c.Set("Content-Type", "application/zip")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", id))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		out := &countingWriter{w: w}
+		// リクエストの期限はハンドラーから戻った時点で終わっているため使わない（R2の取得ごとにタイムアウトがある）
+		files, err := r.jobManager.WriteArtifactBundle(context.Background(), id, opts, out)
+		if err == nil {
+			err = w.Flush()
+		}
+		r.egress.add(keys, out.n)
+		if err != nil {
+			requestLog(c).Warnf("Failed to send bundle of %s after %d files: %v", id, files, err)
+		}
+	})
+	return nil
+}
+
+// analysisStatus メモリ上のジョブ、なければDBのレコードのステータス
+func (r *Routes) analysisStatus(c *fiber.Ctx, id string) (jobs.JobStatus, bool) {
+	if job, err := r.jobManager.GetJob(id); err == nil {
+		return job.Status, true
+	}
+	if r.db == nil {
+		return "", false
+	}
+	record, err := r.getAnalysisRecord(c.UserContext(), id)
+	if err != nil || record == nil {
+		return "", false
+	}
+	return jobs.JobStatus(record.Status), true
+}
+
+// countingWriter 書き込んだバイト数を数える
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"crypto/md5"
+	"dsa-api/storage"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// sendR2Artifact R2のオブジェクトをメモリに読み込まずに返す（ETag・Last-Modified、If-None-Match・If-Modified-Since、Rangeに対応）
+// contentTypeが空の場合はR2に保存されたContent-Typeを使う
+// checksumがある場合はX-Checksum-SHA256で返し、全体を返すときは送信しながら検証する（一致しない場合は送信を中断する）
+// 取得できなかった場合はfalseを返し、呼び出し側はローカルファイル等にフォールバックする
+func (r *Routes) sendR2Artifact(c *fiber.Ctx, key, contentType string, checksum *storage.ArtifactChecksum) (bool, error) {
+	req := storage.ObjectRequest{IfNoneMatch: c.Get(fiber.HeaderIfNoneMatch)}
+	if req.IfNoneMatch == "" {
+		if t, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince)); err == nil {
+			req.IfModifiedSince = t
+		}
+	}
+	// If-Rangeは検証できないため、指定されている場合は全体を返す
+	if c.Get(fiber.HeaderIfRange) == "" {
+		if rng := c.Get(fiber.HeaderRange); isSingleByteRange(rng) {
+			req.Range = rng
+		}
+	}
+
+	// ボディはハンドラーが返った後に送信されるため、リクエストのコンテキスト（withTimeoutで取り消される）は使わない
+	ctx, cancel := context.WithTimeout(context.Background(), r.longRouteTimeout)
+	stream, err := r.r2.GetObjectStream(ctx, key, req)
+	if err != nil {
+		cancel()
+		switch {
+		case errors.Is(err, storage.ErrNotModified):
+			return true, c.SendStatus(304)
+		case errors.Is(err, storage.ErrRangeNotSatisfiable):
+			return true, c.SendStatus(416)
+		}
+		requestLog(c).Warnf("Failed to stream %s from R2: %v", key, err)
+		return false, nil
+	}
+
+	if contentType == "" {
+		contentType = stream.ContentType
+	}
+	if contentType != "" {
+		c.Set("Content-Type", contentType)
+	}
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if stream.ETag != "" {
+		c.Set(fiber.HeaderETag, stream.ETag)
+	}
+	if !stream.LastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, stream.LastModified.UTC().Format(http.TimeFormat))
+	}
+	body := stream.Body
+	if checksum != nil {
+		c.Set(checksumHeader, checksum.SHA256)
+		if stream.ContentRange == "" {
+			body = storage.NewChecksumReader(body, *checksum)
+		}
+	}
+	if stream.ContentRange != "" {
+		c.Set(fiber.HeaderContentRange, stream.ContentRange)
+		c.Status(206)
+	}
+	// ストリームは送信後に閉じられる
+	return true, c.SendStream(&cancelOnClose{ReadCloser: body, cancel: cancel}, int(stream.ContentLength))
+}
+
+// 成果物のアップロード時のSHA-256（16進数）を返すヘッダー
+const checksumHeader = "X-Checksum-SHA256"
+
+// artifactChecksum 成果物のアップロード時のSHA-256（記録されていない・取得できない場合はnil）
+func (r *Routes) artifactChecksum(c *fiber.Ctx, id, name string) *storage.ArtifactChecksum {
+	checksums, err := r.jobManager.ArtifactChecksums(c.UserContext(), id)
+	if err != nil {
+		requestLog(c).Warnf("Failed to load artifact checksums of %s: %v", id, err)
+		return nil
+	}
+	if checksum, ok := checksums[name]; ok {
+		return &checksum
+	}
+	return nil
+}
+
+// verifyArtifactData R2から読み込んだ成果物を検証する（一致しない場合は502を返してtrue）
+func verifyArtifactData(c *fiber.Ctx, checksum *storage.ArtifactChecksum, data []byte) (bool, error) {
+	if checksum == nil {
+		return false, nil
+	}
+	if err := checksum.Verify(data); err != nil {
+		requestLog(c).Errorf("%v", err)
+		return true, c.Status(502).JSON(fiber.Map{
+			"error": fmt.Sprintf("Checksum mismatch for %s", checksum.Name),
+		})
+	}
+	c.Set(checksumHeader, checksum.SHA256)
+	return false, nil
+}
+
+// cancelOnClose ボディを閉じたときにR2の取得のコンテキストを取り消す
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// sendConditional メモリ上の成果物を返す（ETagは内容のMD5でR2のETagと揃える、modTimeがゼロの場合はLast-Modifiedを付けない）
+func sendConditional(c *fiber.Ctx, data []byte, modTime time.Time, contentType string) error {
+	sum := md5.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	c.Set("Content-Type", contentType)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderETag, etag)
+	if !modTime.IsZero() {
+		c.Set(fiber.HeaderLastModified, modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.Get(fiber.HeaderIfNoneMatch); inm != "" {
+		if etagMatches(inm, etag) {
+			return c.SendStatus(304)
+		}
+	} else if t, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince)); err == nil && !modTime.IsZero() && !modTime.Truncate(time.Second).After(t) {
+		return c.SendStatus(304)
+	}
+
+	rng := c.Get(fiber.HeaderRange)
+	if ifRange := c.Get(fiber.HeaderIfRange); ifRange != "" && ifRange != etag {
+		rng = ""
+	}
+	if !isSingleByteRange(rng) {
+		return c.Send(data)
+	}
+	start, end, ok := parseByteRange(rng, int64(len(data)))
+	if !ok {
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", len(data)))
+		return c.SendStatus(416)
+	}
+	c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+	return c.Status(206).Send(data[start : end+1])
+}
+
+// etagMatches If-None-MatchにETagが含まれるか（弱いETagも同じとみなす）
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// isSingleByteRange 1つの範囲のRangeヘッダーか（複数の範囲は対応せず全体を返す）
+func isSingleByteRange(rng string) bool {
+	spec, ok := strings.CutPrefix(rng, "bytes=")
+	return ok && spec != "" && !strings.Contains(spec, ",")
+}
+
+// parseByteRange bytes=start-end、bytes=start-、bytes=-suffixを[start, end]に変換する（範囲外・不正な形式はfalse）
+func parseByteRange(rng string, size int64) (int64, int64, bool) {
+	first, last, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(rng, "bytes=")), "-")
+	if !ok {
+		return 0, 0, false
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
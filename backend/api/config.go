@@ -0,0 +1,62 @@
+package api
+
+import (
+	"dsa-api/jobs"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientConfig フロントエンドに公開する設定
+// 秘密情報（DB接続文字列やR2のキーなど）は絶対に含めないこと
+type ClientConfig struct {
+	Features      map[string]bool        `json:"features"`
+	MaxUploadSize int                    `json:"max_upload_size"`
+	DefaultParams map[string]interface{} `json:"default_params"`
+	AuthMode      string                 `json:"auth_mode"`
+	Viewer        ViewerConfig           `json:"viewer"`
+	// 画面に表示するインスタンス名（GET /api/instanceのname）
+	InstanceName string `json:"instance_name"`
+}
+
+// ViewerConfig Mol*ビューアの表示オプション
+type ViewerConfig struct {
+	ShowSequence bool   `json:"show_sequence"`
+	PDBSource    string `json:"pdb_source"`
+}
+
+// SetClientConfig /api/configで返す設定を設定する
+func (r *Routes) SetClientConfig(cfg ClientConfig) {
+	r.clientConfig = cfg
+}
+
+func (r *Routes) getConfig(c *fiber.Ctx) error {
+	cfg := r.clientConfig
+	// SetClientConfigのマップは全リクエストで共有するため、リクエストごとにコピーしてから上書きする
+	cfg.Features = make(map[string]bool, len(r.clientConfig.Features))
+	for name, enabled := range r.clientConfig.Features {
+		cfg.Features[name] = enabled
+	}
+	// 実際の構成から判定できるフラグは常に上書きする
+	cfg.Features["persistence"] = r.db != nil
+	cfg.Features["object_storage"] = r.r2 != nil
+	cfg.Features["history"] = r.db != nil
+	cfg.Features["compare"] = r.db != nil
+	cfg.Features["read_only"] = r.readOnly
+	cfg.Features["sessions"] = !r.sessionless
+	cfg.Features["accounts"] = r.db != nil && !r.sessionless
+	cfg.Features["email_notifications"] = r.notifications != nil
+	cfg.Features["archive"] = r.jobManager.ArchiveEnabled()
+	cfg.DefaultParams = jobs.DefaultAnalysisParams().Map()
+	cfg.InstanceName = r.instance.Name
+	if cfg.InstanceName == "" {
+		cfg.InstanceName = defaultInstanceName
+	}
+	if cfg.AuthMode == "" {
+		cfg.AuthMode = "session"
+	}
+	if cfg.Viewer.PDBSource == "" {
+		cfg.Viewer.PDBSource = "rcsb"
+	}
+
+	return c.JSON(cfg)
+}
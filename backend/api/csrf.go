@@ -0,0 +1,97 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// CSRFトークンを保存するCookie（ダブルサブミット方式、ヘッダーの値と一致する必要がある）
+	csrfCookieName = "dsa_csrf_token"
+	// 状態を変更するリクエストでCSRFトークンを指定するヘッダー
+	csrfHeaderName = "X-CSRF-Token"
+	csrfTokenBytes = 32
+)
+
+// getCSRFToken GET /api/csrf CSRFトークンを発行する（Cookieにも設定し、既にある場合は同じトークンを返す）
+// 別オリジンのフロントエンドはCookieを読めないため、レスポンスのtokenをX-CSRF-Tokenヘッダーに指定する
+func (r *Routes) getCSRFToken(c *fiber.Ctx) error {
+	token, err := ensureCSRFToken(c)
+	if err != nil {
+		requestLog(c).Errorf("Failed to generate CSRF token: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to issue CSRF token",
+		})
+	}
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(fiber.Map{
+		"token":  token,
+		"header": csrfHeaderName,
+	})
+}
+
+// ensureCSRFToken CookieのCSRFトークンを返す（ない・形式が不正な場合は生成してCookieに設定する）
+func ensureCSRFToken(c *fiber.Ctx) (string, error) {
+	if token := c.Cookies(csrfCookieName); validCSRFToken(token) {
+		return token, nil
+	}
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	c.Cookie(&fiber.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Expires:  time.Now().Add(30 * 24 * time.Hour), // セッションCookieと同じ30日間
+		HTTPOnly: true,
+		SameSite: "Lax",
+		Path:     "/",
+	})
+	return token, nil
+}
+
+func validCSRFToken(token string) bool {
+	if len(token) != csrfTokenBytes*2 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// csrfGuard 状態を変更するリクエスト（POST・PATCH・PUT・DELETE）で、Cookieによる認証（セッション・ログイン）がある場合に
+// X-CSRF-TokenヘッダーとCSRFトークンのCookieの一致を必須とし、一致しない場合は403を返す
+// 次のリクエストは対象外:
+//   - Cookieのないリクエスト（ブラウザが自動で送る資格情報がない）
+//   - X-API-KeyまたはAuthorizationヘッダーのあるリクエスト（ブラウザは自動で付けず、別オリジンからはCORSのプリフライトで拒否される）
+//   - セッションレスモード、リモートワーカー用の内部API（WORKER_TOKEN）
+func (r *Routes) csrfGuard(c *fiber.Ctx) error {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return c.Next()
+	}
+	if r.sessionless || strings.HasPrefix(c.Path(), "/api/internal/") {
+		return c.Next()
+	}
+	if c.Get("X-API-Key") != "" || c.Get(fiber.HeaderAuthorization) != "" {
+		return c.Next()
+	}
+	if c.Cookies("dsa_session_id") == "" && c.Cookies(authCookieName) == "" {
+		return c.Next()
+	}
+
+	cookie := c.Cookies(csrfCookieName)
+	header := c.Get(csrfHeaderName)
+	if !validCSRFToken(cookie) || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return c.Status(403).JSON(fiber.Map{
+			"error":         "Invalid or missing CSRF token (get one from GET /api/csrf and send it in the X-CSRF-Token header)",
+			"csrf_required": true,
+		})
+	}
+	return c.Next()
+}
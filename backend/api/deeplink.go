@@ -0,0 +1,102 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// jobQuerySchema GET /api/jobs/new のクエリ（uniprot_idとジョブパラメータを同じ階層で受け取る、パラメータはjobs.ParseAnalysisParamsStringsで検証する）
+var jobQuerySchema = objectSchema{"uniprot_id": createJobSchema["uniprot_id"]}
+
+// newJobFromQuery 外部サイトの「DSAで解析」ボタンなどからのディープリンク
+// 例: GET /api/jobs/new?uniprot_id=P04637&xray_only=true
+// APIキーがない場合は入力済みのドラフトを返し（ブラウザからの場合は解析フォームへリダイレクト）、
+// 有効なAPIキーがある場合はそのままジョブを作成する
+func (r *Routes) newJobFromQuery(c *fiber.Ctx) error {
+	query := c.Queries()
+	delete(query, "api_key")
+
+	values := make(map[string]interface{})
+	if v, ok := query["uniprot_id"]; ok {
+		values["uniprot_id"] = v
+	}
+	if errs := jobQuerySchema.validate("", values); len(errs) > 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error":  "Invalid query parameters",
+			"fields": errs,
+		})
+	}
+
+	uniprotID := jobs.NormalizeUniProtID(values["uniprot_id"].(string))
+	if !jobs.ValidUniProtID(uniprotID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid UniProt ID format: " + uniprotID,
+		})
+	}
+	paramQuery := make(map[string]string, len(query))
+	for name, value := range query {
+		if name != "uniprot_id" {
+			paramQuery[name] = value
+		}
+	}
+	params, err := jobs.ParseAnalysisParamsStrings(paramQuery)
+	if err != nil {
+		return invalidParams(c, "", err)
+	}
+	uniprotID, err = jobs.ResolveUniProtVariant(uniprotID, &params)
+	if err != nil {
+		return invalidParams(c, "", err)
+	}
+
+	// ブラウザから開かれた場合（リンクのクリック）はフロントエンドへリダイレクトする
+	browser := c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) == fiber.MIMETextHTML
+
+	apiKey := requestAPIKey(c)
+	if apiKey == "" {
+		form := url.Values{}
+		for name, value := range query {
+			form.Set(name, value)
+		}
+		formURL := r.share.FrontendURL + "/analysis?" + form.Encode()
+		if browser {
+			return c.Redirect(formURL, fiber.StatusFound)
+		}
+		return c.JSON(fiber.Map{
+			"draft": fiber.Map{
+				"uniprot_id": uniprotID,
+				"params":     params.Map(),
+			},
+			"form_url": formURL,
+		})
+	}
+
+	if !r.validAPIKey(apiKey) {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid API key",
+		})
+	}
+	if r.readOnly {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Server is in read-only mode",
+		})
+	}
+
+	if sessionID := r.jobSessionID(c); sessionID != "" {
+		params.SessionID = sessionID
+	}
+	job, err := r.jobManager.CreateJob(uniprotID, params)
+	if err != nil {
+		return jobCreateError(c, err)
+	}
+	r.linkAnalysisUser(c, job.ID)
+
+	if browser {
+		return c.Redirect(r.share.FrontendURL+"/analysis/result?job_id="+url.QueryEscape(job.ID), fiber.StatusFound)
+	}
+	return c.Status(201).JSON(fiber.Map{
+		"job_id": job.ID,
+		"status": job.Status,
+	})
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 一括削除で一度に指定できる解析の数
+const maxBulkDelete = 100
+
+// DeletionTotals 一括削除の影響の合計
+type DeletionTotals struct {
+	Analyses     int   `json:"analyses"`
+	RunningJobs  int   `json:"running_jobs"`
+	DBRows       int   `json:"db_rows"`
+	R2Objects    int   `json:"r2_objects"`
+	R2Bytes      int64 `json:"r2_bytes"`
+	LocalDirs    int   `json:"local_dirs"`
+	LocalBytes   int64 `json:"local_bytes"`
+	TotalBytes   int64 `json:"total_bytes"`
+	NotFound     int   `json:"not_found"`
+	WithWarnings int   `json:"with_warnings"`
+}
+
+func (t *DeletionTotals) add(impact *jobs.DeletionImpact) {
+	t.Analyses++
+	if impact.CancelsRunningJob {
+		t.RunningJobs++
+	}
+	if impact.DBRow {
+		t.DBRows++
+	}
+	t.R2Objects += impact.R2ObjectCount
+	t.R2Bytes += impact.R2Bytes
+	t.LocalDirs += len(impact.LocalDirs)
+	t.LocalBytes += impact.LocalBytes
+	t.TotalBytes += impact.TotalBytes
+	if impact.NotFound {
+		t.NotFound++
+	}
+	if len(impact.Warnings) > 0 {
+		t.WithWarnings++
+	}
+}
+
+// parseIDs カンマ区切りのIDを分割する（重複は除く）
+func parseIDs(param string) []string {
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(param, ",") {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// deleteAnalysisDryRun DELETE /api/analyses/:id?dry_run=true 削除されるものを返す（何も削除しない）
+func (r *Routes) deleteAnalysisDryRun(c *fiber.Ctx, id string) error {
+	impact := r.jobManager.DeletionImpact(c.UserContext(), id)
+	if err := c.UserContext().Err(); err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{
+		"dry_run": true,
+		"impact":  impact,
+	})
+}
+
+// deleteAnalyses DELETE /api/analyses?ids=a,b,c 解析を一括削除する（dry_run=trueの場合は影響のみ返す）
+func (r *Routes) deleteAnalyses(c *fiber.Ctx) error {
+	ids := parseIDs(c.Query("ids"))
+	if len(ids) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "ids parameter is required",
+		})
+	}
+	if len(ids) > maxBulkDelete {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Too many ids (max %d)", maxBulkDelete),
+		})
+	}
+
+	if c.QueryBool("dry_run") {
+		response, err := r.bulkDeleteDryRun(c, ids)
+		if err != nil {
+			return err
+		}
+		return c.JSON(response)
+	}
+
+	deleted, failed := r.bulkDelete(c, ids)
+	return c.JSON(fiber.Map{
+		"message": "Bulk delete completed",
+		"deleted": deleted,
+		"failed":  failed,
+	})
+}
+
+// bulkDeleteDryRun 解析ごとの削除の影響と合計（期限切れの場合はエラーを返して打ち切る）
+func (r *Routes) bulkDeleteDryRun(c *fiber.Ctx, ids []string) (fiber.Map, error) {
+	impacts := make([]*jobs.DeletionImpact, 0, len(ids))
+	var totals DeletionTotals
+	for _, id := range ids {
+		// 期限切れの場合は打ち切る（504を返す）
+		if err := c.UserContext().Err(); err != nil {
+			return nil, err
+		}
+		impact := r.jobManager.DeletionImpact(c.UserContext(), id)
+		impacts = append(impacts, impact)
+		totals.add(impact)
+	}
+	return fiber.Map{
+		"dry_run":  true,
+		"analyses": impacts,
+		"totals":   totals,
+	}, nil
+}
+
+// bulkDelete 解析を1件ずつ削除し、削除した解析と失敗した解析（理由付き）を返す
+func (r *Routes) bulkDelete(c *fiber.Ctx, ids []string) ([]string, []fiber.Map) {
+	deleted := make([]string, 0, len(ids))
+	failed := make([]fiber.Map, 0)
+	for _, id := range ids {
+		// 期限切れの場合は残りを削除せずに結果を返す
+		if c.UserContext().Err() != nil {
+			failed = append(failed, fiber.Map{"analysis_id": id, "error": "Request timed out"})
+			continue
+		}
+		if !r.canModifyAnalysis(c, id) {
+			failed = append(failed, fiber.Map{"analysis_id": id, "error": "Analysis belongs to another session"})
+			continue
+		}
+		if err := r.jobManager.DeleteJob(id); err != nil {
+			requestLog(c).Errorf("Failed to delete job %s: %v", id, err)
+			failed = append(failed, fiber.Map{"analysis_id": id, "error": err.Error()})
+			continue
+		}
+		deleted = append(deleted, id)
+	}
+
+	requestLog(c).Debugf("Bulk delete: %d deleted, %d failed", len(deleted), len(failed))
+	return deleted, failed
+}
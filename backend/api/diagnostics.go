@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// getDiagnostics GET /api/admin/diagnostics 実行環境（Pythonディレクトリ・インタープリター・dsa_cliのimport）、
+// ストレージ・一時ディレクトリの書き込み、DB・オブジェクトストレージへの疎通を確認する
+// 問題がある場合も200で返し、okとchecksの各項目で結果を示す
+func (r *Routes) getDiagnostics(c *fiber.Ctx) error {
+	result := r.jobManager.Diagnose(c.UserContext())
+	if !result.OK {
+		for _, check := range result.Checks {
+			if !check.OK {
+				requestLog(c).Warnf("Diagnostics check %s failed: %s", check.Name, check.Error)
+			}
+		}
+	}
+	return c.JSON(result)
+}
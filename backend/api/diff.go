@@ -0,0 +1,452 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 差分ヒートマップの一辺の目安（px）。残基数が少ない場合は1残基を複数画素で描画する
+const diffHeatmapTargetSize = 800
+
+// 値が存在しないセル（どちらかの解析で欠損）の色
+var diffMissingColor = color.RGBA{R: 220, G: 220, B: 220, A: 255}
+
+// scoreMatrix score_matrix.jsonの内容（行列は残基番号-1でインデックス、欠損はnull）
+type scoreMatrix struct {
+	Size   int          `json:"size"`
+	Values [][]*float64 `json:"values"`
+}
+
+func (s *scoreMatrix) at(i, j int) (float64, bool) {
+	if i >= len(s.Values) || j >= len(s.Values[i]) || s.Values[i][j] == nil {
+		return 0, false
+	}
+	return *s.Values[i][j], true
+}
+
+// getHeatmapDiff 2つの解析のスコア行列の差（b - a）をヒートマップとして返す
+// 正の差は赤、負の差は青で、色の濃さは差の絶対値の最大値（またはmaxパラメータ）で正規化する
+func (r *Routes) getHeatmapDiff(c *fiber.Ctx) error {
+	idA := c.Query("a")
+	idB := c.Query("b")
+	if idA == "" || idB == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "a and b parameters are required",
+		})
+	}
+
+	var scale float64
+	if v := c.Query("max"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || math.IsInf(f, 0) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "max must be a positive number",
+			})
+		}
+		scale = f
+	}
+
+	matrices := make([]*scoreMatrix, 0, 2)
+	for _, id := range []string{idA, idB} {
+		matrix, status, err := r.loadScoreMatrix(c.UserContext(), id)
+		if err != nil {
+			// 期限切れの場合は打ち切る（504を返す）
+			if ctxErr := c.UserContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		matrices = append(matrices, matrix)
+	}
+
+	img, maxAbs := renderHeatmapDiff(matrices[0], matrices[1], scale)
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to encode heatmap",
+		})
+	}
+	c.Set("Content-Type", "image/png")
+	c.Set("X-Diff-Max-Abs", strconv.FormatFloat(maxAbs, 'f', -1, 64))
+	return c.Send(b.Bytes())
+}
+
+// loadScoreMatrix 完了した解析のスコア行列を読み込む（失敗した場合は返すべきステータスコードとエラー）
+func (r *Routes) loadScoreMatrix(ctx context.Context, id string) (*scoreMatrix, int, error) {
+	target, err := r.loadShareTarget(ctx, id)
+	if err != nil {
+		return nil, 404, fmt.Errorf("%s: %v", id, err)
+	}
+
+	if at := r.artifactsExpiredAt(ctx, target.ID); at != nil {
+		return nil, 410, fmt.Errorf("Artifacts of %s have expired (re-run the analysis to regenerate them)", id)
+	}
+	data, err := r.loadArtifact(ctx, target.ID, "score_matrix.json", nil)
+	if err != nil {
+		// スコア行列の保存以前に実行された解析には存在しない
+		return nil, 404, fmt.Errorf("Score matrix not found for %s (re-run the analysis to generate it)", id)
+	}
+	var matrix scoreMatrix
+	if err := json.Unmarshal(data, &matrix); err != nil {
+		return nil, 500, fmt.Errorf("Failed to parse score matrix for %s", id)
+	}
+	return &matrix, 0, nil
+}
+
+// renderHeatmapDiff b - a を青・白・赤の発散カラースケールで描画する
+// scaleが0の場合は差の絶対値の最大値で正規化する。正規化に使った値も返す
+func renderHeatmapDiff(a, b *scoreMatrix, scale float64) (image.Image, float64) {
+	n := a.Size
+	if b.Size > n {
+		n = b.Size
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	diff := make([]float64, n*n)
+	valid := make([]bool, n*n)
+	var maxAbs float64
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			va, okA := a.at(i, j)
+			vb, okB := b.at(i, j)
+			if !okA || !okB {
+				continue
+			}
+			d := vb - va
+			diff[i*n+j] = d
+			valid[i*n+j] = true
+			if abs := math.Abs(d); abs > maxAbs {
+				maxAbs = abs
+			}
+		}
+	}
+	if scale <= 0 {
+		scale = maxAbs
+	}
+
+	cell := diffHeatmapTargetSize / n
+	if cell < 1 {
+		cell = 1
+	}
+	img := image.NewRGBA(image.Rect(0, 0, n*cell, n*cell))
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			col := diffMissingColor
+			if valid[i*n+j] {
+				col = divergingColor(diff[i*n+j], scale)
+			}
+			for y := i * cell; y < (i+1)*cell; y++ {
+				for x := j * cell; x < (j+1)*cell; x++ {
+					img.SetRGBA(x, y, col)
+				}
+			}
+		}
+	}
+	return img, maxAbs
+}
+
+// divergingColor 0を白、+scaleを赤、-scaleを青とする色を返す（範囲外は端の色）
+func divergingColor(v, scale float64) color.RGBA {
+	t := 0.0
+	if scale > 0 {
+		t = math.Max(-1, math.Min(1, v/scale))
+	}
+	fade := uint8(math.Round(255 * (1 - math.Abs(t))))
+	if t >= 0 {
+		return color.RGBA{R: 255, G: fade, B: fade, A: 255}
+	}
+	return color.RGBA{R: fade, G: fade, B: 255, A: 255}
+}
+
+// 差分で比較するresult.jsonのstatisticsの数値（pdb_ids等の配列・文字列は除く）
+var diffStatisticsKeys = []string{"entries", "chains", "length", "length_percent", "umf", "resolution"}
+
+// analysisResult result.jsonのうち差分に使う部分
+type analysisResult struct {
+	UniProtID    string                 `json:"uniprot_id"`
+	Parameters   map[string]interface{} `json:"parameters"`
+	Statistics   map[string]interface{} `json:"statistics"`
+	ScoreSummary map[string]interface{} `json:"score_summary"`
+}
+
+// metricDelta 1つの指標の値と差（b - a、どちらかがない場合はnull）
+type metricDelta struct {
+	A     *float64 `json:"a"`
+	B     *float64 `json:"b"`
+	Delta *float64 `json:"delta"`
+}
+
+// residueDelta 残基ごとの平均スコアの差（残基番号は1始まり）
+type residueDelta struct {
+	Residue int      `json:"residue"`
+	A       *float64 `json:"a"`
+	B       *float64 `json:"b"`
+	Delta   *float64 `json:"delta"`
+}
+
+// getAnalysisDiff GET /api/analyses/diff?a=id1&b=id2 2つの完了した解析の結果の差分（b - a）を返す
+// 残基ごとのスコアの差（score_matrix.jsonの行の平均）、PDBエントリの集合の違い、メトリクス・パラメータの違いを含む
+func (r *Routes) getAnalysisDiff(c *fiber.Ctx) error {
+	idA := c.Query("a")
+	idB := c.Query("b")
+	if idA == "" || idB == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "a and b parameters are required",
+		})
+	}
+
+	results := make([]*analysisResult, 0, 2)
+	for _, id := range []string{idA, idB} {
+		result, status, err := r.loadAnalysisResult(c.UserContext(), id)
+		if err != nil {
+			// 期限切れの場合は打ち切る（504を返す）
+			if ctxErr := c.UserContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		results = append(results, result)
+	}
+	a, b := results[0], results[1]
+
+	response := fiber.Map{
+		"a":          fiber.Map{"id": idA, "uniprot_id": a.UniProtID},
+		"b":          fiber.Map{"id": idB, "uniprot_id": b.UniProtID},
+		"metrics":    diffMetrics(a, b),
+		"parameters": diffParameters(a.Parameters, b.Parameters),
+		"pdb_ids":    diffPDBIDs(a.Statistics["pdb_ids"], b.Statistics["pdb_ids"]),
+	}
+
+	// 残基ごとのスコアはスコア行列から求める（スコア行列の保存以前に実行された解析はnull）
+	var matrices []*scoreMatrix
+	for _, id := range []string{idA, idB} {
+		matrix, _, err := r.loadScoreMatrix(c.UserContext(), id)
+		if err != nil {
+			if ctxErr := c.UserContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			break
+		}
+		matrices = append(matrices, matrix)
+	}
+	if len(matrices) == 2 {
+		residues, summary := diffResidueScores(matrices[0], matrices[1])
+		response["residues"] = residues
+		response["residue_summary"] = summary
+	} else {
+		response["residues"] = nil
+		response["residue_summary"] = nil
+	}
+	return c.JSON(response)
+}
+
+// loadAnalysisResult 完了した解析のresult.jsonを読み込む（失敗した場合は返すべきステータスコードとエラー）
+func (r *Routes) loadAnalysisResult(ctx context.Context, id string) (*analysisResult, int, error) {
+	target, err := r.loadShareTarget(ctx, id)
+	if err != nil {
+		return nil, 404, fmt.Errorf("%s: %v", id, err)
+	}
+
+	if at := r.artifactsExpiredAt(ctx, target.ID); at != nil {
+		return nil, 410, fmt.Errorf("Artifacts of %s have expired (re-run the analysis to regenerate them)", id)
+	}
+	data, err := r.loadArtifact(ctx, target.ID, "result.json", target.ResultKey)
+	if err != nil {
+		return nil, 404, fmt.Errorf("Result not found for %s", id)
+	}
+	var result analysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, 500, fmt.Errorf("Failed to parse result for %s", id)
+	}
+	if result.UniProtID == "" {
+		result.UniProtID = target.UniProtID
+	}
+	return &result, 0, nil
+}
+
+// diffMetrics score_summaryとstatisticsの数値の差（score_summaryのキーはそのまま、statisticsはstatistics.<キー>）
+func diffMetrics(a, b *analysisResult) map[string]metricDelta {
+	metrics := make(map[string]metricDelta)
+	keys := make(map[string]bool)
+	for key := range a.ScoreSummary {
+		keys[key] = true
+	}
+	for key := range b.ScoreSummary {
+		keys[key] = true
+	}
+	for key := range keys {
+		if delta, ok := newMetricDelta(a.ScoreSummary[key], b.ScoreSummary[key]); ok {
+			metrics[key] = delta
+		}
+	}
+	for _, key := range diffStatisticsKeys {
+		if delta, ok := newMetricDelta(a.Statistics[key], b.Statistics[key]); ok {
+			metrics["statistics."+key] = delta
+		}
+	}
+	return metrics
+}
+
+// newMetricDelta どちらも数値でない場合はfalse
+func newMetricDelta(va, vb interface{}) (metricDelta, bool) {
+	fa, okA := va.(float64)
+	fb, okB := vb.(float64)
+	if !okA && !okB {
+		return metricDelta{}, false
+	}
+	var delta metricDelta
+	if okA {
+		delta.A = &fa
+	}
+	if okB {
+		delta.B = &fb
+	}
+	if okA && okB {
+		d := fb - fa
+		delta.Delta = &d
+	}
+	return delta, true
+}
+
+// diffParameters 値が異なるパラメータだけを返す
+func diffParameters(a, b map[string]interface{}) map[string]fiber.Map {
+	changed := make(map[string]fiber.Map)
+	keys := make(map[string]bool)
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	for key := range keys {
+		if fmt.Sprint(a[key]) != fmt.Sprint(b[key]) {
+			changed[key] = fiber.Map{"a": a[key], "b": b[key]}
+		}
+	}
+	return changed
+}
+
+// diffPDBIDs statistics.pdb_idsの集合の違い（共通・aのみ・bのみ、それぞれソート済み）
+func diffPDBIDs(va, vb interface{}) fiber.Map {
+	setA := stringSet(va)
+	setB := stringSet(vb)
+	common := make([]string, 0)
+	onlyA := make([]string, 0)
+	onlyB := make([]string, 0)
+	for id := range setA {
+		if setB[id] {
+			common = append(common, id)
+		} else {
+			onlyA = append(onlyA, id)
+		}
+	}
+	for id := range setB {
+		if !setA[id] {
+			onlyB = append(onlyB, id)
+		}
+	}
+	sort.Strings(common)
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	return fiber.Map{
+		"common":  common,
+		"only_a":  onlyA,
+		"only_b":  onlyB,
+		"changed": len(onlyA) > 0 || len(onlyB) > 0,
+	}
+}
+
+func stringSet(v interface{}) map[string]bool {
+	set := make(map[string]bool)
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// residueScores 残基ごとの平均スコア（行の欠損でない値の平均、値がない残基はnil）
+func residueScores(m *scoreMatrix) []*float64 {
+	scores := make([]*float64, m.Size)
+	for i := 0; i < m.Size; i++ {
+		var sum float64
+		var count int
+		for j := 0; j < m.Size; j++ {
+			if i == j {
+				continue
+			}
+			if v, ok := m.at(i, j); ok {
+				sum += v
+				count++
+			}
+		}
+		if count > 0 {
+			mean := sum / float64(count)
+			scores[i] = &mean
+		}
+	}
+	return scores
+}
+
+// diffResidueScores 残基ごとの平均スコアの差と、差の集計（比較できた残基数・差の絶対値の平均と最大）
+func diffResidueScores(a, b *scoreMatrix) ([]residueDelta, fiber.Map) {
+	scoresA := residueScores(a)
+	scoresB := residueScores(b)
+	n := len(scoresA)
+	if len(scoresB) > n {
+		n = len(scoresB)
+	}
+
+	residues := make([]residueDelta, 0, n)
+	var compared int
+	var sumAbs, maxAbs float64
+	maxResidue := 0
+	for i := 0; i < n; i++ {
+		rd := residueDelta{Residue: i + 1}
+		if i < len(scoresA) {
+			rd.A = scoresA[i]
+		}
+		if i < len(scoresB) {
+			rd.B = scoresB[i]
+		}
+		if rd.A != nil && rd.B != nil {
+			d := *rd.B - *rd.A
+			rd.Delta = &d
+			compared++
+			sumAbs += math.Abs(d)
+			if math.Abs(d) > maxAbs || maxResidue == 0 {
+				maxAbs = math.Abs(d)
+				maxResidue = i + 1
+			}
+		}
+		residues = append(residues, rd)
+	}
+
+	summary := fiber.Map{
+		"compared": compared,
+	}
+	if compared > 0 {
+		summary["mean_abs_delta"] = sumAbs / float64(compared)
+		summary["max_abs_delta"] = maxAbs
+		summary["max_abs_delta_residue"] = maxResidue
+	}
+	return residues, summary
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dryRunJob POST /api/jobs の dry_run=true 検証済みのUniProt IDとパラメータから、実行される内容を返す（ジョブは作成しない）
+// check_uniprot=true の場合はUniProtにエントリが存在するかも確認する
+func (r *Routes) dryRunJob(c *fiber.Ctx, req CreateJobRequest, params jobs.AnalysisParams) error {
+	plan, err := r.jobManager.DryRun(req.UniProtID, params, jobs.CreateJobOptions{
+		Dedupe:  req.Dedupe,
+		NoCache: req.NoCache,
+	})
+	if err != nil {
+		var envErr *jobs.EnvOverrideError
+		if errors.As(err, &envErr) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": envErr.Error(),
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := fiber.Map{
+		"dry_run":    true,
+		"uniprot_id": plan.UniProtID,
+		"params":     plan.Params,
+		"executor":   plan.Executor,
+	}
+	if plan.Argv != nil {
+		response["argv"] = plan.Argv
+	}
+	if plan.CachedFrom != "" {
+		response["cached_from"] = plan.CachedFrom
+	}
+	if plan.DuplicateOf != "" {
+		response["duplicate_of"] = plan.DuplicateOf
+	}
+
+	if req.CheckUniProt {
+		exists, err := jobs.CheckUniProtExists(c.UserContext(), req.UniProtID)
+		if err != nil {
+			return c.Status(502).JSON(fiber.Map{
+				"error": "Failed to check UniProt: " + err.Error(),
+			})
+		}
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "UniProt entry not found: " + req.UniProtID,
+			})
+		}
+		response["uniprot_exists"] = true
+	}
+	return c.JSON(response)
+}
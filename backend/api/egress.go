@@ -0,0 +1,181 @@
+package api
+
+import (
+	"dsa-api/logging"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// egressTracker セッションごとの成果物ダウンロード量を月単位で集計する
+// 集計はストレージディレクトリのegress_usage.jsonに保存し、再起動後も引き継ぐ
+type egressTracker struct {
+	path string
+	// 月ごとの上限（バイト、0は無制限）
+	limit int64
+
+	mu     sync.Mutex
+	period string
+	usage  map[string]int64
+}
+
+// egressState egress_usage.jsonの内容
+type egressState struct {
+	Period string           `json:"period"`
+	Usage  map[string]int64 `json:"usage"`
+}
+
+// EgressUsage セッションの当月のダウンロード量
+type EgressUsage struct {
+	Period     string    `json:"period"`
+	UsedBytes  int64     `json:"used_bytes"`
+	LimitBytes int64     `json:"limit_bytes,omitempty"`
+	ResetsAt   time.Time `json:"resets_at"`
+}
+
+func newEgressTracker(storageDir string) *egressTracker {
+	t := &egressTracker{
+		path:   filepath.Join(storageDir, "egress_usage.json"),
+		period: egressPeriod(time.Now()),
+		usage:  make(map[string]int64),
+	}
+	if data, err := os.ReadFile(t.path); err == nil {
+		var state egressState
+		if err := json.Unmarshal(data, &state); err != nil {
+			logging.Warnf("Failed to parse egress usage: %v", err)
+		} else if state.Period == t.period && state.Usage != nil {
+			t.usage = state.Usage
+		}
+	}
+	return t
+}
+
+// SetEgressLimit セッションごとの月間ダウンロード上限（バイト）を設定する（0以下は無制限）
+func (r *Routes) SetEgressLimit(limit int64) {
+	if limit < 0 {
+		limit = 0
+	}
+	r.egress.mu.Lock()
+	r.egress.limit = limit
+	r.egress.mu.Unlock()
+}
+
+func egressPeriod(now time.Time) string {
+	return now.UTC().Format("2006-01")
+}
+
+// rollover 月が変わっていれば集計をリセットする（t.muを保持して呼ぶ）
+func (t *egressTracker) rollover(now time.Time) {
+	if period := egressPeriod(now); period != t.period {
+		t.period = period
+		t.usage = make(map[string]int64)
+	}
+}
+
+// get セッションの当月の利用状況を返す
+func (t *egressTracker) get(key string) EgressUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.rollover(now)
+	start := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	return EgressUsage{
+		Period:     t.period,
+		UsedBytes:  t.usage[key],
+		LimitBytes: t.limit,
+		ResetsAt:   start.AddDate(0, 1, 0),
+	}
+}
+
+// add ダウンロード量を各集計単位に加算して保存する
+func (t *egressTracker) add(keys []string, n int64) {
+	if n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rollover(time.Now())
+	for _, key := range keys {
+		t.usage[key] += n
+	}
+	if err := t.save(); err != nil {
+		logging.Warnf("Failed to save egress usage: %v", err)
+	}
+}
+
+// save 一時ファイルに書き込んでからリネームする（t.muを保持して呼ぶ）
+func (t *egressTracker) save() error {
+	data, err := json.Marshal(egressState{Period: t.period, Usage: t.usage})
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// egressKeys ダウンロード量を集計する単位
+// セッションCookieは任意に付け替えられるため、IPアドレスでも併せて集計する
+func (r *Routes) egressKeys(c *fiber.Ctx) []string {
+	keys := []string{"ip:" + c.IP()}
+	if sessionID := r.requestSessionID(c); sessionID != "" {
+		keys = append(keys, "session:"+sessionID)
+	}
+	return keys
+}
+
+// usageFor 集計単位のうち最も多く使っているものの利用状況を返す
+func (t *egressTracker) usageFor(keys []string) EgressUsage {
+	var usage EgressUsage
+	for i, key := range keys {
+		if u := t.get(key); i == 0 || u.UsedBytes > usage.UsedBytes {
+			usage = u
+		}
+	}
+	return usage
+}
+
+// egressGuard 成果物のダウンロード量を集計し、月間上限を超えたセッションには429を返す
+func (r *Routes) egressGuard(c *fiber.Ctx) error {
+	keys := r.egressKeys(c)
+	usage := r.egress.usageFor(keys)
+	if usage.LimitBytes > 0 && usage.UsedBytes >= usage.LimitBytes {
+		c.Set("Retry-After", fmt.Sprintf("%d", int(time.Until(usage.ResetsAt).Seconds())+1))
+		return c.Status(429).JSON(fiber.Map{
+			"error": "Monthly download limit exceeded",
+			"usage": usage,
+		})
+	}
+
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	// 成功したレスポンスのみ集計する（SendFileの場合はContent-Lengthから取得）
+	resp := c.Response()
+	if resp.StatusCode() < 200 || resp.StatusCode() >= 300 {
+		return nil
+	}
+	var size int64
+	if resp.IsBodyStream() {
+		size = int64(resp.Header.ContentLength())
+	} else {
+		size = int64(len(resp.Body()))
+	}
+	r.egress.add(keys, size)
+	return nil
+}
+
+// getUsage 現在のセッションの当月のダウンロード量を返す
+func (r *Routes) getUsage(c *fiber.Ctx) error {
+	return c.JSON(r.egress.usageFor(r.egressKeys(c)))
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// getAnalysisEvents 解析の状態遷移・キャンセル要求・再実行などのイベントを古い順に返す
+func (r *Routes) getAnalysisEvents(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	events, err := r.jobManager.ListEvents(c.UserContext(), id)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to list events",
+		})
+	}
+	// イベントがない場合は解析自体が存在するか確認する（イベントログ導入前の解析は空のリスト）
+	if len(events) == 0 {
+		if _, err := r.jobManager.GetJob(id); err != nil {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Analysis not found",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"analysis_id": id,
+		"events":      events,
+	})
+}
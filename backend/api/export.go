@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"dsa-api/storage"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// エクスポートでDBから一度に読み込む解析の数
+	exportPageSize = 1000
+	// limitを指定しない場合にエクスポートする解析の上限
+	exportMaxRows = 100000
+)
+
+// exportMetricColumns エクスポートするメトリクスの列（jobs.extractMetricsが記録する値、method_breakdownは除く）
+var exportMetricColumns = []string{
+	"entries", "chains", "length", "length_percent", "resolution", "umf",
+	"cis_num", "cis_dist_mean", "cis_dist_std", "mean_score", "mean_std",
+}
+
+// exportFormats formatごとの区切り文字・Content-Type・拡張子
+var exportFormats = map[string]struct {
+	comma       rune
+	contentType string
+	ext         string
+}{
+	"csv": {',', "text/csv; charset=utf-8", "csv"},
+	"tsv": {'\t', "text/tab-separated-values; charset=utf-8", "tsv"},
+}
+
+// exportAnalyses GET /api/analyses/export?format=csv 解析の一覧をCSV・TSVで返す（DBが必要）
+// 絞り込みはGET /api/analysesと同じ。2ページ目以降はDBから読み込みながら送る
+func (r *Routes) exportAnalyses(c *fiber.Ctx) error {
+	if r.db == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	}
+
+	format, ok := exportFormats[c.Query("format", "csv")]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "format must be csv or tsv",
+		})
+	}
+	filters, err := r.analysisFilters(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	remaining := exportMaxRows
+	if limit, ok := filters["limit"].(int); ok {
+		remaining = limit
+	}
+	offset, _ := filters["offset"].(int)
+	page := func() map[string]interface{} {
+		f := make(map[string]interface{}, len(filters))
+		for k, v := range filters {
+			f[k] = v
+		}
+		f["limit"] = exportPageSize
+		if remaining < exportPageSize {
+			f["limit"] = remaining
+		}
+		f["offset"] = offset
+		return f
+	}
+
+	// 最初のページの読み込みに失敗した場合はエラーを返す
+	records, err := r.listAnalysisRecords(c.UserContext(), page())
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	c.Set("Content-Type", format.contentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"analyses-%s.%s\"", time.Now().UTC().Format("20060102-150405"), format.ext))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		out := csv.NewWriter(w)
+		out.Comma = format.comma
+		header := append([]string{"id", "uniprot_id", "method", "status", "created_at", "started_at", "finished_at"}, exportMetricColumns...)
+		out.Write(header)
+
+		for {
+			for _, record := range records {
+				out.Write(exportRow(record))
+			}
+			out.Flush()
+			if err := out.Error(); err != nil {
+				// クライアントが切断した
+				return
+			}
+			remaining -= len(records)
+			offset += len(records)
+			if len(records) < exportPageSize || remaining <= 0 {
+				return
+			}
+			// リクエストの期限はハンドラーから戻った時点で終わっているため、ここでは使わない
+			records, err = r.db.SearchAnalyses(context.Background(), page())
+			if err != nil {
+				requestLog(c).Warnf("Failed to export analyses after %d rows: %v", offset, err)
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// exportRow 解析の1行（日時はUTCのRFC3339、値がない列は空）
+func exportRow(record *storage.AnalysisRecord) []string {
+	row := []string{record.ID, record.UniProtID, record.Method, record.Status, formatTime(record.CreatedAt), "", ""}
+	if record.StartedAt != nil {
+		row[5] = formatTime(*record.StartedAt)
+	}
+	if record.FinishedAt != nil {
+		row[6] = formatTime(*record.FinishedAt)
+	}
+	for _, key := range exportMetricColumns {
+		row = append(row, exportValue(record.Metrics[key]))
+	}
+	return row
+}
+
+// exportValue メトリクスの値を表計算ソフトで数値として読める文字列にする（指数表記にしない）
+func exportValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,430 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"dsa-api/jobs"
+	"dsa-api/storage"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// 一度に取得できる解析の上限（成果物のURLは解析ごとに署名するため）
+const maxGraphQLAnalyses = 200
+
+// graphQLRequest POST /api/graphql の本文
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+type graphQLCtxKey struct{}
+
+// gqlAnalysis Analysis型の値（一覧・詳細で共通、成果物は要求された場合のみ取得する）
+type gqlAnalysis struct {
+	ID           string
+	UniProtID    string
+	Method       string
+	Status       string
+	Progress     *int
+	ErrorMessage string
+	CreatedAt    time.Time
+	Params       map[string]interface{}
+	Metrics      map[string]interface{}
+}
+
+func gqlAnalysisFromRecord(record *storage.AnalysisRecord) *gqlAnalysis {
+	a := &gqlAnalysis{
+		ID:        record.ID,
+		UniProtID: record.UniProtID,
+		Method:    record.Method,
+		Status:    record.Status,
+		Progress:  record.Progress,
+		CreatedAt: record.CreatedAt,
+		Params:    record.Params,
+		Metrics:   record.Metrics,
+	}
+	if record.ErrorMessage != nil {
+		a.ErrorMessage = *record.ErrorMessage
+	}
+	return a
+}
+
+func gqlAnalysisFromJob(job *jobs.Job) *gqlAnalysis {
+	method, _ := job.Params["method"].(string)
+	progress := job.Progress
+	return &gqlAnalysis{
+		ID:           job.ID,
+		UniProtID:    job.UniProtID,
+		Method:       method,
+		Status:       string(job.Status),
+		Progress:     &progress,
+		ErrorMessage: job.ErrorMessage,
+		CreatedAt:    job.CreatedAt,
+		Params:       job.Params,
+	}
+}
+
+// gqlMetric 指標の値（数値以外も含むためJSON）
+type gqlMetric struct {
+	Name  string
+	Value interface{}
+}
+
+// gqlArtifact 成果物とURL（GET /api/analyses/:idのartifactsと同じURL）
+type gqlArtifact struct {
+	Name        string
+	ContentType string
+	URL         string
+}
+
+// gqlMetricComparison 比較する解析ごとの同じ指標の値（解析の順）
+type gqlMetricComparison struct {
+	Name   string
+	Values []gqlMetricValue
+}
+
+type gqlMetricValue struct {
+	AnalysisID string
+	Value      interface{}
+}
+
+// jsonScalar 任意のJSONの値（params・metricsの値）
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "任意のJSONの値",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: func(valueAST ast.Value) interface{} { return valueAST.GetValue() },
+})
+
+// graphQLHandler POST /api/graphql 解析・指標・成果物・比較を1回のリクエストで必要なフィールドだけ取得する
+// スキーマは最初の呼び出しで作成する（エラーはGraphQLの形式でerrorsに返す）
+func (r *Routes) graphQLHandler() fiber.Handler {
+	schema, schemaErr := r.newGraphQLSchema()
+	return func(c *fiber.Ctx) error {
+		if schemaErr != nil {
+			requestLog(c).Errorf("Invalid GraphQL schema: %v", schemaErr)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "GraphQL is unavailable",
+			})
+		}
+		var req graphQLRequest
+		if err := c.BodyParser(&req); err != nil || req.Query == "" {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "query is required",
+			})
+		}
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        context.WithValue(c.UserContext(), graphQLCtxKey{}, c),
+		})
+		// 期限切れの場合は打ち切る（504を返す）
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.JSON(result)
+	}
+}
+
+// graphQLFiberCtx リゾルバーからリクエスト（セッション・ログ用）を参照する
+func graphQLFiberCtx(p graphql.ResolveParams) *fiber.Ctx {
+	c, _ := p.Context.Value(graphQLCtxKey{}).(*fiber.Ctx)
+	return c
+}
+
+func (r *Routes) newGraphQLSchema() (graphql.Schema, error) {
+	metricType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Metric",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.Field{Type: jsonScalar},
+		},
+	})
+	artifactType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Artifact",
+		Fields: graphql.Fields{
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"contentType": &graphql.Field{Type: graphql.String},
+			"url":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	analysisType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Analysis",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"uniprotId":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"method":       &graphql.Field{Type: graphql.String},
+			"status":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"progress":     &graphql.Field{Type: graphql.Int},
+			"errorMessage": &graphql.Field{Type: graphql.String},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return formatTime(p.Source.(*gqlAnalysis).CreatedAt), nil
+				},
+			},
+			"params": &graphql.Field{Type: jsonScalar},
+			"metrics": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(metricType)),
+				Description: "指標（namesを指定した場合はその指標のみ、指定した順）",
+				Args: graphql.FieldConfigArgument{
+					"names": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return selectMetrics(p.Source.(*gqlAnalysis).Metrics, stringListArg(p.Args["names"])), nil
+				},
+			},
+			"artifacts": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(artifactType)),
+				Description: "成果物のURL（保持期間を過ぎて削除された場合は空）",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.gqlArtifacts(p.Context, p.Source.(*gqlAnalysis).ID), nil
+				},
+			},
+		},
+	})
+	metricValueType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MetricValue",
+		Fields: graphql.Fields{
+			"analysisId": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"value":      &graphql.Field{Type: jsonScalar},
+		},
+	})
+	metricComparisonType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MetricComparison",
+		Fields: graphql.Fields{
+			"name":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"values": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(metricValueType))},
+		},
+	})
+	comparisonType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Comparison",
+		Fields: graphql.Fields{
+			"analyses": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(analysisType))},
+			"metrics": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(metricComparisonType)),
+				Description: "指標ごとの各解析の値（namesを指定しない場合はいずれかの解析にある指標すべて）",
+				Args: graphql.FieldConfigArgument{
+					"names": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					analyses := p.Source.(map[string]interface{})["analyses"].([]*gqlAnalysis)
+					return compareMetrics(analyses, stringListArg(p.Args["names"])), nil
+				},
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"analyses": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(analysisType)),
+				Description: "解析の一覧（GET /api/analysesと同じ絞り込み、セッションの解析のみ）",
+				Args: graphql.FieldConfigArgument{
+					"uniprotId": &graphql.ArgumentConfig{Type: graphql.String},
+					"method":    &graphql.ArgumentConfig{Type: graphql.String},
+					"status":    &graphql.ArgumentConfig{Type: graphql.String},
+					"from":      &graphql.ArgumentConfig{Type: graphql.String},
+					"to":        &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 50},
+					"offset":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: r.resolveGraphQLAnalyses,
+			},
+			"analysis": &graphql.Field{
+				Type: analysisType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					a, err := r.gqlAnalysis(p.Context, p.Args["id"].(string))
+					if err != nil {
+						return nil, fmt.Errorf("analysis not found")
+					}
+					return a, nil
+				},
+			},
+			"compare": &graphql.Field{
+				Type:        comparisonType,
+				Description: "複数の解析の比較（GET /api/analyses/compareと同じく見つからない解析は除く）",
+				Args: graphql.FieldConfigArgument{
+					"ids": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					ids := stringListArg(p.Args["ids"])
+					if len(ids) == 0 {
+						return nil, fmt.Errorf("at least one id is required")
+					}
+					if len(ids) > maxGraphQLAnalyses {
+						return nil, fmt.Errorf("at most %d ids can be compared", maxGraphQLAnalyses)
+					}
+					analyses := make([]*gqlAnalysis, 0, len(ids))
+					for _, id := range ids {
+						a, err := r.gqlAnalysis(p.Context, id)
+						if err != nil {
+							if ctxErr := p.Context.Err(); ctxErr != nil {
+								return nil, ctxErr
+							}
+							continue
+						}
+						analyses = append(analyses, a)
+					}
+					return map[string]interface{}{"analyses": analyses}, nil
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+// resolveGraphQLAnalyses analysesの絞り込み（REST APIのanalysisFiltersと同じ条件）で一覧を返す
+func (r *Routes) resolveGraphQLAnalyses(p graphql.ResolveParams) (interface{}, error) {
+	if r.db == nil {
+		// DBがない場合はREST APIと同じく空
+		return []*gqlAnalysis{}, nil
+	}
+	c := graphQLFiberCtx(p)
+	filters := make(map[string]interface{})
+	if sessionID := r.listSessionID(c); sessionID != "" {
+		filters["session_id"] = sessionID
+	}
+	for arg, key := range map[string]string{"uniprotId": "uniprot_id", "method": "method", "status": "status"} {
+		if v, ok := p.Args[arg].(string); ok && v != "" {
+			filters[key] = v
+		}
+	}
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"from", "to"} {
+		value, _ := p.Args[name].(string)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeFilter(value, loc, name == "to")
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		filters[name] = t.Format(time.RFC3339Nano)
+	}
+	limit, _ := p.Args["limit"].(int)
+	if limit <= 0 || limit > maxGraphQLAnalyses {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxGraphQLAnalyses)
+	}
+	filters["limit"] = limit
+	if offset, _ := p.Args["offset"].(int); offset > 0 {
+		filters["offset"] = offset
+	}
+
+	records, err := r.listAnalysisRecords(p.Context, filters)
+	if err != nil {
+		requestLog(c).Errorf("Failed to list analyses for GraphQL: %v", err)
+		return nil, fmt.Errorf("failed to list analyses")
+	}
+	analyses := make([]*gqlAnalysis, 0, len(records))
+	for _, record := range records {
+		analyses = append(analyses, gqlAnalysisFromRecord(record))
+	}
+	return analyses, nil
+}
+
+// gqlAnalysis DBから取得し、ない場合はジョブから取得する（GET /api/analyses/:idと同じ）
+func (r *Routes) gqlAnalysis(ctx context.Context, id string) (*gqlAnalysis, error) {
+	if r.db != nil {
+		if record, err := r.getAnalysisRecord(ctx, id); err == nil {
+			return gqlAnalysisFromRecord(record), nil
+		}
+	}
+	job, err := r.jobManager.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	return gqlAnalysisFromJob(job), nil
+}
+
+// gqlArtifacts GET /api/analyses/:idのartifactsのURLを成果物の定義の順に返す
+func (r *Routes) gqlArtifacts(ctx context.Context, id string) []gqlArtifact {
+	response, err := r.analysisResponse(ctx, id)
+	if err != nil {
+		return nil
+	}
+	urls, _ := response["artifacts"].(fiber.Map)
+	artifacts := []gqlArtifact{}
+	for _, a := range jobs.Artifacts() {
+		if a.URLField == "" {
+			continue
+		}
+		if url, ok := urls[a.URLField].(string); ok && url != "" {
+			artifacts = append(artifacts, gqlArtifact{Name: a.Name, ContentType: a.ContentType, URL: url})
+		}
+	}
+	return artifacts
+}
+
+// selectMetrics namesを指定した場合はその順（ない指標は値をnull）、指定しない場合は名前順
+func selectMetrics(metrics map[string]interface{}, names []string) []gqlMetric {
+	if names == nil {
+		names = make([]string, 0, len(metrics))
+		for name := range metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	selected := make([]gqlMetric, 0, len(names))
+	for _, name := range names {
+		selected = append(selected, gqlMetric{Name: name, Value: metrics[name]})
+	}
+	return selected
+}
+
+// compareMetrics 指標ごとに各解析の値を並べる（namesを指定しない場合はいずれかの解析にある指標を名前順）
+func compareMetrics(analyses []*gqlAnalysis, names []string) []gqlMetricComparison {
+	if names == nil {
+		seen := make(map[string]bool)
+		for _, a := range analyses {
+			for name := range a.Metrics {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+	}
+	comparisons := make([]gqlMetricComparison, 0, len(names))
+	for _, name := range names {
+		comparison := gqlMetricComparison{Name: name, Values: make([]gqlMetricValue, 0, len(analyses))}
+		for _, a := range analyses {
+			comparison.Values = append(comparison.Values, gqlMetricValue{AnalysisID: a.ID, Value: a.Metrics[name]})
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons
+}
+
+// stringListArg リストの引数を[]stringに変換する（未指定はnil）
+func stringListArg(arg interface{}) []string {
+	list, ok := arg.([]interface{})
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// グループ化のためにDBから読み込む解析の上限（limit・offsetはグループに対して適用する）
+const groupScanLimit = 5000
+
+// listAnalysisGroups GET /api/analyses?group_by=uniprot_id UniProt IDごとに実行回数・最新の状態・最良の結果をまとめて返す
+func (r *Routes) listAnalysisGroups(c *fiber.Ctx, filters map[string]interface{}) error {
+	limit, _ := filters["limit"].(int)
+	offset, _ := filters["offset"].(int)
+	delete(filters, "offset")
+	filters["limit"] = groupScanLimit
+
+	records, err := r.listAnalysisRecords(c.UserContext(), filters)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	groups := groupAnalysesByUniProt(records)
+	if offset >= len(groups) {
+		groups = groups[:0]
+	} else {
+		groups = groups[offset:]
+	}
+	if limit > 0 && limit < len(groups) {
+		groups = groups[:limit]
+	}
+
+	// タンパク質名等は各グループの最新の解析に記録されたものを使う
+	ids := make([]string, 0, len(groups))
+	for _, g := range groups {
+		ids = append(ids, g["latest"].(fiber.Map)["id"].(string))
+	}
+	proteins := r.jobManager.ProteinInfos(c.UserContext(), ids)
+	for i, g := range groups {
+		addProteinInfo(g, proteins[ids[i]])
+	}
+	return c.JSON(groups)
+}
+
+// groupAnalysesByUniProt 解析をUniProt IDごとにまとめる（最新の解析が新しい順）
+// アイソフォーム・チェーンを指定した解析は正規の配列の解析と混ざらないように別のグループにする
+// 最良の結果は完了した解析のうちエントリ数（なければチェーン数）が最も多いもので、同じ場合は新しいものを選ぶ
+func groupAnalysesByUniProt(records []*storage.AnalysisRecord) []fiber.Map {
+	type group struct {
+		uniprotID string
+		variant   string
+		runs      int
+		statuses  map[string]int
+		latest    *storage.AnalysisRecord
+		best      *storage.AnalysisRecord
+	}
+
+	byID := make(map[string]*group)
+	order := make([]*group, 0)
+	for _, record := range records {
+		variant := jobs.VariantID(record.UniProtID, jobs.AnalysisParamsFromMap(record.Params))
+		g, ok := byID[variant]
+		if !ok {
+			g = &group{uniprotID: record.UniProtID, variant: variant, statuses: make(map[string]int)}
+			byID[variant] = g
+			order = append(order, g)
+		}
+		g.runs++
+		g.statuses[record.Status]++
+		if g.latest == nil || record.CreatedAt.After(g.latest.CreatedAt) {
+			g.latest = record
+		}
+		if record.Status == "done" && record.Metrics != nil && betterRun(record, g.best) {
+			g.best = record
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].latest.CreatedAt.After(order[j].latest.CreatedAt)
+	})
+
+	result := make([]fiber.Map, 0, len(order))
+	for _, g := range order {
+		entry := fiber.Map{
+			"uniprot_id":    g.uniprotID,
+			"run_count":     g.runs,
+			"status_counts": g.statuses,
+			"latest": fiber.Map{
+				"id":         g.latest.ID,
+				"method":     g.latest.Method,
+				"status":     g.latest.Status,
+				"created_at": formatTime(g.latest.CreatedAt),
+			},
+		}
+		if g.variant != g.uniprotID {
+			entry["variant"] = g.variant
+		}
+		if g.best != nil {
+			entry["best"] = fiber.Map{
+				"id":         g.best.ID,
+				"method":     g.best.Method,
+				"created_at": formatTime(g.best.CreatedAt),
+				"metrics":    g.best.Metrics,
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// betterRun recordがcurrentより良い結果か（エントリ数、チェーン数、作成日時の順に比較）
+func betterRun(record, current *storage.AnalysisRecord) bool {
+	if current == nil {
+		return true
+	}
+	for _, key := range []string{"entries", "chains"} {
+		a, b := metricNumber(record.Metrics, key), metricNumber(current.Metrics, key)
+		if a != b {
+			return a > b
+		}
+	}
+	return record.CreatedAt.After(current.CreatedAt)
+}
+
+// metricNumber メトリクスの数値（DBから読み込んだfloat64とGoで設定したintの両方に対応、ない場合は0）
+func metricNumber(metrics map[string]interface{}, key string) float64 {
+	switch v := metrics[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	}
+	return 0
+}
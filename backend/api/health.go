@@ -0,0 +1,33 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// getHealth サーバーと依存サービスの状態を返す
+// オブジェクトストレージのブレーカーが開いている場合は縮退運転中（degraded）として報告する
+func (r *Routes) getHealth(c *fiber.Ctx) error {
+	status := "ok"
+	response := fiber.Map{
+		"database": fiber.Map{
+			"configured": r.db != nil,
+		},
+	}
+
+	objectStorage := fiber.Map{
+		"configured": r.r2 != nil,
+	}
+	if r.r2 != nil {
+		breaker := r.r2.BreakerStatus()
+		objectStorage["breaker"] = breaker
+		if r.r2.Degraded() {
+			status = "degraded"
+		}
+	}
+	response["object_storage"] = objectStorage
+	response["upload_spool"] = r.jobManager.UploadStats()
+	response["read_only"] = r.readOnly
+	response["status"] = status
+
+	return c.JSON(response)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image/png"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 画像の成果物のサイズ（?size=small|medium|full）
+const (
+	imageSizeSmall  = "small"
+	imageSizeMedium = "medium"
+	imageSizeFull   = "full"
+)
+
+// imageSizeWidths 縮小するサイズの最大幅（px、履歴の一覧・モバイル表示用）
+var imageSizeWidths = map[string]int{
+	imageSizeSmall:  320,
+	imageSizeMedium: 800,
+}
+
+// 縮小した画像のキャッシュの上限（バイト、IMAGE_VARIANT_CACHE_SIZEで変更できる）
+const defaultImageVariantCacheSize = 64 << 20
+
+// imageVariantCache 縮小した画像（解析ID・成果物・サイズごと）のキャッシュ
+// 成果物は解析ごとに変わらないため、上限を超えた場合に使われていないものから捨てるだけで無効化はしない
+type imageVariantCache struct {
+	mu      sync.Mutex
+	limit   int64
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type imageVariant struct {
+	key  string
+	data []byte
+}
+
+func newImageVariantCache(limit int64) *imageVariantCache {
+	return &imageVariantCache{
+		limit:   limit,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *imageVariantCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*imageVariant).data, true
+}
+
+func (c *imageVariantCache) put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if int64(len(data)) > c.limit {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.size -= int64(len(elem.Value.(*imageVariant).data))
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.entries[key] = c.order.PushFront(&imageVariant{key: key, data: data})
+	c.size += int64(len(data))
+	for c.size > c.limit {
+		oldest := c.order.Back()
+		variant := oldest.Value.(*imageVariant)
+		c.order.Remove(oldest)
+		delete(c.entries, variant.key)
+		c.size -= int64(len(variant.data))
+	}
+}
+
+// SetImageVariantCacheSize 縮小した画像のキャッシュの上限（バイト、0でキャッシュしない）
+func (r *Routes) SetImageVariantCacheSize(limit int64) {
+	r.imageVariants = newImageVariantCache(limit)
+}
+
+// imageSize ?sizeの値（省略時はfull）、画像以外の成果物に縮小を指定した場合・不明な値はエラー
+func imageSize(c *fiber.Ctx, contentType string) (string, error) {
+	size := c.Query("size", imageSizeFull)
+	if size == imageSizeFull {
+		return size, nil
+	}
+	if _, ok := imageSizeWidths[size]; !ok {
+		return "", fmt.Errorf("Invalid size: %s (must be small, medium or full)", size)
+	}
+	if contentType != "image/png" {
+		return "", fmt.Errorf("size is only supported for image artifacts")
+	}
+	return size, nil
+}
+
+// imageVariantKey キャッシュのキー
+func imageVariantKey(id, name, size string) string {
+	return id + "/" + name + "/" + size
+}
+
+// cachedImageVariant 縮小した画像がキャッシュにあれば返す（fullは常にfalse）
+func (r *Routes) cachedImageVariant(id, name, size string) ([]byte, bool) {
+	if size == imageSizeFull {
+		return nil, false
+	}
+	return r.imageVariants.get(imageVariantKey(id, name, size))
+}
+
+// imageVariant 画像をサイズの最大幅まで縮小してキャッシュする（fullはそのまま返す）
+// 元の画像が最大幅以下の場合は元の画像を返す
+func (r *Routes) imageVariant(id, name, size string, data []byte) ([]byte, error) {
+	width, ok := imageSizeWidths[size]
+	if !ok {
+		return data, nil
+	}
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	resized := data
+	if src.Bounds().Dx() > width {
+		var b bytes.Buffer
+		if err := png.Encode(&b, downscale(src, width)); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+		resized = b.Bytes()
+	}
+	r.imageVariants.put(imageVariantKey(id, name, size), resized)
+	return resized, nil
+}
+
+// sendArtifactData 成果物を返す（画像は?sizeに合わせて縮小する、ETag・Rangeに対応）
+func (r *Routes) sendArtifactData(c *fiber.Ctx, id, name, size, contentType string, data []byte) error {
+	data, err := r.imageVariant(id, name, size, data)
+	if err != nil {
+		requestLog(c).Warnf("Failed to resize %s of %s: %v", name, id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to resize image",
+		})
+	}
+	return sendConditional(c, data, time.Time{}, contentType)
+}
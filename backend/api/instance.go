@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultInstanceName INSTANCE_CONFIGでnameを指定しない場合のインスタンス名
+const defaultInstanceName = "DSA Analysis"
+
+// InstanceInfo デプロイごとの識別情報（GET /api/instance）
+// 同じソフトウェアを複数の研究室で運用する場合に、利用者・スクリプトがどのインスタンスか区別できるようにする
+type InstanceInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// 利用者向けの公開URL
+	URL      string           `json:"url,omitempty"`
+	Operator InstanceOperator `json:"operator"`
+	// 解析パイプライン（Pythonパッケージ）のバージョン（未指定時はdsa/__init__.pyの__version__）
+	PipelineVersion string            `json:"pipeline_version,omitempty"`
+	Retention       InstanceRetention `json:"retention"`
+	Terms           InstanceTerms     `json:"terms"`
+}
+
+// InstanceOperator 運用者の連絡先
+type InstanceOperator struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// InstanceRetention データの保持方針（日数はサーバーの設定から設定する）
+type InstanceRetention struct {
+	// 保持方針の説明（利用者向けの文章）
+	Policy string `json:"policy,omitempty"`
+	// 成果物・解析の保持期間（ARTIFACT_RETENTION_DAYS・RETENTION_DAYS、nullは無期限）
+	ArtifactDays *int `json:"artifact_days"`
+	AnalysisDays *int `json:"analysis_days"`
+}
+
+// InstanceTerms 利用規約
+type InstanceTerms struct {
+	URL        string `json:"url,omitempty"`
+	Text       string `json:"text,omitempty"`
+	PrivacyURL string `json:"privacy_url,omitempty"`
+}
+
+// DefaultInstanceInfo INSTANCE_CONFIGを指定しない場合の識別情報
+func DefaultInstanceInfo() InstanceInfo {
+	return InstanceInfo{Name: defaultInstanceName}
+}
+
+// LoadInstanceInfo INSTANCE_CONFIGのJSONファイルを読み込む（未知のフィールドはtypoとしてエラーにする）
+// 保持期間の日数はサーバーの設定から設定するため、ファイルでは指定できない
+func LoadInstanceInfo(path string) (InstanceInfo, error) {
+	info := DefaultInstanceInfo()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&info); err != nil {
+		return DefaultInstanceInfo(), fmt.Errorf("invalid instance config %s: %w", path, err)
+	}
+	if info.Retention.ArtifactDays != nil || info.Retention.AnalysisDays != nil {
+		return DefaultInstanceInfo(), fmt.Errorf("invalid instance config %s: retention days are set by ARTIFACT_RETENTION_DAYS and RETENTION_DAYS", path)
+	}
+	if email := info.Operator.Email; email != "" && !strings.Contains(email, "@") {
+		return DefaultInstanceInfo(), fmt.Errorf("invalid instance config %s: invalid operator email: %s", path, email)
+	}
+	info.Name = strings.TrimSpace(info.Name)
+	if info.Name == "" {
+		info.Name = defaultInstanceName
+	}
+	return info, nil
+}
+
+// SetInstanceInfo GET /api/instanceで返す識別情報を設定する
+func (r *Routes) SetInstanceInfo(info InstanceInfo) {
+	r.instance = info
+}
+
+// getInstance GET /api/instance インスタンスの名前・運用者の連絡先・パイプラインのバージョン・保持方針・利用規約を返す
+func (r *Routes) getInstance(c *fiber.Ctx) error {
+	info := r.instance
+	if info.Name == "" {
+		info.Name = defaultInstanceName
+	}
+	// 保持期間は実際の設定を返す（設定ファイルの説明と食い違わないように）
+	if d := r.jobManager.ArtifactRetention(); d > 0 {
+		days := int(d.Hours() / 24)
+		info.Retention.ArtifactDays = &days
+	}
+	if d := r.jobManager.Retention(); d > 0 {
+		days := int(d.Hours() / 24)
+		info.Retention.AnalysisDays = &days
+	}
+	return c.JSON(info)
+}
@@ -0,0 +1,377 @@
+package api_test
+
+import (
+	"context"
+	"dsa-api/jobs"
+	"dsa-api/testsupport"
+	"dsa-api/testsupport/fakedsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testUniProtID = "P69905"
+	// 偽のCLIが終了せずに実行中のままになる時間（キャンセル・削除するまで）
+	longRun = time.Minute
+)
+
+// waitForCheckpoint 偽のCLIが起動して最初のチェックポイントを書くまで待つ（SIGTERMの無視はその前に設定される）
+func waitForCheckpoint(t *testing.T, e *testsupport.Env, id string) {
+	t.Helper()
+	path := filepath.Join(e.Manager.LocalJobDir(id), "work", "checkpoint.json")
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("fake dsa_cli for %s did not start within 10s", id)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// startLongJob 終了しない解析を作成し、偽のCLIが起動するまで待つ
+func startLongJob(t *testing.T, e *testsupport.Env, b fakedsa.Behavior) string {
+	t.Helper()
+	b.Duration = longRun
+	e.SetBehavior(t, testUniProtID, b)
+	id := e.CreateJob(t, testUniProtID, nil)
+	e.WaitForStatus(t, id, 10*time.Second, jobs.StatusRunning)
+	waitForCheckpoint(t, e, id)
+	return id
+}
+
+func TestIntegrationCancelRunningJob(t *testing.T) {
+	e := testsupport.NewEnv(t, testsupport.Options{})
+	id := startLongJob(t, e, fakedsa.Behavior{})
+
+	start := time.Now()
+	var resp struct {
+		Summary struct {
+			Status string `json:"status"`
+		} `json:"summary"`
+	}
+	if status := e.DoJSON(t, http.MethodPost, "/api/analyses/"+id+"/cancel", nil, &resp); status != http.StatusOK {
+		t.Fatalf("cancel returned %d, want 200", status)
+	}
+	// SIGTERMで終了し、後処理を待ってから最終的な状態を返す
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancel took %s, want the process to exit on SIGTERM", elapsed)
+	}
+	if resp.Summary.Status != string(jobs.StatusCancelled) {
+		t.Errorf("status in cancel response = %q, want cancelled", resp.Summary.Status)
+	}
+	if got := e.StatusOf(t, id); got != string(jobs.StatusCancelled) {
+		t.Errorf("status = %q, want cancelled", got)
+	}
+
+	// キャンセル済みの解析はもう一度キャンセルできない
+	if status, body := e.Do(t, http.MethodPost, "/api/analyses/"+id+"/cancel", nil); status != http.StatusBadRequest {
+		t.Errorf("second cancel returned %d, want 400: %s", status, body)
+	}
+}
+
+func TestIntegrationCancelKillsJobIgnoringSIGTERM(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the kill grace period")
+	}
+	e := testsupport.NewEnv(t, testsupport.Options{})
+	id := startLongJob(t, e, fakedsa.Behavior{IgnoreSIGTERM: true})
+
+	start := time.Now()
+	// 猶予期間の間は後処理が終わらないため、202（キャンセル中）または強制終了後の200
+	status, body := e.Do(t, http.MethodPost, "/api/analyses/"+id+"/cancel", nil)
+	if status != http.StatusOK && status != http.StatusAccepted {
+		t.Fatalf("cancel returned %d, want 200 or 202: %s", status, body)
+	}
+	if got := e.StatusOf(t, id); got != string(jobs.StatusCancelled) {
+		t.Errorf("status = %q, want cancelled", got)
+	}
+
+	// SIGTERMを無視したプロセスは猶予期間を過ぎてからSIGKILLで終了する
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := e.Manager.WaitSettled(ctx, id); err != nil {
+		t.Fatalf("job did not settle after SIGKILL: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Second {
+		t.Errorf("job settled after %s, want the process to outlive SIGTERM", elapsed)
+	}
+	if got := e.StatusOf(t, id); got != string(jobs.StatusCancelled) {
+		t.Errorf("status after kill = %q, want cancelled", got)
+	}
+}
+
+func TestIntegrationDeleteRunningJob(t *testing.T) {
+	e := testsupport.NewEnv(t, testsupport.Options{})
+	id := startLongJob(t, e, fakedsa.Behavior{})
+	dir := e.Manager.LocalJobDir(id)
+
+	if status, body := e.Do(t, http.MethodDelete, "/api/analyses/"+id, nil); status != http.StatusOK {
+		t.Fatalf("delete returned %d, want 200: %s", status, body)
+	}
+	if status, _ := e.Do(t, http.MethodGet, "/api/analyses/"+id, nil); status != http.StatusNotFound {
+		t.Errorf("GET after delete returned %d, want 404", status)
+	}
+	if _, err := e.Manager.GetJob(id); err == nil {
+		t.Errorf("job %s is still known to the manager after delete", id)
+	}
+	// 実行中のプロセスが終了してからジョブディレクトリが消えていること
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job directory %s still exists after delete", dir)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestIntegrationDeleteFinishedJob(t *testing.T) {
+	e := testsupport.NewEnv(t, testsupport.Options{})
+	id := e.CreateJob(t, testUniProtID, nil)
+	e.WaitForStatus(t, id, 10*time.Second, jobs.StatusDone)
+
+	if status, body := e.Do(t, http.MethodDelete, "/api/analyses/"+id, nil); status != http.StatusOK {
+		t.Fatalf("delete returned %d, want 200: %s", status, body)
+	}
+	for _, path := range []string{"/api/analyses/" + id, "/api/jobs/" + id} {
+		if status, _ := e.Do(t, http.MethodGet, path, nil); status != http.StatusNotFound {
+			t.Errorf("GET %s after delete returned %d, want 404", path, status)
+		}
+	}
+	if _, err := os.Stat(e.Manager.LocalJobDir(id)); !os.IsNotExist(err) {
+		t.Errorf("job directory still exists after delete: %v", err)
+	}
+}
+
+// TestIntegrationFallbackWithoutPersistence DBなし・R2に接続できない場合もジョブの状態をJob APIから返し、成果物をローカルに残す
+func TestIntegrationFallbackWithoutPersistence(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts func(t *testing.T) testsupport.Options
+	}{
+		{"no db", func(t *testing.T) testsupport.Options { return testsupport.Options{} }},
+		{"unreachable r2", func(t *testing.T) testsupport.Options {
+			return testsupport.Options{R2: testsupport.UnreachableR2(t)}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := testsupport.NewEnv(t, tc.opts(t))
+			id := e.CreateJob(t, testUniProtID, nil)
+			e.WaitForStatus(t, id, 10*time.Second, jobs.StatusDone, jobs.StatusFailed)
+
+			if got := e.StatusOf(t, id); got != string(jobs.StatusDone) {
+				t.Fatalf("status = %q, want done", got)
+			}
+			var job struct {
+				Status    string `json:"status"`
+				UniProtID string `json:"uniprot_id"`
+			}
+			if status := e.DoJSON(t, http.MethodGet, "/api/jobs/"+id, nil, &job); status != http.StatusOK {
+				t.Fatalf("GET /api/jobs/%s returned %d, want 200", id, status)
+			}
+			if job.Status != string(jobs.StatusDone) || job.UniProtID != testUniProtID {
+				t.Errorf("job = %+v, want done for %s", job, testUniProtID)
+			}
+			if _, err := os.Stat(filepath.Join(e.Manager.LocalJobDir(id), "result.json")); err != nil {
+				t.Errorf("result.json was not kept locally: %v", err)
+			}
+		})
+	}
+}
+
+// TestIntegrationFailedJob 偽のCLIの失敗の仕方ごとにfailedになること
+func TestIntegrationFailedJob(t *testing.T) {
+	e := testsupport.NewEnv(t, testsupport.Options{})
+	for _, failure := range []string{fakedsa.FailureExit, fakedsa.FailureResult, fakedsa.FailureMissingResult} {
+		t.Run(failure, func(t *testing.T) {
+			uniprotID := "FAIL_" + failure
+			e.SetBehavior(t, uniprotID, fakedsa.Behavior{Failure: failure})
+			id := e.CreateJob(t, uniprotID, nil)
+			e.WaitForStatus(t, id, 10*time.Second, jobs.StatusFailed, jobs.StatusDone)
+			if got := e.StatusOf(t, id); got != string(jobs.StatusFailed) {
+				t.Errorf("status = %q, want failed", got)
+			}
+		})
+	}
+}
+
+// createSessionJob セッションの解析を作成する（POST /api/jobsと同じくparams.session_idに記録する）
+func createSessionJob(t *testing.T, e *testsupport.Env, sessionID string) string {
+	t.Helper()
+	params, err := jobs.ParseAnalysisParams(nil)
+	if err != nil {
+		t.Fatalf("failed to parse default params: %v", err)
+	}
+	params.SessionID = sessionID
+	job, err := e.Manager.CreateJob(testUniProtID, params)
+	if err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+	return job.ID
+}
+
+// TestIntegrationReassignSessionWhileRunning ログイン時のセッションの付け替え（ReassignSession）と、実行中のジョブがパラメータを読む処理が競合しないこと（go test -raceで確認する）
+func TestIntegrationReassignSessionWhileRunning(t *testing.T) {
+	e := testsupport.NewEnv(t, testsupport.Options{})
+	e.SetBehavior(t, testUniProtID, fakedsa.Behavior{Duration: 500 * time.Millisecond, Steps: 10})
+
+	const anonymous, account = "anonymous-session", "account-session"
+	ids := make([]string, 0, 4)
+	for i := 0; i < 4; i++ {
+		ids = append(ids, createSessionJob(t, e, anonymous))
+	}
+
+	// ジョブの起動・実行・完了の間、ログインのたびに付け替える
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				e.Manager.ReassignSession(anonymous, account)
+			} else {
+				e.Manager.ReassignSession(account, anonymous)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	for _, id := range ids {
+		e.WaitForStatus(t, id, 20*time.Second, jobs.StatusDone, jobs.StatusFailed)
+	}
+	close(stop)
+	wg.Wait()
+
+	e.Manager.ReassignSession(anonymous, account)
+	for _, id := range ids {
+		job, err := e.Manager.GetJob(id)
+		if err != nil {
+			t.Fatalf("GetJob(%s): %v", id, err)
+		}
+		if job.Status != jobs.StatusDone {
+			t.Errorf("job %s status = %s, want done", id, job.Status)
+		}
+		if got, _ := job.Params["session_id"].(string); got != account {
+			t.Errorf("job %s session_id = %q, want %q", id, got, account)
+		}
+	}
+}
+
+// TestIntegrationLoginWhileRunning 実行中のジョブがある匿名のセッションでアカウントを作成（ログイン）すると、ジョブがアカウントに引き継がれること
+func TestIntegrationLoginWhileRunning(t *testing.T) {
+	db := testsupport.Postgres(t)
+	e := testsupport.NewEnv(t, testsupport.Options{DB: db})
+	e.SetBehavior(t, testUniProtID, fakedsa.Behavior{Duration: 2 * time.Second, Steps: 20})
+
+	anonymous := fmt.Sprintf("anonymous-%d", time.Now().UnixNano())
+	id := createSessionJob(t, e, anonymous)
+	e.WaitForStatus(t, id, 10*time.Second, jobs.StatusRunning)
+
+	body := fmt.Sprintf(`{"email":"%s@example.com","password":"integration-test-password"}`, anonymous)
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "dsa_session_id", Value: anonymous})
+	resp, err := e.App.Test(req, -1)
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register returned %d", resp.StatusCode)
+	}
+
+	e.WaitForStatus(t, id, 20*time.Second, jobs.StatusDone)
+	job, err := e.Manager.GetJob(id)
+	if err != nil {
+		t.Fatalf("GetJob(%s): %v", id, err)
+	}
+	if got, _ := job.Params["session_id"].(string); got == anonymous {
+		t.Errorf("job %s still belongs to the anonymous session after login", id)
+	}
+}
+
+// getWithSession セッションのCookieを付けてGETする（管理用のAPIキーでもCookieのセッションの解析に絞り込まれる）
+func getWithSession(t *testing.T, e *testsupport.Env, path, sessionID string, out interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-API-Key", testsupport.APIKey)
+	req.AddCookie(&http.Cookie{Name: "dsa_session_id", Value: sessionID})
+	resp, err := e.App.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s returned %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("failed to decode response of GET %s: %v", path, err)
+	}
+}
+
+// TestIntegrationCursorPagingListsPinnedFirst page_size・cursorのページングでも固定した解析を先に返すこと
+func TestIntegrationCursorPagingListsPinnedFirst(t *testing.T) {
+	db := testsupport.Postgres(t)
+	e := testsupport.NewEnv(t, testsupport.Options{DB: db})
+
+	session := fmt.Sprintf("paging-%d", time.Now().UnixNano())
+	ids := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		id := createSessionJob(t, e, session)
+		e.WaitForStatus(t, id, 10*time.Second, jobs.StatusDone)
+		ids = append(ids, id)
+	}
+	// 古い方から2件を固定する
+	pinned := map[string]bool{ids[0]: true, ids[1]: true}
+	for id := range pinned {
+		if status, body := e.Do(t, http.MethodPost, "/api/analyses/"+id+"/pin", nil); status != http.StatusOK {
+			t.Fatalf("pin %s returned %d: %s", id, status, body)
+		}
+	}
+
+	want := []string{ids[1], ids[0], ids[4], ids[3], ids[2]}
+	got := make([]string, 0, len(want))
+	cursor := ""
+	for page := 0; page < len(want); page++ {
+		var resp struct {
+			Analyses []struct {
+				ID string `json:"id"`
+			} `json:"analyses"`
+			HasMore    bool   `json:"has_more"`
+			NextCursor string `json:"next_cursor"`
+		}
+		path := "/api/analyses?page_size=2"
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		getWithSession(t, e, path, session, &resp)
+		for _, a := range resp.Analyses {
+			got = append(got, a.ID)
+		}
+		if !resp.HasMore {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("paged analyses = %v, want pinned first %v", got, want)
+	}
+}
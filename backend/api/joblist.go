@@ -0,0 +1,61 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listJobs GET /api/jobs メモリ上のジョブとDBの解析をまとめて作成日時の新しい順に返す（DATABASE_URLがなくても動作する）
+// status・uniprot_id・created_after（RFC3339、または日付をtzの日付として解釈）で絞り込み、limitとcursor（前のページのnext_cursor）でページングする
+func (r *Routes) listJobs(c *fiber.Ctx) error {
+	filter := jobs.JobListFilter{
+		Status:    jobs.JobStatus(c.Query("status")),
+		UniProtID: c.Query("uniprot_id"),
+		SessionID: r.listSessionID(c),
+		Limit:     c.QueryInt("limit", jobs.DefaultJobListLimit),
+		Cursor:    c.Query("cursor"),
+	}
+	switch filter.Status {
+	case "", jobs.StatusQueued, jobs.StatusRunning, jobs.StatusDone, jobs.StatusFailed, jobs.StatusCancelled:
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid status: %s", filter.Status),
+		})
+	}
+	if v := c.Query("created_after"); v != "" {
+		loc, err := r.requestLocation(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		t, err := parseTimeFilter(v, loc, false)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid created_after: %v", err),
+			})
+		}
+		filter.CreatedAfter = t
+	}
+
+	page, err := r.jobManager.ListJobs(c.UserContext(), filter)
+	if errors.Is(err, jobs.ErrInvalidCursor) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid cursor",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := fiber.Map{"jobs": page.Jobs}
+	if page.NextCursor != "" {
+		response["next_cursor"] = page.NextCursor
+	}
+	return c.JSON(response)
+}
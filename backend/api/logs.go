@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"dsa-api/jobs"
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getJobLogs ジョブのPython出力（logs.txt）を返す
+// 実行中はローカルの作業ディレクトリから、終了後はR2（なければローカル）から取得する。?tail=100 で末尾の行のみ返す
+func (r *Routes) getJobLogs(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	tail := 0
+	if v := c.Query("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "tail must be a non-negative integer",
+			})
+		}
+		tail = n
+	}
+
+	data, status, err := r.loadJobLogs(c.UserContext(), id)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	c.Set("Content-Type", "text/plain; charset=utf-8")
+	return c.Send(jobs.TailLines(data, tail))
+}
+
+// loadJobLogs ローカルの作業ディレクトリ、なければR2（ローカル）からlogs.txtを読み込む（失敗した場合は返すべきステータスコードとエラー）
+func (r *Routes) loadJobLogs(ctx context.Context, id string) ([]byte, int, error) {
+	if path := r.jobManager.LocalLogPath(id); path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			return b, 0, nil
+		}
+	}
+
+	var key *string
+	if r.db != nil {
+		record, err := r.getAnalysisRecord(ctx, id)
+		if err != nil {
+			return nil, 404, errors.New("Job not found")
+		}
+		key = record.LogsKey
+	}
+	data, err := r.loadArtifact(ctx, id, "logs.txt", key)
+	if err != nil {
+		return nil, 404, errors.New("Logs not found")
+	}
+	return data, 0, nil
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"dsa-api/jobs"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ログのストリーミングでファイル・ジョブの状態を確認する間隔と、接続維持のコメントを送る間隔
+const (
+	logStreamPollInterval = 500 * time.Millisecond
+	logStreamKeepAlive    = 15 * time.Second
+)
+
+// streamJobLogs ジョブのPython出力（logs.txt）をServer-Sent Eventsで配信する
+// 実行中は追記された行を逐次送り、ジョブが終了したら event: end（data はステータス）を送って切断する
+// 終了済みのジョブは保存されたログをすべて送ってから end を送る
+func (r *Routes) streamJobLogs(c *fiber.Ctx) error {
+	id := c.Params("id")
+	snapshot, err := r.jobManager.JobSnapshot(id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Job not found",
+		})
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	// リバースプロキシ（nginx）のバッファリングを無効にする
+	c.Set("X-Accel-Buffering", "no")
+
+	if isFinishedStatus(snapshot.Status) {
+		data, _, err := r.loadJobLogs(c.UserContext(), id)
+		if err != nil {
+			data = nil
+		}
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			lines := &sseLineWriter{w: w}
+			lines.Write(data)
+			lines.finish(snapshot.Status)
+		})
+		return nil
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		r.followJobLog(id, &sseLineWriter{w: w})
+	})
+	return nil
+}
+
+// followJobLog logs.txtへの追記を読み続け、ジョブが終了したら残りを送って戻る（クライアントが切断した場合も戻る）
+func (r *Routes) followJobLog(id string, lines *sseLineWriter) {
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	var offset int64
+
+	// ファイルの末尾まで読む（再試行でファイルが作り直された場合は先頭から読み直す）
+	readNew := func() {
+		if info, err := f.Stat(); err == nil && info.Size() < offset {
+			f.Seek(0, io.SeekStart)
+			offset = 0
+		}
+		n, _ := io.Copy(lines, f)
+		offset += n
+	}
+
+	ticker := time.NewTicker(logStreamPollInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+	for {
+		// 開始前はファイルがないため、作成されるまで待つ
+		if f == nil {
+			if path := r.jobManager.LocalLogPath(id); path != "" {
+				if opened, err := os.Open(path); err == nil {
+					f = opened
+				}
+			}
+		}
+		if f != nil {
+			readNew()
+		}
+
+		snapshot, err := r.jobManager.JobSnapshot(id)
+		if err != nil || isFinishedStatus(snapshot.Status) {
+			status := jobs.StatusFailed
+			if err == nil {
+				status = snapshot.Status
+			}
+			if f != nil {
+				readNew()
+			} else if data, _, err := r.loadJobLogs(context.Background(), id); err == nil {
+				// 一度も開けないまま終了した場合（作業ディレクトリが削除済み）は保存されたログを送る
+				lines.Write(data)
+			}
+			lines.finish(status)
+			return
+		}
+
+		if lines.written {
+			lines.written = false
+			lastWrite = time.Now()
+		} else if time.Since(lastWrite) >= logStreamKeepAlive {
+			lines.w.WriteString(": keep-alive\n\n")
+			lastWrite = time.Now()
+		}
+		// 書き込みに失敗した場合はクライアントが切断している
+		if err := lines.w.Flush(); err != nil {
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// isFinishedStatus ジョブがこれ以上ログを出力しない状態か
+func isFinishedStatus(status jobs.JobStatus) bool {
+	return status == jobs.StatusDone || status == jobs.StatusFailed || status == jobs.StatusCancelled
+}
+
+// sseLineWriter 受け取った出力を行ごとに data: イベントとして書き込む（改行のない末尾は次の書き込みまで保持する）
+type sseLineWriter struct {
+	w       *bufio.Writer
+	pending []byte
+	written bool
+}
+
+func (s *sseLineWriter) Write(p []byte) (int, error) {
+	s.pending = append(s.pending, p...)
+	for {
+		idx := bytes.IndexByte(s.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		s.writeLine(s.pending[:idx])
+		s.pending = s.pending[idx+1:]
+	}
+	return len(p), nil
+}
+
+func (s *sseLineWriter) writeLine(line []byte) {
+	// tqdm等の進捗表示は\rで上書きするため、最後の表示のみ送る
+	line = bytes.TrimRight(line, "\r")
+	if idx := bytes.LastIndexByte(line, '\r'); idx >= 0 {
+		line = line[idx+1:]
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", line)
+	s.written = true
+}
+
+// finish 保持している末尾の行と終了イベントを送る
+func (s *sseLineWriter) finish(status jobs.JobStatus) {
+	if len(s.pending) > 0 {
+		s.writeLine(s.pending)
+		s.pending = nil
+	}
+	fmt.Fprintf(s.w, "event: end\ndata: %s\n\n", status)
+	s.w.Flush()
+}
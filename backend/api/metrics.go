@@ -0,0 +1,108 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getMetrics Prometheusのテキスト形式でメトリクスを返す
+func (r *Routes) getMetrics(c *fiber.Ctx) error {
+	var b strings.Builder
+
+	scheduler := r.jobManager.SchedulerStats()
+	writeMetric(&b, "dsa_job_slots", "gauge", "Maximum number of concurrently running analyses", float64(scheduler.Slots))
+	writeMetric(&b, "dsa_jobs_running", "gauge", "Number of analyses currently running", float64(scheduler.Running))
+	writeMetric(&b, "dsa_jobs_queued", "gauge", "Number of analyses waiting for a slot", float64(scheduler.Queued))
+	writeMetric(&b, "dsa_job_queue_sessions", "gauge", "Number of sessions with analyses waiting for a slot", float64(scheduler.WaitingSessions))
+	writeMetric(&b, "dsa_job_queue_paused", "gauge", "Whether dispatching queued analyses is paused (1=paused)", float64(queuePausedValue(scheduler.Paused)))
+	reserved := make(map[string]int, len(scheduler.Classes))
+	running := make(map[string]int, len(scheduler.Classes))
+	queued := make(map[string]int, len(scheduler.Classes))
+	for class, slots := range scheduler.Classes {
+		reserved[class] = slots.Reserved
+		running[class] = slots.Running
+		queued[class] = slots.Queued
+	}
+	writeLabeledMetric(&b, "dsa_job_slots_reserved", "gauge", "Number of slots reserved per job class", "class", jobs.JobClasses, reserved)
+	writeLabeledMetric(&b, "dsa_jobs_running_by_class", "gauge", "Number of analyses currently running per job class", "class", jobs.JobClasses, running)
+	writeLabeledMetric(&b, "dsa_jobs_queued_by_class", "gauge", "Number of analyses waiting for a slot per job class", "class", jobs.JobClasses, queued)
+
+	if r.remote != nil {
+		queued, claimed := r.remote.Pending()
+		writeMetric(&b, "dsa_remote_jobs_unclaimed", "gauge", "Number of analyses waiting for a remote worker", float64(queued))
+		writeMetric(&b, "dsa_remote_jobs_claimed", "gauge", "Number of analyses running on remote workers", float64(claimed))
+	}
+
+	upload := r.jobManager.UploadStats()
+	writeMetric(&b, "dsa_upload_spool_depth", "gauge", "Number of job outputs waiting in the upload spool", float64(upload.SpoolDepth))
+	writeMetric(&b, "dsa_upload_spool_capacity", "gauge", "Maximum number of entries in the upload spool", float64(upload.SpoolCapacity))
+	writeMetric(&b, "dsa_upload_queue_length", "gauge", "Number of spool entries queued for upload", float64(upload.Queued))
+	writeMetric(&b, "dsa_upload_in_flight", "gauge", "Number of uploads currently in progress", float64(upload.InFlight))
+	writeMetric(&b, "dsa_upload_workers", "gauge", "Number of upload workers", float64(upload.Workers))
+	writeMetric(&b, "dsa_uploads_total", "counter", "Total number of successful spool uploads", float64(upload.UploadedTotal))
+	writeMetric(&b, "dsa_upload_failures_total", "counter", "Total number of failed spool upload attempts", float64(upload.FailedTotal))
+	temp := r.jobManager.TempSweepStats()
+	writeMetric(&b, "dsa_temp_dirs_swept_total", "counter", "Total number of orphaned job temp directories removed", float64(temp.SweptTotal))
+	writeMetric(&b, "dsa_temp_reclaimed_bytes_total", "counter", "Total bytes reclaimed by removing orphaned job temp directories", float64(temp.ReclaimedBytes))
+	if !temp.LastSweep.IsZero() {
+		writeMetric(&b, "dsa_temp_last_sweep_timestamp_seconds", "gauge", "Unix time of the last orphaned temp directory sweep", float64(temp.LastSweep.Unix()))
+	}
+	writeMetric(&b, "dsa_pending_deletions", "gauge", "Number of deleted analyses whose storage cleanup has not completed", float64(r.jobManager.PendingDeletions()))
+
+	if r.r2 != nil {
+		breaker := r.r2.BreakerStatus()
+		writeMetric(&b, "dsa_object_store_breaker_state", "gauge", "Object storage circuit breaker state (0=closed, 1=half_open, 2=open)", float64(breakerStateValue(breaker.State)))
+		writeMetric(&b, "dsa_object_store_consecutive_failures", "gauge", "Consecutive object storage failures", float64(breaker.ConsecutiveFailures))
+		writeMetric(&b, "dsa_object_store_failures_total", "counter", "Total object storage failures", float64(breaker.TotalFailures))
+		writeMetric(&b, "dsa_object_store_rejected_total", "counter", "Total object storage calls rejected by the circuit breaker", float64(breaker.TotalRejected))
+	}
+
+	// 失敗の連続回数（分類・依存先ごと、アラートで「PDBが落ちている」と「1つのタンパク質に構造がない」を区別する）
+	failures := r.jobManager.FailureStats()
+	writeLabeledMetric(&b, "dsa_job_failure_streak", "gauge", "Consecutive failed analyses per failure category since the last successful analysis", "category", jobs.FailureCategories, failures.Streaks)
+	writeLabeledMetric(&b, "dsa_job_failures_total", "counter", "Total failed analyses per failure category", "category", jobs.FailureCategories, failures.Totals)
+	writeLabeledMetric(&b, "dsa_dependency_failure_streak", "gauge", "Consecutive failures per external dependency", "dependency", jobs.Dependencies, failures.Dependencies)
+
+	if names, totals := r.rateLimitedTotals(); len(names) > 0 {
+		writeLabeledMetric(&b, "dsa_rate_limited_total", "counter", "Total requests rejected by rate limiting per limit", "limit", names, totals)
+	}
+
+	c.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	return c.SendString(b.String())
+}
+
+func breakerStateValue(state storage.BreakerState) int {
+	switch state {
+	case storage.BreakerHalfOpen:
+		return 1
+	case storage.BreakerOpen:
+		return 2
+	}
+	return 0
+}
+
+func queuePausedValue(paused bool) int {
+	if paused {
+		return 1
+	}
+	return 0
+}
+
+// writeLabeledMetric ラベルの値ごとに1行ずつ出力する（labelsの順）
+func writeLabeledMetric(b *strings.Builder, name, metricType, help, label string, labels []string, values map[string]int) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, metricType)
+	for _, value := range labels {
+		fmt.Fprintf(b, "%s{%s=%q} %d\n", name, label, value, values[value])
+	}
+}
+
+func writeMetric(b *strings.Builder, name, metricType, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, metricType)
+	fmt.Fprintf(b, "%s %v\n", name, value)
+}
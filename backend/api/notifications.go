@@ -0,0 +1,10 @@
+package api
+
+import (
+	"dsa-api/notify"
+)
+
+// SetNotifications ジョブの終了のメール通知（notify_email）を設定する（未設定の場合はnotify_emailを400で拒否する）
+func (r *Routes) SetNotifications(mailer *notify.Mailer) {
+	r.notifications = mailer
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"dsa-api/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requireOwner 解析（:id）を作成したセッション以外からのキャンセル・削除・再実行に403を返す
+func (r *Routes) requireOwner(c *fiber.Ctx) error {
+	if !r.canModifyAnalysis(c, c.Params("id")) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Analysis belongs to another session",
+		})
+	}
+	return c.Next()
+}
+
+// canModifyAnalysis リクエストのdsa_session_id Cookieが解析を作成したセッションと一致するか
+// 管理者（管理用のAPIキー、adminのユーザー）と、セッションレスモード（すべてのAPIでAPIキーが必須）は常に許可する
+// 解析が見つからない場合も許可する（ハンドラーが404を返す）
+// セッションが記録されていない解析（セッションの記録以前の解析等）はAPIキーでのみ変更できる
+func (r *Routes) canModifyAnalysis(c *fiber.Ctx, id string) bool {
+	if r.sessionless || r.requestRole(c) == auth.RoleAdmin {
+		return true
+	}
+	owner, found := r.analysisOwner(c.UserContext(), id)
+	if !found {
+		return true
+	}
+	sessionID := r.requestSessionID(c)
+	return owner != "" && sessionID != "" && subtle.ConstantTimeCompare([]byte(owner), []byte(sessionID)) == 1
+}
+
+// analysisOwner 解析を作成したセッションID（DBのsession_id、DBにない場合はジョブのパラメータから取得）
+func (r *Routes) analysisOwner(ctx context.Context, id string) (string, bool) {
+	if r.db != nil {
+		if record, err := r.getAnalysisRecord(ctx, id); err == nil {
+			return record.SessionID, true
+		}
+	}
+	if job, err := r.jobManager.GetJob(id); err == nil {
+		sessionID, _ := job.Params["session_id"].(string)
+		return sessionID, true
+	}
+	return "", false
+}
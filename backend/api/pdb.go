@@ -0,0 +1,50 @@
+package api
+
+import (
+	"dsa-api/storage/pdbcache"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetPDBCache 構造ファイルの共有キャッシュ（nilの場合はGET /api/pdb/:pdbidは503）
+func (r *Routes) SetPDBCache(cache *pdbcache.Cache) {
+	r.pdbCache = cache
+}
+
+// getPDB GET /api/pdb/:pdbid 構造ファイル（mmCIF）を返す
+// ジョブに関係なく共有キャッシュから返し、キャッシュにない場合はRCSBから取得する
+func (r *Routes) getPDB(c *fiber.Ctx) error {
+	if r.pdbCache == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "PDB cache is not configured",
+		})
+	}
+	return r.sendCachedPDB(c, c.Params("pdbid"))
+}
+
+// sendCachedPDB 共有キャッシュの構造ファイルを返す（取得できない場合はエラーに応じたステータス）
+func (r *Routes) sendCachedPDB(c *fiber.Ctx, pdbID string) error {
+	f, size, err := r.pdbCache.Open(c.UserContext(), pdbID)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		status := 502
+		switch {
+		case errors.Is(err, pdbcache.ErrInvalidID):
+			status = 400
+		case errors.Is(err, pdbcache.ErrNotFound):
+			status = 404
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	id, _ := pdbcache.NormalizeID(pdbID)
+	c.Set("Content-Type", "chemical/x-cif")
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.cif\"", id))
+	// ファイルは送信後に閉じられる
+	return c.SendStream(f, int(size))
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pinAnalysis POST /api/analyses/:id/pin 解析を保持期間による自動削除の対象外にする
+func (r *Routes) pinAnalysis(c *fiber.Ctx) error {
+	return r.setAnalysisPinned(c, true)
+}
+
+// unpinAnalysis DELETE /api/analyses/:id/pin 固定を解除する
+func (r *Routes) unpinAnalysis(c *fiber.Ctx) error {
+	return r.setAnalysisPinned(c, false)
+}
+
+func (r *Routes) setAnalysisPinned(c *fiber.Ctx, pinned bool) error {
+	id := c.Params("id")
+	if err := r.jobManager.PinAnalysis(c.UserContext(), id, pinned); err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, jobs.ErrAnalysisNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Analysis not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"id":     id,
+		"pinned": pinned,
+	})
+}
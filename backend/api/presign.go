@@ -0,0 +1,95 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 成果物のアップロード用の署名付きURLの有効期限
+const presignUploadExpiry = 15 * time.Minute
+
+// presignArtifactsSchema POST /api/analyses/:id/artifacts/presign
+var presignArtifactsSchema = objectSchema{
+	"worker_id": {Type: typeString, Required: true, NonEmpty: true},
+	"names":     {Type: typeArray, Items: &fieldSchema{Type: typeString, Enum: jobs.ArtifactNames()}},
+}
+
+type PresignArtifactsRequest struct {
+	WorkerID string   `json:"worker_id"`
+	Names    []string `json:"names"`
+}
+
+// presignArtifactUploads POST /api/analyses/:id/artifacts/presign
+// ジョブを割り当てられたワーカーが成果物をGoサーバーを経由せずにR2に直接アップロードするための署名付きURLを返す
+// namesを省略した場合は登録されているすべての成果物のURLを返す
+func (r *Routes) presignArtifactUploads(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req PresignArtifactsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if r.r2 == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "R2 not configured",
+		})
+	}
+	// リースを持つワーカーのみ（キャンセル・完了したジョブの成果物を上書きさせない）
+	if _, err := r.remote.TaskDir(id, req.WorkerID); err != nil {
+		return remoteError(c, err)
+	}
+
+	names := req.Names
+	if len(names) == 0 {
+		names = jobs.ArtifactNames()
+	}
+	expiresAt := time.Now().Add(presignUploadExpiry)
+	uploads := make([]fiber.Map, 0, len(names))
+	for _, name := range names {
+		artifact, ok := jobs.LookupArtifact(name)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Unknown artifact: %s", name),
+			})
+		}
+		key := r.jobManager.ArtifactKey(id, name)
+		url, err := r.r2.GetSignedUploadURL(c.UserContext(), key, artifact.ContentType, presignUploadExpiry)
+		if err != nil {
+			if errors.Is(err, storage.ErrCircuitOpen) {
+				return c.Status(503).JSON(fiber.Map{
+					"error": "R2 is temporarily unavailable",
+				})
+			}
+			if errors.Is(err, storage.ErrNotSupported) {
+				return c.Status(501).JSON(fiber.Map{
+					"error": "Object store does not support upload URLs",
+				})
+			}
+			requestLog(c).Warnf("Failed to presign upload of %s for %s: %v", name, id, err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to generate upload URL",
+			})
+		}
+		uploads = append(uploads, fiber.Map{
+			"name":   name,
+			"key":    key,
+			"url":    url,
+			"method": "PUT",
+			"headers": fiber.Map{
+				"Content-Type": artifact.ContentType,
+			},
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"analysis_id": id,
+		"expires_at":  formatTime(expiresAt),
+		"uploads":     uploads,
+	})
+}
@@ -0,0 +1,353 @@
+package api
+
+import (
+	"database/sql"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// セッションごとに作成できるプロジェクトの数
+	maxProjects = 100
+	// プロジェクトの名前・説明の最大の長さ
+	maxProjectNameLength        = 100
+	maxProjectDescriptionLength = 2000
+	// 1回に追加できる解析の数
+	maxProjectAnalysesPerRequest = 100
+)
+
+// createProjectSchema POST /api/projects
+var createProjectSchema = objectSchema{
+	"name":        {Type: typeString, Required: true, NonEmpty: true},
+	"description": {Type: typeString},
+}
+
+// updateProjectSchema PATCH /api/projects/:id
+var updateProjectSchema = objectSchema{
+	"name":        {Type: typeString, NonEmpty: true},
+	"description": {Type: typeString},
+}
+
+// addProjectAnalysesSchema POST /api/projects/:id/analyses
+var addProjectAnalysesSchema = objectSchema{
+	"analysis_ids": {Type: typeArray, Required: true, Items: &fieldSchema{Type: typeString, NonEmpty: true}},
+}
+
+type CreateProjectRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type UpdateProjectRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+type AddProjectAnalysesRequest struct {
+	AnalysisIDs []string `json:"analysis_ids"`
+}
+
+// normalizeProjectFields 名前・説明の前後の空白を除いて長さを検証する（nilの項目は検証しない）
+func normalizeProjectFields(name, description *string) error {
+	if name != nil {
+		*name = strings.TrimSpace(*name)
+		if *name == "" || len(*name) > maxProjectNameLength {
+			return fmt.Errorf("name must be 1 to %d characters", maxProjectNameLength)
+		}
+	}
+	if description != nil {
+		*description = strings.TrimSpace(*description)
+		if len(*description) > maxProjectDescriptionLength {
+			return fmt.Errorf("description must be at most %d characters", maxProjectDescriptionLength)
+		}
+	}
+	return nil
+}
+
+// projectResponse プロジェクトのレスポンス
+func projectResponse(project *storage.Project) fiber.Map {
+	return fiber.Map{
+		"id":             project.ID,
+		"name":           project.Name,
+		"description":    project.Description,
+		"analysis_count": project.AnalysisCount,
+		"created_at":     formatTime(project.CreatedAt),
+		"updated_at":     formatTime(project.UpdatedAt),
+	}
+}
+
+// projectError プロジェクトのエラーをレスポンスに変換する（ない場合は404、同じ名前は409）
+func projectError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Project not found",
+		})
+	case errors.Is(err, storage.ErrProjectNameTaken):
+		return c.Status(409).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if ctxErr := c.UserContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	requestLog(c).Errorf("Failed to %s project: %v", action, err)
+	return c.Status(500).JSON(fiber.Map{
+		"error": fmt.Sprintf("Failed to %s project", action),
+	})
+}
+
+// project セッションのプロジェクト（:id）
+func (r *Routes) project(c *fiber.Ctx) (*storage.Project, error) {
+	sessionID := r.requestSessionID(c)
+	if sessionID == "" {
+		return nil, sql.ErrNoRows
+	}
+	id := c.Params("id")
+	return r.db.GetProject(c.UserContext(), sessionID, id)
+}
+
+// createProject POST /api/projects 解析をまとめるプロジェクトをセッション（ログイン中はアカウント）に作成する
+func (r *Routes) createProject(c *fiber.Ctx) error {
+	var req CreateProjectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := normalizeProjectFields(&req.Name, &req.Description); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	sessionID := r.jobSessionID(c)
+	count, err := r.db.CountProjects(c.UserContext(), sessionID)
+	if err != nil {
+		return projectError(c, "create", err)
+	}
+	if count >= maxProjects {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("At most %d projects can be created", maxProjects),
+		})
+	}
+
+	project, err := r.db.CreateProject(c.UserContext(), &storage.Project{
+		ID:          uuid.New().String(),
+		SessionID:   sessionID,
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return projectError(c, "create", err)
+	}
+	return c.Status(201).JSON(projectResponse(project))
+}
+
+// listProjects GET /api/projects セッションのプロジェクト（新しい順）
+func (r *Routes) listProjects(c *fiber.Ctx) error {
+	response := make([]fiber.Map, 0)
+	sessionID := r.requestSessionID(c)
+	if sessionID == "" {
+		return c.JSON(response)
+	}
+	projects, err := r.db.ListProjects(c.UserContext(), sessionID)
+	if err != nil {
+		return projectError(c, "list", err)
+	}
+	for _, project := range projects {
+		response = append(response, projectResponse(project))
+	}
+	return c.JSON(response)
+}
+
+func (r *Routes) getProject(c *fiber.Ctx) error {
+	project, err := r.project(c)
+	if err != nil {
+		return projectError(c, "get", err)
+	}
+	return c.JSON(projectResponse(project))
+}
+
+// updateProject PATCH /api/projects/:id 名前・説明を変更する
+func (r *Routes) updateProject(c *fiber.Ctx) error {
+	var req UpdateProjectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if err := normalizeProjectFields(req.Name, req.Description); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	sessionID := r.requestSessionID(c)
+	if sessionID == "" {
+		return projectError(c, "update", sql.ErrNoRows)
+	}
+	id := c.Params("id")
+	project, err := r.db.UpdateProject(c.UserContext(), sessionID, id, req.Name, req.Description)
+	if err != nil {
+		return projectError(c, "update", err)
+	}
+	return c.JSON(projectResponse(project))
+}
+
+// deleteProject DELETE /api/projects/:id プロジェクトを削除する（解析は削除しない）
+func (r *Routes) deleteProject(c *fiber.Ctx) error {
+	sessionID := r.requestSessionID(c)
+	id := c.Params("id")
+	deleted := false
+	if sessionID != "" {
+		var err error
+		deleted, err = r.db.DeleteProject(c.UserContext(), sessionID, id)
+		if err != nil {
+			return projectError(c, "delete", err)
+		}
+	}
+	if !deleted {
+		return projectError(c, "delete", sql.ErrNoRows)
+	}
+	return c.JSON(fiber.Map{
+		"message":    "Project deleted successfully",
+		"project_id": id,
+	})
+}
+
+// addProjectAnalyses POST /api/projects/:id/analyses プロジェクトに解析を追加する（追加済みの解析はそのまま）
+// 他のセッションの解析は追加できない（403）、DBにない解析は追加せずnot_foundで返す
+func (r *Routes) addProjectAnalyses(c *fiber.Ctx) error {
+	var req AddProjectAnalysesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	seen := make(map[string]bool)
+	ids := make([]string, 0, len(req.AnalysisIDs))
+	for _, id := range req.AnalysisIDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxProjectAnalysesPerRequest {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("analysis_ids must have 1 to %d IDs", maxProjectAnalysesPerRequest),
+		})
+	}
+
+	project, err := r.project(c)
+	if err != nil {
+		return projectError(c, "update", err)
+	}
+
+	notFound := make([]string, 0)
+	found := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := r.getAnalysisRecord(c.UserContext(), id); err != nil {
+			if ctxErr := c.UserContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			notFound = append(notFound, id)
+			continue
+		}
+		if !r.canModifyAnalysis(c, id) {
+			return c.Status(403).JSON(fiber.Map{
+				"error":       "Analysis belongs to another session",
+				"analysis_id": id,
+			})
+		}
+		found = append(found, id)
+	}
+
+	added := 0
+	if len(found) > 0 {
+		if added, err = r.db.AddProjectAnalyses(c.UserContext(), project.ID, found); err != nil {
+			return projectError(c, "update", err)
+		}
+	}
+	if project, err = r.project(c); err != nil {
+		return projectError(c, "get", err)
+	}
+	response := projectResponse(project)
+	response["added"] = added
+	response["not_found"] = notFound
+	return c.JSON(response)
+}
+
+// removeProjectAnalysis DELETE /api/projects/:id/analyses/:analysisId プロジェクトから解析を外す（解析は削除しない）
+func (r *Routes) removeProjectAnalysis(c *fiber.Ctx) error {
+	project, err := r.project(c)
+	if err != nil {
+		return projectError(c, "update", err)
+	}
+	analysisID := c.Params("analysisId")
+	removed, err := r.db.RemoveProjectAnalysis(c.UserContext(), project.ID, analysisID)
+	if err != nil {
+		return projectError(c, "update", err)
+	}
+	if !removed {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Analysis not found in project",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"message":     "Analysis removed from project",
+		"project_id":  project.ID,
+		"analysis_id": analysisID,
+	})
+}
+
+// listProjectAnalyses GET /api/projects/:id/analyses プロジェクトの解析の一覧
+// 絞り込み・並び順・ページング（page_size・cursor）・group_byはGET /api/analysesと同じ
+func (r *Routes) listProjectAnalyses(c *fiber.Ctx) error {
+	project, err := r.project(c)
+	if err != nil {
+		return projectError(c, "get", err)
+	}
+	filters, err := r.analysisFilters(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	// プロジェクトに追加できるのはセッションの解析（管理者は他のセッションの解析も）のため、セッションでは絞り込まない
+	delete(filters, "session_id")
+	filters[storage.FilterProject] = project.ID
+	return r.respondAnalyses(c, filters)
+}
+
+// getProjectStats GET /api/projects/:id/stats プロジェクトの解析をまとめて集計する
+// ステータス・手法ごとの件数、成功率、UniProt IDの数、完了した解析の指標の件数・平均・最小・最大を返す
+func (r *Routes) getProjectStats(c *fiber.Ctx) error {
+	project, err := r.project(c)
+	if err != nil {
+		return projectError(c, "get", err)
+	}
+	stats, err := r.db.ProjectStats(c.UserContext(), project.ID)
+	if err != nil {
+		return projectError(c, "aggregate", err)
+	}
+
+	metrics := make(fiber.Map, len(stats.Metrics))
+	for name, m := range stats.Metrics {
+		metrics[name] = fiber.Map{"count": m.Count, "mean": m.Mean, "min": m.Min, "max": m.Max}
+	}
+	return c.JSON(fiber.Map{
+		"project_id":   project.ID,
+		"total":        stats.Total,
+		"uniprot_ids":  stats.UniProtIDs,
+		"by_status":    stats.ByStatus,
+		"by_method":    stats.ByMethod,
+		"success_rate": successRate(stats.ByStatus["done"], stats.ByStatus["failed"], stats.ByStatus["cancelled"]),
+		"metrics":      metrics,
+	})
+}
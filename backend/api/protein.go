@@ -0,0 +1,20 @@
+package api
+
+import (
+	"dsa-api/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// addProteinInfo UniProtから取得したタンパク質の情報をレスポンスに追加する（取得できていない項目は含めない）
+func addProteinInfo(response fiber.Map, info storage.ProteinInfo) {
+	if info.Name != "" {
+		response["protein_name"] = info.Name
+	}
+	if info.Organism != "" {
+		response["organism"] = info.Organism
+	}
+	if info.SequenceLength > 0 {
+		response["sequence_length"] = info.SequenceLength
+	}
+}
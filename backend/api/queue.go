@@ -0,0 +1,81 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getQueue GET /api/queue 待ち行列の長さ・ステータスごとの数・実行中のジョブと実行時間・実行枠の使用率を返す
+// 待ちが続いていて実行枠の使用率が常に1であれば、MAX_CONCURRENTを増やす目安になる
+func (r *Routes) getQueue(c *fiber.Ctx) error {
+	status := r.jobManager.QueueStatus()
+	response := fiber.Map{
+		"depth":                 status.Depth,
+		"oldest_queued_seconds": status.OldestQueuedSeconds,
+		"status_counts":         status.StatusCounts,
+		"slots":                 status.Slots,
+		"running":               status.Running,
+		"pause":                 status.Pause,
+	}
+	// リモートワーカーを使う場合、実行枠を割り当てられたジョブもワーカーが取得するまで待っている
+	if r.remote != nil {
+		unclaimed, claimed := r.remote.Pending()
+		response["remote"] = fiber.Map{
+			"unclaimed": unclaimed,
+			"claimed":   claimed,
+		}
+	}
+	return c.JSON(response)
+}
+
+// getActiveJobs GET /api/jobs/active キュー待ち・実行中のすべてのジョブの概要（ID・UniProt ID・進捗・段階・開始時刻）を返す
+// 状態の更新時に作り直される一覧を返すだけのため、ダッシュボードから短い間隔で呼んでもよい
+func (r *Routes) getActiveJobs(c *fiber.Ctx) error {
+	active := r.jobManager.ActiveJobs()
+	return c.JSON(fiber.Map{
+		"jobs":  active,
+		"count": len(active),
+	})
+}
+
+// pauseQueue POST /api/admin/queue/pause 新しいジョブへの実行枠の割り当てを止める（デプロイ・PDBの障害時用）
+// 停止中もジョブの投入は受け付けてqueuedのまま待たせ、実行中のジョブはそのまま完了まで実行される
+func (r *Routes) pauseQueue(c *fiber.Ctx) error {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+
+	state, changed := r.jobManager.PauseQueue(strings.TrimSpace(req.Reason))
+	return c.JSON(r.queuePauseResponse(state, changed))
+}
+
+// resumeQueue POST /api/admin/queue/resume 実行枠の割り当てを再開する
+func (r *Routes) resumeQueue(c *fiber.Ctx) error {
+	state, changed := r.jobManager.ResumeQueue()
+	return c.JSON(r.queuePauseResponse(state, changed))
+}
+
+// queuePauseResponse 一時停止・再開の結果（changedは今回の呼び出しで状態が変わったかどうか、depthは実行枠を待っているジョブ数）
+func (r *Routes) queuePauseResponse(state jobs.QueuePause, changed bool) fiber.Map {
+	response := fiber.Map{
+		"paused":  state.Paused,
+		"changed": changed,
+		"depth":   r.jobManager.SchedulerStats().Queued,
+	}
+	if state.PausedAt != nil {
+		response["paused_at"] = formatTime(*state.PausedAt)
+	}
+	if state.Reason != "" {
+		response["reason"] = state.Reason
+	}
+	return response
+}
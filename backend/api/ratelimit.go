@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 使われていないバケットを削除する間隔
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit トークンバケットによるリクエスト数の制限（Per ごとに Requests 回、最大 Burst 回まで連続で受け付ける）
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	// バケットの容量（0の場合はRequests）
+	Burst int
+}
+
+// rateLimiter 集計単位（IPアドレス・セッション）ごとのトークンバケット
+type rateLimiter struct {
+	name  string
+	rate  float64 // 1秒あたりに補充するトークン数
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+
+	// 429を返した回数（メトリクス用）
+	rejected atomic.Int64
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter 制限が無効（RequestsまたはPerが0以下）の場合はnilを返す
+func newRateLimiter(name string, limit RateLimit) *rateLimiter {
+	if limit.Requests <= 0 || limit.Per <= 0 {
+		return nil
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Requests
+	}
+	return &rateLimiter{
+		name:      name,
+		rate:      float64(limit.Requests) / limit.Per.Seconds(),
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// rateLimitResult 制限の判定結果（レスポンスヘッダー用）
+type rateLimitResult struct {
+	allowed   bool
+	remaining int
+	// 足りないトークンが補充されるまでの時間（拒否した場合のRetry-After）
+	retryAfter time.Duration
+	// バケットが満杯に戻るまでの時間
+	reset time.Duration
+}
+
+// allow すべての集計単位にトークンがn個以上残っている場合のみ、それぞれからn個ずつ消費する
+// 残り回数・待ち時間は最も厳しい集計単位のものを返す
+func (l *rateLimiter) allow(keys []string, n int, now time.Time) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	buckets := make([]*tokenBucket, len(keys))
+	minTokens := l.burst
+	for i, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &tokenBucket{tokens: l.burst, updated: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+		b.updated = now
+		buckets[i] = b
+		minTokens = math.Min(minTokens, b.tokens)
+	}
+
+	cost := float64(n)
+	result := rateLimitResult{allowed: minTokens >= cost}
+	if result.allowed {
+		for _, b := range buckets {
+			b.tokens -= cost
+		}
+		minTokens -= cost
+	} else {
+		result.retryAfter = l.wait(cost - minTokens)
+	}
+	result.remaining = int(math.Max(0, math.Floor(minTokens)))
+	result.reset = l.wait(l.burst - minTokens)
+	return result
+}
+
+// wait トークンがn個補充されるまでの時間
+func (l *rateLimiter) wait(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / l.rate * float64(time.Second))
+}
+
+// sweep 満杯に戻ったバケットを削除する（l.muを保持して呼ぶ）
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// SetJobRateLimit ジョブの作成（POST /api/jobs、GET /api/jobs/new、再実行）のリクエスト数の制限を設定する（Requestsが0以下は無制限）
+func (r *Routes) SetJobRateLimit(limit RateLimit) {
+	r.jobRate = newRateLimiter("jobs", limit)
+}
+
+// SetArtifactRateLimit 成果物の取得のリクエスト数の制限を設定する（Requestsが0以下は無制限）
+func (r *Routes) SetArtifactRateLimit(limit RateLimit) {
+	r.artifactRate = newRateLimiter("artifacts", limit)
+}
+
+// SetAuthRateLimit ユーザー登録・ログイン（POST /api/auth/register・login）のIPアドレスごとのリクエスト数の制限を設定する（Requestsが0以下は無制限）
+func (r *Routes) SetAuthRateLimit(limit RateLimit) {
+	r.authRate = newRateLimiter("auth", limit)
+}
+
+// jobRateLimit ジョブの作成のリクエスト数を制限する
+func (r *Routes) jobRateLimit(c *fiber.Ctx) error {
+	return r.rateLimit(c, r.jobRate, r.egressKeys(c))
+}
+
+// artifactRateLimit 成果物の取得のリクエスト数を制限する
+func (r *Routes) artifactRateLimit(c *fiber.Ctx) error {
+	return r.rateLimit(c, r.artifactRate, r.egressKeys(c))
+}
+
+// authRateLimit ユーザー登録・ログインのリクエスト数を制限する（パスワードの総当たり対策、セッションは作り直せるためIPアドレスのみで集計する）
+func (r *Routes) authRateLimit(c *fiber.Ctx) error {
+	return r.rateLimit(c, r.authRate, []string{"ip:" + c.IP()})
+}
+
+// rateLimit 集計単位（jobs・artifactsはegressKeysと同じIPアドレスとセッション）ごとにリクエスト数を制限し、超えた場合は429を返す
+// 残り回数をX-RateLimit-Limit・X-RateLimit-Remaining・X-RateLimit-Reset（満杯に戻るまでの秒数）で返す
+func (r *Routes) rateLimit(c *fiber.Ctx, limiter *rateLimiter, keys []string) error {
+	if limiter == nil {
+		return c.Next()
+	}
+	if ok, err := consumeRateLimit(c, limiter, keys, 1); !ok {
+		return err
+	}
+	return c.Next()
+}
+
+// consumeRateLimit n回分のリクエストとしてトークンを消費する（足りない場合は消費せずに429を返し、falseを返す）
+func consumeRateLimit(c *fiber.Ctx, limiter *rateLimiter, keys []string, n int) (bool, error) {
+	if limiter == nil {
+		return true, nil
+	}
+	result := limiter.allow(keys, n, time.Now())
+	c.Set("X-RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+	if !result.allowed {
+		limiter.rejected.Add(1)
+		retryAfter := ceilSeconds(result.retryAfter)
+		c.Set("Retry-After", strconv.Itoa(retryAfter))
+		return false, c.Status(429).JSON(fiber.Map{
+			"error":       fmt.Sprintf("Rate limit exceeded for %s, retry after %d seconds", limiter.name, retryAfter),
+			"retry_after": retryAfter,
+		})
+	}
+	return true, nil
+}
+
+// ceilSeconds 秒単位に切り上げる
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitedTotals 制限ごとの429を返した回数（無効な制限は含めない）
+func (r *Routes) rateLimitedTotals() ([]string, map[string]int) {
+	var names []string
+	totals := make(map[string]int)
+	for _, limiter := range []*rateLimiter{r.jobRate, r.artifactRate, r.authRate} {
+		if limiter == nil {
+			continue
+		}
+		names = append(names, limiter.name)
+		totals[limiter.name] = int(limiter.rejected.Load())
+	}
+	return names, totals
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetReadOnly 読み取り専用モード（公開ミラー用）を設定する
+// 有効な場合、ジョブの作成・キャンセル・削除・再実行などの変更系APIは403を返す
+func (r *Routes) SetReadOnly(readOnly bool) {
+	r.readOnly = readOnly
+}
+
+// readOnlyGuard 読み取り専用モードの場合に変更系APIを拒否する
+func (r *Routes) readOnlyGuard(c *fiber.Ctx) error {
+	if r.readOnly {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Server is in read-only mode",
+		})
+	}
+	return c.Next()
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reconcileStorage POST /api/admin/reconcile DB・ローカルのジョブディレクトリ・R2を照合し、孤立したものを報告する
+// ?clean=true の場合は孤立したものを削除し、成果物が失われた解析を成果物の削除済みとして記録する
+func (r *Routes) reconcileStorage(c *fiber.Ctx) error {
+	clean := c.QueryBool("clean")
+	if clean && r.readOnly {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Server is in read-only mode",
+		})
+	}
+
+	report, err := r.jobManager.Reconcile(c.UserContext(), clean)
+	switch {
+	case errors.Is(err, jobs.ErrReconcileUnavailable):
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	case errors.Is(err, jobs.ErrReconcileRunning):
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Reconciliation is already running",
+		})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,376 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"dsa-api/pdf"
+	"dsa-api/xlsx"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getAnalysisReport GET /api/analyses/:id/report.xlsx 解析の結果をExcelのブックとして返す
+// Metrics（パラメータ・スコアの集計・統計）、Residue scores（残基ごとの平均スコア）、PDB entries（解析に使用したPDBエントリ）の3シート
+// 残基ごとのスコアはscore_matrix.json、PDBエントリの詳細はstructures.jsonから作る（ない解析ではresult.jsonの内容のみ）
+func (r *Routes) getAnalysisReport(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+
+	result, status, err := r.loadAnalysisResult(ctx, id)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	var wb xlsx.Workbook
+	metrics := wb.AddSheet("Metrics", "section", "name", "value")
+	metrics.AddRow("analysis", "analysis_id", id)
+	metrics.AddRow("analysis", "uniprot_id", result.UniProtID)
+	addReportValues(metrics, "parameters", "", result.Parameters)
+	addReportValues(metrics, "score_summary", "", result.ScoreSummary)
+	addReportValues(metrics, "statistics", "", result.Statistics)
+
+	residues := wb.AddSheet("Residue scores", "residue", "mean_score")
+	matrix, _, err := r.loadScoreMatrix(ctx, id)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		// スコア行列の保存以前に実行された解析には存在しない
+		residues.AddRow(nil, "score_matrix.json not available (re-run the analysis to generate it)")
+	} else {
+		for i, score := range residueScores(matrix) {
+			if score == nil {
+				residues.AddRow(i + 1)
+				continue
+			}
+			residues.AddRow(i+1, *score)
+		}
+	}
+
+	if err := r.addReportStructures(c, &wb, id, result); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := wb.Write(&b); err != nil {
+		requestLog(c).Errorf("Failed to generate report for %s: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate report",
+		})
+	}
+	c.Set("Content-Type", xlsx.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.xlsx\"", id))
+	return c.Send(b.Bytes())
+}
+
+// addReportStructures PDB entriesシート（structures.jsonがなければstatistics.pdb_idsのみ）
+func (r *Routes) addReportStructures(c *fiber.Ctx, wb *xlsx.Workbook, id string, result *analysisResult) error {
+	data, err := r.loadArtifact(c.UserContext(), id, "structures.json", nil)
+	var report structureReport
+	if err == nil {
+		err = json.Unmarshal(data, &report)
+	}
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		sheet := wb.AddSheet("PDB entries", "pdb_id")
+		ids := make([]string, 0)
+		for pdbID := range stringSet(result.Statistics["pdb_ids"]) {
+			ids = append(ids, pdbID)
+		}
+		sort.Strings(ids)
+		for _, pdbID := range ids {
+			sheet.AddRow(pdbID)
+		}
+		return nil
+	}
+
+	sheet := wb.AddSheet("PDB entries", "pdb_id", "method", "resolution", "chains", "deviation", "mean_abs_diff", "outlier_score", "outlier")
+	for _, s := range report.Structures {
+		var method, resolution interface{}
+		if s.Method != nil {
+			method = *s.Method
+		}
+		if s.Resolution != nil {
+			resolution = *s.Resolution
+		}
+		sheet.AddRow(s.PDBID, method, resolution, strings.Join(s.Chains, ","), s.Deviation, s.MeanAbsDiff, s.OutlierScore, s.Outlier)
+	}
+	return nil
+}
+
+// addReportValues 値を名前の順に1行ずつ追加する（入れ子のオブジェクトは「親.子」の名前に展開し、配列は含めない）
+func addReportValues(sheet *xlsx.Sheet, section, prefix string, values map[string]interface{}) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		switch v := values[key].(type) {
+		case map[string]interface{}:
+			addReportValues(sheet, section, name, v)
+		case []interface{}:
+			// pdb_ids等はPDB entriesシートに含める
+		default:
+			sheet.AddRow(section, name, v)
+		}
+	}
+}
+
+// PDFレポートの余白・行の高さ（pt）
+const (
+	pdfMargin     = 40.0
+	pdfLineHeight = 13.0
+)
+
+// pdfReportMetrics PDFレポートに載せる主な指標（result.jsonのセクションとキー）
+var pdfReportMetrics = []struct {
+	label   string
+	section string
+	key     string
+}{
+	{"Mean score", "score_summary", "mean_score"},
+	{"Std score", "score_summary", "std_score"},
+	{"Max score", "score_summary", "max_score"},
+	{"Min score", "score_summary", "min_score"},
+	{"Mean distance", "score_summary", "mean_distance"},
+	{"Mean distance std", "score_summary", "mean_std"},
+	{"Residue pairs", "score_summary", "total_pairs"},
+	{"Entries", "statistics", "entries"},
+	{"Chains", "statistics", "chains"},
+	{"Length", "statistics", "length"},
+	{"Length (%)", "statistics", "length_percent"},
+	{"Resolution", "statistics", "resolution"},
+	{"UMF", "statistics", "umf"},
+}
+
+// getAnalysisPDFReport GET /api/analyses/:id/report.pdf 実験ノートに貼るための1ページのPDFを返す
+// 主な指標・パラメータ・ヒートマップと散布図（幅800pxに縮小）・来歴（日時・パイプラインのバージョン・CLI引数）を載せる
+// 日時は?tz（デフォルトはDEFAULT_TIMEZONE）で表示する
+func (r *Routes) getAnalysisPDFReport(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx := c.UserContext()
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	result, status, err := r.loadAnalysisResult(ctx, id)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page := pdf.NewPage(pdf.A4Width, pdf.A4Height)
+	contentWidth := pdf.A4Width - 2*pdfMargin
+	y := pdfMargin + 18
+	page.Text(pdfMargin, y, 18, true, "DSA Analysis Report")
+	y += 20
+	subtitle := "UniProt ID: " + result.UniProtID
+	if method, ok := result.Parameters["method"]; ok {
+		subtitle += "    Method: " + reportValue(method)
+	}
+	page.Text(pdfMargin, y, 11, false, subtitle)
+	y += 10
+	page.Line(pdfMargin, y, pdfMargin+contentWidth, y, 0.5, 0.6)
+	y += 20
+
+	// 主な指標（左）とパラメータ（右）
+	columnWidth := (contentWidth - 20) / 2
+	rightX := pdfMargin + columnWidth + 20
+	page.Text(pdfMargin, y, 12, true, "Key metrics")
+	page.Text(rightX, y, 12, true, "Parameters")
+	top := y + pdfLineHeight + 2
+	left := top
+	for _, m := range pdfReportMetrics {
+		var values map[string]interface{}
+		if m.section == "score_summary" {
+			values = result.ScoreSummary
+		} else {
+			values = result.Statistics
+		}
+		v, ok := values[m.key]
+		if !ok || v == nil {
+			continue
+		}
+		page.Text(pdfMargin, left, 9, false, m.label)
+		page.Text(pdfMargin+110, left, 9, false, reportValue(v))
+		left += pdfLineHeight
+	}
+	right := top
+	keys := make([]string, 0, len(result.Parameters))
+	for key := range result.Parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := reportValue(result.Parameters[key])
+		for i, line := range pdf.Wrap(value, 9, columnWidth-110) {
+			if i == 0 {
+				page.Text(rightX, right, 9, false, key)
+			}
+			page.Text(rightX+110, right, 9, false, line)
+			right += pdfLineHeight
+		}
+	}
+	if right > left {
+		left = right
+	}
+	y = left + 10
+
+	// ヒートマップと散布図を横に並べる
+	imageWidth := (contentWidth - 20) / 2
+	imageBottom := y
+	for i, name := range []string{"heatmap.png", "dist_score.png"} {
+		x := pdfMargin + float64(i)*(imageWidth+20)
+		img, err := r.reportImage(ctx, id, name)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			page.Text(x, y+pdfLineHeight, 9, false, name+" not available")
+			continue
+		}
+		bounds := img.Bounds()
+		h := imageWidth * float64(bounds.Dy()) / float64(bounds.Dx())
+		if err := page.Image(img, x, y, imageWidth, h); err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to embed image",
+			})
+		}
+		if y+h > imageBottom {
+			imageBottom = y + h
+		}
+	}
+	y = imageBottom + 25
+
+	// 来歴
+	page.Line(pdfMargin, y-12, pdfMargin+contentWidth, y-12, 0.5, 0.6)
+	page.Text(pdfMargin, y, 12, true, "Provenance")
+	y += pdfLineHeight + 2
+	provenance := [][2]string{{"Analysis ID", id}}
+	for _, t := range r.analysisTimestamps(ctx, id) {
+		provenance = append(provenance, [2]string{t.label, t.at.In(loc).Format("2006-01-02 15:04:05 MST")})
+	}
+	if version := r.instance.PipelineVersion; version != "" {
+		provenance = append(provenance, [2]string{"Pipeline version", version + " (server)"})
+	}
+	if name := r.instance.Name; name != "" {
+		provenance = append(provenance, [2]string{"Instance", name})
+	}
+	if args := r.jobManager.AnalysisCLIArgs(ctx, id); len(args) > 0 {
+		provenance = append(provenance, [2]string{"CLI arguments", strings.Join(args, " ")})
+	}
+	provenance = append(provenance, [2]string{"Report generated", time.Now().In(loc).Format("2006-01-02 15:04:05 MST")})
+	for _, p := range provenance {
+		for i, line := range pdf.Wrap(p[1], 9, contentWidth-110) {
+			if y > pdf.A4Height-pdfMargin {
+				break
+			}
+			if i == 0 {
+				page.Text(pdfMargin, y, 9, false, p[0])
+			}
+			page.Text(pdfMargin+110, y, 9, false, line)
+			y += pdfLineHeight
+		}
+	}
+
+	var b bytes.Buffer
+	if err := page.Write(&b); err != nil {
+		requestLog(c).Errorf("Failed to generate PDF report for %s: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to generate report",
+		})
+	}
+	c.Set("Content-Type", pdf.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.pdf\"", id))
+	return c.Send(b.Bytes())
+}
+
+// reportImage PDFに埋め込む画像（?size=mediumと同じ縮小した画像、キャッシュを使う）
+func (r *Routes) reportImage(ctx context.Context, id, name string) (image.Image, error) {
+	data, ok := r.cachedImageVariant(id, name, imageSizeMedium)
+	if !ok {
+		original, err := r.loadArtifact(ctx, id, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		data, err = r.imageVariant(id, name, imageSizeMedium, original)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// analysisTimestamp 来歴に載せる日時
+type analysisTimestamp struct {
+	label string
+	at    time.Time
+}
+
+// analysisTimestamps 解析の作成・開始・完了日時（DBがなければジョブの作成日時と最終更新日時）
+func (r *Routes) analysisTimestamps(ctx context.Context, id string) []analysisTimestamp {
+	if r.db != nil {
+		if record, err := r.getAnalysisRecord(ctx, id); err == nil {
+			timestamps := []analysisTimestamp{{"Created", record.CreatedAt}}
+			if record.StartedAt != nil {
+				timestamps = append(timestamps, analysisTimestamp{"Started", *record.StartedAt})
+			}
+			if record.FinishedAt != nil {
+				timestamps = append(timestamps, analysisTimestamp{"Finished", *record.FinishedAt})
+			}
+			return timestamps
+		}
+	}
+	job, err := r.jobManager.GetJob(id)
+	if err != nil {
+		return nil
+	}
+	return []analysisTimestamp{{"Created", job.CreatedAt}, {"Finished", job.UpdatedAt}}
+}
+
+// reportValue レポートに表示する値（数値は有効数字6桁）
+func reportValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
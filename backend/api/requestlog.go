@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"dsa-api/logging"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// リクエストIDのヘッダー（クライアント・リバースプロキシが指定した値を引き継ぎ、ない場合は生成する）
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+)
+
+// 引き継ぐリクエストIDの形式（ログへの改行・制御文字の混入を防ぐ）
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// logRequests リクエストIDを付け（レスポンスのX-Request-IDにも返す）、リクエストごとにメソッド・パス・ステータス・処理時間をログに出力する
+// ヘルスチェック・メトリクスはdebug、5xxはerrorのレベルで出力する
+// エラーのレスポンス（{"error": ...}）にはrequest_idを追加する（ハンドラーが返したエラーはapp.ErrorHandlerでRequestIDを付ける）
+func (r *Routes) logRequests(c *fiber.Ctx) error {
+	start := time.Now()
+	id := c.Get(requestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		id = uuid.New().String()
+	}
+	c.Locals(requestIDKey, id)
+	c.Set(requestIDHeader, id)
+
+	// /api/v1/... はversionedAPIでパスを書き換えるため、リクエストされたパスを記録する
+	path := utils.CopyString(c.Path())
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	// エンベロープのレスポンスはmetaにrequest_idを含む
+	if err == nil && status >= 400 && apiVersion(c) == "" {
+		addRequestIDToError(c, id)
+	}
+	if err != nil {
+		// エラーハンドラーはミドルウェアの後に呼ばれるため、返すステータスをここで決める
+		status = fiber.StatusInternalServerError
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			status = fe.Code
+		}
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case c.Path() == "/api/health" || path == "/metrics":
+		level = slog.LevelDebug
+	}
+	logging.With(
+		"request_id", id,
+		"method", c.Method(),
+		"path", path,
+		"status", status,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"ip", c.IP(),
+	).Logf(level, "%s %s %d", c.Method(), path, status)
+	return err
+}
+
+// addRequestIDToError JSONのエラーレスポンスにrequest_idを追加する（JSON以外・errorのないレスポンスは変更しない）
+func addRequestIDToError(c *fiber.Ctx, id string) {
+	resp := c.Response()
+	if resp.IsBodyStream() || !bytes.HasPrefix(resp.Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
+		return
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(resp.Body(), &body); err != nil {
+		return
+	}
+	if _, ok := body["error"]; !ok {
+		return
+	}
+	if _, ok := body[requestIDKey]; ok {
+		return
+	}
+	body[requestIDKey], _ = json.Marshal(id)
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	resp.SetBody(encoded)
+}
+
+// RequestID リクエストのID（logRequestsを通っていない場合は空）
+func RequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDKey).(string)
+	return id
+}
+
+// requestLog リクエストIDを付けたロガー（ハンドラー内のログ用）
+func requestLog(c *fiber.Ctx) *logging.Logger {
+	id := RequestID(c)
+	if id == "" {
+		return logging.With()
+	}
+	return logging.With("request_id", id)
+}
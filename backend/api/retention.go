@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// artifactsExpiredAt 保持期間を過ぎて成果物が削除された日時（削除されていない場合・DBがない場合はnil）
+func (r *Routes) artifactsExpiredAt(ctx context.Context, id string) *time.Time {
+	if r.db == nil {
+		return nil
+	}
+	expired, err := r.db.ArtifactsExpiredAt(ctx, []string{id})
+	if err != nil {
+		logging.Warnf("Failed to check artifact expiry for %s: %v", id, err)
+		return nil
+	}
+	if at, ok := expired[id]; ok {
+		return &at
+	}
+	return nil
+}
+
+// artifactsGuard 成果物が削除済みの解析（:id）へのリクエストに410、長期保存先に移した解析には409を返す
+func (r *Routes) artifactsGuard(c *fiber.Ctx) error {
+	if unavailable, err := r.artifactsUnavailable(c, c.Params("id")); unavailable {
+		return err
+	}
+	return c.Next()
+}
+
+// artifactsUnavailable 成果物が削除済み・長期保存先に移した解析の場合にエラーを返してtrue
+func (r *Routes) artifactsUnavailable(c *fiber.Ctx, id string) (bool, error) {
+	if at := r.artifactsExpiredAt(c.UserContext(), id); at != nil {
+		return true, artifactsExpiredError(c, id, *at)
+	}
+	if a := r.jobManager.AnalysisArchive(c.UserContext(), id); a != nil {
+		if a.Status == storage.ArchiveStatusArchived || a.Status == storage.ArchiveStatusRestoring {
+			return true, archivedError(c, id, a)
+		}
+	}
+	return false, nil
+}
+
+// artifactsExpiredError 成果物が削除済みであることと、再実行の方法を返す
+func artifactsExpiredError(c *fiber.Ctx, id string, at time.Time) error {
+	return c.Status(410).JSON(fiber.Map{
+		"error":                fmt.Sprintf("Artifacts of analysis %s have expired and were deleted", id),
+		"artifacts_expired":    true,
+		"artifacts_expired_at": formatTime(at),
+		"suggestion":           "Re-run the analysis to regenerate the results",
+		"rerun_url":            fmt.Sprintf("/api/analyses/%s/rerun", id),
+	})
+}
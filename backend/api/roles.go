@@ -0,0 +1,252 @@
+package api
+
+import (
+	"database/sql"
+	"dsa-api/auth"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// c.Localsに保存するリクエストのロールのキー
+	requestRoleKey = "request_role"
+	// GET /api/admin/usersの件数の上限
+	maxUserListLimit = 500
+)
+
+// anonymousRole ログインしていないリクエストのロール（ANONYMOUS_ROLE）
+// 未設定の場合、アカウントが有効（DB設定時、セッションレスモード以外）ならviewer、無効ならanalyst（アカウントのない運用でもこれまで通り解析を作成できる）
+func (r *Routes) anonymousRole() string {
+	if r.auth.AnonymousRole != "" {
+		return r.auth.AnonymousRole
+	}
+	if r.db != nil && !r.sessionless {
+		return auth.RoleViewer
+	}
+	return auth.RoleAnalyst
+}
+
+// requestRole リクエストのロール
+// 管理用のAPIキー（X-API-Key）はadmin、匿名のセッションはanonymousRole
+// ログイン中はDBのロールを使う（トークンの発行後に変更されたロールもすぐに反映する）
+func (r *Routes) requestRole(c *fiber.Ctx) string {
+	if role, ok := c.Locals(requestRoleKey).(string); ok {
+		return role
+	}
+	role := r.anonymousRole()
+	if r.validAPIKey(requestAPIKey(c)) {
+		role = auth.RoleAdmin
+	} else if claims := requestClaims(c); claims != nil && r.db != nil {
+		user, err := r.db.GetUser(c.UserContext(), claims.Subject)
+		switch {
+		case err == nil:
+			role = user.Role
+		case errors.Is(err, sql.ErrNoRows):
+			// 削除されたユーザーのトークンは閲覧のみ
+			role = auth.RoleViewer
+		default:
+			// ロールを確認できない場合は権限を与えない
+			requestLog(c).Warnf("Failed to load role of user %s: %v", claims.Subject, err)
+			role = auth.RoleViewer
+		}
+	}
+	c.Locals(requestRoleKey, role)
+	return role
+}
+
+// requireAnalyst 解析の作成・再実行・キャンセル・削除等にanalyst以上のロールを必須とする（viewerは403）
+func (r *Routes) requireAnalyst(c *fiber.Ctx) error {
+	if !auth.HasRole(r.requestRole(c), auth.RoleAnalyst) {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Analyst role is required",
+		})
+	}
+	return c.Next()
+}
+
+// requireAdmin 管理用APIに管理用のAPIキーまたはadminのユーザーのログインを必須とする
+// APIキーが設定されておらずログインもしていない場合は管理用APIを無効として扱う
+func (r *Routes) requireAdmin(c *fiber.Ctx) error {
+	if r.requestRole(c) == auth.RoleAdmin {
+		return c.Next()
+	}
+	if requestClaims(c) != nil {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Admin role is required",
+		})
+	}
+	if len(r.apiKeys) == 0 {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Admin API is disabled (API_KEYS is not configured)",
+		})
+	}
+	return c.Status(401).JSON(fiber.Map{
+		"error": "Valid API key is required",
+	})
+}
+
+// listSessionID 一覧・統計を絞り込むセッションID（空文字列の場合はすべてのセッション）
+// すべてのセッションを対象にできるのは管理者（?all_sessions=true、またはセッションのないAPIキーのリクエスト）とセッションレスモードのみ
+// セッションのない管理者以外のリクエストには新しいセッションを発行し、他のセッションの解析を返さない
+func (r *Routes) listSessionID(c *fiber.Ctx) string {
+	if r.sessionless {
+		return ""
+	}
+	admin := r.requestRole(c) == auth.RoleAdmin
+	if admin && c.QueryBool("all_sessions") {
+		return ""
+	}
+	if sessionID := r.requestSessionID(c); sessionID != "" || admin {
+		return sessionID
+	}
+	return ensureSessionID(c)
+}
+
+// requireAllSessionsAdmin ?all_sessions=true（すべてのセッションの一覧）を管理者以外に403で拒否する
+func (r *Routes) requireAllSessionsAdmin(c *fiber.Ctx) error {
+	if c.QueryBool("all_sessions") && !r.sessionless && r.requestRole(c) != auth.RoleAdmin {
+		return c.Status(403).JSON(fiber.Map{
+			"error": "Admin role is required to list all sessions",
+		})
+	}
+	return c.Next()
+}
+
+// updateUserRoleSchema PATCH /api/admin/users/:id
+var updateUserRoleSchema = objectSchema{
+	"role": {Type: typeString, Required: true, Enum: auth.Roles},
+}
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// listUsers GET /api/admin/users 登録されたユーザーとロールの一覧（limit・offset）
+func (r *Routes) listUsers(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > maxUserListLimit {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("limit must be between 1 and %d", maxUserListLimit),
+		})
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "offset must not be negative",
+		})
+	}
+
+	users, err := r.db.ListUsers(c.UserContext(), limit, offset)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		requestLog(c).Errorf("Failed to list users: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to list users",
+		})
+	}
+
+	response := make([]fiber.Map, 0, len(users))
+	for _, user := range users {
+		item := userResponse(user)
+		item["last_login_at"] = nil
+		if user.LastLoginAt != nil {
+			item["last_login_at"] = formatTime(*user.LastLoginAt)
+		}
+		response = append(response, item)
+	}
+	return c.JSON(fiber.Map{
+		"users":  response,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// updateUserRole PATCH /api/admin/users/:id ユーザーのロールを変更する（次のリクエストから反映される）
+func (r *Routes) updateUserRole(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req UpdateUserRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	// 自分自身のadminを外すと管理用APIを使えなくなるため拒否する
+	if claims := requestClaims(c); claims != nil && claims.Subject == id && req.Role != auth.RoleAdmin && !r.validAPIKey(requestAPIKey(c)) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Cannot remove your own admin role",
+		})
+	}
+
+	user, err := r.db.SetUserRole(c.UserContext(), id, req.Role)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "User not found",
+			})
+		}
+		requestLog(c).Errorf("Failed to update role of user %s: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update role",
+		})
+	}
+	requestLog(c).Infof("Changed role of user %s to %s", user.ID, user.Role)
+	return c.JSON(userResponse(user))
+}
+
+// minRetentionDays 保持期間の下限（0は無期限）
+var minRetentionDays = 0.0
+
+// updateRetentionSchema PATCH /api/admin/retention
+var updateRetentionSchema = objectSchema{
+	"artifact_retention_days": {Type: typeInteger, Min: &minRetentionDays},
+	"retention_days":          {Type: typeInteger, Min: &minRetentionDays},
+}
+
+type UpdateRetentionRequest struct {
+	ArtifactRetentionDays *int `json:"artifact_retention_days"`
+	RetentionDays         *int `json:"retention_days"`
+}
+
+// getRetention GET /api/admin/retention 成果物・解析の保持期間（日数、0は無期限）
+func (r *Routes) getRetention(c *fiber.Ctx) error {
+	return c.JSON(r.retentionResponse())
+}
+
+// updateRetention PATCH /api/admin/retention 保持期間を変更する（指定した項目のみ、再起動するとARTIFACT_RETENTION_DAYS・RETENTION_DAYSに戻る）
+func (r *Routes) updateRetention(c *fiber.Ctx) error {
+	var req UpdateRetentionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.ArtifactRetentionDays != nil {
+		if r.db == nil && *req.ArtifactRetentionDays > 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Artifact retention requires the database",
+			})
+		}
+		r.jobManager.SetArtifactRetention(time.Duration(*req.ArtifactRetentionDays) * 24 * time.Hour)
+	}
+	if req.RetentionDays != nil {
+		r.jobManager.SetRetention(time.Duration(*req.RetentionDays) * 24 * time.Hour)
+	}
+	response := r.retentionResponse()
+	requestLog(c).Infof("Retention changed: artifacts=%v days, analyses=%v days", response["artifact_retention_days"], response["retention_days"])
+	return c.JSON(response)
+}
+
+func (r *Routes) retentionResponse() fiber.Map {
+	return fiber.Map{
+		"artifact_retention_days": int(r.jobManager.ArtifactRetention().Hours() / 24),
+		"retention_days":          int(r.jobManager.Retention().Hours() / 24),
+	}
+}
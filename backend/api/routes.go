@@ -1,77 +1,289 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"dsa-api/alerts"
 	"dsa-api/jobs"
+	"dsa-api/logging"
+	"dsa-api/notify"
 	"dsa-api/storage"
+	"dsa-api/storage/pdbcache"
+	"dsa-api/webhooks"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
 )
 
 type Routes struct {
 	jobManager *jobs.Manager
 	db         *storage.DB
-	r2         *storage.R2Client
-	ctx        context.Context
+	r2         *storage.GuardedR2Client
 	storageDir string
+	// ルートごとのタイムアウト
+	routeTimeout     time.Duration
+	longRouteTimeout time.Duration
+	// フロントエンド向け設定
+	clientConfig ClientConfig
+	// 成果物のダウンロード量（月間上限）
+	egress *egressTracker
+	// ジョブの作成・成果物の取得のリクエスト数の制限（nilは無制限）
+	jobRate      *rateLimiter
+	artifactRate *rateLimiter
+	authRate     *rateLimiter
+	// 縮小した画像の成果物（?size=small|medium）
+	imageVariants *imageVariantCache
+	// 残基ごとの平均スコア（GET /api/analyses/:id/scores）
+	residueScores residueScoreCache
+	// ジョブ間で共有する構造ファイルのキャッシュ（GET /api/pdb/:pdbid）
+	pdbCache *pdbcache.Cache
+	// 読み取り専用モード（公開ミラー用）
+	readOnly bool
+	// 共有リンク
+	share ShareConfig
+	// ユーザーアカウント（ログイン用のトークン）
+	auth AuthConfig
+	// 外部連携用のAPIキー
+	apiKeys map[string]bool
+	// AUTH_MODE=api_key（APIキー必須、セッションCookieを使わない）
+	sessionless bool
+	// 日付のみの絞り込み・日別の集計のデフォルトのタイムゾーン（nilはUTC）
+	defaultLocation *time.Location
+	// Webhook（未設定の場合は無効）
+	webhooks *webhooks.Dispatcher
+	// アラートのルール（未設定の場合は無効）
+	alerts *alerts.Manager
+	// ジョブの終了のメール通知（未設定の場合は無効）
+	notifications *notify.Mailer
+	// リモートワーカー（未設定の場合は内部APIを無効）
+	remote      *jobs.RemoteExecutor
+	workerToken string
+	// デプロイごとの識別情報（GET /api/instance）
+	instance InstanceInfo
 }
 
-func NewRoutes(jobManager *jobs.Manager, db *storage.DB, r2 *storage.R2Client) *Routes {
+func NewRoutes(jobManager *jobs.Manager, db *storage.DB, r2 *storage.GuardedR2Client) *Routes {
 	return &Routes{
 		jobManager: jobManager,
 		db:         db,
 		r2:         r2,
-		ctx:        context.Background(),
 		storageDir: jobManager.GetStorageDir(),
+
+		routeTimeout:     defaultRouteTimeout,
+		longRouteTimeout: defaultLongRouteTimeout,
+		egress:           newEgressTracker(jobManager.GetStorageDir()),
+		imageVariants:    newImageVariantCache(defaultImageVariantCacheSize),
+		share:            ShareConfig{Secret: randomSecret()},
+		auth:             AuthConfig{Secret: randomSecret(), TokenTTL: defaultAuthTokenTTL},
 	}
 }
 
 type CreateJobRequest struct {
 	UniProtID string                 `json:"uniprot_id"`
 	Params    map[string]interface{} `json:"params"`
+	// 同じUniProt ID・パラメータのジョブがキュー待ち・実行中であればそのジョブを返す
+	Dedupe bool `json:"dedupe"`
+	// 結果キャッシュを使わずに必ず解析を実行する
+	NoCache bool `json:"no_cache"`
+	// ジョブを作成せずに検証・実行される内容（解決済みのパラメータ・Python CLIの引数）だけを返す
+	DryRun bool `json:"dry_run"`
+	// dry_runの場合にUniProtにエントリが存在するかも確認する
+	CheckUniProt bool `json:"check_uniprot"`
+	// ジョブの種類（interactive・batch、省略時はinteractive、JOB_CLASS_RESERVATIONSの予約に使う）
+	Class string `json:"class"`
+	// 終了（完了・失敗）を通知するメールアドレス（SMTP_HOST設定時のみ、NOTIFY_MIN_RUNTIMEより短いジョブは通知しない）
+	NotifyEmail string `json:"notify_email"`
 }
 
 func (r *Routes) SetupRoutes(app *fiber.App) {
+	// リクエストID・リクエストログ
+	app.Use(r.logRequests)
+
+	// /api/v1/... （従来の /api/... と同じルート、レスポンスはエンベロープ）
+	app.Use(r.versionedAPI)
+
+	// Prometheusメトリクス
+	app.Get("/metrics", r.getMetrics)
+
+	// 共有リンク（Open Graph/Twitterカード）
+	app.Get("/share/:token", withTimeout(r.longRouteTimeout, r.getSharePage))
+	app.Get("/share/:token/thumbnail.png", withTimeout(r.longRouteTimeout, r.getShareThumbnail))
+	app.Get("/share/:token/artifacts/:name", r.artifactRateLimit, r.egressGuard, withTimeout(r.longRouteTimeout, r.getShareArtifact))
+
 	api := app.Group("/api")
+	api.Use(r.requireAPIKey)
+	api.Use(r.authenticate)
+	api.Use(r.csrfGuard)
+
+	// CSRFトークン（Cookieのセッションで状態を変更するリクエストに必要）
+	api.Get("/csrf", r.getCSRFToken)
+
+	// ユーザーアカウント（任意、DB設定時のみ）
+	api.Post("/auth/register", r.readOnlyGuard, r.requireSessions, r.requireAccounts, r.authRateLimit, validateBody(credentialsSchema, false), withTimeout(r.routeTimeout, r.register))
+	api.Post("/auth/login", r.requireSessions, r.requireAccounts, r.authRateLimit, validateBody(credentialsSchema, false), withTimeout(r.routeTimeout, r.login))
+	api.Post("/auth/logout", r.logout)
+	api.Get("/auth/me", r.requireSessions, r.requireAccounts, withTimeout(r.routeTimeout, r.getCurrentUser))
+
+	// フロントエンド向け設定
+	api.Get("/config", r.getConfig)
+	api.Get("/instance", r.getInstance)
+
+	// ヘルスチェック
+	api.Get("/health", r.getHealth)
+	// 待ち行列と実行枠の状態（MAX_CONCURRENTの見直し用）
+	api.Get("/queue", r.getQueue)
+
+	// 成果物のダウンロード量
+	api.Get("/usage", r.getUsage)
+
+	// 日別のジョブ数（tzの日付で集計）
+	api.Get("/stats", r.requireAllSessionsAdmin, withTimeout(r.longRouteTimeout, r.getStats))
+	api.Get("/stats/daily", r.requireAllSessionsAdmin, withTimeout(r.longRouteTimeout, r.getDailyStats))
 
 	// ジョブ作成
-	api.Post("/jobs", r.createJob)
+	api.Post("/jobs", r.readOnlyGuard, r.requireAnalyst, r.jobRateLimit, validateBody(createJobSchema, false), withTimeout(r.routeTimeout, r.createJob))
+
+	// URLクエリからのジョブ作成（外部サイトからのディープリンク、/jobs/:idより先に定義）
+	api.Get("/jobs/new", r.jobRateLimit, withTimeout(r.routeTimeout, r.newJobFromQuery))
+
+	// ジョブ一覧（メモリ上のジョブとDBの解析をまとめる、DBがなくても動作する）
+	api.Get("/jobs", r.requireAllSessionsAdmin, withTimeout(r.routeTimeout, r.listJobs))
+
+	// キュー待ち・実行中のジョブの概要（ダッシュボード用、/jobs/:idより先に定義）
+	api.Get("/jobs/active", r.getActiveJobs)
 
 	// ジョブ状態取得
-	api.Get("/jobs/:id", r.getJob)
+	api.Get("/jobs/:id", withTimeout(r.routeTimeout, r.getJob))
+
+	// 複数ジョブの進捗をWebSocketで配信
+	api.Get("/ws", r.wsUpgrade, websocket.New(r.handleWS))
 
 	// 結果ファイル取得（R2から取得）
-	api.Get("/jobs/:id/result.json", r.getJobResultJSON)
-	api.Get("/jobs/:id/heatmap.png", r.getJobHeatmap)
-	api.Get("/jobs/:id/dist_score.png", r.getJobScatter)
+	api.Get("/jobs/:id/result.json", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getJobResultJSON))
+	api.Get("/jobs/:id/heatmap.png", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getJobHeatmap))
+	api.Get("/jobs/:id/dist_score.png", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getJobScatter))
+	api.Get("/jobs/:id/logs", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getJobLogs))
+	// 成果物をまとめたzip（?include_pdb=true でPDBファイルも含める）
+	api.Get("/jobs/:id/download.zip", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.routeTimeout, r.downloadJobBundle))
+	// 実行中のログをSSEで配信（/logsと同じ制限、ジョブの終了まで接続が続くためタイムアウトを設定しない）
+	api.Get("/jobs/:id/logs/stream", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, r.streamJobLogs)
 	
 	// PDBファイル取得
-	api.Get("/jobs/:id/pdb/:pdbid", r.getPDBFile)
-	api.Get("/jobs/:id/pdb-list", r.getPDBList)
+	api.Get("/jobs/:id/pdb/:pdbid", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getPDBFile))
+	api.Get("/pdb/:pdbid", r.artifactRateLimit, r.egressGuard, withTimeout(r.longRouteTimeout, r.getPDB))
+	api.Get("/jobs/:id/pdb-list", r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getPDBList))
+
+	// リモートワーカー用の内部API
+	api.Post("/internal/jobs/claim", r.requireWorker, validateBody(claimJobSchema, false), withTimeout(r.routeTimeout, r.claimJob))
+	api.Post("/internal/jobs/:id/report", r.requireWorker, withTimeout(r.longRouteTimeout, r.reportJob))
+
+	// Webhook
+	api.Post("/webhooks", r.readOnlyGuard, r.requireSessions, r.requireWebhooks, validateBody(createWebhookSchema, false), r.createWebhook)
+	api.Get("/webhooks", r.requireSessions, r.requireWebhooks, r.listWebhooks)
+	api.Patch("/webhooks/:id", r.readOnlyGuard, r.requireSessions, r.requireWebhooks, validateBody(updateWebhookSchema, false), r.updateWebhook)
+	api.Delete("/webhooks/:id", r.readOnlyGuard, r.requireSessions, r.requireWebhooks, r.deleteWebhook)
+	api.Get("/webhooks/:id/deliveries", r.requireSessions, r.requireWebhooks, r.listWebhookDeliveries)
+	api.Post("/webhooks/:id/deliveries/:deliveryId/redeliver", r.readOnlyGuard, r.requireSessions, r.requireWebhooks, r.redeliverWebhook)
+
+	// アラート（解析のメトリクスのルール）
+	api.Post("/alerts", r.readOnlyGuard, r.requireSessions, r.requireAlerts, validateBody(createAlertSchema, false), r.createAlert)
+	api.Get("/alerts", r.requireSessions, r.requireAlerts, r.listAlerts)
+	api.Get("/alerts/:id", r.requireSessions, r.requireAlerts, r.getAlert)
+	api.Patch("/alerts/:id", r.readOnlyGuard, r.requireSessions, r.requireAlerts, validateBody(updateAlertSchema, false), r.updateAlert)
+	api.Delete("/alerts/:id", r.readOnlyGuard, r.requireSessions, r.requireAlerts, r.deleteAlert)
+	api.Get("/alerts/:id/history", r.requireSessions, r.requireAlerts, r.listAlertHistory)
+
+	// 保存した検索（セッションごと、DB設定時のみ）
+	api.Post("/searches", r.readOnlyGuard, r.requireSessions, r.requireDB, validateBody(createSavedSearchSchema, false), withTimeout(r.routeTimeout, r.createSavedSearch))
+	api.Get("/searches", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.listSavedSearches))
+	api.Get("/searches/:id", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.getSavedSearch))
+	api.Patch("/searches/:id", r.readOnlyGuard, r.requireSessions, r.requireDB, validateBody(updateSavedSearchSchema, false), withTimeout(r.routeTimeout, r.updateSavedSearch))
+	api.Delete("/searches/:id", r.readOnlyGuard, r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.deleteSavedSearch))
+	api.Get("/searches/:id/results", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.runSavedSearch))
+	// プロジェクト（複数の解析をまとめる、セッションごと、DB設定時のみ）
+	api.Post("/projects", r.readOnlyGuard, r.requireSessions, r.requireDB, validateBody(createProjectSchema, false), withTimeout(r.routeTimeout, r.createProject))
+	api.Get("/projects", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.listProjects))
+	api.Get("/projects/:id", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.getProject))
+	api.Patch("/projects/:id", r.readOnlyGuard, r.requireSessions, r.requireDB, validateBody(updateProjectSchema, false), withTimeout(r.routeTimeout, r.updateProject))
+	api.Delete("/projects/:id", r.readOnlyGuard, r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.deleteProject))
+	api.Get("/projects/:id/analyses", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.listProjectAnalyses))
+	api.Post("/projects/:id/analyses", r.readOnlyGuard, r.requireSessions, r.requireDB, validateBody(addProjectAnalysesSchema, false), withTimeout(r.routeTimeout, r.addProjectAnalyses))
+	api.Delete("/projects/:id/analyses/:analysisId", r.readOnlyGuard, r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.removeProjectAnalysis))
+	api.Get("/projects/:id/stats", r.requireSessions, r.requireDB, withTimeout(r.routeTimeout, r.getProjectStats))
 
 	// Analysis API (Phase 2)
 	// より具体的なルートを先に定義（パラメータ付きルートより前に）
-	api.Get("/analyses", r.listAnalyses)
-	api.Get("/analyses/compare", r.compareAnalyses)
+	api.Get("/analyses", r.requireAllSessionsAdmin, withTimeout(r.routeTimeout, r.listAnalyses))
+	api.Get("/analyses/export", r.requireAllSessionsAdmin, withTimeout(r.routeTimeout, r.exportAnalyses))
+	api.Get("/analyses/compare", withTimeout(r.routeTimeout, r.compareAnalyses))
+	// パラメータスイープ（POST /api/analyses/:id/rerunで配列を指定）の解析を並べて比較する
+	api.Get("/sweeps/:id", r.requireAllSessionsAdmin, withTimeout(r.routeTimeout, r.getSweep))
+	// GraphQL（一覧・指標・成果物・比較を必要なフィールドだけ1回で取得する）
+	api.Post("/graphql", r.requireAllSessionsAdmin, withTimeout(r.routeTimeout, r.graphQLHandler()))
+	api.Get("/analyses/diff", withTimeout(r.longRouteTimeout, r.getAnalysisDiff))
+	api.Get("/analyses/diff/heatmap.png", r.artifactRateLimit, r.egressGuard, withTimeout(r.longRouteTimeout, r.getHeatmapDiff))
+	
+	api.Delete("/analyses", r.readOnlyGuard, r.requireAnalyst, withTimeout(r.longRouteTimeout, r.deleteAnalyses))
+	// 一括削除・キャンセル（IDの一覧または絞り込み、失敗した解析は理由とともに返す）
+	api.Post("/analyses/bulk-delete", r.readOnlyGuard, r.requireAnalyst, r.requireAllSessionsAdmin, validateBody(bulkAnalysesSchema, false), withTimeout(r.longRouteTimeout, r.bulkDeleteAnalyses))
+	api.Post("/analyses/bulk-cancel", r.readOnlyGuard, r.requireAnalyst, r.requireAllSessionsAdmin, validateBody(bulkAnalysesSchema, false), withTimeout(r.longRouteTimeout, r.bulkCancelAnalyses))
 	
 	// メトリクス更新（別パスで競合を回避）
-	api.Post("/update-metrics", r.updateMetricsForAll)
+	api.Post("/update-metrics", r.requireAdmin, r.readOnlyGuard, withTimeout(r.longRouteTimeout, r.updateMetricsForAll))
+
+	// 管理用API（APIキーまたはadminのユーザーのログインが必要）
+	// DB・ローカル・R2の照合（?clean=true で孤立したものを掃除する）
+	api.Post("/admin/reconcile", r.requireAdmin, withTimeout(r.longRouteTimeout, r.reconcileStorage))
+	// 待ち行列の一時停止・再開（停止中も投入は受け付け、実行の開始だけを止める）
+	api.Post("/admin/queue/pause", r.requireAdmin, r.pauseQueue)
+	api.Post("/admin/queue/resume", r.requireAdmin, r.resumeQueue)
+	// 保持期間の確認・変更（再起動すると環境変数の設定に戻る）
+	api.Get("/admin/diagnostics", r.requireAdmin, withTimeout(r.longRouteTimeout, r.getDiagnostics))
+	api.Get("/admin/retention", r.requireAdmin, r.getRetention)
+	api.Patch("/admin/retention", r.requireAdmin, r.readOnlyGuard, validateBody(updateRetentionSchema, false), r.updateRetention)
+	// ユーザーのロールの確認・変更
+	api.Get("/admin/users", r.requireAdmin, r.requireSessions, r.requireAccounts, withTimeout(r.routeTimeout, r.listUsers))
+	api.Patch("/admin/users/:id", r.requireAdmin, r.readOnlyGuard, r.requireSessions, r.requireAccounts, validateBody(updateUserRoleSchema, false), withTimeout(r.routeTimeout, r.updateUserRole))
+	// 管理者による再計算（実行枠の予約ではadminとして扱う）
+	api.Post("/admin/analyses/:id/recompute", r.requireAdmin, r.readOnlyGuard, withTimeout(r.routeTimeout, r.recomputeAnalysis))
 	
 	// Analysis API (Phase 1)
 	// パラメータ付きルートは最後に定義
-	api.Get("/analyses/:id/result", r.getAnalysisResult)
-	api.Get("/analyses/:id/artifacts/:name", r.getAnalysisArtifact)
-	api.Post("/analyses/:id/rerun", r.rerunAnalysis)
-	api.Post("/analyses/:id/cancel", r.cancelAnalysis)
-	api.Get("/analyses/:id", r.getAnalysis)
-	api.Delete("/analyses/:id", r.deleteAnalysis)
+	api.Get("/analyses/:id/result", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getAnalysisResult))
+	api.Get("/analyses/:id/events", withTimeout(r.routeTimeout, r.getAnalysisEvents))
+	api.Get("/analyses/:id/structures", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getAnalysisStructures))
+	api.Get("/analyses/:id/report.xlsx", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getAnalysisReport))
+	api.Get("/analyses/:id/scores", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getAnalysisScores))
+	api.Get("/analyses/:id/report.pdf", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getAnalysisPDFReport))
+	api.Get("/analyses/:id/artifacts/:name", r.artifactRateLimit, r.egressGuard, r.artifactsGuard, withTimeout(r.longRouteTimeout, r.getAnalysisArtifact))
+	api.Post("/analyses/:id/artifacts/presign", r.requireWorker, validateBody(presignArtifactsSchema, false), withTimeout(r.routeTimeout, r.presignArtifactUploads))
+	api.Post("/analyses/:id/rerun", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, r.jobRateLimit, withTimeout(r.routeTimeout, r.rerunAnalysis))
+	api.Post("/analyses/:id/share", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, r.artifactsGuard, validateBody(createShareSchema, true), withTimeout(r.routeTimeout, r.createShareLink))
+	api.Get("/analyses/:id/shares", r.requireOwner, withTimeout(r.routeTimeout, r.listShareLinks))
+	api.Delete("/analyses/:id/share/:token", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.routeTimeout, r.revokeShareLink))
+	api.Post("/analyses/:id/cancel", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.routeTimeout, r.cancelAnalysis))
+	api.Post("/analyses/:id/pin", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.routeTimeout, r.pinAnalysis))
+	api.Delete("/analyses/:id/pin", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.routeTimeout, r.unpinAnalysis))
+	// タグ・メモ（DB設定時のみ、タグはGET /api/analyses?tags=...で絞り込める）
+	api.Get("/analyses/:id/tags", r.requireDB, withTimeout(r.routeTimeout, r.listAnalysisTags))
+	api.Post("/analyses/:id/tags", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, r.requireDB, validateBody(addTagsSchema, false), withTimeout(r.routeTimeout, r.addAnalysisTags))
+	api.Delete("/analyses/:id/tags/:tag", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, r.requireDB, withTimeout(r.routeTimeout, r.removeAnalysisTag))
+	api.Get("/analyses/:id/notes", r.requireDB, withTimeout(r.routeTimeout, r.listAnalysisNotes))
+	api.Post("/analyses/:id/notes", r.readOnlyGuard, r.requireAnalyst, r.requireDB, validateBody(createNoteSchema, false), withTimeout(r.routeTimeout, r.createAnalysisNote))
+	api.Delete("/analyses/:id/notes/:noteId", r.readOnlyGuard, r.requireAnalyst, r.requireDB, withTimeout(r.routeTimeout, r.deleteAnalysisNote))
+	api.Post("/analyses/:id/archive", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.routeTimeout, r.archiveAnalysis))
+	api.Post("/analyses/:id/restore", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.longRouteTimeout, r.restoreAnalysis))
+	api.Get("/analyses/:id", withTimeout(r.routeTimeout, r.getAnalysis))
+	api.Delete("/analyses/:id", r.readOnlyGuard, r.requireAnalyst, r.requireOwner, withTimeout(r.longRouteTimeout, r.deleteAnalysis))
 }
 
 func (r *Routes) createJob(c *fiber.Ctx) error {
@@ -87,47 +299,105 @@ func (r *Routes) createJob(c *fiber.Ctx) error {
 			"error": "uniprot_id is required",
 		})
 	}
+	req.UniProtID = jobs.NormalizeUniProtID(req.UniProtID)
+	if !jobs.ValidUniProtID(req.UniProtID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid UniProt ID format: " + req.UniProtID,
+		})
+	}
 
-	// デフォルトパラメータ
-	params := req.Params
-	if params == nil {
-		params = make(map[string]interface{})
+	// パラメータを検証し、未指定のパラメータにデフォルト値を設定する（未知のキー・範囲外の値は422）
+	params, err := jobs.ParseAnalysisParams(req.Params)
+	if err != nil {
+		return invalidParams(c, "params", err)
 	}
-	if _, ok := params["sequence_ratio"]; !ok {
-		params["sequence_ratio"] = 0.7
+	// アイソフォームの接尾辞（P69905-2）はparams.isoformとして扱い、uniprot_idはアクセッション番号のみにする
+	req.UniProtID, err = jobs.ResolveUniProtVariant(req.UniProtID, &params)
+	if err != nil {
+		return invalidParams(c, "params", err)
 	}
-	if _, ok := params["min_structures"]; !ok {
-		params["min_structures"] = 5
+
+	// Cookie同意をチェック（オプショナル - 厳密にチェックしない）
+	// パラメータにセッションIDを追加（セッションレスモードでは追加しない）
+	if sessionID := r.jobSessionID(c); sessionID != "" {
+		params.SessionID = sessionID
+	}
+	// adminは管理者の再計算（POST /api/admin/analyses/:id/recompute）のみ
+	switch req.Class {
+	case "", jobs.ClassInteractive:
+	case jobs.ClassBatch:
+		params.Class = req.Class
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("class must be %s or %s", jobs.ClassInteractive, jobs.ClassBatch),
+		})
 	}
-	// methodパラメータのデフォルト設定（後方互換性のためxray_onlyもサポート）
-	if _, ok := params["method"]; !ok {
-		if _, ok := params["xray_only"]; !ok {
-			params["method"] = "X-ray"
-		} else {
-			// xray_onlyが指定されている場合は変換
-			if xrayOnly, ok := params["xray_only"].(bool); ok {
-				if xrayOnly {
-					params["method"] = "X-ray"
-				} else {
-					params["method"] = "all"
-				}
-			}
+
+	var notifyEmail string
+	if req.NotifyEmail != "" {
+		if r.notifications == nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Email notifications are not configured",
+			})
+		}
+		if notifyEmail, err = normalizeEmail(req.NotifyEmail); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid notify_email",
+			})
 		}
 	}
-	// xray_onlyパラメータを削除（methodに統一）
-	delete(params, "xray_only")
-	if _, ok := params["negative_pdbid"]; !ok {
-		params["negative_pdbid"] = ""
+
+	if req.DryRun {
+		return r.dryRunJob(c, req, params)
 	}
-	if _, ok := params["cis_threshold"]; !ok {
-		params["cis_threshold"] = 3.3
+
+	job, deduplicated, err := r.jobManager.CreateJobWithOptions(req.UniProtID, params, jobs.CreateJobOptions{
+		Dedupe:    req.Dedupe,
+		NoCache:   req.NoCache,
+		RequestID: RequestID(c),
+	})
+	if err != nil {
+		return jobCreateError(c, err)
 	}
-	if _, ok := params["proc_cis"]; !ok {
-		params["proc_cis"] = true
+	if !deduplicated {
+		r.linkAnalysisUser(c, job.ID)
+	}
+	if notifyEmail != "" && (job.Status == jobs.StatusQueued || job.Status == jobs.StatusRunning) {
+		r.notifications.Register(job.ID, job.UniProtID, notifyEmail)
 	}
 
-	// Cookie同意をチェック（オプショナル - 厳密にチェックしない）
-	// CookieからセッションIDを取得、なければ生成
+	return c.JSON(fiber.Map{
+		"job_id":       job.ID,
+		"status":       job.Status,
+		"deduplicated": deduplicated,
+		"cached":       job.Cached,
+	})
+}
+
+// jobCreateError ジョブ作成のエラーをレスポンスに変換する（上限超過は429）
+func jobCreateError(c *fiber.Ctx, err error) error {
+	var quotaErr *jobs.QuotaError
+	if errors.As(err, &quotaErr) {
+		c.Set("Retry-After", strconv.Itoa(int(quotaErr.RetryAfter.Seconds())))
+		return c.Status(429).JSON(fiber.Map{
+			"error": quotaErr.Error(),
+			"scope": quotaErr.Scope,
+			"limit": quotaErr.Limit,
+		})
+	}
+	var envErr *jobs.EnvOverrideError
+	if errors.As(err, &envErr) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": envErr.Error(),
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// ensureSessionID CookieからセッションIDを取得、なければ生成してCookieに設定する
+func ensureSessionID(c *fiber.Ctx) string {
 	sessionID := c.Cookies("dsa_session_id")
 	if sessionID == "" {
 		sessionID = uuid.New().String()
@@ -142,26 +412,12 @@ func (r *Routes) createJob(c *fiber.Ctx) error {
 			Path:     "/",
 		})
 	}
-
-	// パラメータにセッションIDを追加
-	params["session_id"] = sessionID
-
-	job, err := r.jobManager.CreateJob(req.UniProtID, params)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": err.Error(),
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"job_id": job.ID,
-		"status": job.Status,
-	})
+	return sessionID
 }
 
 func (r *Routes) getJob(c *fiber.Ctx) error {
 	jobID := c.Params("id")
-	job, err := r.jobManager.GetJob(jobID)
+	job, err := r.jobManager.GetJobStatus(jobID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Job not found",
@@ -173,146 +429,86 @@ func (r *Routes) getJob(c *fiber.Ctx) error {
 
 // 古いJob API用のハンドラー（DBとR2から取得、ローカルファイルへのフォールバック付き）
 func (r *Routes) getJobResultJSON(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
-	// DBからレコードを取得
-	if r.db == nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Database not configured",
-		})
-	}
-	
-	record, err := r.db.GetAnalysis(id)
-	if err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Analysis not found in database",
-		})
-	}
-	
-	// R2から取得を試みる
-	if r.r2 != nil {
-		var resultKey string
-		if record.ResultKey != nil {
-			resultKey = *record.ResultKey
-		} else {
-			// R2キーが保存されていない場合、プレフィックスから推測
-			resultKey = fmt.Sprintf("analysis/%s/result.json", id)
-		}
-		
-		data, err := r.r2.GetObject(r.ctx, resultKey)
-		if err == nil {
-			c.Set("Content-Type", "application/json")
-			return c.Send(data)
-		}
-		fmt.Printf("[WARN] Failed to get result from R2 for %s (key: %s): %v\n", id, resultKey, err)
-	}
-	
-	// R2から取得できない場合、ローカルファイルから取得を試みる（フォールバック）
-	jobDir := filepath.Join(r.storageDir, id)
-	resultPath := filepath.Join(jobDir, "result.json")
-	if data, err := os.ReadFile(resultPath); err == nil {
-		c.Set("Content-Type", "application/json")
-		return c.Send(data)
-	}
-	
-	return c.Status(404).JSON(fiber.Map{
-		"error": "Result file not found in R2 or local storage",
-	})
+	return r.sendJobArtifact(c, "result.json")
 }
 
 func (r *Routes) getJobHeatmap(c *fiber.Ctx) error {
+	return r.sendJobArtifact(c, "heatmap.png")
+}
+
+func (r *Routes) getJobScatter(c *fiber.Ctx) error {
+	return r.sendJobArtifact(c, "dist_score.png")
+}
+
+// sendJobArtifact 登録されている成果物をR2から返し、取得できなければローカルファイルから返す
+func (r *Routes) sendJobArtifact(c *fiber.Ctx, name string) error {
 	id := c.Params("id")
-	
-	// DBからレコードを取得
-	if r.db == nil {
+	artifact, ok := jobs.LookupArtifact(name)
+	if !ok {
 		return c.Status(404).JSON(fiber.Map{
-			"error": "Database not configured",
+			"error": fmt.Sprintf("Unknown artifact: %s", name),
 		})
 	}
-	
-	record, err := r.db.GetAnalysis(id)
+	size, err := imageSize(c, artifact.ContentType)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Analysis not found in database",
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
-	
-	// R2から取得を試みる
-	if r.r2 != nil {
-		var heatmapKey string
-		if record.HeatmapKey != nil {
-			heatmapKey = *record.HeatmapKey
-		} else {
-			// R2キーが保存されていない場合、プレフィックスから推測
-			heatmapKey = fmt.Sprintf("analysis/%s/heatmap.png", id)
-		}
-		
-		data, err := r.r2.GetObject(r.ctx, heatmapKey)
-		if err == nil {
-			c.Set("Content-Type", "image/png")
-			return c.Send(data)
-		}
-		fmt.Printf("[WARN] Failed to get heatmap from R2 for %s (key: %s): %v\n", id, heatmapKey, err)
-	}
-	
-	// R2から取得できない場合、ローカルファイルから取得を試みる（フォールバック）
-	jobDir := filepath.Join(r.storageDir, id)
-	heatmapPath := filepath.Join(jobDir, "heatmap.png")
-	if data, err := os.ReadFile(heatmapPath); err == nil {
-		c.Set("Content-Type", "image/png")
-		return c.Send(data)
-	}
-	
-	return c.Status(404).JSON(fiber.Map{
-		"error": "Heatmap not found in R2 or local storage",
-	})
-}
 
-func (r *Routes) getJobScatter(c *fiber.Ctx) error {
-	id := c.Params("id")
-	
 	// DBからレコードを取得
 	if r.db == nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Database not configured",
 		})
 	}
-	
-	record, err := r.db.GetAnalysis(id)
+
+	record, err := r.getAnalysisRecord(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Analysis not found in database",
 		})
 	}
-	
-	// R2から取得を試みる
+
+	// 縮小した画像はキャッシュがあれば元の画像を取得しない
+	if data, ok := r.cachedImageVariant(id, name, size); ok {
+		return sendConditional(c, data, time.Time{}, artifact.ContentType)
+	}
+
+	// R2から取得を試みる（R2キーが保存されていない場合はプレフィックスから推測）
 	if r.r2 != nil {
-		var scatterKey string
-		if record.ScatterKey != nil {
-			scatterKey = *record.ScatterKey
+		key := artifact.ResolveKey(id, record)
+		checksum := r.artifactChecksum(c, id, artifact.Name)
+		// 縮小しない場合はメモリに読み込まずに返す
+		if _, resize := imageSizeWidths[size]; !resize {
+			if ok, err := r.sendR2Artifact(c, key, artifact.ContentType, checksum); ok {
+				return err
+			}
+		} else if data, err := r.r2.GetObject(c.UserContext(), key); err == nil {
+			if mismatch, err := verifyArtifactData(c, checksum, data); mismatch {
+				return err
+			}
+			return r.sendArtifactData(c, id, name, size, artifact.ContentType, data)
 		} else {
-			// R2キーが保存されていない場合、プレフィックスから推測
-			scatterKey = fmt.Sprintf("analysis/%s/dist_score.png", id)
-		}
-		
-		data, err := r.r2.GetObject(r.ctx, scatterKey)
-		if err == nil {
-			c.Set("Content-Type", "image/png")
-			return c.Send(data)
+			requestLog(c).Warnf("Failed to get %s from R2 for %s (key: %s): %v", name, id, key, err)
 		}
-		fmt.Printf("[WARN] Failed to get scatter plot from R2 for %s (key: %s): %v\n", id, scatterKey, err)
 	}
-	
+
 	// R2から取得できない場合、ローカルファイルから取得を試みる（フォールバック）
-	jobDir := filepath.Join(r.storageDir, id)
-	scatterPath := filepath.Join(jobDir, "dist_score.png")
-	if data, err := os.ReadFile(scatterPath); err == nil {
-		c.Set("Content-Type", "image/png")
-		return c.Send(data)
+	path := filepath.Join(r.jobManager.LocalJobDir(id), artifact.FileName())
+	if data, err := os.ReadFile(path); err == nil {
+		if _, resize := imageSizeWidths[size]; !resize {
+			var modTime time.Time
+			if info, err := os.Stat(path); err == nil {
+				modTime = info.ModTime()
+			}
+			return sendConditional(c, data, modTime, artifact.ContentType)
+		}
+		return r.sendArtifactData(c, id, name, size, artifact.ContentType, data)
 	}
-	
+
 	return c.Status(404).JSON(fiber.Map{
-		"error": "Scatter plot not found in R2 or local storage",
+		"error": fmt.Sprintf("%s not found in R2 or local storage", artifact.Label),
 	})
 }
 
@@ -335,10 +531,25 @@ func (r *Routes) getPDBFile(c *fiber.Ctx) error {
 	}
 
 	// PDBファイルのパスを取得 (work/pdb_files/{pdbid}.cif)
-	storageDir := r.jobManager.GetStorageDir()
-	pdbPath := filepath.Join(storageDir, jobID, "work", "pdb_files", fmt.Sprintf("%s.cif", pdbID))
+	pdbPath := filepath.Join(r.jobManager.LocalJobDir(jobID), "work", "pdb_files", fmt.Sprintf("%s.cif", pdbID))
 
 	if _, err := os.Stat(pdbPath); os.IsNotExist(err) {
+		// 作業ディレクトリが残っていない場合はR2に保存されたファイル（PDB_UPLOAD）の署名URLにリダイレクトする
+		if key, err := r.jobManager.PDBFileKey(c.UserContext(), jobID, pdbID); err != nil {
+			requestLog(c).Warnf("Failed to look up PDB file %s of %s in R2: %v", pdbID, jobID, err)
+		} else if key != "" {
+			if url, err := r.r2.GetSignedURL(c.UserContext(), key, 10*time.Minute); err == nil {
+				return c.Redirect(url, 302)
+			}
+			c.Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.cif\"", pdbID))
+			if ok, err := r.sendR2Artifact(c, key, "chemical/x-cif", nil); ok {
+				return err
+			}
+		}
+		// それもなければ共有キャッシュから返す
+		if r.pdbCache != nil {
+			return r.sendCachedPDB(c, pdbID)
+		}
 		return c.Status(404).JSON(fiber.Map{
 			"error": "PDB file not found",
 		})
@@ -369,13 +580,13 @@ func (r *Routes) getPDBList(c *fiber.Ctx) error {
 	// result.jsonからPDB IDリストを取得（R2から取得）
 	var resultData []byte
 	if r.db != nil && r.r2 != nil {
-		record, err := r.db.GetAnalysis(jobID)
+		record, err := r.getAnalysisRecord(c.UserContext(), jobID)
 		if err != nil || record.ResultKey == nil {
 			return c.Status(404).JSON(fiber.Map{
 				"error": "Analysis not found",
 			})
 		}
-		resultData, err = r.r2.GetObject(r.ctx, *record.ResultKey)
+		resultData, err = r.r2.GetObject(c.UserContext(), *record.ResultKey)
 		if err != nil {
 			return c.Status(404).JSON(fiber.Map{
 				"error": "Result file not found in R2",
@@ -429,27 +640,58 @@ func (r *Routes) getPDBList(c *fiber.Ctx) error {
 func (r *Routes) getAnalysis(c *fiber.Ctx) error {
 	id := c.Params("id")
 
+	response, err := r.analysisResponse(c.UserContext(), id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Analysis not found",
+		})
+	}
+	return c.JSON(response)
+}
+
+// analysisResponse GET /api/analyses/:idのレスポンス（DBにない場合はJob APIから取得）
+func (r *Routes) analysisResponse(ctx context.Context, id string) (fiber.Map, error) {
 	// まずDBから取得を試みる
 	if r.db != nil {
-		record, err := r.db.GetAnalysis(id)
+		record, err := r.getAnalysisRecord(ctx, id)
 		if err == nil {
 			// DBから取得できた場合
-			response := r.analysisRecordToResponse(record)
-			return c.JSON(response)
+			response := r.analysisRecordToResponse(ctx, record)
+			response["pinned"] = r.jobManager.PinnedAnalyses(ctx, []string{id})[id]
+			if eta := r.jobManager.ETASeconds(id); eta != nil {
+				response["eta_seconds"] = *eta
+			}
+			if archive := r.jobManager.AnalysisArchive(ctx, id); archive != nil {
+				response["archive"] = archiveResponse(archive)
+			}
+			addProteinInfo(response, r.jobManager.ProteinInfos(ctx, []string{id})[id])
+			if args := r.jobManager.AnalysisCLIArgs(ctx, id); args != nil {
+				response["cli_args"] = args
+			}
+			if tags := r.analysisTags(ctx, []string{id})[id]; len(tags) > 0 {
+				response["tags"] = tags
+			}
+			return response, nil
 		}
 	}
 
 	// DBにない場合は既存のJob APIから取得
 	job, err := r.jobManager.GetJob(id)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Analysis not found",
-		})
+		return nil, err
 	}
 
 	// JobをAnalysis形式に変換
 	response := r.jobToAnalysisResponse(job)
-	return c.JSON(response)
+	response["pinned"] = r.jobManager.PinnedAnalyses(ctx, []string{id})[id]
+	if eta := r.jobManager.ETASeconds(id); eta != nil {
+		response["eta_seconds"] = *eta
+	}
+	addProteinInfo(response, r.jobManager.ProteinInfos(ctx, []string{id})[id])
+	if args := r.jobManager.AnalysisCLIArgs(ctx, id); args != nil {
+		response["cli_args"] = args
+	}
+	return response, nil
 }
 
 func (r *Routes) getAnalysisResult(c *fiber.Ctx) error {
@@ -465,29 +707,20 @@ func (r *Routes) getAnalysisResult(c *fiber.Ctx) error {
 		})
 	}
 
-	record, err := r.db.GetAnalysis(id)
+	record, err := r.getAnalysisRecord(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Analysis not found in database",
 		})
 	}
 
-	// R2から取得を試みる
+	// R2から取得を試みる（R2キーが保存されていない場合はプレフィックスから推測）
 	if r.r2 != nil {
-		var resultKey string
-		if record.ResultKey != nil {
-			resultKey = *record.ResultKey
-		} else {
-			// R2キーが保存されていない場合、プレフィックスから推測
-			resultKey = fmt.Sprintf("analysis/%s/result.json", id)
-		}
-		
-		data, err := r.r2.GetObject(r.ctx, resultKey)
-		if err == nil {
-			c.Set("Content-Type", "application/json")
-			return c.Send(data)
+		artifact, _ := jobs.LookupArtifact("result.json")
+		resultKey := artifact.ResolveKey(id, record)
+		if ok, err := r.sendR2Artifact(c, resultKey, artifact.ContentType, r.artifactChecksum(c, id, artifact.Name)); ok {
+			return err
 		}
-		fmt.Printf("[WARN] Failed to get result from R2 for %s (key: %s): %v\n", id, resultKey, err)
 	}
 
 	// R2から取得できない場合はエラー
@@ -497,9 +730,11 @@ func (r *Routes) getAnalysisResult(c *fiber.Ctx) error {
 }
 
 func (r *Routes) getAnalysisArtifact(c *fiber.Ctx) error {
-	id := c.Params("id")
-	name := c.Params("name")
+	return r.sendAnalysisArtifact(c, c.Params("id"), c.Params("name"))
+}
 
+// sendAnalysisArtifact 解析の成果物nameを返す（getAnalysisArtifact・共有リンクから使う）
+func (r *Routes) sendAnalysisArtifact(c *fiber.Ctx, id, name string) error {
 	// DBからレコードを取得
 	if r.db == nil {
 		return c.Status(404).JSON(fiber.Map{
@@ -507,49 +742,47 @@ func (r *Routes) getAnalysisArtifact(c *fiber.Ctx) error {
 		})
 	}
 
-	record, err := r.db.GetAnalysis(id)
+	record, err := r.getAnalysisRecord(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Analysis not found in database",
 		})
 	}
 
-	// アーティファクトのキーとContent-Typeを決定
-	var key *string
-	var contentType string
-
-	switch name {
-	case "heatmap.png":
-		key = record.HeatmapKey
-		contentType = "image/png"
-	case "dist_score.png":
-		key = record.ScatterKey
-		contentType = "image/png"
-	case "logs.txt":
-		key = record.LogsKey
-		contentType = "text/plain"
-	default:
+	// 登録されている成果物のみ返す
+	artifact, ok := jobs.LookupArtifact(name)
+	if !ok {
 		return c.Status(404).JSON(fiber.Map{
 			"error": fmt.Sprintf("Unknown artifact: %s", name),
 		})
 	}
+	size, err := imageSize(c, artifact.ContentType)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if data, ok := r.cachedImageVariant(id, name, size); ok {
+		return sendConditional(c, data, time.Time{}, artifact.ContentType)
+	}
 
-	// R2から取得を試みる
+	// R2から取得を試みる（R2キーが保存されていない場合はプレフィックスから推測）
 	if r.r2 != nil {
-		var artifactKey string
-		if key != nil {
-			artifactKey = *key
+		artifactKey := artifact.ResolveKey(id, record)
+		checksum := r.artifactChecksum(c, id, artifact.Name)
+		// 縮小しない場合はメモリに読み込まずに返す
+		if _, resize := imageSizeWidths[size]; !resize {
+			if ok, err := r.sendR2Artifact(c, artifactKey, artifact.ContentType, checksum); ok {
+				return err
+			}
+		} else if data, err := r.r2.GetObject(c.UserContext(), artifactKey); err == nil {
+			if mismatch, err := verifyArtifactData(c, checksum, data); mismatch {
+				return err
+			}
+			return r.sendArtifactData(c, id, name, size, artifact.ContentType, data)
 		} else {
-			// R2キーが保存されていない場合、プレフィックスから推測
-			artifactKey = fmt.Sprintf("analysis/%s/%s", id, name)
-		}
-		
-		data, err := r.r2.GetObject(r.ctx, artifactKey)
-		if err == nil {
-			c.Set("Content-Type", contentType)
-			return c.Send(data)
+			requestLog(c).Warnf("Failed to get artifact %s from R2 for %s (key: %s): %v", name, id, artifactKey, err)
 		}
-		fmt.Printf("[WARN] Failed to get artifact %s from R2 for %s (key: %s): %v\n", name, id, artifactKey, err)
 	}
 
 	// R2から取得できない場合はエラー
@@ -558,17 +791,18 @@ func (r *Routes) getAnalysisArtifact(c *fiber.Ctx) error {
 	})
 }
 
-func (r *Routes) analysisRecordToResponse(record *storage.AnalysisRecord) fiber.Map {
+func (r *Routes) analysisRecordToResponse(ctx context.Context, record *storage.AnalysisRecord) fiber.Map {
 	summary := fiber.Map{
 		"id":         record.ID,
 		"uniprot_id": record.UniProtID,
 		"method":     record.Method,
 		"status":     record.Status,
-		"created_at": record.CreatedAt.Format(time.RFC3339),
+		"created_at": formatTime(record.CreatedAt),
 	}
 	if record.Progress != nil {
 		summary["progress"] = *record.Progress
 	}
+	addVariant(summary, record.UniProtID, record.Params)
 	response := fiber.Map{
 		"summary": summary,
 		"params":  record.Params,
@@ -579,50 +813,56 @@ func (r *Routes) analysisRecordToResponse(record *storage.AnalysisRecord) fiber.
 		response["summary"].(fiber.Map)["metrics"] = record.Metrics
 	}
 
+	// DBにR2キーが保存されている成果物のURL（保持期間を過ぎて削除された場合は載せない）
 	artifacts := fiber.Map{}
-	if record.ResultKey != nil {
-		if r.r2 != nil {
-			// 署名URLを生成（10分有効）
-			if url, err := r.r2.GetSignedURL(r.ctx, *record.ResultKey, 10*time.Minute); err == nil {
-				artifacts["result_url"] = url
-			} else if publicURL := r.r2.GetPublicURL(*record.ResultKey); publicURL != "" {
-				artifacts["result_url"] = publicURL
+	expiredAt := r.artifactsExpiredAt(ctx, record.ID)
+	if expiredAt != nil {
+		response["artifacts_expired"] = true
+		response["artifacts_expired_at"] = formatTime(*expiredAt)
+	} else {
+		for _, a := range jobs.Artifacts() {
+			if a.URLField == "" || a.RecordKey == nil {
+				continue
 			}
-		} else {
-			artifacts["result_url"] = fmt.Sprintf("/api/analyses/%s/result", record.ID)
-		}
-	}
-	if record.HeatmapKey != nil {
-		if r.r2 != nil {
-			if url, err := r.r2.GetSignedURL(r.ctx, *record.HeatmapKey, 10*time.Minute); err == nil {
-				artifacts["heatmap_url"] = url
-			} else if publicURL := r.r2.GetPublicURL(*record.HeatmapKey); publicURL != "" {
-				artifacts["heatmap_url"] = publicURL
+			key := a.RecordKey(record)
+			if key == nil {
+				continue
 			}
-		} else {
-			artifacts["heatmap_url"] = fmt.Sprintf("/api/analyses/%s/artifacts/heatmap.png", record.ID)
-		}
-	}
-	if record.ScatterKey != nil {
-		if r.r2 != nil {
-			if url, err := r.r2.GetSignedURL(r.ctx, *record.ScatterKey, 10*time.Minute); err == nil {
-				artifacts["scatter_url"] = url
-			} else if publicURL := r.r2.GetPublicURL(*record.ScatterKey); publicURL != "" {
-				artifacts["scatter_url"] = publicURL
+			apiURL := fmt.Sprintf("/api/analyses/%s/artifacts/%s", record.ID, a.Name)
+			if a.Name == "result.json" {
+				apiURL = fmt.Sprintf("/api/analyses/%s/result", record.ID)
+			}
+			if r.r2 != nil {
+				// 署名URLを生成（10分有効）
+				url, err := r.r2.GetSignedURL(ctx, *key, 10*time.Minute)
+				if err == nil {
+					artifacts[a.URLField] = url
+				} else if publicURL := r.r2.GetPublicURL(*key); publicURL != "" {
+					artifacts[a.URLField] = publicURL
+				} else if r.r2.Degraded() || errors.Is(err, storage.ErrNotSupported) {
+					// R2停止中（縮退運転）・署名URLを発行できない保存先（STORAGE_BACKEND=local）はAPI経由で配信
+					artifacts[a.URLField] = apiURL
+				}
+			} else {
+				artifacts[a.URLField] = apiURL
 			}
-		} else {
-			artifacts["scatter_url"] = fmt.Sprintf("/api/analyses/%s/artifacts/dist_score.png", record.ID)
 		}
 	}
 	if len(artifacts) > 0 {
 		response["artifacts"] = artifacts
+		// アップロード時のSHA-256（クライアントがダウンロードした成果物を検証できるように）
+		if checksums, err := r.jobManager.ArtifactChecksums(ctx, record.ID); err != nil {
+			logging.Warnf("Failed to load artifact checksums of %s: %v", record.ID, err)
+		} else if len(checksums) > 0 {
+			response["checksums"] = checksums
+		}
 	}
 
 	if record.StartedAt != nil {
-		response["started_at"] = record.StartedAt.Format(time.RFC3339)
+		response["started_at"] = formatTime(*record.StartedAt)
 	}
 	if record.FinishedAt != nil {
-		response["finished_at"] = record.FinishedAt.Format(time.RFC3339)
+		response["finished_at"] = formatTime(*record.FinishedAt)
 	}
 	if record.ErrorMessage != nil {
 		response["error_message"] = *record.ErrorMessage
@@ -651,10 +891,11 @@ func (r *Routes) jobToAnalysisResponse(job *jobs.Job) fiber.Map {
 			"uniprot_id": job.UniProtID,
 			"method":     method,
 			"status":     string(job.Status),
-			"created_at": job.CreatedAt.Format(time.RFC3339),
+			"created_at": formatTime(job.CreatedAt),
 		},
 		"params": job.Params,
 	}
+	addVariant(response["summary"].(fiber.Map), job.UniProtID, job.Params)
 
 	if job.Result != nil {
 		artifacts := fiber.Map{
@@ -668,6 +909,10 @@ func (r *Routes) jobToAnalysisResponse(job *jobs.Job) fiber.Map {
 	if job.ErrorMessage != "" {
 		response["error_message"] = job.ErrorMessage
 	}
+	// 終了したジョブは最後の更新時刻が終了時刻
+	if job.Status == jobs.StatusDone || job.Status == jobs.StatusFailed || job.Status == jobs.StatusCancelled {
+		response["finished_at"] = formatTime(job.UpdatedAt)
+	}
 
 	return response
 }
@@ -678,49 +923,64 @@ func (r *Routes) listAnalyses(c *fiber.Ctx) error {
 		return c.JSON([]fiber.Map{})
 	}
 
-	filters := make(map[string]interface{})
-
-	// CookieからセッションIDを取得してフィルタに追加
-	sessionID := c.Cookies("dsa_session_id")
-	if sessionID != "" {
-		filters["session_id"] = sessionID
+	filters, err := r.analysisFilters(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
+	return r.respondAnalyses(c, filters)
+}
 
-	if uniprotID := c.Query("uniprot_id"); uniprotID != "" {
-		filters["uniprot_id"] = uniprotID
-	}
-	if method := c.Query("method"); method != "" {
-		filters["method"] = method
-	}
-	if status := c.Query("status"); status != "" {
-		filters["status"] = status
-	}
-	if from := c.Query("from"); from != "" {
-		filters["from"] = from
-	}
-	if to := c.Query("to"); to != "" {
-		filters["to"] = to
+// respondAnalyses フィルターに一致する解析の一覧を返す（group_by・page_size・cursorはリクエストのクエリで指定する）
+func (r *Routes) respondAnalyses(c *fiber.Ctx, filters map[string]interface{}) error {
+	// UniProt IDごとにまとめる
+	switch groupBy := c.Query("group_by"); groupBy {
+	case "":
+	case "uniprot_id":
+		return r.listAnalysisGroups(c, filters)
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Unsupported group_by: %s (supported: uniprot_id)", groupBy),
+		})
 	}
-	if limitStr := c.Query("limit"); limitStr != "" {
-		var limit int
-		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err == nil && limit > 0 {
-			filters["limit"] = limit
-		}
+
+	// page_size・cursorを指定した場合はカーソルでページングする（next_cursor・has_moreを含むオブジェクトで返す）
+	if c.Query("page_size") != "" || c.Query("cursor") != "" {
+		return r.listAnalysesPage(c, filters)
 	}
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		var offset int
-		if _, err := fmt.Sscanf(offsetStr, "%d", &offset); err == nil && offset >= 0 {
-			filters["offset"] = offset
-		}
+
+	// 並び順を指定しない場合は固定した解析を先に返す（それぞれ作成日時の新しい順）
+	if _, ok := filters[storage.FilterSort]; !ok {
+		filters[storage.FilterSort] = []storage.SortKey{{Field: "pinned", Desc: true}}
 	}
 
-	records, err := r.db.ListAnalyses(filters)
+	records, err := r.listAnalysisRecords(c.UserContext(), filters)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	return c.JSON(r.analysisSummaries(c, records))
+}
+
+// analysisSummaries 一覧の解析のサマリー（成果物の削除・固定・タンパク質の情報を含む）
+func (r *Routes) analysisSummaries(c *fiber.Ctx, records []*storage.AnalysisRecord) []fiber.Map {
+	// 保持期間を過ぎて成果物が削除された解析（サマリーとメトリクスは残る）
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	expired, err := r.db.ArtifactsExpiredAt(c.UserContext(), ids)
+	if err != nil {
+		requestLog(c).Warnf("Failed to check artifact expiry: %v", err)
+	}
+
+	pinned := r.jobManager.PinnedAnalyses(c.UserContext(), ids)
+	proteins := r.jobManager.ProteinInfos(c.UserContext(), ids)
+	tags := r.analysisTags(c.UserContext(), ids)
+
 	summaries := make([]fiber.Map, 0, len(records))
 	for _, record := range records {
 		summary := fiber.Map{
@@ -728,24 +988,99 @@ func (r *Routes) listAnalyses(c *fiber.Ctx) error {
 			"uniprot_id": record.UniProtID,
 			"method":     record.Method,
 			"status":     record.Status,
-			"created_at": record.CreatedAt.Format(time.RFC3339),
+			"created_at": formatTime(record.CreatedAt),
 		}
 		if record.Progress != nil {
 			summary["progress"] = *record.Progress
 		}
+		addVariant(summary, record.UniProtID, record.Params)
 		if record.ErrorMessage != nil {
 			summary["error_message"] = *record.ErrorMessage
 		}
 		if record.Metrics != nil {
 			summary["metrics"] = record.Metrics
 		}
+		if _, ok := expired[record.ID]; ok {
+			summary["artifacts_expired"] = true
+		}
+		if pinned[record.ID] {
+			summary["pinned"] = true
+		}
+		if t := tags[record.ID]; len(t) > 0 {
+			summary["tags"] = t
+		}
+		addProteinInfo(summary, proteins[record.ID])
 		summaries = append(summaries, summary)
 	}
+	return summaries
+}
+
+// analysisFilters 解析の一覧の絞り込み（セッション・uniprot_id・method・status・from・to・limit・offset・q・指標の範囲・sort）をクエリから読み取る
+func (r *Routes) analysisFilters(c *fiber.Ctx) (map[string]interface{}, error) {
+	filters := make(map[string]interface{})
+
+	// CookieからセッションIDを取得してフィルタに追加（セッションレスモード・管理者のall_sessionsでは絞り込まない）
+	sessionID := r.listSessionID(c)
+	if sessionID != "" {
+		filters["session_id"] = sessionID
+	}
 
-	return c.JSON(summaries)
+	if uniprotID := c.Query("uniprot_id"); uniprotID != "" {
+		filters["uniprot_id"] = uniprotID
+	}
+	if method := c.Query("method"); method != "" {
+		filters["method"] = method
+	}
+	if status := c.Query("status"); status != "" {
+		filters["status"] = status
+	}
+	// 日時はRFC3339（オフセット付き）または日付（tzの日付として解釈）で指定し、UTCで絞り込む
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"from", "to"} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeFilter(value, loc, name == "to")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %v", name, err)
+		}
+		filters[name] = t.Format(time.RFC3339Nano)
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var limit int
+		if _, err := fmt.Sscanf(limitStr, "%d", &limit); err == nil && limit > 0 {
+			filters["limit"] = limit
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		var offset int
+		if _, err := fmt.Sscanf(offsetStr, "%d", &offset); err == nil && offset >= 0 {
+			filters["offset"] = offset
+		}
+	}
+	// 部分一致検索・指標の範囲・並び順（SQLで絞り込む）
+	if err := addSearchFilters(c, filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
 }
 
 func (r *Routes) rerunAnalysis(c *fiber.Ctx) error {
+	return r.rerun(c, "")
+}
+
+// recomputeAnalysis POST /api/admin/analyses/:id/recompute 管理者による再計算（adminの種類で再実行する）
+// JOB_CLASS_RESERVATIONSでinteractiveに予約した実行枠は使わないため、まとめて再計算しても通常の投入を待たせない
+func (r *Routes) recomputeAnalysis(c *fiber.Ctx) error {
+	return r.rerun(c, jobs.ClassAdmin)
+}
+
+// rerun 解析を元のパラメータ（ボディのオーバーライドを適用）で再実行する（classが空の場合はinteractive、配列を指定した場合はパラメータスイープ）
+func (r *Routes) rerun(c *fiber.Ctx, class string) error {
 	id := c.Params("id")
 
 	// 元の分析を取得
@@ -753,7 +1088,7 @@ func (r *Routes) rerunAnalysis(c *fiber.Ctx) error {
 	var uniprotID string
 
 	if r.db != nil {
-		record, err := r.db.GetAnalysis(id)
+		record, err := r.getAnalysisRecord(c.UserContext(), id)
 		if err == nil {
 			originalParams = record.Params
 			uniprotID = record.UniProtID
@@ -772,28 +1107,43 @@ func (r *Routes) rerunAnalysis(c *fiber.Ctx) error {
 		uniprotID = job.UniProtID
 	}
 
-	// オーバーライドを取得
+	// 元のパラメータにオーバーライドを適用する（空ボディは元のパラメータのまま、未知のキー・範囲外の値は422）
+	params := jobs.AnalysisParamsFromMap(originalParams)
+	// 元の解析がキャッシュから作成されていた場合の記録・ジョブの種類は引き継がない
+	params.CachedFrom = ""
+	params.Class = class
 	var overrides map[string]interface{}
-	if err := c.BodyParser(&overrides); err != nil {
-		overrides = make(map[string]interface{})
+	if body := bytes.TrimSpace(c.Body()); len(body) > 0 {
+		if err := json.Unmarshal(body, &overrides); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Request body must be a JSON object",
+			})
+		}
 	}
-
-	// パラメータをマージ（オーバーライド優先）
-	params := make(map[string]interface{})
-	for k, v := range originalParams {
-		params[k] = v
+	// 配列で指定したパラメータは値の組み合わせごとに再実行する（パラメータスイープ、GET /api/sweeps/:idで並べて比較する）
+	keys, combinations, err := sweepCombinations(overrides)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if combinations != nil {
+		return r.rerunSweep(c, id, uniprotID, params, keys, combinations)
 	}
-	for k, v := range overrides {
-		params[k] = v
+	if err := params.Apply(overrides); err != nil {
+		return invalidParams(c, "", err)
+	}
+	// 元の解析のチェックポイント（ダウンロード済みのPDBファイル等）から再開する
+	if _, ok := overrides["resume_from"]; !ok {
+		params.ResumeFrom = id
 	}
 
-	// 新しいジョブを作成
-	job, err := r.jobManager.CreateJob(uniprotID, params)
+	// 新しいジョブを作成（再実行は結果キャッシュを使わずに必ず解析する）
+	job, _, err := r.jobManager.CreateJobWithOptions(uniprotID, params, jobs.CreateJobOptions{NoCache: true, RerunOf: id, RequestID: RequestID(c)})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return jobCreateError(c, err)
 	}
+	r.linkAnalysisUser(c, job.ID)
 
 	return c.JSON(fiber.Map{
 		"analysis_id": job.ID,
@@ -832,8 +1182,12 @@ func (r *Routes) compareAnalyses(c *fiber.Ctx) error {
 	// 各分析を取得
 	summaries := make([]fiber.Map, 0, len(ids))
 	for _, id := range ids {
-		record, err := r.db.GetAnalysis(id)
+		record, err := r.getAnalysisRecord(c.UserContext(), id)
 		if err != nil {
+			// 期限切れの場合は打ち切る（504を返す）
+			if ctxErr := c.UserContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
 			// エラーは無視して続行（古いレコード等）
 			continue
 		}
@@ -843,7 +1197,7 @@ func (r *Routes) compareAnalyses(c *fiber.Ctx) error {
 			"uniprot_id": record.UniProtID,
 			"method":     record.Method,
 			"status":     record.Status,
-			"created_at": record.CreatedAt.Format(time.RFC3339),
+			"created_at": formatTime(record.CreatedAt),
 		}
 		if record.Metrics != nil {
 			summary["metrics"] = record.Metrics
@@ -856,6 +1210,10 @@ func (r *Routes) compareAnalyses(c *fiber.Ctx) error {
 	})
 }
 
+// キャンセルしたジョブの後処理（チェックポイント・ログの保存、DBの更新）を待つ時間
+// 超えた場合は202と状態の取得先を返す
+const cancelSettleTimeout = 10 * time.Second
+
 func (r *Routes) cancelAnalysis(c *fiber.Ctx) error {
 	id := c.Params("id")
 
@@ -865,39 +1223,73 @@ func (r *Routes) cancelAnalysis(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"message":    "Analysis cancelled successfully",
-		"analysis_id": id,
-	})
+	// 後処理が終わってから最終的な状態を返す（UIがポーリングせずに表示を更新できるように）
+	statusURL := fmt.Sprintf("/api/analyses/%s", id)
+	ctx, cancel := context.WithTimeout(c.UserContext(), cancelSettleTimeout)
+	defer cancel()
+	if err := r.jobManager.WaitSettled(ctx, id); err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		requestLog(c).Warnf("Cancelled job %s did not settle within %s", id, cancelSettleTimeout)
+		c.Location(statusURL)
+		return c.Status(202).JSON(fiber.Map{
+			"message":     "Cancellation in progress",
+			"analysis_id": id,
+			"status_url":  statusURL,
+		})
+	}
+
+	response, err := r.analysisResponse(c.UserContext(), id)
+	if err != nil {
+		// 削除された場合など
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Analysis not found",
+		})
+	}
+	// 途中までの成果物（キャンセルまでのPythonの出力）
+	if _, _, err := r.loadJobLogs(c.UserContext(), id); err == nil {
+		response["partial_artifacts"] = fiber.Map{
+			"logs_url": fmt.Sprintf("/api/jobs/%s/logs", id),
+		}
+	}
+	response["message"] = "Analysis cancelled successfully"
+	response["analysis_id"] = id
+	return c.JSON(response)
 }
 
 func (r *Routes) deleteAnalysis(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
 	if id == "" {
-		fmt.Printf("[ERROR] Delete request with empty ID\n")
+		requestLog(c).Errorf("Delete request with empty ID")
 		return c.Status(400).JSON(fiber.Map{
 			"error": "Analysis ID is required",
 		})
 	}
 
-	fmt.Printf("[DEBUG] Deleting analysis: %s\n", id)
+	// ドライラン: 削除されるものを返すだけで何も削除しない
+	if c.QueryBool("dry_run") {
+		return r.deleteAnalysisDryRun(c, id)
+	}
+
+	requestLog(c).Debugf("Deleting analysis: %s", id)
 	
 	if err := r.jobManager.DeleteJob(id); err != nil {
-		fmt.Printf("[ERROR] Failed to delete job %s: %v\n", id, err)
+		requestLog(c).Errorf("Failed to delete job %s: %v", id, err)
 		return c.Status(500).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
-	fmt.Printf("[DEBUG] Analysis %s deleted successfully\n", id)
+	requestLog(c).Debugf("Analysis %s deleted successfully", id)
 	
 	response := fiber.Map{
 		"message":    "Analysis deleted successfully",
 		"analysis_id": id,
 	}
 	
-	fmt.Printf("[DEBUG] Sending delete response: %+v\n", response)
+	requestLog(c).Debugf("Sending delete response: %+v", response)
 	return c.JSON(response)
 }
 
@@ -909,7 +1301,7 @@ func (r *Routes) updateMetricsForAll(c *fiber.Ctx) error {
 	}
 
 	// すべての解析を取得
-	records, err := r.db.ListAnalyses(map[string]interface{}{"limit": 1000})
+	records, err := r.listAnalysisRecords(c.UserContext(), map[string]interface{}{"limit": 1000})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": err.Error(),
@@ -921,6 +1313,11 @@ func (r *Routes) updateMetricsForAll(c *fiber.Ctx) error {
 	errors := 0
 
 	for _, record := range records {
+		// 期限切れの場合は打ち切る（更新済みのレコードはそのまま残る）
+		if err := c.UserContext().Err(); err != nil {
+			return err
+		}
+
 		// メトリクスが既に存在する場合はスキップ
 		if len(record.Metrics) > 0 {
 			skipped++
@@ -928,7 +1325,7 @@ func (r *Routes) updateMetricsForAll(c *fiber.Ctx) error {
 		}
 
 		// result.jsonを読み込む
-		resultPath := filepath.Join(r.storageDir, record.ID, "result.json")
+		resultPath := filepath.Join(r.jobManager.LocalJobDir(record.ID), "result.json")
 		if _, err := os.Stat(resultPath); os.IsNotExist(err) {
 			skipped++
 			continue
@@ -937,14 +1334,14 @@ func (r *Routes) updateMetricsForAll(c *fiber.Ctx) error {
 		resultData, err := os.ReadFile(resultPath)
 		if err != nil {
 			errors++
-			fmt.Printf("[WARN] Failed to read result.json for %s: %v\n", record.ID, err)
+			requestLog(c).Warnf("Failed to read result.json for %s: %v", record.ID, err)
 			continue
 		}
 
 		var result map[string]interface{}
 		if err := json.Unmarshal(resultData, &result); err != nil {
 			errors++
-			fmt.Printf("[WARN] Failed to parse result.json for %s: %v\n", record.ID, err)
+			requestLog(c).Warnf("Failed to parse result.json for %s: %v", record.ID, err)
 			continue
 		}
 
@@ -954,9 +1351,10 @@ func (r *Routes) updateMetricsForAll(c *fiber.Ctx) error {
 		// メトリクスを更新
 		if err := r.db.UpdateMetricsFromResult(record.ID, metrics); err != nil {
 			errors++
-			fmt.Printf("[WARN] Failed to update metrics for %s: %v\n", record.ID, err)
+			requestLog(c).Warnf("Failed to update metrics for %s: %v", record.ID, err)
 			continue
 		}
+		r.jobManager.NotifyChanged(record.ID)
 
 		updated++
 	}
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"database/sql"
+	"dsa-api/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// セッションごとに保存できる検索の数
+	maxSavedSearches = 100
+	// 保存する検索の名前の最大の長さ
+	maxSavedSearchNameLength = 100
+)
+
+// 保存した検索の実行時にリクエストのクエリから引き継ぐパラメータ（ページング・タイムゾーン）
+var savedSearchRunParams = []string{"page_size", "cursor", "include_total", "limit", "offset", "tz"}
+
+// SavedSearchFilters 保存する絞り込みの条件（GET /api/analysesのクエリと同じ意味）
+type SavedSearchFilters struct {
+	UniProtIDs []string `json:"uniprot_ids,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Pinned     *bool    `json:"pinned,omitempty"`
+	Method     string   `json:"method,omitempty"`
+	Status     string   `json:"status,omitempty"`
+	// RFC3339または日付（実行時のtzの日付として解釈する）
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+	Query string `json:"q,omitempty"`
+	// 指標の範囲（{"mean_score_gt": 10, "entries_gte": 5}）
+	MetricRanges map[string]float64 `json:"metric_ranges,omitempty"`
+	Sort         string             `json:"sort,omitempty"`
+}
+
+type CreateSavedSearchRequest struct {
+	Name    string             `json:"name"`
+	Filters SavedSearchFilters `json:"filters"`
+}
+
+type UpdateSavedSearchRequest struct {
+	Name    *string             `json:"name"`
+	Filters *SavedSearchFilters `json:"filters"`
+}
+
+// savedSearchFiltersSchema 保存する絞り込みの条件（指標の範囲の名前・日時・並び順はnormalizeで検証する）
+var savedSearchFiltersSchema = objectSchema{
+	"uniprot_ids":   {Type: typeArray, Items: &fieldSchema{Type: typeString, NonEmpty: true}},
+	"tags":          {Type: typeArray, Items: &fieldSchema{Type: typeString, NonEmpty: true}},
+	"pinned":        {Type: typeBoolean},
+	"method":        {Type: typeString},
+	"status":        {Type: typeString},
+	"from":          {Type: typeString},
+	"to":            {Type: typeString},
+	"q":             {Type: typeString},
+	"metric_ranges": {Type: typeObject},
+	"sort":          {Type: typeString},
+}
+
+// createSavedSearchSchema POST /api/searches
+var createSavedSearchSchema = objectSchema{
+	"name":    {Type: typeString, Required: true, NonEmpty: true},
+	"filters": {Type: typeObject, Required: true, Properties: &savedSearchFiltersSchema},
+}
+
+// updateSavedSearchSchema PATCH /api/searches/:id
+var updateSavedSearchSchema = objectSchema{
+	"name":    {Type: typeString, NonEmpty: true},
+	"filters": {Type: typeObject, Properties: &savedSearchFiltersSchema},
+}
+
+// normalize 条件を検証し、UniProt ID・文字列を正規化する（日時はlocの日付として検証する）
+func (f *SavedSearchFilters) normalize(loc *time.Location) error {
+	ids, err := parseUniProtIDs(f.UniProtIDs)
+	if err != nil {
+		return err
+	}
+	f.UniProtIDs = ids
+	tags, err := normalizeTags(f.Tags)
+	if err != nil {
+		return err
+	}
+	f.Tags = tags
+	f.Method = strings.TrimSpace(f.Method)
+	f.Status = strings.TrimSpace(f.Status)
+	f.Query = strings.TrimSpace(f.Query)
+	if len(f.Query) > maxSearchQueryLength {
+		return fmt.Errorf("q must be at most %d characters", maxSearchQueryLength)
+	}
+	for name, value := range map[string]string{"from": f.From, "to": f.To} {
+		if value == "" {
+			continue
+		}
+		if _, err := parseTimeFilter(value, loc, name == "to"); err != nil {
+			return fmt.Errorf("Invalid %s: %v", name, err)
+		}
+	}
+	for key := range f.MetricRanges {
+		if _, _, ok := parseMetricRangeKey(key); !ok {
+			return fmt.Errorf("Unsupported metric range: %s", key)
+		}
+	}
+	if f.Sort != "" {
+		if _, err := parseSortKeys(f.Sort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query GET /api/analysesのクエリ
+func (f *SavedSearchFilters) query() url.Values {
+	query := url.Values{}
+	if len(f.UniProtIDs) > 0 {
+		query.Set("uniprot_ids", strings.Join(f.UniProtIDs, ","))
+	}
+	if len(f.Tags) > 0 {
+		query.Set("tags", strings.Join(f.Tags, ","))
+	}
+	if f.Pinned != nil {
+		query.Set("pinned", strconv.FormatBool(*f.Pinned))
+	}
+	for key, value := range map[string]string{"method": f.Method, "status": f.Status, "from": f.From, "to": f.To, "q": f.Query, "sort": f.Sort} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	keys := make([]string, 0, len(f.MetricRanges))
+	for key := range f.MetricRanges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query.Set(key, fmt.Sprintf("%g", f.MetricRanges[key]))
+	}
+	return query
+}
+
+// requireDB DBが設定されていない場合は503を返す（保存した検索・プロジェクト・タグ・メモ）
+func (r *Routes) requireDB(c *fiber.Ctx) error {
+	if r.db == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	}
+	return c.Next()
+}
+
+// savedSearchResponse 保存した検索のレスポンス
+func savedSearchResponse(search *storage.SavedSearch) fiber.Map {
+	var filters SavedSearchFilters
+	_ = json.Unmarshal(search.Filters, &filters)
+	response := fiber.Map{
+		"id":          search.ID,
+		"name":        search.Name,
+		"filters":     filters,
+		"created_at":  formatTime(search.CreatedAt),
+		"updated_at":  formatTime(search.UpdatedAt),
+		"last_run_at": nil,
+	}
+	if search.LastRunAt != nil {
+		response["last_run_at"] = formatTime(*search.LastRunAt)
+	}
+	return response
+}
+
+// savedSearchError 保存した検索のエラーをレスポンスに変換する（ない場合は404、同じ名前は409）
+func savedSearchError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Saved search not found",
+		})
+	case errors.Is(err, storage.ErrSavedSearchNameTaken):
+		return c.Status(409).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if ctxErr := c.UserContext().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	requestLog(c).Errorf("Failed to %s saved search: %v", action, err)
+	return c.Status(500).JSON(fiber.Map{
+		"error": fmt.Sprintf("Failed to %s saved search", action),
+	})
+}
+
+// createSavedSearch POST /api/searches 絞り込みの条件に名前を付けてセッション（ログイン中はアカウント）に保存する
+func (r *Routes) createSavedSearch(c *fiber.Ctx) error {
+	var req CreateSavedSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxSavedSearchNameLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("name must be 1 to %d characters", maxSavedSearchNameLength),
+		})
+	}
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err := req.Filters.normalize(loc); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filters, err := json.Marshal(req.Filters)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid filters",
+		})
+	}
+
+	sessionID := r.jobSessionID(c)
+	count, err := r.db.CountSavedSearches(c.UserContext(), sessionID)
+	if err != nil {
+		return savedSearchError(c, "create", err)
+	}
+	if count >= maxSavedSearches {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("At most %d searches can be saved", maxSavedSearches),
+		})
+	}
+
+	search, err := r.db.CreateSavedSearch(c.UserContext(), &storage.SavedSearch{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Name:      req.Name,
+		Filters:   filters,
+	})
+	if err != nil {
+		return savedSearchError(c, "create", err)
+	}
+	return c.Status(201).JSON(savedSearchResponse(search))
+}
+
+// listSavedSearches GET /api/searches セッションの保存した検索（新しい順）
+func (r *Routes) listSavedSearches(c *fiber.Ctx) error {
+	response := make([]fiber.Map, 0)
+	sessionID := r.requestSessionID(c)
+	if sessionID == "" {
+		return c.JSON(response)
+	}
+	searches, err := r.db.ListSavedSearches(c.UserContext(), sessionID)
+	if err != nil {
+		return savedSearchError(c, "list", err)
+	}
+	for _, search := range searches {
+		response = append(response, savedSearchResponse(search))
+	}
+	return c.JSON(response)
+}
+
+// savedSearch セッションの保存した検索（:id）
+func (r *Routes) savedSearch(c *fiber.Ctx) (*storage.SavedSearch, error) {
+	sessionID := r.requestSessionID(c)
+	if sessionID == "" {
+		return nil, sql.ErrNoRows
+	}
+	id := c.Params("id")
+	return r.db.GetSavedSearch(c.UserContext(), sessionID, id)
+}
+
+func (r *Routes) getSavedSearch(c *fiber.Ctx) error {
+	search, err := r.savedSearch(c)
+	if err != nil {
+		return savedSearchError(c, "get", err)
+	}
+	return c.JSON(savedSearchResponse(search))
+}
+
+// updateSavedSearch PATCH /api/searches/:id 名前・絞り込みの条件を変更する
+func (r *Routes) updateSavedSearch(c *fiber.Ctx) error {
+	var req UpdateSavedSearchRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > maxSavedSearchNameLength {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("name must be 1 to %d characters", maxSavedSearchNameLength),
+			})
+		}
+		req.Name = &name
+	}
+	var filters json.RawMessage
+	if req.Filters != nil {
+		loc, err := r.requestLocation(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err := req.Filters.normalize(loc); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if filters, err = json.Marshal(req.Filters); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid filters",
+			})
+		}
+	}
+
+	sessionID := r.requestSessionID(c)
+	if sessionID == "" {
+		return savedSearchError(c, "update", sql.ErrNoRows)
+	}
+	id := c.Params("id")
+	search, err := r.db.UpdateSavedSearch(c.UserContext(), sessionID, id, req.Name, filters)
+	if err != nil {
+		return savedSearchError(c, "update", err)
+	}
+	return c.JSON(savedSearchResponse(search))
+}
+
+func (r *Routes) deleteSavedSearch(c *fiber.Ctx) error {
+	sessionID := r.requestSessionID(c)
+	id := c.Params("id")
+	deleted := false
+	if sessionID != "" {
+		var err error
+		deleted, err = r.db.DeleteSavedSearch(c.UserContext(), sessionID, id)
+		if err != nil {
+			return savedSearchError(c, "delete", err)
+		}
+	}
+	if !deleted {
+		return savedSearchError(c, "delete", sql.ErrNoRows)
+	}
+	return c.JSON(fiber.Map{
+		"message":   "Saved search deleted successfully",
+		"search_id": id,
+	})
+}
+
+// runSavedSearch GET /api/searches/:id/results 保存した条件でGET /api/analysesを実行する
+// ページング（page_size・cursor・include_total・limit・offset）とtzはリクエストのクエリを使う
+func (r *Routes) runSavedSearch(c *fiber.Ctx) error {
+	search, err := r.savedSearch(c)
+	if err != nil {
+		return savedSearchError(c, "run", err)
+	}
+	var filters SavedSearchFilters
+	if err := json.Unmarshal(search.Filters, &filters); err != nil {
+		return savedSearchError(c, "run", err)
+	}
+
+	query := filters.query()
+	for _, key := range savedSearchRunParams {
+		if v := c.Query(key); v != "" {
+			query.Set(key, v)
+		}
+	}
+	c.Request().URI().SetQueryString(query.Encode())
+
+	if err := r.db.MarkSavedSearchRun(c.UserContext(), search.ID); err != nil {
+		requestLog(c).Warnf("Failed to record run of saved search %s: %v", search.ID, err)
+	}
+	return r.listAnalyses(c)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// キャッシュする解析の数（超えた場合はすべて捨てる）
+const residueScoreCacheLimit = 256
+
+// residueScoreCache 解析ごとの残基ごとの平均スコア（スコア行列から計算したもの）
+// 成果物は解析ごとに変わらないため無効化はしない
+type residueScoreCache struct {
+	mu     sync.Mutex
+	scores map[string][]*float64
+}
+
+func (c *residueScoreCache) get(id string) ([]*float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scores, ok := c.scores[id]
+	return scores, ok
+}
+
+func (c *residueScoreCache) put(id string, scores []*float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scores == nil || len(c.scores) >= residueScoreCacheLimit {
+		c.scores = make(map[string][]*float64)
+	}
+	c.scores[id] = scores
+}
+
+// getAnalysisScores GET /api/analyses/:id/scores?from=10&to=120 残基ごとの平均スコアの一部を返す
+// 残基番号は1始まりで、fromとtoを含む（省略時は先頭・末尾まで）。値のない残基はnull
+// スコア行列（score_matrix.json）全体を取得せずに必要な範囲だけ取得するためのもので、計算した結果はメモリにキャッシュする
+func (r *Routes) getAnalysisScores(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	// キャッシュがあっても削除済み・未完了の解析には返さない
+	if _, err := r.loadShareTarget(c.UserContext(), id); err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	scores, ok := r.residueScores.get(id)
+	if !ok {
+		matrix, status, err := r.loadScoreMatrix(c.UserContext(), id)
+		if err != nil {
+			if ctxErr := c.UserContext().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		scores = residueScores(matrix)
+		r.residueScores.put(id, scores)
+	}
+
+	length := len(scores)
+	from, err := residueParam(c, "from", 1)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	to, err := residueParam(c, "to", length)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if from > to {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "from must be <= to",
+		})
+	}
+	if from > length {
+		return c.Status(400).JSON(fiber.Map{
+			"error":  "from is out of range",
+			"length": length,
+		})
+	}
+	if to > length {
+		to = length
+	}
+
+	return c.JSON(fiber.Map{
+		"analysis_id": id,
+		"length":      length,
+		"from":        from,
+		"to":          to,
+		"scores":      scores[from-1 : to],
+	})
+}
+
+// residueParam 残基番号のクエリパラメータ（1以上の整数、省略時はdef）
+func residueParam(c *fiber.Ctx, name string, def int) (int, error) {
+	v := c.Query(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("Invalid %s: %s (must be a residue number >= 1)", name, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"dsa-api/storage"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// 部分一致検索の最大の長さ
+	maxSearchQueryLength = 200
+	// uniprot_idsに指定できるUniProt IDの数
+	maxSearchUniProtIDs = 100
+)
+
+// addSearchFilters 部分一致検索（q）・タグ（tags=publication candidate,reviewed、すべて付いたもの）・固定（pinned=true）・UniProt IDの集合（uniprot_ids=P69905,P68871）・指標の範囲（mean_score_gt=10、entries_gte=5 など）・並び順（sort=-mean_score,created_at）をフィルターに追加する
+func addSearchFilters(c *fiber.Ctx, filters map[string]interface{}) error {
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		if len(q) > maxSearchQueryLength {
+			return fmt.Errorf("q must be at most %d characters", maxSearchQueryLength)
+		}
+		filters[storage.FilterQuery] = q
+	}
+
+	if v := c.Query("tags"); v != "" {
+		tags, err := normalizeTags(strings.Split(v, ","))
+		if err != nil {
+			return err
+		}
+		if len(tags) > 0 {
+			filters[storage.FilterTags] = tags
+		}
+	}
+
+	if v := c.Query("pinned"); v != "" {
+		pinned, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("Invalid pinned: %s", v)
+		}
+		filters[storage.FilterPinned] = pinned
+	}
+
+	if v := c.Query("uniprot_ids"); v != "" {
+		ids, err := parseUniProtIDs(strings.Split(v, ","))
+		if err != nil {
+			return err
+		}
+		filters[storage.FilterUniProtIDs] = ids
+	}
+
+	// <指標>_<gt|gte|lt|lte>（下限と上限を同時に指定できる）
+	var ranges []storage.MetricRange
+	for key, value := range c.Queries() {
+		name, op, ok := parseMetricRangeKey(key)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %s", key, value)
+		}
+		ranges = append(ranges, storage.MetricRange{Name: name, Op: op, Value: v})
+	}
+	if len(ranges) > 0 {
+		// SQLが毎回同じになるように並べる
+		sort.Slice(ranges, func(i, j int) bool {
+			if ranges[i].Name != ranges[j].Name {
+				return ranges[i].Name < ranges[j].Name
+			}
+			return ranges[i].Op < ranges[j].Op
+		})
+		filters[storage.FilterMetricRanges] = ranges
+	}
+
+	if v := c.Query("sort"); v != "" {
+		keys, err := parseSortKeys(v)
+		if err != nil {
+			return err
+		}
+		filters[storage.FilterSort] = keys
+	}
+	return nil
+}
+
+// parseUniProtIDs UniProt IDの集合（空の要素は除き、大文字にする）
+func parseUniProtIDs(values []string) ([]string, error) {
+	ids := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			ids = append(ids, v)
+		}
+	}
+	if len(ids) > maxSearchUniProtIDs {
+		return nil, fmt.Errorf("uniprot_ids must have at most %d IDs", maxSearchUniProtIDs)
+	}
+	return ids, nil
+}
+
+// parseMetricRangeKey 指標の範囲のクエリ（mean_score_gt）を指標と比較に分ける（指標の範囲でない場合はfalse）
+func parseMetricRangeKey(key string) (string, string, bool) {
+	i := strings.LastIndex(key, "_")
+	if i <= 0 {
+		return "", "", false
+	}
+	name, op := key[:i], key[i+1:]
+	if !storage.SearchMetrics[name] || !storage.ValidMetricRangeOp(op) {
+		return "", "", false
+	}
+	return name, op, true
+}
+
+// parseSortKeys 並び順（カンマ区切り、-を付けた列は降順）
+func parseSortKeys(v string) ([]storage.SortKey, error) {
+	var keys []storage.SortKey
+	for _, field := range strings.Split(v, ",") {
+		field = strings.TrimSpace(field)
+		key := storage.SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+		if !storage.ValidSortField(key.Field) {
+			return nil, fmt.Errorf("Unsupported sort: %s", field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
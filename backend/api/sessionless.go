@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SetSessionless 別のアプリケーションの内部サービスとして動かすモード（AUTH_MODE=api_key）を設定する
+// 有効な場合はすべてのAPIでAPIキーを必須とし、セッションCookieの発行・セッションによる絞り込みを行わない
+// セッション単位の機能（Webhook、WebSocketのセッション購読）は無効になる
+func (r *Routes) SetSessionless(enabled bool) {
+	r.sessionless = enabled
+}
+
+// requireAPIKey セッションレスモードの場合、APIキーのないリクエストに401を返す
+// ヘルスチェック・フロントエンド向け設定・インスタンス情報・リモートワーカー用の内部API（ワーカーのトークンで認証）は対象外
+func (r *Routes) requireAPIKey(c *fiber.Ctx) error {
+	if !r.sessionless {
+		return c.Next()
+	}
+	switch path := c.Path(); {
+	case path == "/api/health", path == "/api/config", path == "/api/instance", strings.HasPrefix(path, "/api/internal/"):
+		return c.Next()
+	}
+	if !r.validAPIKey(requestAPIKey(c)) {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Valid API key is required",
+		})
+	}
+	return c.Next()
+}
+
+// requireSessions セッションレスモードではセッション単位の機能に404を返す
+func (r *Routes) requireSessions(c *fiber.Ctx) error {
+	if r.sessionless {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Session features are disabled in api_key auth mode",
+		})
+	}
+	return c.Next()
+}
+
+// jobSessionID ジョブに記録するセッションID（セッションレスモードでは空文字列、Cookieも発行しない）
+// ログイン中はアカウントのセッションID
+func (r *Routes) jobSessionID(c *fiber.Ctx) string {
+	if r.sessionless {
+		return ""
+	}
+	if claims := requestClaims(c); claims != nil {
+		return claims.SessionID
+	}
+	return ensureSessionID(c)
+}
+
+// requestSessionID CookieのセッションID（セッションレスモードでは常に空文字列）
+// ログイン中はアカウントのセッションID（別の端末からも同じ履歴になる）
+func (r *Routes) requestSessionID(c *fiber.Ctx) string {
+	if r.sessionless {
+		return ""
+	}
+	if claims := requestClaims(c); claims != nil {
+		return claims.SessionID
+	}
+	return c.Cookies("dsa_session_id")
+}
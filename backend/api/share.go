@@ -0,0 +1,445 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"dsa-api/jobs"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// 共有ページのサムネイルの最大幅（px）
+	shareThumbnailWidth = 600
+	// 共有リンクの有効期限のデフォルト（ShareConfig.TTLが0の場合）
+	defaultShareTTL = 7 * 24 * time.Hour
+	// POST /api/analyses/:id/shareのexpires_in（秒）の上限
+	maxShareTTL = 365 * 24 * time.Hour
+)
+
+var (
+	minShareExpiresIn = 60.0
+	maxShareExpiresIn = maxShareTTL.Seconds()
+)
+
+// createShareSchema POST /api/analyses/:id/share
+var createShareSchema = objectSchema{
+	"expires_in": {Type: typeInteger, Min: &minShareExpiresIn, Max: &maxShareExpiresIn},
+}
+
+// ShareConfig 共有リンクの設定
+type ShareConfig struct {
+	// トークンの署名鍵（未設定の場合は起動ごとにランダムに生成され、再起動で共有リンクが無効になる）
+	Secret []byte
+	// 共有リンク・OG画像の絶対URLに使うAPIの公開URL（未設定の場合はリクエストのホストから組み立てる）
+	PublicURL string
+	// 共有ページから遷移するフロントエンドのURL（未設定の場合は同一オリジン）
+	FrontendURL string
+	// 共有リンクの有効期限（0の場合は7日、負の値は期限なし、DB未設定の場合は常に期限なし）
+	TTL time.Duration
+}
+
+// SetShareConfig 共有リンクの設定を行う
+func (r *Routes) SetShareConfig(cfg ShareConfig) {
+	if len(cfg.Secret) == 0 {
+		// NewRoutesで生成したランダムな鍵を使う
+		cfg.Secret = r.share.Secret
+		logging.Warnf("SHARE_SECRET is not set, share links will be invalidated on restart")
+	}
+	cfg.PublicURL = strings.TrimRight(cfg.PublicURL, "/")
+	cfg.FrontendURL = strings.TrimRight(cfg.FrontendURL, "/")
+	r.share = cfg
+}
+
+// randomSecret 起動ごとのトークン署名鍵（共有リンク・ログイン用のトークン）
+func randomSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("failed to generate secret: %v", err))
+	}
+	return secret
+}
+
+// shareToken 解析IDに署名した共有トークン（<base64url(id)>.<署名>）を返す
+func (r *Routes) shareToken(id string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(id))
+	return encoded + "." + r.shareSignature(encoded)
+}
+
+// parseShareToken 共有トークンを検証して解析IDを返す
+func (r *Routes) parseShareToken(token string) (string, bool) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(r.shareSignature(encoded))) {
+		return "", false
+	}
+	id, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(id), true
+}
+
+func (r *Routes) shareSignature(encoded string) string {
+	mac := hmac.New(sha256.New, r.share.Secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (r *Routes) sharePublicURL(c *fiber.Ctx) string {
+	if r.share.PublicURL != "" {
+		return r.share.PublicURL
+	}
+	return c.BaseURL()
+}
+
+// createShareLink 完了した解析の共有リンクを発行する
+// DB設定時はリンクをanalysis_sharesに登録し、期限切れ・取り消し（DELETE /api/analyses/:id/share/:token）で無効にできる
+// DB未設定の場合は解析IDに署名したトークンを返す（期限・取り消しなし）
+func (r *Routes) createShareLink(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var req struct {
+		ExpiresIn *int64 `json:"expires_in"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	if _, err := r.loadShareTarget(c.UserContext(), id); err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if r.db == nil {
+		if req.ExpiresIn != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Share link expiry requires the database",
+			})
+		}
+		token := r.shareToken(id)
+		return c.JSON(fiber.Map{
+			"token": token,
+			"url":   fmt.Sprintf("%s/share/%s", r.sharePublicURL(c), token),
+		})
+	}
+
+	ttl := r.share.TTL
+	if ttl == 0 {
+		ttl = defaultShareTTL
+	}
+	if req.ExpiresIn != nil {
+		ttl = time.Duration(*req.ExpiresIn) * time.Second
+	}
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+	share, err := r.db.CreateAnalysisShare(c.UserContext(), uuid.New().String(), id, expiresAt)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		requestLog(c).Errorf("Failed to create share link for %s: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create share link",
+		})
+	}
+	return c.Status(201).JSON(r.shareLinkResponse(c, share))
+}
+
+// resolveShare 共有トークンを検証して共有リンクを返す（無効な場合はステータスコードとエラー）
+// DB未設定の場合は解析IDに署名したトークンとして扱う
+func (r *Routes) resolveShare(ctx context.Context, token string) (*storage.AnalysisShare, int, error) {
+	payload, ok := r.parseShareToken(token)
+	if !ok {
+		return nil, 404, errors.New("Share link not found")
+	}
+	if r.db == nil {
+		return &storage.AnalysisShare{ID: payload, AnalysisID: payload}, 0, nil
+	}
+
+	share, err := r.db.GetAnalysisShare(ctx, payload)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, 404, errors.New("Share link not found")
+	case err != nil:
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, 500, ctxErr
+		}
+		logging.Errorf("Failed to load share link: %v", err)
+		return nil, 500, errors.New("Failed to load share link")
+	case share.RevokedAt != nil:
+		return nil, 410, errors.New("Share link has been revoked")
+	case share.Expired(time.Now()):
+		return nil, 410, errors.New("Share link has expired")
+	}
+	return share, 0, nil
+}
+
+// shareTarget 共有ページに表示する解析の情報
+type shareTarget struct {
+	ID         string
+	UniProtID  string
+	Method     string
+	HeatmapKey *string
+	ResultKey  *string
+}
+
+// loadShareTarget 共有対象の完了した解析を取得する（DB優先、なければジョブから）
+func (r *Routes) loadShareTarget(ctx context.Context, id string) (*shareTarget, error) {
+	if r.db != nil {
+		if record, err := r.getAnalysisRecord(ctx, id); err == nil {
+			if record.Status != string(jobs.StatusDone) {
+				return nil, errors.New("Analysis is not completed")
+			}
+			return &shareTarget{
+				ID:         record.ID,
+				UniProtID:  record.UniProtID,
+				Method:     record.Method,
+				HeatmapKey: record.HeatmapKey,
+				ResultKey:  record.ResultKey,
+			}, nil
+		}
+	}
+
+	job, err := r.jobManager.GetJob(id)
+	if err != nil {
+		return nil, errors.New("Analysis not found")
+	}
+	if job.Status != jobs.StatusDone {
+		return nil, errors.New("Analysis is not completed")
+	}
+	method, _ := job.Params["method"].(string)
+	return &shareTarget{ID: job.ID, UniProtID: job.UniProtID, Method: method}, nil
+}
+
+// loadArtifact 成果物をR2から取得し、取得できなければローカルファイルから読み込む
+func (r *Routes) loadArtifact(ctx context.Context, id, name string, key *string) ([]byte, error) {
+	if r.r2 != nil {
+		artifactKey := r.jobManager.ArtifactKey(id, name)
+		if key != nil {
+			artifactKey = *key
+		}
+		data, err := r.r2.GetObject(ctx, artifactKey)
+		if err == nil {
+			return data, nil
+		}
+		logging.Warnf("Failed to get %s from R2 for %s (key: %s): %v", name, id, artifactKey, err)
+	}
+	return os.ReadFile(filepath.Join(r.jobManager.LocalJobDir(id), name))
+}
+
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="article">
+<meta property="og:site_name" content="DSA">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{- if .Image}}
+<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.Image}}">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta http-equiv="refresh" content="0; url={{.RedirectURL}}">
+</head>
+<body>
+<p><a href="{{.RedirectURL}}">{{.Title}}</a></p>
+</body>
+</html>
+`))
+
+// getSharePage 共有リンクのOpen Graph/Twitterカード用のページを返す
+// ブラウザはフロントエンドの結果ページへリダイレクトされる
+// Accept: application/jsonの場合は読み取り専用の表示（getShareView）を返す
+func (r *Routes) getSharePage(c *fiber.Ctx) error {
+	c.Vary(fiber.HeaderAccept)
+	if c.Accepts(fiber.MIMETextHTML, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON {
+		return r.getShareView(c)
+	}
+
+	token := c.Params("token")
+	share, status, err := r.resolveShare(c.UserContext(), token)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).SendString(err.Error())
+	}
+	target, err := r.loadShareTarget(c.UserContext(), share.AnalysisID)
+	if err != nil {
+		return c.Status(404).SendString(err.Error())
+	}
+
+	title := target.UniProtID
+	description := fmt.Sprintf("DSA analysis of %s", target.UniProtID)
+	if data, err := r.loadArtifact(c.UserContext(), target.ID, "result.json", target.ResultKey); err == nil {
+		var result struct {
+			Statistics struct {
+				ProteinName string  `json:"protein_name"`
+				Organism    string  `json:"organism"`
+				Entries     int     `json:"entries"`
+				UMF         float64 `json:"umf"`
+			} `json:"statistics"`
+		}
+		if err := json.Unmarshal(data, &result); err == nil {
+			stats := result.Statistics
+			if stats.ProteinName != "" {
+				title = fmt.Sprintf("%s (%s)", stats.ProteinName, target.UniProtID)
+			}
+			parts := []string{description}
+			if stats.Organism != "" {
+				parts = append(parts, stats.Organism)
+			}
+			if stats.Entries > 0 {
+				parts = append(parts, fmt.Sprintf("%d PDB entries", stats.Entries))
+			}
+			if stats.UMF > 0 {
+				parts = append(parts, fmt.Sprintf("UMF %.1f", stats.UMF))
+			}
+			description = strings.Join(parts, " / ")
+		}
+	}
+
+	publicURL := r.sharePublicURL(c)
+	page := map[string]string{
+		"Title":       title,
+		"Description": description,
+		"URL":         fmt.Sprintf("%s/share/%s", publicURL, token),
+		"RedirectURL": fmt.Sprintf("%s/analysis/result?job_id=%s", r.share.FrontendURL, target.ID),
+	}
+	if r.r2 != nil || target.HeatmapKey != nil || fileExists(filepath.Join(r.jobManager.LocalJobDir(target.ID), "heatmap.png")) {
+		page["Image"] = fmt.Sprintf("%s/share/%s/thumbnail.png", publicURL, token)
+	}
+
+	var b bytes.Buffer
+	if err := sharePageTemplate.Execute(&b, page); err != nil {
+		return c.Status(500).SendString("Failed to render share page")
+	}
+	c.Set("Content-Type", "text/html; charset=utf-8")
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.Send(b.Bytes())
+}
+
+// getShareThumbnail 共有ページ用にヒートマップを縮小した画像を返す
+func (r *Routes) getShareThumbnail(c *fiber.Ctx) error {
+	share, status, err := r.resolveShare(c.UserContext(), c.Params("token"))
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	target, err := r.loadShareTarget(c.UserContext(), share.AnalysisID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	data, err := r.loadArtifact(c.UserContext(), target.ID, "heatmap.png", target.HeatmapKey)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Heatmap not found",
+		})
+	}
+	src, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to decode heatmap",
+		})
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, downscale(src, shareThumbnailWidth)); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to encode thumbnail",
+		})
+	}
+	c.Set("Content-Type", "image/png")
+	c.Set("Cache-Control", "public, max-age=86400")
+	return c.Send(b.Bytes())
+}
+
+// downscale 画像を幅maxWidthまで縮小する（各画素は対応する領域の平均色）
+func downscale(src image.Image, maxWidth int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxWidth || srcW == 0 {
+		return src
+	}
+	dstW := maxWidth
+	dstH := srcH * dstW / srcW
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := bounds.Min.Y + (y+1)*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := bounds.Min.X + (x+1)*srcW/dstW
+			var sr, sg, sb, sa, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					sr += uint64(cr)
+					sg += uint64(cg)
+					sb += uint64(cb)
+					sa += uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(sr / n),
+				G: uint16(sg / n),
+				B: uint16(sb / n),
+				A: uint16(sa / n),
+			})
+		}
+	}
+	return dst
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
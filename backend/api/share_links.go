@@ -0,0 +1,214 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 共有リンクから取得できる成果物（ログ等は含めない）
+var shareArtifactNames = []string{"result.json", "heatmap.png", "dist_score.png"}
+
+func isShareArtifact(name string) bool {
+	for _, n := range shareArtifactNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// shareLinkResponse 共有リンクの発行・一覧のレスポンス
+func (r *Routes) shareLinkResponse(c *fiber.Ctx, share *storage.AnalysisShare) fiber.Map {
+	token := r.shareToken(share.ID)
+	response := fiber.Map{
+		"token":      token,
+		"url":        fmt.Sprintf("%s/share/%s", r.sharePublicURL(c), token),
+		"created_at": formatTime(share.CreatedAt),
+		"expires_at": nil,
+		"revoked_at": nil,
+		"active":     share.RevokedAt == nil && !share.Expired(time.Now()),
+	}
+	if share.ExpiresAt != nil {
+		response["expires_at"] = formatTime(*share.ExpiresAt)
+	}
+	if share.RevokedAt != nil {
+		response["revoked_at"] = formatTime(*share.RevokedAt)
+	}
+	return response
+}
+
+// listShareLinks GET /api/analyses/:id/shares 解析の共有リンクの一覧（期限切れ・取り消し済みを含む）
+func (r *Routes) listShareLinks(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if r.db == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	}
+
+	shares, err := r.db.ListAnalysisShares(c.UserContext(), id)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		requestLog(c).Errorf("Failed to list share links for %s: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to list share links",
+		})
+	}
+
+	links := make([]fiber.Map, 0, len(shares))
+	for _, share := range shares {
+		links = append(links, r.shareLinkResponse(c, share))
+	}
+	return c.JSON(fiber.Map{
+		"analysis_id": id,
+		"shares":      links,
+	})
+}
+
+// revokeShareLink DELETE /api/analyses/:id/share/:token 共有リンクを取り消す（以降のアクセスは410）
+func (r *Routes) revokeShareLink(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if r.db == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	}
+	shareID, ok := r.parseShareToken(c.Params("token"))
+	if !ok {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Share link not found",
+		})
+	}
+
+	revoked, err := r.db.RevokeAnalysisShare(c.UserContext(), id, shareID)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		requestLog(c).Errorf("Failed to revoke share link of %s: %v", id, err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke share link",
+		})
+	}
+	if !revoked {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Share link not found or already revoked",
+		})
+	}
+	requestLog(c).Infof("Revoked share link %s of %s", shareID, id)
+	return c.SendStatus(204)
+}
+
+// getShareView GET /share/:token（Accept: application/json）共有された解析の読み取り専用の表示
+// 概要・メトリクス（result.jsonのscore_summaryとstatistics）と、共有リンク経由の成果物のURLを返す
+func (r *Routes) getShareView(c *fiber.Ctx) error {
+	token := c.Params("token")
+	ctx := c.UserContext()
+	share, status, err := r.resolveShare(ctx, token)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	target, err := r.loadShareTarget(ctx, share.AnalysisID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	response := fiber.Map{
+		"analysis_id": target.ID,
+		"summary": fiber.Map{
+			"id":         target.ID,
+			"uniprot_id": target.UniProtID,
+			"method":     target.Method,
+		},
+		"expires_at": nil,
+	}
+	if share.ExpiresAt != nil {
+		response["expires_at"] = formatTime(*share.ExpiresAt)
+	}
+
+	result, status, err := r.loadAnalysisResult(ctx, target.ID)
+	switch {
+	case err == nil:
+		response["parameters"] = result.Parameters
+		response["metrics"] = fiber.Map{
+			"score_summary": result.ScoreSummary,
+			"statistics":    result.Statistics,
+		}
+	case status == 410:
+		response["artifacts_expired"] = true
+	default:
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if status != 410 {
+		publicURL := r.sharePublicURL(c)
+		artifacts := fiber.Map{}
+		for _, name := range shareArtifactNames {
+			artifact, ok := jobs.LookupArtifact(name)
+			if !ok || artifact.URLField == "" {
+				continue
+			}
+			if r.r2 != nil || fileExists(filepath.Join(r.jobManager.LocalJobDir(target.ID), artifact.FileName())) {
+				artifacts[artifact.URLField] = fmt.Sprintf("%s/share/%s/artifacts/%s", publicURL, token, name)
+			}
+		}
+		response["artifacts"] = artifacts
+	}
+
+	c.Set("Cache-Control", "no-store")
+	return c.JSON(response)
+}
+
+// getShareArtifact GET /share/:token/artifacts/:name 共有リンクから成果物を返す（セッションのCookieは不要）
+func (r *Routes) getShareArtifact(c *fiber.Ctx) error {
+	share, status, err := r.resolveShare(c.UserContext(), c.Params("token"))
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	name := c.Params("name")
+	if !isShareArtifact(name) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": fmt.Sprintf("Unknown artifact: %s", name),
+		})
+	}
+	if unavailable, err := r.artifactsUnavailable(c, share.AnalysisID); unavailable {
+		return err
+	}
+
+	if r.db != nil {
+		return r.sendAnalysisArtifact(c, share.AnalysisID, name)
+	}
+	// DB未設定の場合はR2（推測したキー）またはローカルファイルから返す
+	artifact, _ := jobs.LookupArtifact(name)
+	data, err := r.loadArtifact(c.UserContext(), share.AnalysisID, name, nil)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": fmt.Sprintf("%s not found", artifact.Label),
+		})
+	}
+	return sendConditional(c, data, time.Time{}, artifact.ContentType)
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"dsa-api/storage"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 全体の集計のデフォルトの期間と、件数・区間数の上限
+const (
+	defaultAggregateDays = 30
+	defaultTopUniProt    = 10
+	maxTopUniProt        = 100
+	defaultScoreBins     = 20
+	maxScoreBins         = 100
+	// bucket=hourで集計できる期間の上限
+	maxHourlyRange = 31 * 24 * time.Hour
+)
+
+// getStats GET /api/stats ダッシュボード用に解析を集計する（DBが必要）
+// ステータス・手法ごとの件数、成功率、mean_scoreの分布、解析数の多いUniProt ID、期間（bucket）ごとの件数と成功率を返す
+func (r *Routes) getStats(c *fiber.Ctx) error {
+	if r.db == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	}
+
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		t, err := parseTimeFilter(v, loc, true)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid to: %v", err),
+			})
+		}
+		to = t.Add(time.Nanosecond)
+	}
+	from := to.AddDate(0, 0, -defaultAggregateDays)
+	if v := c.Query("from"); v != "" {
+		t, err := parseTimeFilter(v, loc, false)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid from: %v", err),
+			})
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "from must be before to",
+		})
+	}
+
+	bucket := c.Query("bucket", "day")
+	if !storage.ValidStatsBucket(bucket) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "bucket must be one of hour, day, week, month",
+		})
+	}
+	if bucket == "hour" && to.Sub(from) > maxHourlyRange {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "bucket=hour is limited to a range of 31 days",
+		})
+	}
+	top := c.QueryInt("top", defaultTopUniProt)
+	if top < 0 || top > maxTopUniProt {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("top must be between 0 and %d", maxTopUniProt),
+		})
+	}
+	bins := c.QueryInt("bins", defaultScoreBins)
+	if bins < 1 || bins > maxScoreBins {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("bins must be between 1 and %d", maxScoreBins),
+		})
+	}
+
+	filter := storage.StatsFilter{
+		From:      from,
+		To:        to,
+		SessionID: r.listSessionID(c),
+		Bucket:    bucket,
+		Location:  loc,
+		TopN:      top,
+		Bins:      bins,
+	}
+	stats, err := r.db.AnalysisStats(c.UserContext(), filter)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	topUniProt := make([]fiber.Map, 0, len(stats.TopUniProt))
+	for _, u := range stats.TopUniProt {
+		topUniProt = append(topUniProt, fiber.Map{"uniprot_id": u.UniProtID, "count": u.Count})
+	}
+	buckets := make([]fiber.Map, 0, len(stats.Buckets))
+	for _, b := range stats.Buckets {
+		buckets = append(buckets, fiber.Map{
+			"start":        b.Start.Format(time.RFC3339),
+			"total":        b.Total,
+			"done":         b.Done,
+			"failed":       b.Failed,
+			"cancelled":    b.Cancelled,
+			"success_rate": successRate(b.Done, b.Failed, b.Cancelled),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"from":            formatTime(from),
+		"to":              formatTime(to),
+		"timezone":        loc.String(),
+		"bucket":          bucket,
+		"total":           stats.Total,
+		"by_status":       stats.ByStatus,
+		"by_method":       stats.ByMethod,
+		"success_rate":    successRate(stats.ByStatus["done"], stats.ByStatus["failed"], stats.ByStatus["cancelled"]),
+		"mean_score":      scoreDistributionResponse(stats.MeanScore),
+		"top_uniprot_ids": topUniProt,
+		"buckets":         buckets,
+	})
+}
+
+// successRate 終了した解析のうち完了した割合（終了した解析がない場合はnil）
+func successRate(done, failed, cancelled int) *float64 {
+	finished := done + failed + cancelled
+	if finished == 0 {
+		return nil
+	}
+	rate := float64(done) / float64(finished)
+	return &rate
+}
+
+// scoreDistributionResponse mean_scoreの分布（完了した解析がない場合はcountのみ）
+func scoreDistributionResponse(d storage.ScoreDistribution) fiber.Map {
+	response := fiber.Map{
+		"count": d.Count,
+	}
+	if d.Count == 0 {
+		return response
+	}
+	histogram := make([]fiber.Map, 0, len(d.Histogram))
+	for _, bin := range d.Histogram {
+		histogram = append(histogram, fiber.Map{"lower": bin.Lower, "upper": bin.Upper, "count": bin.Count})
+	}
+	response["mean"] = d.Mean
+	response["min"] = d.Min
+	response["max"] = d.Max
+	response["p25"] = d.P25
+	response["median"] = d.Median
+	response["p75"] = d.P75
+	response["histogram"] = histogram
+	return response
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// structureReport structures.jsonの内容（PythonのDSAパイプラインが解析完了時に出力する）
+type structureReport struct {
+	Threshold  float64          `json:"threshold"`
+	Structures []structureEntry `json:"structures"`
+	Outliers   []string         `json:"outliers"`
+}
+
+// structureEntry 解析に使用したPDBエントリ
+type structureEntry struct {
+	PDBID      string   `json:"pdb_id"`
+	Method     *string  `json:"method"`
+	Resolution *float64 `json:"resolution"`
+	Chains     []string `json:"chains"`
+	// 残基ペア距離の全体平均からのずれ（|距離 - 平均| / 標準偏差 の平均）
+	Deviation    float64 `json:"deviation"`
+	MeanAbsDiff  float64 `json:"mean_abs_diff"`
+	OutlierScore float64 `json:"outlier_score"`
+	Outlier      bool    `json:"outlier"`
+}
+
+// getAnalysisStructures 解析に使用したPDBエントリごとの分解能・手法・外れ値フラグを返す
+// 外れ値はsuggested_negative_pdbidとしてまとめて返し、次回の解析のnegative_pdbidにそのまま指定できる
+func (r *Routes) getAnalysisStructures(c *fiber.Ctx) error {
+	id := c.Params("id")
+	target, err := r.loadShareTarget(c.UserContext(), id)
+	if err != nil {
+		if ctxErr := c.UserContext().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	data, err := r.loadArtifact(c.UserContext(), target.ID, "structures.json", nil)
+	if err != nil {
+		// レポートの出力以前に実行された解析には存在しない
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Structure report not found (re-run the analysis to generate it)",
+		})
+	}
+	var report structureReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to parse structure report",
+		})
+	}
+	if report.Structures == nil {
+		report.Structures = []structureEntry{}
+	}
+	if report.Outliers == nil {
+		report.Outliers = []string{}
+	}
+
+	return c.JSON(fiber.Map{
+		"analysis_id":              target.ID,
+		"uniprot_id":               target.UniProtID,
+		"threshold":                report.Threshold,
+		"structures":               report.Structures,
+		"outliers":                 report.Outliers,
+		"suggested_negative_pdbid": strings.Join(report.Outliers, ","),
+	})
+}
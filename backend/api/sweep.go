@@ -0,0 +1,219 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// パラメータスイープで1つのパラメータに指定できる値の数
+	maxSweepValues = 20
+	// パラメータスイープで作成できる解析の数（組み合わせの数）
+	maxSweepCombinations = 50
+)
+
+// sweepCombinations 配列で指定したパラメータの値の組み合わせごとのオーバーライドと、配列で指定したパラメータ（配列がない場合はnil）
+// 配列でないパラメータはすべての組み合わせに含める
+func sweepCombinations(overrides map[string]interface{}) ([]string, []map[string]interface{}, error) {
+	keys := make([]string, 0)
+	for key, value := range overrides {
+		if _, ok := value.([]interface{}); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	sort.Strings(keys)
+
+	total := 1
+	for _, key := range keys {
+		values := overrides[key].([]interface{})
+		if len(values) == 0 || len(values) > maxSweepValues {
+			return nil, nil, fmt.Errorf("%s must have 1 to %d values", key, maxSweepValues)
+		}
+		total *= len(values)
+		if total > maxSweepCombinations {
+			return nil, nil, fmt.Errorf("At most %d combinations can be run in a sweep", maxSweepCombinations)
+		}
+	}
+
+	fixed := make(map[string]interface{})
+	for key, value := range overrides {
+		if _, ok := value.([]interface{}); !ok {
+			fixed[key] = value
+		}
+	}
+	combinations := []map[string]interface{}{fixed}
+	for _, key := range keys {
+		values := overrides[key].([]interface{})
+		next := make([]map[string]interface{}, 0, len(combinations)*len(values))
+		for _, base := range combinations {
+			for _, value := range values {
+				combination := make(map[string]interface{}, len(base)+1)
+				for k, v := range base {
+					combination[k] = v
+				}
+				combination[key] = value
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+	return keys, combinations, nil
+}
+
+// rerunSweep 組み合わせごとに解析を再実行し、スイープのIDと作成した解析を返す
+// すべての組み合わせを検証してから作成する（作成に失敗した組み合わせはfailedで返し、1件も作成できない場合はエラー）
+// ジョブの作成のリクエスト数の制限は組み合わせの数だけ消費する（足りない場合は1件も作成せずに429）
+func (r *Routes) rerunSweep(c *fiber.Ctx, id, uniprotID string, base jobs.AnalysisParams, keys []string, combinations []map[string]interface{}) error {
+	paramsList := make([]jobs.AnalysisParams, 0, len(combinations))
+	for _, overrides := range combinations {
+		params := base
+		if err := params.Apply(overrides); err != nil {
+			return invalidParams(c, "", err)
+		}
+		if _, ok := overrides["resume_from"]; !ok {
+			params.ResumeFrom = id
+		}
+		paramsList = append(paramsList, params)
+	}
+	// 1回分はミドルウェア（jobRateLimit）で消費済み。バケットの容量を超える場合は待っても受け付けられないため、Retry-Afterを返さない
+	if r.jobRate != nil && float64(len(paramsList)) > r.jobRate.burst {
+		r.jobRate.rejected.Add(1)
+		return c.Status(429).JSON(fiber.Map{
+			"error": fmt.Sprintf("A sweep of %d combinations exceeds the job rate limit of %d requests at once", len(paramsList), int(r.jobRate.burst)),
+		})
+	}
+	if ok, err := consumeRateLimit(c, r.jobRate, r.egressKeys(c), len(paramsList)-1); !ok {
+		return err
+	}
+
+	sweepID := uuid.New().String()
+	analyses := make([]fiber.Map, 0, len(paramsList))
+	failed := make([]fiber.Map, 0)
+	var firstErr error
+	for i, params := range paramsList {
+		swept := make(fiber.Map, len(keys))
+		for _, key := range keys {
+			swept[key] = combinations[i][key]
+		}
+		params.SweepID = sweepID
+		job, _, err := r.jobManager.CreateJobWithOptions(uniprotID, params, jobs.CreateJobOptions{NoCache: true, RerunOf: id, RequestID: RequestID(c)})
+		if err != nil {
+			requestLog(c).Warnf("Failed to create sweep job for %s (%v): %v", id, swept, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed = append(failed, fiber.Map{"params": swept, "error": err.Error()})
+			continue
+		}
+		r.linkAnalysisUser(c, job.ID)
+		analyses = append(analyses, fiber.Map{"analysis_id": job.ID, "params": swept})
+	}
+	if len(analyses) == 0 {
+		return jobCreateError(c, firstErr)
+	}
+
+	return c.JSON(fiber.Map{
+		"sweep_id":    sweepID,
+		"parameters":  keys,
+		"analyses":    analyses,
+		"failed":      failed,
+		"compare_url": fmt.Sprintf("/api/sweeps/%s", sweepID),
+	})
+}
+
+// sweepVariedParams スイープの解析の間で値が異なるパラメータ（名前の順）
+func sweepVariedParams(params []map[string]interface{}) []string {
+	varied := make([]string, 0)
+	if len(params) == 0 {
+		return varied
+	}
+	keys := make(map[string]bool)
+	for _, p := range params {
+		for key := range p {
+			keys[key] = true
+		}
+	}
+	for key := range keys {
+		for _, p := range params[1:] {
+			if !reflect.DeepEqual(p[key], params[0][key]) {
+				varied = append(varied, key)
+				break
+			}
+		}
+	}
+	sort.Strings(varied)
+	return varied
+}
+
+// getSweep GET /api/sweeps/:id パラメータスイープで作成した解析を、異なるパラメータの値と指標で並べて返す（作成した順）
+func (r *Routes) getSweep(c *fiber.Ctx) error {
+	if r.db == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Database not configured",
+		})
+	}
+
+	sweepID := c.Params("id")
+	filters := map[string]interface{}{
+		storage.FilterSweep: sweepID,
+		storage.FilterSort:  []storage.SortKey{{Field: "created_at"}},
+		"limit":             maxSweepCombinations,
+	}
+	if sessionID := r.listSessionID(c); sessionID != "" {
+		filters["session_id"] = sessionID
+	}
+	records, err := r.listAnalysisRecords(c.UserContext(), filters)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if len(records) == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Sweep not found",
+		})
+	}
+
+	params := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		params = append(params, jobs.AnalysisParamsFromMap(record.Params).Map())
+	}
+	varied := sweepVariedParams(params)
+
+	analyses := make([]fiber.Map, 0, len(records))
+	for i, record := range records {
+		swept := make(fiber.Map, len(varied))
+		for _, key := range varied {
+			swept[key] = params[i][key]
+		}
+		analysis := fiber.Map{
+			"id":         record.ID,
+			"status":     record.Status,
+			"created_at": formatTime(record.CreatedAt),
+			"params":     swept,
+		}
+		if record.Metrics != nil {
+			analysis["metrics"] = record.Metrics
+		}
+		if record.ErrorMessage != nil && *record.ErrorMessage != "" {
+			analysis["error_message"] = *record.ErrorMessage
+		}
+		analyses = append(analyses, analysis)
+	}
+
+	return c.JSON(fiber.Map{
+		"sweep_id":   sweepID,
+		"uniprot_id": records[0].UniProtID,
+		"parameters": varied,
+		"analyses":   analyses,
+	})
+}
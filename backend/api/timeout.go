@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ルートごとのデフォルトのタイムアウト
+const (
+	defaultRouteTimeout = 30 * time.Second
+	// R2からの取得や一括処理など時間のかかるルート用
+	defaultLongRouteTimeout = 2 * time.Minute
+)
+
+// SetRouteTimeouts ルートのタイムアウトを設定する（0以下はデフォルト値）
+func (r *Routes) SetRouteTimeouts(timeout, longTimeout time.Duration) {
+	if timeout > 0 {
+		r.routeTimeout = timeout
+	}
+	if longTimeout > 0 {
+		r.longRouteTimeout = longTimeout
+	}
+}
+
+// withTimeout ハンドラーに期限付きのコンテキストを渡し、期限切れの場合は504を返す
+// ハンドラーはc.UserContext()をDB・R2の呼び出しに渡す
+func withTimeout(timeout time.Duration, handler fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := handler(c)
+		// 期限切れが原因のエラーレスポンスは504に置き換える（フォールバックで成功した場合はそのまま返す）
+		if errors.Is(err, context.DeadlineExceeded) ||
+			(errors.Is(ctx.Err(), context.DeadlineExceeded) && c.Response().StatusCode() >= 400) {
+			logging.Warnf("Request timed out after %s: %s %s", timeout, c.Method(), c.OriginalURL())
+			return c.Status(504).JSON(fiber.Map{
+				"error": "Request timed out",
+			})
+		}
+		return err
+	}
+}
+
+// getAnalysisRecord リクエストの期限内でDBから解析レコードを取得する
+func (r *Routes) getAnalysisRecord(ctx context.Context, id string) (*storage.AnalysisRecord, error) {
+	return r.db.GetAnalysisContext(ctx, id)
+}
+
+// listAnalysisRecords リクエストの期限内でDBから解析レコードの一覧を取得する
+func (r *Routes) listAnalysisRecords(ctx context.Context, filters map[string]interface{}) ([]*storage.AnalysisRecord, error) {
+	return r.db.SearchAnalyses(ctx, filters)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 日別の集計で遡る日数（デフォルトと上限）
+const (
+	defaultStatsDays = 7
+	maxStatsDays     = 90
+)
+
+// 日別の集計で読み込むジョブの上限
+const statsScanLimit = 10000
+
+// SetDefaultTimezone tzクエリを指定しない場合のタイムゾーン（日付のみの絞り込み・日別の集計に使う、デフォルトはUTC）
+func (r *Routes) SetDefaultTimezone(loc *time.Location) {
+	r.defaultLocation = loc
+}
+
+// requestLocation tzクエリ（例: Asia/Tokyo）のタイムゾーン、なければデフォルト
+func (r *Routes) requestLocation(c *fiber.Ctx) (*time.Location, error) {
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tz: %s", tz)
+		}
+		return loc, nil
+	}
+	if r.defaultLocation != nil {
+		return r.defaultLocation, nil
+	}
+	return time.UTC, nil
+}
+
+// parseTimeFilter from/to等の日時の絞り込みを解釈する
+// RFC3339（オフセット付き）はそのまま、オフセットのない日時・日付のみはlocの時刻として解釈する
+// 日付のみの場合、endOfDayがtrueならその日の終わり（to用）、falseなら始まり（from用）
+func parseTimeFilter(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", value, loc); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC3339 (e.g. 2026-10-18T09:00:00+09:00) or a date (2026-10-18)")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t.UTC(), nil
+}
+
+// formatTime レスポンスの日時（常にUTCのRFC3339）
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// getDailyStats GET /api/stats/daily tzの日付ごとにジョブの作成数をステータス別に集計する（新しい日付順、DBがなくても動作する）
+// 「今日のジョブ」を利用者の日付で数えるため、日付の区切りはtz（デフォルトはDEFAULT_TIMEZONE）で決める
+func (r *Routes) getDailyStats(c *fiber.Ctx) error {
+	loc, err := r.requestLocation(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	days := c.QueryInt("days", defaultStatsDays)
+	if days <= 0 || days > maxStatsDays {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("days must be between 1 and %d", maxStatsDays),
+		})
+	}
+
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	start := today.AddDate(0, 0, -(days - 1))
+
+	type bucket struct {
+		Date     string         `json:"date"`
+		Total    int            `json:"total"`
+		ByStatus map[string]int `json:"by_status"`
+	}
+	buckets := make(map[string]*bucket, days)
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		buckets[date] = &bucket{Date: date, ByStatus: make(map[string]int)}
+	}
+
+	// 開始日の0時ちょうどに作成されたジョブも含める
+	filter := jobs.JobListFilter{
+		CreatedAfter: start.Add(-time.Nanosecond),
+		SessionID:    r.listSessionID(c),
+		Limit:        jobs.MaxJobListLimit,
+	}
+	scanned := 0
+	truncated := false
+	for {
+		page, err := r.jobManager.ListJobs(c.UserContext(), filter)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		for _, job := range page.Jobs {
+			if b, ok := buckets[job.CreatedAt.In(loc).Format("2006-01-02")]; ok {
+				b.Total++
+				b.ByStatus[string(job.Status)]++
+			}
+		}
+		scanned += len(page.Jobs)
+		if page.NextCursor == "" {
+			break
+		}
+		if scanned >= statsScanLimit {
+			truncated = true
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	result := make([]*bucket, 0, len(buckets))
+	for _, b := range buckets {
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date > result[j].Date
+	})
+
+	response := fiber.Map{
+		"timezone": loc.String(),
+		"today":    today.Format("2006-01-02"),
+		"days":     result,
+	}
+	if truncated {
+		response["truncated"] = true
+	}
+	return c.JSON(response)
+}
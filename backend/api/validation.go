@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bytes"
+	"dsa-api/alerts"
+	"dsa-api/jobs"
+	"dsa-api/webhooks"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fieldType JSONの値の型
+type fieldType string
+
+const (
+	typeString  fieldType = "string"
+	typeNumber  fieldType = "number"
+	typeInteger fieldType = "integer"
+	typeBoolean fieldType = "boolean"
+	typeObject  fieldType = "object"
+	typeArray   fieldType = "array"
+)
+
+// fieldSchema 1フィールドの検証ルール
+type fieldSchema struct {
+	Type     fieldType
+	Required bool
+	NonEmpty bool
+	Enum     []string
+	Min      *float64
+	Max      *float64
+	// Type が typeObject の場合の中身
+	Properties *objectSchema
+	// Type が typeArray の場合の要素
+	Items *fieldSchema
+}
+
+// objectSchema JSONオブジェクトの検証ルール（定義されていないフィールドはエラー）
+type objectSchema map[string]fieldSchema
+
+// FieldError フィールドごとの検証エラー
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// createJobSchema POST /api/jobs
+var createJobSchema = objectSchema{
+	"uniprot_id":    {Type: typeString, Required: true, NonEmpty: true},
+	"params":        {Type: typeObject}, // 中身はjobs.ParseAnalysisParamsで検証する（422）
+	"dedupe":        {Type: typeBoolean},
+	"no_cache":      {Type: typeBoolean},
+	"dry_run":       {Type: typeBoolean},
+	"check_uniprot": {Type: typeBoolean}, // dry_runの場合のみ（UniProtへの存在確認）
+	"notify_email":  {Type: typeString},
+}
+
+// createWebhookSchema POST /api/webhooks
+var createWebhookSchema = objectSchema{
+	"url":    {Type: typeString, Required: true, NonEmpty: true},
+	"events": {Type: typeArray, Items: &fieldSchema{Type: typeString, Enum: webhooks.Events}},
+	"digest": {Type: typeString, Enum: webhooks.Digests},
+}
+
+// updateWebhookSchema PATCH /api/webhooks/:id
+var updateWebhookSchema = objectSchema{
+	"digest": {Type: typeString, Required: true, Enum: webhooks.Digests},
+}
+
+// createAlertSchema POST /api/alerts
+var createAlertSchema = objectSchema{
+	"name":       {Type: typeString},
+	"metric":     {Type: typeString, Required: true, Enum: alerts.Metrics},
+	"condition":  {Type: typeString, Required: true, Enum: alerts.Conditions},
+	"threshold":  {Type: typeNumber, Required: true},
+	"baseline":   {Type: typeString, Enum: alerts.Baselines},
+	"direction":  {Type: typeString, Enum: alerts.Directions},
+	"uniprot_id": {Type: typeString},
+	"enabled":    {Type: typeBoolean},
+}
+
+// updateAlertSchema PATCH /api/alerts/:id
+var updateAlertSchema = objectSchema{
+	"name":       {Type: typeString},
+	"threshold":  {Type: typeNumber},
+	"direction":  {Type: typeString, Enum: alerts.Directions},
+	"uniprot_id": {Type: typeString},
+	"enabled":    {Type: typeBoolean},
+}
+
+// claimJobSchema POST /api/internal/jobs/claim
+var claimJobSchema = objectSchema{
+	"worker_id": {Type: typeString, Required: true, NonEmpty: true},
+}
+
+// validateBody POSTボディをスキーマで検証し、エラーをまとめて400で返す
+// allowEmptyがtrueの場合、空のボディは検証せずに通す
+func validateBody(schema objectSchema, allowEmpty bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := bytes.TrimSpace(c.Body())
+		if len(body) == 0 {
+			if allowEmpty {
+				return c.Next()
+			}
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Request body is required",
+			})
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid JSON: %v", err),
+			})
+		}
+
+		if errs := schema.validate("", value); len(errs) > 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":  "Invalid request body",
+				"fields": errs,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// invalidParams パラメータの検証エラー（jobs.ParamsError）を422で返す（prefixはフィールド名に付ける階層）
+func invalidParams(c *fiber.Ctx, prefix string, err error) error {
+	var paramsErr *jobs.ParamsError
+	if !errors.As(err, &paramsErr) {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	fields := make([]FieldError, 0, len(paramsErr.Errors))
+	for _, fe := range paramsErr.Errors {
+		fields = append(fields, FieldError{Field: fieldPath(prefix, fe.Field), Message: fe.Message})
+	}
+	return c.Status(422).JSON(fiber.Map{
+		"error":  "Invalid params",
+		"fields": fields,
+	})
+}
+
+// validate valueを検証し、すべてのフィールドエラーを返す
+func (s objectSchema) validate(path string, value interface{}) []FieldError {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		field := path
+		if field == "" {
+			field = "body"
+		}
+		return []FieldError{{Field: field, Message: "must be an object"}}
+	}
+
+	var errs []FieldError
+	for name, field := range s {
+		v, exists := obj[name]
+		if !exists || v == nil {
+			if field.Required {
+				errs = append(errs, FieldError{Field: fieldPath(path, name), Message: "is required"})
+			}
+			continue
+		}
+		errs = append(errs, field.validate(fieldPath(path, name), v)...)
+	}
+	for name := range obj {
+		if _, known := s[name]; !known {
+			errs = append(errs, FieldError{Field: fieldPath(path, name), Message: "is not a known field"})
+		}
+	}
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Field < errs[j].Field
+	})
+	return errs
+}
+
+func (f fieldSchema) validate(path string, value interface{}) []FieldError {
+	fail := func(format string, args ...interface{}) []FieldError {
+		return []FieldError{{Field: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	switch f.Type {
+	case typeString:
+		s, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if f.NonEmpty && strings.TrimSpace(s) == "" {
+			return fail("must not be empty")
+		}
+		if len(f.Enum) > 0 && !containsString(f.Enum, s) {
+			return fail("must be one of %s", strings.Join(f.Enum, ", "))
+		}
+	case typeNumber, typeInteger:
+		n, ok := value.(float64)
+		if !ok {
+			return fail("must be a number")
+		}
+		if f.Type == typeInteger && n != math.Trunc(n) {
+			return fail("must be an integer")
+		}
+		if f.Min != nil && n < *f.Min {
+			return fail("must be >= %g", *f.Min)
+		}
+		if f.Max != nil && n > *f.Max {
+			return fail("must be <= %g", *f.Max)
+		}
+	case typeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	case typeObject:
+		if f.Properties == nil {
+			if _, ok := value.(map[string]interface{}); !ok {
+				return fail("must be an object")
+			}
+			return nil
+		}
+		return f.Properties.validate(path, value)
+	case typeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fail("must be an array")
+		}
+		if f.Items == nil {
+			return nil
+		}
+		var errs []FieldError
+		for i, item := range items {
+			errs = append(errs, f.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+		return errs
+	}
+	return nil
+}
+
+func fieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package api
+
+import (
+	"dsa-api/jobs"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// addVariant アイソフォーム・チェーンを指定した解析に対象のID（"P69905-2:A" 等）を追加する（正規の配列のすべてのチェーンは追加しない）
+func addVariant(summary fiber.Map, uniprotID string, params map[string]interface{}) {
+	if variant := jobs.VariantID(uniprotID, jobs.AnalysisParamsFromMap(params)); variant != uniprotID {
+		summary["variant"] = variant
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const (
+	// バージョンを指定したAPIのレスポンスヘッダー
+	apiVersionHeader = "X-API-Version"
+	apiVersionKey    = "api_version"
+)
+
+// 対応しているAPIのバージョン（互換性のない変更はv2として追加し、/api/v1と従来の/apiはそのまま残す）
+var apiVersions = map[string]bool{
+	"v1": true,
+}
+
+// /api/v1/... のパス（バージョンと残りのパス）
+var versionedPathPattern = regexp.MustCompile(`^/api/(v[0-9]+)(/.*)?$`)
+
+// envelope バージョンを指定したAPIのレスポンス（成功時はdata、失敗時はerrorのみ値を持つ）
+type envelope struct {
+	Data  json.RawMessage `json:"data"`
+	Error *envelopeError  `json:"error"`
+	Meta  envelopeMeta    `json:"meta"`
+}
+
+// envelopeError エラーの内容（従来のレスポンスのerror以外のフィールドはdetailsに入れる）
+type envelopeError struct {
+	Status  int                        `json:"status"`
+	Message string                     `json:"message"`
+	Details map[string]json.RawMessage `json:"details,omitempty"`
+}
+
+type envelopeMeta struct {
+	APIVersion string `json:"api_version"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// versionedAPI /api/v1/... を従来の /api/... のルートで処理し、JSONのレスポンスをエンベロープ（data/error/meta）で返す
+// パスを書き換えるため、ルート・ミドルウェアはバージョンごとに定義しなくてよい（従来の /api/... のレスポンスは変更しない）
+func (r *Routes) versionedAPI(c *fiber.Ctx) error {
+	m := versionedPathPattern.FindStringSubmatch(c.Path())
+	if m == nil {
+		return c.Next()
+	}
+	version := m[1]
+	c.Locals(apiVersionKey, version)
+	c.Set(apiVersionHeader, version)
+	if !apiVersions[version] {
+		c.Status(404).JSON(fiber.Map{
+			"error": "unsupported API version: " + version,
+		})
+		wrapEnvelope(c, version)
+		return nil
+	}
+	c.Path("/api" + m[2])
+
+	if err := c.Next(); err != nil {
+		// エラーハンドラーはミドルウェアの後に呼ばれるため、エンベロープで返せるようにここで呼ぶ
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+	wrapEnvelope(c, version)
+	return nil
+}
+
+// apiVersion リクエストで指定されたAPIのバージョン（従来の /api/... の場合は空）
+func apiVersion(c *fiber.Ctx) string {
+	version, _ := c.Locals(apiVersionKey).(string)
+	return version
+}
+
+// wrapEnvelope JSONのレスポンスをエンベロープに変換する（ファイル・ストリーム・WebSocketなどJSON以外は変更しない）
+func wrapEnvelope(c *fiber.Ctx, version string) {
+	resp := c.Response()
+	if resp.IsBodyStream() || !bytes.HasPrefix(resp.Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
+		return
+	}
+	body := resp.Body()
+	status := resp.StatusCode()
+	out := envelope{Meta: envelopeMeta{APIVersion: version, RequestID: RequestID(c)}}
+	if status < 400 {
+		out.Data = json.RawMessage(bytes.Clone(body))
+	} else {
+		out.Error = envelopeErrorFromBody(status, body)
+	}
+	encoded, err := json.Marshal(out)
+	if err != nil {
+		return
+	}
+	resp.SetBody(encoded)
+}
+
+// envelopeErrorFromBody 従来のエラーレスポンス（{"error": "...", ...}）をエンベロープのerrorに変換する
+func envelopeErrorFromBody(status int, body []byte) *envelopeError {
+	e := &envelopeError{Status: status, Message: utils.StatusMessage(status)}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return e
+	}
+	if raw, ok := fields["error"]; ok {
+		var message string
+		if err := json.Unmarshal(raw, &message); err == nil {
+			e.Message = message
+			delete(fields, "error")
+		}
+	}
+	// リクエストIDはmetaで返す
+	delete(fields, requestIDKey)
+	if len(fields) > 0 {
+		e.Details = fields
+	}
+	return e
+}
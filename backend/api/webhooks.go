@@ -0,0 +1,113 @@
+package api
+
+import (
+	"dsa-api/webhooks"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// イベントをまとめて配信する期間（immediate・hourly・daily、省略時はimmediate）
+	Digest string `json:"digest"`
+}
+
+type UpdateWebhookRequest struct {
+	Digest string `json:"digest"`
+}
+
+// SetWebhooks Webhookの配信を有効にする
+func (r *Routes) SetWebhooks(dispatcher *webhooks.Dispatcher) {
+	r.webhooks = dispatcher
+}
+
+// requireWebhooks Webhookが無効の場合は503を返す
+func (r *Routes) requireWebhooks(c *fiber.Ctx) error {
+	if r.webhooks == nil {
+		return c.Status(503).JSON(fiber.Map{
+			"error": "Webhooks not configured",
+		})
+	}
+	return c.Next()
+}
+
+// webhookError Dispatcherのエラーをレスポンスに変換する
+func webhookError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, webhooks.ErrEndpointNotFound) || errors.Is(err, webhooks.ErrDeliveryNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// createWebhook セッションのジョブの終了を通知するWebhookを登録する
+// 署名用の鍵（secret）は作成時のレスポンスでのみ返す
+func (r *Routes) createWebhook(c *fiber.Ctx) error {
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	endpoint, err := r.webhooks.CreateEndpoint(c.UserContext(), ensureSessionID(c), req.URL, req.Events, req.Digest)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(201).JSON(endpoint)
+}
+
+// updateWebhook Webhookのダイジェストの期間を変更する
+func (r *Routes) updateWebhook(c *fiber.Ctx) error {
+	var req UpdateWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	endpoint, err := r.webhooks.SetEndpointDigest(c.Cookies("dsa_session_id"), c.Params("id"), req.Digest)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(endpoint)
+}
+
+func (r *Routes) listWebhooks(c *fiber.Ctx) error {
+	return c.JSON(r.webhooks.ListEndpoints(c.Cookies("dsa_session_id")))
+}
+
+func (r *Routes) deleteWebhook(c *fiber.Ctx) error {
+	if err := r.webhooks.DeleteEndpoint(c.Cookies("dsa_session_id"), c.Params("id")); err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"message":    "Webhook deleted successfully",
+		"webhook_id": c.Params("id"),
+	})
+}
+
+// listWebhookDeliveries 配信記録（新しい順、デバッグ用）
+func (r *Routes) listWebhookDeliveries(c *fiber.Ctx) error {
+	deliveries, err := r.webhooks.Deliveries(c.Cookies("dsa_session_id"), c.Params("id"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(deliveries)
+}
+
+// redeliverWebhook デッドレターになった配信などを再送する
+func (r *Routes) redeliverWebhook(c *fiber.Ctx) error {
+	delivery, err := r.webhooks.Redeliver(c.Cookies("dsa_session_id"), c.Params("id"), c.Params("deliveryId"))
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(delivery)
+}
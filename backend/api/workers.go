@@ -0,0 +1,126 @@
+package api
+
+import (
+	"crypto/subtle"
+	"dsa-api/jobs"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ClaimJobRequest struct {
+	WorkerID string `json:"worker_id"`
+}
+
+// WorkerReportRequest 進捗はJSON、成果物を伴う完了・失敗の報告はmultipart/form-dataで送る
+type WorkerReportRequest struct {
+	WorkerID string `json:"worker_id" form:"worker_id"`
+	Status   string `json:"status" form:"status"`
+	Progress *int   `json:"progress" form:"progress"`
+	Message  string `json:"message" form:"message"`
+	Error    string `json:"error" form:"error"`
+}
+
+// SetRemoteWorkers リモートワーカー用の内部API（/api/internal/jobs）を有効にする
+func (r *Routes) SetRemoteWorkers(executor *jobs.RemoteExecutor, token string) {
+	r.remote = executor
+	r.workerToken = token
+}
+
+// requireWorker リモートワーカーのトークン（Authorization: Bearer <WORKER_TOKEN>）を検証する
+func (r *Routes) requireWorker(c *fiber.Ctx) error {
+	if r.remote == nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Remote workers are not enabled",
+		})
+	}
+	token := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	if r.workerToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(r.workerToken)) != 1 {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid worker token",
+		})
+	}
+	return c.Next()
+}
+
+// remoteError RemoteExecutorのエラーをレスポンスに変換する
+// 410/409を受け取ったワーカーは処理を中断する
+func remoteError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, jobs.ErrRemoteTaskNotFound):
+		return c.Status(410).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, jobs.ErrRemoteLeaseLost):
+		return c.Status(409).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(400).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// claimJob 割り当て待ちのジョブを1件ワーカーに割り当てる（なければ204）
+func (r *Routes) claimJob(c *fiber.Ctx) error {
+	var req ClaimJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	claimed := r.remote.Claim(req.WorkerID)
+	if claimed == nil {
+		return c.SendStatus(204)
+	}
+	return c.JSON(claimed)
+}
+
+// reportJob ワーカーからの進捗（ハートビートを兼ねる）・完了・失敗の報告を受け付ける
+// 完了・失敗の場合は添付された成果物をジョブディレクトリに保存してからManagerに返す
+func (r *Routes) reportJob(c *fiber.Ctx) error {
+	jobID := c.Params("id")
+	var req WorkerReportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.WorkerID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "worker_id is required",
+		})
+	}
+
+	if form, err := c.MultipartForm(); err == nil && (req.Status == "done" || req.Status == "failed") {
+		jobDir, err := r.remote.TaskDir(jobID, req.WorkerID)
+		if err != nil {
+			return remoteError(c, err)
+		}
+		for _, name := range jobs.ArtifactNames() {
+			files := form.File[name]
+			if len(files) == 0 {
+				continue
+			}
+			if err := c.SaveFile(files[0], filepath.Join(jobDir, name)); err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error": "Failed to save " + name,
+				})
+			}
+		}
+	}
+
+	err := r.remote.Report(jobID, req.WorkerID, jobs.WorkerReport{
+		Status:   req.Status,
+		Progress: req.Progress,
+		Message:  req.Message,
+		Error:    req.Error,
+	})
+	if err != nil {
+		return remoteError(c, err)
+	}
+	return c.SendStatus(204)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"dsa-api/jobs"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// wsMessage クライアントから受信するメッセージ
+// {"action": "subscribe", "job_ids": ["..."]} / {"action": "subscribe_session"} / {"action": "unsubscribe", "job_ids": ["..."]}
+type wsMessage struct {
+	Action string   `json:"action"`
+	JobIDs []string `json:"job_ids"`
+}
+
+// wsSubscription 接続ごとの購読状態
+type wsSubscription struct {
+	jobIDs    map[string]bool
+	session   bool
+	sessionID string
+}
+
+func (s *wsSubscription) matches(update jobs.JobUpdate) bool {
+	if s.jobIDs[update.JobID] {
+		return true
+	}
+	return s.session && s.sessionID != "" && update.SessionID == s.sessionID
+}
+
+// wsUpgrade WebSocketへのアップグレード要求のみ通す
+func (r *Routes) wsUpgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	// Cookieはアップグレード後に参照できないため、ここで保存しておく
+	c.Locals("session_id", r.requestSessionID(c))
+	return c.Next()
+}
+
+// handleWS 複数ジョブの進捗をプッシュ配信する
+// クエリパラメータ ?job_ids=a,b や ?session=true で接続時に購読することもできる
+func (r *Routes) handleWS(conn *websocket.Conn) {
+	updates, unsubscribe := r.jobManager.Subscribe()
+	defer unsubscribe()
+
+	sub := &wsSubscription{jobIDs: make(map[string]bool)}
+	if sid, ok := conn.Locals("session_id").(string); ok {
+		sub.sessionID = sid
+	}
+
+	// 接続時のクエリパラメータによる購読
+	initial := wsMessage{Action: "subscribe"}
+	for _, id := range strings.Split(conn.Query("job_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			initial.JobIDs = append(initial.JobIDs, id)
+		}
+	}
+	if len(initial.JobIDs) > 0 {
+		if err := r.applyWSMessage(conn, sub, initial); err != nil {
+			return
+		}
+	}
+	if conn.Query("session") == "true" {
+		if err := r.applyWSMessage(conn, sub, wsMessage{Action: "subscribe_session"}); err != nil {
+			return
+		}
+	}
+
+	// 受信は別goroutineで行い、書き込みはこのgoroutineに集約する
+	messages := make(chan wsMessage)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-messages:
+			if err := r.applyWSMessage(conn, sub, msg); err != nil {
+				return
+			}
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if !sub.matches(update) {
+				continue
+			}
+			if err := conn.WriteJSON(fiber.Map{"type": "update", "job": update}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// applyWSMessage 購読状態を更新し、新たに購読したジョブの現在の状態を送信する
+func (r *Routes) applyWSMessage(conn *websocket.Conn, sub *wsSubscription, msg wsMessage) error {
+	switch msg.Action {
+	case "subscribe":
+		for _, id := range msg.JobIDs {
+			sub.jobIDs[id] = true
+			snapshot, err := r.jobManager.JobSnapshot(id)
+			if err != nil {
+				if err := conn.WriteJSON(fiber.Map{"type": "error", "job_id": id, "error": "Job not found"}); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := conn.WriteJSON(fiber.Map{"type": "update", "job": snapshot}); err != nil {
+				return err
+			}
+		}
+	case "subscribe_session":
+		if r.sessionless {
+			return conn.WriteJSON(fiber.Map{"type": "error", "error": "Session features are disabled in api_key auth mode"})
+		}
+		if sub.sessionID == "" {
+			return conn.WriteJSON(fiber.Map{"type": "error", "error": "Session cookie not found"})
+		}
+		sub.session = true
+		for _, snapshot := range r.jobManager.SessionSnapshots(sub.sessionID) {
+			if err := conn.WriteJSON(fiber.Map{"type": "update", "job": snapshot}); err != nil {
+				return err
+			}
+		}
+	case "unsubscribe":
+		for _, id := range msg.JobIDs {
+			delete(sub.jobIDs, id)
+		}
+	case "unsubscribe_session":
+		sub.session = false
+	default:
+		return conn.WriteJSON(fiber.Map{"type": "error", "error": fmt.Sprintf("Unknown action: %s", msg.Action)})
+	}
+	return nil
+}
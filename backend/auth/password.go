@@ -0,0 +1,100 @@
+// Package auth ユーザーアカウントのパスワードのハッシュとログイン用のトークン（JWT、HS256）
+// 外部ライブラリを使わない最小限の実装で、パスワードはPBKDF2-HMAC-SHA256でハッシュする
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// PBKDF2の反復回数（OWASPの推奨値）
+	passwordIterations = 600000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+	passwordScheme     = "pbkdf2-sha256"
+)
+
+// HashPassword パスワードのハッシュ（pbkdf2-sha256$<反復回数>$<salt>$<hash>）を返す
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := pbkdf2([]byte(password), salt, passwordIterations, passwordKeySize)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword パスワードがハッシュと一致するか（形式が不正な場合はfalse）
+func VerifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	key := pbkdf2([]byte(password), salt, iterations, len(expected))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// VerifyDummy 存在しないユーザーのログインでパスワードの検証と同じ時間をかける（応答時間からユーザーの有無を推測させない）
+func VerifyDummy(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("")
+	})
+	VerifyPassword(dummyHash, password)
+}
+
+// pbkdf2 RFC 8018のPBKDF2（PRFはHMAC-SHA256）
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	var counter [4]byte
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
@@ -0,0 +1,33 @@
+package auth
+
+const (
+	// RoleViewer 解析の閲覧のみ
+	RoleViewer = "viewer"
+	// RoleAnalyst 解析の作成・再実行・キャンセル・削除（ユーザー登録時と匿名のセッションのデフォルト）
+	RoleAnalyst = "analyst"
+	// RoleAdmin 管理用API（メトリクスの更新、待ち行列の一時停止、保持期間の設定、全セッションの一覧等）
+	RoleAdmin = "admin"
+)
+
+// Roles 権限の弱い順
+var Roles = []string{RoleViewer, RoleAnalyst, RoleAdmin}
+
+// ValidRole 定義済みのロールか
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// HasRole roleがrequired以上の権限を持つか（未定義のロールは権限なし）
+func HasRole(role, required string) bool {
+	rank := roleRank(role)
+	return rank >= 0 && rank >= roleRank(required)
+}
+
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
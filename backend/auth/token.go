@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken 署名・形式が不正、または期限切れのトークン
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims ログイン用のトークンの内容
+type Claims struct {
+	// ユーザーID
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+	// アカウントのセッションID（解析の一覧・所有者の確認に使う）
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Expires 有効期限
+func (c Claims) Expires() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignToken secretで署名したJWT（HS256）を返す
+func SignToken(secret []byte, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + tokenSignature(secret, signingInput), nil
+}
+
+// ParseToken トークンの署名と有効期限を検証して内容を返す（失敗した場合はErrInvalidToken）
+func ParseToken(secret []byte, token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	signingInput := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(tokenSignature(secret, signingInput))) {
+		return nil, ErrInvalidToken
+	}
+
+	// 署名済みでもHS256以外のアルゴリズムは受け付けない
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.Subject == "" || claims.SessionID == "" || !now.Before(claims.Expires()) {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+func tokenSignature(secret []byte, signingInput string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
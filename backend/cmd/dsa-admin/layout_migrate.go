@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 終了済みのステータス（実行中の解析は移行しない）
+var terminalStatuses = map[string]bool{"done": true, "failed": true, "cancelled": true}
+
+// runLayoutMigrate dsa-admin layout migrate --to 2 --limit 100 --dry-run
+// 移行中もAPIは移行前の場所を探すため、途中で止めても成果物は参照できる
+// メモリ上のジョブは移行前の保存形式を保持するため、サーバーを停止してから実行する
+func runLayoutMigrate(args []string) int {
+	fs := flag.NewFlagSet("layout migrate", flag.ContinueOnError)
+	to := fs.Int("to", 0, "移行先の保存形式のバージョン（1: flat, 2: sharded）")
+	limit := fs.Int("limit", 100, "1回で移行する解析の上限")
+	dryRun := fs.Bool("dry-run", false, "移行せずに対象の解析を表示する")
+	storageDir := fs.String("storage-dir", "", "ローカルのストレージディレクトリ（デフォルト: STORAGE_DIR、未設定時は ./storage）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	target, ok := jobs.LookupStorageLayout(*to)
+	if *to == 0 || !ok {
+		fmt.Fprintf(os.Stderr, "Invalid --to: %d (available: %s)\n", *to, layoutVersions())
+		return 2
+	}
+	if *limit <= 0 {
+		fmt.Fprintf(os.Stderr, "Invalid --limit: %d\n", *limit)
+		return 2
+	}
+	if *storageDir == "" {
+		*storageDir = os.Getenv("STORAGE_DIR")
+	}
+	if *storageDir == "" {
+		*storageDir = "./storage"
+	}
+
+	// R2（保存先）の環境変数がない場合はローカルのディレクトリのみ移動する
+	var r2 storage.ObjectStore
+	if objectStoreConfigured() {
+		client, err := newR2Client()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create R2 client: %v\n", err)
+			return 1
+		}
+		r2 = client
+	}
+
+	m := &layoutMigrator{target: target, storageDir: *storageDir, r2: r2, dryRun: *dryRun}
+	var migrated, failed int
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		db, err := storage.NewDB(databaseURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+			return 1
+		}
+		defer db.Close()
+		m.db = db
+
+		candidates, err := db.ListLayoutCandidates(target.Version, *limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list analyses (apply migrations/007_add_storage_layout.sql): %v\n", err)
+			return 1
+		}
+		for _, c := range candidates {
+			if err := m.migrateRecord(c); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to migrate %s: %v\n", c.ID, err)
+				failed++
+				continue
+			}
+			migrated++
+		}
+	} else {
+		dirs, err := m.localCandidates(*limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list local analyses: %v\n", err)
+			return 1
+		}
+		for _, d := range dirs {
+			if err := m.migrateLocal(d.id, d.dir, d.layout); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to migrate %s: %v\n", d.id, err)
+				failed++
+				continue
+			}
+			migrated++
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d analyses would be migrated to layout %d (%s)\n", migrated, target.Version, target.Name)
+		return 0
+	}
+	fmt.Printf("Layout migration completed: %d migrated, %d failed\n", migrated, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// layoutMigrator 解析の成果物を移行先の保存形式に移す
+type layoutMigrator struct {
+	target     *jobs.StorageLayout
+	storageDir string
+	db         *storage.DB
+	r2         storage.ObjectStore
+	dryRun     bool
+}
+
+// migrateRecord DBの解析を移行する
+// R2のオブジェクトを新しいプレフィックスにコピーしてからDBのキーを更新し、最後に古いオブジェクト・ディレクトリを片付ける
+func (m *layoutMigrator) migrateRecord(c *storage.LayoutCandidate) error {
+	from, ok := jobs.LookupStorageLayout(c.StorageLayout)
+	if !ok {
+		return fmt.Errorf("unknown storage layout version: %d", c.StorageLayout)
+	}
+	oldPrefix := from.Prefix(c.ID)
+	if c.R2Prefix != nil && *c.R2Prefix != "" {
+		oldPrefix = strings.TrimSuffix(*c.R2Prefix, "/")
+	}
+	newPrefix := m.target.Prefix(c.ID)
+	fmt.Printf("%s  status=%s  layout=%d->%d  r2=%s/ -> %s/\n", c.ID, c.Status, from.Version, m.target.Version, oldPrefix, newPrefix)
+	if m.dryRun {
+		return nil
+	}
+
+	record, err := m.db.GetAnalysis(c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get analysis: %w", err)
+	}
+
+	ctx := context.Background()
+	copied := 0
+	if m.r2 != nil {
+		objects, err := m.r2.ListObjectsWithPrefix(ctx, oldPrefix+"/")
+		if err != nil {
+			return fmt.Errorf("failed to list R2 objects: %w", err)
+		}
+		for _, obj := range objects {
+			dst := newPrefix + strings.TrimPrefix(obj.Key, oldPrefix)
+			if err := m.r2.CopyObject(ctx, obj.Key, dst); err != nil {
+				return fmt.Errorf("failed to copy %s: %w", obj.Key, err)
+			}
+			copied++
+		}
+	}
+
+	rekey := func(key *string) *string {
+		if key == nil {
+			return nil
+		}
+		if rest, ok := strings.CutPrefix(*key, oldPrefix+"/"); ok {
+			moved := newPrefix + "/" + rest
+			return &moved
+		}
+		return key
+	}
+	keys := storage.StorageKeys{
+		R2Prefix:   &newPrefix,
+		ResultKey:  rekey(record.ResultKey),
+		HeatmapKey: rekey(record.HeatmapKey),
+		ScatterKey: rekey(record.ScatterKey),
+		LogsKey:    rekey(record.LogsKey),
+	}
+	moved, err := m.db.MoveAnalysisStorage(c.ID, c.StorageLayout, m.target.Version, keys)
+	if err != nil {
+		return fmt.Errorf("failed to update analysis: %w", err)
+	}
+	if !moved {
+		// 他の移行と競合した（コピーしたオブジェクトは照合で孤立したものとして見つかる）
+		return fmt.Errorf("storage layout changed during migration")
+	}
+
+	if dir := from.LocalDir(m.storageDir, c.ID); dirExists(dir) {
+		if err := moveLocalDir(dir, m.target.LocalDir(m.storageDir, c.ID), m.target.Version); err != nil {
+			// APIはすべての保存形式の場所を探すため、移動できなくても参照できる
+			fmt.Fprintf(os.Stderr, "Failed to move local directory of %s: %v\n", c.ID, err)
+		}
+	}
+	if m.r2 != nil && copied > 0 {
+		if err := m.r2.DeleteObjectsWithPrefix(ctx, oldPrefix+"/"); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to delete old R2 objects of %s (run POST /api/admin/reconcile to clean up): %v\n", c.ID, err)
+		}
+	}
+	fmt.Printf("Migrated %s (%d objects)\n", c.ID, copied)
+	return nil
+}
+
+// localDir ローカルのジョブディレクトリ（DBがない場合の移行対象）
+type localDir struct {
+	id     string
+	dir    string
+	layout *jobs.StorageLayout
+}
+
+// localCandidates 移行先以外の保存形式にある終了済みのジョブディレクトリ（IDの順、limit件まで）
+func (m *layoutMigrator) localCandidates(limit int) ([]localDir, error) {
+	var candidates []localDir
+	for _, l := range jobs.StorageLayouts() {
+		if l.Version == m.target.Version {
+			continue
+		}
+		dirs, err := l.LocalAnalysisDirs(m.storageDir)
+		if err != nil {
+			return nil, err
+		}
+		for id, dir := range dirs {
+			candidates = append(candidates, localDir{id: id, dir: dir, layout: l})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].id < candidates[j].id
+	})
+
+	selected := make([]localDir, 0, min(limit, len(candidates)))
+	for _, c := range candidates {
+		if len(selected) >= limit {
+			break
+		}
+		status, err := readLocalStatus(c.dir)
+		if err != nil || !terminalStatuses[status] {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// migrateLocal DBがない場合にジョブディレクトリを移行先の保存形式の場所に移す
+func (m *layoutMigrator) migrateLocal(id, dir string, from *jobs.StorageLayout) error {
+	dst := m.target.LocalDir(m.storageDir, id)
+	fmt.Printf("%s  layout=%d->%d  %s -> %s\n", id, from.Version, m.target.Version, dir, dst)
+	if m.dryRun {
+		return nil
+	}
+	if err := moveLocalDir(dir, dst, m.target.Version); err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %s\n", id)
+	return nil
+}
+
+// moveLocalDir ジョブディレクトリを移動し、status.jsonのstorage_layoutを更新する
+func moveLocalDir(src, dst string, version int) error {
+	if dirExists(dst) {
+		return fmt.Errorf("destination already exists: %s", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	return updateLocalStatusLayout(dst, version)
+}
+
+// readLocalStatus status.jsonのステータス
+func readLocalStatus(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "status.json"))
+	if err != nil {
+		return "", err
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return "", err
+	}
+	return status.Status, nil
+}
+
+// updateLocalStatusLayout status.jsonのstorage_layoutを書き換える（status.jsonがない場合は何もしない）
+func updateLocalStatusLayout(dir string, version int) error {
+	path := filepath.Join(dir, "status.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var status map[string]interface{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return err
+	}
+	status["storage_layout"] = version
+	data, err = json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// layoutVersions 登録されている保存形式の一覧（usage・エラーメッセージ用）
+func layoutVersions() string {
+	names := make([]string, 0)
+	for _, l := range jobs.StorageLayouts() {
+		names = append(names, fmt.Sprintf("%d (%s)", l.Version, l.Name))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"dsa-api/storage"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: dsa-admin <command> [options]
+
+Commands:
+  r2 purge         R2の解析結果を条件を指定して削除する（DBと照合）
+  layout migrate   終了済みの解析の成果物を指定した保存形式に移す（ローカル・R2・DB）
+
+Run "dsa-admin <command> -h" for options.
+`
+
+func main() {
+	loadEnv()
+
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] + " " + os.Args[2] {
+	case "r2 purge":
+		os.Exit(runR2Purge(os.Args[3:]))
+	case "layout migrate":
+		os.Exit(runLayoutMigrate(os.Args[3:]))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s %s\n\n", os.Args[1], os.Args[2])
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// loadEnv プロジェクトルートの.envファイルを読み込む（backend、backend/cmd/dsa-adminのどちらから実行しても見つかるように）
+func loadEnv() {
+	for _, envPath := range []string{
+		".env",
+		"../.env",
+		filepath.Join("..", "..", "..", ".env"),
+	} {
+		if err := godotenv.Load(envPath); err == nil {
+			fmt.Printf("Loaded .env from: %s\n", envPath)
+			return
+		}
+	}
+}
+
+// newR2Client 環境変数から成果物の保存先を作成する（STORAGE_BACKENDはサーバーと同じ、デフォルトはR2）
+func newR2Client() (storage.ObjectStore, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", storage.BackendR2:
+		r2AccountID := os.Getenv("R2_ACCOUNT_ID")
+		r2AccessKeyID := os.Getenv("R2_ACCESS_KEY_ID")
+		r2SecretAccessKey := os.Getenv("R2_SECRET_ACCESS_KEY")
+		r2Bucket := os.Getenv("R2_BUCKET")
+		r2Endpoint := os.Getenv("R2_ENDPOINT")
+
+		if r2AccountID == "" || r2AccessKeyID == "" || r2SecretAccessKey == "" || r2Bucket == "" || r2Endpoint == "" {
+			return nil, fmt.Errorf("R2 environment variables are required: R2_ACCOUNT_ID, R2_ACCESS_KEY_ID, R2_SECRET_ACCESS_KEY, R2_BUCKET, R2_ENDPOINT")
+		}
+		return storage.NewR2Client(r2AccountID, r2AccessKeyID, r2SecretAccessKey, r2Bucket, r2Endpoint, "")
+	case storage.BackendS3, storage.BackendGCS, storage.BackendMinIO:
+		return storage.NewS3Client(backend, storage.S3Config{
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			Region:          os.Getenv("S3_REGION"),
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			UsePathStyle:    os.Getenv("S3_USE_PATH_STYLE") == "true",
+		})
+	case storage.BackendLocal:
+		dir := os.Getenv("LOCAL_STORE_DIR")
+		if dir == "" {
+			storageDir := os.Getenv("STORAGE_DIR")
+			if storageDir == "" {
+				storageDir = "./storage"
+			}
+			dir = filepath.Join(storageDir, "objects")
+		}
+		return storage.NewLocalStore(dir)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND: %s", backend)
+	}
+}
+
+// objectStoreConfigured 保存先の環境変数が設定されているか（R2はR2_BUCKET、それ以外はSTORAGE_BACKEND）
+func objectStoreConfigured() bool {
+	backend := os.Getenv("STORAGE_BACKEND")
+	return os.Getenv("R2_BUCKET") != "" || (backend != "" && backend != storage.BackendR2)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DBにレコードがない解析を指定するステータス
+const statusOrphaned = "orphaned"
+
+// purgeTarget 削除対象の解析（R2のプレフィックス単位）
+type purgeTarget struct {
+	ID      string
+	Prefix  string
+	Status  string
+	InDB    bool
+	Age     time.Time
+	Objects []storage.ObjectInfo
+	Bytes   int64
+}
+
+// runR2Purge dsa-admin r2 purge --older-than 90d --status failed --dry-run
+func runR2Purge(args []string) int {
+	fs := flag.NewFlagSet("r2 purge", flag.ContinueOnError)
+	olderThan := fs.String("older-than", "", "この期間より前に作成された解析のみ対象にする（例: 90d, 36h）")
+	statusList := fs.String("status", "", "対象のステータス（カンマ区切り: queued,running,done,failed,cancelled,orphaned）。orphanedはDBにレコードがないもの")
+	dryRun := fs.Bool("dry-run", false, "削除せずに対象のオブジェクトを表示する")
+	yes := fs.Bool("yes", false, "確認なしで削除を実行する")
+	deleteRows := fs.Bool("delete-rows", false, "R2のオブジェクトと併せてDBのレコードも削除する")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var minAge time.Duration
+	if *olderThan != "" {
+		d, err := parseAge(*olderThan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --older-than: %v\n", err)
+			return 2
+		}
+		minAge = d
+	}
+	statuses := make(map[string]bool)
+	for _, s := range strings.Split(*statusList, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			statuses[s] = true
+		}
+	}
+
+	r2, err := newR2Client()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create R2 client: %v\n", err)
+		return 1
+	}
+
+	// DBと照合してステータス・作成日時を取得する（DBがない場合はすべてorphanedとして扱う）
+	var db *storage.DB
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		db, err = storage.NewDB(databaseURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+			return 1
+		}
+		defer db.Close()
+	} else if (len(statuses) > 0 && !(len(statuses) == 1 && statuses[statusOrphaned])) || *deleteRows {
+		fmt.Fprintf(os.Stderr, "DATABASE_URL is required to filter by status or delete rows\n")
+		return 1
+	}
+
+	records := make(map[string]*storage.AnalysisRecord)
+	if db != nil {
+		records, err = listAllAnalyses(db)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list analyses: %v\n", err)
+			return 1
+		}
+	}
+
+	// すべての保存形式のプレフィックスを対象にする（移行中は同じ解析が複数の保存形式に残ることがある）
+	ctx := context.Background()
+	var objects []storage.ObjectInfo
+	for _, layout := range jobs.StorageLayouts() {
+		found, err := r2.ListObjectsWithPrefix(ctx, layout.R2Root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to list R2 objects: %v\n", err)
+			return 1
+		}
+		objects = append(objects, found...)
+	}
+
+	targets := selectPurgeTargets(objects, records, statuses, minAge, time.Now())
+	if len(targets) == 0 {
+		fmt.Println("No matching objects.")
+		return 0
+	}
+
+	var totalObjects int
+	var totalBytes int64
+	for _, t := range targets {
+		fmt.Printf("%s  status=%s  date=%s  objects=%d  size=%s\n", t.ID, t.Status, t.Age.Format("2006-01-02"), len(t.Objects), formatBytes(t.Bytes))
+		for _, obj := range t.Objects {
+			fmt.Printf("    %s  %s\n", obj.Key, formatBytes(obj.Size))
+		}
+		totalObjects += len(t.Objects)
+		totalBytes += t.Bytes
+	}
+	fmt.Printf("\n%d analyses, %d objects, %s\n", len(targets), totalObjects, formatBytes(totalBytes))
+
+	if *dryRun {
+		fmt.Println("Dry run: nothing was deleted.")
+		return 0
+	}
+	if !*yes {
+		fmt.Println("Re-run with --yes to delete these objects.")
+		return 1
+	}
+
+	failed := 0
+	for _, t := range targets {
+		if err := r2.DeleteObjectsWithPrefix(ctx, t.Prefix+"/"); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to delete objects for %s: %v\n", t.ID, err)
+			failed++
+			continue
+		}
+		if *deleteRows && t.InDB {
+			if err := db.DeleteAnalysis(t.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to delete DB row for %s: %v\n", t.ID, err)
+				failed++
+				continue
+			}
+		}
+		fmt.Printf("Deleted %s\n", t.ID)
+	}
+
+	fmt.Printf("Purge completed: %d deleted, %d failed\n", len(targets)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// listAllAnalyses DBのすべての解析をIDをキーにして返す
+func listAllAnalyses(db *storage.DB) (map[string]*storage.AnalysisRecord, error) {
+	const pageSize = 1000
+	records := make(map[string]*storage.AnalysisRecord)
+	for offset := 0; ; offset += pageSize {
+		page, err := db.ListAnalyses(map[string]interface{}{"limit": pageSize, "offset": offset})
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range page {
+			records[record.ID] = record
+		}
+		if len(page) < pageSize {
+			return records, nil
+		}
+	}
+}
+
+// selectPurgeTargets R2のオブジェクトを解析ごとにまとめ、条件に一致するものを古い順に返す
+// 日時はDBのレコードがあれば作成日時、なければオブジェクトの最終更新日時
+func selectPurgeTargets(objects []storage.ObjectInfo, records map[string]*storage.AnalysisRecord, statuses map[string]bool, minAge time.Duration, now time.Time) []*purgeTarget {
+	byPrefix := make(map[string]*purgeTarget)
+	for _, obj := range objects {
+		id, layout := analysisIDFromKey(obj.Key)
+		if layout == nil {
+			continue
+		}
+		prefix := layout.Prefix(id)
+		t, exists := byPrefix[prefix]
+		if !exists {
+			t = &purgeTarget{ID: id, Prefix: prefix, Status: statusOrphaned}
+			if record, ok := records[id]; ok {
+				t.Status = record.Status
+				t.InDB = true
+				t.Age = record.CreatedAt
+			}
+			byPrefix[prefix] = t
+		}
+		t.Objects = append(t.Objects, obj)
+		t.Bytes += obj.Size
+		if !t.InDB && obj.LastModified.After(t.Age) {
+			t.Age = obj.LastModified
+		}
+	}
+
+	targets := make([]*purgeTarget, 0, len(byPrefix))
+	for _, t := range byPrefix {
+		if len(statuses) > 0 && !statuses[t.Status] {
+			continue
+		}
+		if minAge > 0 && now.Sub(t.Age) < minAge {
+			continue
+		}
+		targets = append(targets, t)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Age.Before(targets[j].Age)
+	})
+	return targets
+}
+
+// analysisIDFromKey R2のキーから解析IDと保存形式を取り出す（解析の成果物のキーでない場合はnil）
+func analysisIDFromKey(key string) (string, *jobs.StorageLayout) {
+	for _, layout := range jobs.StorageLayouts() {
+		if id, ok := layout.AnalysisIDFromKey(key); ok {
+			return id, layout
+		}
+	}
+	return "", nil
+}
+
+// parseAge "90d" のような日数、または "36h" のようなGoの期間を解釈する
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid number of days: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,11 @@
+// 結合テスト用の偽のPython CLI（PYTHON_PATHに指定して使う、testsupport.BuildFakeCLIがビルドする）
+package main
+
+import (
+	"dsa-api/testsupport/fakedsa"
+	"os"
+)
+
+func main() {
+	os.Exit(fakedsa.Main(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"dsa-api/jobs"
 	"dsa-api/storage"
 	"encoding/json"
 	"fmt"
@@ -48,9 +49,9 @@ func main() {
 			continue
 		}
 
-		// result.jsonを読み込む
-		resultPath := filepath.Join(storageDir, record.ID, "result.json")
-		if _, err := os.Stat(resultPath); os.IsNotExist(err) {
+		// result.jsonを読み込む（解析の保存形式ごとの場所を探す）
+		resultPath := findResultPath(storageDir, record.ID)
+		if resultPath == "" {
 			fmt.Printf("Skipping %s: result.json not found\n", record.ID)
 			continue
 		}
@@ -80,4 +81,13 @@ func main() {
 	fmt.Printf("Updated %d analyses\n", updated)
 }
 
-
+// findResultPath いずれかの保存形式のジョブディレクトリにあるresult.json（ない場合は空文字列）
+func findResultPath(storageDir, id string) string {
+	for _, layout := range jobs.StorageLayouts() {
+		path := filepath.Join(layout.LocalDir(storageDir, id), "result.json")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"dsa-api/jobs"
+	"dsa-api/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// errJobGone ジョブがキャンセル・タイムアウトした、または他のワーカーに再割り当てされた
+var errJobGone = errors.New("job is no longer assigned to this worker")
+
+// client APIサーバーの内部API（/api/internal/jobs）のクライアント
+type client struct {
+	baseURL  string
+	token    string
+	workerID string
+	http     *http.Client
+}
+
+func main() {
+	// .envファイルを読み込む（エラーは無視）
+	godotenv.Load()
+
+	if err := logging.Setup(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT")); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	serverURL := strings.TrimRight(os.Getenv("DSA_SERVER_URL"), "/")
+	token := os.Getenv("WORKER_TOKEN")
+	if serverURL == "" || token == "" {
+		fmt.Fprintf(os.Stderr, "DSA_SERVER_URL and WORKER_TOKEN environment variables are required\n")
+		os.Exit(1)
+	}
+
+	workerID := os.Getenv("WORKER_ID")
+	if workerID == "" {
+		hostname, _ := os.Hostname()
+		workerID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	pythonPath := os.Getenv("PYTHON_PATH")
+	if pythonPath == "" {
+		pythonPath = "python3"
+	}
+
+	workDir := os.Getenv("WORK_DIR")
+	if workDir == "" {
+		workDir = filepath.Join(os.TempDir(), "dsa-worker")
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create work directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	pollInterval := 5 * time.Second
+	if v := os.Getenv("POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			pollInterval = d
+		} else {
+			logging.Warnf("Invalid POLL_INTERVAL: %s, using %s", v, pollInterval)
+		}
+	}
+
+	c := &client{
+		baseURL:  serverURL,
+		token:    token,
+		workerID: workerID,
+		http:     &http.Client{Timeout: 5 * time.Minute},
+	}
+	// Pythonディレクトリはwork_dirの親、またはPYTHON_DIRから探す
+	executor := &jobs.LocalPythonExecutor{PythonPath: pythonPath, StorageDir: workDir}
+	// Pythonプロセスの資源の制限（APIサーバーと同じ環境変数）
+	for name, dst := range map[string]*int{"JOB_NICE": &executor.Limits.Nice, "JOB_MAX_MEMORY_MB": &executor.Limits.MaxMemoryMB, "JOB_THREADS": &executor.Limits.Threads} {
+		if v := os.Getenv(name); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				*dst = n
+			} else {
+				logging.Warnf("Invalid %s: %s, ignoring", name, v)
+			}
+		}
+	}
+	// ジョブのparams.envで上書きできる環境変数（APIサーバーで検証済みだが、ワーカー側でも許可したもののみ適用する）
+	if v := os.Getenv("JOB_ENV_ALLOWLIST"); v != "" {
+		executor.EnvAllowlist = make(map[string]bool)
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				executor.EnvAllowlist[name] = true
+			}
+		}
+	}
+
+	// 停止時は実行中のジョブを中断して終了する（報告しないのでリースが切れると他のワーカーに再割り当てされる）
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logging.Infof("Worker %s started (server: %s, work dir: %s)", workerID, serverURL, workDir)
+	for ctx.Err() == nil {
+		claimed, err := c.claim(ctx)
+		if err != nil {
+			logging.Warnf("Failed to claim job: %v", err)
+		}
+		if claimed == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		runJob(ctx, c, executor, workDir, claimed)
+	}
+	logging.Infof("Worker %s stopped", workerID)
+}
+
+// runJob ジョブをローカルで実行し、進捗と結果を報告する
+func runJob(ctx context.Context, c *client, executor jobs.Executor, workDir string, claimed *jobs.ClaimedJob) {
+	logging.Job(claimed.JobID).Infof("Running job %s (%s)", claimed.JobID, claimed.UniProtID)
+
+	jobDir := filepath.Join(workDir, claimed.JobID)
+	defer os.RemoveAll(jobDir)
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		c.complete(ctx, claimed.JobID, fmt.Sprintf("Failed to create job directory: %v", err), jobDir)
+		return
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 進捗は最新の値だけを送る（リースの半分の間隔でハートビートも送る）
+	heartbeat := time.Duration(claimed.LeaseSeconds) * time.Second / 2
+	if heartbeat <= 0 {
+		heartbeat = 30 * time.Second
+	}
+	var mu sync.Mutex
+	var latest *jobs.WorkerReport
+	notify := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+			case <-notify:
+			}
+			mu.Lock()
+			report := jobs.WorkerReport{Status: "running"}
+			if latest != nil {
+				report = *latest
+				latest = nil
+			}
+			mu.Unlock()
+			if err := c.report(jobCtx, claimed.JobID, report); errors.Is(err, errJobGone) {
+				logging.Warnf("Job %s was cancelled or reassigned, stopping", claimed.JobID)
+				cancel()
+				return
+			} else if err != nil && jobCtx.Err() == nil {
+				logging.Warnf("Failed to report progress for job %s: %v", claimed.JobID, err)
+			}
+		}
+	}()
+
+	job := &jobs.Job{ID: claimed.JobID, UniProtID: claimed.UniProtID, Params: claimed.Params}
+	err := executor.Run(jobCtx, job, jobDir, func(percent int, message string) {
+		mu.Lock()
+		latest = &jobs.WorkerReport{Status: "running", Progress: &percent, Message: message}
+		mu.Unlock()
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	})
+	stopped := jobCtx.Err() != nil
+	cancel()
+	wg.Wait()
+
+	if stopped {
+		// キャンセル・再割り当て・ワーカーの停止の場合は報告しない
+		logging.Job(claimed.JobID).Infof("Job %s stopped", claimed.JobID)
+		return
+	}
+
+	errorMessage := ""
+	if err != nil {
+		errorMessage = err.Error()
+		if errorMessage == "" {
+			errorMessage = "Analysis failed on worker"
+		}
+	}
+	c.complete(ctx, claimed.JobID, errorMessage, jobDir)
+}
+
+// complete 成果物を添付して完了（errorMessageが空でない場合は失敗）を報告する
+func (c *client) complete(ctx context.Context, jobID, errorMessage, jobDir string) {
+	status := "done"
+	if errorMessage != "" {
+		status = "failed"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("worker_id", c.workerID)
+	form.WriteField("status", status)
+	form.WriteField("error", errorMessage)
+	for _, name := range jobs.ArtifactNames() {
+		data, err := os.ReadFile(filepath.Join(jobDir, name))
+		if err != nil {
+			continue
+		}
+		part, err := form.CreateFormFile(name, name)
+		if err != nil {
+			logging.Warnf("Failed to attach %s: %v", name, err)
+			continue
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	err := c.post(ctx, "/api/internal/jobs/"+jobID+"/report", form.FormDataContentType(), &body, nil)
+	if err != nil {
+		logging.Job(jobID).Errorf("Failed to report result for job %s: %v", jobID, err)
+		return
+	}
+	logging.Job(jobID).Infof("Job %s reported as %s", jobID, status)
+}
+
+// claim 割り当て待ちのジョブを取得する（なければnil）
+func (c *client) claim(ctx context.Context) (*jobs.ClaimedJob, error) {
+	body, _ := json.Marshal(map[string]string{"worker_id": c.workerID})
+	var claimed jobs.ClaimedJob
+	if err := c.post(ctx, "/api/internal/jobs/claim", "application/json", bytes.NewReader(body), &claimed); err != nil {
+		return nil, err
+	}
+	if claimed.JobID == "" {
+		return nil, nil
+	}
+	return &claimed, nil
+}
+
+// report 進捗（ハートビート）を報告する
+func (c *client) report(ctx context.Context, jobID string, report jobs.WorkerReport) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"worker_id": c.workerID,
+		"status":    report.Status,
+		"progress":  report.Progress,
+		"message":   report.Message,
+	})
+	return c.post(ctx, "/api/internal/jobs/"+jobID+"/report", "application/json", bytes.NewReader(body), nil)
+}
+
+func (c *client) post(ctx context.Context, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusConflict:
+		return errJobGone
+	case resp.StatusCode == http.StatusNoContent:
+		return nil
+	case resp.StatusCode >= 300:
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("server returned %d: %s", resp.StatusCode, apiErr.Error)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package grpcapi
+
+import (
+	"encoding/json"
+
+	"dsa-api/grpcapi/dsapb"
+	"dsa-api/jobs"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func jobProto(job *jobs.Job) *dsapb.Job {
+	pb := &dsapb.Job{
+		JobId:        job.ID,
+		UniprotId:    job.UniProtID,
+		Status:       string(job.Status),
+		Progress:     int32(job.Progress),
+		Message:      job.Message,
+		ErrorMessage: job.ErrorMessage,
+		Params:       paramsStruct(job.Params),
+		CreatedAt:    timestamppb.New(job.CreatedAt),
+		UpdatedAt:    timestamppb.New(job.UpdatedAt),
+		Cached:       job.Cached,
+		CachedFrom:   job.CachedFrom,
+		EtaSeconds:   etaSeconds(job.ETASeconds),
+	}
+	if job.Result != nil {
+		pb.Result = &dsapb.JobResult{
+			JsonUrl:    job.Result.JSONURL,
+			HeatmapUrl: job.Result.HeatmapURL,
+			ScatterUrl: job.Result.ScatterURL,
+		}
+	}
+	return pb
+}
+
+func jobUpdateFromJob(job *jobs.Job) *dsapb.JobUpdate {
+	return &dsapb.JobUpdate{
+		JobId:        job.ID,
+		Status:       string(job.Status),
+		Progress:     int32(job.Progress),
+		Message:      job.Message,
+		ErrorMessage: job.ErrorMessage,
+		UpdatedAt:    timestamppb.New(job.UpdatedAt),
+		EtaSeconds:   etaSeconds(job.ETASeconds),
+	}
+}
+
+func jobUpdateProto(update jobs.JobUpdate) *dsapb.JobUpdate {
+	return &dsapb.JobUpdate{
+		JobId:        update.JobID,
+		Status:       string(update.Status),
+		Progress:     int32(update.Progress),
+		Message:      update.Message,
+		ErrorMessage: update.ErrorMessage,
+		UpdatedAt:    timestamppb.New(update.UpdatedAt),
+		EtaSeconds:   etaSeconds(update.ETASeconds),
+	}
+}
+
+func etaSeconds(eta *int) *int32 {
+	if eta == nil {
+		return nil
+	}
+	return proto.Int32(int32(*eta))
+}
+
+// paramsStruct パラメータをStructに変換する（[]string等もJSONと同じ形にするためJSONを経由する）
+func paramsStruct(params map[string]interface{}) *structpb.Struct {
+	if params == nil {
+		return nil
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil
+	}
+	s, err := structpb.NewStruct(generic)
+	if err != nil {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,861 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: dsa.proto
+
+package dsapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CreateJobRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	UniprotId string                 `protobuf:"bytes,1,opt,name=uniprot_id,json=uniprotId,proto3" json:"uniprot_id,omitempty"`
+	// 解析パラメータ（REST APIのparamsと同じ）
+	Params *structpb.Struct `protobuf:"bytes,2,opt,name=params,proto3" json:"params,omitempty"`
+	// 同じ条件のキュー待ち・実行中のジョブがあれば、新しく作成せずにそのジョブを返す
+	Dedupe bool `protobuf:"varint,3,opt,name=dedupe,proto3" json:"dedupe,omitempty"`
+	// 結果キャッシュを使わずに必ず解析を実行する
+	NoCache bool `protobuf:"varint,4,opt,name=no_cache,json=noCache,proto3" json:"no_cache,omitempty"`
+	// ジョブの種類（interactive・batch、空の場合はbatch）
+	Class string `protobuf:"bytes,5,opt,name=class,proto3" json:"class,omitempty"`
+	// 記録するセッションID（空の場合はどのセッションにも属さない）
+	SessionId     string `protobuf:"bytes,6,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateJobRequest) Reset() {
+	*x = CreateJobRequest{}
+	mi := &file_dsa_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateJobRequest) ProtoMessage() {}
+
+func (x *CreateJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateJobRequest.ProtoReflect.Descriptor instead.
+func (*CreateJobRequest) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateJobRequest) GetUniprotId() string {
+	if x != nil {
+		return x.UniprotId
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetParams() *structpb.Struct {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *CreateJobRequest) GetDedupe() bool {
+	if x != nil {
+		return x.Dedupe
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetNoCache() bool {
+	if x != nil {
+		return x.NoCache
+	}
+	return false
+}
+
+func (x *CreateJobRequest) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *CreateJobRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type CreateJobResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Deduplicated  bool                   `protobuf:"varint,3,opt,name=deduplicated,proto3" json:"deduplicated,omitempty"`
+	Cached        bool                   `protobuf:"varint,4,opt,name=cached,proto3" json:"cached,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateJobResponse) Reset() {
+	*x = CreateJobResponse{}
+	mi := &file_dsa_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateJobResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateJobResponse) ProtoMessage() {}
+
+func (x *CreateJobResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateJobResponse.ProtoReflect.Descriptor instead.
+func (*CreateJobResponse) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateJobResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *CreateJobResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CreateJobResponse) GetDeduplicated() bool {
+	if x != nil {
+		return x.Deduplicated
+	}
+	return false
+}
+
+func (x *CreateJobResponse) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_dsa_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type JobResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JsonUrl       string                 `protobuf:"bytes,1,opt,name=json_url,json=jsonUrl,proto3" json:"json_url,omitempty"`
+	HeatmapUrl    string                 `protobuf:"bytes,2,opt,name=heatmap_url,json=heatmapUrl,proto3" json:"heatmap_url,omitempty"`
+	ScatterUrl    string                 `protobuf:"bytes,3,opt,name=scatter_url,json=scatterUrl,proto3" json:"scatter_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobResult) Reset() {
+	*x = JobResult{}
+	mi := &file_dsa_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobResult) ProtoMessage() {}
+
+func (x *JobResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobResult.ProtoReflect.Descriptor instead.
+func (*JobResult) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *JobResult) GetJsonUrl() string {
+	if x != nil {
+		return x.JsonUrl
+	}
+	return ""
+}
+
+func (x *JobResult) GetHeatmapUrl() string {
+	if x != nil {
+		return x.HeatmapUrl
+	}
+	return ""
+}
+
+func (x *JobResult) GetScatterUrl() string {
+	if x != nil {
+		return x.ScatterUrl
+	}
+	return ""
+}
+
+type Job struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	JobId     string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	UniprotId string                 `protobuf:"bytes,2,opt,name=uniprot_id,json=uniprotId,proto3" json:"uniprot_id,omitempty"`
+	// queued・running・done・failed・cancelled
+	Status       string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Progress     int32                  `protobuf:"varint,4,opt,name=progress,proto3" json:"progress,omitempty"`
+	Message      string                 `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	ErrorMessage string                 `protobuf:"bytes,6,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	Params       *structpb.Struct       `protobuf:"bytes,7,opt,name=params,proto3" json:"params,omitempty"`
+	Result       *JobResult             `protobuf:"bytes,8,opt,name=result,proto3" json:"result,omitempty"`
+	CreatedAt    *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt    *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Cached       bool                   `protobuf:"varint,11,opt,name=cached,proto3" json:"cached,omitempty"`
+	CachedFrom   string                 `protobuf:"bytes,12,opt,name=cached_from,json=cachedFrom,proto3" json:"cached_from,omitempty"`
+	// 残り時間の推定（秒、実行中で推定できる場合のみ）
+	EtaSeconds    *int32 `protobuf:"varint,13,opt,name=eta_seconds,json=etaSeconds,proto3,oneof" json:"eta_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_dsa_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Job) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *Job) GetUniprotId() string {
+	if x != nil {
+		return x.UniprotId
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *Job) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Job) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *Job) GetParams() *structpb.Struct {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+func (x *Job) GetResult() *JobResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *Job) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Job) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Job) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *Job) GetCachedFrom() string {
+	if x != nil {
+		return x.CachedFrom
+	}
+	return ""
+}
+
+func (x *Job) GetEtaSeconds() int32 {
+	if x != nil && x.EtaSeconds != nil {
+		return *x.EtaSeconds
+	}
+	return 0
+}
+
+type WatchJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchJobRequest) Reset() {
+	*x = WatchJobRequest{}
+	mi := &file_dsa_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchJobRequest) ProtoMessage() {}
+
+func (x *WatchJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchJobRequest.ProtoReflect.Descriptor instead.
+func (*WatchJobRequest) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchJobRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+type JobUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Progress      int32                  `protobuf:"varint,3,opt,name=progress,proto3" json:"progress,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	ErrorMessage  string                 `protobuf:"bytes,5,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	EtaSeconds    *int32                 `protobuf:"varint,7,opt,name=eta_seconds,json=etaSeconds,proto3,oneof" json:"eta_seconds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobUpdate) Reset() {
+	*x = JobUpdate{}
+	mi := &file_dsa_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobUpdate) ProtoMessage() {}
+
+func (x *JobUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobUpdate.ProtoReflect.Descriptor instead.
+func (*JobUpdate) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *JobUpdate) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *JobUpdate) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *JobUpdate) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *JobUpdate) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *JobUpdate) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *JobUpdate) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *JobUpdate) GetEtaSeconds() int32 {
+	if x != nil && x.EtaSeconds != nil {
+		return *x.EtaSeconds
+	}
+	return 0
+}
+
+type ListAnalysesRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Status    string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	UniprotId string                 `protobuf:"bytes,2,opt,name=uniprot_id,json=uniprotId,proto3" json:"uniprot_id,omitempty"`
+	// この日時より後に作成された解析のみ
+	CreatedAfter *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_after,json=createdAfter,proto3" json:"created_after,omitempty"`
+	// 空の場合はすべてのセッション
+	SessionId string `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// 1ページの件数（デフォルト50、上限200）
+	Limit int32 `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	// 前のページのnext_cursor
+	Cursor        string `protobuf:"bytes,6,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnalysesRequest) Reset() {
+	*x = ListAnalysesRequest{}
+	mi := &file_dsa_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnalysesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnalysesRequest) ProtoMessage() {}
+
+func (x *ListAnalysesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnalysesRequest.ProtoReflect.Descriptor instead.
+func (*ListAnalysesRequest) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListAnalysesRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListAnalysesRequest) GetUniprotId() string {
+	if x != nil {
+		return x.UniprotId
+	}
+	return ""
+}
+
+func (x *ListAnalysesRequest) GetCreatedAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAfter
+	}
+	return nil
+}
+
+func (x *ListAnalysesRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ListAnalysesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListAnalysesRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+type ListAnalysesResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Analyses []*Job                 `protobuf:"bytes,1,rep,name=analyses,proto3" json:"analyses,omitempty"`
+	// 次のページのカーソル（最後のページでは空）
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAnalysesResponse) Reset() {
+	*x = ListAnalysesResponse{}
+	mi := &file_dsa_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAnalysesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAnalysesResponse) ProtoMessage() {}
+
+func (x *ListAnalysesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dsa_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAnalysesResponse.ProtoReflect.Descriptor instead.
+func (*ListAnalysesResponse) Descriptor() ([]byte, []int) {
+	return file_dsa_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListAnalysesResponse) GetAnalyses() []*Job {
+	if x != nil {
+		return x.Analyses
+	}
+	return nil
+}
+
+func (x *ListAnalysesResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+var File_dsa_proto protoreflect.FileDescriptor
+
+const file_dsa_proto_rawDesc = "" +
+	"\n" +
+	"\tdsa.proto\x12\x06dsa.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xca\x01\n" +
+	"\x10CreateJobRequest\x12\x1d\n" +
+	"\n" +
+	"uniprot_id\x18\x01 \x01(\tR\tuniprotId\x12/\n" +
+	"\x06params\x18\x02 \x01(\v2\x17.google.protobuf.StructR\x06params\x12\x16\n" +
+	"\x06dedupe\x18\x03 \x01(\bR\x06dedupe\x12\x19\n" +
+	"\bno_cache\x18\x04 \x01(\bR\anoCache\x12\x14\n" +
+	"\x05class\x18\x05 \x01(\tR\x05class\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x06 \x01(\tR\tsessionId\"~\n" +
+	"\x11CreateJobResponse\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\"\n" +
+	"\fdeduplicated\x18\x03 \x01(\bR\fdeduplicated\x12\x16\n" +
+	"\x06cached\x18\x04 \x01(\bR\x06cached\"&\n" +
+	"\rGetJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"h\n" +
+	"\tJobResult\x12\x19\n" +
+	"\bjson_url\x18\x01 \x01(\tR\ajsonUrl\x12\x1f\n" +
+	"\vheatmap_url\x18\x02 \x01(\tR\n" +
+	"heatmapUrl\x12\x1f\n" +
+	"\vscatter_url\x18\x03 \x01(\tR\n" +
+	"scatterUrl\"\xef\x03\n" +
+	"\x03Job\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x1d\n" +
+	"\n" +
+	"uniprot_id\x18\x02 \x01(\tR\tuniprotId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x1a\n" +
+	"\bprogress\x18\x04 \x01(\x05R\bprogress\x12\x18\n" +
+	"\amessage\x18\x05 \x01(\tR\amessage\x12#\n" +
+	"\rerror_message\x18\x06 \x01(\tR\ferrorMessage\x12/\n" +
+	"\x06params\x18\a \x01(\v2\x17.google.protobuf.StructR\x06params\x12)\n" +
+	"\x06result\x18\b \x01(\v2\x11.dsa.v1.JobResultR\x06result\x129\n" +
+	"\n" +
+	"created_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x16\n" +
+	"\x06cached\x18\v \x01(\bR\x06cached\x12\x1f\n" +
+	"\vcached_from\x18\f \x01(\tR\n" +
+	"cachedFrom\x12$\n" +
+	"\veta_seconds\x18\r \x01(\x05H\x00R\n" +
+	"etaSeconds\x88\x01\x01B\x0e\n" +
+	"\f_eta_seconds\"(\n" +
+	"\x0fWatchJobRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\x86\x02\n" +
+	"\tJobUpdate\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1a\n" +
+	"\bprogress\x18\x03 \x01(\x05R\bprogress\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12#\n" +
+	"\rerror_message\x18\x05 \x01(\tR\ferrorMessage\x129\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12$\n" +
+	"\veta_seconds\x18\a \x01(\x05H\x00R\n" +
+	"etaSeconds\x88\x01\x01B\x0e\n" +
+	"\f_eta_seconds\"\xda\x01\n" +
+	"\x13ListAnalysesRequest\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x1d\n" +
+	"\n" +
+	"uniprot_id\x18\x02 \x01(\tR\tuniprotId\x12?\n" +
+	"\rcreated_after\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\fcreatedAfter\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x06 \x01(\tR\x06cursor\"`\n" +
+	"\x14ListAnalysesResponse\x12'\n" +
+	"\banalyses\x18\x01 \x03(\v2\v.dsa.v1.JobR\banalyses\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor2\x81\x02\n" +
+	"\n" +
+	"DSAService\x12@\n" +
+	"\tCreateJob\x12\x18.dsa.v1.CreateJobRequest\x1a\x19.dsa.v1.CreateJobResponse\x12,\n" +
+	"\x06GetJob\x12\x15.dsa.v1.GetJobRequest\x1a\v.dsa.v1.Job\x128\n" +
+	"\bWatchJob\x12\x17.dsa.v1.WatchJobRequest\x1a\x11.dsa.v1.JobUpdate0\x01\x12I\n" +
+	"\fListAnalyses\x12\x1b.dsa.v1.ListAnalysesRequest\x1a\x1c.dsa.v1.ListAnalysesResponseB\x17Z\x15dsa-api/grpcapi/dsapbb\x06proto3"
+
+var (
+	file_dsa_proto_rawDescOnce sync.Once
+	file_dsa_proto_rawDescData []byte
+)
+
+func file_dsa_proto_rawDescGZIP() []byte {
+	file_dsa_proto_rawDescOnce.Do(func() {
+		file_dsa_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dsa_proto_rawDesc), len(file_dsa_proto_rawDesc)))
+	})
+	return file_dsa_proto_rawDescData
+}
+
+var file_dsa_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_dsa_proto_goTypes = []any{
+	(*CreateJobRequest)(nil),      // 0: dsa.v1.CreateJobRequest
+	(*CreateJobResponse)(nil),     // 1: dsa.v1.CreateJobResponse
+	(*GetJobRequest)(nil),         // 2: dsa.v1.GetJobRequest
+	(*JobResult)(nil),             // 3: dsa.v1.JobResult
+	(*Job)(nil),                   // 4: dsa.v1.Job
+	(*WatchJobRequest)(nil),       // 5: dsa.v1.WatchJobRequest
+	(*JobUpdate)(nil),             // 6: dsa.v1.JobUpdate
+	(*ListAnalysesRequest)(nil),   // 7: dsa.v1.ListAnalysesRequest
+	(*ListAnalysesResponse)(nil),  // 8: dsa.v1.ListAnalysesResponse
+	(*structpb.Struct)(nil),       // 9: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+}
+var file_dsa_proto_depIdxs = []int32{
+	9,  // 0: dsa.v1.CreateJobRequest.params:type_name -> google.protobuf.Struct
+	9,  // 1: dsa.v1.Job.params:type_name -> google.protobuf.Struct
+	3,  // 2: dsa.v1.Job.result:type_name -> dsa.v1.JobResult
+	10, // 3: dsa.v1.Job.created_at:type_name -> google.protobuf.Timestamp
+	10, // 4: dsa.v1.Job.updated_at:type_name -> google.protobuf.Timestamp
+	10, // 5: dsa.v1.JobUpdate.updated_at:type_name -> google.protobuf.Timestamp
+	10, // 6: dsa.v1.ListAnalysesRequest.created_after:type_name -> google.protobuf.Timestamp
+	4,  // 7: dsa.v1.ListAnalysesResponse.analyses:type_name -> dsa.v1.Job
+	0,  // 8: dsa.v1.DSAService.CreateJob:input_type -> dsa.v1.CreateJobRequest
+	2,  // 9: dsa.v1.DSAService.GetJob:input_type -> dsa.v1.GetJobRequest
+	5,  // 10: dsa.v1.DSAService.WatchJob:input_type -> dsa.v1.WatchJobRequest
+	7,  // 11: dsa.v1.DSAService.ListAnalyses:input_type -> dsa.v1.ListAnalysesRequest
+	1,  // 12: dsa.v1.DSAService.CreateJob:output_type -> dsa.v1.CreateJobResponse
+	4,  // 13: dsa.v1.DSAService.GetJob:output_type -> dsa.v1.Job
+	6,  // 14: dsa.v1.DSAService.WatchJob:output_type -> dsa.v1.JobUpdate
+	8,  // 15: dsa.v1.DSAService.ListAnalyses:output_type -> dsa.v1.ListAnalysesResponse
+	12, // [12:16] is the sub-list for method output_type
+	8,  // [8:12] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_dsa_proto_init() }
+func file_dsa_proto_init() {
+	if File_dsa_proto != nil {
+		return
+	}
+	file_dsa_proto_msgTypes[4].OneofWrappers = []any{}
+	file_dsa_proto_msgTypes[6].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dsa_proto_rawDesc), len(file_dsa_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dsa_proto_goTypes,
+		DependencyIndexes: file_dsa_proto_depIdxs,
+		MessageInfos:      file_dsa_proto_msgTypes,
+	}.Build()
+	File_dsa_proto = out.File
+	file_dsa_proto_goTypes = nil
+	file_dsa_proto_depIdxs = nil
+}
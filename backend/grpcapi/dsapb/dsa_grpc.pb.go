@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dsa.proto
+
+package dsapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DSAService_CreateJob_FullMethodName    = "/dsa.v1.DSAService/CreateJob"
+	DSAService_GetJob_FullMethodName       = "/dsa.v1.DSAService/GetJob"
+	DSAService_WatchJob_FullMethodName     = "/dsa.v1.DSAService/WatchJob"
+	DSAService_ListAnalyses_FullMethodName = "/dsa.v1.DSAService/ListAnalyses"
+)
+
+// DSAServiceClient is the client API for DSAService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DSAService ジョブマネージャーの操作（REST APIの POST /api/jobs・GET /api/jobs/:id・GET /api/jobs に相当）
+type DSAServiceClient interface {
+	// CreateJob 解析ジョブを作成する（パラメータの検証・上限はREST APIと同じ）
+	CreateJob(ctx context.Context, in *CreateJobRequest, opts ...grpc.CallOption) (*CreateJobResponse, error)
+	// GetJob ジョブの状態を返す
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// WatchJob ジョブの状態の変化を送る（最初に現在の状態を送り、完了・失敗・キャンセルで終わる）
+	WatchJob(ctx context.Context, in *WatchJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobUpdate], error)
+	// ListAnalyses 解析の一覧を作成日時の新しい順に返す
+	ListAnalyses(ctx context.Context, in *ListAnalysesRequest, opts ...grpc.CallOption) (*ListAnalysesResponse, error)
+}
+
+type dSAServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDSAServiceClient(cc grpc.ClientConnInterface) DSAServiceClient {
+	return &dSAServiceClient{cc}
+}
+
+func (c *dSAServiceClient) CreateJob(ctx context.Context, in *CreateJobRequest, opts ...grpc.CallOption) (*CreateJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateJobResponse)
+	err := c.cc.Invoke(ctx, DSAService_CreateJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dSAServiceClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, DSAService_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dSAServiceClient) WatchJob(ctx context.Context, in *WatchJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[JobUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DSAService_ServiceDesc.Streams[0], DSAService_WatchJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchJobRequest, JobUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DSAService_WatchJobClient = grpc.ServerStreamingClient[JobUpdate]
+
+func (c *dSAServiceClient) ListAnalyses(ctx context.Context, in *ListAnalysesRequest, opts ...grpc.CallOption) (*ListAnalysesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAnalysesResponse)
+	err := c.cc.Invoke(ctx, DSAService_ListAnalyses_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DSAServiceServer is the server API for DSAService service.
+// All implementations must embed UnimplementedDSAServiceServer
+// for forward compatibility.
+//
+// DSAService ジョブマネージャーの操作（REST APIの POST /api/jobs・GET /api/jobs/:id・GET /api/jobs に相当）
+type DSAServiceServer interface {
+	// CreateJob 解析ジョブを作成する（パラメータの検証・上限はREST APIと同じ）
+	CreateJob(context.Context, *CreateJobRequest) (*CreateJobResponse, error)
+	// GetJob ジョブの状態を返す
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// WatchJob ジョブの状態の変化を送る（最初に現在の状態を送り、完了・失敗・キャンセルで終わる）
+	WatchJob(*WatchJobRequest, grpc.ServerStreamingServer[JobUpdate]) error
+	// ListAnalyses 解析の一覧を作成日時の新しい順に返す
+	ListAnalyses(context.Context, *ListAnalysesRequest) (*ListAnalysesResponse, error)
+	mustEmbedUnimplementedDSAServiceServer()
+}
+
+// UnimplementedDSAServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDSAServiceServer struct{}
+
+func (UnimplementedDSAServiceServer) CreateJob(context.Context, *CreateJobRequest) (*CreateJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateJob not implemented")
+}
+func (UnimplementedDSAServiceServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedDSAServiceServer) WatchJob(*WatchJobRequest, grpc.ServerStreamingServer[JobUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchJob not implemented")
+}
+func (UnimplementedDSAServiceServer) ListAnalyses(context.Context, *ListAnalysesRequest) (*ListAnalysesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAnalyses not implemented")
+}
+func (UnimplementedDSAServiceServer) mustEmbedUnimplementedDSAServiceServer() {}
+func (UnimplementedDSAServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeDSAServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DSAServiceServer will
+// result in compilation errors.
+type UnsafeDSAServiceServer interface {
+	mustEmbedUnimplementedDSAServiceServer()
+}
+
+func RegisterDSAServiceServer(s grpc.ServiceRegistrar, srv DSAServiceServer) {
+	// If the following call pancis, it indicates UnimplementedDSAServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DSAService_ServiceDesc, srv)
+}
+
+func _DSAService_CreateJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DSAServiceServer).CreateJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DSAService_CreateJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DSAServiceServer).CreateJob(ctx, req.(*CreateJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DSAService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DSAServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DSAService_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DSAServiceServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DSAService_WatchJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DSAServiceServer).WatchJob(m, &grpc.GenericServerStream[WatchJobRequest, JobUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DSAService_WatchJobServer = grpc.ServerStreamingServer[JobUpdate]
+
+func _DSAService_ListAnalyses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAnalysesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DSAServiceServer).ListAnalyses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DSAService_ListAnalyses_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DSAServiceServer).ListAnalyses(ctx, req.(*ListAnalysesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DSAService_ServiceDesc is the grpc.ServiceDesc for DSAService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DSAService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dsa.v1.DSAService",
+	HandlerType: (*DSAServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateJob",
+			Handler:    _DSAService_CreateJob_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _DSAService_GetJob_Handler,
+		},
+		{
+			MethodName: "ListAnalyses",
+			Handler:    _DSAService_ListAnalyses_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchJob",
+			Handler:       _DSAService_WatchJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dsa.proto",
+}
@@ -0,0 +1,320 @@
+// Package grpcapi ジョブマネージャーのgRPCサービス（内部ツール・パイプライン連携用）
+// 定義はdsapb/dsa.proto。REST APIと同じジョブマネージャーを使い、APIキー（API_KEYS）による認証を必須とする
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"dsa-api/grpcapi/dsapb"
+	"dsa-api/jobs"
+	"dsa-api/logging"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// WatchJobで購読の通知が溢れた場合に備えて状態を確認する間隔
+const watchPollInterval = 30 * time.Second
+
+// Server DSAServiceの実装
+type Server struct {
+	dsapb.UnimplementedDSAServiceServer
+	manager  *jobs.Manager
+	apiKeys  []string
+	readOnly bool
+	grpc     *grpc.Server
+}
+
+// NewServer APIキーが1つもない場合はエラーを返す（すべてのセッションの解析を扱うため認証なしでは起動しない）
+func NewServer(manager *jobs.Manager, apiKeys []string, readOnly bool) (*Server, error) {
+	s := &Server{manager: manager, readOnly: readOnly}
+	for _, key := range apiKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			s.apiKeys = append(s.apiKeys, key)
+		}
+	}
+	if len(s.apiKeys) == 0 {
+		return nil, errors.New("gRPC service requires API_KEYS")
+	}
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+	dsapb.RegisterDSAServiceServer(s.grpc, s)
+	return s, nil
+}
+
+// Serve lisで接続を受け付ける（GracefulStopまで戻らない）
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// GracefulStop 処理中のRPCの完了を待って停止する
+func (s *Server) GracefulStop() {
+	s.grpc.GracefulStop()
+}
+
+type requestIDKey struct{}
+
+// requestID metadataのx-request-id（ない場合はインターセプターで生成したもの）
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// authenticate metadataのx-api-keyまたはauthorization: Bearer <key>を確認し、リクエストIDをctxに付ける
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstValue(md, "x-request-id")
+	if id == "" || len(id) > 128 {
+		id = uuid.New().String()
+	}
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+
+	key := firstValue(md, "x-api-key")
+	if key == "" {
+		key = strings.TrimPrefix(firstValue(md, "authorization"), "Bearer ")
+	}
+	if !s.validAPIKey(key) {
+		return ctx, status.Error(codes.Unauthenticated, "valid API key is required")
+	}
+	return ctx, nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// validAPIKey タイミング攻撃を避けるため定数時間で比較する
+func (s *Server) validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for _, known := range s.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, err := s.authenticate(ctx)
+	var resp any
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	logRPC(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// authStream 認証で付けたリクエストIDをハンドラーに渡す
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Server) streamInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, err := s.authenticate(stream.Context())
+	if err == nil {
+		err = handler(srv, &authStream{ServerStream: stream, ctx: ctx})
+	}
+	logRPC(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// logRPC REST APIのリクエストログと同じ形式で出力する（内部エラーはerror）
+func logRPC(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	logger := logging.With(
+		"request_id", requestID(ctx),
+		"method", method,
+		"code", code.String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	switch code {
+	case codes.OK:
+		logger.Infof("gRPC %s %s", method, code)
+	case codes.Internal, codes.Unknown, codes.Unavailable:
+		logger.Errorf("gRPC %s %s: %v", method, code, err)
+	default:
+		logger.Warnf("gRPC %s %s: %v", method, code, err)
+	}
+}
+
+// CreateJob POST /api/jobsと同じ検証でジョブを作成する
+func (s *Server) CreateJob(ctx context.Context, req *dsapb.CreateJobRequest) (*dsapb.CreateJobResponse, error) {
+	if s.readOnly {
+		return nil, status.Error(codes.FailedPrecondition, "server is in read-only mode")
+	}
+	uniprotID := jobs.NormalizeUniProtID(req.GetUniprotId())
+	if uniprotID == "" {
+		return nil, status.Error(codes.InvalidArgument, "uniprot_id is required")
+	}
+	if !jobs.ValidUniProtID(uniprotID) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid UniProt ID format: %s", uniprotID)
+	}
+
+	var raw map[string]interface{}
+	if req.GetParams() != nil {
+		raw = req.GetParams().AsMap()
+	}
+	params, err := jobs.ParseAnalysisParams(raw)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	uniprotID, err = jobs.ResolveUniProtVariant(uniprotID, &params)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	params.SessionID = req.GetSessionId()
+	// パイプラインからの投入はキューからの投入と同じくbatchとして扱う
+	switch req.GetClass() {
+	case "", jobs.ClassBatch:
+		params.Class = jobs.ClassBatch
+	case jobs.ClassInteractive:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "class must be %s or %s", jobs.ClassInteractive, jobs.ClassBatch)
+	}
+
+	job, deduplicated, err := s.manager.CreateJobWithOptions(uniprotID, params, jobs.CreateJobOptions{
+		Dedupe:    req.GetDedupe(),
+		NoCache:   req.GetNoCache(),
+		RequestID: requestID(ctx),
+	})
+	if err != nil {
+		return nil, createError(err)
+	}
+	return &dsapb.CreateJobResponse{
+		JobId:        job.ID,
+		Status:       string(job.Status),
+		Deduplicated: deduplicated,
+		Cached:       job.Cached,
+	}, nil
+}
+
+// createError ジョブ作成のエラーをgRPCのステータスに変換する（上限超過はResourceExhausted）
+func createError(err error) error {
+	var quotaErr *jobs.QuotaError
+	if errors.As(err, &quotaErr) {
+		return status.Error(codes.ResourceExhausted, quotaErr.Error())
+	}
+	var envErr *jobs.EnvOverrideError
+	if errors.As(err, &envErr) {
+		return status.Error(codes.InvalidArgument, envErr.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// GetJob ジョブの状態を返す
+func (s *Server) GetJob(ctx context.Context, req *dsapb.GetJobRequest) (*dsapb.Job, error) {
+	job, err := s.manager.GetJobStatus(req.GetJobId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+	return jobProto(job), nil
+}
+
+// WatchJob 現在の状態を送り、以降は完了・失敗・キャンセルまで状態の変化を送る
+func (s *Server) WatchJob(req *dsapb.WatchJobRequest, stream grpc.ServerStreamingServer[dsapb.JobUpdate]) error {
+	jobID := req.GetJobId()
+	// 現在の状態を取得する前に購読し、その間の変化を取りこぼさないようにする
+	updates, unsubscribe := s.manager.Subscribe()
+	defer unsubscribe()
+
+	job, err := s.manager.GetJobStatus(jobID)
+	if err != nil {
+		return status.Error(codes.NotFound, "job not found")
+	}
+	if err := stream.Send(jobUpdateFromJob(job)); err != nil {
+		return err
+	}
+	if terminal(job.Status) {
+		return nil
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case update, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "subscription closed")
+			}
+			if update.JobID != jobID {
+				continue
+			}
+			if err := stream.Send(jobUpdateProto(update)); err != nil {
+				return err
+			}
+			if terminal(update.Status) {
+				return nil
+			}
+		case <-ticker.C:
+			// 購読のバッファが溢れて完了の通知を取りこぼした場合に備える
+			job, err := s.manager.GetJobStatus(jobID)
+			if err != nil {
+				return status.Error(codes.NotFound, "job not found")
+			}
+			if terminal(job.Status) {
+				return stream.Send(jobUpdateFromJob(job))
+			}
+		}
+	}
+}
+
+func terminal(s jobs.JobStatus) bool {
+	return s == jobs.StatusDone || s == jobs.StatusFailed || s == jobs.StatusCancelled
+}
+
+// ListAnalyses 解析の一覧を作成日時の新しい順に返す（GET /api/jobsと同じカーソル形式）
+func (s *Server) ListAnalyses(ctx context.Context, req *dsapb.ListAnalysesRequest) (*dsapb.ListAnalysesResponse, error) {
+	filter := jobs.JobListFilter{
+		Status:    jobs.JobStatus(req.GetStatus()),
+		UniProtID: req.GetUniprotId(),
+		SessionID: req.GetSessionId(),
+		Limit:     int(req.GetLimit()),
+		Cursor:    req.GetCursor(),
+	}
+	switch filter.Status {
+	case "", jobs.StatusQueued, jobs.StatusRunning, jobs.StatusDone, jobs.StatusFailed, jobs.StatusCancelled:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid status: %s", filter.Status)
+	}
+	if req.GetCreatedAfter() != nil {
+		filter.CreatedAfter = req.GetCreatedAfter().AsTime()
+	}
+
+	page, err := s.manager.ListJobs(ctx, filter)
+	if errors.Is(err, jobs.ErrInvalidCursor) {
+		return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &dsapb.ListAnalysesResponse{NextCursor: page.NextCursor}
+	for _, job := range page.Jobs {
+		resp.Analyses = append(resp.Analyses, jobProto(job))
+	}
+	return resp, nil
+}
@@ -0,0 +1,348 @@
+package intake
+
+import (
+	"dsa-api/jobs"
+	"dsa-api/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultGroup コンシューマーグループ名を指定しない場合のデフォルト
+	DefaultGroup = "dsa-api"
+	// DefaultClaimIdle ジョブを作成できなかった（ACKしていない）メッセージを再処理するまでの時間
+	DefaultClaimIdle = time.Minute
+	// 1回に読み込むメッセージの数
+	readBatchSize = 10
+	// 新しいメッセージを待つ時間（XREADGROUPのBLOCK）
+	readBlock = 5 * time.Second
+	// Redisに接続できない場合に再接続するまでの待ち時間
+	reconnectDelay = 5 * time.Second
+	// 結果のストリームに保持するエントリの目安（XADD MAXLEN ~）
+	resultStreamMaxLen = 10000
+	// リクエストを入れるエントリのフィールド
+	payloadField = "payload"
+)
+
+// Config メッセージキュー（Redis Streams）からのジョブの受付の設定
+type Config struct {
+	// Redisの接続先（redis://[:password@]host:6379/0、TLSはrediss://）
+	URL string
+	// ジョブのリクエストを読み込むストリーム
+	Stream string
+	// コンシューマーグループ名（空の場合はDefaultGroup、複数のサーバーで同じグループを使うと分担して処理する）
+	Group string
+	// コンシューマー名（空の場合はホスト名）
+	Consumer string
+	// 作成したジョブのID・受け付けなかった理由を書き込むストリーム（空の場合は書き込まない）
+	ResultStream string
+	// ACKしていないメッセージを再処理するまでの時間（0以下はDefaultClaimIdle）
+	ClaimIdle time.Duration
+}
+
+// JobRequest メッセージのpayloadフィールドのJSON（POST /api/jobsのリクエストと同じ形式）
+type JobRequest struct {
+	UniProtID string                 `json:"uniprot_id"`
+	Params    map[string]interface{} `json:"params"`
+	Dedupe    bool                   `json:"dedupe"`
+	NoCache   bool                   `json:"no_cache"`
+	// 送信側がリクエストを識別するためのID（結果のストリームにそのまま書き込む）
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// rejectedError 再試行しても受け付けられないメッセージ（ACKして結果のストリームに理由を書き込む）
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rejectedError) Unwrap() error {
+	return e.err
+}
+
+// Consumer ストリームのメッセージからジョブを作成し、作成した後にACKする
+// ジョブを作成できなかったメッセージ（クォータ超過・DBのエラー等）はACKせず、ClaimIdleを過ぎてから再処理する
+type Consumer struct {
+	cfg     Config
+	manager *jobs.Manager
+	client  *redisClient
+
+	groupReady  bool
+	claimCursor string
+	lastClaim   time.Time
+}
+
+// NewConsumer 設定を検証してConsumerを作成する（Redisへの接続はStartの後に行う）
+func NewConsumer(cfg Config, manager *jobs.Manager) (*Consumer, error) {
+	if cfg.Stream == "" {
+		return nil, fmt.Errorf("stream is required")
+	}
+	client, err := newRedisClient(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Group == "" {
+		cfg.Group = DefaultGroup
+	}
+	if cfg.Consumer == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "dsa-api"
+		}
+		cfg.Consumer = hostname
+	}
+	if cfg.ClaimIdle <= 0 {
+		cfg.ClaimIdle = DefaultClaimIdle
+	}
+	return &Consumer{
+		cfg:         cfg,
+		manager:     manager,
+		client:      client,
+		claimCursor: "0-0",
+	}, nil
+}
+
+// Stream 読み込むストリーム名
+func (c *Consumer) Stream() string {
+	return c.cfg.Stream
+}
+
+// Group コンシューマーグループ名
+func (c *Consumer) Group() string {
+	return c.cfg.Group
+}
+
+// Start バックグラウンドでメッセージの読み込みを開始する（Redisに接続できない間は再接続を続ける）
+func (c *Consumer) Start() {
+	go func() {
+		for {
+			if err := c.poll(); err != nil {
+				logging.Warnf("Queue consumer (%s): %v, retrying in %s", c.cfg.Stream, err, reconnectDelay)
+				c.client.close()
+				c.groupReady = false
+				time.Sleep(reconnectDelay)
+			}
+		}
+	}()
+}
+
+// poll 再処理が必要なメッセージと新しいメッセージを1回ずつ読み込んで処理する
+func (c *Consumer) poll() error {
+	if !c.groupReady {
+		if err := c.ensureGroup(); err != nil {
+			return err
+		}
+		c.groupReady = true
+	}
+
+	if time.Since(c.lastClaim) >= c.cfg.ClaimIdle {
+		if err := c.claimPending(); err != nil {
+			return err
+		}
+		// 引き取るメッセージが残っている場合（カーソルが0-0に戻っていない場合）は次回も続ける
+		if c.claimCursor == "0-0" {
+			c.lastClaim = time.Now()
+		}
+	}
+
+	reply, err := c.client.do(readBlock+redisCommandTimeout,
+		"XREADGROUP", "GROUP", c.cfg.Group, c.cfg.Consumer,
+		"COUNT", strconv.Itoa(readBatchSize),
+		"BLOCK", strconv.FormatInt(readBlock.Milliseconds(), 10),
+		"STREAMS", c.cfg.Stream, ">")
+	if err != nil {
+		var replyErr redisError
+		if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "NOGROUP") {
+			// ストリームが削除された場合はグループを作り直す
+			c.groupReady = false
+			return nil
+		}
+		return fmt.Errorf("XREADGROUP failed: %w", err)
+	}
+	// タイムアウトした場合はnil、それ以外は [[stream, entries]]
+	streams, _ := reply.([]interface{})
+	for _, s := range streams {
+		pair, ok := s.([]interface{})
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		entries, err := parseStreamEntries(pair[1])
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := c.process(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ensureGroup コンシューマーグループを作成する（ストリームがなければ作成し、既にあれば何もしない）
+// 新しく作成したグループはストリームの最初のメッセージから読み込む（サーバーの初回起動前に送られたリクエストも処理する）
+func (c *Consumer) ensureGroup() error {
+	_, err := c.client.do(redisCommandTimeout, "XGROUP", "CREATE", c.cfg.Stream, c.cfg.Group, "0", "MKSTREAM")
+	var replyErr redisError
+	if errors.As(err, &replyErr) && strings.HasPrefix(string(replyErr), "BUSYGROUP") {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create consumer group %s: %w", c.cfg.Group, err)
+	}
+	logging.Infof("Created consumer group %s on stream %s", c.cfg.Group, c.cfg.Stream)
+	return nil
+}
+
+// claimPending ClaimIdleを過ぎてもACKされていないメッセージ（このサーバーで作成に失敗した・停止したコンシューマーが読み込んだもの）を引き取って処理する
+// XAUTOCLAIMを使うためRedis 6.2以上が必要
+func (c *Consumer) claimPending() error {
+	reply, err := c.client.do(redisCommandTimeout,
+		"XAUTOCLAIM", c.cfg.Stream, c.cfg.Group, c.cfg.Consumer,
+		strconv.FormatInt(c.cfg.ClaimIdle.Milliseconds(), 10), c.claimCursor,
+		"COUNT", strconv.Itoa(readBatchSize))
+	if err != nil {
+		return fmt.Errorf("XAUTOCLAIM failed: %w", err)
+	}
+	// [次のカーソル, entries, (Redis 7以降)削除済みのID]
+	items, ok := reply.([]interface{})
+	if !ok || len(items) < 2 {
+		return fmt.Errorf("unexpected XAUTOCLAIM reply: %T", reply)
+	}
+	if cursor, ok := items[0].(string); ok {
+		c.claimCursor = cursor
+	}
+	entries, err := parseStreamEntries(items[1])
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.process(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// process 1つのメッセージからジョブを作成し、作成した（または受け付けられない）場合にACKする
+// 返すエラーはRedisとの通信のエラーのみ
+func (c *Consumer) process(entry streamEntry) error {
+	if entry.Fields == nil {
+		// 削除済みのエントリ
+		return c.ack(entry.ID)
+	}
+
+	req, job, deduplicated, err := c.createJob(entry)
+	var rejected *rejectedError
+	switch {
+	case errors.As(err, &rejected):
+		logging.Warnf("Rejected queue message %s: %v", entry.ID, err)
+		if err := c.writeResult(entry.ID, req.RequestID, map[string]string{
+			"status": "rejected",
+			"error":  err.Error(),
+		}); err != nil {
+			return err
+		}
+		return c.ack(entry.ID)
+	case err != nil:
+		// ACKせずにClaimIdleを過ぎてから再処理する
+		logging.Warnf("Failed to create job from queue message %s (will retry after %s): %v", entry.ID, c.cfg.ClaimIdle, err)
+		return nil
+	}
+
+	logging.Infof("Queue message %s: job %s (%s)", entry.ID, job.ID, job.UniProtID)
+	if err := c.writeResult(entry.ID, req.RequestID, map[string]string{
+		"status":       "created",
+		"job_id":       job.ID,
+		"job_status":   string(job.Status),
+		"deduplicated": strconv.FormatBool(deduplicated),
+		"cached":       strconv.FormatBool(job.Cached),
+	}); err != nil {
+		return err
+	}
+	return c.ack(entry.ID)
+}
+
+// createJob メッセージをPOST /api/jobsと同じ手順で検証してジョブを作成する
+// 形式・パラメータが不正なメッセージは*rejectedError
+func (c *Consumer) createJob(entry streamEntry) (JobRequest, *jobs.Job, bool, error) {
+	var req JobRequest
+	payload, ok := entry.Fields[payloadField]
+	if !ok {
+		return req, nil, false, &rejectedError{fmt.Errorf("%s field is required", payloadField)}
+	}
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return req, nil, false, &rejectedError{fmt.Errorf("invalid payload: %w", err)}
+	}
+
+	if req.UniProtID == "" {
+		return req, nil, false, &rejectedError{fmt.Errorf("uniprot_id is required")}
+	}
+	req.UniProtID = jobs.NormalizeUniProtID(req.UniProtID)
+	if !jobs.ValidUniProtID(req.UniProtID) {
+		return req, nil, false, &rejectedError{fmt.Errorf("Invalid UniProt ID format: %s", req.UniProtID)}
+	}
+	params, err := jobs.ParseAnalysisParams(req.Params)
+	if err != nil {
+		return req, nil, false, &rejectedError{err}
+	}
+	accession, err := jobs.ResolveUniProtVariant(req.UniProtID, &params)
+	if err != nil {
+		return req, nil, false, &rejectedError{err}
+	}
+	// キューからの投入はbatchとして扱い、JOB_CLASS_RESERVATIONSでinteractiveに予約した実行枠を使わない
+	params.Class = jobs.ClassBatch
+
+	job, deduplicated, err := c.manager.CreateJobWithOptions(accession, params, jobs.CreateJobOptions{
+		Dedupe:    req.Dedupe,
+		NoCache:   req.NoCache,
+		RequestID: req.RequestID,
+	})
+	var envErr *jobs.EnvOverrideError
+	if errors.As(err, &envErr) {
+		return req, nil, false, &rejectedError{err}
+	}
+	if err != nil {
+		return req, nil, false, err
+	}
+	return req, job, deduplicated, nil
+}
+
+// writeResult 結果のストリームにメッセージの処理結果を書き込む（ResultStreamが空の場合は何もしない）
+func (c *Consumer) writeResult(messageID, requestID string, fields map[string]string) error {
+	if c.cfg.ResultStream == "" {
+		return nil
+	}
+	args := []string{"XADD", c.cfg.ResultStream, "MAXLEN", "~", strconv.Itoa(resultStreamMaxLen), "*",
+		"message_id", messageID}
+	if requestID != "" {
+		args = append(args, "request_id", requestID)
+	}
+	for _, name := range []string{"status", "job_id", "job_status", "deduplicated", "cached", "error"} {
+		if value, ok := fields[name]; ok {
+			args = append(args, name, value)
+		}
+	}
+	if _, err := c.client.do(redisCommandTimeout, args...); err != nil {
+		return fmt.Errorf("failed to write result to %s: %w", c.cfg.ResultStream, err)
+	}
+	return nil
+}
+
+// ack メッセージの処理を完了する
+func (c *Consumer) ack(id string) error {
+	if _, err := c.client.do(redisCommandTimeout, "XACK", c.cfg.Stream, c.cfg.Group, id); err != nil {
+		return fmt.Errorf("XACK failed: %w", err)
+	}
+	return nil
+}
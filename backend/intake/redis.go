@@ -0,0 +1,243 @@
+package intake
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisDialTimeout = 10 * time.Second
+	// ブロッキングしないコマンドの応答待ちのタイムアウト
+	redisCommandTimeout = 10 * time.Second
+)
+
+// redisError Redisが返したエラー応答（-ERR ...）。接続は引き続き使える
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient Redis Streamsの読み書きに必要な最小限のRESPクライアント（1接続、goroutine間で共有しない）
+type redisClient struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// newRedisClient redis://[user:password@]host:port/db の形式のURLを解析する（TLSはrediss://、接続は最初のコマンドで行う）
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis URL: unsupported scheme %q (use redis:// or rediss://)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid redis URL: host is required")
+	}
+	c := &redisClient{
+		addr:   u.Host,
+		useTLS: u.Scheme == "rediss",
+	}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+		// redis://:password@host の形式はパスワードのみ（AUTH password）
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		db, err := strconv.Atoi(path)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("invalid redis URL: invalid database %q", path)
+		}
+		c.db = db
+	}
+	return c, nil
+}
+
+// connect 接続してAUTH・SELECTを行う
+func (c *redisClient) connect() error {
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var conn net.Conn
+	var err error
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := c.do(redisCommandTimeout, args...); err != nil {
+			c.close()
+			return fmt.Errorf("redis AUTH failed: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.do(redisCommandTimeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			c.close()
+			return fmt.Errorf("redis SELECT failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// close 接続を閉じる（次のコマンドで再接続する）
+func (c *redisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+		c.reader = nil
+	}
+}
+
+// do コマンドを送信して応答を返す（timeoutは応答を待つ時間）
+// エラー応答はredisError、それ以外のエラーの場合は接続を閉じる
+func (c *redisClient) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	c.conn.SetDeadline(time.Now().Add(timeout))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		c.close()
+		return nil, err
+	}
+	reply, err := c.readReply()
+	if err != nil {
+		var replyErr redisError
+		if !errors.As(err, &replyErr) {
+			c.close()
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// readReply RESP2の応答を読み込む（文字列は string、整数は int64、nil応答は nil、配列は []interface{}）
+func (c *redisClient) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("invalid redis reply: empty line")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := c.readReply()
+			// 配列内のエラー応答は値として扱う（残りの要素を読み捨てないため）
+			var replyErr redisError
+			if errors.As(err, &replyErr) {
+				items = append(items, replyErr)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("invalid redis reply: %q", line)
+}
+
+// streamEntry ストリームのエントリ（削除済みのエントリはFieldsがnil）
+type streamEntry struct {
+	ID     string
+	Fields map[string]string
+}
+
+// parseStreamEntries [[id, [field, value, ...]], ...] の形式の応答を変換する
+func parseStreamEntries(reply interface{}) ([]streamEntry, error) {
+	items, ok := reply.([]interface{})
+	if !ok {
+		if reply == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected stream entries: %T", reply)
+	}
+	entries := make([]streamEntry, 0, len(items))
+	for _, item := range items {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			// XAUTOCLAIM（Redis 6.2）は削除済みのエントリをnilで返す
+			continue
+		}
+		id, _ := pair[0].(string)
+		entry := streamEntry{ID: id}
+		if values, ok := pair[1].([]interface{}); ok {
+			entry.Fields = make(map[string]string, len(values)/2)
+			for i := 0; i+1 < len(values); i += 2 {
+				field, _ := values[i].(string)
+				value, _ := values[i+1].(string)
+				entry.Fields[field] = value
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
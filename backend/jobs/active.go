@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ジョブの段階（ダッシュボードの表示用、進捗の範囲から決める）
+const (
+	StageQueued     = "queued"
+	StageStarting   = "starting"
+	StageAnalyzing  = "analyzing"
+	StageRestoring  = "restoring_cache"
+	StageFinalizing = "finalizing"
+	StageUploading  = "uploading"
+)
+
+// 成果物のアップロード中の進捗（executeJobの "Uploading artifacts..."）
+const uploadProgress = 90
+
+// ActiveJob キュー待ち・実行中のジョブの概要（GET /api/jobs/active）
+type ActiveJob struct {
+	ID        string     `json:"id"`
+	UniProtID string     `json:"uniprot_id"`
+	Status    JobStatus  `json:"status"`
+	Progress  int        `json:"progress"`
+	Stage     string     `json:"stage"`
+	CreatedAt time.Time  `json:"created_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+// activeIndex キュー待ち・実行中のジョブの概要の一覧
+// 状態の更新のたびに作成順の一覧を作り直して差し替えるため、読み取りはロックなしでコピーを返すだけになる
+type activeIndex struct {
+	mu      sync.Mutex
+	entries map[string]ActiveJob
+	list    atomic.Pointer[[]ActiveJob]
+}
+
+func newActiveIndex() *activeIndex {
+	idx := &activeIndex{entries: make(map[string]ActiveJob)}
+	idx.list.Store(&[]ActiveJob{})
+	return idx
+}
+
+// update ジョブの概要を更新する（終了したジョブは取り除く、m.muを保持して呼ぶ）
+func (idx *activeIndex) update(entry ActiveJob) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if entry.Status == StatusQueued || entry.Status == StatusRunning {
+		idx.entries[entry.ID] = entry
+	} else if _, ok := idx.entries[entry.ID]; ok {
+		delete(idx.entries, entry.ID)
+	} else {
+		return
+	}
+	idx.publishLocked()
+}
+
+func (idx *activeIndex) remove(jobID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.entries[jobID]; !ok {
+		return
+	}
+	delete(idx.entries, jobID)
+	idx.publishLocked()
+}
+
+// publishLocked 読み取り用の一覧を作り直す（idx.muを保持して呼ぶ）
+func (idx *activeIndex) publishLocked() {
+	list := make([]ActiveJob, 0, len(idx.entries))
+	for _, entry := range idx.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	idx.list.Store(&list)
+}
+
+// activeEntry ジョブの概要を作成する（m.muを保持した状態で呼ぶこと）
+func activeEntry(job *Job) ActiveJob {
+	entry := ActiveJob{
+		ID:        job.ID,
+		UniProtID: job.UniProtID,
+		Status:    job.Status,
+		Progress:  job.Progress,
+		Stage:     jobStage(job),
+		CreatedAt: job.CreatedAt,
+	}
+	if !job.startedAt.IsZero() {
+		startedAt := job.startedAt
+		entry.StartedAt = &startedAt
+	}
+	return entry
+}
+
+// jobStage ジョブの段階（進捗の割り当て: 〜20%は起動、20〜60%はPythonの解析、60〜90%は結果の処理、90%〜はアップロード）
+func jobStage(job *Job) string {
+	if job.Status != StatusRunning {
+		return StageQueued
+	}
+	switch {
+	case job.Progress < pythonProgressStart:
+		return StageStarting
+	case job.Progress < pythonProgressEnd:
+		if job.cacheSource != nil {
+			return StageRestoring
+		}
+		return StageAnalyzing
+	case job.Progress < uploadProgress:
+		return StageFinalizing
+	}
+	return StageUploading
+}
+
+// ActiveJobs キュー待ち・実行中のジョブの概要（作成順）
+// ジョブごとの参照やm.muの取得を行わないため、ダッシュボードから頻繁に呼ばれても他の操作をブロックしない
+func (m *Manager) ActiveJobs() []ActiveJob {
+	list := *m.active.list.Load()
+	return append([]ActiveJob(nil), list...)
+}
@@ -0,0 +1,420 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+)
+
+// 復元コピーを読み出せる日数・取り出し速度の既定値（ARCHIVE_RESTORE_DAYS・ARCHIVE_RESTORE_TIER）
+const (
+	defaultArchiveRestoreDays = 7
+	defaultArchiveRestoreTier = "Standard"
+)
+
+var (
+	// ErrArchiveUnavailable 長期保存先・DB・R2のいずれかが設定されていない
+	ErrArchiveUnavailable = errors.New("archiving requires a database, R2 and an archive target (ARCHIVE_BUCKET)")
+	// ErrArchiveNotFinished キュー待ち・実行中の解析は長期保存先に移せない
+	ErrArchiveNotFinished = errors.New("only finished analyses can be archived")
+	// ErrArchiveExpired 成果物が保持期間を過ぎて削除されている
+	ErrArchiveExpired = errors.New("artifacts of the analysis have expired")
+	// ErrArchiveConflict 復元待ちの解析を移そうとした、または長期保存中でない解析を復元しようとした
+	ErrArchiveConflict = errors.New("archive is not in a state that allows this operation")
+)
+
+// 取り出し速度ごとの復元にかかる時間の目安の上限（ストレージクラスごと、AWSの公表値）
+var archiveRestoreLatency = map[string]map[string]time.Duration{
+	"GLACIER": {
+		"Expedited": 5 * time.Minute,
+		"Standard":  5 * time.Hour,
+		"Bulk":      12 * time.Hour,
+	},
+	"DEEP_ARCHIVE": {
+		"Standard": 12 * time.Hour,
+		"Bulk":     48 * time.Hour,
+	},
+}
+
+// archiver 長期保存先（S3 Glacier等）への移動と復元
+type archiver struct {
+	client      *storage.ArchiveClient
+	restoreDays int
+	restoreTier string
+	// 移動・復元中の解析（同じ解析を同時に処理しない）
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// SetArchive 長期保存先を設定する（restoreDaysは復元コピーを読み出せる日数、restoreTierはExpedited・Standard・Bulk）
+func (m *Manager) SetArchive(client *storage.ArchiveClient, restoreDays int, restoreTier string) error {
+	if restoreDays <= 0 {
+		restoreDays = defaultArchiveRestoreDays
+	}
+	if restoreTier == "" {
+		restoreTier = defaultArchiveRestoreTier
+	}
+	if latencies, ok := archiveRestoreLatency[client.StorageClass()]; ok {
+		if _, ok := latencies[restoreTier]; !ok {
+			return fmt.Errorf("restore tier %s is not available for %s", restoreTier, client.StorageClass())
+		}
+	} else if restoreTier != "Expedited" && restoreTier != "Standard" && restoreTier != "Bulk" {
+		return fmt.Errorf("unknown restore tier: %s", restoreTier)
+	}
+	m.archive = &archiver{
+		client:      client,
+		restoreDays: restoreDays,
+		restoreTier: restoreTier,
+		running:     make(map[string]bool),
+	}
+	return nil
+}
+
+// ArchiveEnabled 長期保存先に移せるか（DB・R2・長期保存先がすべて設定されている）
+func (m *Manager) ArchiveEnabled() bool {
+	return m.archive != nil && m.db != nil && m.r2 != nil
+}
+
+// RestoreEstimate 復元の完了見込み（要求日時に取り出し速度の目安の上限を足したもの、復元待ちでない場合はnil）
+func RestoreEstimate(a *storage.AnalysisArchive) *time.Time {
+	if a == nil || a.Status != storage.ArchiveStatusRestoring || a.RestoreRequestedAt == nil || a.RestoreTier == nil {
+		return nil
+	}
+	latency := archiveRestoreLatency[a.StorageClass][*a.RestoreTier]
+	estimate := a.RestoreRequestedAt.Add(latency)
+	return &estimate
+}
+
+// AnalysisArchive 解析の長期保存の状態（長期保存先に移していない場合・無効な場合はnil）
+func (m *Manager) AnalysisArchive(ctx context.Context, id string) *storage.AnalysisArchive {
+	if !m.ArchiveEnabled() {
+		return nil
+	}
+	a, err := m.db.GetAnalysisArchive(ctx, id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logging.Warnf("Failed to get archive state of %s (apply migrations/008_create_analysis_archives.sql): %v", id, err)
+		}
+		return nil
+	}
+	return a
+}
+
+// ArchiveAnalysis 解析の成果物を長期保存先に移す（R2からコピーし、完了したらR2から削除する）
+// 移動はバックグラウンドで行い、2つ目の戻り値は今回移動を開始した場合にtrue（移動中・長期保存中の場合はfalse）
+func (m *Manager) ArchiveAnalysis(ctx context.Context, id string) (*storage.AnalysisArchive, bool, error) {
+	if !m.ArchiveEnabled() {
+		return nil, false, ErrArchiveUnavailable
+	}
+	record, err := m.db.GetAnalysisContext(ctx, id)
+	if err != nil || record == nil {
+		return nil, false, ErrAnalysisNotFound
+	}
+	if record.Status != string(StatusDone) && record.Status != string(StatusFailed) && record.Status != string(StatusCancelled) {
+		return nil, false, ErrArchiveNotFinished
+	}
+	expired, err := m.db.ArtifactsExpiredAt(ctx, []string{id})
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := expired[id]; ok {
+		return nil, false, ErrArchiveExpired
+	}
+
+	// 復元済みの場合は長期保存先のオブジェクトが残っているため、コピーせずにR2から削除するだけでよい
+	var reuse []storage.ArchivedObject
+	if prev := m.AnalysisArchive(ctx, id); prev != nil {
+		switch prev.Status {
+		case storage.ArchiveStatusArchiving, storage.ArchiveStatusArchived:
+			return prev, false, nil
+		case storage.ArchiveStatusRestoring:
+			return prev, false, ErrArchiveConflict
+		case storage.ArchiveStatusRestored:
+			reuse = prev.Objects
+		}
+	}
+
+	started, err := m.db.StartAnalysisArchive(id, m.archive.client.StorageClass())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to record archive (apply migrations/008_create_analysis_archives.sql): %w", err)
+	}
+	a, err := m.db.GetAnalysisArchive(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	if !started {
+		// 同時に要求された
+		return a, false, nil
+	}
+	go m.runArchive(id, reuse)
+	return a, true, nil
+}
+
+// runArchive R2のオブジェクトを長期保存先にコピーし、すべてコピーできたらR2から削除する
+// 途中で失敗した場合はR2のオブジェクトを残してfailedを記録する（再度POSTすると最初からやり直す）
+func (m *Manager) runArchive(id string, reuse []storage.ArchivedObject) {
+	if !m.archive.begin(id) {
+		return
+	}
+	defer m.archive.end(id)
+
+	objects, err := m.copyToArchive(id, reuse)
+	if err != nil {
+		logging.Warnf("Failed to archive %s: %v", id, err)
+		if err := m.db.FailAnalysisArchive(id, err.Error()); err != nil {
+			logging.Warnf("Failed to record archive failure of %s: %v", id, err)
+		}
+		m.notifyChange(id, false)
+		return
+	}
+	if err := m.db.CompleteAnalysisArchive(id, objects); err != nil {
+		// R2のオブジェクトは残っているため、記録できなければ削除しない（再起動時に移動をやり直す）
+		logging.Warnf("Failed to record archive of %s: %v", id, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+	err = m.r2.DeleteObjectsWithPrefix(ctx, m.artifactPrefix(id)+"/")
+	cancel()
+	if err != nil {
+		logging.Warnf("Archived %s but failed to delete R2 objects: %v", id, err)
+		m.db.SetAnalysisArchiveError(id, fmt.Sprintf("failed to delete R2 objects: %v", err))
+	}
+
+	var bytes int64
+	for _, obj := range objects {
+		bytes += obj.Size
+	}
+	logging.Infof("Archived %s (%d objects, %d bytes, %s)", id, len(objects), bytes, m.archive.client.StorageClass())
+	m.recordEvent(id, JobEvent{
+		Type:    EventArchived,
+		Message: fmt.Sprintf("Artifacts moved to %s storage", m.archive.client.StorageClass()),
+		Data:    map[string]interface{}{"objects": len(objects), "bytes": bytes},
+	})
+	m.notifyChange(id, false)
+}
+
+// copyToArchive R2の解析のオブジェクトを長期保存先にコピーする（reuseに含まれるキーはコピー済みとして扱う）
+func (m *Manager) copyToArchive(id string, reuse []storage.ArchivedObject) ([]storage.ArchivedObject, error) {
+	archived := make(map[string]storage.ArchivedObject, len(reuse))
+	for _, obj := range reuse {
+		archived[obj.Key] = obj
+	}
+
+	listCtx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+	listed, err := m.r2.ListObjectsWithPrefix(listCtx, m.artifactPrefix(id)+"/")
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list R2 objects: %w", err)
+	}
+	if len(listed) == 0 {
+		return nil, fmt.Errorf("no artifacts found in R2")
+	}
+
+	objects := make([]storage.ArchivedObject, 0, len(listed))
+	for _, info := range listed {
+		if obj, ok := archived[info.Key]; ok && obj.Size == info.Size {
+			objects = append(objects, obj)
+			continue
+		}
+		obj := storage.ArchivedObject{Key: info.Key, Size: info.Size, ContentType: archiveContentType(info.Key)}
+		ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+		data, err := m.r2.GetObject(ctx, info.Key)
+		if err == nil {
+			err = m.archive.client.PutObject(ctx, info.Key, data, obj.ContentType)
+		}
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", info.Key, err)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// archiveContentType 登録されている成果物はそのContent-Type、それ以外（チェックポイント等）はバイナリ
+func archiveContentType(key string) string {
+	if artifact, ok := LookupArtifact(path.Base(key)); ok {
+		return artifact.ContentType
+	}
+	return "application/octet-stream"
+}
+
+// RequestArchiveRestore 長期保存中の解析の復元を要求する（完了までストレージクラス・取り出し速度に応じて数分〜数日かかる）
+// 復元が完了するとStartArchiveWorkerの定期的な確認でR2に戻し、成果物を配信できるようにする
+// 2つ目の戻り値は今回要求した場合にtrue（既に復元待ち・復元済みの場合はfalse）
+func (m *Manager) RequestArchiveRestore(ctx context.Context, id string) (*storage.AnalysisArchive, bool, error) {
+	if !m.ArchiveEnabled() {
+		return nil, false, ErrArchiveUnavailable
+	}
+	a := m.AnalysisArchive(ctx, id)
+	if a == nil {
+		return nil, false, ErrAnalysisNotFound
+	}
+	switch a.Status {
+	case storage.ArchiveStatusRestoring, storage.ArchiveStatusRestored:
+		return a, false, nil
+	case storage.ArchiveStatusArchived:
+	default:
+		return a, false, ErrArchiveConflict
+	}
+
+	tier := m.archive.restoreTier
+	started, err := m.db.StartArchiveRestore(id, tier)
+	if err != nil {
+		return nil, false, err
+	}
+	if !started {
+		return m.AnalysisArchive(ctx, id), false, nil
+	}
+	for _, obj := range a.Objects {
+		reqCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+		err := m.archive.client.RestoreObject(reqCtx, obj.Key, m.archive.restoreDays, tier)
+		cancel()
+		if err != nil {
+			message := fmt.Sprintf("failed to request restore of %s: %v", obj.Key, err)
+			if err := m.db.CancelArchiveRestore(id, message); err != nil {
+				logging.Warnf("Failed to reset restore state of %s: %v", id, err)
+			}
+			return nil, false, errors.New(message)
+		}
+	}
+
+	a = m.AnalysisArchive(ctx, id)
+	data := map[string]interface{}{"tier": tier, "days": m.archive.restoreDays}
+	if estimate := RestoreEstimate(a); estimate != nil {
+		data["estimated_at"] = estimate.UTC().Format(time.RFC3339)
+	}
+	m.recordEvent(id, JobEvent{Type: EventRestoreRequested, Message: fmt.Sprintf("Restore from archive requested (%s)", tier), Data: data})
+	m.notifyChange(id, false)
+	return a, true, nil
+}
+
+// StartArchiveWorker 復元待ちの解析をintervalごとに確認し、復元が完了したものをR2に戻す
+// 前回の停止で中断された移動も再開する（長期保存先が設定されていない場合は何もしない）
+func (m *Manager) StartArchiveWorker(interval time.Duration) {
+	if !m.ArchiveEnabled() {
+		return
+	}
+	go func() {
+		m.resumeArchives()
+		m.CheckArchiveRestores()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.CheckArchiveRestores()
+		}
+	}()
+}
+
+// resumeArchives 移動中のまま停止した解析の移動をやり直す
+func (m *Manager) resumeArchives() {
+	archives, err := m.db.ListAnalysisArchives([]string{storage.ArchiveStatusArchiving})
+	if err != nil {
+		logging.Warnf("Failed to list pending archives (apply migrations/008_create_analysis_archives.sql): %v", err)
+		return
+	}
+	for _, a := range archives {
+		logging.Infof("Resuming archive of %s", a.AnalysisID)
+		m.runArchive(a.AnalysisID, a.Objects)
+	}
+}
+
+// CheckArchiveRestores 復元待ちの解析を確認し、すべてのオブジェクトの復元が完了したものをR2に戻す
+func (m *Manager) CheckArchiveRestores() {
+	if !m.ArchiveEnabled() {
+		return
+	}
+	archives, err := m.db.ListAnalysisArchives([]string{storage.ArchiveStatusRestoring})
+	if err != nil {
+		logging.Warnf("Failed to list pending restores: %v", err)
+		return
+	}
+	for _, a := range archives {
+		if !m.archive.begin(a.AnalysisID) {
+			continue
+		}
+		if err := m.completeRestore(a); err != nil {
+			logging.Warnf("Restore of %s is not complete: %v", a.AnalysisID, err)
+			if err := m.db.SetAnalysisArchiveError(a.AnalysisID, err.Error()); err != nil {
+				logging.Warnf("Failed to record restore error of %s: %v", a.AnalysisID, err)
+			}
+		}
+		m.archive.end(a.AnalysisID)
+	}
+}
+
+// completeRestore 復元が完了していればR2に戻して記録する（復元中の場合は何もしない）
+func (m *Manager) completeRestore(a *storage.AnalysisArchive) error {
+	var expiresAt *time.Time
+	for _, obj := range a.Objects {
+		ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+		state, err := m.archive.client.RestoreStatus(ctx, obj.Key)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to check restore status of %s: %w", obj.Key, err)
+		}
+		if !state.Ready {
+			return nil
+		}
+		if state.ExpiresAt != nil && (expiresAt == nil || state.ExpiresAt.Before(*expiresAt)) {
+			expiresAt = state.ExpiresAt
+		}
+	}
+
+	for _, obj := range a.Objects {
+		ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+		data, err := m.archive.client.GetObject(ctx, obj.Key)
+		if err == nil {
+			err = m.r2.PutObject(ctx, obj.Key, data, obj.ContentType)
+		}
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to copy %s back to R2: %w", obj.Key, err)
+		}
+	}
+	if err := m.db.CompleteArchiveRestore(a.AnalysisID, expiresAt); err != nil {
+		return fmt.Errorf("failed to record restore: %w", err)
+	}
+
+	elapsed := time.Duration(0)
+	if a.RestoreRequestedAt != nil {
+		elapsed = time.Since(*a.RestoreRequestedAt).Round(time.Minute)
+	}
+	logging.Infof("Restored %s from archive (%d objects, %s after request)", a.AnalysisID, len(a.Objects), elapsed)
+	m.recordEvent(a.AnalysisID, JobEvent{
+		Type:    EventRestored,
+		Message: "Artifacts restored from archive",
+		Data:    map[string]interface{}{"objects": len(a.Objects), "elapsed_seconds": int(elapsed.Seconds())},
+	})
+	m.notifyChange(a.AnalysisID, false)
+	return nil
+}
+
+// hasArchive 長期保存先に移した（移動中・復元済みを含む）解析か（成果物の保持期間による削除の対象外にする）
+func (m *Manager) hasArchive(id string) bool {
+	a := m.AnalysisArchive(context.Background(), id)
+	return a != nil && a.Status != storage.ArchiveStatusFailed
+}
+
+func (a *archiver) begin(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running[id] {
+		return false
+	}
+	a.running[id] = true
+	return true
+}
+
+func (a *archiver) end(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.running, id)
+}
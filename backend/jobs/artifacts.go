@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"dsa-api/storage"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ArtifactSpec 解析の成果物の定義
+// アップロード・スプール・結果キャッシュ・リモートワーカー・API（取得と一覧）はすべてこの定義を参照するため、
+// Pythonの出力を追加する場合はRegisterArtifactで登録するだけでよい
+type ArtifactSpec struct {
+	// API上の名前（/api/analyses/:id/artifacts/:name）
+	Name string
+	// jobDir内のファイル名（空の場合はName）
+	File string
+	// 配信・アップロード時のContent-Type
+	ContentType string
+	// R2キーのパターン（{id}は解析ID、{prefix}は保存形式ごとのプレフィックス、空の場合は {prefix}/<File>）
+	KeyPattern string
+	// エラーメッセージ用の表示名
+	Label string
+	// 必須の成果物（ない場合はアップロード・キャッシュの復元を失敗させる）
+	Required bool
+	// 解析レスポンスのartifactsに載せるURLのフィールド名（空の場合は載せない）
+	URLField string
+	// DBに保存されたR2キー（キー列のない成果物はnil、Keyで推測する）
+	RecordKey func(record *storage.AnalysisRecord) *string
+}
+
+// FileName jobDir内のファイル名
+func (a *ArtifactSpec) FileName() string {
+	if a.File != "" {
+		return a.File
+	}
+	return a.Name
+}
+
+// Key 従来の保存形式（LayoutFlat）で解析IDに対応するR2キー
+func (a *ArtifactSpec) Key(jobID string) string {
+	return a.keyWithPrefix(ArtifactPrefix(jobID), jobID)
+}
+
+// KeyIn 保存形式での解析IDに対応するR2キー
+func (a *ArtifactSpec) KeyIn(layout *StorageLayout, jobID string) string {
+	return a.keyWithPrefix(layout.Prefix(jobID), jobID)
+}
+
+func (a *ArtifactSpec) keyWithPrefix(prefix, jobID string) string {
+	if a.KeyPattern != "" {
+		return strings.NewReplacer("{prefix}", prefix, "{id}", jobID).Replace(a.KeyPattern)
+	}
+	return fmt.Sprintf("%s/%s", prefix, a.FileName())
+}
+
+// ResolveKey DBに保存されたキーを優先し、なければDBに保存されたプレフィックス、それもなければKeyで推測する
+// プレフィックスは保存形式ごとに異なるため、キー列のない成果物も解析の保存形式のまま取得できる
+func (a *ArtifactSpec) ResolveKey(jobID string, record *storage.AnalysisRecord) string {
+	if record != nil && a.RecordKey != nil {
+		if key := a.RecordKey(record); key != nil {
+			return *key
+		}
+	}
+	if record != nil && record.R2Prefix != nil && *record.R2Prefix != "" {
+		return a.keyWithPrefix(*record.R2Prefix, jobID)
+	}
+	return a.Key(jobID)
+}
+
+// ArtifactPrefix 従来の保存形式（LayoutFlat）で解析の成果物をまとめるR2のプレフィックス（削除はこの単位で行う）
+// 解析ごとの保存形式はManagerのartifactPrefixで解決する
+func ArtifactPrefix(jobID string) string {
+	return fmt.Sprintf("analysis/%s", jobID)
+}
+
+var (
+	artifactMu       sync.RWMutex
+	artifactRegistry = []*ArtifactSpec{
+		{
+			Name:        "result.json",
+			ContentType: "application/json",
+			Label:       "Result file",
+			Required:    true,
+			URLField:    "result_url",
+			RecordKey:   func(record *storage.AnalysisRecord) *string { return record.ResultKey },
+		},
+		{
+			Name:        "heatmap.png",
+			ContentType: "image/png",
+			Label:       "Heatmap",
+			URLField:    "heatmap_url",
+			RecordKey:   func(record *storage.AnalysisRecord) *string { return record.HeatmapKey },
+		},
+		{
+			Name:        "dist_score.png",
+			ContentType: "image/png",
+			Label:       "Scatter plot",
+			URLField:    "scatter_url",
+			RecordKey:   func(record *storage.AnalysisRecord) *string { return record.ScatterKey },
+		},
+		{
+			Name:        "score_matrix.json",
+			ContentType: "application/json",
+			Label:       "Score matrix",
+		},
+		{
+			Name:        "structures.json",
+			ContentType: "application/json",
+			Label:       "Structure report",
+		},
+		{
+			Name:        "logs.txt",
+			ContentType: "text/plain; charset=utf-8",
+			Label:       "Logs",
+			URLField:    "logs_url",
+			RecordKey:   func(record *storage.AnalysisRecord) *string { return record.LogsKey },
+		},
+	}
+)
+
+// RegisterArtifact 成果物を登録する（同じ名前の定義があれば置き換える）
+// サーバー・ワーカーの起動前に呼ぶこと
+func RegisterArtifact(spec ArtifactSpec) {
+	artifactMu.Lock()
+	defer artifactMu.Unlock()
+	for i, a := range artifactRegistry {
+		if a.Name == spec.Name {
+			artifactRegistry[i] = &spec
+			return
+		}
+	}
+	artifactRegistry = append(artifactRegistry, &spec)
+}
+
+// Artifacts 登録されている成果物を登録順に返す
+func Artifacts() []*ArtifactSpec {
+	artifactMu.RLock()
+	defer artifactMu.RUnlock()
+	return append([]*ArtifactSpec(nil), artifactRegistry...)
+}
+
+// LookupArtifact 名前から成果物の定義を取得する
+func LookupArtifact(name string) (*ArtifactSpec, bool) {
+	artifactMu.RLock()
+	defer artifactMu.RUnlock()
+	for _, a := range artifactRegistry {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// ArtifactKey 従来の保存形式で名前に対応するR2キー（未登録の名前はプレフィックス直下のファイルとみなす）
+func ArtifactKey(jobID, name string) string {
+	return artifactKeyIn(storageLayout(LayoutFlat), jobID, name)
+}
+
+// artifactKeyIn 保存形式で名前に対応するR2キー
+func artifactKeyIn(layout *StorageLayout, jobID, name string) string {
+	if a, ok := LookupArtifact(name); ok {
+		return a.KeyIn(layout, jobID)
+	}
+	return fmt.Sprintf("%s/%s", layout.Prefix(jobID), name)
+}
+
+// artifactFiles 登録されている成果物のjobDir内のファイル名
+func artifactFiles() []string {
+	specs := Artifacts()
+	files := make([]string, 0, len(specs))
+	for _, a := range specs {
+		files = append(files, a.FileName())
+	}
+	return files
+}
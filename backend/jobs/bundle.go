@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// チェックポイント（作業ディレクトリ）内のPDBファイルのディレクトリ
+const pdbFilesDir = "pdb_files"
+
+// BundleOptions 成果物のzipに含めるもの
+type BundleOptions struct {
+	// 作業ディレクトリのPDBファイル（pdb_files/*.cif）も含める
+	IncludePDB bool
+}
+
+// WriteArtifactBundle 解析の成果物（登録されているもの）をzipにまとめてwに書き込み、含めたファイル数を返す
+// ローカルのジョブディレクトリにあるファイルを優先し、なければR2から取得する（どちらにもない成果物は含めない）
+// PDBファイルはローカルの作業ディレクトリ、なければR2のチェックポイント（CHECKPOINT_UPLOAD=true）から取り出す
+func (m *Manager) WriteArtifactBundle(ctx context.Context, id string, opts BundleOptions, w io.Writer) (int, error) {
+	var record *storage.AnalysisRecord
+	if m.db != nil {
+		r, err := m.db.GetAnalysisContext(ctx, id)
+		if err == nil {
+			record = r
+		}
+	}
+	localDir := m.localJobDir(id)
+	// R2から取得する成果物はアップロード時のSHA-256で検証する（一致しない場合はzipを完成させない）
+	checksums := m.artifactChecksums(ctx, id)
+
+	zw := zip.NewWriter(w)
+	files := 0
+	for _, a := range Artifacts() {
+		getCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+		body, modTime, err := m.bundleArtifact(getCtx, id, a, record, localDir, checksums)
+		if err != nil {
+			cancel()
+			logging.Warnf("Skipping %s in bundle of %s: %v", a.Name, id, err)
+			continue
+		}
+		if body == nil {
+			cancel()
+			continue
+		}
+		err = writeZipEntry(zw, a.FileName(), modTime, body)
+		body.Close()
+		cancel()
+		if err != nil {
+			return files, err
+		}
+		files++
+	}
+
+	if opts.IncludePDB {
+		n, err := m.bundlePDBFiles(ctx, id, zw, localDir)
+		files += n
+		if err != nil {
+			return files, err
+		}
+	}
+	return files, zw.Close()
+}
+
+// bundleArtifact 成果物の内容（ローカル、なければR2からストリーミング、R2を使わずローカルにもない場合はnil）
+// 呼び出し側は読み終えた後に閉じること（R2の場合はctxを読み終えるまで取り消さないこと）
+func (m *Manager) bundleArtifact(ctx context.Context, id string, a *ArtifactSpec, record *storage.AnalysisRecord, localDir string, checksums map[string]storage.ArtifactChecksum) (io.ReadCloser, time.Time, error) {
+	localPath := filepath.Join(localDir, a.FileName())
+	if f, err := os.Open(localPath); err == nil {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, time.Time{}, err
+		}
+		return f, info.ModTime(), nil
+	}
+	if m.r2 == nil {
+		return nil, time.Time{}, nil
+	}
+	key := m.ArtifactKey(id, a.Name)
+	if record != nil {
+		key = a.ResolveKey(id, record)
+	}
+	stream, err := m.r2.GetObjectStream(ctx, key, storage.ObjectRequest{})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	modTime := time.Now()
+	if record != nil && record.FinishedAt != nil {
+		modTime = *record.FinishedAt
+	}
+	if checksum, ok := checksums[a.Name]; ok {
+		return storage.NewChecksumReader(stream.Body, checksum), modTime, nil
+	}
+	return stream.Body, modTime, nil
+}
+
+// bundlePDBFiles PDBファイルをpdb_files/にまとめ、含めたファイル数を返す
+func (m *Manager) bundlePDBFiles(ctx context.Context, id string, zw *zip.Writer, localDir string) (int, error) {
+	pdbDir := filepath.Join(localDir, "work", pdbFilesDir)
+	if entries, err := os.ReadDir(pdbDir); err == nil {
+		files := 0
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".cif") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			f, err := os.Open(filepath.Join(pdbDir, entry.Name()))
+			if err != nil {
+				logging.Warnf("Skipping %s in bundle of %s: %v", entry.Name(), id, err)
+				continue
+			}
+			err = writeZipEntry(zw, path.Join(pdbFilesDir, entry.Name()), info.ModTime(), f)
+			f.Close()
+			if err != nil {
+				return files, err
+			}
+			files++
+		}
+		return files, nil
+	}
+
+	if m.r2 == nil {
+		return 0, nil
+	}
+	key := fmt.Sprintf("%s/%s", m.artifactPrefix(id), checkpointArchive)
+	getCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+	defer cancel()
+	stream, err := m.r2.GetObjectStream(getCtx, key, storage.ObjectRequest{})
+	if err != nil {
+		logging.Warnf("No PDB files for bundle of %s (checkpoint %s): %v", id, key, err)
+		return 0, nil
+	}
+	defer stream.Body.Close()
+
+	gz, err := gzip.NewReader(stream.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read checkpoint of %s: %w", id, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	files := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to read checkpoint of %s: %w", id, err)
+		}
+		name := path.Clean(header.Name)
+		if header.Typeflag != tar.TypeReg || path.Dir(name) != pdbFilesDir || !strings.HasSuffix(name, ".cif") {
+			continue
+		}
+		if err := writeZipEntry(zw, name, header.ModTime, tr); err != nil {
+			return files, err
+		}
+		files++
+	}
+}
+
+// writeZipEntry zipに1ファイル追加する（PNGは圧縮済みのため圧縮しない）
+func writeZipEntry(zw *zip.Writer, name string, modTime time.Time, r io.Reader) error {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	if strings.HasSuffix(name, ".png") {
+		header.Method = zip.Store
+	}
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 結果キャッシュの候補として確認するDBのレコード数（同じUniProt IDの新しい順）
+const cacheLookupLimit = 20
+
+// cacheSource キャッシュとして再利用する完了済みの解析
+type cacheSource struct {
+	ID string
+	// DBのレコード（R2のキーの取得用、DBがない場合はnil）
+	record *storage.AnalysisRecord
+}
+
+// SetResultCacheTTL 同じUniProt ID・同じパラメータの完了した解析をこの期間内であれば再利用する（0以下は無効）
+// 再利用したジョブはPythonを実行せずに成果物をコピーして完了する
+func (m *Manager) SetResultCacheTTL(ttl time.Duration) {
+	if ttl < 0 {
+		ttl = 0
+	}
+	m.cacheTTL = ttl
+}
+
+// findCachedResult TTL内に完了した同じ条件の解析を探す（なければnil）
+// DBがない場合はメモリ上のジョブ（ローカルに成果物がある）、DBとR2がある場合はR2にアップロード済みの解析が対象
+func (m *Manager) findCachedResult(uniprotID string, params map[string]interface{}) *cacheSource {
+	if m.cacheTTL <= 0 {
+		return nil
+	}
+	key := paramsKey(params)
+	if key == "" {
+		return nil
+	}
+	since := time.Now().Add(-m.cacheTTL)
+	// 保持期間を過ぎた解析は成果物が削除されているため再利用しない
+	if retention := m.ArtifactRetention(); retention > 0 {
+		if expiry := time.Now().Add(-retention); expiry.After(since) {
+			since = expiry
+		}
+	}
+
+	if m.db == nil {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		var found *Job
+		for _, job := range m.jobs {
+			if job.UniProtID != uniprotID || job.Status != StatusDone || job.UpdatedAt.Before(since) {
+				continue
+			}
+			if paramsKey(job.Params) != key {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(m.localDirOf(job), "result.json")); err != nil {
+				continue
+			}
+			if found == nil || job.UpdatedAt.After(found.UpdatedAt) {
+				found = job
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		return &cacheSource{ID: found.ID}
+	}
+
+	if m.r2 == nil {
+		// R2がない場合は成果物が残らないため再利用できない
+		return nil
+	}
+	records, err := m.db.ListAnalyses(map[string]interface{}{
+		"uniprot_id": uniprotID,
+		"status":     string(StatusDone),
+		"limit":      cacheLookupLimit,
+	})
+	if err != nil {
+		logging.Warnf("Failed to look up cached results for %s: %v", uniprotID, err)
+		return nil
+	}
+	for _, record := range records {
+		finishedAt := record.CreatedAt
+		if record.FinishedAt != nil {
+			finishedAt = *record.FinishedAt
+		}
+		if record.ResultKey == nil || finishedAt.Before(since) || paramsKey(record.Params) != key {
+			continue
+		}
+		return &cacheSource{ID: record.ID, record: record}
+	}
+	return nil
+}
+
+// restoreCachedResult キャッシュ元の成果物をjobDirにコピーする（必須の成果物がない場合はエラー）
+func (m *Manager) restoreCachedResult(ctx context.Context, src *cacheSource, jobDir string) error {
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return fmt.Errorf("Failed to create job directory: %v", err)
+	}
+
+	srcDir, _ := m.findLocalDir(src.ID)
+	// R2から取得する成果物はアップロード時のSHA-256で検証する（最初にR2から取得するときに読み込む）
+	var checksums map[string]storage.ArtifactChecksum
+	for _, a := range Artifacts() {
+		name := a.FileName()
+		dst := filepath.Join(jobDir, name)
+		local := filepath.Join(srcDir, name)
+		if _, err := os.Stat(local); srcDir != "" && err == nil {
+			if err := copyFile(local, dst); err != nil {
+				return fmt.Errorf("Failed to copy cached %s: %v", name, err)
+			}
+			continue
+		}
+		if m.r2 == nil {
+			continue
+		}
+
+		if checksums == nil {
+			if checksums = m.artifactChecksums(ctx, src.ID); checksums == nil {
+				checksums = map[string]storage.ArtifactChecksum{}
+			}
+		}
+		key := a.ResolveKey(src.ID, src.record)
+		getCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+		data, err := m.r2.GetObject(getCtx, key)
+		cancel()
+		if checksum, ok := checksums[a.Name]; ok && err == nil {
+			err = checksum.Verify(data)
+		}
+		if err != nil {
+			if a.Required {
+				return fmt.Errorf("Failed to get cached %s from R2: %v", name, err)
+			}
+			logging.Warnf("Failed to get cached %s from R2 (key: %s): %v", name, key, err)
+			continue
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return fmt.Errorf("Failed to write cached %s: %v", name, err)
+		}
+	}
+
+	for _, a := range Artifacts() {
+		if !a.Required {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(jobDir, a.FileName())); err != nil {
+			return fmt.Errorf("Cached result of %s is no longer available", src.ID)
+		}
+	}
+	return nil
+}
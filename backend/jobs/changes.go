@@ -0,0 +1,26 @@
+package jobs
+
+// AddChangeListener 解析の作成・状態遷移・削除など、保存された内容の変更を受け取るリスナーを登録する（検索インデックスの同期用）
+// deletedは解析が削除された場合にtrue。進捗のみの更新は通知しない
+// リスナーはm.muの外でジョブの更新と同期して呼ばれるため、すぐに戻ること
+func (m *Manager) AddChangeListener(listener func(jobID string, deleted bool)) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.changeListeners = append(m.changeListeners, listener)
+}
+
+// NotifyChanged 解析の変更を通知する（メトリクスの一括更新など、Managerの外でDBを更新した場合に呼ぶ）
+func (m *Manager) NotifyChanged(jobID string) {
+	m.notifyChange(jobID, false)
+}
+
+// notifyChange 登録されたリスナーに変更を通知する
+func (m *Manager) notifyChange(jobID string, deleted bool) {
+	m.subMu.Lock()
+	listeners := m.changeListeners
+	m.subMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(jobID, deleted)
+	}
+}
@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// チェックポイント（Pythonの作業ディレクトリ）のファイル名
+const (
+	// 作業ディレクトリ内でPythonが書き込む、完了したステージの記録
+	checkpointMetaFile = "checkpoint.json"
+	// R2に保存するアーカイブ（analysis/<id>/checkpoint.tar.gz）
+	checkpointArchive = "checkpoint.tar.gz"
+)
+
+// SetCheckpointUpload 解析終了時にPythonの作業ディレクトリ（ダウンロード済みのPDBファイル等）をR2に保存するか
+// DBがある場合は作業ディレクトリが一時ディレクトリのため、再実行時に再開するにはR2への保存が必要
+func (m *Manager) SetCheckpointUpload(enabled bool) {
+	m.checkpointUpload = enabled
+}
+
+// resumeSource 再開元の解析ID（params.resume_from）
+func resumeSource(job *Job) string {
+	return AnalysisParamsFromMap(job.params()).ResumeFrom
+}
+
+// prepareResume 再開元の作業ディレクトリを用意してパスを返す（用意できない場合は空文字列で最初から実行する）
+// ローカルに残っている作業ディレクトリを優先し、なければR2のチェックポイントをjobDir/resumeに展開する
+func (m *Manager) prepareResume(ctx context.Context, job *Job, jobDir string) string {
+	src := resumeSource(job)
+	if src == "" || src == job.ID || strings.ContainsAny(src, `/\`) {
+		return ""
+	}
+
+	if srcDir, _ := m.findLocalDir(src); srcDir != "" {
+		local := filepath.Join(srcDir, "work")
+		if _, err := os.Stat(filepath.Join(local, checkpointMetaFile)); err == nil {
+			job.logger().Debugf("Resuming job %s from local work directory of %s", job.ID, src)
+			return local
+		}
+	}
+
+	if m.r2 == nil {
+		job.logger().Warnf("No checkpoint found for %s, job %s will start from scratch", src, job.ID)
+		return ""
+	}
+	key := fmt.Sprintf("%s/%s", m.artifactPrefix(src), checkpointArchive)
+	getCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+	data, err := m.r2.GetObject(getCtx, key)
+	cancel()
+	if err != nil {
+		job.logger().Warnf("Failed to get checkpoint from R2 (key: %s), job %s will start from scratch: %v", key, job.ID, err)
+		return ""
+	}
+
+	resumeDir := filepath.Join(jobDir, "resume")
+	if err := extractTarGz(data, resumeDir); err != nil {
+		job.logger().Warnf("Failed to extract checkpoint of %s, job %s will start from scratch: %v", src, job.ID, err)
+		os.RemoveAll(resumeDir)
+		return ""
+	}
+	job.logger().Debugf("Resuming job %s from R2 checkpoint of %s", job.ID, src)
+	return resumeDir
+}
+
+// saveCheckpoint 作業ディレクトリをR2に保存する（チェックポイントが書き込まれていない場合は何もしない）
+func (m *Manager) saveCheckpoint(job *Job, jobDir string) {
+	if !m.checkpointUpload || m.r2 == nil {
+		return
+	}
+	workDir := filepath.Join(jobDir, "work")
+	if _, err := os.Stat(filepath.Join(workDir, checkpointMetaFile)); err != nil {
+		return
+	}
+
+	// 作業ディレクトリは大きくなるため、メモリにまとめずにtar.gzを作りながらアップロードする（大きい場合はマルチパート）
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeTarGz(pw, workDir))
+	}()
+	key := fmt.Sprintf("%s/%s", storageLayout(job.storageLayout).Prefix(job.ID), checkpointArchive)
+	ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+	defer cancel()
+	n, err := m.r2.PutObjectStream(ctx, key, pr, "application/gzip")
+	// アップロードが途中で失敗した場合に書き込み側を終わらせる
+	pr.CloseWithError(err)
+	if err != nil {
+		job.logger().Warnf("Failed to upload checkpoint for %s: %v", job.ID, err)
+		return
+	}
+	job.logger().Debugf("Uploaded checkpoint for %s (%d bytes)", job.ID, n)
+}
+
+// writeTarGz ディレクトリ以下の通常ファイルをtar.gzにまとめてwに書き込む（パスはdirからの相対パス）
+func writeTarGz(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// extractTarGz tar.gzの通常ファイルをdirに展開する（dirの外を指すパスは拒否する）
+func extractTarGz(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in checkpoint: %s", header.Name)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+)
+
+// ArtifactChecksums 解析の成果物のアップロード時のSHA-256（名前をキーにする、DBがない・記録前の解析は空）
+func (m *Manager) ArtifactChecksums(ctx context.Context, id string) (map[string]storage.ArtifactChecksum, error) {
+	if m.db == nil {
+		return nil, nil
+	}
+	return m.db.GetArtifactChecksums(ctx, id)
+}
+
+// artifactChecksums ArtifactChecksumsの取得に失敗した場合は検証しない（警告のみ）
+func (m *Manager) artifactChecksums(ctx context.Context, id string) map[string]storage.ArtifactChecksum {
+	checksums, err := m.ArtifactChecksums(ctx, id)
+	if err != nil {
+		logging.Warnf("Failed to load artifact checksums of %s, downloads will not be verified: %v", id, err)
+	}
+	return checksums
+}
+
+// putArtifact SHA-256をメタデータに付けて成果物をアップロードする
+func (m *Manager) putArtifact(key string, data []byte, contentType string, checksum storage.ArtifactChecksum) error {
+	ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+	defer cancel()
+	return m.r2.PutObjectWithMetadata(ctx, key, data, contentType, map[string]string{
+		storage.ChecksumMetadataKey: checksum.SHA256,
+	})
+}
+
+// saveArtifactChecksums アップロードした成果物のSHA-256をDBに記録する（失敗しても成果物のアップロードは成功として扱う）
+func (m *Manager) saveArtifactChecksums(jobID string, checksums []storage.ArtifactChecksum) {
+	if m.db == nil || len(checksums) == 0 {
+		return
+	}
+	if err := m.db.SaveArtifactChecksums(jobID, checksums); err != nil {
+		logging.Job(jobID).Warnf("Failed to save artifact checksums of %s: %v", jobID, err)
+	}
+}
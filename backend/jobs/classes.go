@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ジョブの種類（実行枠の予約の単位、params.classに保存する）
+const (
+	// 画面・APIからの通常の投入（デフォルト）
+	ClassInteractive = "interactive"
+	// メッセージキュー・スクリプトからのまとまった投入
+	ClassBatch = "batch"
+	// 管理者による再計算（POST /api/admin/analyses/:id/recompute）
+	ClassAdmin = "admin"
+)
+
+// JobClasses ジョブの種類（メトリクスのラベルの順）
+var JobClasses = []string{ClassInteractive, ClassBatch, ClassAdmin}
+
+// ValidJobClass ジョブの種類として使えるか
+func ValidJobClass(class string) bool {
+	return containsParamValue(JobClasses, class)
+}
+
+// jobClass スケジューリングに使うジョブの種類（未設定のジョブはinteractive）
+func jobClass(job *Job) string {
+	if class, ok := job.params()["class"].(string); ok && class != "" {
+		return class
+	}
+	return ClassInteractive
+}
+
+// ParseClassReservations JOB_CLASS_RESERVATIONS（"interactive=0.25,admin=0.1"）を読み込む
+// 値は実行枠に対する割合（0以上1未満）で、合計は1未満にする
+// 切り上げた予約の枠の合計がslots（実行枠の数）以上になり、予約のない種類のジョブが実行できない場合はエラー
+func ParseClassReservations(v string, slots int) (map[string]float64, error) {
+	reservations := make(map[string]float64)
+	total := 0.0
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		class, value, ok := strings.Cut(entry, "=")
+		class = strings.TrimSpace(class)
+		if !ok || !ValidJobClass(class) {
+			return nil, fmt.Errorf("invalid reservation %q (expected <class>=<fraction>, class is one of %s)", entry, strings.Join(JobClasses, ", "))
+		}
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || fraction < 0 || fraction >= 1 {
+			return nil, fmt.Errorf("invalid reservation for %s: %q (must be >= 0 and < 1)", class, value)
+		}
+		total += fraction - reservations[class]
+		reservations[class] = fraction
+	}
+	if total >= 1 {
+		return nil, fmt.Errorf("total reservation must be < 1 (got %g)", total)
+	}
+	if err := checkReservedSlots(reservations, slots); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// reservedSlotsFor 割合fractionで予約する実行枠の数（slotsに対する切り上げ）
+func reservedSlotsFor(fraction float64, slots int) int {
+	if fraction <= 0 {
+		return 0
+	}
+	return int(math.Ceil(fraction * float64(slots)))
+}
+
+// checkReservedSlots 予約の枠の合計がslotsより少なく、予約のない種類のジョブに1枠以上残るか
+// （切り上げのため割合の合計が1未満でも、実行枠が少ないとすべての枠が予約される）
+func checkReservedSlots(reservations map[string]float64, slots int) error {
+	total := 0
+	for _, fraction := range reservations {
+		total += reservedSlotsFor(fraction, slots)
+	}
+	if total > 0 && total >= slots {
+		return fmt.Errorf("reservations take %d of %d slots, leaving none for other job classes (increase MAX_CONCURRENT or lower the fractions)", total, slots)
+	}
+	return nil
+}
+
+// SetClassReservations ジョブの種類ごとに実行枠の一部を予約する（他の種類のジョブは予約された枠を使わない）
+// 予約する枠の数は実行枠の数×割合の切り上げで、実行枠を変更した場合も割合で計算し直す
+// 予約の枠の合計が実行枠の数以上になる場合はエラーで、設定は変更しない
+func (m *Manager) SetClassReservations(reservations map[string]float64) error {
+	m.scheduler.mu.Lock()
+	defer m.scheduler.mu.Unlock()
+	if err := checkReservedSlots(reservations, m.scheduler.slots); err != nil {
+		return err
+	}
+	m.scheduler.reservations = make(map[string]float64, len(reservations))
+	for class, fraction := range reservations {
+		if fraction > 0 {
+			m.scheduler.reservations[class] = fraction
+		}
+	}
+	m.scheduler.dispatch()
+	return nil
+}
+
+// ClassReservations 予約の設定（ログ用、"admin=0.1 (1 slots)" の形式、JobClassesの順）
+func (m *Manager) ClassReservations() []string {
+	s := m.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entries []string
+	for _, class := range JobClasses {
+		if fraction, ok := s.reservations[class]; ok {
+			entries = append(entries, fmt.Sprintf("%s=%g (%d slots)", class, fraction, s.reservedSlots(class)))
+		}
+	}
+	return entries
+}
+
+// reservedSlots 種類ごとに予約されている実行枠の数（s.muを保持して呼ぶ）
+func (s *fairScheduler) reservedSlots(class string) int {
+	return reservedSlotsFor(s.reservations[class], s.slots)
+}
+
+// canStartLocked classのジョブに実行枠を割り当てても、他の種類の予約のうちまだ使われていない枠が残るか（s.muを保持して呼ぶ）
+func (s *fairScheduler) canStartLocked(class string) bool {
+	held := 0
+	for other := range s.reservations {
+		if other == class {
+			continue
+		}
+		if unused := s.reservedSlots(other) - s.byClass[other]; unused > 0 {
+			held += unused
+		}
+	}
+	return s.running+1+held <= s.slots
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import "testing"
+
+// READMEの例（interactive=0.25,admin=0.1）は切り上げでそれぞれ1枠になり、デフォルトの2枠ではbatchの枠が残らない
+func TestParseClassReservationsLeavesUnreservedSlot(t *testing.T) {
+	const readmeExample = "interactive=0.25,admin=0.1"
+	for _, tc := range []struct {
+		slots   int
+		wantErr bool
+	}{
+		{slots: 1, wantErr: true},
+		{slots: 2, wantErr: true},
+		{slots: 3, wantErr: false},
+		{slots: 10, wantErr: false},
+	} {
+		_, err := ParseClassReservations(readmeExample, tc.slots)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseClassReservations(%q, %d) error = %v, wantErr %v", readmeExample, tc.slots, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSetMaxConcurrentKeepsUnreservedSlot(t *testing.T) {
+	m := NewManager(t.TempDir(), "python3", 3)
+	if err := m.SetClassReservations(map[string]float64{ClassInteractive: 0.25, ClassAdmin: 0.1}); err != nil {
+		t.Fatalf("SetClassReservations with 3 slots: %v", err)
+	}
+	if err := m.SetMaxConcurrent(2); err == nil {
+		t.Fatalf("SetMaxConcurrent(2) succeeded, want an error because reservations take both slots")
+	}
+
+	// 変更を拒否した場合は元の実行枠のままで、batchのジョブは予約のない1枠で実行できる
+	s := m.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.slots != 3 {
+		t.Errorf("slots = %d after rejected SetMaxConcurrent, want 3", s.slots)
+	}
+	if !s.canStartLocked(ClassBatch) {
+		t.Errorf("batch job cannot start with 3 slots and no running jobs")
+	}
+	s.running, s.byClass[ClassBatch] = 1, 1
+	if s.canStartLocked(ClassBatch) {
+		t.Errorf("second batch job can start, want it to wait for the unreserved slot")
+	}
+	if !s.canStartLocked(ClassInteractive) || !s.canStartLocked(ClassAdmin) {
+		t.Errorf("reserved classes cannot start while their slots are unused")
+	}
+}
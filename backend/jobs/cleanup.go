@@ -0,0 +1,286 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 削除待ちエントリのメタデータファイル名・ローカルの成果物の退避先
+const (
+	deletionMetaFile  = "deletion.json"
+	deletionFilesDir  = "files"
+	deletionQueueSize = 1000
+)
+
+// 削除の再試行の間隔（失敗するたびに倍にし、deletionRetryMaxまで延ばす）と上限回数
+// 上限に達したR2のオブジェクトは照合（POST /api/admin/reconcile）で孤立したものとして見つかる
+const (
+	deletionRetryBase   = 30 * time.Second
+	deletionRetryMax    = time.Hour
+	deletionMaxAttempts = 10
+)
+
+// deletionMeta 削除待ちの解析（ストレージの後片付けが終わるまで残す）
+type deletionMeta struct {
+	JobID       string    `json:"job_id"`
+	RequestedAt time.Time `json:"requested_at"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// 削除するR2のプレフィックス（解析の保存形式で決まる、空の場合は従来の形式）
+	R2Prefix string `json:"r2_prefix,omitempty"`
+}
+
+// deletionQueue 削除した解析のR2のオブジェクト・ローカルの成果物をバックグラウンドで削除する
+// DeleteJobはメモリ・DBから取り除いて削除待ちとして記録するだけで、遅いR2の呼び出しを待たずに戻る
+type deletionQueue struct {
+	queue   chan string
+	started bool
+	mu      sync.Mutex
+	// 同じエントリを同時に処理しないように1つのワーカーで処理する
+	workMu sync.Mutex
+}
+
+func newDeletionQueue() *deletionQueue {
+	return &deletionQueue{queue: make(chan string, deletionQueueSize)}
+}
+
+func (m *Manager) deletionDir() string {
+	return filepath.Join(m.storageDir, "pending_deletions")
+}
+
+func (m *Manager) deletionEntryDir(jobID string) string {
+	return filepath.Join(m.deletionDir(), jobID)
+}
+
+// scheduleStorageCleanup 解析のストレージの後片付けを削除待ちとして記録し、ワーカーに渡す
+// ローカルのジョブディレクトリは削除待ちエントリに移動するため、この時点で参照できなくなる
+// layoutは削除前に確認した解析の保存形式（DBのレコードは既に削除されている）
+func (m *Manager) scheduleStorageCleanup(jobID string, layout *StorageLayout) {
+	localDir, _ := m.findLocalDir(jobID)
+	hasLocal := m.db == nil && localDir != ""
+	if !hasLocal && m.r2 == nil {
+		return
+	}
+	r2Prefix := layout.Prefix(jobID)
+
+	entryDir := m.deletionEntryDir(jobID)
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		// 記録できない場合はその場で削除する（従来の動作）
+		logging.Job(jobID).Warnf("Failed to create deletion entry for %s, cleaning up inline: %v", jobID, err)
+		m.cleanupStorage(r2Prefix, localDir)
+		return
+	}
+	if hasLocal {
+		filesDir := filepath.Join(entryDir, deletionFilesDir)
+		os.RemoveAll(filesDir)
+		if err := os.Rename(localDir, filesDir); err != nil {
+			logging.Warnf("Failed to move %s to deletion entry, removing inline: %v", localDir, err)
+			if err := os.RemoveAll(localDir); err != nil {
+				logging.Warnf("Failed to delete job directory: %v", err)
+			}
+		}
+	}
+	meta := deletionMeta{JobID: jobID, R2Prefix: r2Prefix, RequestedAt: time.Now().UTC()}
+	if err := writeDeletionMeta(entryDir, &meta); err != nil {
+		logging.Job(jobID).Warnf("Failed to write deletion entry for %s: %v", jobID, err)
+	}
+	m.enqueueDeletion(jobID)
+}
+
+// enqueueDeletion ワーカーが起動していればキューに投入する（溢れた場合は定期的な走査で拾う）
+func (m *Manager) enqueueDeletion(jobID string) {
+	d := m.deletions
+	d.mu.Lock()
+	started := d.started
+	d.mu.Unlock()
+	if !started {
+		return
+	}
+	select {
+	case d.queue <- jobID:
+	default:
+		logging.Job(jobID).Warnf("Deletion queue is full, %s will be retried later", jobID)
+	}
+}
+
+// StartDeletionWorker 削除待ちの後片付けを行うワーカーを起動し、前回の停止で残ったものを再開する
+// 以降はintervalごとに走査して再試行の時刻を過ぎたものを処理する
+func (m *Manager) StartDeletionWorker(interval time.Duration) {
+	d := m.deletions
+	d.mu.Lock()
+	if d.started {
+		d.mu.Unlock()
+		return
+	}
+	d.started = true
+	d.mu.Unlock()
+
+	go func() {
+		for jobID := range d.queue {
+			m.processDeletion(jobID)
+		}
+	}()
+	go func() {
+		m.ResumePendingDeletions()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.ResumePendingDeletions()
+		}
+	}()
+}
+
+// ResumePendingDeletions 再試行の時刻を過ぎた削除待ちを古い順に処理する
+func (m *Manager) ResumePendingDeletions() {
+	dirEntries, err := os.ReadDir(m.deletionDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warnf("Failed to read deletion directory: %v", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	pendings := make([]*deletionMeta, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		if !entry.IsDir() {
+			continue
+		}
+		meta, err := readDeletionMeta(filepath.Join(m.deletionDir(), entry.Name()))
+		if err != nil {
+			// メタデータの書き込み前に停止した場合
+			meta = &deletionMeta{JobID: entry.Name()}
+		}
+		if meta.NextAttempt.After(now) {
+			continue
+		}
+		pendings = append(pendings, meta)
+	}
+	sort.Slice(pendings, func(i, j int) bool {
+		return pendings[i].RequestedAt.Before(pendings[j].RequestedAt)
+	})
+	for _, meta := range pendings {
+		m.processDeletion(meta.JobID)
+	}
+}
+
+// processDeletion 削除待ちの1件を処理する（失敗した場合は再試行の時刻を記録する）
+func (m *Manager) processDeletion(jobID string) {
+	d := m.deletions
+	d.workMu.Lock()
+	defer d.workMu.Unlock()
+
+	entryDir := m.deletionEntryDir(jobID)
+	if _, err := os.Stat(entryDir); os.IsNotExist(err) {
+		// 処理済み
+		return
+	}
+	meta, err := readDeletionMeta(entryDir)
+	if err != nil {
+		meta = &deletionMeta{JobID: jobID, RequestedAt: time.Now().UTC()}
+	}
+	if meta.NextAttempt.After(time.Now()) {
+		return
+	}
+
+	r2Prefix := meta.R2Prefix
+	if r2Prefix == "" {
+		r2Prefix = ArtifactPrefix(jobID)
+	}
+	if err := m.cleanupStorage(r2Prefix, filepath.Join(entryDir, deletionFilesDir)); err != nil {
+		meta.Attempts++
+		meta.LastError = err.Error()
+		if meta.Attempts >= deletionMaxAttempts {
+			logging.Job(jobID).Errorf("Giving up storage cleanup for %s after %d attempts: %v", jobID, meta.Attempts, err)
+			os.RemoveAll(entryDir)
+			return
+		}
+		meta.NextAttempt = time.Now().UTC().Add(deletionRetryDelay(meta.Attempts))
+		logging.Job(jobID).Warnf("Storage cleanup for %s failed (attempt %d, retry at %s): %v", jobID, meta.Attempts, meta.NextAttempt.Format(time.RFC3339), err)
+		if err := writeDeletionMeta(entryDir, meta); err != nil {
+			logging.Job(jobID).Warnf("Failed to update deletion entry for %s: %v", jobID, err)
+		}
+		return
+	}
+	if err := os.RemoveAll(entryDir); err != nil {
+		logging.Job(jobID).Warnf("Failed to remove deletion entry for %s: %v", jobID, err)
+	}
+	logging.Job(jobID).Debugf("Storage cleanup completed for %s", jobID)
+}
+
+// cleanupStorage R2のオブジェクト（r2Prefix/ 以下）とローカルの成果物を削除する
+func (m *Manager) cleanupStorage(r2Prefix, localDir string) error {
+	if localDir != "" {
+		if err := os.RemoveAll(localDir); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", localDir, err)
+		}
+	}
+	if m.r2 == nil {
+		return nil
+	}
+	r2Prefix += "/"
+	ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+	defer cancel()
+	if err := m.r2.DeleteObjectsWithPrefix(ctx, r2Prefix); err != nil {
+		return fmt.Errorf("failed to delete R2 objects with prefix %s: %w", r2Prefix, err)
+	}
+	logging.Debugf("Successfully deleted objects from R2: %s", r2Prefix)
+	return nil
+}
+
+// deletionRetryDelay attempts回失敗した後の再試行までの待ち時間
+func deletionRetryDelay(attempts int) time.Duration {
+	delay := deletionRetryBase
+	for i := 1; i < attempts && delay < deletionRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, deletionRetryMax)
+}
+
+// PendingDeletions ストレージの後片付けが終わっていない削除済みの解析の数
+func (m *Manager) PendingDeletions() int {
+	dirEntries, err := os.ReadDir(m.deletionDir())
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, entry := range dirEntries {
+		if entry.IsDir() {
+			count++
+		}
+	}
+	return count
+}
+
+func readDeletionMeta(entryDir string) (*deletionMeta, error) {
+	data, err := os.ReadFile(filepath.Join(entryDir, deletionMetaFile))
+	if err != nil {
+		return nil, err
+	}
+	var meta deletionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// writeDeletionMeta 途中で停止しても壊れないように一時ファイルに書いてから置き換える
+func writeDeletionMeta(entryDir string, meta *deletionMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(entryDir, deletionMetaFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(entryDir, deletionMetaFile))
+}
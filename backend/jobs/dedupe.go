@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"encoding/json"
+)
+
+// dedupeIgnoredParams 解析結果に影響しないため重複判定・結果キャッシュで無視するパラメータ
+var dedupeIgnoredParams = map[string]bool{
+	"session_id":      true,
+	"timeout_seconds": true,
+	"cached_from":     true,
+	"resume_from":     true,
+	"nice":            true,
+	"max_memory_mb":   true,
+	"threads":         true,
+	"class":           true,
+	"sweep_id":        true,
+}
+
+// paramsKey 重複判定用にパラメータを正規化した文字列
+// AnalysisParamsを経由するため、古い形式（xray_only等）・省略されたデフォルト値も同じ表現になる
+// JSONのキーはソートされ、数値は5と5.0が同じ表現になる
+func paramsKey(params map[string]interface{}) string {
+	normalized := AnalysisParamsFromMap(params).Map()
+	filtered := make(map[string]interface{}, len(normalized))
+	for key, value := range normalized {
+		if !dedupeIgnoredParams[key] {
+			filtered[key] = value
+		}
+	}
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// findDuplicateLocked 同じUniProt ID・同じパラメータでキュー待ち・実行中のジョブを探す（m.muを保持して呼ぶ）
+func (m *Manager) findDuplicateLocked(uniprotID string, params map[string]interface{}) *Job {
+	key := paramsKey(params)
+	if key == "" {
+		return nil
+	}
+	var found *Job
+	for _, job := range m.jobs {
+		if job.UniProtID != uniprotID || (job.Status != StatusQueued && job.Status != StatusRunning) {
+			continue
+		}
+		if paramsKey(job.Params) != key {
+			continue
+		}
+		// 複数ある場合は最も古いジョブ（最も進んでいる可能性が高い）
+		if found == nil || job.CreatedAt.Before(found.CreatedAt) {
+			found = job
+		}
+	}
+	return found
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/storage"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DeletionImpact 解析を削除した場合に削除されるもの（DeleteJobと同じ判定で、何も削除しない）
+type DeletionImpact struct {
+	AnalysisID string `json:"analysis_id"`
+	// メモリ上のジョブ（実行中・キュー待ちの場合はキャンセルされる）
+	JobStatus         JobStatus `json:"job_status,omitempty"`
+	CancelsRunningJob bool      `json:"cancels_running_job"`
+	// DBの解析レコード
+	DBRow bool `json:"db_row"`
+	// R2のオブジェクト（analysis/<id>/ 以下）
+	R2Prefix      string               `json:"r2_prefix,omitempty"`
+	R2Objects     []storage.ObjectInfo `json:"r2_objects"`
+	R2ObjectCount int                  `json:"r2_object_count"`
+	R2Bytes       int64                `json:"r2_bytes"`
+	// ローカルのディレクトリ（ジョブディレクトリ、アップロード待ちのスプール）
+	LocalDirs  []LocalDirImpact `json:"local_dirs"`
+	LocalBytes int64            `json:"local_bytes"`
+	TotalBytes int64            `json:"total_bytes"`
+	// 見つからなかった場合
+	NotFound bool `json:"not_found"`
+	// 削除できないもの・確認できなかったもの
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// LocalDirImpact 削除されるローカルディレクトリ
+type LocalDirImpact struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// DeletionImpact DeleteJobを実行した場合の影響を調べる（ドライラン）
+func (m *Manager) DeletionImpact(ctx context.Context, jobID string) *DeletionImpact {
+	impact := &DeletionImpact{
+		AnalysisID: jobID,
+		R2Objects:  []storage.ObjectInfo{},
+		LocalDirs:  []LocalDirImpact{},
+	}
+
+	m.mu.RLock()
+	job, inMemory := m.jobs[jobID]
+	m.mu.RUnlock()
+	if inMemory {
+		impact.JobStatus = job.Status
+		impact.CancelsRunningJob = job.Status == StatusRunning || job.Status == StatusQueued
+	}
+
+	var record *storage.AnalysisRecord
+	if m.db != nil {
+		r, err := m.db.GetAnalysisContext(ctx, jobID)
+		if err == nil && r != nil {
+			record = r
+			impact.DBRow = true
+		}
+	}
+
+	// ストレージディレクトリ（DBがない場合のみ削除される）
+	if m.db == nil {
+		m.addLocalDirImpact(impact, m.localJobDir(jobID))
+	}
+
+	if m.r2 != nil {
+		m.addLocalDirImpact(impact, m.spoolEntryDir(jobID))
+
+		impact.R2Prefix = m.artifactPrefix(jobID) + "/"
+		listCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+		objects, err := m.r2.ListObjectsWithPrefix(listCtx, impact.R2Prefix)
+		cancel()
+		if err != nil {
+			impact.Warnings = append(impact.Warnings, fmt.Sprintf("Failed to list R2 objects: %v", err))
+		}
+		for _, obj := range objects {
+			impact.R2Objects = append(impact.R2Objects, obj)
+			impact.R2Bytes += obj.Size
+		}
+		impact.R2ObjectCount = len(impact.R2Objects)
+	} else if record != nil && (record.ResultKey != nil || record.HeatmapKey != nil || record.ScatterKey != nil) {
+		impact.Warnings = append(impact.Warnings, "R2 keys found in DB but R2 is not configured. R2 objects will not be deleted.")
+	}
+
+	impact.TotalBytes = impact.R2Bytes + impact.LocalBytes
+	impact.NotFound = !inMemory && !impact.DBRow && impact.R2ObjectCount == 0 && len(impact.LocalDirs) == 0
+	return impact
+}
+
+// addLocalDirImpact ディレクトリが存在すればファイル数とサイズを集計して追加する
+func (m *Manager) addLocalDirImpact(impact *DeletionImpact, dir string) {
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+	entry := LocalDirImpact{Path: dir}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entry.Files++
+		entry.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		impact.Warnings = append(impact.Warnings, fmt.Sprintf("Failed to scan %s: %v", dir, err))
+	}
+	impact.LocalDirs = append(impact.LocalDirs, entry)
+	impact.LocalBytes += entry.Bytes
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 診断で実行するPythonのコマンドのタイムアウト（dsa_cliのimportは依存ライブラリの読み込みを含む）
+const diagnosticCommandTimeout = 30 * time.Second
+
+// DiagnosticCheck 環境の診断項目の結果
+type DiagnosticCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Diagnostics 環境の診断結果（最初のジョブが失敗する前に設定の誤りを見つける用）
+type Diagnostics struct {
+	OK       bool              `json:"ok"`
+	Executor string            `json:"executor"`
+	Checks   []DiagnosticCheck `json:"checks"`
+}
+
+// Diagnoser 実行環境を診断できるExecutor
+type Diagnoser interface {
+	Diagnose(ctx context.Context) []DiagnosticCheck
+}
+
+func diagnosticResult(name, detail string, err error) DiagnosticCheck {
+	check := DiagnosticCheck{Name: name, OK: err == nil, Detail: detail}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// Diagnose 実行環境（Executorが対応していれば）・ストレージディレクトリ・一時ディレクトリの書き込み・DB・オブジェクトストレージへの疎通を確認する
+func (m *Manager) Diagnose(ctx context.Context) *Diagnostics {
+	result := &Diagnostics{Executor: m.executor.Name()}
+	if d, ok := m.executor.(Diagnoser); ok {
+		result.Checks = append(result.Checks, d.Diagnose(ctx)...)
+	}
+
+	result.Checks = append(result.Checks,
+		diagnosticResult("storage_dir", m.storageDir, checkWritable(m.storageDir)),
+		diagnosticResult("temp_dir", m.TempDir(), checkWritable(m.TempDir())),
+	)
+
+	if m.db != nil {
+		result.Checks = append(result.Checks, diagnosticResult("database", "", m.db.Ping(ctx)))
+	} else {
+		result.Checks = append(result.Checks, DiagnosticCheck{Name: "database", OK: true, Detail: "not configured"})
+	}
+	if m.r2 != nil {
+		result.Checks = append(result.Checks, diagnosticResult("object_storage", "", m.r2.Probe(ctx)))
+	} else {
+		result.Checks = append(result.Checks, DiagnosticCheck{Name: "object_storage", OK: true, Detail: "not configured"})
+	}
+
+	result.OK = true
+	for _, check := range result.Checks {
+		if !check.OK {
+			result.OK = false
+		}
+	}
+	return result
+}
+
+// checkWritable ディレクトリにファイルを作成・削除できることを確認する
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".diagnostics-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// Diagnose executeJobと同じ方法でPythonディレクトリを探し、インタープリターのバージョンとdsa_cliをimportできることを確認する
+func (e *LocalPythonExecutor) Diagnose(ctx context.Context) []DiagnosticCheck {
+	version, err := e.runPython(ctx, "", "--version")
+	checks := []DiagnosticCheck{diagnosticResult("python_interpreter", e.PythonPath, err)}
+	if err == nil {
+		checks[0].Detail = fmt.Sprintf("%s (%s)", e.PythonPath, version)
+	}
+
+	pythonDir, err := e.findPythonDir()
+	checks = append(checks, diagnosticResult("python_dir", pythonDir, err))
+	if err != nil {
+		return append(checks, DiagnosticCheck{Name: "dsa_cli_import", OK: false, Error: "python directory not found"})
+	}
+	_, err = e.runPython(ctx, pythonDir, "-c", "import dsa_cli")
+	return append(checks, diagnosticResult("dsa_cli_import", pythonDir, err))
+}
+
+// runPython Pythonを実行して出力を返す（dirを指定した場合はそこをカレントディレクトリ・PYTHONPATHにする、ジョブと同じ）
+func (e *LocalPythonExecutor) runPython(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, diagnosticCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.PythonPath, args...)
+	cmd.Env = os.Environ()
+	if dir != "" {
+		cmd.Dir = dir
+		cmd.Env = append(cmd.Env, "PYTHONPATH="+dir)
+	}
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output != "" {
+			// importエラーはトレースバックの最後の行が原因
+			lines := strings.Split(output, "\n")
+			return "", fmt.Errorf("%v: %s", err, lines[len(lines)-1])
+		}
+		return "", err
+	}
+	return output, nil
+}
@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ドライランで作業ディレクトリの代わりに表示するジョブID
+const dryRunJobID = "<job_id>"
+
+// DryRunPlan ジョブを作成した場合に実行される内容（何も実行・保存しない）
+type DryRunPlan struct {
+	UniProtID string                 `json:"uniprot_id"`
+	Params    map[string]interface{} `json:"params"`
+	Executor  string                 `json:"executor"`
+	// Python CLIの引数（先頭はPythonの実行ファイル、リモートワーカーで実行する場合はワーカー側で決まるため含まない）
+	Argv []string `json:"argv,omitempty"`
+	// 結果キャッシュから復元される場合の復元元の解析ID（Pythonは実行されない）
+	CachedFrom string `json:"cached_from,omitempty"`
+	// dedupe=trueで合流するキュー待ち・実行中のジョブ
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// DryRun CreateJobWithOptionsと同じ検証を行い、作成した場合に実行される内容を返す（ジョブは作成しない）
+// 作業ディレクトリ・再開元のディレクトリはジョブIDの代わりに "<job_id>" で表す
+func (m *Manager) DryRun(uniprotID string, analysisParams AnalysisParams, opts CreateJobOptions) (*DryRunPlan, error) {
+	if err := validateEnvOverrides(m.envAllowlist, analysisParams.Env); err != nil {
+		return nil, err
+	}
+	if err := analysisParams.verifyRoundTrip(); err != nil {
+		return nil, err
+	}
+	params := analysisParams.Map()
+
+	plan := &DryRunPlan{
+		UniProtID: uniprotID,
+		Params:    params,
+		Executor:  m.executor.Name(),
+	}
+	if local, ok := m.executor.(*LocalPythonExecutor); ok {
+		jobDir := filepath.Join(m.storageDir, dryRunJobID)
+		if m.db != nil {
+			// DBがある場合はexecuteJobで一時ディレクトリを作成する
+			jobDir = filepath.Join(m.TempDir(), fmt.Sprintf("%s%s-*", tempDirPrefix, dryRunJobID))
+		}
+		resumeDir := ""
+		if analysisParams.ResumeFrom != "" {
+			resumeDir = filepath.Join(jobDir, "resume")
+		}
+		plan.Argv = local.commandArgs(uniprotID, analysisParams, jobDir, resumeDir)
+	}
+
+	if !opts.NoCache {
+		if src := m.findCachedResult(uniprotID, params); src != nil {
+			plan.CachedFrom = src.ID
+		}
+	}
+	if opts.Dedupe {
+		m.mu.RLock()
+		if existing := m.findDuplicateLocked(uniprotID, params); existing != nil {
+			plan.DuplicateOf = existing.ID
+		}
+		m.mu.RUnlock()
+	}
+	return plan, nil
+}
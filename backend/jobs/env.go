@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ジョブごとの環境変数の上書きを指定するparamsのキー（{"HTTPS_PROXY": "http://proxy:3128", "DSA_DEBUG": "1"}）
+const envParam = "env"
+
+// EnvOverrideError paramsのenvに許可されていない環境変数・不正な値が含まれている
+type EnvOverrideError struct {
+	Name   string
+	Reason string
+}
+
+func (e *EnvOverrideError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("params.env %s", e.Reason)
+	}
+	return fmt.Sprintf("params.env.%s %s", e.Name, e.Reason)
+}
+
+// SetEnvAllowlist ジョブのparams.envで上書きできる環境変数を設定する（空の場合は上書きを受け付けない）
+// リモートワーカーではワーカー側のJOB_ENV_ALLOWLISTで改めて絞り込む
+func (m *Manager) SetEnvAllowlist(names []string) {
+	allowlist := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			allowlist[name] = true
+		}
+	}
+	m.envAllowlist = allowlist
+	if e, ok := m.executor.(*LocalPythonExecutor); ok {
+		e.EnvAllowlist = allowlist
+	}
+}
+
+// EnvAllowlist params.envで上書きできる環境変数の一覧（ソート済み）
+func (m *Manager) EnvAllowlist() []string {
+	names := make([]string, 0, len(m.envAllowlist))
+	for name := range m.envAllowlist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envOverrides paramsのenvを取得する（JSON由来のmap[string]interface{}とGoから直接渡されたmap[string]stringの両方に対応する、AnalysisParamsの検証で使う）
+func envOverrides(params map[string]interface{}) (map[string]string, error) {
+	switch v := params[envParam].(type) {
+	case nil:
+		return nil, nil
+	case map[string]string:
+		return v, nil
+	case map[string]interface{}:
+		env := make(map[string]string, len(v))
+		for name, value := range v {
+			s, ok := value.(string)
+			if !ok {
+				return nil, &EnvOverrideError{Name: name, Reason: "must be a string"}
+			}
+			env[name] = s
+		}
+		return env, nil
+	}
+	return nil, &EnvOverrideError{Reason: "must be an object"}
+}
+
+// validateEnvOverrides paramsのenvがすべて許可されている環境変数か確認する（ジョブ作成時）
+func validateEnvOverrides(allowlist map[string]bool, env map[string]string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !allowlist[name] {
+			return &EnvOverrideError{Name: name, Reason: "is not an allowed environment variable"}
+		}
+		if strings.ContainsRune(env[name], 0) {
+			return &EnvOverrideError{Name: name, Reason: "must not contain NUL characters"}
+		}
+	}
+	return nil
+}
+
+// applyEnvOverrides 許可されている環境変数のみcmd.Envに追加する（起動前、PYTHONPATH・スレッド数より先に呼ぶ）
+// exec.Cmdは同じ名前が複数ある場合に後の値を使うため、後から追加するサーバー側の設定は上書きされない
+func applyEnvOverrides(cmd *exec.Cmd, allowlist map[string]bool, jobID string, env map[string]string) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := env[name]
+		if !allowlist[name] || strings.ContainsRune(value, 0) {
+			logging.Job(jobID).Warnf("Ignoring env override %s for job %s (not allowed)", name, jobID)
+			continue
+		}
+		cmd.Env = append(cmd.Env, name+"="+value)
+		// 値はプロキシの認証情報等を含む可能性があるためログに出さない
+		logging.Job(jobID).Debugf("Env override for job %s: %s", jobID, name)
+	}
+}
@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 構造数の区分（上限の昇順、最後の上限を超える場合はdurationBucketOverflow）
+var durationBuckets = []struct {
+	max  int
+	name string
+}{
+	{10, "1-10"},
+	{50, "11-50"},
+	{200, "51-200"},
+	{1000, "201-1000"},
+}
+
+const durationBucketOverflow = "1001+"
+
+// durationBucket 構造数の区分を返す
+func durationBucket(structures int) string {
+	for _, b := range durationBuckets {
+		if structures <= b.max {
+			return b.name
+		}
+	}
+	return durationBucketOverflow
+}
+
+// 構造数を含む進捗メッセージ（"Found 20 PDB entries"、古いワーカーは "Downloading PDB 3/20 (1ABC)" のみ）
+var structureCountPattern = regexp.MustCompile(`^(?:Found (\d+) PDB entries|Downloading PDB \d+/(\d+))`)
+
+// parseStructureCount 進捗メッセージから解析対象の構造数を読み取る
+func parseStructureCount(message string) (int, bool) {
+	match := structureCountPattern.FindStringSubmatch(message)
+	if match == nil {
+		return 0, false
+	}
+	value := match[1]
+	if value == "" {
+		value = match[2]
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// durationTracker 構造数の区分ごとの所要時間の平均（DBがある場合はDBの値を保持する）
+type durationTracker struct {
+	mu       sync.Mutex
+	averages map[string]storage.DurationAverage
+}
+
+func newDurationTracker() *durationTracker {
+	return &durationTracker{averages: make(map[string]storage.DurationAverage)}
+}
+
+// add 区分の平均に1回分を加える（DBがない場合、またはDBの更新に失敗した場合）
+func (t *durationTracker) add(bucket string, seconds float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	avg := t.averages[bucket]
+	avg.Runs++
+	avg.AvgSeconds += (seconds - avg.AvgSeconds) / float64(avg.Runs)
+	t.averages[bucket] = avg
+}
+
+func (t *durationTracker) set(bucket string, avg storage.DurationAverage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.averages[bucket] = avg
+}
+
+// average 構造数が同じ区分の平均所要時間（秒）
+// 構造数が分からない場合・その区分の実績がない場合は全区分の平均
+func (t *durationTracker) average(structures int) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if structures > 0 {
+		if avg, ok := t.averages[durationBucket(structures)]; ok && avg.Runs > 0 {
+			return avg.AvgSeconds, true
+		}
+	}
+	runs := 0
+	total := 0.0
+	for _, avg := range t.averages {
+		runs += avg.Runs
+		total += avg.AvgSeconds * float64(avg.Runs)
+	}
+	if runs == 0 {
+		return 0, false
+	}
+	return total / float64(runs), true
+}
+
+// LoadDurationStats DBに記録された所要時間の平均を読み込む（DBがない場合は何もしない）
+func (m *Manager) LoadDurationStats() {
+	if m.db == nil {
+		return
+	}
+	averages, err := m.db.ListAnalysisDurations()
+	if err != nil {
+		logging.Warnf("Failed to load analysis durations (apply migrations/006_create_analysis_durations.sql): %v", err)
+		return
+	}
+	for bucket, avg := range averages {
+		m.durations.set(bucket, avg)
+	}
+	logging.Infof("Loaded analysis durations for %d size buckets", len(averages))
+}
+
+// recordDuration 解析が完了したジョブの所要時間（実行開始から解析完了まで）を構造数の区分の平均に加える
+// キャッシュから復元したジョブ・構造数が分からないジョブは記録しない
+func (m *Manager) recordDuration(job *Job) {
+	m.mu.RLock()
+	startedAt, structures := job.startedAt, job.structures
+	m.mu.RUnlock()
+	if job.Cached || startedAt.IsZero() || structures <= 0 {
+		return
+	}
+	bucket := durationBucket(structures)
+	seconds := time.Since(startedAt).Seconds()
+
+	if m.db != nil {
+		avg, err := m.db.RecordAnalysisDuration(bucket, seconds)
+		if err == nil {
+			m.durations.set(bucket, *avg)
+			return
+		}
+		logging.Warnf("Failed to record analysis duration in DB: %v", err)
+	}
+	m.durations.add(bucket, seconds)
+}
+
+// etaSeconds 実行中のジョブの残り時間の推定（秒、推定できない場合はnil、m.muを保持した状態で呼ぶこと）
+// 構造数が同じ区分の平均所要時間から経過時間を引き、平均を超えている場合は進捗の割合から推定する
+func (m *Manager) etaSeconds(job *Job, now time.Time) *int {
+	if job.Status != StatusRunning || job.startedAt.IsZero() || job.cacheSource != nil {
+		return nil
+	}
+	avg, ok := m.durations.average(job.structures)
+	if !ok {
+		return nil
+	}
+	elapsed := now.Sub(job.startedAt).Seconds()
+	remaining := avg - elapsed
+	if remaining <= 0 {
+		if job.Progress <= 0 || job.Progress >= 100 {
+			return nil
+		}
+		remaining = elapsed * float64(100-job.Progress) / float64(job.Progress)
+	}
+	eta := int(remaining + 0.5)
+	return &eta
+}
+
+// ETASeconds ジョブの残り時間の推定（秒、メモリ上で実行中のジョブのみ、推定できない場合はnil）
+func (m *Manager) ETASeconds(jobID string) *int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	return m.etaSeconds(job, time.Now())
+}
+
+// GetJobStatus ジョブの状態を返す（メモリ上のジョブは残り時間の推定を含むコピー）
+func (m *Manager) GetJobStatus(jobID string) (*Job, error) {
+	m.mu.RLock()
+	if job, ok := m.jobs[jobID]; ok {
+		status := job.snapshot()
+		status.ETASeconds = m.etaSeconds(job, time.Now())
+		m.mu.RUnlock()
+		return status, nil
+	}
+	m.mu.RUnlock()
+	return m.GetJob(jobID)
+}
@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// イベントの種類
+const (
+	EventCreated          = "created"
+	EventStatusChanged    = "status_changed"
+	EventCancelRequested  = "cancel_requested"
+	EventRerunRequested   = "rerun_requested"
+	EventDeduplicated     = "deduplicated"
+	EventUploadRetry      = "upload_retry"
+	EventArtifactsExpired = "artifacts_expired"
+	EventArchived         = "archived"
+	EventRestoreRequested = "restore_requested"
+	EventRestored         = "restored"
+)
+
+// DBがない場合にイベントを保存するファイル（storage/<id>/events.jsonl）
+const eventsFile = "events.jsonl"
+
+// JobEvent ジョブの状態遷移・キャンセル要求・再実行などの記録
+type JobEvent struct {
+	Type       string                 `json:"type"`
+	FromStatus string                 `json:"from_status,omitempty"`
+	ToStatus   string                 `json:"to_status,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// recordEvent イベントを記録する（DBがあればanalysis_events、なければジョブディレクトリのevents.jsonl）
+// m.muの外で呼び、m.muは取得しない。記録に失敗してもジョブの処理は続行する
+func (m *Manager) recordEvent(jobID string, event JobEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	if m.db != nil {
+		record := &storage.AnalysisEvent{
+			AnalysisID: jobID,
+			Type:       event.Type,
+			Data:       event.Data,
+			CreatedAt:  event.CreatedAt,
+		}
+		if event.FromStatus != "" {
+			record.FromStatus = &event.FromStatus
+		}
+		if event.ToStatus != "" {
+			record.ToStatus = &event.ToStatus
+		}
+		if event.Message != "" {
+			record.Message = &event.Message
+		}
+		if err := m.db.CreateAnalysisEvent(record); err != nil {
+			logging.Job(jobID).Warnf("Failed to record %s event for %s: %v", event.Type, jobID, err)
+		}
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		logging.Job(jobID).Warnf("Failed to encode %s event for %s: %v", event.Type, jobID, err)
+		return
+	}
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+	jobDir, _ := m.findLocalDir(jobID)
+	if jobDir == "" {
+		// 削除済みのジョブ
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(jobDir, eventsFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logging.Job(jobID).Warnf("Failed to record %s event for %s: %v", event.Type, jobID, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logging.Job(jobID).Warnf("Failed to record %s event for %s: %v", event.Type, jobID, err)
+	}
+}
+
+// ListEvents ジョブのイベントを古い順に返す
+func (m *Manager) ListEvents(ctx context.Context, jobID string) ([]JobEvent, error) {
+	if m.db != nil {
+		records, err := m.db.ListAnalysisEvents(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		events := make([]JobEvent, 0, len(records))
+		for _, record := range records {
+			event := JobEvent{Type: record.Type, Data: record.Data, CreatedAt: record.CreatedAt}
+			if record.FromStatus != nil {
+				event.FromStatus = *record.FromStatus
+			}
+			if record.ToStatus != nil {
+				event.ToStatus = *record.ToStatus
+			}
+			if record.Message != nil {
+				event.Message = *record.Message
+			}
+			events = append(events, event)
+		}
+		return events, nil
+	}
+
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+	events := make([]JobEvent, 0)
+	jobDir, _ := m.findLocalDir(jobID)
+	if jobDir == "" {
+		return events, nil
+	}
+	f, err := os.Open(filepath.Join(jobDir, eventsFile))
+	if os.IsNotExist(err) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event JobEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// 書き込み途中で終了した行は読み飛ばす
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
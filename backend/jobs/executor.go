@@ -0,0 +1,263 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// キャンセル時にSIGTERMを送ってから、SIGKILLで強制終了するまでの猶予期間
+const processKillGracePeriod = 10 * time.Second
+
+// ProgressFunc 実行中の進捗（0-100）を通知する
+type ProgressFunc func(percent int, message string)
+
+// Executor 解析処理を実行する
+// キューイング・永続化・アップロードはManagerが担当し、Executorは成果物をjobDirに出力するだけ
+// ローカルのPython以外（Docker、SSH先のホスト、Kubernetes Jobなど）もこのインターフェースで差し替えられる
+type Executor interface {
+	// Name ログ用の名前
+	Name() string
+	// Run jobDirにresult.json・heatmap.png・dist_score.pngを出力する
+	// ctxがキャンセルされた場合（ユーザーによるキャンセル、タイムアウト）は処理を中断して戻ること
+	Run(ctx context.Context, job *Job, jobDir string, progress ProgressFunc) error
+}
+
+// ExecutionError 解析処理自体が失敗した（起動後に異常終了した）
+// Managerはresult.jsonにエラー内容があればそちらを優先して表示する
+type ExecutionError struct {
+	Err error
+}
+
+func (e *ExecutionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// SetExecutor 解析の実行方法を差し替える（デフォルトはLocalPythonExecutor）
+func (m *Manager) SetExecutor(executor Executor) {
+	m.executor = executor
+}
+
+// LocalPythonExecutor ローカルのPython CLI（dsa_cli）をサブプロセスとして実行する
+type LocalPythonExecutor struct {
+	PythonPath string
+	// Pythonディレクトリの探索の起点
+	StorageDir string
+	// 資源の制限（ジョブのparamsでさらに厳しくできる）
+	Limits ResourceLimits
+	// ジョブのparams.envで上書きできる環境変数
+	EnvAllowlist map[string]bool
+}
+
+func (e *LocalPythonExecutor) Name() string {
+	return "local-python"
+}
+
+func (e *LocalPythonExecutor) Run(ctx context.Context, job *Job, jobDir string, progress ProgressFunc) error {
+	// 再開元の作業ディレクトリ（ダウンロード済みのPDBファイル・完了したステージを再利用する）
+	job.mu.Lock()
+	resumeDir := job.resumeDir
+	job.mu.Unlock()
+
+	// Python CLIコマンドを構築（キャンセル可能なコンテキストを使用）
+	params := AnalysisParamsFromMap(job.params())
+	argv := e.commandArgs(job.UniProtID, params, jobDir, resumeDir)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+
+	// 独自のプロセスグループで起動し、キャンセル時はグループごと終了させる（exec.CommandContextの既定は直接のプロセスのみKill）
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		terminateProcessGroup(cmd.Process.Pid)
+		return nil
+	}
+	// 孫プロセスが出力のパイプを開いたままでもWaitが戻るようにする
+	cmd.WaitDelay = processKillGracePeriod + 5*time.Second
+
+	// ジョブにコマンドを保存（キャンセル時に使用）
+	job.mu.Lock()
+	job.cmd = cmd
+	job.mu.Unlock()
+
+	pythonDir, err := e.findPythonDir()
+	if err != nil {
+		return err
+	}
+
+	cmd.Dir = pythonDir
+	cmd.Env = os.Environ()
+	applyEnvOverrides(cmd, e.EnvAllowlist, job.ID, params.Env)
+	cmd.Env = append(cmd.Env, "PYTHONPATH="+pythonDir)
+
+	limits := effectiveLimits(e.Limits, params)
+	if limits != (ResourceLimits{}) {
+		job.logger().Debugf("Resource limits for job %s: %s", job.ID, limits)
+	}
+	applyThreadLimit(cmd, limits.Threads)
+	if err := applyMemoryLimit(cmd, limits.MaxMemoryMB); err != nil {
+		return fmt.Errorf("Failed to apply memory limit: %v", err)
+	}
+
+	logging.Debugf("Command directory: %s", cmd.Dir)
+	logging.Debugf("Command: %s %v", cmd.Path, cmd.Args)
+
+	// 出力はサーバーのコンソールに加えてjobDir/logs.txtにも書き込む（失敗の原因をユーザーが確認できるように）
+	logWriter, err := openJobLog(jobDir)
+	if err != nil {
+		return fmt.Errorf("Failed to create log file: %v", err)
+	}
+	defer logWriter.Close()
+
+	cmd.Stderr = io.MultiWriter(os.Stderr, logWriter)
+	// 標準出力のPROGRESS行を解析して進捗に反映する
+	stdout := newProgressWriter(io.MultiWriter(os.Stdout, logWriter), func(percent int, message string) {
+		if ctx.Err() != nil {
+			return
+		}
+		progress(percent, message)
+	})
+	cmd.Stdout = stdout
+
+	progress(0, "Running Python analysis...")
+
+	// コマンドを開始してプロセスIDを取得
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Failed to start command: %v", err)
+	}
+
+	setProcessNice(cmd.Process.Pid, limits.Nice)
+
+	// プロセスIDをファイルに保存（後で強制終了するため）
+	pidFile := filepath.Join(jobDir, "pid.txt")
+	if cmd.Process != nil {
+		pid := cmd.Process.Pid
+		if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
+			logging.Warnf("Failed to save PID file: %v", err)
+		} else {
+			logging.Debugf("Saved PID %d to %s", pid, pidFile)
+		}
+	}
+	defer func() {
+		if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
+			logging.Warnf("Failed to remove PID file: %v", err)
+		}
+	}()
+
+	// コマンド実行（キャンセルされた場合はエラーが返る）
+	err = cmd.Wait()
+	stdout.Flush()
+	if err != nil {
+		return &ExecutionError{Err: err}
+	}
+	logging.Debugf("Command executed successfully")
+	return nil
+}
+
+// commandArgs Python CLI（dsa_cli run）の引数を組み立てる（先頭はPythonの実行ファイル、ドライランでも使う）
+func (e *LocalPythonExecutor) commandArgs(uniprotID string, params AnalysisParams, jobDir, resumeDir string) []string {
+	args := []string{e.PythonPath, "-m", "dsa_cli", "run",
+		"--uniprot", uniprotID,
+		"--out", jobDir,
+	}
+	args = append(args, params.CLIArgs()...)
+
+	if resumeDir != "" {
+		args = append(args, "--resume", resumeDir)
+	}
+	return args
+}
+
+// AnalysisCLIArgs 解析で実行したPython CLIの引数（--uniprot・--out・--resumeを除く、分からない場合はnil）
+// DBがある場合は作成時に記録した引数、ない場合はメモリ上のジョブのパラメータから組み立てる
+func (m *Manager) AnalysisCLIArgs(ctx context.Context, id string) []string {
+	if m.db != nil {
+		args, err := m.db.AnalysisCLIArgs(ctx, id)
+		if err != nil {
+			logging.Warnf("Failed to get CLI args of %s: %v", id, err)
+		}
+		return args
+	}
+	job, err := m.GetJob(id)
+	if err != nil {
+		return nil
+	}
+	return AnalysisParamsFromMap(job.Params).CLIArgs()
+}
+
+// findPythonDir dsa_cli.pyのあるPythonディレクトリを探す
+func (e *LocalPythonExecutor) findPythonDir() (string, error) {
+	// 作業ディレクトリを設定（Pythonモジュールのルート）
+	// storageDirから見て、親ディレクトリのpythonディレクトリを探す
+	storageAbs, err := filepath.Abs(e.StorageDir)
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve storage path: %v", err)
+	}
+
+	// デバッグ: パス情報をログ出力
+	logging.Debugf("storageDir: %s", e.StorageDir)
+	logging.Debugf("storageAbs: %s", storageAbs)
+
+	// storageDirがbackend/storageの場合、backendの親（okada）からpythonを探す
+	// まず、storageの親（backend）を取得
+	parentDir := filepath.Dir(storageAbs)
+	// 次に、backendの親（okada）を取得
+	rootDir := filepath.Dir(parentDir)
+	// okada/pythonを探す
+	pythonDir := filepath.Join(rootDir, "python")
+
+	logging.Debugf("parentDir: %s", parentDir)
+	logging.Debugf("rootDir: %s", rootDir)
+	logging.Debugf("pythonDir (first try): %s", pythonDir)
+
+	// Pythonディレクトリの存在確認
+	if _, err := os.Stat(pythonDir); os.IsNotExist(err) {
+		logging.Debugf("First pythonDir not found, trying alternative...")
+		// もし見つからなければ、storageの親から直接探す（storageがokada直下にある場合）
+		altPythonDir := filepath.Join(parentDir, "python")
+		logging.Debugf("pythonDir (alternative): %s", altPythonDir)
+		if _, err := os.Stat(altPythonDir); os.IsNotExist(err) {
+			// さらに、環境変数で指定されたパスを試す
+			if envPythonDir := os.Getenv("PYTHON_DIR"); envPythonDir != "" {
+				envPythonDir, _ = filepath.Abs(envPythonDir)
+				logging.Debugf("pythonDir (from env PYTHON_DIR): %s", envPythonDir)
+				if _, err := os.Stat(envPythonDir); err == nil {
+					pythonDir = envPythonDir
+				} else {
+					errorMsg := fmt.Sprintf("Python directory not found. Tried:\n1. %s\n2. %s\n3. %s (from env)\nStorage: %s", pythonDir, altPythonDir, envPythonDir, storageAbs)
+					logging.Debugf("%s", errorMsg)
+					return "", fmt.Errorf("%s", errorMsg)
+				}
+			} else {
+				errorMsg := fmt.Sprintf("Python directory not found. Tried:\n1. %s\n2. %s\nStorage: %s\nHint: Set PYTHON_DIR environment variable", pythonDir, altPythonDir, storageAbs)
+				logging.Debugf("%s", errorMsg)
+				return "", fmt.Errorf("%s", errorMsg)
+			}
+		} else {
+			pythonDir = altPythonDir
+		}
+	}
+
+	logging.Debugf("Using pythonDir: %s", pythonDir)
+
+	// Pythonディレクトリの最終確認
+	if _, err := os.Stat(pythonDir); os.IsNotExist(err) {
+		return "", fmt.Errorf("Python directory does not exist: %s", pythonDir)
+	}
+
+	// dsa_cli.pyの存在確認
+	dsaCliPath := filepath.Join(pythonDir, "dsa_cli.py")
+	if _, err := os.Stat(dsaCliPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("dsa_cli.py not found in: %s", pythonDir)
+	}
+	logging.Debugf("dsa_cli.py found at: %s", dsaCliPath)
+
+	return pythonDir, nil
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 失敗の分類（PythonはFetchError等でresult.jsonのerror_categoryに設定する）
+const (
+	// データが不十分（構造が見つからない・鎖の数が足りない）。特定のタンパク質の問題でアラートの対象ではない
+	FailureNoStructures = "no_structures"
+	// 存在しないUniProt ID等の入力の誤り
+	FailureInvalidInput = "invalid_input"
+	// UniProtからの取得に失敗した
+	FailureUniProt = "uniprot"
+	// PDBの構造ファイルの取得に失敗した
+	FailurePDBe = "pdbe"
+	// 解析中のエラー（上記以外のPythonの例外）
+	FailureAnalysis = "analysis"
+	// タイムアウト
+	FailureTimeout = "timeout"
+	// サーバー側のエラー（作業ディレクトリの作成・結果ファイルの読み込み・Pythonの起動等）
+	FailureInternal = "internal"
+)
+
+// FailureCategories 失敗の分類の一覧（メトリクスは未発生の分類も0として出力する）
+var FailureCategories = []string{FailureNoStructures, FailureInvalidInput, FailureUniProt, FailurePDBe, FailureAnalysis, FailureTimeout, FailureInternal}
+
+// 外部の依存先
+const (
+	DependencyUniProt = "uniprot"
+	DependencyPDBe    = "pdbe"
+	DependencyR2      = "r2"
+	DependencyDB      = "db"
+)
+
+// Dependencies 外部の依存先の一覧
+var Dependencies = []string{DependencyUniProt, DependencyPDBe, DependencyR2, DependencyDB}
+
+// FailureStats 失敗の連続回数（アラート用）
+type FailureStats struct {
+	// 分類ごとの連続失敗数（解析が成功するとすべて0に戻る）
+	Streaks map[string]int
+	// 分類ごとの失敗の累計
+	Totals map[string]int
+	// 依存先ごとの連続失敗数（その依存先への処理が成功すると0に戻る）
+	Dependencies map[string]int
+}
+
+// failureTracker 失敗の連続回数を記録する
+type failureTracker struct {
+	mu           sync.Mutex
+	streaks      map[string]int
+	totals       map[string]int
+	dependencies map[string]int
+}
+
+func newFailureTracker() *failureTracker {
+	return &failureTracker{
+		streaks:      make(map[string]int),
+		totals:       make(map[string]int),
+		dependencies: make(map[string]int),
+	}
+}
+
+// jobFailed 解析の失敗を記録する
+// UniProtの取得より後の段階で失敗した場合はUniProtへの接続は成功しているとみなす
+func (t *failureTracker) jobFailed(category string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streaks[category]++
+	t.totals[category]++
+	switch category {
+	case FailureUniProt:
+		t.dependencies[DependencyUniProt]++
+	case FailurePDBe:
+		t.dependencies[DependencyPDBe]++
+		t.dependencies[DependencyUniProt] = 0
+	case FailureNoStructures, FailureInvalidInput, FailureAnalysis:
+		t.dependencies[DependencyUniProt] = 0
+	}
+}
+
+// jobSucceeded 解析の成功を記録する（UniProt・PDBのどちらからも取得できている）
+func (t *failureTracker) jobSucceeded() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.streaks)
+	t.dependencies[DependencyUniProt] = 0
+	t.dependencies[DependencyPDBe] = 0
+}
+
+// dependency 依存先への処理の成否を記録する
+func (t *failureTracker) dependency(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.dependencies[name]++
+	} else {
+		t.dependencies[name] = 0
+	}
+}
+
+// FailureStats 分類ごと・依存先ごとの失敗の連続回数を返す
+// 「PDBが落ちている」（pdbeの連続失敗が増え続ける）と「1つのタンパク質に構造がない」（no_structuresが1回）を区別できる
+func (m *Manager) FailureStats() FailureStats {
+	t := m.failures
+	t.mu.Lock()
+	stats := FailureStats{
+		Streaks:      make(map[string]int, len(FailureCategories)),
+		Totals:       make(map[string]int, len(FailureCategories)),
+		Dependencies: make(map[string]int, len(Dependencies)),
+	}
+	for _, category := range FailureCategories {
+		stats.Streaks[category] = t.streaks[category]
+		stats.Totals[category] = t.totals[category]
+	}
+	for _, name := range Dependencies {
+		stats.Dependencies[name] = t.dependencies[name]
+	}
+	t.mu.Unlock()
+
+	// R2はサーキットブレーカーが連続失敗数を記録している
+	if m.r2 != nil {
+		stats.Dependencies[DependencyR2] = m.r2.BreakerStatus().ConsecutiveFailures
+	}
+	return stats
+}
+
+// failJob 失敗の分類を記録してジョブを失敗にする
+func (m *Manager) failJob(job *Job, category, message string) {
+	m.failures.jobFailed(category)
+	m.updateJobStatus(job, StatusFailed, 0, message)
+}
+
+// resultFailureCategory result.jsonのerror_categoryを返す（ない場合はfallback）
+func resultFailureCategory(jobDir, fallback string) string {
+	data, err := os.ReadFile(filepath.Join(jobDir, "result.json"))
+	if err != nil {
+		return fallback
+	}
+	var result struct {
+		ErrorCategory string `json:"error_category"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fallback
+	}
+	for _, category := range FailureCategories {
+		if result.ErrorCategory == category {
+			return category
+		}
+	}
+	return fallback
+}
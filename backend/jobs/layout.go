@@ -0,0 +1,263 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// 保存形式のバージョン（解析ごとにDBのstorage_layout・status.jsonに記録する）
+const (
+	// LayoutFlat ローカルは <storageDir>/<id>、R2は analysis/<id>/（従来の形式）
+	LayoutFlat = 1
+	// LayoutSharded IDの先頭2文字で分ける（ローカルは <storageDir>/analyses/ab/<id>、R2は analyses/ab/<id>/）
+	// 1つのディレクトリ・プレフィックスに解析が溜まりすぎないようにする
+	LayoutSharded = 2
+)
+
+// DefaultStorageLayout 新しい解析の保存形式（STORAGE_LAYOUTで変更できる）
+const DefaultStorageLayout = LayoutFlat
+
+// StorageLayout 解析の成果物の保存場所（ローカルのジョブディレクトリ・R2のキー）の決め方
+// 保存形式を変える場合は新しいバージョンを追加し、既存の解析は記録されたバージョンのまま読めるようにする
+// （移行は dsa-admin layout migrate）
+type StorageLayout struct {
+	Version int
+	Name    string
+	// R2のキーのルート（照合で一覧する単位、末尾は/）
+	R2Root string
+	// storageDirからの相対パス（解析IDのディレクトリを含む階層のルート、空の場合はstorageDir直下）
+	LocalRoot string
+	// IDを分ける階層の文字数（0の場合は分けない）
+	ShardChars int
+}
+
+var storageLayouts = []*StorageLayout{
+	{Version: LayoutFlat, Name: "flat", R2Root: "analysis/"},
+	{Version: LayoutSharded, Name: "sharded", R2Root: "analyses/", LocalRoot: "analyses", ShardChars: 2},
+}
+
+// StorageLayouts 登録されている保存形式（バージョン順）
+func StorageLayouts() []*StorageLayout {
+	return append([]*StorageLayout(nil), storageLayouts...)
+}
+
+// LookupStorageLayout バージョンから保存形式を取得する（0は従来の形式とみなす）
+func LookupStorageLayout(version int) (*StorageLayout, bool) {
+	if version == 0 {
+		version = LayoutFlat
+	}
+	for _, l := range storageLayouts {
+		if l.Version == version {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// storageLayout バージョンから保存形式を取得する（未知のバージョンは従来の形式）
+func storageLayout(version int) *StorageLayout {
+	if l, ok := LookupStorageLayout(version); ok {
+		return l
+	}
+	logging.Warnf("Unknown storage layout version %d, using %d", version, LayoutFlat)
+	l, _ := LookupStorageLayout(LayoutFlat)
+	return l
+}
+
+// shard 解析IDを分ける階層（ShardCharsが0、またはIDが短い場合は空文字列）
+func (l *StorageLayout) shard(id string) string {
+	if l.ShardChars <= 0 || len(id) <= l.ShardChars {
+		return ""
+	}
+	return strings.ToLower(id[:l.ShardChars])
+}
+
+// Prefix 解析の成果物をまとめるR2のプレフィックス（末尾の/なし、削除はこの単位で行う）
+func (l *StorageLayout) Prefix(id string) string {
+	if shard := l.shard(id); shard != "" {
+		return l.R2Root + shard + "/" + id
+	}
+	return l.R2Root + id
+}
+
+// LocalDir 解析のローカルのジョブディレクトリ
+func (l *StorageLayout) LocalDir(storageDir, id string) string {
+	return filepath.Join(storageDir, l.LocalRoot, l.shard(id), id)
+}
+
+// AnalysisIDFromKey R2のキーから解析IDを取り出す（この保存形式のキーでない場合はfalse）
+func (l *StorageLayout) AnalysisIDFromKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, l.R2Root)
+	if !ok {
+		return "", false
+	}
+	shard := ""
+	if l.ShardChars > 0 {
+		if shard, rest, ok = strings.Cut(rest, "/"); !ok {
+			return "", false
+		}
+	}
+	id, _, ok := strings.Cut(rest, "/")
+	if !ok || id == "" || l.shard(id) != shard {
+		return "", false
+	}
+	return id, true
+}
+
+// LocalAnalysisDirs この保存形式のローカルのジョブディレクトリ（名前が解析IDのもの、IDをキーにする）
+func (l *StorageLayout) LocalAnalysisDirs(storageDir string) (map[string]string, error) {
+	root := filepath.Join(storageDir, l.LocalRoot)
+	parents := []string{root}
+	if l.ShardChars > 0 {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return map[string]string{}, nil
+			}
+			return nil, err
+		}
+		parents = parents[:0]
+		for _, entry := range entries {
+			if entry.IsDir() && len(entry.Name()) == l.ShardChars {
+				parents = append(parents, filepath.Join(root, entry.Name()))
+			}
+		}
+	}
+
+	dirs := make(map[string]string)
+	for _, parent := range parents {
+		entries, err := os.ReadDir(parent)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if _, err := uuid.Parse(entry.Name()); err != nil {
+				continue
+			}
+			dirs[entry.Name()] = filepath.Join(parent, entry.Name())
+		}
+	}
+	return dirs, nil
+}
+
+// SetStorageLayout 新しい解析の保存形式を設定する（既存の解析は記録されたバージョンのまま読み書きする）
+func (m *Manager) SetStorageLayout(version int) error {
+	l, ok := LookupStorageLayout(version)
+	if !ok {
+		return fmt.Errorf("unknown storage layout version: %d", version)
+	}
+	m.layout = l
+	return nil
+}
+
+// StorageLayout 新しい解析の保存形式
+func (m *Manager) StorageLayout() *StorageLayout {
+	return m.layout
+}
+
+// layoutOf 解析の保存形式（メモリ上のジョブ、DBの記録、ローカルのディレクトリの順に調べ、分からない場合は従来の形式）
+func (m *Manager) layoutOf(id string) *StorageLayout {
+	m.mu.RLock()
+	job, ok := m.jobs[id]
+	var version int
+	if ok {
+		version = job.storageLayout
+	}
+	m.mu.RUnlock()
+	if ok {
+		return storageLayout(version)
+	}
+
+	if m.db != nil {
+		version, err := m.db.GetAnalysisStorageLayout(id)
+		if err == nil {
+			return storageLayout(version)
+		}
+	}
+	if dir, l := m.findLocalDir(id); dir != "" {
+		return l
+	}
+	return storageLayout(LayoutFlat)
+}
+
+// findLocalDir ローカルに存在するジョブディレクトリと保存形式を探す（新しい解析の保存形式から順に、なければ空文字列）
+func (m *Manager) findLocalDir(id string) (string, *StorageLayout) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", nil
+	}
+	layouts := append([]*StorageLayout{m.layout}, storageLayouts...)
+	for _, l := range layouts {
+		dir := l.LocalDir(m.storageDir, id)
+		if _, err := os.Stat(dir); err == nil {
+			return dir, l
+		}
+	}
+	return "", nil
+}
+
+// localDirOf ジョブの保存形式でのローカルのジョブディレクトリ
+func (m *Manager) localDirOf(job *Job) string {
+	return storageLayout(job.storageLayout).LocalDir(m.storageDir, job.ID)
+}
+
+// localJobDir 解析のローカルのジョブディレクトリ（存在しない場合は解析の保存形式で決まるパス）
+func (m *Manager) localJobDir(id string) string {
+	if dir, _ := m.findLocalDir(id); dir != "" {
+		return dir
+	}
+	return m.layoutOf(id).LocalDir(m.storageDir, id)
+}
+
+// LocalJobDir 解析のローカルのジョブディレクトリ（API・管理ツール用）
+func (m *Manager) LocalJobDir(id string) string {
+	return m.localJobDir(id)
+}
+
+// artifactPrefix 解析の保存形式でのR2のプレフィックス
+func (m *Manager) artifactPrefix(id string) string {
+	return m.layoutOf(id).Prefix(id)
+}
+
+// ArtifactKey 解析の保存形式で名前に対応するR2キー（DBに保存されたキーがない成果物の取得用）
+func (m *Manager) ArtifactKey(id, name string) string {
+	return artifactKeyIn(m.layoutOf(id), id, name)
+}
+
+// localAnalysisDirs すべての保存形式のローカルのジョブディレクトリ（IDをキーにする）
+func (m *Manager) localAnalysisDirs() (map[string]string, error) {
+	dirs := make(map[string]string)
+	for _, l := range storageLayouts {
+		found, err := l.LocalAnalysisDirs(m.storageDir)
+		if err != nil {
+			return nil, err
+		}
+		for id, dir := range found {
+			if _, ok := dirs[id]; !ok {
+				dirs[id] = dir
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// sortedIDs マップのキーをソートして返す
+func sortedIDs(dirs map[string]string) []string {
+	ids := make([]string, 0, len(dirs))
+	for id := range dirs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
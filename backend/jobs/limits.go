@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// ResourceLimits Pythonプロセスに適用する資源の制限（0は制限なし）
+// 1つの重いタンパク質の解析がサーバー全体を占有しないようにする
+type ResourceLimits struct {
+	// niceの値（0-19、大きいほど優先度が低い）
+	Nice int
+	// 仮想メモリの上限（MB）
+	MaxMemoryMB int
+	// numpy等が使うスレッド数（OMP_NUM_THREADS等）
+	Threads int
+}
+
+// スレッド数を指定する環境変数（BLASの実装ごとに異なる）
+var threadEnvVars = []string{"OMP_NUM_THREADS", "OPENBLAS_NUM_THREADS", "MKL_NUM_THREADS", "NUMEXPR_NUM_THREADS"}
+
+// SetResourceLimits ローカルのPythonで実行する場合の資源の制限を設定する
+func (m *Manager) SetResourceLimits(limits ResourceLimits) {
+	if e, ok := m.executor.(*LocalPythonExecutor); ok {
+		e.Limits = limits
+	}
+}
+
+// effectiveLimits サーバーの制限とparams（nice・max_memory_mb・threads）から、ジョブに適用する制限を決める
+// paramsではサーバーの制限より緩くすることはできない（優先度を下げる・上限を小さくする方向のみ）
+func effectiveLimits(server ResourceLimits, params AnalysisParams) ResourceLimits {
+	limits := server
+	if params.Nice > limits.Nice {
+		limits.Nice = min(params.Nice, 19)
+	}
+	if v := params.MaxMemoryMB; v > 0 && (limits.MaxMemoryMB == 0 || v < limits.MaxMemoryMB) {
+		limits.MaxMemoryMB = v
+	}
+	if v := params.Threads; v > 0 && (limits.Threads == 0 || v < limits.Threads) {
+		limits.Threads = v
+	}
+	return limits
+}
+
+// applyThreadLimit スレッド数の環境変数を設定する（起動前に呼ぶ）
+func applyThreadLimit(cmd *exec.Cmd, threads int) {
+	if threads <= 0 {
+		return
+	}
+	for _, name := range threadEnvVars {
+		cmd.Env = append(cmd.Env, name+"="+strconv.Itoa(threads))
+	}
+}
+
+func (l ResourceLimits) String() string {
+	return fmt.Sprintf("nice=%d, max_memory_mb=%d, threads=%d", l.Nice, l.MaxMemoryMB, l.Threads)
+}
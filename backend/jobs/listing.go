@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ジョブ一覧の1ページの件数（デフォルトと上限）
+const (
+	DefaultJobListLimit = 50
+	MaxJobListLimit     = 200
+)
+
+// ErrInvalidCursor カーソルの形式が正しくない
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// JobListFilter ジョブ一覧の絞り込み条件
+type JobListFilter struct {
+	Status    JobStatus
+	UniProtID string
+	// この日時より後に作成されたジョブのみ（ゼロ値は指定なし）
+	CreatedAfter time.Time
+	// セッションID（空の場合は絞り込まない）
+	SessionID string
+	Limit     int
+	// 前のページのNextCursor（空の場合は先頭から）
+	Cursor string
+}
+
+// JobListPage ジョブ一覧の1ページ（作成日時の新しい順）
+type JobListPage struct {
+	Jobs []*Job
+	// 次のページのカーソル（最後のページでは空）
+	NextCursor string
+}
+
+// jobCursor ページの最後のジョブの位置（作成日時、同じ場合はIDの降順）
+type jobCursor struct {
+	createdAt time.Time
+	id        string
+}
+
+// follows 作成日時・IDがcreatedAt・idのジョブがカーソルより後ろに並ぶか
+func (c jobCursor) follows(createdAt time.Time, id string) bool {
+	if !createdAt.Equal(c.createdAt) {
+		return createdAt.Before(c.createdAt)
+	}
+	return id < c.id
+}
+
+func encodeJobCursor(job *Job) string {
+	raw := strconv.FormatInt(job.CreatedAt.UnixNano(), 10) + ":" + job.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeJobCursor(cursor string) (*jobCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &jobCursor{createdAt: time.Unix(0, n), id: id}, nil
+}
+
+// ListJobs メモリ上のジョブとDBの解析をまとめて作成日時の新しい順に返す（DBがない場合はメモリ上のジョブのみ）
+// 同じジョブがメモリとDBの両方にある場合はメモリ上の状態（最新の進捗）を返す
+func (m *Manager) ListJobs(ctx context.Context, filter JobListFilter) (*JobListPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultJobListLimit
+	}
+	filter.Limit = min(filter.Limit, MaxJobListLimit)
+	var cursor *jobCursor
+	if filter.Cursor != "" {
+		c, err := decodeJobCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+
+	matches := func(job *Job) bool {
+		if filter.Status != "" && job.Status != filter.Status {
+			return false
+		}
+		if filter.UniProtID != "" && job.UniProtID != filter.UniProtID {
+			return false
+		}
+		if !filter.CreatedAfter.IsZero() && !job.CreatedAt.After(filter.CreatedAfter) {
+			return false
+		}
+		return cursor == nil || cursor.follows(job.CreatedAt, job.ID)
+	}
+
+	byID := make(map[string]*Job)
+	inMemory := make(map[string]bool)
+	now := time.Now()
+	m.mu.RLock()
+	for id, job := range m.jobs {
+		inMemory[id] = true
+		if filter.SessionID != "" {
+			if sessionID, _ := job.Params["session_id"].(string); sessionID != filter.SessionID {
+				continue
+			}
+		}
+		if matches(job) {
+			snapshot := job.snapshot()
+			snapshot.ETASeconds = m.etaSeconds(job, now)
+			byID[id] = snapshot
+		}
+	}
+	m.mu.RUnlock()
+
+	if m.db != nil {
+		// 次のページの有無を判定するため1件多く取得する
+		filters := map[string]interface{}{"limit": filter.Limit + 1}
+		if filter.Status != "" {
+			filters["status"] = string(filter.Status)
+		}
+		if filter.UniProtID != "" {
+			filters["uniprot_id"] = filter.UniProtID
+		}
+		if filter.SessionID != "" {
+			filters["session_id"] = filter.SessionID
+		}
+		if !filter.CreatedAfter.IsZero() {
+			filters["from"] = filter.CreatedAfter.Format(time.RFC3339Nano)
+		}
+		if cursor != nil {
+			filters["to"] = cursor.createdAt.Format(time.RFC3339Nano)
+		}
+		records, err := m.db.SearchAnalyses(ctx, filters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list analyses: %w", err)
+		}
+		for _, record := range records {
+			if inMemory[record.ID] {
+				continue
+			}
+			// セッションはDBで絞り込み済み
+			if job := jobFromRecord(record); matches(job) {
+				byID[record.ID] = job
+			}
+		}
+	}
+
+	list := make([]*Job, 0, len(byID))
+	for _, job := range byID {
+		list = append(list, job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+
+	// 他のセッションのジョブも含まれるため、セッションIDは返さない
+	for _, job := range list {
+		job.Params = withoutSessionID(job.Params)
+	}
+
+	page := &JobListPage{Jobs: list}
+	if len(list) > filter.Limit {
+		page.Jobs = list[:filter.Limit]
+		page.NextCursor = encodeJobCursor(page.Jobs[filter.Limit-1])
+	}
+	return page, nil
+}
+
+// snapshot 一覧表示用にジョブの公開フィールドをコピーする（m.muを保持した状態で呼ぶこと）
+func (j *Job) snapshot() *Job {
+	return &Job{
+		ID:           j.ID,
+		Status:       j.Status,
+		Progress:     j.Progress,
+		Message:      j.Message,
+		UniProtID:    j.UniProtID,
+		Params:       j.Params,
+		Result:       j.Result,
+		ErrorMessage: j.ErrorMessage,
+		CreatedAt:    j.CreatedAt,
+		UpdatedAt:    j.UpdatedAt,
+		Cached:       j.Cached,
+		CachedFrom:   j.CachedFrom,
+		RequestID:    j.RequestID,
+	}
+}
+
+// withoutSessionID session_idを除いたparamsのコピーを返す
+func withoutSessionID(params map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if key != "session_id" {
+			copied[key] = value
+		}
+	}
+	return copied
+}
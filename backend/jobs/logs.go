@@ -0,0 +1,120 @@
+package jobs
+
+import (
+	"bytes"
+	"dsa-api/logging"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Pythonの標準出力・標準エラーを保存するファイル名と上限サイズ
+const (
+	jobLogFile     = "logs.txt"
+	maxJobLogBytes = 10 << 20
+)
+
+// jobLogWriter 子プロセスの出力をlogs.txtに書き込む（標準出力・標準エラーから並行して呼ばれる）
+// 上限を超えた出力は破棄し、一度だけその旨を書き込む
+type jobLogWriter struct {
+	f         *os.File
+	written   int64
+	truncated bool
+	mu        sync.Mutex
+}
+
+func openJobLog(jobDir string) (*jobLogWriter, error) {
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(jobDir, jobLogFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &jobLogWriter{f: f}, nil
+}
+
+func (w *jobLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.truncated {
+		return len(p), nil
+	}
+	if w.written+int64(len(p)) > maxJobLogBytes {
+		w.truncated = true
+		w.f.WriteString("\n[log truncated: output exceeded 10 MiB]\n")
+		return len(p), nil
+	}
+	n, err := w.f.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		// ログの書き込み失敗で解析を止めない
+		logging.Warnf("Failed to write job log: %v", err)
+		w.truncated = true
+	}
+	return len(p), nil
+}
+
+func (w *jobLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// LocalLogPath ローカルにあるジョブのlogs.txtのパス（実行中の一時ディレクトリを含む、なければ空文字列）
+func (m *Manager) LocalLogPath(jobID string) string {
+	m.mu.RLock()
+	job, exists := m.jobs[jobID]
+	m.mu.RUnlock()
+
+	candidates := []string{filepath.Join(m.localJobDir(jobID), jobLogFile)}
+	if exists {
+		job.mu.Lock()
+		if job.dir != "" {
+			candidates = append([]string{filepath.Join(job.dir, jobLogFile)}, candidates...)
+		}
+		job.mu.Unlock()
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// saveFailureLogs 完了しなかったジョブのlogs.txtをR2に保存する（成功したジョブは他の成果物と一緒にアップロードされる）
+// DBがある場合は作業ディレクトリが一時ディレクトリのため、保存しないと失敗の原因を確認できない
+func (m *Manager) saveFailureLogs(job *Job, jobDir string) {
+	if m.r2 == nil || m.db == nil {
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(jobDir, jobLogFile))
+	if err != nil {
+		return
+	}
+	if err := m.putObject(artifactKeyIn(storageLayout(job.storageLayout), job.ID, jobLogFile), data, "text/plain; charset=utf-8"); err != nil {
+		job.logger().Warnf("Failed to upload logs for %s: %v", job.ID, err)
+	}
+}
+
+// TailLines 末尾のn行を返す（n <= 0の場合はそのまま）
+func TailLines(data []byte, n int) []byte {
+	if n <= 0 {
+		return data
+	}
+	end := len(data)
+	// 最後の改行は行の区切りとして数えない
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	idx := end
+	for i := 0; i < n; i++ {
+		idx = bytes.LastIndexByte(data[:idx], '\n')
+		if idx < 0 {
+			return data
+		}
+	}
+	return data[idx+1:]
+}
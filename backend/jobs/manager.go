@@ -2,8 +2,10 @@ package jobs
 
 import (
 	"context"
+	"dsa-api/logging"
 	"dsa-api/storage"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -35,10 +37,51 @@ type Job struct {
 	ErrorMessage string                `json:"error_message,omitempty"`
 	CreatedAt   time.Time              `json:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at"`
+	// 結果キャッシュから作成した（Pythonを実行せずにCachedFromの成果物をコピーした）
+	Cached     bool   `json:"cached,omitempty"`
+	CachedFrom string `json:"cached_from,omitempty"`
+	// 残り時間の推定（秒、実行中のジョブの状態取得時のみ）
+	ETASeconds *int `json:"eta_seconds,omitempty"`
+	// 作成したHTTPリクエスト・キューのメッセージのID（ログ・イベントとの照合用、メモリ上のジョブのみ）
+	RequestID string `json:"request_id,omitempty"`
+	cacheSource *cacheSource
+	// 実行を開始した時刻と解析対象の構造数（進捗メッセージから取得、残り時間の推定用、m.muで保護）
+	startedAt  time.Time
+	structures int
+	// 再開元の作業ディレクトリ（params.resume_fromから用意、Executorが--resumeで渡す）
+	resumeDir string
+	// 実行中の作業ディレクトリ（実行中のlogs.txtの参照用）
+	dir string
 	// For cancellation
 	cmd    *exec.Cmd
 	cancel context.CancelFunc
-	mu     sync.Mutex
+	// キャンセルが要求された（executeJobがcancelを登録する前に要求された場合も登録時にキャンセルする、muで保護）
+	cancelRequested bool
+	mu              sync.Mutex
+	// 実行（キャンセル・失敗時の後処理を含む）が終わると閉じる（作成したジョブのみ、WaitSettled用）
+	settled chan struct{}
+	// 同じジョブの状態更新（DB・イベント・通知）を順番に行う（m.muと異なり他のジョブの操作はブロックしない）
+	updateMu sync.Mutex
+	// 成果物の保存形式のバージョン（作成時に決まり、移行するまで変わらない）
+	storageLayout int
+	// Paramsの差し替え（ReassignSession）はm.muとparamsMuの両方を保持して行う
+	// m.muを保持せずにParamsを読む実行中の処理（Executor・スケジューラー等）はparams()で読む
+	paramsMu sync.RWMutex
+}
+
+// params m.muを保持していない処理から読むパラメータ（マップ自体は書き換えないため、返した後は保護しなくてよい）
+func (j *Job) params() map[string]interface{} {
+	j.paramsMu.RLock()
+	defer j.paramsMu.RUnlock()
+	return j.Params
+}
+
+// logger job_id（作成したリクエストがわかる場合はrequest_idも）を付けたロガー
+func (j *Job) logger() *logging.Logger {
+	if j.RequestID == "" {
+		return logging.Job(j.ID)
+	}
+	return logging.Job(j.ID).With("request_id", j.RequestID)
 }
 
 type JobResult struct {
@@ -51,13 +94,62 @@ type Manager struct {
 	jobs         map[string]*Job
 	mu           sync.RWMutex
 	storageDir   string
-	pythonPath   string
+	// 解析の実行方法（デフォルトはローカルのPython）
+	executor Executor
 	maxConcurrent int
-	semaphore    chan struct{}
+	// 実行枠（セッション間で公平に割り当てる）
+	scheduler *fairScheduler
 	// Optional: DB and R2 for persistence
 	db  *storage.DB
-	r2  *storage.R2Client
+	r2  *storage.GuardedR2Client
 	ctx context.Context
+	// ステータス変更の購読者（WebSocket配信用）
+	subscribers map[chan JobUpdate]struct{}
+	listeners   []func(JobUpdate)
+	// 保存された内容の変更の通知先（検索インデックスの同期用）
+	changeListeners []func(jobID string, deleted bool)
+	subMu           sync.Mutex
+	// DB・ローカル・R2の照合（同時に1つのみ実行する）
+	reconcileMu sync.Mutex
+	// 失敗の分類ごと・依存先ごとの連続回数（アラート用のメトリクス）
+	failures *failureTracker
+	// 削除した解析のストレージの後片付け（R2・ローカル、バックグラウンドで再試行する）
+	deletions *deletionQueue
+	// キュー待ち・実行中のジョブの概要（GET /api/jobs/active、m.muを保持して更新する）
+	active *activeIndex
+	// 新しい解析の保存形式（既存の解析は記録されたバージョンを使う）
+	layout *StorageLayout
+	// 構造数の区分ごとの所要時間の平均（残り時間の推定用）
+	durations *durationTracker
+	// R2アップロード専用のワーカープールとスプール
+	spool *uploadSpool
+	// ジョブのデフォルトのタイムアウト（0は無制限）
+	defaultTimeout time.Duration
+	// キュー待ち・実行中のジョブ数の上限（0は無制限、m.muで保護）
+	sessionQuota int
+	globalQuota  int
+	// ジョブのparams.envで上書きできる環境変数
+	envAllowlist map[string]bool
+	// 結果キャッシュの有効期間（0は無効）
+	cacheTTL time.Duration
+	// 作業ディレクトリをチェックポイントとしてR2に保存する
+	checkpointUpload bool
+	// PythonがダウンロードしたPDBファイルを成果物と一緒にR2に保存する
+	pdbUpload bool
+	// 成果物の保持期間（0は無期限、DBのレコードは残す、retentionMuで保護）
+	artifactRetention time.Duration
+	// 解析の保持期間（0は無期限、固定された解析は対象外、retentionMuで保護）
+	retention time.Duration
+	// 保持期間は管理用APIから実行中に変更できる
+	retentionMu sync.RWMutex
+	// DBがない場合のevents.jsonlへの書き込み
+	eventsMu sync.Mutex
+	// 成果物の長期保存先（S3 Glacier等、nilは無効）
+	archive *archiver
+	// ジョブ作成時に取得したUniProtのタンパク質の情報（nilは取得しない）
+	proteins *proteinCache
+	// 一時ディレクトリのルートと孤立した一時ディレクトリの掃除
+	temp tempSweeper
 }
 
 func NewManager(storageDir, pythonPath string, maxConcurrent int) *Manager {
@@ -67,29 +159,63 @@ func NewManager(storageDir, pythonPath string, maxConcurrent int) *Manager {
 	return &Manager{
 		jobs:         make(map[string]*Job),
 		storageDir:   storageDir,
-		pythonPath:   pythonPath,
+		executor:     &LocalPythonExecutor{PythonPath: pythonPath, StorageDir: storageDir},
 		maxConcurrent: maxConcurrent,
-		semaphore:    make(chan struct{}, maxConcurrent),
+		scheduler:    newFairScheduler(maxConcurrent),
 		ctx:          context.Background(),
+		subscribers:  make(map[chan JobUpdate]struct{}),
+		spool:        newUploadSpool(),
+		failures:     newFailureTracker(),
+		durations:    newDurationTracker(),
+		deletions:    newDeletionQueue(),
+		active:       newActiveIndex(),
+		layout:       storageLayout(DefaultStorageLayout),
 	}
 }
 
-func NewManagerWithPersistence(storageDir, pythonPath string, maxConcurrent int, db *storage.DB, r2 *storage.R2Client) *Manager {
+func NewManagerWithPersistence(storageDir, pythonPath string, maxConcurrent int, db *storage.DB, r2 *storage.GuardedR2Client) *Manager {
 	m := NewManager(storageDir, pythonPath, maxConcurrent)
 	m.db = db
 	m.r2 = r2
 	return m
 }
 
-func (m *Manager) CreateJob(uniprotID string, params map[string]interface{}) (*Job, error) {
+// CreateJobOptions ジョブ作成時のオプション
+type CreateJobOptions struct {
+	// 同じUniProt ID・同じパラメータのジョブがキュー待ち・実行中であれば、新しく作成せずにそのジョブを返す
+	Dedupe bool
+	// 結果キャッシュを使わずに必ず解析を実行する
+	NoCache bool
+	// 再実行元の解析ID（イベントログに記録する）
+	RerunOf string
+	// 作成したHTTPリクエスト・キューのメッセージのID（ログ・イベントに記録する）
+	RequestID string
+}
+
+func (m *Manager) CreateJob(uniprotID string, params AnalysisParams) (*Job, error) {
+	job, _, err := m.CreateJobWithOptions(uniprotID, params, CreateJobOptions{})
+	return job, err
+}
+
+// CreateJobWithOptions 2つ目の戻り値は重複として既存のジョブを返した場合にtrue
+// paramsはParseAnalysisParamsで検証済みのもの（Job.Params・DBにはMapで変換して保存する）
+func (m *Manager) CreateJobWithOptions(uniprotID string, analysisParams AnalysisParams, opts CreateJobOptions) (*Job, bool, error) {
+	if err := validateEnvOverrides(m.envAllowlist, analysisParams.Env); err != nil {
+		return nil, false, err
+	}
+	if err := analysisParams.verifyRoundTrip(); err != nil {
+		return nil, false, err
+	}
+	params := analysisParams.Map()
 	jobID := uuid.New().String()
 	
 	// DBがある場合はローカルディレクトリを作成しない（一時ディレクトリをexecuteJobで使用）
 	// DBがない場合のみ従来通りローカルに保存
+	layout := m.layout
 	if m.db == nil {
-		jobDir := filepath.Join(m.storageDir, jobID)
+		jobDir := layout.LocalDir(m.storageDir, jobID)
 		if err := os.MkdirAll(jobDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create job directory: %w", err)
+			return nil, false, fmt.Errorf("failed to create job directory: %w", err)
 		}
 	}
 
@@ -100,59 +226,102 @@ func (m *Manager) CreateJob(uniprotID string, params map[string]interface{}) (*J
 		Message:   "Job queued",
 		UniProtID: uniprotID,
 		Params:    params,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+		RequestID: opts.RequestID,
+		// 新しい解析の保存形式を記録する（途中で変更されても作成時の形式のまま保存する）
+		storageLayout: layout.Version,
+		settled:       make(chan struct{}),
+	}
+
+	// 結果キャッシュ（同じ条件の完了した解析があれば成果物を再利用する）
+	if !opts.NoCache {
+		if src := m.findCachedResult(uniprotID, params); src != nil {
+			logging.Job(jobID).Debugf("Using cached result of %s for job %s", src.ID, jobID)
+			job.Cached = true
+			job.CachedFrom = src.ID
+			job.cacheSource = src
+			job.Message = "Job queued (cached result)"
+			params["cached_from"] = src.ID
+		}
 	}
 
 	m.mu.Lock()
+	// 重複・上限の確認と登録を同じロック内で行う（同時に作成されても重複・上限超過しないように）
+	if opts.Dedupe {
+		if existing := m.findDuplicateLocked(uniprotID, params); existing != nil {
+			snapshot := existing.snapshot()
+			m.mu.Unlock()
+			if m.db == nil {
+				os.RemoveAll(layout.LocalDir(m.storageDir, jobID))
+			}
+			logging.Debugf("Reusing active job %s for %s (dedupe)", existing.ID, uniprotID)
+			dedupeData := map[string]interface{}{"session_id": params["session_id"]}
+			if opts.RequestID != "" {
+				dedupeData["request_id"] = opts.RequestID
+			}
+			m.recordEvent(existing.ID, JobEvent{Type: EventDeduplicated, Message: "Identical job request was merged into this job", Data: dedupeData})
+			return snapshot, true, nil
+		}
+	}
+	if err := m.checkQuotaLocked(jobSession(job)); err != nil {
+		m.mu.Unlock()
+		if m.db == nil {
+			os.RemoveAll(layout.LocalDir(m.storageDir, jobID))
+		}
+		return nil, false, err
+	}
 	m.jobs[jobID] = job
+	created := job.snapshot()
+	m.active.update(activeEntry(job))
 	m.mu.Unlock()
 
 	// DBに記録（オプショナル）
 	if m.db != nil {
-		// methodパラメータを取得（デフォルトは"X-ray"）
-		method := "X-ray"
-		if methodParam, ok := params["method"].(string); ok && methodParam != "" {
-			method = methodParam
-		} else if xrayOnly, ok := params["xray_only"].(bool); ok {
-			// 後方互換性のため、xray_onlyもサポート
-			if xrayOnly {
-				method = "X-ray"
-			} else {
-				method = "all"
-			}
-		}
-		// セッションIDを取得
-		sessionID := ""
-		if sid, ok := params["session_id"].(string); ok {
-			sessionID = sid
-		}
-
 		record := &storage.AnalysisRecord{
 			ID:        jobID,
 			UniProtID: uniprotID,
-			Method:    method,
+			Method:    analysisParams.Method,
 			Status:    "queued",
 			Params:    params,
 			CreatedAt: job.CreatedAt,
-			SessionID: sessionID,
+			SessionID: analysisParams.SessionID,
 		}
-		if err := m.db.CreateAnalysis(record); err != nil {
-			fmt.Printf("[WARN] Failed to create analysis in DB: %v\n", err)
+		err := m.db.CreateAnalysis(record)
+		m.failures.dependency(DependencyDB, err)
+		if err != nil {
+			logging.Warnf("Failed to create analysis in DB: %v", err)
 			// DBエラーは無視して続行（既存の動作を維持）
 		} else {
+			// アイソフォーム・チェーンを指定した解析は正規の配列の解析と区別できるように記録する
+			if variant := VariantID(uniprotID, analysisParams); variant != uniprotID {
+				if err := m.db.SetAnalysisVariant(jobID, variant); err != nil {
+					logging.Job(jobID).Warnf("Failed to record variant of %s (apply migrations/010_add_variant.sql): %v", jobID, err)
+				}
+			}
+			// 解析を再現できるように、実行するCLIの引数をパラメータと一緒に記録する
+			if err := m.db.SetAnalysisCLIArgs(jobID, analysisParams.CLIArgs()); err != nil {
+				logging.Job(jobID).Warnf("Failed to record CLI args of %s (apply migrations/011_add_cli_args.sql): %v", jobID, err)
+			}
+			// 従来の形式はカラムのデフォルト値のため記録しない
+			if layout.Version != LayoutFlat {
+				if err := m.db.SetAnalysisStorageLayout(jobID, layout.Version); err != nil {
+					logging.Job(jobID).Warnf("Failed to record storage layout of %s (apply migrations/007_add_storage_layout.sql): %v", jobID, err)
+				}
+			}
+			m.notifyChange(jobID, false)
 			// ジョブ数が50個以上の場合、最も古いジョブを1つ削除
 			count, err := m.db.CountAnalyses()
 			if err == nil && count > 50 {
 				oldest, err := m.db.GetOldestAnalysis()
 				if err == nil && oldest != nil {
-					fmt.Printf("[INFO] Job count (%d) exceeds limit (50), deleting oldest job: %s\n", count, oldest.ID)
+					logging.Infof("Job count (%d) exceeds limit (50), deleting oldest job: %s", count, oldest.ID)
 					// 非同期で削除（ジョブ作成をブロックしない）
 					go func() {
 						if err := m.DeleteJob(oldest.ID); err != nil {
-							fmt.Printf("[WARN] Failed to delete oldest job %s: %v\n", oldest.ID, err)
+							logging.Warnf("Failed to delete oldest job %s: %v", oldest.ID, err)
 						} else {
-							fmt.Printf("[INFO] Successfully deleted oldest job: %s\n", oldest.ID)
+							logging.Infof("Successfully deleted oldest job: %s", oldest.ID)
 						}
 					}()
 				}
@@ -160,127 +329,157 @@ func (m *Manager) CreateJob(uniprotID string, params map[string]interface{}) (*J
 		}
 	}
 
+	// 作成をイベントとして記録する（DBのレコード作成後）
+	data := map[string]interface{}{"params": params, "cli_args": analysisParams.CLIArgs()}
+	if created.CachedFrom != "" {
+		data["cached_from"] = created.CachedFrom
+	}
+	if opts.RequestID != "" {
+		data["request_id"] = opts.RequestID
+	}
+	if opts.RerunOf != "" {
+		data["rerun_of"] = opts.RerunOf
+		m.recordEvent(opts.RerunOf, JobEvent{Type: EventRerunRequested, Message: "Re-run requested", Data: map[string]interface{}{"job_id": jobID}})
+	}
+	m.recordEvent(jobID, JobEvent{Type: EventCreated, ToStatus: string(StatusQueued), Message: created.Message, Data: data})
+
+	// タンパク質名等をUniProtから取得して記録する（一覧の表示用）
+	if m.proteins != nil {
+		go m.enrichProtein(jobID, VariantID(uniprotID, AnalysisParams{Isoform: analysisParams.Isoform}))
+	}
+
 	// 非同期でジョブを実行
 	go m.executeJob(job)
 
-	return job, nil
+	// 実行中に更新されるジョブ本体ではなく、登録時点のコピーを返す
+	return created, false, nil
 }
 
+// GetJob ジョブの状態を返す（メモリ上のジョブはその時点のコピー、呼び出し側はロックなしで参照できる）
+// DB・ディスクからの読み込みはロックの外で行い、他のジョブの操作をブロックしない
 func (m *Manager) GetJob(jobID string) (*Job, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	job, exists := m.jobs[jobID]
-	if !exists {
-		// DBから読み込む（DBがある場合）
-		if m.db != nil {
-			record, err := m.db.GetAnalysis(jobID)
-			if err == nil {
-				// DBから取得できた場合、Jobに変換
-				job = &Job{
-					ID:        record.ID,
-					Status:    JobStatus(record.Status),
-					Progress:  0,
-					Message:   "",
-					UniProtID: record.UniProtID,
-					Params:    record.Params,
-					CreatedAt: record.CreatedAt,
-					UpdatedAt: record.CreatedAt,
-				}
-				if record.Progress != nil {
-					job.Progress = *record.Progress
-				}
-				if record.ErrorMessage != nil {
-					job.ErrorMessage = *record.ErrorMessage
-				}
-				if record.FinishedAt != nil {
-					job.UpdatedAt = *record.FinishedAt
-				} else if record.StartedAt != nil {
-					job.UpdatedAt = *record.StartedAt
-				}
-				// 結果URLを設定
-				if record.ResultKey != nil || record.HeatmapKey != nil || record.ScatterKey != nil {
-					job.Result = &JobResult{
-						JSONURL:    fmt.Sprintf("/api/analyses/%s/result.json", jobID),
-						HeatmapURL: fmt.Sprintf("/api/analyses/%s/heatmap.png", jobID),
-						ScatterURL: fmt.Sprintf("/api/analyses/%s/dist_score.png", jobID),
-					}
-				}
-				return job, nil
-			}
+	if exists {
+		snapshot := job.snapshot()
+		m.mu.RUnlock()
+		return snapshot, nil
+	}
+	m.mu.RUnlock()
+
+	// DBから読み込む（DBがある場合）
+	if m.db != nil {
+		record, err := m.db.GetAnalysis(jobID)
+		if err == nil {
+			// DBから取得できた場合、Jobに変換
+			return jobFromRecord(record), nil
 		}
-		// DBがない場合、またはDBから取得できなかった場合はディスクから読み込む（フォールバック）
-		return m.loadJob(jobID)
 	}
-	return job, nil
+	// DBがない場合、またはDBから取得できなかった場合はディスクから読み込む（フォールバック）
+	return m.loadJob(jobID)
+}
+
+// jobFromRecord DBのレコードをJobに変換する
+func jobFromRecord(record *storage.AnalysisRecord) *Job {
+	job := &Job{
+		ID:        record.ID,
+		Status:    JobStatus(record.Status),
+		Progress:  0,
+		Message:   "",
+		UniProtID: record.UniProtID,
+		Params:    record.Params,
+		CreatedAt: record.CreatedAt.UTC(),
+		UpdatedAt: record.CreatedAt.UTC(),
+	}
+	if record.Progress != nil {
+		job.Progress = *record.Progress
+	}
+	if record.ErrorMessage != nil {
+		job.ErrorMessage = *record.ErrorMessage
+	}
+	if record.FinishedAt != nil {
+		job.UpdatedAt = record.FinishedAt.UTC()
+	} else if record.StartedAt != nil {
+		job.UpdatedAt = record.StartedAt.UTC()
+	}
+	// 結果URLを設定
+	if record.ResultKey != nil || record.HeatmapKey != nil || record.ScatterKey != nil {
+		job.Result = &JobResult{
+			JSONURL:    fmt.Sprintf("/api/analyses/%s/result.json", record.ID),
+			HeatmapURL: fmt.Sprintf("/api/analyses/%s/heatmap.png", record.ID),
+			ScatterURL: fmt.Sprintf("/api/analyses/%s/dist_score.png", record.ID),
+		}
+	}
+	return job
 }
 
 func (m *Manager) CancelJob(jobID string) error {
-	fmt.Printf("[DEBUG] CancelJob called for: %s\n", jobID)
+	logging.Job(jobID).Debugf("CancelJob called for: %s", jobID)
 	
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
+	// m.muはジョブの参照・登録の間だけ保持し、ディスクの読み込み・プロセスの終了・DBの更新は他のジョブをブロックしないようにロックの外で行う
+	m.mu.RLock()
 	job, exists := m.jobs[jobID]
+	m.mu.RUnlock()
 	if !exists {
-		fmt.Printf("[DEBUG] Job not found in memory: %s, trying to load from disk\n", jobID)
+		logging.Job(jobID).Debugf("Job not found in memory: %s, trying to load from disk", jobID)
 		// ディスクから読み込む
-		var err error
-		job, err = m.loadJob(jobID)
+		loaded, err := m.loadJob(jobID)
 		if err != nil {
-			fmt.Printf("[ERROR] Failed to load job from disk: %v\n", err)
+			logging.Errorf("Failed to load job from disk: %v", err)
 			return fmt.Errorf("job not found: %w", err)
 		}
-		// メモリに追加（後でステータス更新するため）
-		m.jobs[jobID] = job
+		// メモリに追加（後でステータス更新するため、読み込み中に他の操作で追加された場合はそちらを使う）
+		m.mu.Lock()
+		if existing, ok := m.jobs[jobID]; ok {
+			job = existing
+		} else {
+			m.jobs[jobID] = loaded
+			job = loaded
+		}
+		m.mu.Unlock()
 	}
 
-	fmt.Printf("[DEBUG] Job found: %s, status: %s\n", jobID, job.Status)
+	m.mu.RLock()
+	status := job.Status
+	m.mu.RUnlock()
+	logging.Job(jobID).Debugf("Job found: %s, status: %s", jobID, status)
 
 	// ジョブが実行中またはキュー待ちの場合のみキャンセル可能
-	if job.Status != StatusQueued && job.Status != StatusRunning {
-		fmt.Printf("[WARN] Job %s is not cancellable (status: %s)\n", jobID, job.Status)
-		return fmt.Errorf("job is not cancellable (status: %s)", job.Status)
+	if status != StatusQueued && status != StatusRunning {
+		logging.Job(jobID).Warnf("Job %s is not cancellable (status: %s)", jobID, status)
+		return fmt.Errorf("job is not cancellable (status: %s)", status)
 	}
+	m.recordEvent(jobID, JobEvent{Type: EventCancelRequested, FromStatus: string(status), Message: "Cancellation requested by user"})
 
-	// キャンセル関数を呼び出し
+	// キャンセル関数を呼び出し（ジョブごとのロックのみ保持する）
 	job.mu.Lock()
+	job.cancelRequested = true
 	if job.cancel != nil {
-		fmt.Printf("[DEBUG] Calling cancel function for job: %s\n", jobID)
+		logging.Job(jobID).Debugf("Calling cancel function for job: %s", jobID)
 		job.cancel()
 	} else {
-		fmt.Printf("[WARN] Cancel function is nil for job: %s\n", jobID)
+		logging.Job(jobID).Warnf("Cancel function is nil for job: %s", jobID)
 	}
 	
-	// コマンドプロセスを強制終了
+	// コマンドのプロセスグループを終了（猶予期間を過ぎたら強制終了）
 	if job.cmd != nil {
 		if job.cmd.Process != nil {
-			fmt.Printf("[DEBUG] Killing process for job: %s, PID: %d\n", jobID, job.cmd.Process.Pid)
-			if err := job.cmd.Process.Kill(); err != nil {
-				fmt.Printf("[WARN] Failed to kill process: %v\n", err)
-			} else {
-				fmt.Printf("[DEBUG] Process killed successfully for job: %s\n", jobID)
-			}
+			logging.Job(jobID).Debugf("Terminating process group for job: %s, PID: %d", jobID, job.cmd.Process.Pid)
+			terminateProcessGroup(job.cmd.Process.Pid)
 		} else {
-			fmt.Printf("[WARN] Process is nil for job: %s\n", jobID)
+			logging.Job(jobID).Warnf("Process is nil for job: %s", jobID)
 		}
 	} else {
-		fmt.Printf("[WARN] Command is nil for job: %s\n", jobID)
+		logging.Job(jobID).Warnf("Command is nil for job: %s", jobID)
 		// プロセスIDをファイルから読み込んで強制終了を試みる（DBがない場合のみ）
 		if m.db == nil {
-			jobDir := filepath.Join(m.storageDir, jobID)
+			jobDir := m.localJobDir(jobID)
 			pidFile := filepath.Join(jobDir, "pid.txt")
 			if pidData, err := os.ReadFile(pidFile); err == nil {
 			var pid int
 			if _, err := fmt.Sscanf(string(pidData), "%d", &pid); err == nil {
-				fmt.Printf("[DEBUG] Found PID file, attempting to kill process: %d\n", pid)
-				if proc, err := os.FindProcess(pid); err == nil {
-					if err := proc.Kill(); err != nil {
-						fmt.Printf("[WARN] Failed to kill process from PID file: %v\n", err)
-					} else {
-						fmt.Printf("[DEBUG] Process killed from PID file: %d\n", pid)
-					}
-				}
+				logging.Debugf("Found PID file, attempting to terminate process group: %d", pid)
+				terminateProcessGroup(pid)
 			}
 			}
 		}
@@ -288,143 +487,174 @@ func (m *Manager) CancelJob(jobID string) error {
 	job.mu.Unlock()
 
 	// ステータスを更新
-	fmt.Printf("[DEBUG] Updating job status to cancelled: %s\n", jobID)
+	logging.Job(jobID).Debugf("Updating job status to cancelled: %s", jobID)
 	m.updateJobStatus(job, StatusCancelled, 0, "Analysis cancelled by user")
 
 	// DBを更新（オプショナル）
 	if m.db != nil {
-		fmt.Printf("[DEBUG] Updating DB status to cancelled: %s\n", jobID)
+		logging.Job(jobID).Debugf("Updating DB status to cancelled: %s", jobID)
 		if err := m.db.UpdateAnalysisStatus(jobID, string(StatusCancelled), nil, "Analysis cancelled by user", nil); err != nil {
-			fmt.Printf("[ERROR] Failed to update analysis status in DB: %v\n", err)
+			logging.Errorf("Failed to update analysis status in DB: %v", err)
 			return fmt.Errorf("failed to update database: %w", err)
 		}
-		fmt.Printf("[DEBUG] DB status updated successfully: %s\n", jobID)
+		logging.Job(jobID).Debugf("DB status updated successfully: %s", jobID)
 	} else {
-		fmt.Printf("[DEBUG] DB not configured, skipping DB update\n")
+		logging.Debugf("DB not configured, skipping DB update")
 	}
 
-	fmt.Printf("[DEBUG] CancelJob completed successfully for: %s\n", jobID)
+	logging.Job(jobID).Debugf("CancelJob completed successfully for: %s", jobID)
 	return nil
 }
 
+// WaitSettled ジョブの実行が後処理（チェックポイント・ログの保存、DBの更新）を含めて終わるまで待つ
+// キャンセル直後に最終的な状態を返すためのもので、メモリ上にない・このサーバーで実行していないジョブはすぐに戻る
+func (m *Manager) WaitSettled(ctx context.Context, jobID string) error {
+	m.mu.RLock()
+	job, exists := m.jobs[jobID]
+	m.mu.RUnlock()
+	if !exists || job.settled == nil {
+		return nil
+	}
+	select {
+	case <-job.settled:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (m *Manager) DeleteJob(jobID string) error {
-	fmt.Printf("[DEBUG] DeleteJob called for: %s\n", jobID)
+	logging.Job(jobID).Debugf("DeleteJob called for: %s", jobID)
 	
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	// 保存形式はDBのレコード・メモリ上のジョブを削除する前に確認する（後片付けで使う）
+	layout := m.layoutOf(jobID)
 
+	// メモリから取り除く（ロックはここだけで、プロセスの終了・R2・DBの呼び出しは他のジョブ操作をブロックしない）
+	m.mu.Lock()
 	job, exists := m.jobs[jobID]
+	var status JobStatus
 	if exists {
-		fmt.Printf("[DEBUG] Job found in memory: %s, status: %s\n", jobID, job.Status)
+		status = job.Status
+		delete(m.jobs, jobID)
+		m.active.remove(jobID)
+	}
+	m.mu.Unlock()
+
+	if exists {
+		logging.Job(jobID).Debugf("Job found in memory: %s, status: %s", jobID, status)
 		// 実行中のジョブをキャンセル
-		if job.Status == StatusRunning || job.Status == StatusQueued {
+		if status == StatusRunning || status == StatusQueued {
 			job.mu.Lock()
+			job.cancelRequested = true
 			if job.cancel != nil {
 				job.cancel()
-				fmt.Printf("[DEBUG] Context cancel function called for job: %s\n", jobID)
+				logging.Job(jobID).Debugf("Context cancel function called for job: %s", jobID)
 			}
 			if job.cmd != nil && job.cmd.Process != nil {
-				if err := job.cmd.Process.Kill(); err != nil {
-					fmt.Printf("[WARN] Failed to kill process %d for job %s: %v\n", job.cmd.Process.Pid, jobID, err)
-				} else {
-					fmt.Printf("[DEBUG] Killed process %d for job: %s\n", job.cmd.Process.Pid, jobID)
-				}
+				logging.Job(jobID).Debugf("Terminating process group %d for job: %s", job.cmd.Process.Pid, jobID)
+				terminateProcessGroup(job.cmd.Process.Pid)
 			} else {
-				fmt.Printf("[WARN] Process is nil for job: %s\n", jobID)
+				logging.Job(jobID).Warnf("Process is nil for job: %s", jobID)
 			}
 			job.mu.Unlock()
 		}
-		delete(m.jobs, jobID)
-		fmt.Printf("[DEBUG] Job removed from memory: %s\n", jobID)
+		logging.Job(jobID).Debugf("Job removed from memory: %s", jobID)
 	} else {
-		fmt.Printf("[DEBUG] Job not found in memory: %s (may be on disk only)\n", jobID)
+		logging.Job(jobID).Debugf("Job not found in memory: %s (may be on disk only)", jobID)
 		// メモリにない場合でも、実行中の可能性があるのでPIDファイルからプロセスを終了（DBがない場合のみ）
 		if m.db == nil {
-			jobDir := filepath.Join(m.storageDir, jobID)
+			jobDir := m.localJobDir(jobID)
 			pidFile := filepath.Join(jobDir, "pid.txt")
 			if pidData, err := os.ReadFile(pidFile); err == nil {
 			var pid int
 			if _, err := fmt.Sscanf(string(pidData), "%d", &pid); err == nil {
-				fmt.Printf("[DEBUG] Found PID file for job %s, attempting to kill process: %d\n", jobID, pid)
-				if proc, err := os.FindProcess(pid); err == nil {
-					if err := proc.Kill(); err != nil {
-						fmt.Printf("[WARN] Failed to kill process %d from PID file for job %s: %v\n", pid, jobID, err)
-					} else {
-						fmt.Printf("[DEBUG] Process killed from PID file: %d for job: %s\n", pid, jobID)
-					}
-				} else {
-					fmt.Printf("[WARN] Failed to find process %d from PID file for job %s: %v\n", pid, jobID, err)
-				}
+				logging.Job(jobID).Debugf("Found PID file for job %s, attempting to terminate process group: %d", jobID, pid)
+				terminateProcessGroup(pid)
 			} else {
-				fmt.Printf("[WARN] Failed to parse PID from file %s for job %s: %v\n", pidFile, jobID, err)
+				logging.Job(jobID).Warnf("Failed to parse PID from file %s for job %s: %v", pidFile, jobID, err)
 			}
 		} else if !os.IsNotExist(err) {
-			fmt.Printf("[WARN] Failed to read PID file %s for job %s: %v\n", pidFile, jobID, err)
+			logging.Job(jobID).Warnf("Failed to read PID file %s for job %s: %v", pidFile, jobID, err)
 		}
 		}
 	}
 
-	// ストレージディレクトリを削除（DBがない場合のみ）
-	if m.db == nil {
-		jobDir := filepath.Join(m.storageDir, jobID)
-		fmt.Printf("[DEBUG] Attempting to delete storage directory: %s\n", jobDir)
-		if err := os.RemoveAll(jobDir); err != nil {
-			fmt.Printf("[WARN] Failed to delete job directory: %v\n", err)
-		} else {
-			fmt.Printf("[DEBUG] Storage directory deleted: %s\n", jobDir)
-		}
-	} else {
-		fmt.Printf("[DEBUG] DB configured, skipping local directory deletion (temp directory already removed)\n")
-	}
-
-	// R2から削除（オプショナル）
-	// DBからR2キーを取得して削除を試みる
+	// アップロード待ちのスプールを削除（削除後に再アップロードされないように）
 	if m.r2 != nil {
-		r2Prefix := fmt.Sprintf("analysis/%s/", jobID)
-		fmt.Printf("[DEBUG] Attempting to delete objects from R2 with prefix: %s\n", r2Prefix)
-		if err := m.r2.DeleteObjectsWithPrefix(context.Background(), r2Prefix); err != nil {
-			fmt.Printf("[ERROR] Failed to delete objects from R2 for %s: %v\n", jobID, err)
-			// R2削除エラーは警告のみ（DB削除は続行）
-		} else {
-			fmt.Printf("[DEBUG] Successfully deleted objects from R2: %s\n", r2Prefix)
-		}
+		m.forgetSpoolEntry(jobID)
 	} else if m.db != nil {
 		// R2が設定されていない場合でも、DBからR2キーを確認してログ出力
 		record, err := m.db.GetAnalysis(jobID)
 		if err == nil {
 			if record.ResultKey != nil || record.HeatmapKey != nil || record.ScatterKey != nil {
-				fmt.Printf("[WARN] R2 keys found in DB for %s but R2 is not configured. R2 objects will not be deleted.\n", jobID)
+				logging.Job(jobID).Warnf("R2 keys found in DB for %s but R2 is not configured. R2 objects will not be deleted.", jobID)
 			}
 		}
 	}
 
 	// DBから削除（オプショナル）
 	if m.db != nil {
-		fmt.Printf("[DEBUG] Attempting to delete from DB: %s\n", jobID)
+		logging.Job(jobID).Debugf("Attempting to delete from DB: %s", jobID)
 		if err := m.db.DeleteAnalysis(jobID); err != nil {
-			fmt.Printf("[ERROR] Failed to delete analysis from DB: %v\n", err)
+			logging.Errorf("Failed to delete analysis from DB: %v", err)
 			return fmt.Errorf("failed to delete from database: %w", err)
 		}
-		fmt.Printf("[DEBUG] Analysis deleted from DB: %s\n", jobID)
+		logging.Job(jobID).Debugf("Analysis deleted from DB: %s", jobID)
 	} else {
-		fmt.Printf("[DEBUG] DB not configured, skipping DB deletion\n")
+		logging.Debugf("DB not configured, skipping DB deletion")
 	}
+	m.notifyChange(jobID, true)
 
-	fmt.Printf("[DEBUG] DeleteJob completed successfully for: %s\n", jobID)
+	// R2のオブジェクト・ローカルのジョブディレクトリ（DBがない場合のみ）はバックグラウンドで削除する（失敗した場合は再試行）
+	m.scheduleStorageCleanup(jobID, layout)
+
+	logging.Job(jobID).Debugf("DeleteJob completed successfully for: %s", jobID)
 	return nil
 }
 
 func (m *Manager) executeJob(job *Job) {
-	// セマフォで並列実行数を制限
-	m.semaphore <- struct{}{}
-	defer func() { <-m.semaphore }()
-
-	// キャンセル可能なコンテキストを作成
+	// 後処理（チェックポイント・ログの保存、作業ディレクトリの削除）を含めて終わったことを通知する（最初に登録して最後に実行する）
+	defer close(job.settled)
+	// キャンセル可能なコンテキストを作成（キュー待ちの間もキャンセルできるように先に設定）
 	jobCtx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
 	job.mu.Lock()
 	job.cancel = cancel
+	if job.cancelRequested {
+		// 実行を開始する前にキャンセル・削除された場合
+		cancel()
+	}
 	job.mu.Unlock()
 
+	// アップロード待ちがスプール上限近くまで溜まっている場合はアップロードを優先する（待機中のキャンセル・削除はキュー待ちと同じく終了する）
+	if err := m.waitForSpoolCapacity(jobCtx); err != nil {
+		job.logger().Debugf("Job %s cancelled while waiting for upload spool capacity", job.ID)
+		return
+	}
+
+	// 実行枠をセッション間で公平に割り当てて並列実行数を制限（キャッシュから復元する場合は実行枠を使わない）
+	if job.cacheSource == nil {
+		release, err := m.scheduler.acquire(jobCtx, jobSession(job), jobClass(job))
+		if err == nil && jobCtx.Err() != nil {
+			// 割り当てと同時にキャンセルされた場合
+			release()
+			err = jobCtx.Err()
+		}
+		if err != nil {
+			job.logger().Debugf("Job %s cancelled while queued", job.ID)
+			return
+		}
+		defer release()
+	}
+
+	// タイムアウトを設定（超過した場合はキャンセルと同じ仕組みでプロセスを終了する）
+	timeout := m.jobTimeout(job)
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		jobCtx, cancelTimeout = context.WithTimeout(jobCtx, timeout)
+		defer cancelTimeout()
+	}
+
 	m.updateJobStatus(job, StatusRunning, 10, "Starting analysis...")
 
 	// 一時ディレクトリを作成（DBがある場合）
@@ -432,9 +662,9 @@ func (m *Manager) executeJob(job *Job) {
 	var cleanupDir bool
 	if m.db != nil {
 		// 一時ディレクトリを使用
-		tempDir, err := os.MkdirTemp("", fmt.Sprintf("dsa-job-%s-", job.ID))
+		tempDir, err := m.newJobTempDir(job.ID)
 		if err != nil {
-			m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("Failed to create temp directory: %v", err))
+			m.failJob(job, FailureInternal, fmt.Sprintf("Failed to create temp directory: %v", err))
 			return
 		}
 		jobDir = tempDir
@@ -443,248 +673,104 @@ func (m *Manager) executeJob(job *Job) {
 		defer func() {
 			if cleanupDir {
 				if err := os.RemoveAll(jobDir); err != nil {
-					fmt.Printf("[WARN] Failed to remove temp directory %s: %v\n", jobDir, err)
+					job.logger().Warnf("Failed to remove temp directory %s: %v", jobDir, err)
 				} else {
-					fmt.Printf("[DEBUG] Temp directory removed: %s\n", jobDir)
+					job.logger().Debugf("Temp directory removed: %s", jobDir)
 				}
 			}
 		}()
 	} else {
-		// DBがない場合は従来通り
-		jobDir = filepath.Join(m.storageDir, job.ID)
+		// DBがない場合は保存形式で決まるジョブディレクトリに直接出力する
+		jobDir = m.localDirOf(job)
 	}
 	
-	// デバッグ: ストレージディレクトリ情報
-	fmt.Printf("[DEBUG] Manager storageDir: %s\n", m.storageDir)
-	fmt.Printf("[DEBUG] JobDir: %s\n", jobDir)
-
-	// Python CLIコマンドを構築（キャンセル可能なコンテキストを使用）
-	cmd := exec.CommandContext(jobCtx, m.pythonPath, "-m", "dsa_cli", "run",
-		"--uniprot", job.UniProtID,
-		"--out", jobDir,
-		"--sequence-ratio", fmt.Sprintf("%v", job.Params["sequence_ratio"]),
-		"--min-structures", fmt.Sprintf("%v", job.Params["min_structures"]),
-	)
-	
-	// ジョブにコマンドを保存（キャンセル時に使用）
 	job.mu.Lock()
-	job.cmd = cmd
+	job.dir = jobDir
 	job.mu.Unlock()
-
-	// methodパラメータを取得（デフォルトは"X-ray"）
-	method := "X-ray"
-	fmt.Printf("[DEBUG] job.Params[\"method\"] = %v (type: %T)\n", job.Params["method"], job.Params["method"])
-	if methodParam, ok := job.Params["method"].(string); ok {
-		fmt.Printf("[DEBUG] methodParam = %q\n", methodParam)
-		if methodParam != "" {
-			if methodParam == "all" {
-				method = "" // "all"は空文字列に変換（Python CLIのchoicesに合わせる）
-				fmt.Printf("[DEBUG] Converting 'all' to empty string\n")
-			} else {
-				method = methodParam
-			}
+	// 完了しなかった場合もlogs.txtを残す（一時ディレクトリの削除より先に実行される）
+	defer func() {
+		m.mu.RLock()
+		status := job.Status
+		m.mu.RUnlock()
+		if status != StatusDone {
+			m.saveFailureLogs(job, jobDir)
 		}
-	} else if xrayOnly, ok := job.Params["xray_only"].(bool); ok {
-		// 後方互換性のため、xray_onlyもサポート
-		fmt.Printf("[DEBUG] Using xray_only parameter: %v\n", xrayOnly)
-		if xrayOnly {
-			method = "X-ray"
-		} else {
-			method = "" // 空文字列で全メソッド
-		}
-	}
-	// methodが空文字列の場合でも--methodを追加（Python CLIのchoicesに""が含まれているため）
-	fmt.Printf("[DEBUG] Final method value: %q\n", method)
-	cmd.Args = append(cmd.Args, "--method", method)
-	fmt.Printf("[DEBUG] Command args after method: %v\n", cmd.Args)
-
-	if negativePDB, ok := job.Params["negative_pdbid"].(string); ok && negativePDB != "" {
-		cmd.Args = append(cmd.Args, "--negative-pdbid", negativePDB)
-	}
-
-	if cisThreshold, ok := job.Params["cis_threshold"].(float64); ok {
-		cmd.Args = append(cmd.Args, "--cis-threshold", fmt.Sprintf("%.1f", cisThreshold))
-	}
-
-	if procCis, ok := job.Params["proc_cis"].(bool); ok && procCis {
-		cmd.Args = append(cmd.Args, "--proc-cis")
-	}
+	}()
 
-	// 作業ディレクトリを設定（Pythonモジュールのルート）
-	// storageDirから見て、親ディレクトリのpythonディレクトリを探す
-	storageAbs, err := filepath.Abs(m.storageDir)
-	if err != nil {
-		m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("Failed to resolve storage path: %v", err))
-		return
-	}
-	
-	// デバッグ: パス情報をログ出力
-	fmt.Printf("[DEBUG] storageDir: %s\n", m.storageDir)
-	fmt.Printf("[DEBUG] storageAbs: %s\n", storageAbs)
-	
-	// storageDirがbackend/storageの場合、backendの親（okada）からpythonを探す
-	// まず、storageの親（backend）を取得
-	parentDir := filepath.Dir(storageAbs)
-	// 次に、backendの親（okada）を取得
-	rootDir := filepath.Dir(parentDir)
-	// okada/pythonを探す
-	pythonDir := filepath.Join(rootDir, "python")
-	
-	fmt.Printf("[DEBUG] parentDir: %s\n", parentDir)
-	fmt.Printf("[DEBUG] rootDir: %s\n", rootDir)
-	fmt.Printf("[DEBUG] pythonDir (first try): %s\n", pythonDir)
-	
-	// Pythonディレクトリの存在確認
-	if _, err := os.Stat(pythonDir); os.IsNotExist(err) {
-		fmt.Printf("[DEBUG] First pythonDir not found, trying alternative...\n")
-		// もし見つからなければ、storageの親から直接探す（storageがokada直下にある場合）
-		altPythonDir := filepath.Join(parentDir, "python")
-		fmt.Printf("[DEBUG] pythonDir (alternative): %s\n", altPythonDir)
-		if _, err := os.Stat(altPythonDir); os.IsNotExist(err) {
-			// さらに、環境変数で指定されたパスを試す
-			if envPythonDir := os.Getenv("PYTHON_DIR"); envPythonDir != "" {
-				envPythonDir, _ = filepath.Abs(envPythonDir)
-				fmt.Printf("[DEBUG] pythonDir (from env PYTHON_DIR): %s\n", envPythonDir)
-				if _, err := os.Stat(envPythonDir); err == nil {
-					pythonDir = envPythonDir
-				} else {
-					errorMsg := fmt.Sprintf("Python directory not found. Tried:\n1. %s\n2. %s\n3. %s (from env)\nStorage: %s", pythonDir, altPythonDir, envPythonDir, storageAbs)
-					fmt.Printf("[DEBUG] %s\n", errorMsg)
-					m.updateJobStatus(job, StatusFailed, 0, errorMsg)
-					return
-				}
-			} else {
-				errorMsg := fmt.Sprintf("Python directory not found. Tried:\n1. %s\n2. %s\nStorage: %s\nHint: Set PYTHON_DIR environment variable", pythonDir, altPythonDir, storageAbs)
-				fmt.Printf("[DEBUG] %s\n", errorMsg)
-				m.updateJobStatus(job, StatusFailed, 0, errorMsg)
-				return
-			}
-		} else {
-			pythonDir = altPythonDir
+	// デバッグ: ストレージディレクトリ情報
+	job.logger().Debugf("Manager storageDir: %s", m.storageDir)
+	job.logger().Debugf("JobDir: %s", jobDir)
+
+	// 解析を実行（進捗はジョブ全体の20%〜60%に割り当てる）
+	var err error
+	if job.cacheSource != nil {
+		m.updateJobStatus(job, StatusRunning, scalePythonProgress(0), fmt.Sprintf("Restoring cached result of %s...", job.cacheSource.ID))
+		err = m.restoreCachedResult(jobCtx, job.cacheSource, jobDir)
+	} else {
+		if resumeSource(job) != "" {
+			resumeDir := m.prepareResume(jobCtx, job, jobDir)
+			job.mu.Lock()
+			job.resumeDir = resumeDir
+			job.mu.Unlock()
 		}
+		job.logger().Debugf("Running job %s with executor: %s", job.ID, m.executor.Name())
+		err = m.executor.Run(jobCtx, job, jobDir, func(percent int, message string) {
+			m.updateJobStatus(job, StatusRunning, scalePythonProgress(percent), message)
+		})
+		// 失敗・キャンセルした場合も再実行で再開できるように作業ディレクトリを保存する
+		m.saveCheckpoint(job, jobDir)
 	}
-	
-	fmt.Printf("[DEBUG] Using pythonDir: %s\n", pythonDir)
-	
-	// Pythonディレクトリの最終確認
-	if _, err := os.Stat(pythonDir); os.IsNotExist(err) {
-		m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("Python directory does not exist: %s", pythonDir))
-		return
-	}
-	
-	// dsa_cli.pyの存在確認
-	dsaCliPath := filepath.Join(pythonDir, "dsa_cli.py")
-	if _, err := os.Stat(dsaCliPath); os.IsNotExist(err) {
-		m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("dsa_cli.py not found in: %s", pythonDir))
-		return
-	}
-	fmt.Printf("[DEBUG] dsa_cli.py found at: %s\n", dsaCliPath)
-	
-	cmd.Dir = pythonDir
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "PYTHONPATH="+pythonDir)
-	
-	fmt.Printf("[DEBUG] Command directory: %s\n", cmd.Dir)
-	fmt.Printf("[DEBUG] Command: %s %v\n", cmd.Path, cmd.Args)
-	
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-
-	m.updateJobStatus(job, StatusRunning, 20, "Running Python analysis...")
-
-	// コマンドを開始してプロセスIDを取得
-	if err := cmd.Start(); err != nil {
-		m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("Failed to start command: %v", err))
-		return
-	}
-
-	// プロセスIDをファイルに保存（後で強制終了するため）
-	pidFile := filepath.Join(jobDir, "pid.txt")
-	if cmd.Process != nil {
-		pid := cmd.Process.Pid
-		if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", pid)), 0644); err != nil {
-			fmt.Printf("[WARN] Failed to save PID file: %v\n", err)
-		} else {
-			fmt.Printf("[DEBUG] Saved PID %d to %s\n", pid, pidFile)
+	if err != nil {
+		// タイムアウトした場合は失敗として扱う
+		if jobCtx.Err() == context.DeadlineExceeded {
+			job.logger().Warnf("Job timed out: %s (timeout: %s)", job.ID, timeout)
+			m.failJob(job, FailureTimeout, timeoutMessage(timeout))
+			return
 		}
-	}
 
-	// コマンド実行（キャンセルされた場合はcontext.Canceledエラーが返る）
-	if err := cmd.Wait(); err != nil {
 		// キャンセルされた場合は特別に処理
 		if jobCtx.Err() == context.Canceled {
-			fmt.Printf("[DEBUG] Job cancelled: %s\n", job.ID)
+			job.logger().Debugf("Job cancelled: %s", job.ID)
 			m.updateJobStatus(job, StatusCancelled, 0, "Analysis cancelled by user")
-			// PIDファイルを削除
-			if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
-				fmt.Printf("[WARN] Failed to remove PID file: %v\n", err)
-			}
 			return
 		}
-		
-		fmt.Printf("[ERROR] Command execution failed for job %s: %v\n", job.ID, err)
-		
-		// もし result.json が生成されていれば、その中のエラー内容を優先してユーザーに伝える
-		resultPath := filepath.Join(jobDir, "result.json")
-		errorMessage := fmt.Sprintf("Analysis failed: %v", err)
-
-		if data, readErr := os.ReadFile(resultPath); readErr == nil {
-			var res map[string]interface{}
-			if jsonErr := json.Unmarshal(data, &res); jsonErr == nil {
-				// errorフィールドを確認
-				if msg, ok := res["error"].(string); ok && msg != "" {
-					errorMessage = msg
-					fmt.Printf("[ERROR] Analysis failed with error from result.json: %s\n", msg)
-				} else if status, ok := res["status"].(string); ok && status == "failed" {
-					// statusがfailedの場合も確認
-					if msg, ok := res["error"].(string); ok && msg != "" {
-						errorMessage = msg
-						fmt.Printf("[ERROR] Analysis failed with error from result.json: %s\n", msg)
-					} else {
-						fmt.Printf("[WARN] result.json has status='failed' but no error message\n")
-					}
-				} else {
-					fmt.Printf("[WARN] result.json exists but contains no error information. Content: %+v\n", res)
-				}
-			} else {
-				fmt.Printf("[WARN] Failed to parse result.json: %v\n", jsonErr)
-				if len(data) > 500 {
-					fmt.Printf("[DEBUG] result.json content (first 500 chars): %s\n", string(data[:500]))
-				} else {
-					fmt.Printf("[DEBUG] result.json content: %s\n", string(data))
-				}
-			}
-		} else {
-			fmt.Printf("[WARN] result.json not found or unreadable at %s: %v\n", resultPath, readErr)
+
+		// 起動前の失敗（環境の不備など）はそのままユーザーに伝える
+		errorMessage := err.Error()
+		category := FailureInternal
+		var execErr *ExecutionError
+		if errors.As(err, &execErr) {
+			job.logger().Errorf("Command execution failed for job %s: %v", job.ID, err)
+			errorMessage = executionFailureMessage(job, jobDir, execErr)
+			category = resultFailureCategory(jobDir, FailureAnalysis)
 		}
 
 		// エラーメッセージをログに出力してから、ジョブステータスを更新
-		fmt.Printf("[ERROR] Job %s failed: %s\n", job.ID, errorMessage)
-		m.updateJobStatus(job, StatusFailed, 0, errorMessage)
+		job.logger().Errorf("Job %s failed: %s", job.ID, errorMessage)
+		m.failJob(job, category, errorMessage)
 		return
 	}
-	fmt.Printf("[DEBUG] Command executed successfully\n")
 
 	// Python処理完了後の進捗更新
-	m.updateJobStatus(job, StatusRunning, 60, "Processing result files...")
+	m.updateJobStatus(job, StatusRunning, pythonProgressEnd, "Processing result files...")
 
 	// 結果ファイルの存在確認
 	resultPath := filepath.Join(jobDir, "result.json")
 	if _, err := os.Stat(resultPath); os.IsNotExist(err) {
-		m.updateJobStatus(job, StatusFailed, 0, "Result file not found")
+		m.failJob(job, FailureInternal, "Result file not found")
 		return
 	}
 
 	// result.jsonを読み込んでエラーチェック
 	resultData, err := os.ReadFile(resultPath)
 	if err != nil {
-		m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("Failed to read result: %v", err))
+		m.failJob(job, FailureInternal, fmt.Sprintf("Failed to read result: %v", err))
 		return
 	}
 
 	var result map[string]interface{}
 	if err := json.Unmarshal(resultData, &result); err != nil {
-		m.updateJobStatus(job, StatusFailed, 0, fmt.Sprintf("Failed to parse result: %v", err))
+		m.failJob(job, FailureInternal, fmt.Sprintf("Failed to parse result: %v", err))
 		return
 	}
 
@@ -696,105 +782,149 @@ func (m *Manager) executeJob(job *Job) {
 		if errMsg, ok := result["error"].(string); ok {
 			errorMsg = errMsg
 		}
-		m.updateJobStatus(job, StatusFailed, 0, errorMsg)
+		m.failJob(job, resultFailureCategory(jobDir, FailureAnalysis), errorMsg)
 		return
 	}
+	// キャッシュから復元した場合はUniProt・PDBに接続していないため成功として数えない
+	if !job.Cached {
+		m.failures.jobSucceeded()
+	}
+	m.recordDuration(job)
 
 	// 結果URLを設定
+	m.mu.Lock()
 	job.Result = &JobResult{
 		JSONURL:    fmt.Sprintf("/api/jobs/%s/result.json", job.ID),
 		HeatmapURL: fmt.Sprintf("/api/jobs/%s/heatmap.png", job.ID),
 		ScatterURL: fmt.Sprintf("/api/jobs/%s/dist_score.png", job.ID),
 	}
+	m.mu.Unlock()
 
 	// メトリクスを抽出
 	metrics := m.extractMetrics(result)
 
 	// R2にアップロード（オプショナル）
-	var r2Prefix, resultKey, heatmapKey, scatterKey, logsKey string
+	// 成果物をスプールへ退避し、アップロードは専用ワーカーに任せてPython実行枠を解放する
+	// ジョブはアップロード完了後にワーカーが完了状態にする
+	layout := storageLayout(job.storageLayout)
+	uploaded := false
 	if m.r2 != nil {
-		if err := m.uploadToR2(job, jobDir, result); err != nil {
-			fmt.Printf("[WARN] Failed to upload to R2: %v\n", err)
-			// R2エラーは無視して続行
+		entryDir, err := m.spoolOutputs(job.ID, jobDir, layout, metrics)
+		if err == nil {
+			m.updateJobStatus(job, StatusRunning, 90, "Uploading artifacts...")
+			m.enqueueUpload(entryDir)
+			m.finishJob(job, jobDir)
+			return
+		}
+		job.logger().Warnf("Failed to spool outputs for %s: %v", job.ID, err)
+		// スプールできない場合は作業ディレクトリから直接アップロード
+		if err := m.uploadToR2(layout, job.ID, jobDir); err != nil {
+			job.logger().Warnf("Failed to upload to R2: %v", err)
 		} else {
-			// アップロード成功時のみキーを設定
-			r2Prefix = fmt.Sprintf("analysis/%s", job.ID)
-			resultKey = fmt.Sprintf("%s/result.json", r2Prefix)
-			heatmapKey = fmt.Sprintf("%s/heatmap.png", r2Prefix)
-			scatterKey = fmt.Sprintf("%s/dist_score.png", r2Prefix)
-			// logs.txtは存在する場合のみ
-			logsPath := filepath.Join(jobDir, "logs.txt")
-			if _, err := os.Stat(logsPath); err == nil {
-				logsKey = fmt.Sprintf("%s/logs.txt", r2Prefix)
-			}
+			uploaded = true
 		}
 	}
 
 	// DBを更新（オプショナル、R2の成否に関わらず実行）
 	if m.db != nil {
+		var r2Prefix, resultKey, heatmapKey, scatterKey, logsKey string
+		if uploaded {
+			// アップロード成功時のみキーを設定
+			r2Prefix, resultKey, heatmapKey, scatterKey, logsKey = artifactKeys(layout, job.ID, jobDir)
+		}
 		if err := m.db.CompleteAnalysis(job.ID, metrics, r2Prefix, resultKey, heatmapKey, scatterKey, logsKey); err != nil {
-			fmt.Printf("[WARN] Failed to update analysis in DB: %v\n", err)
+			job.logger().Warnf("Failed to update analysis in DB: %v", err)
 			// DBエラーは無視して続行（既存の動作を維持）
 		}
 	}
 
 	m.updateJobStatus(job, StatusDone, 100, "Analysis completed successfully")
-	
+	m.finishJob(job, jobDir)
+}
+
+// executionFailureMessage 解析の失敗理由を返す
+// もし result.json が生成されていれば、その中のエラー内容を優先してユーザーに伝える
+func executionFailureMessage(job *Job, jobDir string, err error) string {
+	resultPath := filepath.Join(jobDir, "result.json")
+	errorMessage := fmt.Sprintf("Analysis failed: %v", err)
+
+	if data, readErr := os.ReadFile(resultPath); readErr == nil {
+		var res map[string]interface{}
+		if jsonErr := json.Unmarshal(data, &res); jsonErr == nil {
+			// errorフィールドを確認
+			if msg, ok := res["error"].(string); ok && msg != "" {
+				errorMessage = msg
+				job.logger().Errorf("Analysis failed with error from result.json: %s", msg)
+			} else if status, ok := res["status"].(string); ok && status == "failed" {
+				// statusがfailedの場合も確認
+				if msg, ok := res["error"].(string); ok && msg != "" {
+					errorMessage = msg
+					job.logger().Errorf("Analysis failed with error from result.json: %s", msg)
+				} else {
+					job.logger().Warnf("result.json has status='failed' but no error message")
+				}
+			} else {
+				job.logger().Warnf("result.json exists but contains no error information. Content: %+v", res)
+			}
+		} else {
+			job.logger().Warnf("Failed to parse result.json: %v", jsonErr)
+			if len(data) > 500 {
+				job.logger().Debugf("result.json content (first 500 chars): %s", string(data[:500]))
+			} else {
+				job.logger().Debugf("result.json content: %s", string(data))
+			}
+		}
+	} else {
+		job.logger().Warnf("result.json not found or unreadable at %s: %v", resultPath, readErr)
+	}
+
+	return errorMessage
+}
+
+// finishJob 正常終了したジョブの後処理
+func (m *Manager) finishJob(job *Job, jobDir string) {
 	// PIDファイルを削除
-	pidFile = filepath.Join(jobDir, "pid.txt")
+	pidFile := filepath.Join(jobDir, "pid.txt")
 	if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
-		fmt.Printf("[WARN] Failed to remove PID file: %v\n", err)
+		job.logger().Warnf("Failed to remove PID file: %v", err)
 	}
 
 	// DBがある場合、一時ディレクトリはdeferで自動削除される
 	// DBがない場合は従来通りローカルファイルを保持
 	if m.db == nil {
-		fmt.Printf("[DEBUG] DB not configured, keeping local files in: %s\n", jobDir)
+		job.logger().Debugf("DB not configured, keeping local files in: %s", jobDir)
 	}
 }
 
-func (m *Manager) uploadToR2(job *Job, jobDir string, result map[string]interface{}) error {
-	r2Prefix := fmt.Sprintf("analysis/%s", job.ID)
-
-	// result.jsonをアップロード
-	resultPath := filepath.Join(jobDir, "result.json")
-	resultData, err := os.ReadFile(resultPath)
-	if err != nil {
-		return fmt.Errorf("failed to read result.json: %w", err)
-	}
-	resultKey := fmt.Sprintf("%s/result.json", r2Prefix)
-	if err := m.r2.PutObject(m.ctx, resultKey, resultData, "application/json"); err != nil {
-		return fmt.Errorf("failed to upload result.json: %w", err)
-	}
-
-	// heatmap.pngをアップロード
-	heatmapPath := filepath.Join(jobDir, "heatmap.png")
-	heatmapKey := fmt.Sprintf("%s/heatmap.png", r2Prefix)
-	if data, err := os.ReadFile(heatmapPath); err == nil {
-		if err := m.r2.PutObject(m.ctx, heatmapKey, data, "image/png"); err != nil {
-			return fmt.Errorf("failed to upload heatmap.png: %w", err)
+// uploadToR2 登録されている成果物を解析の保存形式のキーでアップロードする
+func (m *Manager) uploadToR2(layout *StorageLayout, jobID, jobDir string) error {
+	// 登録されている成果物をアップロード（必須でないものは存在する場合のみ）
+	// SHA-256をメタデータに付け、すべてアップロードした後にDBに記録する（配信・ダウンロード時の検証用）
+	var checksums []storage.ArtifactChecksum
+	for _, a := range Artifacts() {
+		name := a.FileName()
+		data, err := os.ReadFile(filepath.Join(jobDir, name))
+		if err != nil {
+			if a.Required {
+				return fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			continue
 		}
-	}
-
-	// dist_score.pngをアップロード
-	scatterPath := filepath.Join(jobDir, "dist_score.png")
-	scatterKey := fmt.Sprintf("%s/dist_score.png", r2Prefix)
-	if data, err := os.ReadFile(scatterPath); err == nil {
-		if err := m.r2.PutObject(m.ctx, scatterKey, data, "image/png"); err != nil {
-			return fmt.Errorf("failed to upload dist_score.png: %w", err)
-		}
-	}
-
-	// logs.txtをアップロード（存在する場合）
-	logsPath := filepath.Join(jobDir, "logs.txt")
-	logsKey := fmt.Sprintf("%s/logs.txt", r2Prefix)
-	if data, err := os.ReadFile(logsPath); err == nil {
-		if err := m.r2.PutObject(m.ctx, logsKey, data, "text/plain"); err != nil {
-			return fmt.Errorf("failed to upload logs.txt: %w", err)
+		checksum := storage.NewArtifactChecksum(a.Name, data)
+		if err := m.putArtifact(a.KeyIn(layout, jobID), data, a.ContentType, checksum); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", name, err)
 		}
+		checksums = append(checksums, checksum)
 	}
+	m.saveArtifactChecksums(jobID, checksums)
+	return m.uploadPDBFiles(layout, jobID, jobDir)
+}
 
-	return nil
+// putObject タイムアウト付きでR2にアップロードする
+func (m *Manager) putObject(key string, data []byte, contentType string) error {
+	ctx, cancel := context.WithTimeout(m.ctx, objectStoreTimeout)
+	defer cancel()
+	return m.r2.PutObject(ctx, key, data, contentType)
 }
 
 // ExtractMetrics extracts metrics from a result map (public method for API use)
@@ -838,6 +968,11 @@ func (m *Manager) extractMetrics(result map[string]interface{}) map[string]inter
 				metrics["cis_dist_std"] = cisDistStd
 			}
 		}
+
+		// 全手法で解析した場合の手法ごとの内訳（{"X-ray": {"entries", "chains", "resolution", "umf", "mean_std"}, ...}）
+		if breakdown, ok := stats["method_breakdown"].(map[string]interface{}); ok && len(breakdown) > 0 {
+			metrics["method_breakdown"] = breakdown
+		}
 	}
 
 	// score_summaryから抽出
@@ -853,51 +988,90 @@ func (m *Manager) extractMetrics(result map[string]interface{}) map[string]inter
 	return metrics
 }
 
+// updateJobStatus ジョブの状態を更新し、DB・イベント・購読者に反映する
+// m.muはメモリ上の状態の書き換えの間だけ保持し、DBの更新・通知はジョブごとのロック（updateMu）で順番を保ってロックの外で行う
 func (m *Manager) updateJobStatus(job *Job, status JobStatus, progress int, message string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	job.updateMu.Lock()
+	defer job.updateMu.Unlock()
 
+	m.mu.Lock()
+	prevStatus := job.Status
 	job.Status = status
 	job.Progress = progress
 	job.Message = message
-	job.UpdatedAt = time.Now()
-
+	job.UpdatedAt = time.Now().UTC()
+	if status == StatusRunning && prevStatus != StatusRunning {
+		job.startedAt = job.UpdatedAt
+	}
+	if n, ok := parseStructureCount(message); ok {
+		job.structures = n
+	}
 	if status == StatusFailed {
 		job.ErrorMessage = message
-		fmt.Printf("[ERROR] Job %s failed: %s\n", job.ID, message)
+	}
+	update := m.newJobUpdate(job)
+	// 削除済みのジョブは一覧に戻さない
+	if m.jobs[job.ID] == job {
+		m.active.update(activeEntry(job))
+	}
+	m.mu.Unlock()
+
+	if status == StatusFailed {
+		job.logger().Errorf("Job %s failed: %s", job.ID, message)
 	} else {
-		fmt.Printf("[DEBUG] Job %s status updated: %s (progress: %d%%) - %s\n", job.ID, status, progress, message)
+		job.logger().Debugf("Job %s status updated: %s (progress: %d%%) - %s", job.ID, status, progress, message)
+	}
+
+	// 状態遷移をイベントとして記録する（進捗のみの更新は記録しない）
+	if status != prevStatus {
+		event := JobEvent{Type: EventStatusChanged, FromStatus: string(prevStatus), ToStatus: string(status), Message: message}
+		// 失敗した解析を作成したリクエストまで辿れるようにする
+		if status == StatusFailed && job.RequestID != "" {
+			event.Data = map[string]interface{}{"request_id": job.RequestID}
+		}
+		m.recordEvent(job.ID, event)
 	}
 
 	// DBを更新（オプショナル）
 	if m.db != nil {
 		progressPtr := &progress
 		var startedAt *time.Time
-		if status == StatusRunning && job.Progress > 0 {
+		// 開始時刻は実行中に遷移したときのみ記録する（進捗更新で上書きしない）
+		if status == StatusRunning && prevStatus != StatusRunning {
 			now := time.Now()
 			startedAt = &now
 		}
-		if err := m.db.UpdateAnalysisStatus(job.ID, string(status), progressPtr, message, startedAt); err != nil {
-			fmt.Printf("[WARN] Failed to update analysis status in DB: %v\n", err)
+		err := m.db.UpdateAnalysisStatus(job.ID, string(status), progressPtr, message, startedAt)
+		m.failures.dependency(DependencyDB, err)
+		if err != nil {
+			job.logger().Warnf("Failed to update analysis status in DB: %v", err)
 		}
 		if status == StatusFailed {
 			if err := m.db.FailAnalysis(job.ID, message); err != nil {
-				fmt.Printf("[WARN] Failed to fail analysis in DB: %v\n", err)
+				job.logger().Warnf("Failed to fail analysis in DB: %v", err)
 			} else {
-				fmt.Printf("[DEBUG] Error message saved to DB for job %s: %s\n", job.ID, message)
+				job.logger().Debugf("Error message saved to DB for job %s: %s", job.ID, message)
 			}
 		}
 	}
+
+	// 購読者に通知
+	m.publish(update)
+	m.notifyListeners(update)
+	if status != prevStatus {
+		m.notifyChange(job.ID, false)
+	}
 }
 
 func (m *Manager) saveStatus(job *Job) error {
-	jobDir := filepath.Join(m.storageDir, job.ID)
+	jobDir := m.localDirOf(job)
 	statusPath := filepath.Join(jobDir, "status.json")
 
 	statusData := map[string]interface{}{
-		"status":   job.Status,
-		"progress": job.Progress,
-		"message":  job.Message,
+		"status":         job.Status,
+		"progress":       job.Progress,
+		"message":        job.Message,
+		"storage_layout": storageLayout(job.storageLayout).Version,
 	}
 
 	if job.ErrorMessage != "" {
@@ -913,7 +1087,10 @@ func (m *Manager) saveStatus(job *Job) error {
 }
 
 func (m *Manager) loadJob(jobID string) (*Job, error) {
-	jobDir := filepath.Join(m.storageDir, jobID)
+	jobDir, layout := m.findLocalDir(jobID)
+	if jobDir == "" {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
 	statusPath := filepath.Join(jobDir, "status.json")
 
 	data, err := os.ReadFile(statusPath)
@@ -931,7 +1108,9 @@ func (m *Manager) loadJob(jobID string) (*Job, error) {
 		Status:    JobStatus(statusData["status"].(string)),
 		Progress:  int(statusData["progress"].(float64)),
 		Message:   statusData["message"].(string),
-		UpdatedAt: time.Now(),
+		UpdatedAt: time.Now().UTC(),
+		// ディレクトリの場所で保存形式が決まる（status.jsonのstorage_layoutは確認用）
+		storageLayout: layout.Version,
 	}
 
 	if errorMsg, ok := statusData["error_message"].(string); ok {
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AnalysisMetrics 完了した解析のメトリクス（DBがあればレコードに保存したもの、なければローカルのresult.jsonから抽出する）
+func (m *Manager) AnalysisMetrics(ctx context.Context, id string) (map[string]interface{}, error) {
+	if m.db != nil {
+		record, err := m.db.GetAnalysisContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, fmt.Errorf("analysis not found: %s", id)
+		}
+		if len(record.Metrics) > 0 {
+			return record.Metrics, nil
+		}
+	}
+
+	dir, _ := m.findLocalDir(id)
+	if dir == "" {
+		return nil, fmt.Errorf("metrics not found for %s", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "result.json"))
+	if err != nil {
+		return nil, fmt.Errorf("metrics not found for %s: %w", id, err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result of %s: %w", id, err)
+	}
+	return m.extractMetrics(result), nil
+}
+
+// RerunSource 再実行で作成した解析の再実行元のID（作成イベントのrerun_of、再実行でない場合は空）
+func (m *Manager) RerunSource(ctx context.Context, id string) (string, error) {
+	events, err := m.ListEvents(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	for _, event := range events {
+		if event.Type != EventCreated {
+			continue
+		}
+		source, _ := event.Data["rerun_of"].(string)
+		return source, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,384 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AnalysisParams 解析のパラメータ
+// Job.Params・DBのparamsはこの構造体をMapで変換したもの（キーはJSONのフィールド名）
+type AnalysisParams struct {
+	SequenceRatio float64 `json:"sequence_ratio"`
+	MinStructures int     `json:"min_structures"`
+	// "X-ray"・"NMR"・"EM"・"all"
+	Method        string  `json:"method"`
+	NegativePDBID string  `json:"negative_pdbid"`
+	CisThreshold  float64 `json:"cis_threshold"`
+	ProcCis       bool    `json:"proc_cis"`
+	// UniProtのアイソフォーム番号（uniprot_idの "-2" 等の接尾辞から設定する、0は正規の配列）
+	Isoform int `json:"isoform,omitempty"`
+	// 解析するチェーンID（"A"・"A,B"、空はすべてのチェーン）
+	Chain string `json:"chain,omitempty"`
+	// ジョブのタイムアウト（秒、0はサーバーのデフォルト）
+	TimeoutSeconds float64 `json:"timeout_seconds,omitempty"`
+	// 作業ディレクトリを再利用する解析のID
+	ResumeFrom string `json:"resume_from,omitempty"`
+	// 資源の制限（サーバーの制限より厳しくする方向のみ、0は指定なし）
+	Nice        int `json:"nice,omitempty"`
+	MaxMemoryMB int `json:"max_memory_mb,omitempty"`
+	Threads     int `json:"threads,omitempty"`
+	// 環境変数の上書き（JOB_ENV_ALLOWLISTで許可されたもののみ）
+	Env map[string]string `json:"env,omitempty"`
+
+	// 以下はサーバーが設定する（リクエストでは指定できない）
+	SessionID string `json:"session_id,omitempty"`
+	// 結果キャッシュから復元した場合の復元元の解析ID
+	CachedFrom string `json:"cached_from,omitempty"`
+	// ジョブの種類（実行枠の予約に使う、空はinteractive）
+	Class string `json:"class,omitempty"`
+	// パラメータスイープ（POST /api/analyses/:id/rerunで配列を指定した再実行）のID
+	SweepID string `json:"sweep_id,omitempty"`
+}
+
+// DefaultAnalysisParams ジョブ作成時のデフォルトパラメータ（/api/configでフロントエンドにも返す）
+func DefaultAnalysisParams() AnalysisParams {
+	return AnalysisParams{
+		SequenceRatio: 0.7,
+		MinStructures: 5,
+		Method:        "X-ray",
+		CisThreshold:  3.3,
+		ProcCis:       true,
+	}
+}
+
+// paramKind パラメータの値の型
+type paramKind int
+
+const (
+	paramNumber paramKind = iota
+	paramInteger
+	paramString
+	paramBoolean
+	paramEnv
+)
+
+// paramSpec 1つのパラメータの検証ルール
+type paramSpec struct {
+	kind paramKind
+	min  *float64
+	max  *float64
+	enum []string
+	// 文字列の形式（nilは任意の文字列）
+	pattern *regexp.Regexp
+	// サーバーが設定するパラメータ（リクエストでは指定できない、保存済みのパラメータからは読み込む）
+	internal bool
+}
+
+func paramBound(v float64) *float64 {
+	return &v
+}
+
+// paramSpecs 指定できるパラメータ（ここにないキーは検証でエラーにする）
+var paramSpecs = map[string]paramSpec{
+	"sequence_ratio":  {kind: paramNumber, min: paramBound(0), max: paramBound(1)},
+	"min_structures":  {kind: paramInteger, min: paramBound(1), max: paramBound(10000)},
+	"method":          {kind: paramString, enum: []string{"X-ray", "NMR", "EM", "all"}},
+	"xray_only":       {kind: paramBoolean}, // 後方互換性のため（methodに変換する）
+	"negative_pdbid":  {kind: paramString},
+	"cis_threshold":   {kind: paramNumber, min: paramBound(0), max: paramBound(10)},
+	"proc_cis":        {kind: paramBoolean},
+	"isoform":         {kind: paramInteger, min: paramBound(1), max: paramBound(999)},
+	"chain":           {kind: paramString, pattern: chainIDsPattern},
+	"timeout_seconds": {kind: paramNumber, min: paramBound(1)},
+	"resume_from":     {kind: paramString},
+	"nice":            {kind: paramInteger, min: paramBound(0), max: paramBound(19)},
+	"max_memory_mb":   {kind: paramInteger, min: paramBound(1)},
+	"threads":         {kind: paramInteger, min: paramBound(1)},
+	"env":             {kind: paramEnv},
+	"session_id":      {kind: paramString, internal: true},
+	"cached_from":     {kind: paramString, internal: true},
+	"class":           {kind: paramString, enum: JobClasses, internal: true},
+	"sweep_id":        {kind: paramString, internal: true},
+}
+
+// chainIDsPattern chainパラメータの形式（PDBのチェーンIDをカンマ区切りで指定する、大文字・小文字は区別する）
+var chainIDsPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,4}(,[A-Za-z0-9]{1,4})*$`)
+
+// ParamError パラメータごとの検証エラー
+type ParamError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ParamsError パラメータの検証エラー（未知のキー・型・範囲、すべてのエラーを含む）
+type ParamsError struct {
+	Errors []ParamError
+}
+
+func (e *ParamsError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, fe.Field+" "+fe.Message)
+	}
+	return "invalid params: " + strings.Join(messages, "; ")
+}
+
+// ParseAnalysisParams リクエストのparamsを検証し、未指定のパラメータにデフォルト値を設定する
+// 未知のキー（typoを含む）・サーバーが設定するキーは*ParamsErrorになる
+func ParseAnalysisParams(raw map[string]interface{}) (AnalysisParams, error) {
+	p := DefaultAnalysisParams()
+	if err := p.Apply(raw); err != nil {
+		return AnalysisParams{}, err
+	}
+	return p, nil
+}
+
+// ParseAnalysisParamsStrings クエリ文字列のパラメータを型に合わせて変換してから検証する（GET /api/jobs/new）
+// 変換できない値は文字列のまま検証し、型のエラーにする
+func ParseAnalysisParamsStrings(query map[string]string) (AnalysisParams, error) {
+	raw := make(map[string]interface{}, len(query))
+	for name, value := range query {
+		raw[name] = value
+		spec, ok := paramSpecs[name]
+		if !ok {
+			continue
+		}
+		switch spec.kind {
+		case paramNumber, paramInteger:
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				raw[name] = n
+			}
+		case paramBoolean:
+			if b, err := strconv.ParseBool(value); err == nil {
+				raw[name] = b
+			}
+		}
+	}
+	return ParseAnalysisParams(raw)
+}
+
+// AnalysisParamsFromMap 保存済みのパラメータ（Job.Params・DBのparams）を読み込む
+// 検証済みのため、古いバージョンで保存された未知のキー・不正な値は無視してデフォルト値を使う
+func AnalysisParamsFromMap(raw map[string]interface{}) AnalysisParams {
+	p := DefaultAnalysisParams()
+	valid := make(map[string]interface{}, len(raw))
+	for name, value := range raw {
+		spec, ok := paramSpecs[name]
+		if !ok || value == nil || spec.validate(name, value) != nil {
+			continue
+		}
+		valid[name] = value
+	}
+	p.merge(valid)
+	return p
+}
+
+// Apply リクエストで指定されたパラメータで上書きする（再実行のオーバーライド等、指定されていないパラメータはそのまま）
+func (p *AnalysisParams) Apply(raw map[string]interface{}) error {
+	var errs []ParamError
+	for name, value := range raw {
+		spec, ok := paramSpecs[name]
+		if !ok || spec.internal {
+			errs = append(errs, ParamError{Field: name, Message: "is not a known field"})
+			continue
+		}
+		if value == nil {
+			errs = append(errs, ParamError{Field: name, Message: "must not be null"})
+			continue
+		}
+		if fe := spec.validate(name, value); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool {
+			return errs[i].Field < errs[j].Field
+		})
+		return &ParamsError{Errors: errs}
+	}
+	p.merge(raw)
+	return nil
+}
+
+// merge 検証済みの値で上書きする（xray_onlyはmethodが指定されていない場合のみmethodに変換する）
+func (p *AnalysisParams) merge(raw map[string]interface{}) {
+	values := p.Map()
+	for name, value := range raw {
+		values[name] = value
+	}
+	if xrayOnly, ok := raw["xray_only"].(bool); ok {
+		if _, hasMethod := raw["method"]; !hasMethod {
+			if xrayOnly {
+				values["method"] = "X-ray"
+			} else {
+				values["method"] = "all"
+			}
+		}
+	}
+	delete(values, "xray_only")
+
+	// 型は検証済みのため、JSONを経由して構造体に変換する（整数の1.0はintに変換できる）
+	data, err := json.Marshal(values)
+	if err != nil {
+		logging.Warnf("Failed to encode params: %v", err)
+		return
+	}
+	var next AnalysisParams
+	if err := json.Unmarshal(data, &next); err != nil {
+		logging.Warnf("Failed to decode params: %v", err)
+		return
+	}
+	*p = next
+}
+
+// Map Job.Params・DBに保存する形式（整数のパラメータはint、それ以外の数値はfloat64）
+// DBのJSONBから読み込むと整数もfloat64になるため、読み込みは必ずAnalysisParamsFromMapで型を戻す
+func (p AnalysisParams) Map() map[string]interface{} {
+	values := make(map[string]interface{})
+	data, err := json.Marshal(p)
+	if err != nil {
+		return values
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		logging.Warnf("Failed to convert params: %v", err)
+	}
+	for name, value := range values {
+		if n, ok := value.(float64); ok && paramSpecs[name].kind == paramInteger {
+			values[name] = int(n)
+		}
+	}
+	return values
+}
+
+// CLIArgs 解析のパラメータをPython CLI（dsa_cli run）の引数にする（--uniprot・--out・--resumeは含まない）
+// 数値は指数表記にせず、値を丸めない最短の表記にする（同じパラメータからは必ず同じ引数になる）
+func (p AnalysisParams) CLIArgs() []string {
+	args := []string{
+		"--sequence-ratio", formatParamFloat(p.SequenceRatio),
+		"--min-structures", strconv.Itoa(p.MinStructures),
+	}
+
+	// "all"は空文字列に変換（Python CLIのchoicesに合わせる、xray_onlyはパラメータの読み込み時にmethodに変換済み）
+	method := p.Method
+	if method == "all" {
+		method = ""
+	}
+	// methodが空文字列の場合でも--methodを追加（Python CLIのchoicesに""が含まれているため）
+	args = append(args, "--method", method)
+
+	if p.NegativePDBID != "" {
+		args = append(args, "--negative-pdbid", p.NegativePDBID)
+	}
+
+	args = append(args, "--cis-threshold", formatParamFloat(p.CisThreshold))
+
+	if p.ProcCis {
+		args = append(args, "--proc-cis")
+	}
+
+	// アイソフォーム・チェーンの指定（正規の配列・すべてのチェーンの場合は渡さない）
+	if p.Isoform > 0 {
+		args = append(args, "--isoform", strconv.Itoa(p.Isoform))
+	}
+	if p.Chain != "" {
+		args = append(args, "--chain", p.Chain)
+	}
+	return args
+}
+
+// formatParamFloat 数値の引数（0.7・3.25・0.00001、整数の値は "3"）
+func formatParamFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// verifyRoundTrip DB（JSONB）に保存して読み込み直したパラメータから同じCLIの引数になることを確認する
+// 再実行・結果キャッシュの照合で、記録した引数と異なる解析にならないようにする
+func (p AnalysisParams) verifyRoundTrip() error {
+	data, err := json.Marshal(p.Map())
+	if err != nil {
+		return fmt.Errorf("failed to encode params: %w", err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to decode params: %w", err)
+	}
+	want := p.CLIArgs()
+	got := AnalysisParamsFromMap(stored).CLIArgs()
+	if strings.Join(want, "\x00") != strings.Join(got, "\x00") {
+		return fmt.Errorf("params do not round-trip: %q became %q", want, got)
+	}
+	return nil
+}
+
+// validate 1つの値を検証する（エラーがない場合はnil）
+func (s paramSpec) validate(name string, value interface{}) *ParamError {
+	fail := func(format string, args ...interface{}) *ParamError {
+		return &ParamError{Field: name, Message: fmt.Sprintf(format, args...)}
+	}
+
+	switch s.kind {
+	case paramNumber, paramInteger:
+		n, ok := paramNumberValue(value)
+		if !ok {
+			return fail("must be a number")
+		}
+		if s.kind == paramInteger && n != math.Trunc(n) {
+			return fail("must be an integer")
+		}
+		if s.min != nil && n < *s.min {
+			return fail("must be >= %g", *s.min)
+		}
+		if s.max != nil && n > *s.max {
+			return fail("must be <= %g", *s.max)
+		}
+	case paramString:
+		v, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		if len(s.enum) > 0 && !containsParamValue(s.enum, v) {
+			return fail("must be one of %s", strings.Join(s.enum, ", "))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fail("has an invalid format")
+		}
+	case paramBoolean:
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	case paramEnv:
+		if _, err := envOverrides(map[string]interface{}{envParam: value}); err != nil {
+			if envErr, ok := err.(*EnvOverrideError); ok && envErr.Name != "" {
+				return &ParamError{Field: name + "." + envErr.Name, Message: envErr.Reason}
+			}
+			return fail("must be an object")
+		}
+	}
+	return nil
+}
+
+// paramNumberValue JSON由来のfloat64とGoから直接渡されたintの両方に対応する
+func paramNumberValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func containsParamValue(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
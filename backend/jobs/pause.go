@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 待ち行列の一時停止の状態を保存するファイル（再起動後も停止したままにする）
+const queuePauseFile = "queue_paused.json"
+
+// QueuePause 待ち行列の一時停止の状態
+// 停止中もジョブの投入は受け付け（queued）、実行枠の割り当て（Pythonの起動・リモートワーカーへの受け渡し）だけを止める
+type QueuePause struct {
+	Paused   bool       `json:"paused"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+}
+
+// PauseQueue 新しいジョブへの実行枠の割り当てを止める（実行中のジョブはそのまま完了まで実行される）
+// 既に停止している場合は状態を変えずにfalseを返す
+func (m *Manager) PauseQueue(reason string) (QueuePause, bool) {
+	s := m.scheduler
+	s.mu.Lock()
+	if s.paused {
+		state := s.pauseState()
+		s.mu.Unlock()
+		return state, false
+	}
+	s.paused = true
+	s.pausedAt = time.Now().UTC()
+	s.pauseReason = reason
+	state := s.pauseState()
+	s.mu.Unlock()
+
+	if err := m.saveQueuePause(state); err != nil {
+		logging.Warnf("Failed to save queue pause state: %v", err)
+	}
+	logging.Infof("Job queue paused (reason: %q)", reason)
+	return state, true
+}
+
+// ResumeQueue 実行枠の割り当てを再開し、待っているジョブに空いている枠を割り当てる
+// 停止していない場合はfalseを返す
+func (m *Manager) ResumeQueue() (QueuePause, bool) {
+	s := m.scheduler
+	s.mu.Lock()
+	if !s.paused {
+		state := s.pauseState()
+		s.mu.Unlock()
+		return state, false
+	}
+	s.paused = false
+	s.pausedAt = time.Time{}
+	s.pauseReason = ""
+	s.dispatch()
+	state := s.pauseState()
+	s.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(m.storageDir, queuePauseFile)); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Failed to remove queue pause state: %v", err)
+	}
+	logging.Infof("Job queue resumed")
+	return state, true
+}
+
+// QueuePauseState 待ち行列の一時停止の状態を返す
+func (m *Manager) QueuePauseState() QueuePause {
+	m.scheduler.mu.Lock()
+	defer m.scheduler.mu.Unlock()
+	return m.scheduler.pauseState()
+}
+
+// LoadQueuePauseState 前回の停止時に一時停止していた場合は停止した状態で起動する（デプロイ中の再起動用）
+func (m *Manager) LoadQueuePauseState() {
+	data, err := os.ReadFile(filepath.Join(m.storageDir, queuePauseFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warnf("Failed to read queue pause state: %v", err)
+		}
+		return
+	}
+	var state QueuePause
+	if err := json.Unmarshal(data, &state); err != nil {
+		logging.Warnf("Failed to parse queue pause state: %v", err)
+		return
+	}
+	if !state.Paused {
+		return
+	}
+
+	s := m.scheduler
+	s.mu.Lock()
+	s.paused = true
+	s.pauseReason = state.Reason
+	if state.PausedAt != nil {
+		s.pausedAt = *state.PausedAt
+	} else {
+		s.pausedAt = time.Now().UTC()
+	}
+	s.mu.Unlock()
+	logging.Infof("Job queue is paused since %s (reason: %q), resume with POST /api/admin/queue/resume", s.pausedAt.Format(time.RFC3339), state.Reason)
+}
+
+// saveQueuePause 途中で停止しても壊れないように一時ファイルに書いてから置き換える
+func (m *Manager) saveQueuePause(state QueuePause) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.storageDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(m.storageDir, queuePauseFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// pauseState 一時停止の状態（s.muを保持して呼ぶ）
+func (s *fairScheduler) pauseState() QueuePause {
+	if !s.paused {
+		return QueuePause{}
+	}
+	pausedAt := s.pausedAt
+	return QueuePause{Paused: true, PausedAt: &pausedAt, Reason: s.pauseReason}
+}
@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetPDBUpload 解析完了時にPythonがダウンロードしたPDBファイル（work/pdb_files/*.cif）を成果物と一緒にR2に保存するか
+// DBがある場合は作業ディレクトリが一時ディレクトリのため、保存しないとGET /api/jobs/:id/pdb/:pdbidで取得できない
+func (m *Manager) SetPDBUpload(enabled bool) {
+	m.pdbUpload = enabled
+}
+
+// pdbFileKey 解析のPDBファイルのR2キー（<prefix>/pdb_files/<pdbid>.cif）
+func (m *Manager) pdbFileKey(jobID, pdbID string) string {
+	return fmt.Sprintf("%s/%s/%s.cif", m.artifactPrefix(jobID), pdbFilesDir, strings.ToLower(pdbID))
+}
+
+// PDBFileKey R2に保存された解析のPDBファイルのキー（保存されていない場合は空文字列）
+func (m *Manager) PDBFileKey(ctx context.Context, jobID, pdbID string) (string, error) {
+	if m.r2 == nil || strings.ContainsAny(pdbID, `/\`) {
+		return "", nil
+	}
+	key := m.pdbFileKey(jobID, pdbID)
+	listCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+	defer cancel()
+	objects, err := m.r2.ListObjectsWithPrefix(listCtx, key)
+	if err != nil {
+		return "", err
+	}
+	for _, obj := range objects {
+		if obj.Key == key {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// spoolPDBFiles PDBファイルをスプールエントリのpdb_files/にコピーする（保存しない設定の場合は何もしない）
+func (m *Manager) spoolPDBFiles(jobDir, entryDir string) error {
+	if !m.pdbUpload {
+		return nil
+	}
+	files, err := pdbFiles(filepath.Join(jobDir, "work", pdbFilesDir))
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	dst := filepath.Join(entryDir, pdbFilesDir)
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, src := range files {
+		if err := copyFile(src, filepath.Join(dst, filepath.Base(src))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadPDBFiles PDBファイルをR2にアップロードする
+// dirはスプールエントリ（pdb_files/）または作業ディレクトリ（work/pdb_files/、保存する設定の場合のみ）
+func (m *Manager) uploadPDBFiles(layout *StorageLayout, jobID, dir string) error {
+	pdbDir := filepath.Join(dir, pdbFilesDir)
+	if _, err := os.Stat(pdbDir); err != nil {
+		if !m.pdbUpload {
+			return nil
+		}
+		pdbDir = filepath.Join(dir, "work", pdbFilesDir)
+	}
+	files, err := pdbFiles(pdbDir)
+	if err != nil {
+		return err
+	}
+	for _, src := range files {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filepath.Base(src), err)
+		}
+		key := fmt.Sprintf("%s/%s/%s", layout.Prefix(jobID), pdbFilesDir, strings.ToLower(filepath.Base(src)))
+		if err := m.putObject(key, data, "chemical/x-cif"); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", filepath.Base(src), err)
+		}
+	}
+	if len(files) > 0 {
+		logging.Job(jobID).Debugf("Uploaded %d PDB files for %s", len(files), jobID)
+	}
+	return nil
+}
+
+// pdbFiles ディレクトリ内の.cifファイル（ディレクトリがない場合は空）
+func pdbFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".cif") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
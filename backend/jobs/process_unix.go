@@ -0,0 +1,84 @@
+//go:build !windows
+
+package jobs
+
+import (
+	"dsa-api/logging"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup 子プロセスを独自のプロセスグループで起動する
+// Pythonが起動した孫プロセス（multiprocessing等）もまとめて終了できるようにする
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// terminateProcessGroup プロセスグループにSIGTERMを送り、猶予期間内に終了しなければSIGKILLを送る
+// 終了の確認はバックグラウンドで行うため、ロックを保持したまま呼んでもよい
+func terminateProcessGroup(pid int) {
+	if pid <= 0 {
+		return
+	}
+	// プロセスグループで起動していないプロセス（古いPIDファイル等）は単体で終了させる
+	target := -pid
+	if err := syscall.Kill(target, syscall.SIGTERM); err != nil {
+		if !errors.Is(err, syscall.ESRCH) {
+			logging.Warnf("Failed to send SIGTERM to process group %d: %v", pid, err)
+		}
+		target = pid
+		if err := syscall.Kill(target, syscall.SIGTERM); err != nil {
+			if !errors.Is(err, syscall.ESRCH) {
+				logging.Warnf("Failed to send SIGTERM to process %d: %v", pid, err)
+			}
+			return
+		}
+	}
+	logging.Debugf("Sent SIGTERM to process group %d", pid)
+
+	go func() {
+		deadline := time.Now().Add(processKillGracePeriod)
+		for time.Now().Before(deadline) {
+			// シグナル0は存在確認のみ（グループ内のプロセスがすべて終了するとESRCH）
+			if err := syscall.Kill(target, 0); errors.Is(err, syscall.ESRCH) {
+				logging.Debugf("Process group %d terminated", pid)
+				return
+			}
+			time.Sleep(200 * time.Millisecond)
+		}
+		logging.Warnf("Process group %d did not exit within %s, sending SIGKILL", pid, processKillGracePeriod)
+		if err := syscall.Kill(target, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			logging.Warnf("Failed to send SIGKILL to process group %d: %v", pid, err)
+		}
+	}()
+}
+
+// applyMemoryLimit 仮想メモリの上限（ulimit -v）を設定したシェル経由で起動するようにコマンドを書き換える（起動前に呼ぶ）
+// 子プロセスの資源制限はGoから直接設定できないため、シェルで制限してからexecする（PIDは変わらない）
+func applyMemoryLimit(cmd *exec.Cmd, maxMemoryMB int) error {
+	if maxMemoryMB <= 0 {
+		return nil
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return fmt.Errorf("sh not found: %v", err)
+	}
+	script := "ulimit -v " + strconv.Itoa(maxMemoryMB*1024) + ` && exec "$0" "$@"`
+	cmd.Args = append([]string{"sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = sh
+	return nil
+}
+
+// setProcessNice プロセスグループの優先度（nice）を設定する（起動後に呼ぶ、以降に起動した子プロセスにも引き継がれる）
+func setProcessNice(pid, nice int) {
+	if nice <= 0 {
+		return
+	}
+	if err := syscall.Setpriority(syscall.PRIO_PGRP, pid, nice); err != nil {
+		logging.Warnf("Failed to set nice %d for process group %d: %v", nice, pid, err)
+	}
+}
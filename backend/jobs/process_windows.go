@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup Windowsではプロセスグループを使わない（直接のプロセスのみ終了させる）
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup Windowsではシグナルを送れないため、プロセスを強制終了する
+func terminateProcessGroup(pid int) {
+	if pid <= 0 {
+		return
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	if err := proc.Kill(); err != nil {
+		logging.Warnf("Failed to kill process %d: %v", pid, err)
+	}
+}
+
+// applyMemoryLimit Windowsではメモリの上限を設定しない
+func applyMemoryLimit(cmd *exec.Cmd, maxMemoryMB int) error {
+	if maxMemoryMB > 0 {
+		logging.Warnf("max_memory_mb is not supported on Windows, ignoring")
+	}
+	return nil
+}
+
+// setProcessNice Windowsでは優先度を変更しない
+func setProcessNice(pid, nice int) {}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"bytes"
+	"dsa-api/logging"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Python実行中に割り当てる進捗の範囲（開始20% → 処理完了60%）
+const (
+	pythonProgressStart = 20
+	pythonProgressEnd   = 60
+)
+
+// parseProgressLine "PROGRESS 45 Downloading PDB 3/20" 形式の行を解析する
+func parseProgressLine(line string) (int, string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "PROGRESS ") {
+		return 0, "", false
+	}
+	fields := strings.SplitN(strings.TrimPrefix(line, "PROGRESS "), " ", 2)
+	percent, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	message := ""
+	if len(fields) > 1 {
+		message = strings.TrimSpace(fields[1])
+	}
+	return int(percent), message, true
+}
+
+// scalePythonProgress Pythonの進捗(0-100)をジョブ全体の進捗に変換する
+func scalePythonProgress(percent int) int {
+	return pythonProgressStart + percent*(pythonProgressEnd-pythonProgressStart)/100
+}
+
+// progressWriter 子プロセスの標準出力を行単位で解析し、PROGRESS行を通知する
+// それ以外の出力はそのまま出力先に書き込む
+type progressWriter struct {
+	out        io.Writer
+	onProgress func(percent int, message string)
+	buf        bytes.Buffer
+	mu         sync.Mutex
+}
+
+func newProgressWriter(out io.Writer, onProgress func(percent int, message string)) *progressWriter {
+	return &progressWriter{out: out, onProgress: onProgress}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(w.buf.Next(idx + 1))
+		w.handleLine(line)
+	}
+	return len(p), nil
+}
+
+// Flush 改行で終わっていない残りの出力を処理する
+func (w *progressWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buf.Len() > 0 {
+		w.handleLine(w.buf.String() + "\n")
+		w.buf.Reset()
+	}
+}
+
+func (w *progressWriter) handleLine(line string) {
+	if percent, message, ok := parseProgressLine(line); ok {
+		if w.onProgress != nil {
+			w.onProgress(percent, message)
+		}
+		return
+	}
+	if w.out != nil {
+		if _, err := io.WriteString(w.out, line); err != nil {
+			logging.Warnf("Failed to write process output: %v", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"sort"
+	"time"
+)
+
+// QueueStatus 待ち行列と実行枠の状態（MAX_CONCURRENTの見直し用）
+type QueueStatus struct {
+	// 実行枠の空きを待っているジョブ数
+	Depth int `json:"depth"`
+	// 最も長く待っているジョブの待ち時間（秒、待っているジョブがない場合は0）
+	OldestQueuedSeconds int `json:"oldest_queued_seconds"`
+	// メモリ上のジョブのステータスごとの数
+	StatusCounts map[string]int `json:"status_counts"`
+	// 実行枠の使用状況
+	Slots SlotUsage `json:"slots"`
+	// 実行中のジョブ（実行時間の長い順）
+	Running []RunningJob `json:"running"`
+	// 待ち行列の一時停止の状態
+	Pause QueuePause `json:"pause"`
+}
+
+// SlotUsage 実行枠（MAX_CONCURRENT）の使用状況
+type SlotUsage struct {
+	Total int `json:"total"`
+	InUse int `json:"in_use"`
+	// 使用率（0-1、実行枠を減らした直後は1を超えることがある）
+	Utilization float64 `json:"utilization"`
+	// 1セッションが同時に使える実行枠の上限（0は無制限）
+	PerSession int `json:"per_session"`
+	// 待ちジョブのあるセッション数
+	WaitingSessions int `json:"waiting_sessions"`
+	// ジョブの種類ごとの予約した実行枠・実行中・待ちの数
+	Classes map[string]ClassSlots `json:"classes"`
+}
+
+// RunningJob 実行中のジョブ
+type RunningJob struct {
+	JobID          string    `json:"job_id"`
+	UniProtID      string    `json:"uniprot_id"`
+	Progress       int       `json:"progress"`
+	Message        string    `json:"message"`
+	StartedAt      time.Time `json:"started_at"`
+	RuntimeSeconds int       `json:"runtime_seconds"`
+	ETASeconds     *int      `json:"eta_seconds,omitempty"`
+	Cached         bool      `json:"cached,omitempty"`
+}
+
+// QueueStatus 待ち行列の長さ・ステータスごとの数・実行中のジョブ・実行枠の使用率を返す
+func (m *Manager) QueueStatus() QueueStatus {
+	scheduler := m.SchedulerStats()
+	m.scheduler.mu.Lock()
+	perSession := m.scheduler.perSession
+	m.scheduler.mu.Unlock()
+
+	status := QueueStatus{
+		Depth:        scheduler.Queued,
+		StatusCounts: make(map[string]int),
+		Slots: SlotUsage{
+			Total:           scheduler.Slots,
+			InUse:           scheduler.Running,
+			PerSession:      perSession,
+			WaitingSessions: scheduler.WaitingSessions,
+			Classes:         scheduler.Classes,
+		},
+		Running: []RunningJob{},
+		Pause:   m.QueuePauseState(),
+	}
+	if scheduler.Slots > 0 {
+		status.Slots.Utilization = float64(scheduler.Running) / float64(scheduler.Slots)
+	}
+
+	now := time.Now()
+	var oldestQueued time.Time
+	m.mu.RLock()
+	for _, job := range m.jobs {
+		status.StatusCounts[string(job.Status)]++
+		switch job.Status {
+		case StatusQueued:
+			if oldestQueued.IsZero() || job.CreatedAt.Before(oldestQueued) {
+				oldestQueued = job.CreatedAt
+			}
+		case StatusRunning:
+			running := RunningJob{
+				JobID:      job.ID,
+				UniProtID:  job.UniProtID,
+				Progress:   job.Progress,
+				Message:    job.Message,
+				StartedAt:  job.startedAt,
+				ETASeconds: m.etaSeconds(job, now),
+				Cached:     job.Cached,
+			}
+			if !job.startedAt.IsZero() {
+				running.RuntimeSeconds = int(now.Sub(job.startedAt).Seconds())
+			}
+			status.Running = append(status.Running, running)
+		}
+	}
+	m.mu.RUnlock()
+
+	if !oldestQueued.IsZero() {
+		status.OldestQueuedSeconds = int(now.Sub(oldestQueued).Seconds())
+	}
+	sort.Slice(status.Running, func(i, j int) bool {
+		return status.Running[i].RuntimeSeconds > status.Running[j].RuntimeSeconds
+	})
+	return status
+}
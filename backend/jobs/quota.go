@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+)
+
+// 上限超過時にクライアントへ再試行を促すまでの時間
+const quotaRetryAfter = 30 * time.Second
+
+// QuotaError キュー待ち・実行中のジョブ数が上限に達している
+type QuotaError struct {
+	// session（セッションごとの上限）またはglobal（サーバー全体の上限）
+	Scope string
+	Limit int
+	// 再試行までの目安
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	if e.Scope == "session" {
+		return fmt.Sprintf("Too many active jobs for this session (limit: %d)", e.Limit)
+	}
+	return fmt.Sprintf("Too many active jobs on the server (limit: %d)", e.Limit)
+}
+
+// SetJobQuotas キュー待ち・実行中のジョブ数の上限を設定する（0以下は無制限）
+// 実行枠（SetMaxConcurrent・SetSessionMaxConcurrent）と異なり、上限を超えたジョブは作成しない
+func (m *Manager) SetJobQuotas(perSession, global int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionQuota = max(perSession, 0)
+	m.globalQuota = max(global, 0)
+}
+
+// checkQuotaLocked 新しいジョブを作成できるか確認する（m.muを保持して呼ぶ）
+func (m *Manager) checkQuotaLocked(session string) error {
+	if m.sessionQuota == 0 && m.globalQuota == 0 {
+		return nil
+	}
+
+	active, activeInSession := 0, 0
+	for _, job := range m.jobs {
+		if job.Status != StatusQueued && job.Status != StatusRunning {
+			continue
+		}
+		active++
+		if jobSession(job) == session {
+			activeInSession++
+		}
+	}
+
+	if m.sessionQuota > 0 && activeInSession >= m.sessionQuota {
+		return &QuotaError{Scope: "session", Limit: m.sessionQuota, RetryAfter: quotaRetryAfter}
+	}
+	if m.globalQuota > 0 && active >= m.globalQuota {
+		return &QuotaError{Scope: "global", Limit: m.globalQuota, RetryAfter: quotaRetryAfter}
+	}
+	return nil
+}
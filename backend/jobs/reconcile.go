@@ -0,0 +1,311 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 作成・更新されてからこの期間内のものは処理中の可能性があるため孤立とみなさない
+const reconcileGracePeriod = time.Hour
+
+// 照合全体のタイムアウト（R2の全オブジェクトの列挙を含む）
+const reconcileTimeout = 10 * time.Minute
+
+// ErrReconcileRunning 照合がすでに実行中
+var ErrReconcileRunning = errors.New("reconciliation is already running")
+
+// ErrReconcileUnavailable DBがないため照合できない
+var ErrReconcileUnavailable = errors.New("reconciliation requires a database")
+
+// ReconcileReport DB・ローカルのジョブディレクトリ・R2の照合結果
+type ReconcileReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// trueの場合は孤立したものを削除・記録した
+	Clean bool `json:"clean"`
+	// 照合した数
+	DBRows     int `json:"db_rows"`
+	LocalDirs  int `json:"local_dirs"`
+	R2Prefixes int `json:"r2_prefixes"`
+	// DBにレコードがないR2のオブジェクト（analysis/<id>/ 単位）
+	OrphanedR2 []OrphanedPrefix `json:"orphaned_r2"`
+	// DBにレコードがないローカルのジョブディレクトリ
+	OrphanedLocal []OrphanedLocalDir `json:"orphaned_local"`
+	// 完了しているが必須の成果物がR2にもローカルにもないDBのレコード
+	MissingArtifacts []MissingArtifacts `json:"missing_artifacts"`
+	// 照合できなかったもの・削除に失敗したもの
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// OrphanedPrefix DBにレコードがないR2のプレフィックス
+type OrphanedPrefix struct {
+	AnalysisID   string    `json:"analysis_id"`
+	Prefix       string    `json:"prefix"`
+	Objects      int       `json:"objects"`
+	Bytes        int64     `json:"bytes"`
+	LastModified time.Time `json:"last_modified"`
+	Deleted      bool      `json:"deleted"`
+}
+
+// OrphanedLocalDir DBにレコードがないローカルのジョブディレクトリ
+type OrphanedLocalDir struct {
+	AnalysisID string    `json:"analysis_id"`
+	Path       string    `json:"path"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Deleted    bool      `json:"deleted"`
+}
+
+// MissingArtifacts 成果物が失われたDBのレコード（掃除する場合は成果物を削除済みとして記録し、APIは410で再実行を促す）
+type MissingArtifacts struct {
+	AnalysisID string   `json:"analysis_id"`
+	Status     string   `json:"status"`
+	Missing    []string `json:"missing"`
+	Marked     bool     `json:"marked"`
+}
+
+// StartReconciler DB・ローカル・R2の照合を定期的に実行する（cleanがtrueの場合は孤立したものを掃除する）
+func (m *Manager) StartReconciler(interval time.Duration, clean bool) {
+	if m.db == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(m.ctx, reconcileTimeout)
+			if _, err := m.Reconcile(ctx, clean); err != nil && !errors.Is(err, ErrReconcileRunning) {
+				logging.Warnf("Storage reconciliation failed: %v", err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// Reconcile DBのレコード・ローカルのジョブディレクトリ・R2のプレフィックスを照合し、孤立したものを報告する
+// cleanがtrueの場合、DBにレコードがないR2のオブジェクト・ローカルのディレクトリを削除し、
+// 成果物が失われたレコードは成果物を削除済みとして記録する（レコード自体は削除しない）
+func (m *Manager) Reconcile(ctx context.Context, clean bool) (*ReconcileReport, error) {
+	if m.db == nil {
+		return nil, ErrReconcileUnavailable
+	}
+	if !m.reconcileMu.TryLock() {
+		return nil, ErrReconcileRunning
+	}
+	defer m.reconcileMu.Unlock()
+
+	report := &ReconcileReport{
+		StartedAt:        time.Now(),
+		Clean:            clean,
+		OrphanedR2:       []OrphanedPrefix{},
+		OrphanedLocal:    []OrphanedLocalDir{},
+		MissingArtifacts: []MissingArtifacts{},
+	}
+	cutoff := report.StartedAt.Add(-reconcileGracePeriod)
+
+	records, err := m.allAnalyses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list analyses: %w", err)
+	}
+	report.DBRows = len(records)
+
+	// R2のオブジェクトを解析ごとにまとめる（すべての保存形式のルートを一覧する）
+	r2Objects := make(map[string][]storage.ObjectInfo)
+	r2Prefixes := make(map[string]string)
+	if m.r2 != nil {
+		for _, layout := range storageLayouts {
+			objects, err := m.r2.ListObjectsWithPrefix(ctx, layout.R2Root)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list R2 objects: %w", err)
+			}
+			for _, obj := range objects {
+				if id, ok := layout.AnalysisIDFromKey(obj.Key); ok {
+					r2Objects[id] = append(r2Objects[id], obj)
+					r2Prefixes[id] = layout.Prefix(id)
+				}
+			}
+		}
+	}
+	report.R2Prefixes = len(r2Objects)
+
+	m.reconcileR2(ctx, report, records, r2Objects, r2Prefixes, cutoff)
+	m.reconcileLocal(report, records, cutoff)
+	m.reconcileArtifacts(ctx, report, records, r2Objects, cutoff)
+
+	report.FinishedAt = time.Now()
+	logging.Infof("Storage reconciliation completed: %d orphaned R2 prefixes, %d orphaned local dirs, %d analyses with missing artifacts (clean: %v)",
+		len(report.OrphanedR2), len(report.OrphanedLocal), len(report.MissingArtifacts), clean)
+	return report, nil
+}
+
+// allAnalyses DBのすべての解析をIDをキーにして返す
+func (m *Manager) allAnalyses(ctx context.Context) (map[string]*storage.AnalysisRecord, error) {
+	const pageSize = 1000
+	records := make(map[string]*storage.AnalysisRecord)
+	for offset := 0; ; offset += pageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := m.db.ListAnalyses(map[string]interface{}{"limit": pageSize, "offset": offset})
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range page {
+			records[record.ID] = record
+		}
+		if len(page) < pageSize {
+			return records, nil
+		}
+	}
+}
+
+// isKnownJob DBのレコードがある、またはメモリ上にある（作成中・実行中・アップロード中）解析か
+func (m *Manager) isKnownJob(id string, records map[string]*storage.AnalysisRecord) bool {
+	if _, ok := records[id]; ok {
+		return true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.jobs[id]
+	return ok
+}
+
+// reconcileR2 DBにレコードがないR2のプレフィックスを探す（最終更新が猶予期間内のものは除く）
+func (m *Manager) reconcileR2(ctx context.Context, report *ReconcileReport, records map[string]*storage.AnalysisRecord, r2Objects map[string][]storage.ObjectInfo, r2Prefixes map[string]string, cutoff time.Time) {
+	for id, objects := range r2Objects {
+		if m.isKnownJob(id, records) {
+			continue
+		}
+		orphan := OrphanedPrefix{AnalysisID: id, Prefix: r2Prefixes[id] + "/", Objects: len(objects)}
+		for _, obj := range objects {
+			orphan.Bytes += obj.Size
+			if obj.LastModified.After(orphan.LastModified) {
+				orphan.LastModified = obj.LastModified
+			}
+		}
+		if orphan.LastModified.After(cutoff) {
+			continue
+		}
+		if report.Clean {
+			deleteCtx, cancel := context.WithTimeout(ctx, objectStoreTimeout)
+			err := m.r2.DeleteObjectsWithPrefix(deleteCtx, orphan.Prefix)
+			cancel()
+			if err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to delete R2 objects of %s: %v", id, err))
+			} else {
+				orphan.Deleted = true
+			}
+		}
+		report.OrphanedR2 = append(report.OrphanedR2, orphan)
+	}
+	sort.Slice(report.OrphanedR2, func(i, j int) bool {
+		return report.OrphanedR2[i].LastModified.Before(report.OrphanedR2[j].LastModified)
+	})
+}
+
+// reconcileLocal DBにレコードがないローカルのジョブディレクトリを探す（すべての保存形式、名前が解析IDのディレクトリのみ）
+func (m *Manager) reconcileLocal(report *ReconcileReport, records map[string]*storage.AnalysisRecord, cutoff time.Time) {
+	dirs, err := m.localAnalysisDirs()
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to read %s: %v", m.storageDir, err))
+		return
+	}
+	for _, id := range sortedIDs(dirs) {
+		report.LocalDirs++
+		if m.isKnownJob(id, records) {
+			continue
+		}
+		info, err := os.Stat(dirs[id])
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		orphan := OrphanedLocalDir{AnalysisID: id, Path: dirs[id], ModifiedAt: info.ModTime()}
+		if report.Clean {
+			if err := os.RemoveAll(orphan.Path); err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to delete %s: %v", orphan.Path, err))
+			} else {
+				orphan.Deleted = true
+			}
+		}
+		report.OrphanedLocal = append(report.OrphanedLocal, orphan)
+	}
+}
+
+// reconcileArtifacts 完了したレコードのうち、必須の成果物がR2にもローカルにもないものを探す
+// 保持期間で成果物を削除済みのもの・アップロード待ちのもの・終了して間もないものは除く
+func (m *Manager) reconcileArtifacts(ctx context.Context, report *ReconcileReport, records map[string]*storage.AnalysisRecord, r2Objects map[string][]storage.ObjectInfo, cutoff time.Time) {
+	ids := make([]string, 0, len(records))
+	for id, record := range records {
+		if record.Status == string(StatusDone) {
+			ids = append(ids, id)
+		}
+	}
+	expired, err := m.db.ArtifactsExpiredAt(ctx, ids)
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to check expired artifacts: %v", err))
+		return
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		record := records[id]
+		if _, ok := expired[id]; ok {
+			continue
+		}
+		if record.FinishedAt != nil && record.FinishedAt.After(cutoff) {
+			continue
+		}
+		if _, err := os.Stat(m.spoolEntryDir(id)); err == nil {
+			continue
+		}
+		if m.r2 == nil && record.ResultKey != nil {
+			// R2にアップロードされた成果物はR2が設定されていないと確認できない
+			continue
+		}
+
+		stored := make(map[string]bool)
+		for _, obj := range r2Objects[id] {
+			stored[obj.Key] = true
+		}
+		missing := make([]string, 0)
+		for _, a := range Artifacts() {
+			if !a.Required {
+				continue
+			}
+			if stored[a.ResolveKey(id, record)] {
+				continue
+			}
+			if dir, _ := m.findLocalDir(id); dir != "" {
+				if _, err := os.Stat(filepath.Join(dir, a.FileName())); err == nil {
+					continue
+				}
+			}
+			missing = append(missing, a.Name)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		entry := MissingArtifacts{AnalysisID: id, Status: record.Status, Missing: missing}
+		if report.Clean {
+			if err := m.db.MarkArtifactsExpired(id); err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Failed to mark artifacts of %s as expired: %v", id, err))
+			} else {
+				entry.Marked = true
+				m.recordEvent(id, JobEvent{
+					Type:    EventArtifactsExpired,
+					Message: fmt.Sprintf("Artifacts missing from storage: %s", strings.Join(missing, ", ")),
+				})
+				m.notifyChange(id, false)
+			}
+		}
+		report.MissingArtifacts = append(report.MissingArtifacts, entry)
+	}
+}
@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ワーカーからの報告がない場合にジョブを他のワーカーへ再割り当てするまでの時間
+const defaultLeaseTimeout = 2 * time.Minute
+
+var (
+	// ErrRemoteTaskNotFound ジョブがキャンセル・タイムアウト・完了済みで、ワーカーの処理対象でなくなった
+	ErrRemoteTaskNotFound = errors.New("job is no longer active")
+	// ErrRemoteLeaseLost リースが切れて他のワーカーに再割り当てされた
+	ErrRemoteLeaseLost = errors.New("job is claimed by another worker")
+)
+
+// ArtifactNames ワーカーからアップロードを受け付ける成果物のファイル名
+func ArtifactNames() []string {
+	return artifactFiles()
+}
+
+// ClaimedJob ワーカーに割り当てたジョブ
+type ClaimedJob struct {
+	JobID        string                 `json:"job_id"`
+	UniProtID    string                 `json:"uniprot_id"`
+	Params       map[string]interface{} `json:"params"`
+	LeaseSeconds int                    `json:"lease_seconds"`
+}
+
+// WorkerReport ワーカーからの進捗・結果の報告
+type WorkerReport struct {
+	// running（進捗・ハートビート）、done、failed
+	Status   string
+	Progress *int
+	Message  string
+	Error    string
+}
+
+// RemoteExecutor 解析を別のマシンのワーカー（cmd/worker）に実行させる
+// Runはジョブを待ち行列に入れ、ワーカーがClaimで取得してReportで結果を返すまで待つ
+// 実行枠・タイムアウト・キャンセル・R2へのアップロードはローカル実行と同じくManagerが担当する
+type RemoteExecutor struct {
+	leaseTimeout time.Duration
+
+	mu    sync.Mutex
+	tasks map[string]*remoteTask
+	// 割り当て待ちの順番（ジョブID）
+	order []string
+}
+
+type remoteTask struct {
+	job      *Job
+	jobDir   string
+	progress ProgressFunc
+	done     chan error
+
+	workerID     string
+	leaseExpires time.Time
+}
+
+// NewRemoteExecutor leaseTimeoutが0以下の場合はデフォルト（2分）
+func NewRemoteExecutor(leaseTimeout time.Duration) *RemoteExecutor {
+	if leaseTimeout <= 0 {
+		leaseTimeout = defaultLeaseTimeout
+	}
+	return &RemoteExecutor{
+		leaseTimeout: leaseTimeout,
+		tasks:        make(map[string]*remoteTask),
+	}
+}
+
+func (e *RemoteExecutor) Name() string {
+	return "remote"
+}
+
+func (e *RemoteExecutor) Run(ctx context.Context, job *Job, jobDir string, progress ProgressFunc) error {
+	if err := os.MkdirAll(jobDir, 0755); err != nil {
+		return fmt.Errorf("Failed to create job directory: %v", err)
+	}
+
+	task := &remoteTask{
+		job:      job,
+		jobDir:   jobDir,
+		progress: progress,
+		done:     make(chan error, 1),
+	}
+	e.mu.Lock()
+	e.tasks[job.ID] = task
+	e.order = append(e.order, job.ID)
+	e.mu.Unlock()
+
+	progress(0, "Waiting for a worker...")
+
+	select {
+	case err := <-task.done:
+		return err
+	case <-ctx.Done():
+		// ワーカーは次の報告でErrRemoteTaskNotFoundを受け取り、処理を中断する
+		e.mu.Lock()
+		e.removeLocked(job.ID)
+		e.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Claim 割り当て待ちのジョブを1件ワーカーに割り当てる（なければnil）
+// リースが切れたジョブ（ワーカーが停止した場合など）も再割り当ての対象にする
+func (e *RemoteExecutor) Claim(workerID string) *ClaimedJob {
+	e.mu.Lock()
+	now := time.Now()
+	var task *remoteTask
+	for _, id := range e.order {
+		t := e.tasks[id]
+		if t.workerID == "" || now.After(t.leaseExpires) {
+			if t.workerID != "" {
+				logging.Job(id).Warnf("Lease of job %s expired (worker: %s), reassigning to %s", id, t.workerID, workerID)
+			}
+			task = t
+			break
+		}
+	}
+	if task == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	task.workerID = workerID
+	task.leaseExpires = now.Add(e.leaseTimeout)
+	e.mu.Unlock()
+
+	task.job.logger().Debugf("Job %s claimed by worker %s", task.job.ID, workerID)
+	task.progress(0, fmt.Sprintf("Running on worker %s...", workerID))
+
+	jobParams := task.job.params()
+	params := make(map[string]interface{}, len(jobParams))
+	for key, value := range jobParams {
+		if key == "session_id" {
+			continue
+		}
+		params[key] = value
+	}
+	return &ClaimedJob{
+		JobID:        task.job.ID,
+		UniProtID:    task.job.UniProtID,
+		Params:       params,
+		LeaseSeconds: int(e.leaseTimeout.Seconds()),
+	}
+}
+
+// TaskDir ワーカーが成果物を書き込むディレクトリを返す
+func (e *RemoteExecutor) TaskDir(jobID, workerID string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	task, err := e.claimedLocked(jobID, workerID)
+	if err != nil {
+		return "", err
+	}
+	return task.jobDir, nil
+}
+
+// Report ワーカーからの報告を反映する
+// runningはリースを延長し、done/failedはRunを終了させる（成果物は事前にTaskDirに書き込んでおく）
+func (e *RemoteExecutor) Report(jobID, workerID string, report WorkerReport) error {
+	e.mu.Lock()
+	task, err := e.claimedLocked(jobID, workerID)
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+
+	switch report.Status {
+	case "running":
+		task.leaseExpires = time.Now().Add(e.leaseTimeout)
+		e.mu.Unlock()
+		if report.Progress != nil {
+			task.progress(*report.Progress, report.Message)
+		}
+	case "done":
+		e.removeLocked(jobID)
+		e.mu.Unlock()
+		task.done <- nil
+	case "failed":
+		e.removeLocked(jobID)
+		e.mu.Unlock()
+		message := report.Error
+		if message == "" {
+			message = "Analysis failed on worker"
+		}
+		task.done <- &ExecutionError{Err: errors.New(message)}
+	default:
+		e.mu.Unlock()
+		return fmt.Errorf("unknown status: %s", report.Status)
+	}
+	return nil
+}
+
+// Pending 割り当て待ち・実行中のジョブ数
+func (e *RemoteExecutor) Pending() (queued, claimed int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for _, task := range e.tasks {
+		if task.workerID == "" || now.After(task.leaseExpires) {
+			queued++
+		} else {
+			claimed++
+		}
+	}
+	return queued, claimed
+}
+
+// claimedLocked workerIDに割り当て中のジョブを返す（e.muを保持して呼ぶ）
+func (e *RemoteExecutor) claimedLocked(jobID, workerID string) (*remoteTask, error) {
+	task, ok := e.tasks[jobID]
+	if !ok {
+		return nil, ErrRemoteTaskNotFound
+	}
+	if task.workerID != workerID {
+		return nil, ErrRemoteLeaseLost
+	}
+	return task, nil
+}
+
+// removeLocked 待ち行列から取り除く（e.muを保持して呼ぶ）
+func (e *RemoteExecutor) removeLocked(jobID string) {
+	delete(e.tasks, jobID)
+	for i, id := range e.order {
+		if id == jobID {
+			e.order = append(e.order[:i], e.order[i+1:]...)
+			break
+		}
+	}
+}
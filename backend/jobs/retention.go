@@ -0,0 +1,251 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 1回の期限切れ処理で成果物・解析を削除する数（残りは次回に処理する）
+const expiryBatchSize = 100
+
+// DBがない場合に固定された解析を示すファイル（storage/<id>/pinned）
+const pinnedMarkerFile = "pinned"
+
+// ErrAnalysisNotFound 固定する解析が存在しない
+var ErrAnalysisNotFound = errors.New("analysis not found")
+
+// SetArtifactRetention 終了した解析の成果物（R2・ローカル）を保持する期間を設定する（0以下は無期限）
+// DBのレコード（サマリー・メトリクス）は残し、artifacts_expired_atを記録する
+func (m *Manager) SetArtifactRetention(retention time.Duration) {
+	if retention < 0 {
+		retention = 0
+	}
+	m.retentionMu.Lock()
+	m.artifactRetention = retention
+	m.retentionMu.Unlock()
+}
+
+// SetRetention 解析そのもの（ローカルのジョブディレクトリ、R2の成果物、DBのレコード）を保持する期間を設定する（0以下は無期限）
+// 固定（pinned）された解析は対象外
+func (m *Manager) SetRetention(retention time.Duration) {
+	if retention < 0 {
+		retention = 0
+	}
+	m.retentionMu.Lock()
+	m.retention = retention
+	m.retentionMu.Unlock()
+}
+
+// ArtifactRetention 成果物の保持期間（0は無期限、DBがない場合は削除しないため0）
+func (m *Manager) ArtifactRetention() time.Duration {
+	if m.db == nil {
+		return 0
+	}
+	m.retentionMu.RLock()
+	defer m.retentionMu.RUnlock()
+	return m.artifactRetention
+}
+
+// Retention 解析の保持期間（0は無期限）
+func (m *Manager) Retention() time.Duration {
+	m.retentionMu.RLock()
+	defer m.retentionMu.RUnlock()
+	return m.retention
+}
+
+// StartJanitor 保持期間を過ぎた成果物・解析を定期的に削除する
+// 保持期間は実行中に変更できるため、無期限の場合も起動しておく（各回の処理は何もしない）
+func (m *Manager) StartJanitor(interval time.Duration) {
+	go func() {
+		m.ExpireArtifacts()
+		m.ExpireAnalyses()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.ExpireArtifacts()
+			m.ExpireAnalyses()
+		}
+	}()
+}
+
+// ExpireArtifacts 保持期間を過ぎた解析の成果物を削除し、削除した解析の数を返す
+func (m *Manager) ExpireArtifacts() int {
+	retention := m.ArtifactRetention()
+	if retention <= 0 {
+		return 0
+	}
+	before := time.Now().Add(-retention)
+	ids, err := m.db.ListArtifactExpiryCandidates(before, expiryBatchSize)
+	if err != nil {
+		logging.Warnf("Failed to list analyses with expired artifacts: %v", err)
+		return 0
+	}
+
+	expired := 0
+	for _, id := range ids {
+		// 長期保存先に移した解析は保持期間の対象外（復元した成果物も削除しない）
+		if m.hasArchive(id) {
+			continue
+		}
+		if err := m.expireArtifacts(id); err != nil {
+			logging.Warnf("Failed to expire artifacts of %s: %v", id, err)
+			continue
+		}
+		expired++
+	}
+	if expired > 0 {
+		logging.Debugf("Expired artifacts of %d analyses (finished before %s)", expired, before.Format(time.RFC3339))
+	}
+	return expired
+}
+
+// expireArtifacts 1つの解析の成果物を削除し、DBに記録する（R2の削除に失敗した場合は記録せず次回に再試行する）
+func (m *Manager) expireArtifacts(id string) error {
+	if m.r2 != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), objectStoreTimeout)
+		err := m.r2.DeleteObjectsWithPrefix(ctx, m.artifactPrefix(id)+"/")
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to delete objects from R2: %w", err)
+		}
+	}
+	if dir, _ := m.findLocalDir(id); dir != "" {
+		if err := os.RemoveAll(dir); err != nil {
+			logging.Warnf("Failed to delete local directory of %s: %v", id, err)
+		}
+	}
+	if err := m.db.MarkArtifactsExpired(id); err != nil {
+		return fmt.Errorf("failed to update database: %w", err)
+	}
+	m.recordEvent(id, JobEvent{
+		Type:    EventArtifactsExpired,
+		Message: fmt.Sprintf("Artifacts deleted after retention period of %s", m.ArtifactRetention()),
+	})
+	m.notifyChange(id, false)
+	return nil
+}
+
+// ExpireAnalyses 保持期間を過ぎた固定されていない解析を削除し、削除した解析の数を返す
+// DBがある場合は作成日時、ない場合はstatus.jsonの更新日時（終了日時）で判定する
+func (m *Manager) ExpireAnalyses() int {
+	retention := m.Retention()
+	if retention <= 0 {
+		return 0
+	}
+	before := time.Now().Add(-retention)
+
+	var ids []string
+	if m.db != nil {
+		var err error
+		ids, err = m.db.ListRetentionCandidates(before, expiryBatchSize)
+		if err != nil {
+			logging.Warnf("Failed to list expired analyses: %v", err)
+			return 0
+		}
+	} else {
+		ids = m.localRetentionCandidates(before)
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if err := m.DeleteJob(id); err != nil {
+			logging.Warnf("Failed to delete expired analysis %s: %v", id, err)
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		logging.Debugf("Deleted %d analyses older than %s", deleted, before.Format(time.RFC3339))
+	}
+	return deleted
+}
+
+// localRetentionCandidates DBがない場合に、beforeより前に終了した固定されていないジョブのIDを返す
+func (m *Manager) localRetentionCandidates(before time.Time) []string {
+	dirs, err := m.localAnalysisDirs()
+	if err != nil {
+		logging.Warnf("Failed to read storage directory: %v", err)
+		return nil
+	}
+
+	ids := make([]string, 0)
+	for _, id := range sortedIDs(dirs) {
+		if len(ids) >= expiryBatchSize {
+			break
+		}
+		jobDir := dirs[id]
+		info, err := os.Stat(filepath.Join(jobDir, "status.json"))
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(jobDir, pinnedMarkerFile)); err == nil {
+			continue
+		}
+		job, err := m.GetJob(id)
+		if err != nil || (job.Status != StatusDone && job.Status != StatusFailed && job.Status != StatusCancelled) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// PinAnalysis 解析を保持期間による自動削除の対象外にする（pinned=falseで戻す）
+func (m *Manager) PinAnalysis(ctx context.Context, id string, pinned bool) error {
+	if m.db != nil {
+		found, err := m.db.SetAnalysisPinned(ctx, id, pinned)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrAnalysisNotFound
+		}
+		m.notifyChange(id, false)
+		return nil
+	}
+
+	jobDir, _ := m.findLocalDir(id)
+	if jobDir == "" {
+		return ErrAnalysisNotFound
+	}
+	if _, err := os.Stat(filepath.Join(jobDir, "status.json")); err != nil {
+		return ErrAnalysisNotFound
+	}
+	marker := filepath.Join(jobDir, pinnedMarkerFile)
+	if pinned {
+		return os.WriteFile(marker, nil, 0644)
+	}
+	if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PinnedAnalyses 固定された解析のIDを返す（取得に失敗した場合は空）
+func (m *Manager) PinnedAnalyses(ctx context.Context, ids []string) map[string]bool {
+	if m.db != nil {
+		pinned, err := m.db.PinnedAnalyses(ctx, ids)
+		if err != nil {
+			logging.Warnf("Failed to check pinned analyses: %v", err)
+			return map[string]bool{}
+		}
+		return pinned
+	}
+
+	pinned := make(map[string]bool)
+	for _, id := range ids {
+		dir, _ := m.findLocalDir(id)
+		if dir == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, pinnedMarkerFile)); err == nil {
+			pinned[id] = true
+		}
+	}
+	return pinned
+}
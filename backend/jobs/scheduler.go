@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// fairScheduler 解析の実行枠をセッション間でラウンドロビンに割り当てる
+// 1つのセッションが大量のジョブを投入しても、他のセッションのジョブは次に空いた枠で実行される
+type fairScheduler struct {
+	slots int
+	// セッションごとの同時実行数の上限（0は無制限）
+	perSession int
+
+	// ジョブの種類ごとに予約する実行枠の割合（JOB_CLASS_RESERVATIONS）
+	reservations map[string]float64
+
+	mu        sync.Mutex
+	running   int
+	bySession map[string]int
+	byClass   map[string]int
+	waiting   map[string][]*schedTicket
+	// 待ちジョブのあるセッションの順番（先頭から割り当てる）
+	order []string
+	// 一時停止中は新しい割り当てを行わない（POST /api/admin/queue/pause）
+	paused      bool
+	pausedAt    time.Time
+	pauseReason string
+}
+
+type schedTicket struct {
+	session string
+	class   string
+	ready   chan struct{}
+	granted bool
+}
+
+// SchedulerStats 実行枠の状態（メトリクス用）
+type SchedulerStats struct {
+	Slots           int  `json:"slots"`
+	Running         int  `json:"running"`
+	Queued          int  `json:"queued"`
+	WaitingSessions int  `json:"waiting_sessions"`
+	Paused          bool `json:"paused"`
+	// ジョブの種類ごとの予約した実行枠・実行中・待ちの数
+	Classes map[string]ClassSlots `json:"classes"`
+}
+
+// ClassSlots ジョブの種類ごとの実行枠の状態
+type ClassSlots struct {
+	Reserved int `json:"reserved"`
+	Running  int `json:"running"`
+	Queued   int `json:"queued"`
+}
+
+func newFairScheduler(slots int) *fairScheduler {
+	return &fairScheduler{
+		slots:     slots,
+		bySession: make(map[string]int),
+		byClass:   make(map[string]int),
+		waiting:   make(map[string][]*schedTicket),
+	}
+}
+
+// SetSessionMaxConcurrent 1セッションが同時に使える実行枠の上限を設定する（0以下は無制限）
+func (m *Manager) SetSessionMaxConcurrent(n int) {
+	m.scheduler.mu.Lock()
+	defer m.scheduler.mu.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	m.scheduler.perSession = n
+	m.scheduler.dispatch()
+}
+
+// SetMaxConcurrent 同時に実行するジョブ数（実行枠）を変更する
+// 減らした場合、実行中のジョブはそのまま完了まで実行される
+// ジョブの種類ごとの予約で実行枠がすべて埋まる数の場合はエラーで、実行枠は変更しない
+func (m *Manager) SetMaxConcurrent(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("max concurrent must be > 0 (got %d)", n)
+	}
+	m.scheduler.mu.Lock()
+	defer m.scheduler.mu.Unlock()
+	if err := checkReservedSlots(m.scheduler.reservations, n); err != nil {
+		return err
+	}
+	m.scheduler.slots = n
+	m.scheduler.dispatch()
+	return nil
+}
+
+// SchedulerStats 実行枠の状態を返す
+func (m *Manager) SchedulerStats() SchedulerStats {
+	s := m.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	classes := make(map[string]ClassSlots, len(JobClasses))
+	for _, class := range JobClasses {
+		classes[class] = ClassSlots{Reserved: s.reservedSlots(class), Running: s.byClass[class]}
+	}
+	queued := 0
+	for _, tickets := range s.waiting {
+		queued += len(tickets)
+		for _, ticket := range tickets {
+			usage := classes[ticket.class]
+			usage.Queued++
+			classes[ticket.class] = usage
+		}
+	}
+	return SchedulerStats{
+		Slots:           s.slots,
+		Running:         s.running,
+		Queued:          queued,
+		WaitingSessions: len(s.order),
+		Paused:          s.paused,
+		Classes:         classes,
+	}
+}
+
+// acquire 実行枠が割り当てられるまで待つ
+// ctxがキャンセルされた場合は待ち行列から外してctx.Err()を返す
+func (s *fairScheduler) acquire(ctx context.Context, session, class string) (func(), error) {
+	ticket := &schedTicket{session: session, class: class, ready: make(chan struct{})}
+
+	s.mu.Lock()
+	if len(s.waiting[session]) == 0 {
+		s.order = append(s.order, session)
+	}
+	s.waiting[session] = append(s.waiting[session], ticket)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-ticket.ready:
+		return func() { s.release(session, class) }, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ticket.granted {
+			// 割り当てと同時にキャンセルされた場合は枠を返す
+			s.releaseLocked(session, class)
+		} else {
+			s.removeTicket(ticket)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (s *fairScheduler) release(session, class string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked(session, class)
+}
+
+func (s *fairScheduler) releaseLocked(session, class string) {
+	s.running--
+	if s.bySession[session]--; s.bySession[session] <= 0 {
+		delete(s.bySession, session)
+	}
+	if s.byClass[class]--; s.byClass[class] <= 0 {
+		delete(s.byClass, class)
+	}
+	s.dispatch()
+}
+
+// dispatch 空いている枠を待ち行列の先頭のセッションから順に割り当てる（s.muを保持して呼ぶ）
+// 割り当てたセッションにまだ待ちジョブがあれば末尾に回す（一時停止中は割り当てない）
+// 他の種類に予約された枠しか空いていないジョブは飛ばし、同じセッションの後ろのジョブ・次のセッションを見る
+func (s *fairScheduler) dispatch() {
+	for !s.paused && s.running < s.slots {
+		idx, pos := -1, -1
+		for i, session := range s.order {
+			if s.perSession != 0 && s.bySession[session] >= s.perSession {
+				continue
+			}
+			for j, ticket := range s.waiting[session] {
+				if s.canStartLocked(ticket.class) {
+					pos = j
+					break
+				}
+			}
+			if pos >= 0 {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return
+		}
+
+		session := s.order[idx]
+		s.order = append(s.order[:idx], s.order[idx+1:]...)
+		tickets := s.waiting[session]
+		ticket := tickets[pos]
+		tickets = append(tickets[:pos], tickets[pos+1:]...)
+		if len(tickets) > 0 {
+			s.waiting[session] = tickets
+			s.order = append(s.order, session)
+		} else {
+			delete(s.waiting, session)
+		}
+
+		s.running++
+		s.bySession[session]++
+		s.byClass[ticket.class]++
+		ticket.granted = true
+		close(ticket.ready)
+	}
+}
+
+// removeTicket 割り当て前の待ちジョブを取り除く（s.muを保持して呼ぶ）
+func (s *fairScheduler) removeTicket(ticket *schedTicket) {
+	tickets := s.waiting[ticket.session]
+	for i, t := range tickets {
+		if t == ticket {
+			tickets = append(tickets[:i], tickets[i+1:]...)
+			break
+		}
+	}
+	if len(tickets) > 0 {
+		s.waiting[ticket.session] = tickets
+		return
+	}
+	delete(s.waiting, ticket.session)
+	for i, session := range s.order {
+		if session == ticket.session {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// jobSession スケジューリングに使うセッション（未設定のジョブは1つのグループとして扱う）
+func jobSession(job *Job) string {
+	if sessionID, ok := job.params()["session_id"].(string); ok {
+		return sessionID
+	}
+	return ""
+}
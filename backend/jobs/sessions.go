@@ -0,0 +1,28 @@
+package jobs
+
+// ReassignSession メモリ上のジョブのセッションを付け替え、付け替えた件数を返す（ログイン時に匿名のセッションの解析をアカウントに引き継ぐ）
+// Paramsはスナップショットと共有しているため、書き換えずにコピーを差し替える（実行中の処理が読むためparamsMuも保持する）
+func (m *Manager) ReassignSession(from, to string) int {
+	if from == "" || from == to {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reassigned := 0
+	for _, job := range m.jobs {
+		if sessionID, _ := job.Params["session_id"].(string); sessionID != from {
+			continue
+		}
+		params := make(map[string]interface{}, len(job.Params))
+		for key, value := range job.Params {
+			params[key] = value
+		}
+		params["session_id"] = to
+		job.paramsMu.Lock()
+		job.Params = params
+		job.paramsMu.Unlock()
+		reassigned++
+	}
+	return reassigned
+}
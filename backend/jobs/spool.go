@@ -0,0 +1,460 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// スプールエントリのメタデータファイル名
+const spoolMetaFile = "spool.json"
+
+// スプールのデフォルト設定
+const (
+	defaultUploadWorkers = 2
+	defaultSpoolMax      = 100
+	// スプールがこの割合（%）以上埋まったら新しいPython実行を待機させる
+	spoolHighWatermarkPercent = 80
+)
+
+// spoolMeta アップロード待ちジョブのメタデータ
+type spoolMeta struct {
+	JobID     string                 `json:"job_id"`
+	Metrics   map[string]interface{} `json:"metrics"`
+	SpooledAt time.Time              `json:"spooled_at"`
+	Attempts  int                    `json:"attempts"`
+	LastError string                 `json:"last_error,omitempty"`
+	// 解析の保存形式（アップロード先のキーを決める、0は従来の形式）
+	StorageLayout int `json:"storage_layout,omitempty"`
+}
+
+// uploadSpool R2アップロード専用のワーカープールと有界スプール
+// アップロードはジョブのセマフォとは独立して実行され、R2が遅くてもPython実行枠を占有しない
+type uploadSpool struct {
+	dir     string
+	max     int
+	workers int
+	queue   chan string
+	// スプール上のエントリ（entryDir → スプール時刻）
+	entries map[string]time.Time
+	// キュー投入済み・アップロード中のエントリ
+	queued   map[string]bool
+	inFlight map[string]bool
+	uploaded int64
+	failed   int64
+	started  bool
+	mu       sync.Mutex
+	cond     *sync.Cond
+}
+
+func newUploadSpool() *uploadSpool {
+	s := &uploadSpool{
+		max:      defaultSpoolMax,
+		workers:  defaultUploadWorkers,
+		entries:  make(map[string]time.Time),
+		queued:   make(map[string]bool),
+		inFlight: make(map[string]bool),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// UploadStats アップロードスプールの状態（メトリクス用）
+type UploadStats struct {
+	SpoolDepth    int   `json:"spool_depth"`
+	SpoolCapacity int   `json:"spool_capacity"`
+	Queued        int   `json:"queued"`
+	InFlight      int   `json:"in_flight"`
+	Workers       int   `json:"workers"`
+	UploadedTotal int64 `json:"uploaded_total"`
+	FailedTotal   int64 `json:"failed_total"`
+}
+
+// SetSpoolDir アップロード待ちファイルの保存先を設定する
+func (m *Manager) SetSpoolDir(dir string) {
+	m.spool.dir = dir
+}
+
+// SetUploadPool アップロードワーカー数とスプールの最大エントリ数を設定する（StartUploadWorkersより前に呼ぶこと）
+func (m *Manager) SetUploadPool(workers, maxEntries int) {
+	if workers > 0 {
+		m.spool.workers = workers
+	}
+	if maxEntries > 0 {
+		m.spool.max = maxEntries
+	}
+}
+
+// UploadStats アップロードスプールの現在の状態を返す
+func (m *Manager) UploadStats() UploadStats {
+	s := m.spool
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return UploadStats{
+		SpoolDepth:    len(s.entries),
+		SpoolCapacity: s.max,
+		Queued:        len(s.queued),
+		InFlight:      len(s.inFlight),
+		Workers:       s.workers,
+		UploadedTotal: s.uploaded,
+		FailedTotal:   s.failed,
+	}
+}
+
+func (m *Manager) spoolEntryDir(jobID string) string {
+	return filepath.Join(m.getSpoolDir(), jobID)
+}
+
+func (m *Manager) getSpoolDir() string {
+	if m.spool.dir != "" {
+		return m.spool.dir
+	}
+	return filepath.Join(m.storageDir, "upload_spool")
+}
+
+// StartUploadWorkers アップロードワーカーを起動し、前回の停止で残った成果物を再アップロードする
+// 以降はintervalごとにスプールを走査して失敗したエントリを再投入する
+func (m *Manager) StartUploadWorkers(interval time.Duration) {
+	if m.r2 == nil {
+		return
+	}
+	s := m.spool
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.queue = make(chan string, s.max)
+	s.mu.Unlock()
+
+	for i := 0; i < s.workers; i++ {
+		go m.uploadWorker()
+	}
+
+	go func() {
+		m.ResumePendingUploads()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.ResumePendingUploads()
+		}
+	}()
+}
+
+// waitForSpoolCapacity スプールが上限近くまで埋まっている間、新しいPython実行を待機させる
+// アップロードを優先し、スプールがあふれないようにする（待機中にctxがキャンセルされた場合はctx.Err()）
+func (m *Manager) waitForSpoolCapacity(ctx context.Context) error {
+	if m.r2 == nil {
+		return nil
+	}
+	s := m.spool
+	// キャンセル・削除されたジョブの待機を起こす（s.muを取ってから通知し、Waitに入る前の通知を取りこぼさない）
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	})
+	defer stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	highWatermark := s.max * spoolHighWatermarkPercent / 100
+	if highWatermark < 1 {
+		highWatermark = 1
+	}
+	if s.started && len(s.entries) >= highWatermark {
+		logging.Infof("Upload spool near capacity (%d/%d), waiting before starting new analysis", len(s.entries), s.max)
+		for len(s.entries) >= highWatermark {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			s.cond.Wait()
+		}
+	}
+	return ctx.Err()
+}
+
+// spoolOutputs Python処理の出力をスプールディレクトリにコピーする
+// サーバーがアップロード前に停止しても成果物が失われないようにする
+func (m *Manager) spoolOutputs(jobID, jobDir string, layout *StorageLayout, metrics map[string]interface{}) (string, error) {
+	s := m.spool
+	entryDir := m.spoolEntryDir(jobID)
+
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return "", fmt.Errorf("upload workers not started")
+	}
+	if len(s.entries) >= s.max {
+		s.mu.Unlock()
+		return "", fmt.Errorf("upload spool is full (%d entries)", s.max)
+	}
+	s.mu.Unlock()
+
+	if err := os.MkdirAll(entryDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	// 登録されている成果物（R2にアップロードする対象）をスプールに保存する
+	for _, name := range artifactFiles() {
+		src := filepath.Join(jobDir, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(entryDir, name)); err != nil {
+			os.RemoveAll(entryDir)
+			return "", fmt.Errorf("failed to spool %s: %w", name, err)
+		}
+	}
+	if err := m.spoolPDBFiles(jobDir, entryDir); err != nil {
+		os.RemoveAll(entryDir)
+		return "", fmt.Errorf("failed to spool PDB files: %w", err)
+	}
+
+	// メタデータは最後に書き込む（メタデータのないエントリは書き込み途中とみなす）
+	meta := spoolMeta{
+		JobID:         jobID,
+		Metrics:       metrics,
+		SpooledAt:     time.Now(),
+		StorageLayout: layout.Version,
+	}
+	if err := writeSpoolMeta(entryDir, &meta); err != nil {
+		os.RemoveAll(entryDir)
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.entries[entryDir] = meta.SpooledAt
+	s.mu.Unlock()
+
+	return entryDir, nil
+}
+
+// enqueueUpload スプールエントリをアップロードキューに投入する（投入済み・アップロード中なら何もしない）
+func (m *Manager) enqueueUpload(entryDir string) bool {
+	s := m.spool
+	s.mu.Lock()
+	if s.queued[entryDir] || s.inFlight[entryDir] {
+		s.mu.Unlock()
+		return false
+	}
+	s.queued[entryDir] = true
+	s.mu.Unlock()
+
+	s.queue <- entryDir
+	return true
+}
+
+func (m *Manager) uploadWorker() {
+	s := m.spool
+	for entryDir := range s.queue {
+		s.mu.Lock()
+		delete(s.queued, entryDir)
+		s.inFlight[entryDir] = true
+		s.mu.Unlock()
+
+		jobID := filepath.Base(entryDir)
+		err := m.uploadSpooled(entryDir)
+
+		s.mu.Lock()
+		delete(s.inFlight, entryDir)
+		if err != nil {
+			s.failed++
+		} else {
+			s.uploaded++
+			delete(s.entries, entryDir)
+			s.cond.Broadcast()
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			logging.Job(jobID).Warnf("Failed to upload artifacts for %s, kept in spool for retry: %v", jobID, err)
+		}
+		m.completeUploadedJob(jobID, entryDir, err)
+	}
+}
+
+// completeUploadedJob アップロード完了（または失敗）時に、実行中のジョブを完了状態にする
+func (m *Manager) completeUploadedJob(jobID, entryDir string, uploadErr error) {
+	m.mu.RLock()
+	job, exists := m.jobs[jobID]
+	running := exists && job.Status == StatusRunning
+	m.mu.RUnlock()
+	if !running {
+		// 前回起動時のジョブ、またはキャンセル・削除済みのジョブ
+		return
+	}
+
+	if uploadErr != nil && m.db != nil {
+		// アップロードに失敗しても解析自体は成功しているので、キーなしで完了させる
+		// キーはスプールの再試行で後から設定される
+		if meta, err := readSpoolMeta(entryDir); err == nil {
+			if err := m.db.CompleteAnalysis(jobID, meta.Metrics, "", "", "", "", ""); err != nil {
+				logging.Warnf("Failed to update analysis in DB: %v", err)
+			}
+		}
+	}
+	m.updateJobStatus(job, StatusDone, 100, "Analysis completed successfully")
+}
+
+// uploadSpooled スプールエントリをR2にアップロードし、DBのキーを設定する
+// 成功した場合はエントリを削除する
+func (m *Manager) uploadSpooled(entryDir string) error {
+	meta, err := readSpoolMeta(entryDir)
+	if err != nil {
+		return err
+	}
+
+	layout := storageLayout(meta.StorageLayout)
+	if err := m.uploadToR2(layout, meta.JobID, entryDir); err != nil {
+		meta.Attempts++
+		meta.LastError = err.Error()
+		m.recordEvent(meta.JobID, JobEvent{Type: EventUploadRetry, Message: err.Error(), Data: map[string]interface{}{"attempts": meta.Attempts}})
+		if werr := writeSpoolMeta(entryDir, meta); werr != nil {
+			logging.Warnf("Failed to update spool metadata for %s: %v", meta.JobID, werr)
+		}
+		return err
+	}
+
+	if m.db != nil {
+		r2Prefix, resultKey, heatmapKey, scatterKey, logsKey := artifactKeys(layout, meta.JobID, entryDir)
+		if err := m.db.CompleteAnalysis(meta.JobID, meta.Metrics, r2Prefix, resultKey, heatmapKey, scatterKey, logsKey); err != nil {
+			// DBにキーが保存されるまではスプールを残して再試行する
+			return fmt.Errorf("failed to update analysis keys in DB: %w", err)
+		}
+	}
+
+	if err := os.RemoveAll(entryDir); err != nil {
+		logging.Warnf("Failed to remove spool entry %s: %v", entryDir, err)
+	}
+	return nil
+}
+
+// forgetSpoolEntry 削除されたジョブのスプールエントリを破棄する
+func (m *Manager) forgetSpoolEntry(jobID string) {
+	entryDir := m.spoolEntryDir(jobID)
+	if err := os.RemoveAll(entryDir); err != nil {
+		logging.Job(jobID).Warnf("Failed to remove spool entry for %s: %v", jobID, err)
+	}
+
+	s := m.spool
+	s.mu.Lock()
+	delete(s.entries, entryDir)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// ResumePendingUploads スプールに残っている成果物を古い順にアップロードキューへ投入する
+func (m *Manager) ResumePendingUploads() {
+	if m.r2 == nil {
+		return
+	}
+
+	dirEntries, err := os.ReadDir(m.getSpoolDir())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warnf("Failed to read spool directory: %v", err)
+		}
+		return
+	}
+
+	type pending struct {
+		dir       string
+		spooledAt time.Time
+	}
+	pendings := make([]pending, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		if !entry.IsDir() {
+			continue
+		}
+		entryDir := filepath.Join(m.getSpoolDir(), entry.Name())
+		meta, err := readSpoolMeta(entryDir)
+		if err != nil {
+			// 書き込み途中のエントリはスキップ
+			continue
+		}
+		pendings = append(pendings, pending{dir: entryDir, spooledAt: meta.SpooledAt})
+	}
+
+	// 古いものから処理する
+	sort.Slice(pendings, func(i, j int) bool {
+		return pendings[i].spooledAt.Before(pendings[j].spooledAt)
+	})
+
+	s := m.spool
+	for _, p := range pendings {
+		s.mu.Lock()
+		s.entries[p.dir] = p.spooledAt
+		s.mu.Unlock()
+		if m.enqueueUpload(p.dir) {
+			logging.Infof("Queued pending upload: %s", filepath.Base(p.dir))
+		}
+	}
+}
+
+// artifactKeys ディレクトリ内のファイルに対応する保存形式でのR2キーを返す（logs.txtは存在する場合のみ）
+func artifactKeys(layout *StorageLayout, jobID, dir string) (r2Prefix, resultKey, heatmapKey, scatterKey, logsKey string) {
+	r2Prefix = layout.Prefix(jobID)
+	resultKey = artifactKeyIn(layout, jobID, "result.json")
+	heatmapKey = artifactKeyIn(layout, jobID, "heatmap.png")
+	scatterKey = artifactKeyIn(layout, jobID, "dist_score.png")
+	if _, err := os.Stat(filepath.Join(dir, jobLogFile)); err == nil {
+		logsKey = artifactKeyIn(layout, jobID, jobLogFile)
+	}
+	return
+}
+
+func readSpoolMeta(entryDir string) (*spoolMeta, error) {
+	data, err := os.ReadFile(filepath.Join(entryDir, spoolMetaFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool metadata: %w", err)
+	}
+	var meta spoolMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse spool metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+func writeSpoolMeta(entryDir string, meta *spoolMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 書き込み途中で停止しても壊れないように一時ファイル経由で置き換える
+	tmpPath := filepath.Join(entryDir, spoolMetaFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write spool metadata: %w", err)
+	}
+	return os.Rename(tmpPath, filepath.Join(entryDir, spoolMetaFile))
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
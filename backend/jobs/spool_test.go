@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/storage"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newFullSpoolManager スプールが上限まで埋まったManager（アップロードワーカーは起動しない）
+func newFullSpoolManager(t *testing.T) *Manager {
+	t.Helper()
+	m := NewManagerWithPersistence(t.TempDir(), "python3", 1, nil, &storage.GuardedR2Client{})
+	m.SetUploadPool(1, 1)
+	m.spool.mu.Lock()
+	m.spool.started = true
+	m.spool.entries["pending"] = time.Now()
+	m.spool.mu.Unlock()
+	return m
+}
+
+func TestWaitForSpoolCapacityReturnsOnCancel(t *testing.T) {
+	m := newFullSpoolManager(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.waitForSpoolCapacity(ctx) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("waitForSpoolCapacity returned %v while the spool is full", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("waitForSpoolCapacity returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForSpoolCapacity did not return after the context was cancelled")
+	}
+}
+
+func TestCancelJobWaitingForSpoolCapacity(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// executeJobがスプールの待機に入ってからキャンセルする
+		waitForExecution bool
+	}{
+		{name: "before execution starts"},
+		{name: "while waiting", waitForExecution: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newFullSpoolManager(t)
+			params, err := ParseAnalysisParams(nil)
+			if err != nil {
+				t.Fatalf("ParseAnalysisParams: %v", err)
+			}
+			job, err := m.CreateJob("P69905", params)
+			if err != nil {
+				t.Fatalf("CreateJob: %v", err)
+			}
+			if tc.waitForExecution {
+				waitForCancelFunc(t, m, job.ID)
+			}
+			if err := m.CancelJob(job.ID); err != nil {
+				t.Fatalf("CancelJob: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := m.WaitSettled(ctx, job.ID); err != nil {
+				t.Fatalf("job waiting for spool capacity did not settle after cancel: %v", err)
+			}
+			got, err := m.GetJob(job.ID)
+			if err != nil {
+				t.Fatalf("GetJob: %v", err)
+			}
+			if got.Status != StatusCancelled {
+				t.Errorf("status = %s, want cancelled", got.Status)
+			}
+		})
+	}
+}
+
+// waitForCancelFunc executeJobがキャンセル関数を登録する（スプールの待機に入る直前）まで待つ
+func waitForCancelFunc(t *testing.T, m *Manager, id string) {
+	t.Helper()
+	m.mu.RLock()
+	job := m.jobs[id]
+	m.mu.RUnlock()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job.mu.Lock()
+		registered := job.cancel != nil
+		job.mu.Unlock()
+		if registered {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not start executing within 5s", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"time"
+)
+
+// JobUpdate ジョブ状態の変更通知（WebSocket等で配信）
+type JobUpdate struct {
+	JobID        string    `json:"job_id"`
+	UniProtID    string    `json:"uniprot_id"`
+	SessionID    string    `json:"-"`
+	Status       JobStatus `json:"status"`
+	Progress     int       `json:"progress"`
+	Message      string    `json:"message"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// 残り時間の推定（秒、実行中のみ）
+	ETASeconds *int `json:"eta_seconds,omitempty"`
+}
+
+// 購読者ごとのバッファサイズ（溢れた通知は破棄する）
+const subscriberBufferSize = 64
+
+// Subscribe ジョブ状態の変更通知を購読する
+// 返り値の関数を呼ぶと購読を解除する
+func (m *Manager) Subscribe() (<-chan JobUpdate, func()) {
+	ch := make(chan JobUpdate, subscriberBufferSize)
+
+	m.subMu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.subMu.Unlock()
+
+	unsubscribe := func() {
+		m.subMu.Lock()
+		if _, ok := m.subscribers[ch]; ok {
+			delete(m.subscribers, ch)
+			close(ch)
+		}
+		m.subMu.Unlock()
+	}
+	return ch, unsubscribe
+}
+
+// publish 全購読者に通知を送る（遅い購読者でジョブ処理をブロックしない）
+func (m *Manager) publish(update JobUpdate) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	for ch := range m.subscribers {
+		select {
+		case ch <- update:
+		default:
+			logging.Job(update.JobID).Warnf("Subscriber buffer full, dropping update for job %s", update.JobID)
+		}
+	}
+}
+
+// AddStatusListener ジョブ状態の変更を同期的に受け取るリスナーを登録する
+// Subscribeと異なり通知は破棄されない（Webhookなど取りこぼせない用途向け）
+// リスナーはm.muの外でジョブの更新と同期して呼ばれる（同じジョブの通知は順番通り）ため、すぐに戻ること
+func (m *Manager) AddStatusListener(listener func(JobUpdate)) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// notifyListeners 登録されたリスナーに通知する
+func (m *Manager) notifyListeners(update JobUpdate) {
+	m.subMu.Lock()
+	listeners := m.listeners
+	m.subMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(update)
+	}
+}
+
+// newJobUpdate ジョブから通知を作成する（m.muを保持した状態で呼ぶこと）
+func (m *Manager) newJobUpdate(job *Job) JobUpdate {
+	sessionID, _ := job.Params["session_id"].(string)
+	return JobUpdate{
+		JobID:        job.ID,
+		UniProtID:    job.UniProtID,
+		SessionID:    sessionID,
+		Status:       job.Status,
+		Progress:     job.Progress,
+		Message:      job.Message,
+		ErrorMessage: job.ErrorMessage,
+		UpdatedAt:    job.UpdatedAt,
+		ETASeconds:   m.etaSeconds(job, time.Now()),
+	}
+}
+
+// JobSnapshot ジョブの現在の状態を通知形式で取得する（購読開始時の初期状態用）
+// メモリ上のジョブは残り時間の推定を含めるため、コピーではなく本体からロックを保持して組み立てる
+func (m *Manager) JobSnapshot(jobID string) (*JobUpdate, error) {
+	m.mu.RLock()
+	if job, ok := m.jobs[jobID]; ok {
+		update := m.newJobUpdate(job)
+		m.mu.RUnlock()
+		return &update, nil
+	}
+	m.mu.RUnlock()
+
+	job, err := m.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	update := m.newJobUpdate(job)
+	return &update, nil
+}
+
+// SessionSnapshots セッションに属するメモリ上のジョブの現在の状態を取得する
+func (m *Manager) SessionSnapshots(sessionID string) []JobUpdate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	updates := make([]JobUpdate, 0)
+	for _, job := range m.jobs {
+		if sid, _ := job.Params["session_id"].(string); sid == sessionID {
+			updates = append(updates, m.newJobUpdate(job))
+		}
+	}
+	return updates
+}
@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DBがある場合の解析の一時ディレクトリ（<一時ディレクトリのルート>/dsa-job-<id>-<乱数>）
+const tempDirPrefix = "dsa-job-"
+
+// DefaultTempSweepAge 孤立した一時ディレクトリとみなすまでの時間（最終更新からの経過時間）
+const DefaultTempSweepAge = 24 * time.Hour
+
+// tempSweeper 一時ディレクトリのルートと、孤立した一時ディレクトリの掃除の累計
+type tempSweeper struct {
+	mu sync.Mutex
+	// 一時ディレクトリのルート（空の場合はos.TempDir()）
+	root   string
+	maxAge time.Duration
+	// 掃除した一時ディレクトリの数・解放したバイト数の累計
+	sweptTotal     int
+	reclaimedTotal int64
+	lastSweep      time.Time
+}
+
+// TempSweepStats 孤立した一時ディレクトリの掃除の累計（/metrics用）
+type TempSweepStats struct {
+	Root           string
+	SweptTotal     int
+	ReclaimedBytes int64
+	LastSweep      time.Time
+}
+
+// SetTempDir 解析の一時ディレクトリのルートを設定する（存在しない場合は作成する、空の場合はos.TempDir()）
+// 他のプログラムの一時ファイルと分けるため、専用のディレクトリを指定することを推奨する
+func (m *Manager) SetTempDir(root string) error {
+	if root != "" {
+		if err := os.MkdirAll(root, 0755); err != nil {
+			return fmt.Errorf("failed to create temp directory %s: %w", root, err)
+		}
+	}
+	m.temp.mu.Lock()
+	defer m.temp.mu.Unlock()
+	m.temp.root = root
+	return nil
+}
+
+// TempDir 解析の一時ディレクトリのルート
+func (m *Manager) TempDir() string {
+	m.temp.mu.Lock()
+	defer m.temp.mu.Unlock()
+	if m.temp.root == "" {
+		return os.TempDir()
+	}
+	return m.temp.root
+}
+
+// newJobTempDir ジョブの一時ディレクトリを作成する
+func (m *Manager) newJobTempDir(jobID string) (string, error) {
+	return os.MkdirTemp(m.TempDir(), fmt.Sprintf("%s%s-", tempDirPrefix, jobID))
+}
+
+// StartTempSweeper 起動時と定期的に、maxAgeより前から更新されていない孤立した一時ディレクトリを削除する（0以下はDefaultTempSweepAge）
+// 実行中のジョブの一時ディレクトリは経過時間に関係なく削除しない
+func (m *Manager) StartTempSweeper(maxAge, interval time.Duration) {
+	if maxAge <= 0 {
+		maxAge = DefaultTempSweepAge
+	}
+	m.temp.mu.Lock()
+	m.temp.maxAge = maxAge
+	m.temp.mu.Unlock()
+
+	go func() {
+		m.SweepTempDirs()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.SweepTempDirs()
+		}
+	}()
+}
+
+// SweepTempDirs 孤立した一時ディレクトリ（dsa-job-*）を削除し、削除した数と解放したバイト数を返す
+// プロセスが強制終了された場合などにdeferで削除されなかったものを対象にする
+func (m *Manager) SweepTempDirs() (int, int64) {
+	root := m.TempDir()
+	m.temp.mu.Lock()
+	maxAge := m.temp.maxAge
+	m.temp.mu.Unlock()
+	if maxAge <= 0 {
+		maxAge = DefaultTempSweepAge
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		logging.Warnf("Failed to read temp directory %s: %v", root, err)
+		return 0, 0
+	}
+	active := m.activeJobDirs()
+	cutoff := time.Now().Add(-maxAge)
+
+	swept := 0
+	var reclaimed int64
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), tempDirPrefix) {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if active[dir] {
+			continue
+		}
+		size, modTime := dirUsage(dir)
+		if modTime.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			logging.Warnf("Failed to remove orphaned temp directory %s: %v", dir, err)
+			continue
+		}
+		swept++
+		reclaimed += size
+	}
+
+	m.temp.mu.Lock()
+	m.temp.sweptTotal += swept
+	m.temp.reclaimedTotal += reclaimed
+	m.temp.lastSweep = time.Now()
+	m.temp.mu.Unlock()
+	if swept > 0 {
+		logging.Infof("Removed %d orphaned temp directories in %s (%d bytes reclaimed)", swept, root, reclaimed)
+	}
+	return swept, reclaimed
+}
+
+// TempSweepStats 孤立した一時ディレクトリの掃除の累計を返す
+func (m *Manager) TempSweepStats() TempSweepStats {
+	root := m.TempDir()
+	m.temp.mu.Lock()
+	defer m.temp.mu.Unlock()
+	return TempSweepStats{
+		Root:           root,
+		SweptTotal:     m.temp.sweptTotal,
+		ReclaimedBytes: m.temp.reclaimedTotal,
+		LastSweep:      m.temp.lastSweep,
+	}
+}
+
+// activeJobDirs メモリ上のジョブが使っている作業ディレクトリ
+func (m *Manager) activeJobDirs() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	dirs := make(map[string]bool)
+	for _, job := range m.jobs {
+		job.mu.Lock()
+		if job.dir != "" {
+			dirs[filepath.Clean(job.dir)] = true
+		}
+		job.mu.Unlock()
+	}
+	return dirs
+}
+
+// dirUsage ディレクトリ以下のファイルの合計サイズと最新の更新日時（長時間の解析で古いディレクトリを削除しないように中のファイルも見る）
+func dirUsage(dir string) (int64, time.Time) {
+	var size int64
+	var latest time.Time
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return size, latest
+}
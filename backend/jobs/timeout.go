@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+)
+
+// objectStoreTimeout R2への1回の呼び出しに許容する時間
+const objectStoreTimeout = 2 * time.Minute
+
+// SetDefaultTimeout ジョブのデフォルトのタイムアウトを設定する（0以下は無制限）
+func (m *Manager) SetDefaultTimeout(timeout time.Duration) {
+	m.defaultTimeout = timeout
+}
+
+// jobTimeout ジョブに適用するタイムアウトを返す
+// paramsのtimeout_secondsが指定されていればそれを優先し、なければサーバーのデフォルトを使う
+func (m *Manager) jobTimeout(job *Job) time.Duration {
+	if seconds := AnalysisParamsFromMap(job.params()).TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return m.defaultTimeout
+}
+
+// timeoutMessage タイムアウト時のエラーメッセージ
+func timeoutMessage(timeout time.Duration) string {
+	return fmt.Sprintf("Analysis timed out after %s", timeout)
+}
@@ -0,0 +1,250 @@
+package jobs
+
+import (
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UniProtのアクセッション番号の形式（https://www.uniprot.org/help/accession_numbers、アイソフォームの "-2" 等を含む）
+var uniprotAccessionPattern = regexp.MustCompile(`^(?:[OPQ][0-9][A-Z0-9]{3}[0-9]|[A-NR-Z][0-9](?:[A-Z][A-Z0-9]{2}[0-9]){1,2})(?:-[0-9]+)?$`)
+
+// UniProtの存在確認・タンパク質の情報の取得に使うREST API（GET <url>/<accession>、存在しない場合は404）
+const (
+	uniprotEntryURL     = "https://rest.uniprot.org/uniprotkb"
+	uniprotCheckTimeout = 10 * time.Second
+)
+
+// DBがない場合にタンパク質の情報を保存するファイル（storage/<id>/protein.json）
+const proteinInfoFile = "protein.json"
+
+// メモリに保持するタンパク質の情報の上限（超えたら全て破棄する）
+const proteinCacheLimit = 1000
+
+// NormalizeUniProtID 前後の空白を除いて大文字にする
+func NormalizeUniProtID(id string) string {
+	return strings.ToUpper(strings.TrimSpace(id))
+}
+
+// ValidUniProtID UniProtのアクセッション番号の形式か（大文字・小文字は区別しない）
+func ValidUniProtID(id string) bool {
+	return uniprotAccessionPattern.MatchString(strings.ToUpper(id))
+}
+
+// ResolveUniProtVariant アイソフォームの接尾辞（"P69905-2" の "-2"）をparams.isoformに移し、アクセッション番号を返す
+// params.isoformと接尾辞の両方が指定されて異なる場合は*ParamsErrorになる（idは検証済みのもの）
+func ResolveUniProtVariant(id string, params *AnalysisParams) (string, error) {
+	accession, suffix, found := strings.Cut(id, "-")
+	if !found {
+		return id, nil
+	}
+	isoform, err := strconv.Atoi(suffix)
+	if err != nil || isoform < 1 {
+		return "", &ParamsError{Errors: []ParamError{{Field: "isoform", Message: "must be >= 1"}}}
+	}
+	if params.Isoform != 0 && params.Isoform != isoform {
+		return "", &ParamsError{Errors: []ParamError{{Field: "isoform", Message: "conflicts with the isoform suffix of uniprot_id"}}}
+	}
+	params.Isoform = isoform
+	return accession, nil
+}
+
+// VariantID 解析対象を表すID（アイソフォームは "-2"、チェーンは ":A" を付ける、例: "P69905-2:A"）
+func VariantID(accession string, params AnalysisParams) string {
+	id := accession
+	if params.Isoform > 0 {
+		id += "-" + strconv.Itoa(params.Isoform)
+	}
+	if params.Chain != "" {
+		id += ":" + params.Chain
+	}
+	return id
+}
+
+// CheckUniProtExists UniProtにエントリが存在するか確認する（UniProtに接続できない場合はエラー）
+func CheckUniProtExists(ctx context.Context, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, uniprotCheckTimeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s/%s?fields=accession&format=json", uniprotEntryURL, url.PathEscape(strings.ToUpper(id)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to reach UniProt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest:
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected UniProt response: %s", resp.Status)
+}
+
+// uniprotEntry UniProt REST APIのレスポンスのうち使用する部分
+type uniprotEntry struct {
+	ProteinDescription struct {
+		RecommendedName *struct {
+			FullName struct {
+				Value string `json:"value"`
+			} `json:"fullName"`
+		} `json:"recommendedName"`
+		SubmissionNames []struct {
+			FullName struct {
+				Value string `json:"value"`
+			} `json:"fullName"`
+		} `json:"submissionNames"`
+	} `json:"proteinDescription"`
+	Organism struct {
+		ScientificName string `json:"scientificName"`
+	} `json:"organism"`
+	Sequence struct {
+		Length int `json:"length"`
+	} `json:"sequence"`
+}
+
+// FetchUniProtMetadata UniProtからタンパク質名・生物種・配列長を取得する（エントリが存在しない場合・接続できない場合はエラー）
+// 名前はRecommended name、ない場合（TrEMBL）は最初のSubmission nameを使う
+func FetchUniProtMetadata(ctx context.Context, id string) (*storage.ProteinInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, uniprotCheckTimeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s/%s?fields=protein_name,organism_name,length&format=json", uniprotEntryURL, url.PathEscape(strings.ToUpper(id)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach UniProt: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected UniProt response: %s", resp.Status)
+	}
+
+	var entry uniprotEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("failed to decode UniProt response: %w", err)
+	}
+	info := &storage.ProteinInfo{
+		Organism:       entry.Organism.ScientificName,
+		SequenceLength: entry.Sequence.Length,
+	}
+	if name := entry.ProteinDescription.RecommendedName; name != nil {
+		info.Name = name.FullName.Value
+	} else if len(entry.ProteinDescription.SubmissionNames) > 0 {
+		info.Name = entry.ProteinDescription.SubmissionNames[0].FullName.Value
+	}
+	return info, nil
+}
+
+// proteinCache アクセッション番号ごとのタンパク質の情報（同じタンパク質の解析で再取得しない）
+type proteinCache struct {
+	mu    sync.Mutex
+	infos map[string]*storage.ProteinInfo
+}
+
+func (c *proteinCache) get(id string) *storage.ProteinInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.infos[id]
+}
+
+func (c *proteinCache) put(id string, info *storage.ProteinInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.infos) >= proteinCacheLimit {
+		c.infos = make(map[string]*storage.ProteinInfo)
+	}
+	c.infos[id] = info
+}
+
+// SetUniProtMetadata ジョブ作成時にUniProtからタンパク質の情報を取得して解析に記録する（デフォルトは無効）
+func (m *Manager) SetUniProtMetadata(enabled bool) {
+	if !enabled {
+		m.proteins = nil
+		return
+	}
+	m.proteins = &proteinCache{infos: make(map[string]*storage.ProteinInfo)}
+}
+
+// enrichProtein タンパク質の情報を取得して解析に記録する（ジョブの作成・実行はブロックしない、失敗しても解析は続行する）
+func (m *Manager) enrichProtein(jobID, uniprotID string) {
+	info := m.proteins.get(uniprotID)
+	if info == nil {
+		fetched, err := FetchUniProtMetadata(m.ctx, uniprotID)
+		if err != nil {
+			logging.Warnf("Failed to fetch UniProt metadata for %s: %v", uniprotID, err)
+			return
+		}
+		info = fetched
+		m.proteins.put(uniprotID, info)
+	}
+
+	if m.db != nil {
+		if err := m.db.SetAnalysisProteinInfo(jobID, *info); err != nil {
+			logging.Job(jobID).Warnf("Failed to record protein metadata of %s (apply migrations/009_add_protein_metadata.sql): %v", jobID, err)
+			return
+		}
+	} else {
+		dir, _ := m.findLocalDir(jobID)
+		if dir == "" {
+			return
+		}
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, proteinInfoFile), data, 0644)
+		}
+		if err != nil {
+			logging.Job(jobID).Warnf("Failed to save protein metadata of %s: %v", jobID, err)
+			return
+		}
+	}
+	m.notifyChange(jobID, false)
+}
+
+// ProteinInfos 解析ごとのタンパク質の情報を返す（記録されていない解析は含まない、取得に失敗した場合は空）
+func (m *Manager) ProteinInfos(ctx context.Context, ids []string) map[string]storage.ProteinInfo {
+	if m.db != nil {
+		infos, err := m.db.ProteinInfos(ctx, ids)
+		if err != nil {
+			logging.Warnf("Failed to get protein metadata: %v", err)
+			return map[string]storage.ProteinInfo{}
+		}
+		return infos
+	}
+
+	infos := make(map[string]storage.ProteinInfo)
+	for _, id := range ids {
+		dir, _ := m.findLocalDir(id)
+		if dir == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, proteinInfoFile))
+		if err != nil {
+			continue
+		}
+		var info storage.ProteinInfo
+		if err := json.Unmarshal(data, &info); err == nil {
+			infos[id] = info
+		}
+	}
+	return infos
+}
@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"dsa-api/logging"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// pipelineVersionPattern dsa/__init__.pyの__version__
+var pipelineVersionPattern = regexp.MustCompile(`(?m)^__version__\s*=\s*["']([^"']+)["']`)
+
+// PipelineVersion ローカルのPythonパッケージ（dsa/__init__.py）の__version__を返す
+func (e *LocalPythonExecutor) PipelineVersion() (string, error) {
+	pythonDir, err := e.findPythonDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(pythonDir, "dsa", "__init__.py"))
+	if err != nil {
+		return "", err
+	}
+	match := pipelineVersionPattern.FindSubmatch(data)
+	if match == nil {
+		return "", fmt.Errorf("__version__ not found in dsa/__init__.py")
+	}
+	return string(match[1]), nil
+}
+
+// PipelineVersion 解析パイプラインのバージョン（ローカルで実行する場合のみ、分からない場合は空）
+// リモートワーカーで実行する場合はワーカーごとに異なるため返さない
+func (m *Manager) PipelineVersion() string {
+	local, ok := m.executor.(*LocalPythonExecutor)
+	if !ok {
+		return ""
+	}
+	version, err := local.PipelineVersion()
+	if err != nil {
+		logging.Warnf("Failed to read pipeline version: %v", err)
+		return ""
+	}
+	return version
+}
@@ -0,0 +1,104 @@
+// Package logging レベル付きの構造化ログ（log/slog）
+// 出力レベル（LOG_LEVEL）と形式（LOG_FORMAT=text|json）はSetupで設定し、
+// job_id・request_id等のフィールドはWithで付ける
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Logger フィールド付きのロガー（出力先・レベルは呼び出し時のslog.Default()に従う）
+type Logger struct {
+	args []any
+}
+
+var root = &Logger{}
+
+// Setup ログの出力レベル（debug・info・warn・error、空はinfo）と形式（text・json、空はtext）を設定する
+// 標準のlogパッケージの出力もinfoレベルとして同じ形式で出力する
+func Setup(level, format string) error {
+	return SetupWriter(os.Stdout, level, format)
+}
+
+// SetupWriter 出力先を指定してSetupと同じ設定を行う
+func SetupWriter(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		lvl = slog.LevelDebug
+	case "", "info":
+		lvl = slog.LevelInfo
+	case "warn", "warning":
+		lvl = slog.LevelWarn
+	case "error":
+		lvl = slog.LevelError
+	default:
+		return fmt.Errorf("invalid log level: %s (must be debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format: %s (must be text or json)", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	// slog.SetDefaultでlogパッケージの出力もslogに流れる（日時はslogが付ける）
+	log.SetFlags(0)
+	return nil
+}
+
+// With フィールド（キーと値の組）を付けたロガーを返す
+func With(args ...any) *Logger {
+	return root.With(args...)
+}
+
+// With フィールドを追加したロガーを返す
+func (l *Logger) With(args ...any) *Logger {
+	merged := make([]any, 0, len(l.args)+len(args))
+	merged = append(merged, l.args...)
+	merged = append(merged, args...)
+	return &Logger{args: merged}
+}
+
+// Logf レベルを指定して出力する（メッセージはfmt.Sprintfの形式）
+func (l *Logger) Logf(level slog.Level, format string, args ...any) {
+	logger := slog.Default()
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, fmt.Sprintf(format, args...), l.args...)
+}
+
+func (l *Logger) Debugf(format string, args ...any) { l.Logf(slog.LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...any)  { l.Logf(slog.LevelInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...any)  { l.Logf(slog.LevelWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...any) { l.Logf(slog.LevelError, format, args...) }
+
+// Debugf フィールドなしで出力する
+func Debugf(format string, args ...any) { root.Logf(slog.LevelDebug, format, args...) }
+
+// Infof フィールドなしで出力する
+func Infof(format string, args ...any) { root.Logf(slog.LevelInfo, format, args...) }
+
+// Warnf フィールドなしで出力する
+func Warnf(format string, args ...any) { root.Logf(slog.LevelWarn, format, args...) }
+
+// Errorf フィールドなしで出力する
+func Errorf(format string, args ...any) { root.Logf(slog.LevelError, format, args...) }
+
+// Job ジョブ（解析）のIDをjob_idとして付けたロガーを返す
+func Job(id string) *Logger {
+	return root.With("job_id", id)
+}
@@ -1,12 +1,29 @@
 package main
 
 import (
+	"context"
+	"dsa-api/alerts"
 	"dsa-api/api"
+	"dsa-api/auth"
+	"dsa-api/grpcapi"
+	"dsa-api/intake"
 	"dsa-api/jobs"
+	"dsa-api/logging"
+	"dsa-api/notify"
+	"dsa-api/search"
 	"dsa-api/storage"
+	"dsa-api/storage/pdbcache"
+	"dsa-api/webhooks"
+	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -16,6 +33,11 @@ import (
 func main() {
 	// .envファイルを読み込む（エラーは無視）
 	godotenv.Load()
+
+	// ログのレベル（debug・info・warn・error）と形式（text・json）
+	if err := logging.Setup(os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT")); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
 	
 	// 環境変数から設定を取得
 	storageDir := os.Getenv("STORAGE_DIR")
@@ -35,11 +57,11 @@ func main() {
 		log.Fatalf("Failed to resolve storage directory: %v", err)
 	}
 	
-	log.Printf("[DEBUG] Working directory: %s", func() string {
+	logging.Debugf("Working directory: %s", func() string {
 		wd, _ := os.Getwd()
 		return wd
 	}())
-	log.Printf("[DEBUG] Storage directory: %s", storageDir)
+	logging.Debugf("Storage directory: %s", storageDir)
 
 	pythonPath := os.Getenv("PYTHON_PATH")
 	if pythonPath == "" {
@@ -50,10 +72,10 @@ func main() {
 		venvPythonAbs, _ := filepath.Abs(venvPython)
 		if _, err := os.Stat(venvPythonAbs); err == nil {
 			pythonPath = venvPythonAbs
-			log.Printf("[DEBUG] Using virtual environment Python: %s", pythonPath)
+			logging.Debugf("Using virtual environment Python: %s", pythonPath)
 		} else {
 			pythonPath = "python3"
-			log.Printf("[DEBUG] Virtual environment not found at %s, using system Python: %s", venvPythonAbs, pythonPath)
+			logging.Debugf("Virtual environment not found at %s, using system Python: %s", venvPythonAbs, pythonPath)
 		}
 	}
 
@@ -70,7 +92,7 @@ func main() {
 
 	// DBとR2クライアントの初期化（オプショナル）
 	var db *storage.DB
-	var r2 *storage.R2Client
+	var r2 *storage.GuardedR2Client
 
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL != "" {
@@ -80,23 +102,48 @@ func main() {
 			log.Fatalf("Failed to connect to database: %v", err)
 		}
 		defer db.Close()
-		log.Printf("Connected to database")
+		logging.Infof("Connected to database")
 	}
 
-	r2AccountID := os.Getenv("R2_ACCOUNT_ID")
-	r2AccessKeyID := os.Getenv("R2_ACCESS_KEY_ID")
-	r2SecretAccessKey := os.Getenv("R2_SECRET_ACCESS_KEY")
-	r2Bucket := os.Getenv("R2_BUCKET")
-	r2Endpoint := os.Getenv("R2_ENDPOINT")
-	r2PublicBase := os.Getenv("R2_PUBLIC_BASE_URL")
-
-	if r2AccountID != "" && r2AccessKeyID != "" && r2SecretAccessKey != "" && r2Bucket != "" && r2Endpoint != "" {
-		var err error
-		r2, err = storage.NewR2Client(r2AccountID, r2AccessKeyID, r2SecretAccessKey, r2Bucket, r2Endpoint, r2PublicBase)
-		if err != nil {
-			log.Fatalf("Failed to create R2 client: %v", err)
+	// 成果物の保存先（STORAGE_BACKEND=r2|s3|gcs|minio|local、デフォルトはr2）
+	// 保存先の種類に関係なく、以降のコードではR2として扱う
+	storageBackend := os.Getenv("STORAGE_BACKEND")
+	if storageBackend == "" {
+		storageBackend = storage.BackendR2
+	}
+	objectStore, err := newObjectStore(storageBackend, storageDir)
+	if err != nil {
+		log.Fatalf("Failed to create %s object store: %v", storageBackend, err)
+	}
+	if objectStore != nil {
+		// サーキットブレーカー（連続失敗で一定時間R2呼び出しを遮断し、縮退運転する）
+		threshold, _ := strconv.Atoi(os.Getenv("R2_BREAKER_THRESHOLD"))
+		cooldown, _ := time.ParseDuration(os.Getenv("R2_BREAKER_COOLDOWN"))
+		breaker := storage.NewCircuitBreaker("r2", threshold, cooldown)
+		r2 = storage.NewGuardedR2Client(objectStore, breaker, os.Getenv("OBJECT_CACHE_DIR"))
+		// パートサイズ以上のアップロードはマルチパートにしてパートごとに再試行する（R2_MULTIPART_PART_SIZE=16MB、R2_UPLOAD_RETRIES=3）
+		var multipart storage.MultipartOptions
+		if v := os.Getenv("R2_MULTIPART_PART_SIZE"); v != "" {
+			if n, ok := parseByteSize(v); ok {
+				multipart.PartSize = n
+			} else {
+				logging.Warnf("Invalid R2_MULTIPART_PART_SIZE: %s, using default", v)
+			}
+		}
+		if v := os.Getenv("R2_UPLOAD_RETRIES"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil {
+				// 0は再試行しない
+				multipart.Retries = n
+				if n == 0 {
+					multipart.Retries = -1
+				}
+			} else {
+				logging.Warnf("Invalid R2_UPLOAD_RETRIES: %s, using default", v)
+			}
 		}
-		log.Printf("R2 client initialized")
+		r2.SetMultipartOptions(multipart)
+		r2.StartHealthProbe(15 * time.Second)
+		logging.Infof("Object store initialized (backend: %s)", storageBackend)
 	}
 
 	// ジョブマネージャーの作成
@@ -104,39 +151,639 @@ func main() {
 	if db != nil {
 		if r2 != nil {
 			jobManager = jobs.NewManagerWithPersistence(storageDir, pythonPath, maxConcurrent, db, r2)
-			log.Printf("Job manager created with persistence (DB + R2)")
+			logging.Infof("Job manager created with persistence (DB + R2)")
 		} else {
 			// DBだけでも保存できるようにする
 			jobManager = jobs.NewManagerWithPersistence(storageDir, pythonPath, maxConcurrent, db, nil)
-			log.Printf("Job manager created with persistence (DB only)")
+			logging.Infof("Job manager created with persistence (DB only)")
 		}
 	} else {
 		jobManager = jobs.NewManager(storageDir, pythonPath, maxConcurrent)
-		log.Printf("Job manager created without persistence")
+		logging.Infof("Job manager created without persistence")
 	}
 
+	// 新しい解析の保存形式（STORAGE_LAYOUT=2 でIDの先頭2文字で分けた形式、既存の解析は記録された形式のまま）
+	if v := os.Getenv("STORAGE_LAYOUT"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err == nil {
+			err = jobManager.SetStorageLayout(version)
+		}
+		if err != nil {
+			logging.Warnf("Invalid STORAGE_LAYOUT: %s, using layout %d", v, jobs.DefaultStorageLayout)
+		} else {
+			logging.Infof("Storage layout for new analyses: %d (%s)", version, jobManager.StorageLayout().Name)
+		}
+	}
+
+	// R2アップロード待ちの成果物を再アップロード（前回の停止で中断された分を含む）
+	if spoolDir := os.Getenv("UPLOAD_SPOOL_DIR"); spoolDir != "" {
+		jobManager.SetSpoolDir(spoolDir)
+	}
+	uploadWorkers, _ := strconv.Atoi(os.Getenv("UPLOAD_WORKERS"))
+	spoolMax, _ := strconv.Atoi(os.Getenv("UPLOAD_SPOOL_MAX"))
+	jobManager.SetUploadPool(uploadWorkers, spoolMax)
+	jobManager.StartUploadWorkers(5 * time.Minute)
+	// 削除した解析のR2のオブジェクト・ローカルの成果物の後片付け（失敗した場合は再試行）
+	jobManager.StartDeletionWorker(time.Minute)
+
+	// 解析の一時ディレクトリ（DBがある場合、TEMP_DIR未設定時はOSの一時ディレクトリ）と孤立した一時ディレクトリの掃除
+	if err := jobManager.SetTempDir(os.Getenv("TEMP_DIR")); err != nil {
+		logging.Warnf("%v, using %s", err, os.TempDir())
+	}
+	var tempSweepAge time.Duration
+	if v := os.Getenv("TEMP_SWEEP_AGE"); v != "" {
+		if d, ok := parseDuration(v); ok && d > 0 {
+			tempSweepAge = d
+		} else {
+			logging.Warnf("Invalid TEMP_SWEEP_AGE: %s, using default %s", v, jobs.DefaultTempSweepAge)
+		}
+	}
+	tempSweepInterval := time.Hour
+	if v := os.Getenv("TEMP_SWEEP_INTERVAL"); v != "" {
+		if d, ok := parseDuration(v); ok && d > 0 {
+			tempSweepInterval = d
+		} else {
+			logging.Warnf("Invalid TEMP_SWEEP_INTERVAL: %s, using default %s", v, tempSweepInterval)
+		}
+	}
+	jobManager.StartTempSweeper(tempSweepAge, tempSweepInterval)
+	// 待ち行列を一時停止したまま再起動した場合は停止した状態を引き継ぐ（POST /api/admin/queue/resume で再開）
+	jobManager.LoadQueuePauseState()
+
+	// ジョブのデフォルトのタイムアウト（JOB_TIMEOUT=3600 または 1h のように指定）
+	if v := os.Getenv("JOB_TIMEOUT"); v != "" {
+		if d, ok := parseDuration(v); ok {
+			jobManager.SetDefaultTimeout(d)
+		} else {
+			logging.Warnf("Invalid JOB_TIMEOUT: %s, jobs will run without timeout", v)
+		}
+	}
+
+	// 結果キャッシュ（RESULT_CACHE_TTL=24h 等、同じ条件の完了した解析を再利用する）
+	if v := os.Getenv("RESULT_CACHE_TTL"); v != "" {
+		if d, ok := parseDuration(v); ok {
+			jobManager.SetResultCacheTTL(d)
+		} else {
+			logging.Warnf("Invalid RESULT_CACHE_TTL: %s, result cache is disabled", v)
+		}
+	}
+
+	// ジョブ作成時にUniProtからタンパク質名・生物種・配列長を取得する（UNIPROT_METADATA=true、一覧の表示用）
+	if os.Getenv("UNIPROT_METADATA") == "true" {
+		jobManager.SetUniProtMetadata(true)
+		logging.Infof("UniProt metadata enrichment enabled")
+	}
+
+	// 成果物の保持期間（ARTIFACT_RETENTION_DAYS=90 等、過ぎた解析はR2の成果物のみ削除しDBのサマリーは残す）
+	if v := os.Getenv("ARTIFACT_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days > 0 {
+			jobManager.SetArtifactRetention(time.Duration(days) * 24 * time.Hour)
+		} else {
+			logging.Warnf("Invalid ARTIFACT_RETENTION_DAYS: %s, artifacts will be kept forever", v)
+		}
+	}
+	// 解析の保持期間（RETENTION_DAYS=365 等、過ぎた解析はローカル・R2・DBからすべて削除、固定された解析は対象外）
+	if v := os.Getenv("RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days > 0 {
+			jobManager.SetRetention(time.Duration(days) * 24 * time.Hour)
+		} else {
+			logging.Warnf("Invalid RETENTION_DAYS: %s, analyses will be kept forever", v)
+		}
+	}
+	jobManager.StartJanitor(time.Hour)
+
+	// 成果物の長期保存先（ARCHIVE_BUCKET等、機関のS3 Glacier等をR2とは別の認証情報で使う）
+	if archiveBucket := os.Getenv("ARCHIVE_BUCKET"); archiveBucket != "" {
+		archiveClient, err := storage.NewArchiveClient(storage.ArchiveConfig{
+			Bucket:          archiveBucket,
+			Region:          os.Getenv("ARCHIVE_REGION"),
+			AccessKeyID:     os.Getenv("ARCHIVE_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("ARCHIVE_SECRET_ACCESS_KEY"),
+			Endpoint:        os.Getenv("ARCHIVE_ENDPOINT"),
+			StorageClass:    os.Getenv("ARCHIVE_STORAGE_CLASS"),
+			Prefix:          os.Getenv("ARCHIVE_PREFIX"),
+		})
+		if err != nil {
+			logging.Warnf("Failed to create archive client: %v, archiving is disabled", err)
+		} else {
+			restoreDays := 0
+			if v := os.Getenv("ARCHIVE_RESTORE_DAYS"); v != "" {
+				if days, err := strconv.Atoi(v); err == nil && days > 0 {
+					restoreDays = days
+				} else {
+					logging.Warnf("Invalid ARCHIVE_RESTORE_DAYS: %s, using default", v)
+				}
+			}
+			if err := jobManager.SetArchive(archiveClient, restoreDays, os.Getenv("ARCHIVE_RESTORE_TIER")); err != nil {
+				logging.Warnf("Invalid archive configuration: %v, archiving is disabled", err)
+			} else if !jobManager.ArchiveEnabled() {
+				logging.Warnf("ARCHIVE_BUCKET is set but archiving requires DATABASE_URL and R2, archiving is disabled")
+			} else {
+				checkInterval := 15 * time.Minute
+				if v := os.Getenv("ARCHIVE_CHECK_INTERVAL"); v != "" {
+					if d, ok := parseDuration(v); ok && d > 0 {
+						checkInterval = d
+					} else {
+						logging.Warnf("Invalid ARCHIVE_CHECK_INTERVAL: %s, using %s", v, checkInterval)
+					}
+				}
+				jobManager.StartArchiveWorker(checkInterval)
+				logging.Infof("Archive enabled (bucket: %s, storage class: %s, restore check interval: %s)", archiveBucket, archiveClient.StorageClass(), checkInterval)
+			}
+		}
+	}
+	// 構造数の区分ごとの所要時間の平均（eta_secondsの推定用）
+	jobManager.LoadDurationStats()
+
+	// DB・ローカル・R2の定期的な照合（RECONCILE_INTERVAL=24h、RECONCILE_CLEAN=true で孤立したものを掃除する）
+	if v := os.Getenv("RECONCILE_INTERVAL"); v != "" {
+		if d, ok := parseDuration(v); ok && d > 0 {
+			clean := os.Getenv("RECONCILE_CLEAN") == "true"
+			jobManager.StartReconciler(d, clean)
+			logging.Infof("Storage reconciliation enabled (interval: %s, clean: %v)", d, clean)
+		} else {
+			logging.Warnf("Invalid RECONCILE_INTERVAL: %s, storage will not be reconciled periodically", v)
+		}
+	}
+
+	// Pythonプロセスの資源の制限（JOB_NICE=10、JOB_MAX_MEMORY_MB=4096、JOB_THREADS=2 等、ジョブのparamsでさらに厳しくできる）
+	var limits jobs.ResourceLimits
+	for name, dst := range map[string]*int{"JOB_NICE": &limits.Nice, "JOB_MAX_MEMORY_MB": &limits.MaxMemoryMB, "JOB_THREADS": &limits.Threads} {
+		if v := os.Getenv(name); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				*dst = n
+			} else {
+				logging.Warnf("Invalid %s: %s, ignoring", name, v)
+			}
+		}
+	}
+	jobManager.SetResourceLimits(limits)
+
+	// ジョブのparams.envで上書きできる環境変数（JOB_ENV_ALLOWLIST=HTTPS_PROXY,NO_PROXY,DSA_DEBUG 等、未設定時は上書き不可）
+	if v := os.Getenv("JOB_ENV_ALLOWLIST"); v != "" {
+		jobManager.SetEnvAllowlist(strings.Split(v, ","))
+		logging.Infof("Per-job env overrides allowed: %s", strings.Join(jobManager.EnvAllowlist(), ", "))
+	}
+
+	// 解析終了時に作業ディレクトリ（ダウンロード済みのPDBファイル等）をR2に保存し、再実行時に再開できるようにする
+	if os.Getenv("CHECKPOINT_UPLOAD") == "true" {
+		jobManager.SetCheckpointUpload(true)
+	}
+
+	// 解析完了時にPythonがダウンロードしたPDBファイルをR2に保存し、作業ディレクトリの削除後もGET /api/jobs/:id/pdb/:pdbidで取得できるようにする
+	if os.Getenv("PDB_UPLOAD") == "true" {
+		jobManager.SetPDBUpload(true)
+	}
+
+	// ジョブの種類ごとに実行枠の一部を予約する（JOB_CLASS_RESERVATIONS=interactive=0.25,admin=0.1 等、割合は実行枠の数に対して切り上げ）
+	// 管理者の再計算・キューからのバッチ投入が実行枠をすべて使って、画面からの投入が待たされないようにする
+	if v := os.Getenv("JOB_CLASS_RESERVATIONS"); v != "" {
+		if reservations, err := jobs.ParseClassReservations(v, maxConcurrent); err == nil {
+			if err := jobManager.SetClassReservations(reservations); err != nil {
+				logging.Warnf("Invalid JOB_CLASS_RESERVATIONS: %v, ignoring", err)
+			} else {
+				logging.Infof("Job class reservations: %s", strings.Join(jobManager.ClassReservations(), ", "))
+			}
+		} else {
+			logging.Warnf("Invalid JOB_CLASS_RESERVATIONS: %v, ignoring", err)
+		}
+	}
+
+	// 1セッションが同時に使える実行枠の上限（未設定時は無制限、枠はセッション間でラウンドロビンに割り当て）
+	if v := os.Getenv("SESSION_MAX_CONCURRENT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			jobManager.SetSessionMaxConcurrent(n)
+		} else {
+			logging.Warnf("Invalid SESSION_MAX_CONCURRENT: %s, ignoring", v)
+		}
+	}
+
+	// キュー待ち・実行中のジョブ数の上限（超えた場合 POST /api/jobs は429を返す）
+	sessionQuota, globalQuota := 0, 0
+	if v := os.Getenv("SESSION_JOB_QUOTA"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			sessionQuota = n
+		} else {
+			logging.Warnf("Invalid SESSION_JOB_QUOTA: %s, ignoring", v)
+		}
+	}
+	if v := os.Getenv("GLOBAL_JOB_QUOTA"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			globalQuota = n
+		} else {
+			logging.Warnf("Invalid GLOBAL_JOB_QUOTA: %s, ignoring", v)
+		}
+	}
+	jobManager.SetJobQuotas(sessionQuota, globalQuota)
+
 	// ルーティングの設定
 	routes := api.NewRoutes(jobManager, db, r2)
 
+	// ルートのタイムアウト（ROUTE_TIMEOUT=30 または 30s、R2からの取得等はROUTE_TIMEOUT_LONG）
+	var routeTimeout, longRouteTimeout time.Duration
+	if v := os.Getenv("ROUTE_TIMEOUT"); v != "" {
+		if d, ok := parseDuration(v); ok && d > 0 {
+			routeTimeout = d
+		} else {
+			logging.Warnf("Invalid ROUTE_TIMEOUT: %s, using default", v)
+		}
+	}
+	if v := os.Getenv("ROUTE_TIMEOUT_LONG"); v != "" {
+		if d, ok := parseDuration(v); ok && d > 0 {
+			longRouteTimeout = d
+		} else {
+			logging.Warnf("Invalid ROUTE_TIMEOUT_LONG: %s, using default", v)
+		}
+	}
+	routes.SetRouteTimeouts(routeTimeout, longRouteTimeout)
+
+	// 読み取り専用モード（公開ミラー用、変更系APIを無効化）
+	if os.Getenv("READ_ONLY") == "true" {
+		routes.SetReadOnly(true)
+		logging.Infof("Read-only mode enabled (create/cancel/delete/rerun endpoints are disabled)")
+	}
+
+	// リモートワーカーモード（EXECUTOR=remote、解析はcmd/workerが別のマシンで実行する）
+	if os.Getenv("EXECUTOR") == "remote" {
+		workerToken := os.Getenv("WORKER_TOKEN")
+		if workerToken == "" {
+			log.Fatalf("WORKER_TOKEN is required when EXECUTOR=remote")
+		}
+		var leaseTimeout time.Duration
+		if v := os.Getenv("WORKER_LEASE_TIMEOUT"); v != "" {
+			if d, ok := parseDuration(v); ok && d > 0 {
+				leaseTimeout = d
+			} else {
+				logging.Warnf("Invalid WORKER_LEASE_TIMEOUT: %s, using default", v)
+			}
+		}
+		// 同時にワーカーへ割り当てるジョブ数（ワーカーの台数に合わせる）
+		if v := os.Getenv("REMOTE_MAX_JOBS"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				if err := jobManager.SetMaxConcurrent(n); err != nil {
+					logging.Warnf("Invalid REMOTE_MAX_JOBS: %v, ignoring", err)
+				}
+			} else {
+				logging.Warnf("Invalid REMOTE_MAX_JOBS: %s, ignoring", v)
+			}
+		}
+		remote := jobs.NewRemoteExecutor(leaseTimeout)
+		jobManager.SetExecutor(remote)
+		routes.SetRemoteWorkers(remote, workerToken)
+		logging.Infof("Remote worker mode enabled (jobs are executed by workers via /api/internal/jobs)")
+	}
+
+	// 外部連携用のAPIキー（カンマ区切り、ディープリンクからのジョブ作成に使用）
+	if v := os.Getenv("API_KEYS"); v != "" {
+		routes.SetAPIKeys(strings.Split(v, ","))
+	}
+
+	// AUTH_MODE=api_key: 内部サービスとして動かす（APIキー必須、セッションCookieを発行しない）
+	authMode := os.Getenv("AUTH_MODE")
+	if authMode == "api_key" {
+		if os.Getenv("API_KEYS") == "" {
+			log.Fatalf("AUTH_MODE=api_key requires API_KEYS")
+		}
+		routes.SetSessionless(true)
+		logging.Infof("Session-less API mode enabled (API key required, session features disabled)")
+	}
+
+	// 日付のみの絞り込み・日別の集計・Webhookのダイジェストの区切りのタイムゾーン（例: Asia/Tokyo、デフォルトはUTC）
+	defaultLocation := time.UTC
+	if v := os.Getenv("DEFAULT_TIMEZONE"); v != "" {
+		if loc, err := time.LoadLocation(v); err != nil {
+			logging.Warnf("Invalid DEFAULT_TIMEZONE %q, using UTC: %v", v, err)
+		} else {
+			routes.SetDefaultTimezone(loc)
+			defaultLocation = loc
+			logging.Infof("Default timezone: %s", loc)
+		}
+	}
+
+	// Webhook（WEBHOOKS_ENABLED=false で無効化）
+	var dispatcher *webhooks.Dispatcher
+	if os.Getenv("WEBHOOKS_ENABLED") != "false" {
+		d, err := webhooks.NewDispatcher(filepath.Join(storageDir, "webhooks"), db)
+		if err != nil {
+			logging.Warnf("Failed to initialize webhooks: %v", err)
+		} else {
+			dispatcher = d
+			maxAttempts, _ := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+			dispatcher.SetRetryPolicy(maxAttempts, 0)
+			dispatcher.SetDigestLocation(defaultLocation)
+			// 社内ネットワークの受信側に送る場合のみ WEBHOOK_ALLOW_PRIVATE_NETWORKS=true（デフォルトはループバック・プライベートなどのアドレスを拒否）
+			if os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true" {
+				dispatcher.SetAllowPrivateNetworks(true)
+				logging.Warnf("Webhooks may be delivered to loopback and private addresses")
+			}
+			dispatcher.Start(2)
+			jobManager.AddStatusListener(dispatcher.JobListener())
+			routes.SetWebhooks(dispatcher)
+		}
+	}
+
+	// アラートのルール（ALERTS_ENABLED=false で無効化、条件を満たしたルールはWebhookのalert.triggeredで通知する）
+	if os.Getenv("ALERTS_ENABLED") != "false" {
+		alertManager, err := alerts.NewManager(filepath.Join(storageDir, "alerts"), jobManager)
+		if err != nil {
+			logging.Warnf("Failed to initialize alerts: %v", err)
+		} else {
+			if dispatcher != nil {
+				alertManager.SetNotifier(func(sessionID string, data interface{}) {
+					dispatcher.Enqueue(webhooks.EventAlertTriggered, sessionID, data)
+				})
+			}
+			jobManager.AddStatusListener(alertManager.JobListener())
+			routes.SetAlerts(alertManager)
+		}
+	}
+
+	// 長時間のジョブの終了のメール通知（SMTP_HOST設定時のみ、ジョブ作成時のnotify_emailに送る）
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		smtpPort, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		notifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{
+			Host:     smtpHost,
+			Port:     smtpPort,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+			TLS:      os.Getenv("SMTP_TLS"),
+		})
+		var mailer *notify.Mailer
+		if err == nil {
+			mailer, err = notify.NewMailer(filepath.Join(storageDir, "notifications"), notifier)
+		}
+		if err != nil {
+			logging.Warnf("Email notifications disabled: %v", err)
+		} else {
+			// 作成から終了までの時間がNOTIFY_MIN_RUNTIMEより短いジョブは通知しない（デフォルト5分）
+			minRuntime := 5 * time.Minute
+			if v := os.Getenv("NOTIFY_MIN_RUNTIME"); v != "" {
+				if d, ok := parseDuration(v); ok && d >= 0 {
+					minRuntime = d
+				} else {
+					logging.Warnf("Invalid NOTIFY_MIN_RUNTIME: %s, using default (5m)", v)
+				}
+			}
+			mailer.SetMinRuntime(minRuntime)
+			// 結果のリンク（フロントエンドの/analysis/result）
+			resultURL := os.Getenv("FRONTEND_URL")
+			if resultURL == "" {
+				logging.Warnf("FRONTEND_URL is not set, notification emails will not include a link to the result")
+			}
+			mailer.SetResultURL(resultURL)
+			mailer.Start()
+			jobManager.AddStatusListener(mailer.JobListener())
+			routes.SetNotifications(mailer)
+			logging.Infof("Email notifications enabled via %s (minimum runtime %s)", smtpHost, minRuntime)
+		}
+	}
+
+	// Elasticsearch/OpenSearchへの解析のサマリー・メトリクスの反映（SEARCH_URL、DBが必要）
+	if searchURL := os.Getenv("SEARCH_URL"); searchURL != "" {
+		indexer, err := search.NewIndexer(search.Config{
+			URL:      searchURL,
+			Index:    os.Getenv("SEARCH_INDEX"),
+			Username: os.Getenv("SEARCH_USERNAME"),
+			Password: os.Getenv("SEARCH_PASSWORD"),
+			APIKey:   os.Getenv("SEARCH_API_KEY"),
+		}, db)
+		if err != nil {
+			logging.Warnf("Search indexer disabled: %v", err)
+		} else {
+			jobManager.AddChangeListener(indexer.Enqueue)
+			indexer.Start()
+			// インデックスを作成した場合とSEARCH_REINDEX=trueの場合は既存の解析をすべて反映する
+			go func() {
+				created, err := indexer.EnsureIndex(context.Background())
+				if err != nil {
+					logging.Warnf("Failed to prepare search index: %v", err)
+					return
+				}
+				if created || os.Getenv("SEARCH_REINDEX") == "true" {
+					n, err := indexer.Reindex(context.Background())
+					if err != nil {
+						logging.Warnf("Failed to reindex analyses: %v", err)
+					}
+					logging.Infof("Indexed %d analyses into %s", n, indexer.Index())
+				}
+			}()
+			logging.Infof("Search indexer enabled (index: %s)", indexer.Index())
+		}
+	}
+
+	// メッセージキュー（Redis Streams）からのジョブの受付（JOB_QUEUE_URL、HTTPのPOST /api/jobsと併用）
+	if queueURL := os.Getenv("JOB_QUEUE_URL"); queueURL != "" {
+		if os.Getenv("READ_ONLY") == "true" {
+			logging.Warnf("JOB_QUEUE_URL is ignored in read-only mode")
+		} else {
+			var claimIdle time.Duration
+			if v := os.Getenv("JOB_QUEUE_CLAIM_IDLE"); v != "" {
+				if d, ok := parseDuration(v); ok && d > 0 {
+					claimIdle = d
+				} else {
+					logging.Warnf("Invalid JOB_QUEUE_CLAIM_IDLE: %s, using default %s", v, intake.DefaultClaimIdle)
+				}
+			}
+			stream := os.Getenv("JOB_QUEUE_STREAM")
+			if stream == "" {
+				stream = "dsa:jobs"
+			}
+			consumer, err := intake.NewConsumer(intake.Config{
+				URL:          queueURL,
+				Stream:       stream,
+				Group:        os.Getenv("JOB_QUEUE_GROUP"),
+				Consumer:     os.Getenv("JOB_QUEUE_CONSUMER"),
+				ResultStream: os.Getenv("JOB_QUEUE_RESULT_STREAM"),
+				ClaimIdle:    claimIdle,
+			}, jobManager)
+			if err != nil {
+				logging.Warnf("Job queue consumer disabled: %v", err)
+			} else {
+				consumer.Start()
+				logging.Infof("Job queue consumer enabled (stream: %s, group: %s)", consumer.Stream(), consumer.Group())
+			}
+		}
+	}
+
+	// 共有リンク（/share/:token）の署名鍵と公開URL、有効期限（SHARE_LINK_TTL=168h 等、0は期限なし）
+	shareConfig := api.ShareConfig{
+		Secret:      []byte(os.Getenv("SHARE_SECRET")),
+		PublicURL:   os.Getenv("PUBLIC_URL"),
+		FrontendURL: os.Getenv("FRONTEND_URL"),
+	}
+	if v := os.Getenv("SHARE_LINK_TTL"); v != "" {
+		if d, ok := parseDuration(v); ok && d >= 0 {
+			shareConfig.TTL = d
+			if d == 0 {
+				shareConfig.TTL = -1
+			}
+		} else {
+			logging.Warnf("Invalid SHARE_LINK_TTL: %s, using default (7 days)", v)
+		}
+	}
+	routes.SetShareConfig(shareConfig)
+
+	// ユーザーアカウントのログイン用トークンの署名鍵と有効期限（AUTH_TOKEN_TTL=168h 等）
+	authConfig := api.AuthConfig{Secret: []byte(os.Getenv("AUTH_SECRET"))}
+	if v := os.Getenv("AUTH_TOKEN_TTL"); v != "" {
+		if d, ok := parseDuration(v); ok && d > 0 {
+			authConfig.TokenTTL = d
+		} else {
+			logging.Warnf("Invalid AUTH_TOKEN_TTL: %s, using default (7 days)", v)
+		}
+	}
+	// 登録時に管理者（adminのロール）とするメールアドレス（ADMIN_EMAILS=alice@example.com,bob@example.com）
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			authConfig.AdminEmails = append(authConfig.AdminEmails, email)
+		}
+	}
+	// ログインしていないリクエストのロール（ANONYMOUS_ROLE=viewer|analyst、未設定の場合はDB設定時はviewer、ない場合はanalyst）
+	if v := os.Getenv("ANONYMOUS_ROLE"); v != "" {
+		if v == auth.RoleViewer || v == auth.RoleAnalyst {
+			authConfig.AnonymousRole = v
+		} else {
+			logging.Warnf("Invalid ANONYMOUS_ROLE: %s (must be viewer or analyst), using default", v)
+		}
+	}
+	routes.SetAuthConfig(authConfig)
+
+	// ジョブの作成・成果物の取得のリクエスト数の制限（IPアドレス・セッションごと、RATE_LIMIT_JOBS=10/1m 等、0は無制限）
+	// ユーザー登録・ログインはIPアドレスごと（RATE_LIMIT_AUTH）
+	// 連続で受け付ける回数はRATE_LIMIT_JOBS_BURST・RATE_LIMIT_ARTIFACTS_BURST・RATE_LIMIT_AUTH_BURST（デフォルトは期間あたりの回数）
+	routes.SetJobRateLimit(rateLimitFromEnv("RATE_LIMIT_JOBS", api.RateLimit{Requests: 20, Per: time.Minute}))
+	routes.SetArtifactRateLimit(rateLimitFromEnv("RATE_LIMIT_ARTIFACTS", api.RateLimit{Requests: 600, Per: time.Minute}))
+	routes.SetAuthRateLimit(rateLimitFromEnv("RATE_LIMIT_AUTH", api.RateLimit{Requests: 10, Per: time.Minute}))
+
+	// セッションごとの成果物ダウンロードの月間上限（EGRESS_MONTHLY_LIMIT=1073741824 または 1GB）
+	if v := os.Getenv("EGRESS_MONTHLY_LIMIT"); v != "" {
+		if n, ok := parseByteSize(v); ok {
+			routes.SetEgressLimit(n)
+		} else {
+			logging.Warnf("Invalid EGRESS_MONTHLY_LIMIT: %s, downloads will not be limited", v)
+		}
+	}
+
+	// 縮小した画像の成果物（?size=small|medium）のキャッシュの上限（IMAGE_VARIANT_CACHE_SIZE=64MB、0でキャッシュしない）
+	if v := os.Getenv("IMAGE_VARIANT_CACHE_SIZE"); v != "" {
+		if n, ok := parseByteSize(v); ok {
+			routes.SetImageVariantCacheSize(n)
+		} else {
+			logging.Warnf("Invalid IMAGE_VARIANT_CACHE_SIZE: %s, using default", v)
+		}
+	}
+
+	// ジョブ間で共有する構造ファイルのキャッシュ（GET /api/pdb/:pdbid、PDB_CACHE_ENABLED=falseで無効）
+	if os.Getenv("PDB_CACHE_ENABLED") != "false" {
+		pdbCacheDir := os.Getenv("PDB_CACHE_DIR")
+		if pdbCacheDir == "" {
+			pdbCacheDir = filepath.Join(storageDir, "pdb_cache")
+		}
+		pdbCacheSize := int64(2 << 30)
+		if v := os.Getenv("PDB_CACHE_SIZE"); v != "" {
+			if n, ok := parseByteSize(v); ok {
+				pdbCacheSize = n
+			} else {
+				logging.Warnf("Invalid PDB_CACHE_SIZE: %s, using default %d", v, pdbCacheSize)
+			}
+		}
+		cache, err := pdbcache.New(pdbCacheDir, pdbCacheSize)
+		if err != nil {
+			logging.Warnf("Failed to open PDB cache, GET /api/pdb/:pdbid is disabled: %v", err)
+		} else {
+			if v := os.Getenv("PDB_SOURCE_URL"); v != "" {
+				cache.SetSourceURL(v)
+			}
+			routes.SetPDBCache(cache)
+			logging.Infof("PDB cache enabled (dir: %s, size limit: %d bytes)", pdbCacheDir, pdbCacheSize)
+		}
+	}
+
+	// フロントエンド向け設定（/api/config）
+	maxUploadSize := fiber.DefaultBodyLimit
+	if v := os.Getenv("MAX_UPLOAD_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			maxUploadSize = n
+		} else {
+			logging.Warnf("Invalid MAX_UPLOAD_SIZE: %s, using default %d", v, maxUploadSize)
+		}
+	}
+	features := make(map[string]bool)
+	// FEATURE_FLAGS=flag1,flag2 の形式で有効化するフラグを指定
+	for _, flag := range strings.Split(os.Getenv("FEATURE_FLAGS"), ",") {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			features[flag] = true
+		}
+	}
+	routes.SetClientConfig(api.ClientConfig{
+		Features:      features,
+		MaxUploadSize: maxUploadSize,
+		AuthMode:      authMode,
+		Viewer: api.ViewerConfig{
+			ShowSequence: os.Getenv("VIEWER_SHOW_SEQUENCE") != "false",
+			PDBSource:    os.Getenv("VIEWER_PDB_SOURCE"),
+		},
+	})
+
+	// デプロイごとの識別情報（/api/instance、INSTANCE_CONFIG=instance.json で名前・連絡先・保持方針・利用規約を設定する）
+	instance := api.DefaultInstanceInfo()
+	if path := os.Getenv("INSTANCE_CONFIG"); path != "" {
+		loaded, err := api.LoadInstanceInfo(path)
+		if err != nil {
+			logging.Warnf("Failed to load INSTANCE_CONFIG: %v, using defaults", err)
+		} else {
+			instance = loaded
+		}
+	}
+	if instance.PipelineVersion == "" {
+		instance.PipelineVersion = jobManager.PipelineVersion()
+	}
+	routes.SetInstanceInfo(instance)
+	logging.Infof("Instance: %s (pipeline version: %s)", instance.Name, instance.PipelineVersion)
+
 	// Fiberアプリの作成
 	app := fiber.New(fiber.Config{
+		BodyLimit: maxUploadSize,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
 				code = e.Code
 			}
-			return c.Status(code).JSON(fiber.Map{
+			body := fiber.Map{
 				"error": err.Error(),
-			})
+			}
+			// ログと照合できるようにリクエストIDを返す
+			if id := api.RequestID(c); id != "" {
+				body["request_id"] = id
+			}
+			return c.Status(code).JSON(body)
 		},
 	})
 
-	// CORS設定
-	app.Use(cors.New(cors.Config{
+	// CORS設定（CORS_ORIGINSを指定した場合はそのオリジンのみ、セッションCookieを含むリクエストを許可する）
+	// 未設定・*の場合はすべてのオリジンを許可するがCookieは送られない（状態を変更するリクエストはCSRFトークンでも保護する）
+	corsConfig := cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,DELETE,OPTIONS",
-		AllowHeaders: "Content-Type",
-	}))
+		AllowMethods: "GET,POST,PATCH,DELETE,OPTIONS",
+		AllowHeaders: "Content-Type,X-API-Key,Authorization,X-CSRF-Token",
+		// 解析一覧の総件数（?include_total=true）
+		ExposeHeaders: "X-Total-Count",
+	}
+	corsOrigins := os.Getenv("CORS_ORIGINS")
+	if corsOrigins == "" {
+		if corsOrigins = os.Getenv("CORS_ALLOW_ORIGINS"); corsOrigins != "" {
+			logging.Warnf("CORS_ALLOW_ORIGINS is deprecated, use CORS_ORIGINS instead")
+		}
+	}
+	if origins := parseCORSOrigins(corsOrigins); len(origins) > 0 {
+		corsConfig.AllowOrigins = strings.Join(origins, ",")
+		corsConfig.AllowCredentials = true
+		logging.Infof("CORS enabled with credentials for: %s", corsConfig.AllowOrigins)
+	} else if corsOrigins == "" {
+		logging.Warnf("CORS_ORIGINS is not set, allowing all origins without credentials (cross-origin frontends cannot use session cookies)")
+	}
+	app.Use(cors.New(corsConfig))
 
 	// ルート設定
 	routes.SetupRoutes(app)
@@ -152,8 +799,137 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Server starting on port %s", port)
+	// gRPCサービス（内部ツール・パイプライン連携用、GRPC_PORTを指定した場合のみ、API_KEYSが必要）
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		grpcServer, err := grpcapi.NewServer(jobManager, strings.Split(os.Getenv("API_KEYS"), ","), os.Getenv("READ_ONLY") == "true")
+		if err != nil {
+			logging.Warnf("gRPC service disabled: %v", err)
+		} else {
+			lis, err := net.Listen("tcp", ":"+grpcPort)
+			if err != nil {
+				log.Fatalf("Failed to listen on gRPC port %s: %v", grpcPort, err)
+			}
+			go func() {
+				if err := grpcServer.Serve(lis); err != nil {
+					logging.Errorf("gRPC server stopped: %v", err)
+				}
+			}()
+			logging.Infof("gRPC service listening on port %s", grpcPort)
+		}
+	}
+
+	logging.Infof("Server starting on port %s", port)
 	if err := app.Listen(":" + port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// newObjectStore 環境変数から成果物の保存先を作成する（r2で環境変数が設定されていない場合はnil）
+// s3・gcs・minioはS3_*、localはLOCAL_STORE_DIR（デフォルトは<STORAGE_DIR>/objects）を使う
+func newObjectStore(backend, storageDir string) (storage.ObjectStore, error) {
+	switch backend {
+	case storage.BackendR2:
+		r2AccountID := os.Getenv("R2_ACCOUNT_ID")
+		r2AccessKeyID := os.Getenv("R2_ACCESS_KEY_ID")
+		r2SecretAccessKey := os.Getenv("R2_SECRET_ACCESS_KEY")
+		r2Bucket := os.Getenv("R2_BUCKET")
+		r2Endpoint := os.Getenv("R2_ENDPOINT")
+		r2PublicBase := os.Getenv("R2_PUBLIC_BASE_URL")
+		if r2AccountID == "" || r2AccessKeyID == "" || r2SecretAccessKey == "" || r2Bucket == "" || r2Endpoint == "" {
+			return nil, nil
+		}
+		return storage.NewR2Client(r2AccountID, r2AccessKeyID, r2SecretAccessKey, r2Bucket, r2Endpoint, r2PublicBase)
+	case storage.BackendS3, storage.BackendGCS, storage.BackendMinIO:
+		return storage.NewS3Client(backend, storage.S3Config{
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			Region:          os.Getenv("S3_REGION"),
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			UsePathStyle:    os.Getenv("S3_USE_PATH_STYLE") == "true",
+			PublicBaseURL:   os.Getenv("S3_PUBLIC_BASE_URL"),
+		})
+	case storage.BackendLocal:
+		dir := os.Getenv("LOCAL_STORE_DIR")
+		if dir == "" {
+			dir = filepath.Join(storageDir, "objects")
+		}
+		return storage.NewLocalStore(dir)
+	}
+	return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (expected one of %s)", backend, strings.Join(storage.Backends, ", "))
+}
+
+// parseDuration 整数（秒）またはGoのduration形式（1h, 30sなど）を解析する
+func parseDuration(v string) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, true
+	}
+	return 0, false
+}
+
+// rateLimitFromEnv <回数>/<期間>（10/1m、10/60）形式の制限とname_BURSTを読み取る（0は無制限、不正な値はデフォルト）
+func rateLimitFromEnv(name string, limit api.RateLimit) api.RateLimit {
+	if v := os.Getenv(name); v != "" {
+		if v == "0" {
+			return api.RateLimit{}
+		}
+		requests, period, _ := strings.Cut(v, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(requests))
+		d, ok := parseDuration(strings.TrimSpace(period))
+		if err != nil || n <= 0 || !ok || d <= 0 {
+			logging.Warnf("Invalid %s: %s, using default (%d/%s)", name, v, limit.Requests, limit.Per)
+		} else {
+			limit = api.RateLimit{Requests: n, Per: d}
+		}
+	}
+	if v := os.Getenv(name + "_BURST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit.Burst = n
+		} else {
+			logging.Warnf("Invalid %s_BURST: %s, using %d", name, v, limit.Requests)
+		}
+	}
+	return limit
+}
+
+// parseCORSOrigins カンマ区切りのオリジン（http(s)://host[:port]）を検証して返す（*・不正な値は除く）
+func parseCORSOrigins(v string) []string {
+	var origins []string
+	for _, origin := range strings.Split(v, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" || origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			logging.Warnf("Ignoring invalid CORS origin: %s", origin)
+			continue
+		}
+		origins = append(origins, origin)
+	}
+	return origins
+}
+
+// parseByteSize バイト数（1048576）または単位付き（500MB, 1GB）のサイズを解析する
+func parseByteSize(v string) (int64, bool) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(v, unit.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n * multiplier, true
+}
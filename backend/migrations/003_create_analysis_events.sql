@@ -0,0 +1,16 @@
+-- Migration: Create analysis_events table
+-- Created: 2026-10-18
+
+-- ジョブの状態遷移・キャンセル要求・再実行などの記録（GET /api/analyses/:id/events）
+CREATE TABLE IF NOT EXISTS analysis_events (
+    id BIGSERIAL PRIMARY KEY,
+    analysis_id TEXT NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    from_status TEXT NULL,
+    to_status TEXT NULL,
+    message TEXT NULL,
+    data JSONB NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_events_analysis_created ON analysis_events(analysis_id, created_at);
@@ -0,0 +1,8 @@
+-- Migration: Add artifacts_expired_at column to analyses table
+-- Created: 2026-10-18
+
+-- 保持期間（ARTIFACT_RETENTION_DAYS）を過ぎて成果物を削除した日時（DBのサマリーとメトリクスは残す）
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS artifacts_expired_at TIMESTAMPTZ NULL;
+
+-- 成果物の期限切れ処理の対象を探すためのインデックス
+CREATE INDEX IF NOT EXISTS idx_analyses_finished_artifacts ON analyses(finished_at) WHERE artifacts_expired_at IS NULL;
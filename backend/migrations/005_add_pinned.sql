@@ -0,0 +1,8 @@
+-- Migration: Add pinned column to analyses table
+-- Created: 2026-10-18
+
+-- 保持期間（RETENTION_DAYS・ARTIFACT_RETENTION_DAYS）による自動削除の対象外にする解析
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 自動削除の対象を探すためのインデックス
+CREATE INDEX IF NOT EXISTS idx_analyses_created_unpinned ON analyses(created_at) WHERE NOT pinned;
@@ -0,0 +1,10 @@
+-- Migration: Create analysis_durations table
+-- Created: 2026-10-18
+
+-- 構造数の区分ごとの解析の所要時間（実行開始から解析完了まで）の平均（eta_secondsの推定に使う）
+CREATE TABLE IF NOT EXISTS analysis_durations (
+    size_bucket TEXT PRIMARY KEY,
+    runs INTEGER NOT NULL DEFAULT 0,
+    avg_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+-- Migration: Add storage_layout column to analyses table
+-- Created: 2026-10-18
+
+-- 成果物の保存形式のバージョン（1: analysis/<id>/、2: analyses/<先頭2文字>/<id>/）
+-- 既存の解析は従来の形式（1）のまま読み書きし、dsa-admin layout migrate で移行する
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS storage_layout INT NOT NULL DEFAULT 1;
+
+-- 移行の対象を探すためのインデックス
+CREATE INDEX IF NOT EXISTS idx_analyses_storage_layout ON analyses(storage_layout);
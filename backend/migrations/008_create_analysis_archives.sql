@@ -0,0 +1,25 @@
+-- Migration: Create analysis_archives table
+-- Created: 2026-10-18
+
+-- 長期保存先（S3 Glacier等）に移した解析の成果物と復元の状態（POST /api/analyses/:id/archive・restore）
+-- status: archiving（移動中）/ archived（長期保存先のみ）/ restoring（復元待ち）/ restored（R2に戻した）/ failed（移動に失敗）
+CREATE TABLE IF NOT EXISTS analysis_archives (
+    analysis_id TEXT PRIMARY KEY REFERENCES analyses(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    storage_class TEXT NOT NULL,
+    -- 移したオブジェクト（[{"key": ..., "size": ..., "content_type": ...}]、キーはR2と同じ）
+    objects JSONB NOT NULL DEFAULT '[]',
+    bytes BIGINT NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    archived_at TIMESTAMPTZ NULL,
+    restore_tier TEXT NULL,
+    restore_requested_at TIMESTAMPTZ NULL,
+    restore_completed_at TIMESTAMPTZ NULL,
+    -- 長期保存先の一時的な復元コピーの有効期限
+    restore_expires_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 移動中・復元待ちの解析を再開・確認するためのインデックス
+CREATE INDEX IF NOT EXISTS idx_analysis_archives_status ON analysis_archives(status);
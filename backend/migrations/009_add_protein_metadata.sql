@@ -0,0 +1,7 @@
+-- Migration: Add protein metadata columns to analyses table
+-- Created: 2026-10-18
+
+-- ジョブ作成時にUniProtから取得したタンパク質の情報（UNIPROT_METADATA=true の場合、取得できなかった場合はNULL）
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS protein_name TEXT;
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS organism TEXT;
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS sequence_length INT;
@@ -0,0 +1,9 @@
+-- Migration: Add variant column to analyses table
+-- Created: 2026-10-18
+
+-- アイソフォーム・チェーンを指定した解析の対象（"P69905-2"・"P69905:A"・"P69905-2:A"、正規の配列のすべてのチェーンはNULL）
+-- uniprot_idはアクセッション番号のみ（アイソフォームの接尾辞はparams.isoformに移す）
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS variant TEXT;
+
+-- 同じタンパク質の解析をアイソフォーム・チェーンごとに探すためのインデックス
+CREATE INDEX IF NOT EXISTS idx_analyses_uniprot_variant ON analyses(uniprot_id, variant);
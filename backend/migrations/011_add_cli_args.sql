@@ -0,0 +1,6 @@
+-- Migration: Add cli_args column to analyses table
+-- Created: 2026-10-18
+
+-- 解析のパラメータから組み立てたPython CLIの引数（--uniprot・--out・--resumeを除く、記録前の解析はNULL）
+-- paramsはJSONBのため整数もfloat64として読み込まれる。実行した引数をそのまま残し、同じ解析を再現できるようにする
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS cli_args TEXT[];
@@ -0,0 +1,13 @@
+-- Migration: Create analysis_artifact_checksums table
+-- Created: 2026-10-18
+
+-- アップロード時に計算した成果物のSHA-256（配信・ダウンロード時の検証と、GET /api/analyses/:idのchecksumsに使う）
+-- R2のオブジェクトにもメタデータ（x-amz-meta-sha256）として保存する
+CREATE TABLE IF NOT EXISTS analysis_artifact_checksums (
+    analysis_id TEXT NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (analysis_id, name)
+);
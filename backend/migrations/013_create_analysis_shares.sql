@@ -0,0 +1,16 @@
+-- Migration: Create analysis_shares table
+-- Created: 2026-10-18
+
+-- 解析の共有リンク（POST /api/analyses/:id/share で発行、GET /share/:token で公開）
+-- トークンはidにSHARE_SECRETで署名したもので、期限切れ・取り消し済みのリンクは無効になる
+CREATE TABLE IF NOT EXISTS analysis_shares (
+    id TEXT PRIMARY KEY,
+    analysis_id TEXT NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    -- NULLの場合は期限なし
+    expires_at TIMESTAMPTZ NULL,
+    revoked_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 解析ごとの共有リンクの一覧用
+CREATE INDEX IF NOT EXISTS idx_analysis_shares_analysis_id ON analysis_shares(analysis_id);
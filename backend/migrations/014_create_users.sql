@@ -0,0 +1,18 @@
+-- Migration: Create users table and add user_id column to analyses table
+-- Created: 2026-10-18
+
+-- ユーザーアカウント（POST /api/auth/register・login、任意）
+-- session_idはアカウントのセッションで、ログイン中に作成した解析・ログイン時に引き継いだ解析はこのセッションに属する
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    session_id TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ NULL
+);
+
+-- 解析を作成・引き継いだユーザー（匿名のセッションの解析はNULL）
+ALTER TABLE analyses ADD COLUMN IF NOT EXISTS user_id TEXT NULL REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_analyses_user_id ON analyses(user_id, created_at DESC);
@@ -0,0 +1,6 @@
+-- Migration: Add role column to users table
+-- Created: 2026-10-18
+
+-- ユーザーのロール（viewer: 閲覧のみ、analyst: 解析の作成・変更、admin: 管理用API）
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'analyst'
+    CHECK (role IN ('viewer', 'analyst', 'admin'));
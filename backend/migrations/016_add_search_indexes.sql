@@ -0,0 +1,11 @@
+-- Migration: Add indexes for analysis search
+-- Created: 2026-10-18
+
+-- UniProt ID・タンパク質名の部分一致検索（GET /api/analyses?q=...、ILIKE '%...%'）
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_analyses_uniprot_trgm ON analyses USING gin (uniprot_id gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_analyses_protein_name_trgm ON analyses USING gin (protein_name gin_trgm_ops);
+
+-- よく使う指標の範囲での絞り込み・並べ替え（mean_score_gt・entries_gte・sort=-mean_score など）
+CREATE INDEX IF NOT EXISTS idx_analyses_mean_score ON analyses (((metrics->>'mean_score')::double precision));
+CREATE INDEX IF NOT EXISTS idx_analyses_entries ON analyses (((metrics->>'entries')::double precision));
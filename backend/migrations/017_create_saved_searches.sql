@@ -0,0 +1,19 @@
+-- Migration: Create saved_searches table
+-- Created: 2026-10-18
+
+-- 名前を付けて保存した解析一覧の絞り込み（POST /api/searches、GET /api/searches/:id/results で再実行）
+-- セッション（ログイン中はアカウントのセッションID）ごとに保存する
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    -- 絞り込みの条件（uniprot_ids・method・status・from・to・q・metric_ranges・sort）
+    filters JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_run_at TIMESTAMPTZ NULL,
+    UNIQUE (session_id, name)
+);
+
+-- セッションごとの一覧用
+CREATE INDEX IF NOT EXISTS idx_saved_searches_session ON saved_searches(session_id, created_at DESC);
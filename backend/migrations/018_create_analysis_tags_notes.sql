@@ -0,0 +1,29 @@
+-- Migration: Create analysis_tags and analysis_notes tables
+-- Created: 2026-10-18
+
+-- 解析のタグ（"publication candidate"・"bad parameters" など、小文字に正規化して保存する）
+-- GET /api/analyses?tags=... で絞り込む
+CREATE TABLE IF NOT EXISTS analysis_tags (
+    analysis_id TEXT NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (analysis_id, tag)
+);
+
+-- タグでの絞り込み用
+CREATE INDEX IF NOT EXISTS idx_analysis_tags_tag ON analysis_tags(tag);
+
+-- 解析のメモ（自由記述、作成したセッション・管理者のみ削除できる）
+CREATE TABLE IF NOT EXISTS analysis_notes (
+    id TEXT PRIMARY KEY,
+    analysis_id TEXT NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    -- 作成したセッション（ログイン中はアカウントのセッションID）とユーザー
+    session_id TEXT NULL,
+    user_id TEXT NULL,
+    author_email TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 解析ごとのメモの一覧用
+CREATE INDEX IF NOT EXISTS idx_analysis_notes_analysis ON analysis_notes(analysis_id, created_at);
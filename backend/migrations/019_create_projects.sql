@@ -0,0 +1,28 @@
+-- Migration: Create projects and project_analyses tables
+-- Created: 2026-10-18
+
+-- 複数の解析をまとめるプロジェクト（複数のタンパク質を対象にした研究など）
+-- セッション（ログイン中はアカウントのセッションID）ごとに作成する
+CREATE TABLE IF NOT EXISTS projects (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (session_id, name)
+);
+
+-- セッションごとの一覧用
+CREATE INDEX IF NOT EXISTS idx_projects_session ON projects(session_id, created_at DESC);
+
+-- プロジェクトに追加した解析（プロジェクト・解析の削除で外れる）
+CREATE TABLE IF NOT EXISTS project_analyses (
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    analysis_id TEXT NOT NULL REFERENCES analyses(id) ON DELETE CASCADE,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, analysis_id)
+);
+
+-- 解析が含まれるプロジェクトの検索用
+CREATE INDEX IF NOT EXISTS idx_project_analyses_analysis ON project_analyses(analysis_id);
@@ -0,0 +1,5 @@
+-- Migration: Add index on params->>'sweep_id'
+-- Created: 2026-10-18
+
+-- パラメータスイープ（POST /api/analyses/:id/rerun で配列を指定した再実行）で作成した解析の一覧用（GET /api/sweeps/:id）
+CREATE INDEX IF NOT EXISTS idx_analyses_sweep_id ON analyses((params->>'sweep_id')) WHERE params ? 'sweep_id';
@@ -0,0 +1,21 @@
+-- Migration: Create webhook_deliveries table
+-- Created: 2026-10-18
+
+-- Webhookの配信記録（DATABASE_URL設定時、ない場合はwebhooks/deliveries.jsonに保存する）
+-- Webhook自体はendpoints.jsonに保存するため、endpoint_idに外部キーは設定しない
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    endpoint_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    response_status INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+-- Webhookごとの削除用
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id);
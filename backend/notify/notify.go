@@ -0,0 +1,251 @@
+// Package notify 長時間のジョブの終了をメールで通知する
+// 送信方法はNotifierとして差し替えられる（SMTPNotifier等）
+package notify
+
+import (
+	"context"
+	"dsa-api/jobs"
+	"dsa-api/logging"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 送信待ちのメッセージの上限（溢れたものは破棄する）
+	queueSize = 100
+	// 1通の送信の再試行（30秒, 60秒）
+	maxAttempts = 3
+	retryDelay  = 30 * time.Second
+	sendTimeout = 30 * time.Second
+	// 終了しないまま残った登録を破棄するまでの期間
+	registrationTTL = 30 * 24 * time.Hour
+)
+
+// Message 送信するメッセージ（本文はプレーンテキスト）
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier メッセージを送信する
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// registration ジョブの終了を通知する宛先
+type registration struct {
+	JobID     string    `json:"job_id"`
+	UniProtID string    `json:"uniprot_id"`
+	Emails    []string  `json:"emails"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Mailer ジョブ作成時に指定されたnotify_emailに、最低実行時間を超えたジョブの完了・失敗を通知する
+// 宛先は解析のパラメータ（APIで返す）には含めず、dirのpending.jsonに保存して再起動後も引き継ぐ
+type Mailer struct {
+	dir      string
+	notifier Notifier
+	// 作成から終了までの時間がこれより短いジョブは通知しない
+	minRuntime time.Duration
+	// 結果のリンクのベースURL（FRONTEND_URL、空の場合はリンクを含めない）
+	resultURL string
+
+	mu      sync.Mutex
+	pending map[string]*registration
+	queue   chan Message
+}
+
+// NewMailer dirに保存された送信待ちの登録を読み込む
+func NewMailer(dir string, notifier Notifier) (*Mailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create notification directory: %w", err)
+	}
+	m := &Mailer{
+		dir:      dir,
+		notifier: notifier,
+		pending:  make(map[string]*registration),
+		queue:    make(chan Message, queueSize),
+	}
+
+	var stored []*registration
+	data, err := os.ReadFile(m.pendingPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load notifications: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return nil, fmt.Errorf("failed to load notifications: %w", err)
+		}
+	}
+	cutoff := time.Now().Add(-registrationTTL)
+	for _, reg := range stored {
+		if reg.CreatedAt.After(cutoff) {
+			m.pending[reg.JobID] = reg
+		}
+	}
+	return m, nil
+}
+
+// SetMinRuntime 通知する最低実行時間を設定する（0以下はすべてのジョブを通知する）
+func (m *Mailer) SetMinRuntime(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	m.minRuntime = d
+}
+
+// SetResultURL 結果のリンクのベースURL（<base>/analysis/result?job_id=<id>）を設定する
+func (m *Mailer) SetResultURL(base string) {
+	m.resultURL = strings.TrimRight(base, "/")
+}
+
+func (m *Mailer) pendingPath() string {
+	return filepath.Join(m.dir, "pending.json")
+}
+
+// savePending m.muを保持して呼ぶ
+func (m *Mailer) savePending() {
+	stored := make([]*registration, 0, len(m.pending))
+	for _, reg := range m.pending {
+		stored = append(stored, reg)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err == nil {
+		tmp := filepath.Join(m.dir, ".pending.json.tmp")
+		if err = os.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, m.pendingPath())
+		}
+	}
+	if err != nil {
+		logging.Warnf("Failed to save notifications: %v", err)
+	}
+}
+
+// Register ジョブの終了をemailに通知する（同じジョブに複数の宛先を登録できる）
+func (m *Mailer) Register(jobID, uniprotID, email string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reg, ok := m.pending[jobID]
+	if !ok {
+		reg = &registration{JobID: jobID, UniProtID: uniprotID, CreatedAt: time.Now()}
+		m.pending[jobID] = reg
+	}
+	for _, e := range reg.Emails {
+		if e == email {
+			return
+		}
+	}
+	reg.Emails = append(reg.Emails, email)
+	m.savePending()
+}
+
+// Start 送信するgoroutineを起動する
+func (m *Mailer) Start() {
+	go func() {
+		for msg := range m.queue {
+			m.send(msg)
+		}
+	}()
+}
+
+// send 失敗した場合は間隔を空けて再試行する
+func (m *Mailer) send(msg Message) {
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := m.notifier.Send(ctx, msg)
+		cancel()
+		if err == nil {
+			logging.Infof("Sent notification email: %s", msg.Subject)
+			return
+		}
+		if attempt >= maxAttempts {
+			logging.Errorf("Failed to send notification email (%s) after %d attempts: %v", msg.Subject, attempt, err)
+			return
+		}
+		logging.Warnf("Failed to send notification email (%s), retrying: %v", msg.Subject, err)
+		time.Sleep(retryDelay * time.Duration(attempt))
+	}
+}
+
+// JobListener ジョブの完了・失敗を通知するリスナー（キャンセルは通知しない）
+func (m *Mailer) JobListener() func(jobs.JobUpdate) {
+	return func(update jobs.JobUpdate) {
+		switch update.Status {
+		case jobs.StatusDone, jobs.StatusFailed:
+		case jobs.StatusCancelled:
+			m.mu.Lock()
+			if _, ok := m.pending[update.JobID]; ok {
+				delete(m.pending, update.JobID)
+				m.savePending()
+			}
+			m.mu.Unlock()
+			return
+		default:
+			return
+		}
+
+		m.mu.Lock()
+		reg, ok := m.pending[update.JobID]
+		if ok {
+			delete(m.pending, update.JobID)
+			m.savePending()
+		}
+		m.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		runtime := time.Since(reg.CreatedAt)
+		if runtime < m.minRuntime {
+			logging.Debugf("Job %s finished in %s, skipping notification email", update.JobID, runtime.Round(time.Second))
+			return
+		}
+		for _, email := range reg.Emails {
+			msg := m.jobMessage(email, reg, update, runtime)
+			select {
+			case m.queue <- msg:
+			default:
+				logging.Warnf("Notification queue is full, dropping email for job %s", update.JobID)
+			}
+		}
+	}
+}
+
+// jobMessage ジョブの終了を知らせるメッセージ
+func (m *Mailer) jobMessage(to string, reg *registration, update jobs.JobUpdate, runtime time.Duration) Message {
+	uniprotID := update.UniProtID
+	if uniprotID == "" {
+		uniprotID = reg.UniProtID
+	}
+	outcome := "completed"
+	if update.Status == jobs.StatusFailed {
+		outcome = "failed"
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Your DSA analysis of %s has %s.\n\n", uniprotID, outcome)
+	fmt.Fprintf(&body, "UniProt ID:  %s\n", uniprotID)
+	fmt.Fprintf(&body, "Analysis ID: %s\n", update.JobID)
+	fmt.Fprintf(&body, "Status:      %s\n", update.Status)
+	fmt.Fprintf(&body, "Runtime:     %s\n", runtime.Round(time.Second))
+	if update.Status == jobs.StatusFailed && update.ErrorMessage != "" {
+		fmt.Fprintf(&body, "Error:       %s\n", update.ErrorMessage)
+	}
+	if m.resultURL != "" {
+		fmt.Fprintf(&body, "\nResult: %s/analysis/result?job_id=%s\n", m.resultURL, update.JobID)
+	}
+	body.WriteString("\nYou received this email because notify_email was specified when the analysis was submitted.\n")
+
+	return Message{
+		To:      to,
+		Subject: fmt.Sprintf("[DSA] Analysis %s: %s", outcome, uniprotID),
+		Body:    body.String(),
+	}
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPの暗号化の方式
+const (
+	// STARTTLSで暗号化する（デフォルト、ポート587）
+	SMTPStartTLS = "starttls"
+	// 接続時からTLSを使う（ポート465）
+	SMTPImplicitTLS = "tls"
+	// 暗号化しない（ローカルの中継サーバー用）
+	SMTPPlain = "none"
+)
+
+// SMTPConfig SMTPサーバーの設定（SMTP_*）
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// 送信元のアドレス（"DSA <dsa@example.com>" の形式も可）
+	From string
+	// starttls・tls・none（空はstarttls）
+	TLS string
+}
+
+// SMTPNotifier SMTPでメールを送信する
+type SMTPNotifier struct {
+	cfg  SMTPConfig
+	from *mail.Address
+}
+
+// NewSMTPNotifier 設定を検証してSMTPNotifierを返す
+func NewSMTPNotifier(cfg SMTPConfig) (*SMTPNotifier, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if cfg.TLS == "" {
+		cfg.TLS = SMTPStartTLS
+	}
+	switch cfg.TLS {
+	case SMTPStartTLS, SMTPImplicitTLS, SMTPPlain:
+	default:
+		return nil, fmt.Errorf("invalid SMTP TLS mode: %s (must be starttls, tls or none)", cfg.TLS)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+		if cfg.TLS == SMTPImplicitTLS {
+			cfg.Port = 465
+		}
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP from address: %w", err)
+	}
+	return &SMTPNotifier{cfg: cfg, from: from}, nil
+}
+
+// Send メッセージを送信する（接続からQUITまでctxの期限内に行う）
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	data, err := n.buildMessage(to, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(n.cfg.Host, fmt.Sprintf("%d", n.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: n.cfg.Host}
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if n.cfg.TLS == SMTPImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, n.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if n.cfg.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("SMTP server does not support STARTTLS (set SMTP_TLS=none to send without encryption)")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if n.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(n.from.Address); err != nil {
+		return fmt.Errorf("MAIL FROM failed: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("RCPT TO failed: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage ヘッダーと本文（UTF-8のプレーンテキスト、改行はCRLF）を組み立てる
+func (n *SMTPNotifier) buildMessage(to *mail.Address, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("subject must not contain line breaks")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", to.String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	body := strings.TrimRight(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(body, "\n") {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return []byte(b.String()), nil
+}
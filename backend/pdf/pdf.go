@@ -0,0 +1,209 @@
+// Package pdf 1ページのPDFを書き出す（レポート用の最小限の実装）
+// フォントはPDFの標準フォント（Helvetica・Helvetica-Bold、WinAnsiEncoding）のみで、ASCII以外の文字は?にする
+// 画像はRGBに変換してFlateDecodeで埋め込む（透過部分は白）
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A4の大きさ（pt）
+const (
+	A4Width  = 595.0
+	A4Height = 842.0
+)
+
+// ContentType PDFのContent-Type
+const ContentType = "application/pdf"
+
+// Page 1ページ分の描画内容
+// 座標はページの左上を原点とし、yは下向き（テキストはyがベースライン）
+type Page struct {
+	Width  float64
+	Height float64
+
+	content bytes.Buffer
+	images  []*pageImage
+}
+
+// pageImage ページに埋め込む画像（RGB、圧縮済み）
+type pageImage struct {
+	width  int
+	height int
+	data   []byte
+}
+
+// NewPage 大きさがwidth×height（pt）のページ
+func NewPage(width, height float64) *Page {
+	return &Page{Width: width, Height: height}
+}
+
+// Text テキストを描画する（boldはHelvetica-Bold）
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(p.Height-y), escapeText(s))
+}
+
+// Line 線を描画する（太さwidth、グレーの濃さgray: 0は黒、1は白）
+func (p *Page) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "q %s G %s w %s %s m %s %s l S Q\n", num(gray), num(width), num(x1), num(p.Height-y1), num(x2), num(p.Height-y2))
+}
+
+// Image 画像を左上(x, y)・大きさw×h（pt）で描画する
+func (p *Page) Image(img image.Image, x, y, w, h float64) error {
+	bounds := img.Bounds()
+	raw := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			r, g, b, a := img.At(px, py).RGBA()
+			// 乗算済みのアルファを白の背景に合成する
+			white := 0xffff - a
+			raw = append(raw, byte((r+white)>>8), byte((g+white)>>8), byte((b+white)>>8))
+		}
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	p.images = append(p.images, &pageImage{width: bounds.Dx(), height: bounds.Dy(), data: compressed.Bytes()})
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", num(w), num(h), num(x), num(p.Height-y-h), len(p.images))
+	return nil
+}
+
+// Write ページを1ページのPDFとしてwに書き込む
+func (p *Page) Write(w io.Writer) error {
+	var b bytes.Buffer
+	offsets := []int{}
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s", len(offsets), body)
+		if stream != nil {
+			b.WriteString("\nstream\n")
+			b.Write(stream)
+			b.WriteString("\nendstream")
+		}
+		b.WriteString("\nendobj\n")
+	}
+
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// 1: カタログ、2: ページツリー、3: ページ、4・5: フォント、6: ページの内容、7〜: 画像
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	var xobjects strings.Builder
+	for i := range p.images {
+		fmt.Fprintf(&xobjects, " /Im%d %d 0 R", i+1, 7+i)
+	}
+	object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> /XObject <<%s >> >> /Contents 6 0 R >>",
+		num(p.Width), num(p.Height), xobjects.String()), nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	content := p.content.Bytes()
+	object(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+	for _, img := range p.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			img.width, img.height, len(img.data)), img.data)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// TextWidth Helveticaで描画したテキストの幅（pt、Helvetica-Boldもおおよそこの幅）
+func TextWidth(s string, size float64) float64 {
+	var units int
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			units += helveticaWidths[r-32]
+		} else {
+			units += helveticaWidths['?'-32]
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// Wrap テキストを幅maxWidth（pt）に収まるように折り返す（空白で区切れない長い語は途中で折り返す）
+func Wrap(s string, size, maxWidth float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if TextWidth(candidate, size) <= maxWidth {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		// 1語で幅を超える場合は収まる長さで区切る
+		for TextWidth(word, size) > maxWidth {
+			n := 1
+			for n < len(word) && TextWidth(word[:n+1], size) <= maxWidth {
+				n++
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escapeText PDFの文字列リテラルとして書き込めるようにする（ASCII以外は?）
+func escapeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r <= 126:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// num 座標・大きさを小数第2位までで書き込む
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// helveticaWidths Helveticaの文字幅（1000分の1em、ASCIIの32〜126）
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space〜/
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0〜9
+	278, 278, 584, 584, 584, 556, 1015, // :〜@
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A〜M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N〜Z
+	278, 278, 278, 469, 556, 333, // [〜`
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a〜m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n〜z
+	334, 260, 334, 584, // {〜~
+}
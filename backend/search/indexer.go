@@ -0,0 +1,427 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIndex インデックス名を指定しない場合のデフォルト
+	DefaultIndex = "dsa-analyses"
+	// 1回の_bulkリクエストで送る解析の数
+	bulkBatchSize = 200
+	// 変更の通知を受けてから送信するまでの待ち時間（続けて起きた変更をまとめる）
+	flushDelay = time.Second
+	// 送信に失敗した場合に再試行するまでの待ち時間
+	retryDelay = 30 * time.Second
+	// 1つの解析の送信を諦めるまでの試行回数
+	maxAttempts = 5
+	// Elasticsearch/OpenSearchへのリクエストのタイムアウト
+	requestTimeout = 30 * time.Second
+)
+
+// Config Elasticsearch/OpenSearchの接続設定
+type Config struct {
+	// ベースURL（例: http://localhost:9200）
+	URL string
+	// インデックス名（空の場合はDefaultIndex）
+	Index string
+	// Basic認証（OpenSearch・Elasticsearchのユーザー）
+	Username string
+	Password string
+	// ElasticsearchのAPIキー（指定した場合はBasic認証より優先）
+	APIKey string
+}
+
+// Document インデックスに保存する解析のサマリーとメトリクス
+type Document struct {
+	ID                 string                 `json:"id"`
+	UniProtID          string                 `json:"uniprot_id"`
+	Method             string                 `json:"method"`
+	Status             string                 `json:"status"`
+	Params             map[string]interface{} `json:"params,omitempty"`
+	Metrics            map[string]interface{} `json:"metrics,omitempty"`
+	ErrorMessage       string                 `json:"error_message,omitempty"`
+	Pinned             bool                   `json:"pinned"`
+	ArtifactsExpired   bool                   `json:"artifacts_expired"`
+	ArtifactsExpiredAt *time.Time             `json:"artifacts_expired_at,omitempty"`
+	CreatedAt          time.Time              `json:"created_at"`
+	StartedAt          *time.Time             `json:"started_at,omitempty"`
+	FinishedAt         *time.Time             `json:"finished_at,omitempty"`
+	IndexedAt          time.Time              `json:"indexed_at"`
+}
+
+// インデックスのマッピング（UniProt IDはあいまい検索用にtextでも保存する）
+var indexMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id":                   map[string]interface{}{"type": "keyword"},
+			"uniprot_id":           map[string]interface{}{"type": "keyword", "fields": map[string]interface{}{"text": map[string]interface{}{"type": "text"}}},
+			"method":               map[string]interface{}{"type": "keyword"},
+			"status":               map[string]interface{}{"type": "keyword"},
+			"params":               map[string]interface{}{"type": "object", "enabled": false},
+			"metrics":              map[string]interface{}{"type": "object"},
+			"error_message":        map[string]interface{}{"type": "text"},
+			"pinned":               map[string]interface{}{"type": "boolean"},
+			"artifacts_expired":    map[string]interface{}{"type": "boolean"},
+			"artifacts_expired_at": map[string]interface{}{"type": "date"},
+			"created_at":           map[string]interface{}{"type": "date"},
+			"started_at":           map[string]interface{}{"type": "date"},
+			"finished_at":          map[string]interface{}{"type": "date"},
+			"indexed_at":           map[string]interface{}{"type": "date"},
+		},
+	},
+}
+
+// pendingChange 送信待ちの変更
+type pendingChange struct {
+	deleted  bool
+	attempts int
+}
+
+// Indexer 解析のサマリー・メトリクスをElasticsearch/OpenSearchのインデックスに反映する
+// 変更の通知（Enqueue）をまとめて_bulkで送信し、失敗した場合は再試行する。主となるDBはPostgreSQLのまま
+type Indexer struct {
+	baseURL string
+	index   string
+	config  Config
+	client  *http.Client
+	db      *storage.DB
+
+	mu      sync.Mutex
+	pending map[string]*pendingChange
+	wake    chan struct{}
+}
+
+// NewIndexer インデクサーを作成する（解析の内容はdbから読み込む）
+func NewIndexer(config Config, db *storage.DB) (*Indexer, error) {
+	if config.URL == "" {
+		return nil, errors.New("search URL is required")
+	}
+	if db == nil {
+		return nil, errors.New("search indexer requires a database")
+	}
+	index := config.Index
+	if index == "" {
+		index = DefaultIndex
+	}
+	return &Indexer{
+		baseURL: strings.TrimRight(config.URL, "/"),
+		index:   index,
+		config:  config,
+		client:  &http.Client{Timeout: requestTimeout},
+		db:      db,
+		pending: make(map[string]*pendingChange),
+		wake:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Index インデックス名
+func (ix *Indexer) Index() string {
+	return ix.index
+}
+
+// EnsureIndex インデックスがなければマッピングを指定して作成する（作成した場合はtrue）
+func (ix *Indexer) EnsureIndex(ctx context.Context) (bool, error) {
+	body, err := json.Marshal(indexMapping)
+	if err != nil {
+		return false, err
+	}
+	status, respBody, err := ix.do(ctx, http.MethodPut, "/"+ix.index, "application/json", body)
+	if err != nil {
+		return false, err
+	}
+	if status >= 200 && status < 300 {
+		return true, nil
+	}
+	if status == http.StatusBadRequest && bytes.Contains(respBody, []byte("resource_already_exists_exception")) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to create index %s: status %d: %s", ix.index, status, truncate(respBody))
+}
+
+// Start 送信用のワーカーを起動する
+func (ix *Indexer) Start() {
+	go ix.worker()
+}
+
+// Enqueue 解析の変更をインデックスへの送信待ちにする（すぐに戻るため、jobs.ManagerのAddChangeListenerに渡せる）
+func (ix *Indexer) Enqueue(id string, deleted bool) {
+	ix.mu.Lock()
+	if change, ok := ix.pending[id]; ok {
+		change.deleted = deleted
+	} else {
+		ix.pending[id] = &pendingChange{deleted: deleted}
+	}
+	ix.mu.Unlock()
+
+	select {
+	case ix.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Reindex DBのすべての解析をインデックスに送信し、送信した数を返す（初回の作成時やインデックスの再構築用）
+func (ix *Indexer) Reindex(ctx context.Context) (int, error) {
+	indexed := 0
+	for offset := 0; ; offset += bulkBatchSize {
+		records, err := ix.db.ListAnalyses(map[string]interface{}{
+			"limit":  bulkBatchSize,
+			"offset": offset,
+		})
+		if err != nil {
+			return indexed, fmt.Errorf("failed to list analyses: %w", err)
+		}
+		if len(records) == 0 {
+			return indexed, nil
+		}
+		failed, err := ix.bulk(ctx, ix.documents(ctx, records), nil)
+		if err != nil {
+			return indexed, err
+		}
+		indexed += len(records) - len(failed)
+		if len(records) < bulkBatchSize {
+			return indexed, nil
+		}
+	}
+}
+
+// worker 送信待ちの変更をまとめて送信する
+func (ix *Indexer) worker() {
+	retry := time.NewTicker(retryDelay)
+	defer retry.Stop()
+	for {
+		select {
+		case <-ix.wake:
+			time.Sleep(flushDelay)
+		case <-retry.C:
+		}
+		for ix.flush() {
+		}
+	}
+}
+
+// flush 送信待ちの変更を最大bulkBatchSize件送信する（続けて送信するものが残っている場合はtrue）
+func (ix *Indexer) flush() bool {
+	ix.mu.Lock()
+	batch := make(map[string]*pendingChange, min(len(ix.pending), bulkBatchSize))
+	for id, change := range ix.pending {
+		if len(batch) >= bulkBatchSize {
+			break
+		}
+		batch[id] = change
+		delete(ix.pending, id)
+	}
+	more := len(ix.pending) > 0
+	ix.mu.Unlock()
+	if len(batch) == 0 {
+		return false
+	}
+
+	// 削除されていない解析はDBから最新の内容を読み込む
+	records := make([]*storage.AnalysisRecord, 0, len(batch))
+	deletes := make([]string, 0)
+	failed := make(map[string]bool)
+	for id, change := range batch {
+		if change.deleted {
+			deletes = append(deletes, id)
+			continue
+		}
+		record, err := ix.db.GetAnalysis(id)
+		if err != nil || record == nil {
+			logging.Warnf("Failed to load analysis %s for search index: %v", id, err)
+			failed[id] = true
+			continue
+		}
+		records = append(records, record)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	rejected, err := ix.bulk(ctx, ix.documents(ctx, records), deletes)
+	if err != nil {
+		logging.Warnf("Failed to update search index %s: %v", ix.index, err)
+		// 送信できなかった場合はすべて再試行する
+		for id := range batch {
+			failed[id] = true
+		}
+	}
+	for _, id := range rejected {
+		failed[id] = true
+	}
+	if len(failed) > 0 {
+		ix.requeue(batch, failed)
+		// 失敗した場合は再試行まで待つ
+		return false
+	}
+	return more
+}
+
+// requeue 送信に失敗した変更を送信待ちに戻す（その間に新しい変更が届いた場合はそちらを優先する）
+func (ix *Indexer) requeue(batch map[string]*pendingChange, failed map[string]bool) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for id := range failed {
+		change := batch[id]
+		change.attempts++
+		if change.attempts >= maxAttempts {
+			logging.Errorf("Giving up indexing analysis %s after %d attempts", id, change.attempts)
+			continue
+		}
+		if _, ok := ix.pending[id]; !ok {
+			ix.pending[id] = change
+		}
+	}
+}
+
+// documents DBのレコードからインデックスのドキュメントを作成する（固定・成果物の削除の状態を含む）
+func (ix *Indexer) documents(ctx context.Context, records []*storage.AnalysisRecord) []Document {
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	pinned, err := ix.db.PinnedAnalyses(ctx, ids)
+	if err != nil {
+		logging.Warnf("Failed to load pinned analyses for search index: %v", err)
+	}
+	expired, err := ix.db.ArtifactsExpiredAt(ctx, ids)
+	if err != nil {
+		logging.Warnf("Failed to load expired artifacts for search index: %v", err)
+	}
+
+	now := time.Now()
+	docs := make([]Document, 0, len(records))
+	for _, record := range records {
+		doc := Document{
+			ID:         record.ID,
+			UniProtID:  record.UniProtID,
+			Method:     record.Method,
+			Status:     record.Status,
+			Params:     make(map[string]interface{}, len(record.Params)),
+			Metrics:    record.Metrics,
+			Pinned:     pinned[record.ID],
+			CreatedAt:  record.CreatedAt,
+			StartedAt:  record.StartedAt,
+			FinishedAt: record.FinishedAt,
+			IndexedAt:  now,
+		}
+		if record.ErrorMessage != nil {
+			doc.ErrorMessage = *record.ErrorMessage
+		}
+		if expiredAt, ok := expired[record.ID]; ok {
+			doc.ArtifactsExpired = true
+			doc.ArtifactsExpiredAt = &expiredAt
+		}
+		// セッションIDは検索対象にしない
+		for key, value := range record.Params {
+			if key != "session_id" {
+				doc.Params[key] = value
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// bulk ドキュメントの保存と削除を_bulkで送信し、拒否された解析のIDを返す
+func (ix *Indexer) bulk(ctx context.Context, docs []Document, deletes []string) ([]string, error) {
+	if len(docs) == 0 && len(deletes) == 0 {
+		return nil, nil
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, doc := range docs {
+		enc.Encode(map[string]interface{}{"index": map[string]string{"_index": ix.index, "_id": doc.ID}})
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("failed to encode document %s: %w", doc.ID, err)
+		}
+	}
+	for _, id := range deletes {
+		enc.Encode(map[string]interface{}{"delete": map[string]string{"_index": ix.index, "_id": id}})
+	}
+
+	status, respBody, err := ix.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if status < 200 || status >= 300 {
+		return nil, fmt.Errorf("bulk request failed: status %d: %s", status, truncate(respBody))
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("invalid bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil, nil
+	}
+	rejected := make([]string, 0)
+	for _, item := range resp.Items {
+		for action, result := range item {
+			// 既に存在しないドキュメントの削除は成功とみなす
+			if action == "delete" && result.Status == http.StatusNotFound {
+				continue
+			}
+			if result.Status >= 300 {
+				logging.Warnf("Search index rejected %s of %s: %s", action, result.ID, truncate(result.Error))
+				rejected = append(rejected, result.ID)
+			}
+		}
+	}
+	return rejected, nil
+}
+
+// do Elasticsearch/OpenSearchにリクエストを送り、ステータスコードとボディを返す
+func (ix *Indexer) do(ctx context.Context, method, path, contentType string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, ix.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if ix.config.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+ix.config.APIKey)
+	} else if ix.config.Username != "" {
+		req.SetBasicAuth(ix.config.Username, ix.config.Password)
+	}
+
+	resp, err := ix.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, respBody, nil
+}
+
+// truncate エラーメッセージに含めるレスポンスを短くする
+func truncate(body []byte) string {
+	const limit = 500
+	if len(body) > limit {
+		return string(body[:limit]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 件数の絞り込みに使うListAnalysesのフィルターと条件（limit・offsetは使わない）
+var analysisCountConditions = []struct {
+	key       string
+	condition string
+}{
+	{"session_id", "session_id = $%d"},
+	{"uniprot_id", "uniprot_id = $%d"},
+	{"method", "method = $%d"},
+	{"status", "status = $%d"},
+	{"from", "created_at >= $%d::timestamptz"},
+	{"to", "created_at <= $%d::timestamptz"},
+}
+
+// analysisFilterConditions ListAnalysesのフィルターの条件と引数（$1から）
+func analysisFilterConditions(filters map[string]interface{}) ([]string, []interface{}) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	for _, c := range analysisCountConditions {
+		value, ok := filters[c.key]
+		if !ok {
+			continue
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(c.condition, len(args)))
+	}
+	return conditions, args
+}
+
+// CountAnalysesMatching ListAnalyses・SearchAnalysesと同じフィルターに一致する解析の件数（ページングの総件数用）
+func (db *DB) CountAnalysesMatching(ctx context.Context, filters map[string]interface{}) (int, error) {
+	conditions, args := searchConditions(filters)
+
+	var count int
+	query := `SELECT COUNT(*) FROM analyses WHERE ` + strings.Join(conditions, " AND ")
+	if err := db.conn.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AnalysisNote analysis_notesテーブルの行（解析のメモ）
+type AnalysisNote struct {
+	ID         string
+	AnalysisID string
+	Body       string
+	// 作成したセッション・ユーザー（匿名のセッションではUserID・AuthorEmailは空）
+	SessionID   string
+	UserID      string
+	AuthorEmail string
+	CreatedAt   time.Time
+}
+
+const analysisNoteColumns = `id, analysis_id, body, session_id, user_id, author_email, created_at`
+
+func scanAnalysisNote(row interface{ Scan(...interface{}) error }) (*AnalysisNote, error) {
+	n := &AnalysisNote{}
+	var sessionID, userID, email sql.NullString
+	if err := row.Scan(&n.ID, &n.AnalysisID, &n.Body, &sessionID, &userID, &email, &n.CreatedAt); err != nil {
+		return nil, err
+	}
+	n.SessionID, n.UserID, n.AuthorEmail = sessionID.String, userID.String, email.String
+	return n, nil
+}
+
+// AddAnalysisTags 解析にタグを追加する（付いているタグは変更しない）
+func (db *DB) AddAnalysisTags(ctx context.Context, id string, tags []string) error {
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO analysis_tags (analysis_id, tag, created_at)
+		SELECT $1, tag, NOW() FROM unnest($2::text[]) AS tag
+		ON CONFLICT (analysis_id, tag) DO NOTHING
+	`, id, pq.Array(tags))
+	return err
+}
+
+// RemoveAnalysisTag 解析からタグを外す（付いていた場合はtrue）
+func (db *DB) RemoveAnalysisTag(ctx context.Context, id, tag string) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM analysis_tags WHERE analysis_id = $1 AND tag = $2`, id, tag)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AnalysisTags 解析ごとのタグ（名前順、タグのない解析は含まない）
+func (db *DB) AnalysisTags(ctx context.Context, ids []string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT analysis_id, tag
+		FROM analysis_tags
+		WHERE analysis_id = ANY($1)
+		ORDER BY analysis_id, tag
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	return tags, rows.Err()
+}
+
+// CreateAnalysisNote 解析にメモを追加する
+func (db *DB) CreateAnalysisNote(ctx context.Context, note *AnalysisNote) (*AnalysisNote, error) {
+	row := db.conn.QueryRowContext(ctx, `
+		INSERT INTO analysis_notes (id, analysis_id, body, session_id, user_id, author_email, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING `+analysisNoteColumns, note.ID, note.AnalysisID, note.Body,
+		sql.NullString{String: note.SessionID, Valid: note.SessionID != ""},
+		sql.NullString{String: note.UserID, Valid: note.UserID != ""},
+		sql.NullString{String: note.AuthorEmail, Valid: note.AuthorEmail != ""})
+	return scanAnalysisNote(row)
+}
+
+// GetAnalysisNote 解析のメモを返す（ない場合はsql.ErrNoRows）
+func (db *DB) GetAnalysisNote(ctx context.Context, analysisID, id string) (*AnalysisNote, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+analysisNoteColumns+` FROM analysis_notes WHERE id = $1 AND analysis_id = $2`, id, analysisID)
+	return scanAnalysisNote(row)
+}
+
+// ListAnalysisNotes 解析のメモを古い順に返す
+func (db *DB) ListAnalysisNotes(ctx context.Context, analysisID string) ([]*AnalysisNote, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT `+analysisNoteColumns+`
+		FROM analysis_notes
+		WHERE analysis_id = $1
+		ORDER BY created_at, id
+	`, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make([]*AnalysisNote, 0)
+	for rows.Next() {
+		n, err := scanAnalysisNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// DeleteAnalysisNote 解析のメモを削除する（削除した場合はtrue）
+func (db *DB) DeleteAnalysisNote(ctx context.Context, analysisID, id string) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM analysis_notes WHERE id = $1 AND analysis_id = $2`, id, analysisID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ArchiveConfig 長期保存先（機関のS3 Glacier等、R2とは別の認証情報・バケット）
+type ArchiveConfig struct {
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// S3互換のエンドポイント（空の場合はAWSのS3）
+	Endpoint string
+	// 保存時のストレージクラス（GLACIER・DEEP_ARCHIVE・GLACIER_IR等、空の場合はGLACIER）
+	StorageClass string
+	// キーの前に付けるプレフィックス（他の用途と同じバケットを使う場合）
+	Prefix string
+}
+
+// ArchiveClient 長期保存先のクライアント（キーはR2と同じものにPrefixを付ける）
+type ArchiveClient struct {
+	client       *s3.Client
+	bucket       string
+	storageClass string
+	prefix       string
+}
+
+// ArchiveRestoreState 長期保存先のオブジェクトの復元の状態
+type ArchiveRestoreState struct {
+	// 読み出せる（復元済み、またはGLACIER_IR等の即時に読み出せるストレージクラス）
+	Ready bool
+	// 復元中
+	Ongoing bool
+	// 一時的な復元コピーの有効期限（分からない場合はnil）
+	ExpiresAt *time.Time
+}
+
+// NewArchiveClient 長期保存先のクライアントを作成する
+func NewArchiveClient(cfg ArchiveConfig) (*ArchiveClient, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("archive bucket and credentials are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.StorageClass == "" {
+		cfg.StorageClass = string(types.StorageClassGlacier)
+	}
+	if !validStorageClass(cfg.StorageClass) {
+		return nil, fmt.Errorf("unknown storage class: %s", cfg.StorageClass)
+	}
+
+	accessKeyID, secretAccessKey := cfg.AccessKeyID, cfg.SecretAccessKey
+	options := s3.Options{
+		Region: cfg.Region,
+		Credentials: aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, Source: "ArchiveConfig"}, nil
+		})),
+	}
+	if cfg.Endpoint != "" {
+		options.BaseEndpoint = aws.String(cfg.Endpoint)
+		options.UsePathStyle = true
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &ArchiveClient{
+		client:       s3.New(options),
+		bucket:       cfg.Bucket,
+		storageClass: cfg.StorageClass,
+		prefix:       prefix,
+	}, nil
+}
+
+func validStorageClass(class string) bool {
+	for _, c := range types.StorageClassStandard.Values() {
+		if string(c) == class {
+			return true
+		}
+	}
+	return false
+}
+
+// StorageClass 保存時のストレージクラス
+func (a *ArchiveClient) StorageClass() string {
+	return a.storageClass
+}
+
+func (a *ArchiveClient) key(key string) string {
+	return a.prefix + key
+}
+
+// PutObject オブジェクトを保存時のストレージクラスで保存する
+func (a *ArchiveClient) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := a.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(a.bucket),
+		Key:          aws.String(a.key(key)),
+		Body:         bytes.NewReader(data),
+		ContentType:  aws.String(contentType),
+		StorageClass: types.StorageClass(a.storageClass),
+	})
+	return err
+}
+
+// GetObject オブジェクトを取得する（GLACIER・DEEP_ARCHIVEは復元済みの場合のみ）
+func (a *ArchiveClient) GetObject(ctx context.Context, key string) ([]byte, error) {
+	out, err := a.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.key(key)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+// RestoreObject 一時的な復元コピーを要求する（days日間読み出せる、tierはExpedited・Standard・Bulk）
+// 復元中のオブジェクトに再度要求した場合はエラーにしない
+func (a *ArchiveClient) RestoreObject(ctx context.Context, key string, days int, tier string) error {
+	_, err := a.client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.key(key)),
+		RestoreRequest: &types.RestoreRequest{
+			Days:                 aws.Int32(int32(days)),
+			GlacierJobParameters: &types.GlacierJobParameters{Tier: types.Tier(tier)},
+		},
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress" {
+		return nil
+	}
+	return err
+}
+
+// restoreExpiryPattern x-amz-restoreヘッダーの有効期限（ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"）
+var restoreExpiryPattern = regexp.MustCompile(`expiry-date="([^"]+)"`)
+
+// RestoreStatus オブジェクトの復元の状態を返す
+func (a *ArchiveClient) RestoreStatus(ctx context.Context, key string) (ArchiveRestoreState, error) {
+	out, err := a.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(a.key(key)),
+	})
+	if err != nil {
+		return ArchiveRestoreState{}, err
+	}
+	restore := aws.ToString(out.Restore)
+	if restore == "" {
+		// 復元が不要なストレージクラス（GLACIER_IR等）は即時に読み出せる
+		class := out.StorageClass
+		return ArchiveRestoreState{Ready: class != types.StorageClassGlacier && class != types.StorageClassDeepArchive}, nil
+	}
+	if strings.Contains(restore, `ongoing-request="true"`) {
+		return ArchiveRestoreState{Ongoing: true}, nil
+	}
+	state := ArchiveRestoreState{Ready: true}
+	if match := restoreExpiryPattern.FindStringSubmatch(restore); match != nil {
+		if t, err := time.Parse(time.RFC1123, match[1]); err == nil {
+			state.ExpiresAt = &t
+		}
+	}
+	return state, nil
+}
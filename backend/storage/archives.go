@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 長期保存先に移した解析の状態（analysis_archives.status）
+const (
+	ArchiveStatusArchiving = "archiving"
+	ArchiveStatusArchived  = "archived"
+	ArchiveStatusRestoring = "restoring"
+	ArchiveStatusRestored  = "restored"
+	ArchiveStatusFailed    = "failed"
+)
+
+// ArchivedObject 長期保存先に移したオブジェクト（キーはR2と同じ）
+type ArchivedObject struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// AnalysisArchive analysis_archivesテーブルの行
+type AnalysisArchive struct {
+	AnalysisID         string
+	Status             string
+	StorageClass       string
+	Objects            []ArchivedObject
+	Bytes              int64
+	Error              *string
+	ArchivedAt         *time.Time
+	RestoreTier        *string
+	RestoreRequestedAt *time.Time
+	RestoreCompletedAt *time.Time
+	RestoreExpiresAt   *time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+const analysisArchiveColumns = `
+	analysis_id, status, storage_class, objects, bytes, error, archived_at,
+	restore_tier, restore_requested_at, restore_completed_at, restore_expires_at, created_at, updated_at
+`
+
+func scanAnalysisArchive(row interface{ Scan(...interface{}) error }) (*AnalysisArchive, error) {
+	a := &AnalysisArchive{}
+	var objects []byte
+	if err := row.Scan(&a.AnalysisID, &a.Status, &a.StorageClass, &objects, &a.Bytes, &a.Error, &a.ArchivedAt,
+		&a.RestoreTier, &a.RestoreRequestedAt, &a.RestoreCompletedAt, &a.RestoreExpiresAt, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if len(objects) > 0 {
+		if err := json.Unmarshal(objects, &a.Objects); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// GetAnalysisArchive 解析の長期保存の状態を返す（長期保存先に移していない場合はsql.ErrNoRows）
+func (db *DB) GetAnalysisArchive(ctx context.Context, id string) (*AnalysisArchive, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+analysisArchiveColumns+` FROM analysis_archives WHERE analysis_id = $1`, id)
+	return scanAnalysisArchive(row)
+}
+
+// ListAnalysisArchives 指定した状態の長期保存を更新の古い順に返す（移動の再開・復元の確認用）
+func (db *DB) ListAnalysisArchives(statuses []string) ([]*AnalysisArchive, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+analysisArchiveColumns+`
+		FROM analysis_archives
+		WHERE status = ANY($1)
+		ORDER BY updated_at ASC
+	`, pq.Array(statuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	archives := make([]*AnalysisArchive, 0)
+	for rows.Next() {
+		a, err := scanAnalysisArchive(rows)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, a)
+	}
+	return archives, rows.Err()
+}
+
+// StartAnalysisArchive 長期保存先への移動を開始したことを記録する
+// 記録がない場合・復元済み・失敗した場合のみ開始し、移動中・長期保存中・復元待ちの場合はfalseを返す
+func (db *DB) StartAnalysisArchive(id, storageClass string) (bool, error) {
+	result, err := db.conn.Exec(`
+		INSERT INTO analysis_archives (analysis_id, status, storage_class)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (analysis_id) DO UPDATE SET
+			status = EXCLUDED.status,
+			storage_class = EXCLUDED.storage_class,
+			error = NULL,
+			updated_at = NOW()
+		WHERE analysis_archives.status IN ($4, $5)
+	`, id, ArchiveStatusArchiving, storageClass, ArchiveStatusRestored, ArchiveStatusFailed)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CompleteAnalysisArchive 長期保存先への移動が完了したことを記録する（復元の記録はリセットする）
+func (db *DB) CompleteAnalysisArchive(id string, objects []ArchivedObject) error {
+	data, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+	var bytes int64
+	for _, obj := range objects {
+		bytes += obj.Size
+	}
+	_, err = db.conn.Exec(`
+		UPDATE analysis_archives SET
+			status = $2,
+			objects = $3,
+			bytes = $4,
+			error = NULL,
+			archived_at = NOW(),
+			restore_tier = NULL,
+			restore_requested_at = NULL,
+			restore_completed_at = NULL,
+			restore_expires_at = NULL,
+			updated_at = NOW()
+		WHERE analysis_id = $1
+	`, id, ArchiveStatusArchived, data, bytes)
+	return err
+}
+
+// FailAnalysisArchive 長期保存先への移動に失敗したことを記録する（R2のオブジェクトは残っている）
+func (db *DB) FailAnalysisArchive(id, message string) error {
+	_, err := db.conn.Exec(`
+		UPDATE analysis_archives SET status = $2, error = $3, updated_at = NOW()
+		WHERE analysis_id = $1
+	`, id, ArchiveStatusFailed, message)
+	return err
+}
+
+// StartArchiveRestore 復元を要求したことを記録する（長期保存中の場合のみ、それ以外はfalse）
+func (db *DB) StartArchiveRestore(id, tier string) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE analysis_archives SET
+			status = $2,
+			restore_tier = $3,
+			restore_requested_at = NOW(),
+			restore_completed_at = NULL,
+			restore_expires_at = NULL,
+			error = NULL,
+			updated_at = NOW()
+		WHERE analysis_id = $1 AND status = $4
+	`, id, ArchiveStatusRestoring, tier, ArchiveStatusArchived)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// CancelArchiveRestore 復元の要求に失敗した場合に長期保存中に戻す
+func (db *DB) CancelArchiveRestore(id, message string) error {
+	_, err := db.conn.Exec(`
+		UPDATE analysis_archives SET status = $2, error = $3, updated_at = NOW()
+		WHERE analysis_id = $1 AND status = $4
+	`, id, ArchiveStatusArchived, message, ArchiveStatusRestoring)
+	return err
+}
+
+// CompleteArchiveRestore 復元したオブジェクトをR2に戻したことを記録する
+func (db *DB) CompleteArchiveRestore(id string, expiresAt *time.Time) error {
+	_, err := db.conn.Exec(`
+		UPDATE analysis_archives SET
+			status = $2,
+			restore_completed_at = NOW(),
+			restore_expires_at = $3,
+			error = NULL,
+			updated_at = NOW()
+		WHERE analysis_id = $1
+	`, id, ArchiveStatusRestored, expiresAt)
+	return err
+}
+
+// SetAnalysisArchiveError 状態を変えずに直近のエラーを記録する（復元の確認の失敗等、次の確認で再試行する）
+func (db *DB) SetAnalysisArchiveError(id, message string) error {
+	_, err := db.conn.Exec(`
+		UPDATE analysis_archives SET error = $2, updated_at = NOW()
+		WHERE analysis_id = $1
+	`, id, message)
+	return err
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen サーキットブレーカーが開いている（外部サービスが停止中とみなしている）
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState サーキットブレーカーの状態
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// CircuitBreaker 連続失敗が閾値を超えたら一定時間呼び出しを遮断する
+// 遮断中は即座にErrCircuitOpenを返し、各リクエストがタイムアウトまで待たされるのを防ぐ
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu               sync.Mutex
+	state            BreakerState
+	consecutiveFails int
+	openedAt         time.Time
+	lastError        string
+	probing          bool
+	totalFailures    int64
+	totalRejected    int64
+}
+
+// BreakerStatus サーキットブレーカーの状態（ヘルスチェック・メトリクス用）
+type BreakerStatus struct {
+	Name                string       `json:"name"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+	TotalFailures       int64        `json:"total_failures"`
+	TotalRejected       int64        `json:"total_rejected"`
+}
+
+// NewCircuitBreaker threshold回連続で失敗したらcooldownの間遮断する
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// Allow 呼び出しを許可するか判定する
+// 遮断中でもcooldown経過後は1件だけ試行（half-open）を許可する
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			b.totalRejected++
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			b.totalRejected++
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Record 呼び出し結果を記録する
+func (b *CircuitBreaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.state = BreakerClosed
+		b.consecutiveFails = 0
+		return
+	}
+
+	b.consecutiveFails++
+	b.totalFailures++
+	b.lastError = err.Error()
+	if b.state == BreakerHalfOpen || b.consecutiveFails >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Status 現在の状態を返す
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Name:                b.name,
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFails,
+		LastError:           b.lastError,
+		TotalFailures:       b.totalFailures,
+		TotalRejected:       b.totalRejected,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"dsa-api/logging"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ChecksumMetadataKey 成果物のSHA-256を保存するオブジェクトのメタデータのキー（x-amz-meta-sha256）
+const ChecksumMetadataKey = "sha256"
+
+// ErrChecksumMismatch 成果物の内容がアップロード時のSHA-256と一致しない
+var ErrChecksumMismatch = errors.New("artifact checksum mismatch")
+
+// ArtifactChecksum アップロード時に計算した成果物のSHA-256（analysis_artifact_checksumsテーブルの行）
+type ArtifactChecksum struct {
+	Name   string `json:"-"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// NewArtifactChecksum 成果物の内容のSHA-256を計算する
+func NewArtifactChecksum(name string, data []byte) ArtifactChecksum {
+	sum := sha256.Sum256(data)
+	return ArtifactChecksum{Name: name, SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))}
+}
+
+// Verify 内容が一致するか（一致しない場合はErrChecksumMismatch）
+func (c ArtifactChecksum) Verify(data []byte) error {
+	if actual := NewArtifactChecksum(c.Name, data); actual.SHA256 != c.SHA256 {
+		return fmt.Errorf("%w: %s (expected %s, got %s)", ErrChecksumMismatch, c.Name, c.SHA256, actual.SHA256)
+	}
+	return nil
+}
+
+// SaveArtifactChecksums 解析の成果物のSHA-256を保存する（同じ名前は上書きする）
+func (db *DB) SaveArtifactChecksums(id string, checksums []ArtifactChecksum) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, c := range checksums {
+		_, err := tx.Exec(`
+			INSERT INTO analysis_artifact_checksums (analysis_id, name, sha256, size, created_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (analysis_id, name) DO UPDATE SET
+				sha256 = EXCLUDED.sha256,
+				size = EXCLUDED.size,
+				created_at = NOW()
+		`, id, c.Name, c.SHA256, c.Size)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetArtifactChecksums 解析の成果物のSHA-256（名前をキーにする、記録前の解析は空）
+func (db *DB) GetArtifactChecksums(ctx context.Context, id string) (map[string]ArtifactChecksum, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT name, sha256, size FROM analysis_artifact_checksums WHERE analysis_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := make(map[string]ArtifactChecksum)
+	for rows.Next() {
+		var c ArtifactChecksum
+		if err := rows.Scan(&c.Name, &c.SHA256, &c.Size); err != nil {
+			return nil, err
+		}
+		checksums[c.Name] = c
+	}
+	return checksums, rows.Err()
+}
+
+// checksumReader 読み終えたときに内容のSHA-256を検証する
+type checksumReader struct {
+	io.ReadCloser
+	expected ArtifactChecksum
+	hash     hash.Hash
+}
+
+// NewChecksumReader 最後まで読んだときに内容が一致しなければErrChecksumMismatchを返すReadCloser
+// 途中で閉じた場合（範囲指定の取得等）は検証しない
+func NewChecksumReader(body io.ReadCloser, expected ArtifactChecksum) io.ReadCloser {
+	return &checksumReader{ReadCloser: body, expected: expected, hash: sha256.New()}
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(r.hash.Sum(nil)); actual != r.expected.SHA256 {
+			logging.Errorf("Checksum mismatch for %s: expected %s, got %s", r.expected.Name, r.expected.SHA256, actual)
+			return n, fmt.Errorf("%w: %s (expected %s, got %s)", ErrChecksumMismatch, r.expected.Name, r.expected.SHA256, actual)
+		}
+	}
+	return n, err
+}
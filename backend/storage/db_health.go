@@ -0,0 +1,8 @@
+package storage
+
+import "context"
+
+// Ping DBへの疎通を確認する（診断用）
+func (db *DB) Ping(ctx context.Context) error {
+	return db.conn.PingContext(ctx)
+}
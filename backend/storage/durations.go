@@ -0,0 +1,45 @@
+package storage
+
+// DurationAverage 構造数の区分ごとの解析の所要時間の平均
+type DurationAverage struct {
+	Runs       int
+	AvgSeconds float64
+}
+
+// RecordAnalysisDuration 区分の所要時間の平均に1回分を加え、更新後の平均を返す
+func (db *DB) RecordAnalysisDuration(bucket string, seconds float64) (*DurationAverage, error) {
+	query := `
+		INSERT INTO analysis_durations (size_bucket, runs, avg_seconds, updated_at)
+		VALUES ($1, 1, $2, NOW())
+		ON CONFLICT (size_bucket) DO UPDATE SET
+			runs = analysis_durations.runs + 1,
+			avg_seconds = analysis_durations.avg_seconds + ($2 - analysis_durations.avg_seconds) / (analysis_durations.runs + 1),
+			updated_at = NOW()
+		RETURNING runs, avg_seconds
+	`
+	avg := &DurationAverage{}
+	if err := db.conn.QueryRow(query, bucket, seconds).Scan(&avg.Runs, &avg.AvgSeconds); err != nil {
+		return nil, err
+	}
+	return avg, nil
+}
+
+// ListAnalysisDurations 区分ごとの所要時間の平均を返す
+func (db *DB) ListAnalysisDurations() (map[string]DurationAverage, error) {
+	rows, err := db.conn.Query(`SELECT size_bucket, runs, avg_seconds FROM analysis_durations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	averages := make(map[string]DurationAverage)
+	for rows.Next() {
+		var bucket string
+		var avg DurationAverage
+		if err := rows.Scan(&bucket, &avg.Runs, &avg.AvgSeconds); err != nil {
+			return nil, err
+		}
+		averages[bucket] = avg
+	}
+	return averages, rows.Err()
+}
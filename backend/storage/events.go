@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// AnalysisEvent analysis_eventsテーブルの行（ジョブの状態遷移などの記録）
+type AnalysisEvent struct {
+	ID         int64
+	AnalysisID string
+	Type       string
+	FromStatus *string
+	ToStatus   *string
+	Message    *string
+	Data       map[string]interface{}
+	CreatedAt  time.Time
+}
+
+// CreateAnalysisEvent イベントを追加する（CreatedAtが未設定の場合は現在時刻）
+func (db *DB) CreateAnalysisEvent(event *AnalysisEvent) error {
+	var data []byte
+	if event.Data != nil {
+		var err error
+		data, err = json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+	}
+	createdAt := event.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO analysis_events (analysis_id, event_type, from_status, to_status, message, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+	return db.conn.QueryRow(query,
+		event.AnalysisID, event.Type, event.FromStatus, event.ToStatus, event.Message, eventDataParam(data), createdAt,
+	).Scan(&event.ID)
+}
+
+// ListAnalysisEvents 解析のイベントを古い順に返す
+func (db *DB) ListAnalysisEvents(ctx context.Context, analysisID string) ([]*AnalysisEvent, error) {
+	query := `
+		SELECT id, analysis_id, event_type, from_status, to_status, message, data, created_at
+		FROM analysis_events
+		WHERE analysis_id = $1
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := db.conn.QueryContext(ctx, query, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*AnalysisEvent, 0)
+	for rows.Next() {
+		event := &AnalysisEvent{}
+		var data []byte
+		if err := rows.Scan(&event.ID, &event.AnalysisID, &event.Type, &event.FromStatus, &event.ToStatus, &event.Message, &data, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &event.Data); err != nil {
+				return nil, err
+			}
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func eventDataParam(data []byte) interface{} {
+	if data == nil {
+		return sql.NullString{}
+	}
+	return string(data)
+}
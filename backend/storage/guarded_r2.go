@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"dsa-api/logging"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// GuardedR2Client 保存先（R2Client等のObjectStore）をサーキットブレーカーで保護するラッパー
+// R2が停止している間は即座に失敗し、取得系はローカルキャッシュから返す（縮退運転）
+type GuardedR2Client struct {
+	client    ObjectStore
+	breaker   *CircuitBreaker
+	cacheDir  string
+	multipart MultipartOptions
+}
+
+// NewGuardedR2Client cacheDirが空の場合はローカルキャッシュを使用しない
+func NewGuardedR2Client(client ObjectStore, breaker *CircuitBreaker, cacheDir string) *GuardedR2Client {
+	return &GuardedR2Client{
+		client:   client,
+		breaker:  breaker,
+		cacheDir: cacheDir,
+	}
+}
+
+// BreakerStatus サーキットブレーカーの状態を返す
+func (g *GuardedR2Client) BreakerStatus() BreakerStatus {
+	return g.breaker.Status()
+}
+
+// Degraded 縮退運転中（ブレーカーが閉じていない）かどうか
+func (g *GuardedR2Client) Degraded() bool {
+	return g.breaker.Status().State != BreakerClosed
+}
+
+// SetMultipartOptions パートサイズ以上のアップロードをマルチパートにする設定（パートごとに再試行する）
+func (g *GuardedR2Client) SetMultipartOptions(opts MultipartOptions) {
+	g.multipart = opts
+}
+
+func (g *GuardedR2Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := g.breaker.Allow(); err != nil {
+		return err
+	}
+	var err error
+	if opts := g.multipart.withDefaults(); int64(len(data)) >= opts.PartSize {
+		_, err = g.client.PutObjectStream(ctx, key, bytes.NewReader(data), contentType, g.multipart)
+	} else {
+		err = retryUpload(ctx, opts.Retries, func() error {
+			return g.client.PutObject(ctx, key, data, contentType)
+		})
+	}
+	g.record(err)
+	if err == nil {
+		g.writeCache(key, data)
+	}
+	return err
+}
+
+func (g *GuardedR2Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	if err := g.breaker.Allow(); err != nil {
+		if data, ok := g.readCache(key); ok {
+			return data, nil
+		}
+		return nil, err
+	}
+	data, err := g.client.GetObject(ctx, key)
+	g.record(err)
+	if err != nil {
+		if isBreakerFailure(err) {
+			if cached, ok := g.readCache(key); ok {
+				logging.Warnf("Serving %s from local cache: %v", key, err)
+				return cached, nil
+			}
+		}
+		return nil, err
+	}
+	g.writeCache(key, data)
+	return data, nil
+}
+
+func (g *GuardedR2Client) GetSignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	// 署名はローカルで計算されるが、R2停止中は利用できないURLを返さないようにする
+	if g.Degraded() {
+		return "", ErrCircuitOpen
+	}
+	return g.client.GetSignedURL(ctx, key, expires)
+}
+
+func (g *GuardedR2Client) GetPublicURL(key string) string {
+	if g.Degraded() {
+		return ""
+	}
+	return g.client.GetPublicURL(key)
+}
+
+func (g *GuardedR2Client) DeleteObjectsWithPrefix(ctx context.Context, prefix string) error {
+	if err := g.breaker.Allow(); err != nil {
+		return err
+	}
+	err := g.client.DeleteObjectsWithPrefix(ctx, prefix)
+	g.record(err)
+	if err == nil {
+		g.removeCache(prefix)
+	}
+	return err
+}
+
+// Probe R2への疎通を確認し、結果をブレーカーに記録する
+func (g *GuardedR2Client) Probe(ctx context.Context) error {
+	err := g.client.HeadBucket(ctx)
+	g.breaker.Record(breakerResult(err))
+	return err
+}
+
+// StartHealthProbe ブレーカーが開いている間、定期的に疎通を確認して復旧を検知する
+func (g *GuardedR2Client) StartHealthProbe(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !g.Degraded() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := g.Probe(ctx); err != nil {
+				logging.Warnf("Object storage health probe failed: %v", err)
+			} else {
+				logging.Infof("Object storage recovered, circuit breaker closed")
+			}
+			cancel()
+		}
+	}()
+}
+
+func (g *GuardedR2Client) record(err error) {
+	g.breaker.Record(breakerResult(err))
+}
+
+// breakerResult ブレーカーの失敗として数えるエラーのみを返す
+func breakerResult(err error) error {
+	if isBreakerFailure(err) {
+		return err
+	}
+	return nil
+}
+
+// isBreakerFailure R2自体の障害を示すエラーか判定する
+// オブジェクトが存在しない等の4xx応答はR2が正常に応答しているため失敗として数えない
+func isBreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() >= 500
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	// ローカルの保存先（LocalStore）のファイルがない・対応していない操作
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ErrNotSupported) {
+		return false
+	}
+	// ネットワークエラー・タイムアウト等
+	return true
+}
+
+// cachePath キーに対応するキャッシュファイルのパス（キャッシュディレクトリ外を指す場合は空）
+func (g *GuardedR2Client) cachePath(key string) string {
+	if g.cacheDir == "" {
+		return ""
+	}
+	path := filepath.Join(g.cacheDir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(g.cacheDir)+string(filepath.Separator)) {
+		return ""
+	}
+	return path
+}
+
+func (g *GuardedR2Client) readCache(key string) ([]byte, bool) {
+	path := g.cachePath(key)
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (g *GuardedR2Client) writeCache(key string, data []byte) {
+	path := g.cachePath(key)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logging.Warnf("Failed to create cache directory for %s: %v", key, err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		logging.Warnf("Failed to write cache for %s: %v", key, err)
+	}
+}
+
+func (g *GuardedR2Client) removeCache(prefix string) {
+	path := g.cachePath(strings.TrimSuffix(prefix, "/"))
+	if path == "" {
+		return
+	}
+	if err := os.RemoveAll(path); err != nil {
+		logging.Warnf("Failed to remove cache for %s: %v", prefix, err)
+	}
+}
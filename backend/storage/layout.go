@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+)
+
+// LayoutCandidate 保存形式の移行対象の解析
+type LayoutCandidate struct {
+	ID            string
+	Status        string
+	StorageLayout int
+	R2Prefix      *string
+}
+
+// StorageKeys 解析の成果物のR2キー（NULLのキーはNULLのまま保存する）
+type StorageKeys struct {
+	R2Prefix   *string
+	ResultKey  *string
+	HeatmapKey *string
+	ScatterKey *string
+	LogsKey    *string
+}
+
+// GetAnalysisStorageLayout 解析の保存形式のバージョンを返す（解析がない場合はsql.ErrNoRows）
+func (db *DB) GetAnalysisStorageLayout(id string) (int, error) {
+	var version int
+	err := db.conn.QueryRow(`SELECT storage_layout FROM analyses WHERE id = $1`, id).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// SetAnalysisStorageLayout 解析の保存形式のバージョンを記録する（作成直後、成果物を保存する前に呼ぶ）
+func (db *DB) SetAnalysisStorageLayout(id string, version int) error {
+	result, err := db.conn.Exec(`UPDATE analyses SET storage_layout = $2 WHERE id = $1`, id, version)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListLayoutCandidates 保存形式がversionでない終了済みの解析を作成順に返す（実行中の解析は移行しない）
+func (db *DB) ListLayoutCandidates(version, limit int) ([]*LayoutCandidate, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, status, storage_layout, r2_prefix FROM analyses
+		WHERE storage_layout <> $1 AND status IN ('done', 'failed', 'cancelled')
+		ORDER BY created_at
+		LIMIT $2
+	`, version, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := make([]*LayoutCandidate, 0)
+	for rows.Next() {
+		c := &LayoutCandidate{}
+		var prefix sql.NullString
+		if err := rows.Scan(&c.ID, &c.Status, &c.StorageLayout, &prefix); err != nil {
+			return nil, err
+		}
+		if prefix.Valid {
+			c.R2Prefix = &prefix.String
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// MoveAnalysisStorage 解析の保存形式とR2キーを更新する
+// 保存形式がfromのままの場合のみ更新し、他の移行と競合した場合はfalseを返す
+func (db *DB) MoveAnalysisStorage(id string, from, to int, keys StorageKeys) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE analyses SET
+			storage_layout = $3,
+			r2_prefix = $4,
+			result_key = $5,
+			heatmap_key = $6,
+			scatter_key = $7,
+			logs_key = $8
+		WHERE id = $1 AND storage_layout = $2
+	`, id, from, to, keys.R2Prefix, keys.ResultKey, keys.HeatmapKey, keys.ScatterKey, keys.LogsKey)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
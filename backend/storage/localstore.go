@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LocalStore ローカルのファイルシステムを保存先にする（STORAGE_BACKEND=local、Cloudflare・クラウドを使わない環境用）
+// キーは<dir>/<key>に保存し、Content-Typeは拡張子から判定する
+// 署名付きURLは発行できない（成果物はAPI経由で配信する）
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore dirを保存先にする
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("local store directory is required")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create local store directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// path キーに対応するファイルのパス（保存先の外を指すキーはエラー）
+func (s *LocalStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("invalid object key: %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStore) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	_, err := s.PutObjectStream(ctx, key, bytes.NewReader(data), contentType, MultipartOptions{})
+	return err
+}
+
+// PutObjectStream 一時ファイルに書き込んでから置き換える（途中で失敗したファイルを読まれないように）
+func (s *LocalStore) PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string, opts MultipartOptions) (int64, error) {
+	dst, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *LocalStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+// GetObjectStream If-None-Match・If-Modified-Sinceに対応する（Rangeは無視して全体を返す）
+// ETagはサイズと更新時刻から作る
+func (s *LocalStore) GetObjectStream(ctx context.Context, key string, req ObjectRequest) (*ObjectStream, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	modTime := info.ModTime().Truncate(time.Second)
+	if (req.IfNoneMatch != "" && req.IfNoneMatch == etag) ||
+		(req.IfNoneMatch == "" && !req.IfModifiedSince.IsZero() && !modTime.After(req.IfModifiedSince)) {
+		f.Close()
+		return nil, ErrNotModified
+	}
+	return &ObjectStream{
+		Body:          f,
+		ContentLength: info.Size(),
+		ContentType:   mime.TypeByExtension(path.Ext(key)),
+		ETag:          etag,
+		LastModified:  info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStore) ListObjectsWithPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			// プレフィックスと関係のないディレクトリは辿らない
+			if key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *LocalStore) DeleteObjectsWithPrefix(ctx context.Context, prefix string) error {
+	objects, err := s.ListObjectsWithPrefix(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		p, err := s.path(obj.Key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// 空になったディレクトリを削除する（プレフィックスがディレクトリの場合）
+	if dir := strings.TrimSuffix(prefix, "/"); dir != "" && strings.HasSuffix(prefix, "/") {
+		if p, err := s.path(dir); err == nil {
+			os.RemoveAll(p)
+		}
+	}
+	return nil
+}
+
+func (s *LocalStore) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	src, err := s.path(srcKey)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = s.PutObjectStream(ctx, dstKey, f, "", MultipartOptions{})
+	return err
+}
+
+func (s *LocalStore) HeadBucket(ctx context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
+
+func (s *LocalStore) GetSignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (s *LocalStore) GetSignedUploadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (s *LocalStore) GetPublicURL(key string) string {
+	return ""
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrNotSupported 保存先が対応していない操作（ローカルの保存先の署名付きURL等）
+var ErrNotSupported = errors.New("operation not supported by object store")
+
+// ObjectStore 成果物の保存先（GuardedR2Clientで保護して使う）
+// R2・S3・GCS・MinIOはS3互換API（R2Client）、localはファイルシステム（LocalStore）で実装する
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte, contentType string) error
+	PutObjectWithMetadata(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) error
+	PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string, opts MultipartOptions) (int64, error)
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	GetObjectStream(ctx context.Context, key string, req ObjectRequest) (*ObjectStream, error)
+	ListObjectsWithPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	DeleteObjectsWithPrefix(ctx context.Context, prefix string) error
+	CopyObject(ctx context.Context, srcKey, dstKey string) error
+	// HeadBucket 保存先への疎通を確認する（ヘルスチェック用）
+	HeadBucket(ctx context.Context) error
+	GetSignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	GetSignedUploadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+	GetPublicURL(key string) string
+}
+
+var (
+	_ ObjectStore = (*R2Client)(nil)
+	_ ObjectStore = (*LocalStore)(nil)
+)
+
+// 保存先の種類（STORAGE_BACKEND）
+const (
+	BackendR2    = "r2"
+	BackendS3    = "s3"
+	BackendGCS   = "gcs"
+	BackendMinIO = "minio"
+	BackendLocal = "local"
+)
+
+// Backends 対応している保存先の種類
+var Backends = []string{BackendR2, BackendS3, BackendGCS, BackendMinIO, BackendLocal}
+
+// S3Config S3互換APIの保存先（AWS S3・GCSの相互運用API・MinIO）
+type S3Config struct {
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// 空の場合はus-east-1（GCSはauto）
+	Region string
+	// 空の場合はAWSのS3（GCSはhttps://storage.googleapis.com）
+	Endpoint string
+	// パス形式のURL（MinIO等、バケット名のサブドメインを使えない場合）
+	UsePathStyle  bool
+	PublicBaseURL string
+}
+
+// NewS3Client S3互換APIのクライアントを作成する（R2以外の保存先用、R2はNewR2Clientを使う）
+// GCSはHMACキーを使った相互運用API（XML API）でアクセスする
+func NewS3Client(backend string, cfg S3Config) (*R2Client, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%s bucket and credentials are required", backend)
+	}
+	switch backend {
+	case BackendS3:
+	case BackendGCS:
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://storage.googleapis.com"
+		}
+		if cfg.Region == "" {
+			cfg.Region = "auto"
+		}
+	case BackendMinIO:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("minio endpoint is required")
+		}
+		cfg.UsePathStyle = true
+	default:
+		return nil, fmt.Errorf("unknown S3-compatible backend: %s", backend)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	accessKeyID, secretAccessKey := cfg.AccessKeyID, cfg.SecretAccessKey
+	options := s3.Options{
+		Region: cfg.Region,
+		Credentials: aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secretAccessKey, Source: "S3Config"}, nil
+		})),
+		UsePathStyle: cfg.UsePathStyle,
+	}
+	if backend != BackendS3 {
+		// GCS・MinIO等のS3互換APIは既定のチェックサム（x-amz-checksum-*ヘッダー・aws-chunkedのアップロード）に対応していない場合があるため、
+		// 操作が必須とする場合のみ計算・検証する
+		options.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		options.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	}
+	if cfg.Endpoint != "" {
+		options.BaseEndpoint = aws.String(cfg.Endpoint)
+	}
+	client := s3.New(options)
+	return &R2Client{
+		client:        client,
+		presignClient: s3.NewPresignClient(client),
+		bucket:        cfg.Bucket,
+		publicBaseURL: cfg.PublicBaseURL,
+	}, nil
+}
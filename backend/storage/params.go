@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// SetAnalysisCLIArgs 解析のパラメータから組み立てたPython CLIの引数を記録する
+func (db *DB) SetAnalysisCLIArgs(id string, args []string) error {
+	_, err := db.conn.Exec(`UPDATE analyses SET cli_args = $2 WHERE id = $1`, id, pq.Array(args))
+	return err
+}
+
+// AnalysisCLIArgs 記録したPython CLIの引数（記録されていない解析はnil）
+func (db *DB) AnalysisCLIArgs(ctx context.Context, id string) ([]string, error) {
+	var args pq.StringArray
+	err := db.conn.QueryRowContext(ctx, `SELECT cli_args FROM analyses WHERE id = $1`, id).Scan(&args)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []string(args), nil
+}
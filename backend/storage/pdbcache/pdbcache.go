@@ -0,0 +1,297 @@
+// Package pdbcache ジョブ間で共有する構造ファイル（mmCIF）のディスクキャッシュ
+// キャッシュにない構造はRCSBから取得し、同じ構造の同時の取得は1回にまとめる
+// 合計サイズが上限を超えた場合は最後に使われた時刻が古いものから削除する（時刻はファイルの更新時刻に記録し、再起動後も引き継ぐ）
+package pdbcache
+
+import (
+	"container/list"
+	"context"
+	"dsa-api/logging"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSourceURL 構造ファイルの取得元（GET <url>/<pdbid>.cif）
+const DefaultSourceURL = "https://files.rcsb.org/download"
+
+// 1ファイルの取得のタイムアウト
+const downloadTimeout = 2 * time.Minute
+
+// PDB ID（4文字の従来の形式と、pdb_00001abcの拡張形式）
+var pdbIDPattern = regexp.MustCompile(`^(?:[0-9][a-z0-9]{3}|pdb_[0-9]{4}[0-9][a-z0-9]{3})$`)
+
+var (
+	// ErrInvalidID PDB IDの形式ではない
+	ErrInvalidID = errors.New("invalid PDB ID")
+	// ErrNotFound 取得元に構造が存在しない
+	ErrNotFound = errors.New("PDB entry not found")
+)
+
+// NormalizeID 前後の空白を除いて小文字にする（形式が正しくない場合はErrInvalidID）
+func NormalizeID(id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if !pdbIDPattern.MatchString(id) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidID, id)
+	}
+	return id, nil
+}
+
+// Cache 構造ファイルのキャッシュ（<dir>/<pdbid>.cif）
+type Cache struct {
+	dir       string
+	limit     int64
+	sourceURL string
+	client    *http.Client
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+	// 取得中の構造（同時に要求された場合は同じ取得の完了を待つ）
+	inflight map[string]*download
+}
+
+type entry struct {
+	id   string
+	size int64
+}
+
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// Stats キャッシュの状態
+type Stats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+	Limit int64 `json:"limit"`
+}
+
+// New dirのキャッシュを開く（既存のファイルは最後に使われた順に引き継ぎ、上限を超えている場合は削除する）
+// limitが0以下の場合は上限なし
+func New(dir string, limit int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create PDB cache directory: %w", err)
+	}
+	c := &Cache{
+		dir:       dir,
+		limit:     limit,
+		sourceURL: DefaultSourceURL,
+		client:    &http.Client{Timeout: downloadTimeout},
+		order:     list.New(),
+		entries:   make(map[string]*list.Element),
+		inflight:  make(map[string]*download),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetSourceURL 構造ファイルの取得元を変更する（ミラー・テスト用）
+func (c *Cache) SetSourceURL(url string) {
+	c.sourceURL = strings.TrimRight(url, "/")
+}
+
+// load 既存のファイルを更新時刻の古い順に登録する（取得途中の一時ファイルは削除する）
+func (c *Cache) load() error {
+	des, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read PDB cache directory: %w", err)
+	}
+	type file struct {
+		id      string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+	for _, de := range des {
+		name := de.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		id, ok := strings.CutSuffix(name, ".cif")
+		if !ok || !pdbIDPattern.MatchString(id) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{id: id, size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range files {
+		c.entries[f.id] = c.order.PushFront(&entry{id: f.id, size: f.size})
+		c.size += f.size
+	}
+	c.evictLocked()
+	return nil
+}
+
+// Open 構造ファイルを開く（キャッシュにない場合は取得する）
+// 呼び出し側はファイルを閉じること（開いた後に上限を超えて削除されても読み込める）
+func (c *Cache) Open(ctx context.Context, pdbID string) (*os.File, int64, error) {
+	id, err := NormalizeID(pdbID)
+	if err != nil {
+		return nil, 0, err
+	}
+	for {
+		c.mu.Lock()
+		if elem, ok := c.entries[id]; ok {
+			c.order.MoveToFront(elem)
+			f, err := os.Open(c.path(id))
+			c.mu.Unlock()
+			if err != nil {
+				// キャッシュの外で削除された場合は登録を外して取得し直す
+				c.remove(id)
+				continue
+			}
+			now := time.Now()
+			os.Chtimes(c.path(id), now, now)
+			return f, elem.Value.(*entry).size, nil
+		}
+		d, ok := c.inflight[id]
+		if !ok {
+			d = &download{done: make(chan struct{})}
+			c.inflight[id] = d
+			go c.fetch(id, d)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-d.done:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+		if d.err != nil {
+			return nil, 0, d.err
+		}
+	}
+}
+
+// Contains キャッシュにあるか（取得はしない）
+func (c *Cache) Contains(pdbID string) bool {
+	id, err := NormalizeID(pdbID)
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[id]
+	return ok
+}
+
+// Stats キャッシュのファイル数・合計サイズ・上限
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{Files: len(c.entries), Bytes: c.size, Limit: c.limit}
+}
+
+// fetch 取得元から構造ファイルを取得して登録する（要求したリクエストがキャンセルされても最後まで取得する）
+func (c *Cache) fetch(id string, d *download) {
+	size, err := c.downloadFile(id)
+	c.mu.Lock()
+	if err == nil {
+		c.entries[id] = c.order.PushFront(&entry{id: id, size: size})
+		c.size += size
+		c.evictLocked()
+	}
+	d.err = err
+	delete(c.inflight, id)
+	c.mu.Unlock()
+	close(d.done)
+	if err != nil {
+		logging.Warnf("Failed to download PDB entry %s: %v", id, err)
+	} else {
+		logging.Debugf("Cached PDB entry %s (%d bytes)", id, size)
+	}
+}
+
+// downloadFile 一時ファイルに書き込んでから置き換える（途中で失敗したファイルを配信しないように）
+func (c *Cache) downloadFile(id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+	url := fmt.Sprintf("%s/%s.cif", c.sourceURL, strings.ToUpper(id))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status from %s: %s", url, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, id+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(id))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return size, nil
+}
+
+// remove 登録を外してファイルを削除する
+func (c *Cache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.removeLocked(elem)
+	}
+}
+
+// evictLocked 上限を超えている間、最後に使われた時刻が古いものから削除する（c.muを保持した状態で呼ぶこと）
+// 上限より大きい1ファイルは、取得した直後のリクエストに返せるように残す
+func (c *Cache) evictLocked() {
+	if c.limit <= 0 {
+		return
+	}
+	for c.size > c.limit && c.order.Len() > 1 {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+func (c *Cache) removeLocked(elem *list.Element) {
+	e := elem.Value.(*entry)
+	c.order.Remove(elem)
+	delete(c.entries, e.id)
+	c.size -= e.size
+	if err := os.Remove(c.path(e.id)); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Failed to remove cached PDB entry %s: %v", e.id, err)
+	}
+}
+
+func (c *Cache) path(id string) string {
+	return filepath.Join(c.dir, id+".cif")
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrProjectNameTaken 同じセッションに同じ名前のプロジェクトがある
+var ErrProjectNameTaken = errors.New("a project with the same name already exists")
+
+// Project projectsテーブルの行（AnalysisCountはプロジェクトに追加した解析の数）
+type Project struct {
+	ID            string
+	SessionID     string
+	Name          string
+	Description   string
+	AnalysisCount int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// ProjectStats プロジェクトの解析の集計
+type ProjectStats struct {
+	Total      int
+	UniProtIDs int
+	ByStatus   map[string]int
+	ByMethod   map[string]int
+	// 完了した解析の指標（SearchMetricsのうち値のある解析が1件以上のもの）
+	Metrics map[string]MetricSummary
+}
+
+// MetricSummary 指標の件数・平均・最小・最大
+type MetricSummary struct {
+	Count int
+	Mean  float64
+	Min   float64
+	Max   float64
+}
+
+const projectColumns = `p.id, p.session_id, p.name, p.description, p.created_at, p.updated_at,
+	(SELECT COUNT(*) FROM project_analyses pa WHERE pa.project_id = p.id)`
+
+func scanProject(row interface{ Scan(...interface{}) error }) (*Project, error) {
+	p := &Project{}
+	if err := row.Scan(&p.ID, &p.SessionID, &p.Name, &p.Description, &p.CreatedAt, &p.UpdatedAt, &p.AnalysisCount); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// projectError 名前の一意制約の違反をErrProjectNameTakenに変換する
+func projectError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrProjectNameTaken
+	}
+	return err
+}
+
+// CreateProject プロジェクトを作成する（同じセッションに同じ名前がある場合はErrProjectNameTaken）
+func (db *DB) CreateProject(ctx context.Context, project *Project) (*Project, error) {
+	_, err := db.conn.ExecContext(ctx, `
+		INSERT INTO projects (id, session_id, name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`, project.ID, project.SessionID, project.Name, project.Description)
+	if err != nil {
+		return nil, projectError(err)
+	}
+	return db.GetProject(ctx, project.SessionID, project.ID)
+}
+
+// GetProject セッションのプロジェクトを返す（ない場合・他のセッションの場合はsql.ErrNoRows）
+func (db *DB) GetProject(ctx context.Context, sessionID, id string) (*Project, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+projectColumns+` FROM projects p WHERE p.id = $1 AND p.session_id = $2`, id, sessionID)
+	return scanProject(row)
+}
+
+// ListProjects セッションのプロジェクトを新しい順に返す
+func (db *DB) ListProjects(ctx context.Context, sessionID string) ([]*Project, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT `+projectColumns+`
+		FROM projects p
+		WHERE p.session_id = $1
+		ORDER BY p.created_at DESC, p.id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := make([]*Project, 0)
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// CountProjects セッションのプロジェクトの数
+func (db *DB) CountProjects(ctx context.Context, sessionID string) (int, error) {
+	var count int
+	err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM projects WHERE session_id = $1`, sessionID).Scan(&count)
+	return count, err
+}
+
+// UpdateProject 名前・説明を変更する（nilの項目は変更しない、ない場合はsql.ErrNoRows）
+func (db *DB) UpdateProject(ctx context.Context, sessionID, id string, name, description *string) (*Project, error) {
+	row := db.conn.QueryRowContext(ctx, `
+		UPDATE projects
+		SET name = COALESCE($3, name), description = COALESCE($4, description), updated_at = NOW()
+		WHERE id = $1 AND session_id = $2
+		RETURNING id
+	`, id, sessionID, name, description)
+	var updatedID string
+	if err := row.Scan(&updatedID); err != nil {
+		return nil, projectError(err)
+	}
+	return db.GetProject(ctx, sessionID, updatedID)
+}
+
+// DeleteProject プロジェクトを削除する（解析は削除しない、削除した場合はtrue）
+func (db *DB) DeleteProject(ctx context.Context, sessionID, id string) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM projects WHERE id = $1 AND session_id = $2`, id, sessionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AddProjectAnalyses プロジェクトに解析を追加する（DBにない解析・追加済みの解析は無視し、追加した数を返す）
+func (db *DB) AddProjectAnalyses(ctx context.Context, projectID string, analysisIDs []string) (int, error) {
+	result, err := db.conn.ExecContext(ctx, `
+		INSERT INTO project_analyses (project_id, analysis_id, added_at)
+		SELECT $1, id, NOW() FROM analyses WHERE id = ANY($2)
+		ON CONFLICT DO NOTHING
+	`, projectID, pq.Array(analysisIDs))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		if _, err := db.conn.ExecContext(ctx, `UPDATE projects SET updated_at = NOW() WHERE id = $1`, projectID); err != nil {
+			return int(n), err
+		}
+	}
+	return int(n), nil
+}
+
+// RemoveProjectAnalysis プロジェクトから解析を外す（外した場合はtrue）
+func (db *DB) RemoveProjectAnalysis(ctx context.Context, projectID, analysisID string) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM project_analyses WHERE project_id = $1 AND analysis_id = $2`, projectID, analysisID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		if _, err := db.conn.ExecContext(ctx, `UPDATE projects SET updated_at = NOW() WHERE id = $1`, projectID); err != nil {
+			return true, err
+		}
+	}
+	return n > 0, nil
+}
+
+// ProjectStats プロジェクトの解析をステータス・手法ごとに数え、完了した解析の指標を集計する
+func (db *DB) ProjectStats(ctx context.Context, projectID string) (*ProjectStats, error) {
+	const members = `FROM analyses WHERE id IN (SELECT analysis_id FROM project_analyses WHERE project_id = $1)`
+	stats := &ProjectStats{
+		ByStatus: make(map[string]int),
+		ByMethod: make(map[string]int),
+		Metrics:  make(map[string]MetricSummary),
+	}
+
+	if err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(DISTINCT uniprot_id) `+members, projectID).
+		Scan(&stats.Total, &stats.UniProtIDs); err != nil {
+		return nil, err
+	}
+	for column, counts := range map[string]map[string]int{"status": stats.ByStatus, "method": stats.ByMethod} {
+		rows, err := db.conn.QueryContext(ctx, `SELECT `+column+`, COUNT(*) `+members+` GROUP BY `+column, projectID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			var count int
+			if err := rows.Scan(&key, &count); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			counts[key] = count
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// 指標ごとに件数・平均・最小・最大を1回のクエリで集計する
+	names := make([]string, 0, len(SearchMetrics))
+	for name := range SearchMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	columns := make([]string, 0, len(names)*4)
+	for _, name := range names {
+		expr := metricExpr(name)
+		columns = append(columns, fmt.Sprintf("COUNT(%s), COALESCE(AVG(%s), 0), COALESCE(MIN(%s), 0), COALESCE(MAX(%s), 0)", expr, expr, expr, expr))
+	}
+	summaries := make([]MetricSummary, len(names))
+	dest := make([]interface{}, 0, len(names)*4)
+	for i := range summaries {
+		dest = append(dest, &summaries[i].Count, &summaries[i].Mean, &summaries[i].Min, &summaries[i].Max)
+	}
+	query := `SELECT ` + strings.Join(columns, ", ") + ` ` + members + ` AND status = 'done' AND metrics IS NOT NULL`
+	if err := db.conn.QueryRowContext(ctx, query, projectID).Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		if summaries[i].Count > 0 {
+			stats.Metrics[name] = summaries[i]
+		}
+	}
+	return stats, nil
+}
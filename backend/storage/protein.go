@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// ProteinInfo UniProtから取得したタンパク質の情報
+type ProteinInfo struct {
+	Name           string `json:"protein_name,omitempty"`
+	Organism       string `json:"organism,omitempty"`
+	SequenceLength int    `json:"sequence_length,omitempty"`
+}
+
+// SetAnalysisProteinInfo 解析にタンパク質の情報を記録する
+func (db *DB) SetAnalysisProteinInfo(id string, info ProteinInfo) error {
+	_, err := db.conn.Exec(`
+		UPDATE analyses SET protein_name = $2, organism = $3, sequence_length = $4
+		WHERE id = $1
+	`, id,
+		sql.NullString{String: info.Name, Valid: info.Name != ""},
+		sql.NullString{String: info.Organism, Valid: info.Organism != ""},
+		sql.NullInt64{Int64: int64(info.SequenceLength), Valid: info.SequenceLength > 0})
+	return err
+}
+
+// ProteinInfos 解析ごとのタンパク質の情報を返す（記録されていない解析は含まない）
+func (db *DB) ProteinInfos(ctx context.Context, ids []string) (map[string]ProteinInfo, error) {
+	infos := make(map[string]ProteinInfo)
+	if len(ids) == 0 {
+		return infos, nil
+	}
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT id, protein_name, organism, sequence_length
+		FROM analyses
+		WHERE id = ANY($1)
+		  AND (protein_name IS NOT NULL OR organism IS NOT NULL OR sequence_length IS NOT NULL)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var name, organism sql.NullString
+		var length sql.NullInt64
+		if err := rows.Scan(&id, &name, &organism, &length); err != nil {
+			return nil, err
+		}
+		infos[id] = ProteinInfo{Name: name.String, Organism: organism.String, SequenceLength: int(length.Int64)}
+	}
+	return infos, rows.Err()
+}
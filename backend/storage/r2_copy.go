@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// CopyObject 同じバケット内でオブジェクトをコピーする（保存形式の移行用、Content-Typeなどのメタデータも引き継ぐ）
+func (r *R2Client) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	_, err := r.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(r.bucket),
+		CopySource: aws.String((&url.URL{Path: r.bucket + "/" + srcKey}).EscapedPath()),
+		Key:        aws.String(dstKey),
+	})
+	return err
+}
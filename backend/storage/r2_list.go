@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ObjectInfo R2オブジェクトのキーとサイズ
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ListObjectsWithPrefix プレフィックスに一致するオブジェクトをすべて列挙する
+func (r *R2Client) ListObjectsWithPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
+
+func (g *GuardedR2Client) ListObjectsWithPrefix(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	objects, err := g.client.ListObjectsWithPrefix(ctx, prefix)
+	g.record(err)
+	return objects, err
+}
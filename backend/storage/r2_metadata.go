@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PutObjectWithMetadata メタデータ（x-amz-meta-*）付きでアップロードする（1回のPutObject、一時的な失敗は再試行しない）
+func (r *R2Client) PutObjectWithMetadata(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+		Metadata:    metadata,
+	})
+	return err
+}
+
+// PutObjectWithMetadata ローカルの保存先はメタデータを保存しない
+func (s *LocalStore) PutObjectWithMetadata(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) error {
+	return s.PutObject(ctx, key, data, contentType)
+}
+
+// PutObjectWithMetadata 一時的な失敗はSetMultipartOptionsの再試行回数まで再試行する
+func (g *GuardedR2Client) PutObjectWithMetadata(ctx context.Context, key string, data []byte, contentType string, metadata map[string]string) error {
+	if err := g.breaker.Allow(); err != nil {
+		return err
+	}
+	err := retryUpload(ctx, g.multipart.withDefaults().Retries, func() error {
+		return g.client.PutObjectWithMetadata(ctx, key, data, contentType, metadata)
+	})
+	g.record(err)
+	if err == nil {
+		g.writeCache(key, data)
+	}
+	return err
+}
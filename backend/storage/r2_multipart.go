@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"dsa-api/logging"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// DefaultMultipartPartSize マルチパートアップロードの1パートのサイズ
+	DefaultMultipartPartSize = 16 << 20
+	// S3互換APIの最小パートサイズ（最後のパートを除く）
+	minMultipartPartSize = 5 << 20
+	// DefaultUploadRetries 1パート（または1回のPutObject）の失敗時の再試行回数
+	DefaultUploadRetries = 3
+	// 再試行の待ち時間（試行ごとに倍にする）
+	uploadRetryBackoff = time.Second
+)
+
+// MultipartOptions PutObjectStreamの設定（0の項目はデフォルト値）
+type MultipartOptions struct {
+	// 1パートのサイズ（これより小さいオブジェクトは1回のPutObjectでアップロードする）
+	PartSize int64
+	// 1パートの失敗時の再試行回数（負の値は再試行しない）
+	Retries int
+}
+
+func (o MultipartOptions) withDefaults() MultipartOptions {
+	if o.PartSize <= 0 {
+		o.PartSize = DefaultMultipartPartSize
+	}
+	if o.PartSize < minMultipartPartSize {
+		o.PartSize = minMultipartPartSize
+	}
+	if o.Retries == 0 {
+		o.Retries = DefaultUploadRetries
+	}
+	if o.Retries < 0 {
+		o.Retries = 0
+	}
+	return o
+}
+
+// PutObjectStream bodyを読みながらアップロードし、アップロードしたバイト数を返す
+// PartSize以上の場合はマルチパートアップロードでパートごとに再試行し、失敗した場合はアップロードを中止する
+// メモリに保持するのは1パート分のみ
+func (r *R2Client) PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string, opts MultipartOptions) (int64, error) {
+	opts = opts.withDefaults()
+	buf := make([]byte, opts.PartSize)
+	n, err := io.ReadFull(body, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// 1パートに収まる場合は通常のアップロード
+		part := buf[:n]
+		err := retryUpload(ctx, opts.Retries, func() error {
+			_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket:      aws.String(r.bucket),
+				Key:         aws.String(key),
+				Body:        bytes.NewReader(part),
+				ContentType: aws.String(contentType),
+			})
+			return err
+		})
+		return int64(n), err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	created, err := r.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	uploadID := created.UploadId
+
+	var parts []types.CompletedPart
+	var total int64
+	for partNumber := int32(1); n > 0; partNumber++ {
+		part := buf[:n]
+		var etag *string
+		err := retryUpload(ctx, opts.Retries, func() error {
+			out, err := r.client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket:     aws.String(r.bucket),
+				Key:        aws.String(key),
+				UploadId:   uploadID,
+				PartNumber: aws.Int32(partNumber),
+				Body:       bytes.NewReader(part),
+			})
+			if err == nil {
+				etag = out.ETag
+			}
+			return err
+		})
+		if err != nil {
+			r.abortMultipartUpload(key, uploadID)
+			return total, fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: etag, PartNumber: aws.Int32(partNumber)})
+		total += int64(n)
+
+		n, err = io.ReadFull(body, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			r.abortMultipartUpload(key, uploadID)
+			return total, err
+		}
+	}
+
+	_, err = r.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(r.bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		r.abortMultipartUpload(key, uploadID)
+		return total, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	logging.Debugf("Uploaded %s in %d parts (%d bytes)", key, len(parts), total)
+	return total, nil
+}
+
+// abortMultipartUpload 途中のパートを削除する（リクエストがキャンセルされていても実行する）
+func (r *R2Client) abortMultipartUpload(key string, uploadID *string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := r.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(r.bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	}); err != nil {
+		logging.Warnf("Failed to abort multipart upload of %s: %v", key, err)
+	}
+}
+
+// retryUpload 一時的な失敗（5xx・ネットワークエラー）の場合にretries回まで再試行する
+func retryUpload(ctx context.Context, retries int, upload func() error) error {
+	backoff := uploadRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := upload()
+		if err == nil || attempt >= retries || !isBreakerFailure(err) {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// PutObjectStream SetMultipartOptionsの設定でbodyを読みながらアップロードする（ローカルキャッシュには書き込まない）
+func (g *GuardedR2Client) PutObjectStream(ctx context.Context, key string, body io.Reader, contentType string) (int64, error) {
+	if err := g.breaker.Allow(); err != nil {
+		return 0, err
+	}
+	n, err := g.client.PutObjectStream(ctx, key, body, contentType, g.multipart)
+	g.record(err)
+	return n, err
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// GetSignedUploadURL keyにPUTでアップロードできる署名付きURLを生成する
+// contentTypeを指定した場合、アップロード時に同じContent-Typeヘッダーを送る必要がある
+func (r *R2Client) GetSignedUploadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	req, err := r.presignClient.PresignPutObject(ctx, input, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (g *GuardedR2Client) GetSignedUploadURL(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	// GetSignedURLと同じく、R2停止中はアップロードできないURLを返さない
+	if g.Degraded() {
+		return "", ErrCircuitOpen
+	}
+	return g.client.GetSignedUploadURL(ctx, key, contentType, expires)
+}
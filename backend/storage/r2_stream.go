@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"dsa-api/logging"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+var (
+	// ErrNotModified If-None-Match・If-Modified-Sinceに一致した（変更されていない）
+	ErrNotModified = errors.New("object not modified")
+	// ErrRangeNotSatisfiable Rangeがオブジェクトの範囲外
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+)
+
+// ObjectRequest 条件付き・範囲指定の取得（空の項目は指定しない）
+type ObjectRequest struct {
+	// Rangeヘッダーの値（例: bytes=0-1023）
+	Range           string
+	IfNoneMatch     string
+	IfModifiedSince time.Time
+}
+
+// ObjectStream ストリーミングで取得したオブジェクト（Bodyは呼び出し側が閉じること）
+type ObjectStream struct {
+	Body io.ReadCloser
+	// 不明な場合は-1
+	ContentLength int64
+	ContentType   string
+	// Rangeを指定した場合のContent-Range（例: bytes 0-1023/4096）、全体の場合は空
+	ContentRange string
+	ETag         string
+	LastModified time.Time
+}
+
+// GetObjectStream オブジェクトをメモリに読み込まずに取得する
+// 条件に一致した場合はErrNotModified、範囲外の場合はErrRangeNotSatisfiableを返す
+func (r *R2Client) GetObjectStream(ctx context.Context, key string, req ObjectRequest) (*ObjectStream, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	}
+	if req.Range != "" {
+		input.Range = aws.String(req.Range)
+	}
+	if req.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(req.IfNoneMatch)
+	}
+	if !req.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(req.IfModifiedSince)
+	}
+	out, err := r.client.GetObject(ctx, input)
+	if err != nil {
+		var respErr *smithyhttp.ResponseError
+		if errors.As(err, &respErr) {
+			switch respErr.HTTPStatusCode() {
+			case http.StatusNotModified:
+				return nil, ErrNotModified
+			case http.StatusRequestedRangeNotSatisfiable:
+				return nil, ErrRangeNotSatisfiable
+			}
+		}
+		return nil, err
+	}
+	length := int64(-1)
+	if out.ContentLength != nil {
+		length = *out.ContentLength
+	}
+	return &ObjectStream{
+		Body:          out.Body,
+		ContentLength: length,
+		ContentType:   aws.ToString(out.ContentType),
+		ContentRange:  aws.ToString(out.ContentRange),
+		ETag:          aws.ToString(out.ETag),
+		LastModified:  aws.ToTime(out.LastModified),
+	}, nil
+}
+
+// GetObjectStream R2が停止している場合はローカルキャッシュのファイルを返す
+// キャッシュから返す場合は条件・範囲を無視して全体を返す（ContentRangeは空）
+func (g *GuardedR2Client) GetObjectStream(ctx context.Context, key string, req ObjectRequest) (*ObjectStream, error) {
+	if err := g.breaker.Allow(); err != nil {
+		if stream, ok := g.openCache(key); ok {
+			return stream, nil
+		}
+		return nil, err
+	}
+	stream, err := g.client.GetObjectStream(ctx, key, req)
+	if errors.Is(err, ErrNotModified) || errors.Is(err, ErrRangeNotSatisfiable) {
+		g.record(nil)
+		return nil, err
+	}
+	g.record(err)
+	if isBreakerFailure(err) {
+		if cached, ok := g.openCache(key); ok {
+			logging.Warnf("Serving %s from local cache: %v", key, err)
+			return cached, nil
+		}
+	}
+	return stream, err
+}
+
+// openCache キャッシュのファイルをストリームとして開く
+func (g *GuardedR2Client) openCache(key string) (*ObjectStream, bool) {
+	path := g.cachePath(key)
+	if path == "" {
+		return nil, false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false
+	}
+	return &ObjectStream{
+		Body:          f,
+		ContentLength: info.Size(),
+		LastModified:  info.ModTime(),
+	}, true
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ListArtifactExpiryCandidates beforeより前に終了し、成果物がまだ削除されていない解析のIDを古い順に返す（固定された解析は除く）
+func (db *DB) ListArtifactExpiryCandidates(before time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT id
+		FROM analyses
+		WHERE finished_at < $1
+		  AND artifacts_expired_at IS NULL
+		  AND NOT pinned
+		  AND status IN ('done', 'failed', 'cancelled')
+		ORDER BY finished_at ASC
+		LIMIT $2
+	`
+	rows, err := db.conn.Query(query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkArtifactsExpired 解析の成果物を削除したことを記録する（レコード自体は残す）
+func (db *DB) MarkArtifactsExpired(id string) error {
+	_, err := db.conn.Exec(`UPDATE analyses SET artifacts_expired_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// ArtifactsExpiredAt 成果物が削除済みの解析について、削除した日時を返す（削除されていない解析は含まない）
+func (db *DB) ArtifactsExpiredAt(ctx context.Context, ids []string) (map[string]time.Time, error) {
+	expired := make(map[string]time.Time)
+	if len(ids) == 0 {
+		return expired, nil
+	}
+	query := `
+		SELECT id, artifacts_expired_at
+		FROM analyses
+		WHERE id = ANY($1) AND artifacts_expired_at IS NOT NULL
+	`
+	rows, err := db.conn.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		expired[id] = at
+	}
+	return expired, rows.Err()
+}
+
+// ListRetentionCandidates beforeより前に作成され、終了している解析のIDを古い順に返す（固定された解析は除く）
+func (db *DB) ListRetentionCandidates(before time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT id
+		FROM analyses
+		WHERE created_at < $1
+		  AND NOT pinned
+		  AND status IN ('done', 'failed', 'cancelled')
+		ORDER BY created_at ASC
+		LIMIT $2
+	`
+	rows, err := db.conn.Query(query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetAnalysisPinned 解析を自動削除の対象外にする（または戻す）。解析が存在しない場合はfalseを返す
+func (db *DB) SetAnalysisPinned(ctx context.Context, id string, pinned bool) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `UPDATE analyses SET pinned = $2 WHERE id = $1`, id, pinned)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// PinnedAnalyses 固定された解析のIDを返す（固定されていない解析は含まない）
+func (db *DB) PinnedAnalyses(ctx context.Context, ids []string) (map[string]bool, error) {
+	pinned := make(map[string]bool)
+	if len(ids) == 0 {
+		return pinned, nil
+	}
+	rows, err := db.conn.QueryContext(ctx, `SELECT id FROM analyses WHERE id = ANY($1) AND pinned`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		pinned[id] = true
+	}
+	return pinned, rows.Err()
+}
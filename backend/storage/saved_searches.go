@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrSavedSearchNameTaken 同じセッションに同じ名前の検索が保存済み
+var ErrSavedSearchNameTaken = errors.New("a saved search with the same name already exists")
+
+// SavedSearch saved_searchesテーブルの行（名前を付けて保存した解析一覧の絞り込み）
+type SavedSearch struct {
+	ID        string
+	SessionID string
+	Name      string
+	// 絞り込みの条件（JSONのまま保存し、API側で解釈する）
+	Filters   json.RawMessage
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	LastRunAt *time.Time
+}
+
+const savedSearchColumns = `id, session_id, name, filters, created_at, updated_at, last_run_at`
+
+func scanSavedSearch(row interface{ Scan(...interface{}) error }) (*SavedSearch, error) {
+	s := &SavedSearch{}
+	var filters []byte
+	if err := row.Scan(&s.ID, &s.SessionID, &s.Name, &filters, &s.CreatedAt, &s.UpdatedAt, &s.LastRunAt); err != nil {
+		return nil, err
+	}
+	s.Filters = filters
+	return s, nil
+}
+
+// savedSearchError 名前の一意制約の違反をErrSavedSearchNameTakenに変換する
+func savedSearchError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrSavedSearchNameTaken
+	}
+	return err
+}
+
+// CreateSavedSearch 検索を保存する（同じセッションに同じ名前がある場合はErrSavedSearchNameTaken）
+func (db *DB) CreateSavedSearch(ctx context.Context, search *SavedSearch) (*SavedSearch, error) {
+	row := db.conn.QueryRowContext(ctx, `
+		INSERT INTO saved_searches (id, session_id, name, filters, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING `+savedSearchColumns, search.ID, search.SessionID, search.Name, []byte(search.Filters))
+	created, err := scanSavedSearch(row)
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	return created, nil
+}
+
+// GetSavedSearch セッションの保存した検索を返す（ない場合・他のセッションの場合はsql.ErrNoRows）
+func (db *DB) GetSavedSearch(ctx context.Context, sessionID, id string) (*SavedSearch, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = $1 AND session_id = $2`, id, sessionID)
+	return scanSavedSearch(row)
+}
+
+// ListSavedSearches セッションの保存した検索を新しい順に返す
+func (db *DB) ListSavedSearches(ctx context.Context, sessionID string) ([]*SavedSearch, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches
+		WHERE session_id = $1
+		ORDER BY created_at DESC, id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := make([]*SavedSearch, 0)
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+// CountSavedSearches セッションの保存した検索の数
+func (db *DB) CountSavedSearches(ctx context.Context, sessionID string) (int, error) {
+	var count int
+	err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM saved_searches WHERE session_id = $1`, sessionID).Scan(&count)
+	return count, err
+}
+
+// UpdateSavedSearch 名前・絞り込みの条件を変更する（nilの項目は変更しない、ない場合はsql.ErrNoRows）
+func (db *DB) UpdateSavedSearch(ctx context.Context, sessionID, id string, name *string, filters json.RawMessage) (*SavedSearch, error) {
+	var filtersArg interface{}
+	if filters != nil {
+		filtersArg = []byte(filters)
+	}
+	row := db.conn.QueryRowContext(ctx, `
+		UPDATE saved_searches
+		SET name = COALESCE($3, name), filters = COALESCE($4, filters), updated_at = NOW()
+		WHERE id = $1 AND session_id = $2
+		RETURNING `+savedSearchColumns, id, sessionID, name, filtersArg)
+	updated, err := scanSavedSearch(row)
+	if err != nil {
+		return nil, savedSearchError(err)
+	}
+	return updated, nil
+}
+
+// DeleteSavedSearch 保存した検索を削除する（削除した場合はtrue）
+func (db *DB) DeleteSavedSearch(ctx context.Context, sessionID, id string) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `DELETE FROM saved_searches WHERE id = $1 AND session_id = $2`, id, sessionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// MarkSavedSearchRun 保存した検索を実行した日時を記録する
+func (db *DB) MarkSavedSearchRun(ctx context.Context, id string) error {
+	_, err := db.conn.ExecContext(ctx, `UPDATE saved_searches SET last_run_at = NOW() WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// 検索のフィルターのキー（ListAnalysesのフィルターに加えて指定する、いずれかがある場合はSearchAnalysesで取得する）
+const (
+	// UniProt ID・タンパク質名の部分一致（string）
+	FilterQuery = "q"
+	// いずれかのUniProt IDの解析（[]string）
+	FilterUniProtIDs = "uniprot_ids"
+	// すべてのタグが付いた解析（[]string、重複のないもの）
+	FilterTags = "tags"
+	// プロジェクトに追加した解析（string、プロジェクトID）
+	FilterProject = "project_id"
+	// 固定した（trueの場合）・固定していない（falseの場合）解析（bool）
+	FilterPinned = "pinned"
+	// パラメータスイープで作成した解析（string、paramsのsweep_id）
+	FilterSweep = "sweep_id"
+	// 指標の範囲（[]MetricRange）
+	FilterMetricRanges = "metric_ranges"
+	// 並び順（[]SortKey、指定しない場合は作成日時の新しい順）
+	FilterSort = "sort"
+)
+
+// MetricRange metricsの数値の指標の範囲（Opはgt・gte・lt・lte）
+type MetricRange struct {
+	Name  string
+	Op    string
+	Value float64
+}
+
+// SortKey 並べ替えの列（Fieldはcreated_at・uniprot_id・method・status・protein_name・pinned・SearchMetricsの指標）
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// SearchMetrics 範囲で絞り込み・並べ替えできるmetricsの指標（数値のみ）
+var SearchMetrics = map[string]bool{
+	"mean_score":     true,
+	"mean_std":       true,
+	"entries":        true,
+	"chains":         true,
+	"length":         true,
+	"length_percent": true,
+	"resolution":     true,
+	"umf":            true,
+	"cis_num":        true,
+	"cis_dist_mean":  true,
+	"cis_dist_std":   true,
+}
+
+// metricRangeOps 範囲の比較演算子
+var metricRangeOps = map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+
+// ValidMetricRangeOp 範囲の比較（gt・gte・lt・lte）として使えるか
+func ValidMetricRangeOp(op string) bool {
+	_, ok := metricRangeOps[op]
+	return ok
+}
+
+// sortColumns 指標以外の並べ替えの列
+var sortColumns = map[string]bool{"created_at": true, "uniprot_id": true, "method": true, "status": true, "protein_name": true, "pinned": true}
+
+// ValidSortField 並べ替えの列として使えるか
+func ValidSortField(field string) bool {
+	return sortColumns[field] || SearchMetrics[field]
+}
+
+// metricExpr 指標の数値の式（migrations/016_add_search_indexes.sqlのインデックスと同じ式）
+func metricExpr(name string) string {
+	return fmt.Sprintf("((metrics->>'%s')::double precision)", name)
+}
+
+// likePattern 部分一致のパターン（%・_・\はエスケープする）
+func likePattern(q string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q)
+	return "%" + escaped + "%"
+}
+
+// searchConditions ListAnalysesのフィルターと検索の条件・引数（$1から）
+func searchConditions(filters map[string]interface{}) ([]string, []interface{}) {
+	conditions, args := analysisFilterConditions(filters)
+	if q, _ := filters[FilterQuery].(string); q != "" {
+		args = append(args, likePattern(q))
+		conditions = append(conditions, fmt.Sprintf("(uniprot_id ILIKE $%d OR protein_name ILIKE $%d)", len(args), len(args)))
+	}
+	if ids, _ := filters[FilterUniProtIDs].([]string); len(ids) > 0 {
+		args = append(args, pq.Array(ids))
+		conditions = append(conditions, fmt.Sprintf("uniprot_id = ANY($%d)", len(args)))
+	}
+	if tags, _ := filters[FilterTags].([]string); len(tags) > 0 {
+		args = append(args, pq.Array(tags), len(tags))
+		conditions = append(conditions, fmt.Sprintf(
+			"id IN (SELECT analysis_id FROM analysis_tags WHERE tag = ANY($%d) GROUP BY analysis_id HAVING COUNT(*) = $%d)",
+			len(args)-1, len(args)))
+	}
+	if projectID, _ := filters[FilterProject].(string); projectID != "" {
+		args = append(args, projectID)
+		conditions = append(conditions, fmt.Sprintf("id IN (SELECT analysis_id FROM project_analyses WHERE project_id = $%d)", len(args)))
+	}
+	if pinned, ok := filters[FilterPinned].(bool); ok {
+		args = append(args, pinned)
+		conditions = append(conditions, fmt.Sprintf("pinned = $%d", len(args)))
+	}
+	if sweepID, _ := filters[FilterSweep].(string); sweepID != "" {
+		args = append(args, sweepID)
+		conditions = append(conditions, fmt.Sprintf("params->>'sweep_id' = $%d", len(args)))
+	}
+	ranges, _ := filters[FilterMetricRanges].([]MetricRange)
+	for _, m := range ranges {
+		op, ok := metricRangeOps[m.Op]
+		if !ok || !SearchMetrics[m.Name] {
+			continue
+		}
+		args = append(args, m.Value)
+		conditions = append(conditions, fmt.Sprintf("%s %s $%d", metricExpr(m.Name), op, len(args)))
+	}
+	return conditions, args
+}
+
+// orderBy 並び順（同じ値の場合は作成日時・IDの新しい順、指標のない解析は最後）
+func orderBy(keys []SortKey) string {
+	terms := make([]string, 0, len(keys)+2)
+	for _, key := range keys {
+		direction := "ASC"
+		if key.Desc {
+			direction = "DESC"
+		}
+		switch {
+		case SearchMetrics[key.Field]:
+			terms = append(terms, fmt.Sprintf("%s %s NULLS LAST", metricExpr(key.Field), direction))
+		case sortColumns[key.Field]:
+			terms = append(terms, fmt.Sprintf("%s %s NULLS LAST", key.Field, direction))
+		}
+	}
+	return strings.Join(append(terms, "created_at DESC", "id DESC"), ", ")
+}
+
+const analysisColumns = `id, uniprot_id, method, status, params, created_at, started_at, finished_at, progress,
+	metrics, error_message, r2_prefix, result_key, heatmap_key, scatter_key, logs_key, session_id`
+
+// SearchAnalyses ListAnalysesのフィルターに加えて、UniProt ID・タンパク質名の部分一致・UniProt IDの集合・タグ・指標の範囲で絞り込み、指定した順に返す
+func (db *DB) SearchAnalyses(ctx context.Context, filters map[string]interface{}) ([]*AnalysisRecord, error) {
+	conditions, args := searchConditions(filters)
+	keys, _ := filters[FilterSort].([]SortKey)
+	query := `SELECT ` + analysisColumns + `
+		FROM analyses
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + orderBy(keys)
+	if limit, ok := filters["limit"].(int); ok && limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if offset, ok := filters["offset"].(int); ok && offset > 0 {
+		args = append(args, offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]*AnalysisRecord, 0)
+	for rows.Next() {
+		record, err := scanAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// GetAnalysisContext GetAnalysisと同じく解析を返す（ない場合はsql.ErrNoRows）。ctxの期限切れ・キャンセルでクエリを中断する
+func (db *DB) GetAnalysisContext(ctx context.Context, id string) (*AnalysisRecord, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+analysisColumns+` FROM analyses WHERE id = $1`, id)
+	return scanAnalysis(row)
+}
+
+func scanAnalysis(row interface{ Scan(...interface{}) error }) (*AnalysisRecord, error) {
+	var record AnalysisRecord
+	var params, metrics []byte
+	var sessionID sql.NullString
+	if err := row.Scan(&record.ID, &record.UniProtID, &record.Method, &record.Status, &params,
+		&record.CreatedAt, &record.StartedAt, &record.FinishedAt, &record.Progress,
+		&metrics, &record.ErrorMessage, &record.R2Prefix, &record.ResultKey, &record.HeatmapKey,
+		&record.ScatterKey, &record.LogsKey, &sessionID); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &record.Params); err != nil {
+		return nil, fmt.Errorf("failed to decode params of %s: %w", record.ID, err)
+	}
+	if metrics != nil {
+		if err := json.Unmarshal(metrics, &record.Metrics); err != nil {
+			return nil, fmt.Errorf("failed to decode metrics of %s: %w", record.ID, err)
+		}
+	}
+	record.SessionID = sessionID.String
+	return &record, nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// AnalysisShare analysis_sharesテーブルの行（解析の共有リンク）
+type AnalysisShare struct {
+	ID         string
+	AnalysisID string
+	ExpiresAt  *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// Expired 期限を過ぎているか
+func (s *AnalysisShare) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
+}
+
+const analysisShareColumns = `id, analysis_id, expires_at, revoked_at, created_at`
+
+func scanAnalysisShare(row interface{ Scan(...interface{}) error }) (*AnalysisShare, error) {
+	s := &AnalysisShare{}
+	if err := row.Scan(&s.ID, &s.AnalysisID, &s.ExpiresAt, &s.RevokedAt, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CreateAnalysisShare 共有リンクを登録する（expiresAtがnilの場合は期限なし）
+func (db *DB) CreateAnalysisShare(ctx context.Context, id, analysisID string, expiresAt *time.Time) (*AnalysisShare, error) {
+	row := db.conn.QueryRowContext(ctx, `
+		INSERT INTO analysis_shares (id, analysis_id, expires_at, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING `+analysisShareColumns, id, analysisID, expiresAt)
+	return scanAnalysisShare(row)
+}
+
+// GetAnalysisShare 共有リンクを返す（登録されていない場合はsql.ErrNoRows）
+func (db *DB) GetAnalysisShare(ctx context.Context, id string) (*AnalysisShare, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+analysisShareColumns+` FROM analysis_shares WHERE id = $1`, id)
+	return scanAnalysisShare(row)
+}
+
+// ListAnalysisShares 解析の共有リンクを新しい順に返す（期限切れ・取り消し済みを含む）
+func (db *DB) ListAnalysisShares(ctx context.Context, analysisID string) ([]*AnalysisShare, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT `+analysisShareColumns+`
+		FROM analysis_shares
+		WHERE analysis_id = $1
+		ORDER BY created_at DESC
+	`, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []*AnalysisShare
+	for rows.Next() {
+		s, err := scanAnalysisShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
+
+// RevokeAnalysisShare 解析の共有リンクを取り消す（登録されていない・取り消し済みの場合はfalse）
+func (db *DB) RevokeAnalysisShare(ctx context.Context, analysisID, id string) (bool, error) {
+	result, err := db.conn.ExecContext(ctx, `
+		UPDATE analysis_shares SET revoked_at = NOW()
+		WHERE id = $1 AND analysis_id = $2 AND revoked_at IS NULL
+	`, id, analysisID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
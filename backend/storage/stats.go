@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatsFilter 集計の対象（作成日時の範囲、SessionIDが空の場合はすべてのセッション）
+type StatsFilter struct {
+	From      time.Time
+	To        time.Time
+	SessionID string
+	// 期間ごとの集計の単位（hour・day・week・month）とタイムゾーン
+	Bucket   string
+	Location *time.Location
+	// 解析数の多いUniProt IDの件数
+	TopN int
+	// mean_scoreのヒストグラムの区間数
+	Bins int
+}
+
+// AnalysisStats 解析の集計結果
+type AnalysisStats struct {
+	Total      int
+	ByStatus   map[string]int
+	ByMethod   map[string]int
+	MeanScore  ScoreDistribution
+	TopUniProt []UniProtCount
+	Buckets    []StatusBucket
+}
+
+// ScoreDistribution 完了した解析のmetrics.mean_scoreの分布（Countが0の場合は他の値を使わない）
+type ScoreDistribution struct {
+	Count     int
+	Mean      float64
+	Min       float64
+	Max       float64
+	P25       float64
+	Median    float64
+	P75       float64
+	Histogram []HistogramBin
+}
+
+// HistogramBin ヒストグラムの1区間（[Lower, Upper)、最後の区間はUpperを含む）
+type HistogramBin struct {
+	Lower float64
+	Upper float64
+	Count int
+}
+
+// UniProtCount UniProt IDごとの解析数
+type UniProtCount struct {
+	UniProtID string
+	Count     int
+}
+
+// StatusBucket 期間ごとの解析数（Startはフィルターのタイムゾーンでの期間の開始）
+type StatusBucket struct {
+	Start     time.Time
+	Total     int
+	Done      int
+	Failed    int
+	Cancelled int
+}
+
+// statsBuckets 期間ごとの集計に使える単位（date_truncの引数）
+var statsBuckets = map[string]bool{"hour": true, "day": true, "week": true, "month": true}
+
+// ValidStatsBucket 期間ごとの集計の単位として使えるか
+func ValidStatsBucket(bucket string) bool {
+	return statsBuckets[bucket]
+}
+
+// where 絞り込みの条件と引数（$1から）
+func (f StatsFilter) where() (string, []interface{}) {
+	conditions := []string{"created_at >= $1", "created_at < $2"}
+	args := []interface{}{f.From, f.To}
+	if f.SessionID != "" {
+		args = append(args, f.SessionID)
+		conditions = append(conditions, fmt.Sprintf("session_id = $%d", len(args)))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// AnalysisStats 作成日時の範囲の解析をステータス・手法・UniProt ID・期間ごとに集計し、mean_scoreの分布を求める
+func (db *DB) AnalysisStats(ctx context.Context, filter StatsFilter) (*AnalysisStats, error) {
+	if !ValidStatsBucket(filter.Bucket) {
+		return nil, fmt.Errorf("invalid bucket: %s", filter.Bucket)
+	}
+	if filter.Location == nil {
+		filter.Location = time.UTC
+	}
+	where, args := filter.where()
+
+	stats := &AnalysisStats{
+		ByStatus:   make(map[string]int),
+		ByMethod:   make(map[string]int),
+		TopUniProt: make([]UniProtCount, 0),
+		Buckets:    make([]StatusBucket, 0),
+	}
+
+	// ステータス・手法ごとの件数
+	for _, group := range []struct {
+		column string
+		counts map[string]int
+	}{{"status", stats.ByStatus}, {"method", stats.ByMethod}} {
+		rows, err := db.conn.QueryContext(ctx, fmt.Sprintf(`SELECT %s, COUNT(*) FROM analyses WHERE %s GROUP BY %s`, group.column, where, group.column), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			var count int
+			if err := rows.Scan(&key, &count); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			group.counts[key] = count
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, count := range stats.ByStatus {
+		stats.Total += count
+	}
+
+	// 解析数の多いUniProt ID
+	if filter.TopN > 0 {
+		query := fmt.Sprintf(`
+			SELECT uniprot_id, COUNT(*) AS n
+			FROM analyses
+			WHERE %s
+			GROUP BY uniprot_id
+			ORDER BY n DESC, uniprot_id
+			LIMIT %d
+		`, where, filter.TopN)
+		rows, err := db.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var c UniProtCount
+			if err := rows.Scan(&c.UniProtID, &c.Count); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			stats.TopUniProt = append(stats.TopUniProt, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.scoreDistribution(ctx, where, args, filter.Bins, &stats.MeanScore); err != nil {
+		return nil, err
+	}
+
+	// 期間ごとの件数（期間の開始はタイムゾーンの時刻で区切る）
+	bucketArgs := append(append([]interface{}{}, args...), filter.Location.String())
+	query := fmt.Sprintf(`
+		SELECT date_trunc('%s', created_at AT TIME ZONE $%d) AS bucket,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'done'),
+		       COUNT(*) FILTER (WHERE status = 'failed'),
+		       COUNT(*) FILTER (WHERE status = 'cancelled')
+		FROM analyses
+		WHERE %s
+		GROUP BY bucket
+		ORDER BY bucket
+	`, filter.Bucket, len(bucketArgs), where)
+	rows, err := db.conn.QueryContext(ctx, query, bucketArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b StatusBucket
+		var start time.Time
+		if err := rows.Scan(&start, &b.Total, &b.Done, &b.Failed, &b.Cancelled); err != nil {
+			return nil, err
+		}
+		// timestamp without time zoneはUTCとして読み込まれるため、タイムゾーンの時刻として解釈し直す
+		b.Start = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, filter.Location)
+		stats.Buckets = append(stats.Buckets, b)
+	}
+	return stats, rows.Err()
+}
+
+// scoreDistribution 完了した解析のmetrics.mean_scoreの平均・四分位数・ヒストグラム（数値でない値は除く）
+func (db *DB) scoreDistribution(ctx context.Context, where string, args []interface{}, bins int, dist *ScoreDistribution) error {
+	scores := fmt.Sprintf(`
+		SELECT (metrics->>'mean_score')::float8 AS x
+		FROM analyses
+		WHERE %s AND status = 'done' AND jsonb_typeof(metrics->'mean_score') = 'number'
+	`, where)
+
+	query := fmt.Sprintf(`
+		SELECT COUNT(x), COALESCE(AVG(x), 0), COALESCE(MIN(x), 0), COALESCE(MAX(x), 0),
+		       COALESCE(percentile_cont(0.25) WITHIN GROUP (ORDER BY x), 0),
+		       COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY x), 0),
+		       COALESCE(percentile_cont(0.75) WITHIN GROUP (ORDER BY x), 0)
+		FROM (%s) s
+	`, scores)
+	if err := db.conn.QueryRowContext(ctx, query, args...).Scan(&dist.Count, &dist.Mean, &dist.Min, &dist.Max, &dist.P25, &dist.Median, &dist.P75); err != nil {
+		return err
+	}
+	dist.Histogram = make([]HistogramBin, 0)
+	if dist.Count == 0 || bins <= 0 {
+		return nil
+	}
+	// すべて同じ値の場合は1区間
+	if dist.Max <= dist.Min {
+		dist.Histogram = append(dist.Histogram, HistogramBin{Lower: dist.Min, Upper: dist.Max, Count: dist.Count})
+		return nil
+	}
+
+	width := (dist.Max - dist.Min) / float64(bins)
+	for i := 0; i < bins; i++ {
+		dist.Histogram = append(dist.Histogram, HistogramBin{
+			Lower: dist.Min + width*float64(i),
+			Upper: dist.Min + width*float64(i+1),
+		})
+	}
+	dist.Histogram[bins-1].Upper = dist.Max
+
+	// width_bucketは最大値を区間外（bins+1）にするため、最後の区間に含める
+	histArgs := append(append([]interface{}{}, args...), dist.Min, dist.Max)
+	query = fmt.Sprintf(`
+		SELECT LEAST(width_bucket(x, $%d, $%d, %d), %d) AS bin, COUNT(*)
+		FROM (%s) s
+		GROUP BY bin
+	`, len(histArgs)-1, len(histArgs), bins, bins, scores)
+	rows, err := db.conn.QueryContext(ctx, query, histArgs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bin, count int
+		if err := rows.Scan(&bin, &count); err != nil {
+			return err
+		}
+		if bin >= 1 && bin <= bins {
+			dist.Histogram[bin-1].Count += count
+		}
+	}
+	return rows.Err()
+}
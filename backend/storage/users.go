@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrEmailTaken 登録済みのメールアドレス
+var ErrEmailTaken = errors.New("email is already registered")
+
+// User usersテーブルの行（ユーザーアカウント）
+type User struct {
+	ID           string
+	Email        string
+	PasswordHash string
+	// アカウントのセッションID（匿名のセッションのdsa_session_idの代わりに使う）
+	SessionID string
+	// ロール（viewer・analyst・admin）
+	Role        string
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
+}
+
+const userColumns = `id, email, password_hash, session_id, role, created_at, last_login_at`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*User, error) {
+	u := &User{}
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.SessionID, &u.Role, &u.CreatedAt, &u.LastLoginAt); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CreateUser ユーザーを登録する（メールアドレスが登録済みの場合はErrEmailTaken）
+func (db *DB) CreateUser(ctx context.Context, user *User) (*User, error) {
+	row := db.conn.QueryRowContext(ctx, `
+		INSERT INTO users (id, email, password_hash, session_id, role, created_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING `+userColumns, user.ID, user.Email, user.PasswordHash, user.SessionID, user.Role)
+	created, err := scanUser(row)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrEmailTaken
+	}
+	return created, err
+}
+
+// GetUser ユーザーを返す（登録されていない場合はsql.ErrNoRows）
+func (db *DB) GetUser(ctx context.Context, id string) (*User, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+	return scanUser(row)
+}
+
+// GetUserByEmail メールアドレス（小文字に正規化したもの）でユーザーを返す（登録されていない場合はsql.ErrNoRows）
+func (db *DB) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	row := db.conn.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+	return scanUser(row)
+}
+
+// ListUsers 登録日時の古い順にユーザーを返す
+func (db *DB) ListUsers(ctx context.Context, limit, offset int) ([]*User, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT `+userColumns+` FROM users
+		ORDER BY created_at, id
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SetUserRole ユーザーのロールを変更する（登録されていない場合はsql.ErrNoRows）
+func (db *DB) SetUserRole(ctx context.Context, id, role string) (*User, error) {
+	row := db.conn.QueryRowContext(ctx, `UPDATE users SET role = $2 WHERE id = $1 RETURNING `+userColumns, id, role)
+	return scanUser(row)
+}
+
+// TouchUserLogin 最終ログイン日時を記録する
+func (db *DB) TouchUserLogin(id string) error {
+	_, err := db.conn.Exec(`UPDATE users SET last_login_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// SetAnalysisUser 解析を作成したユーザーを記録する
+func (db *DB) SetAnalysisUser(id, userID string) error {
+	_, err := db.conn.Exec(`UPDATE analyses SET user_id = $2 WHERE id = $1`, id, userID)
+	return err
+}
+
+// ClaimSessionAnalyses 匿名のセッションの解析をユーザーのアカウントに引き継ぎ、引き継いだ件数を返す
+// 他のユーザーに引き継がれた解析は対象外
+func (db *DB) ClaimSessionAnalyses(sessionID string, user *User) (int64, error) {
+	result, err := db.conn.Exec(`
+		UPDATE analyses SET session_id = $2, user_id = $3
+		WHERE session_id = $1 AND user_id IS NULL
+	`, sessionID, user.SessionID, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,7 @@
+package storage
+
+// SetAnalysisVariant 解析の対象のアイソフォーム・チェーン（jobs.VariantIDの形式）を記録する
+func (db *DB) SetAnalysisVariant(id, variant string) error {
+	_, err := db.conn.Exec(`UPDATE analyses SET variant = $2 WHERE id = $1`, id, variant)
+	return err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebhookDelivery webhook_deliveriesテーブルの行（Webhookの1回のイベント送信と再試行の状態）
+type WebhookDelivery struct {
+	ID             string
+	EndpointID     string
+	Event          string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	NextAttemptAt  *time.Time
+	LastError      string
+	ResponseStatus int
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// ListWebhookDeliveries すべての配信記録を古い順に返す
+func (db *DB) ListWebhookDeliveries(ctx context.Context) ([]*WebhookDelivery, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+		SELECT id, endpoint_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at
+		FROM webhook_deliveries
+		ORDER BY created_at ASC, id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		d := &WebhookDelivery{}
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastError, &d.ResponseStatus, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// SaveWebhookDeliveries 配信記録を保存する（同じIDは上書きする）
+func (db *DB) SaveWebhookDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range deliveries {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (id, endpoint_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, delivered_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (id) DO UPDATE SET
+				status = EXCLUDED.status,
+				attempts = EXCLUDED.attempts,
+				next_attempt_at = EXCLUDED.next_attempt_at,
+				last_error = EXCLUDED.last_error,
+				response_status = EXCLUDED.response_status,
+				delivered_at = EXCLUDED.delivered_at
+		`, d.ID, d.EndpointID, d.Event, d.Payload, d.Status, d.Attempts, d.NextAttemptAt,
+			d.LastError, d.ResponseStatus, d.CreatedAt, d.DeliveredAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteWebhookDeliveries 配信記録を削除する
+func (db *DB) DeleteWebhookDeliveries(ctx context.Context, ids []string) error {
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
@@ -0,0 +1,264 @@
+// Package fakedsa 結合テスト用の偽のPython CLI（dsa_cli run）
+// LocalPythonExecutorから `<PythonPath> -m dsa_cli run --uniprot <id> --out <dir> ...` として起動され、
+// 本物と同じ成果物（result.json・heatmap.png・dist_score.png・score_matrix.json・structures.json）とPROGRESS行を出力する
+// 所要時間・失敗の仕方は作業ディレクトリ（Pythonディレクトリ）のfake_dsa.jsonでUniProt IDごとに指定する
+package fakedsa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ConfigFile 動作の設定ファイル（Pythonディレクトリに置く、UniProt IDごとのBehavior、""はデフォルト）
+const ConfigFile = "fake_dsa.json"
+
+// 失敗の仕方（Behavior.Failure）
+const (
+	// 成果物を書き込まずに終了コード1で終了する（起動後の異常終了）
+	FailureExit = "exit"
+	// result.jsonにstatus: failedを書き込んで終了コード1で終了する（Pythonが捕捉したエラー）
+	FailureResult = "result"
+	// 終了コード0で終了するがresult.jsonを書き込まない
+	FailureMissingResult = "missing_result"
+)
+
+// Behavior 1回の実行の動作
+type Behavior struct {
+	// 全体の所要時間（Stepsに分けてPROGRESS行を出力する）
+	Duration time.Duration `json:"duration"`
+	// 進捗の段階数（0は5）
+	Steps int `json:"steps"`
+	// 失敗の仕方（空の場合は成功する）
+	Failure string `json:"failure,omitempty"`
+	// FailureResultのresult.jsonのerror_category（空の場合はanalysis）
+	ErrorCategory string `json:"error_category,omitempty"`
+	// 残基数（スコア行列の大きさ、0は20）
+	Residues int `json:"residues"`
+	// SIGTERMを無視する（猶予期間を過ぎてからの強制終了を確認する）
+	IgnoreSIGTERM bool `json:"ignore_sigterm,omitempty"`
+}
+
+// args 起動時の引数
+type args struct {
+	uniprotID string
+	outDir    string
+	resumeDir string
+}
+
+// Main 偽のCLIを実行して終了コードを返す（argsは実行ファイル名を除く引数）
+func Main(argv []string, stdout, stderr io.Writer) int {
+	a, err := parseArgs(argv)
+	if err != nil {
+		fmt.Fprintf(stderr, "fake dsa_cli: %v\n", err)
+		return 2
+	}
+	b, err := loadBehavior(ConfigFile, a.uniprotID)
+	if err != nil {
+		fmt.Fprintf(stderr, "fake dsa_cli: %v\n", err)
+		return 2
+	}
+	if b.IgnoreSIGTERM {
+		signal.Ignore(syscall.SIGTERM)
+	}
+	if err := run(a, b, stdout, stderr); err != nil {
+		fmt.Fprintf(stderr, "fake dsa_cli: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseArgs `-m dsa_cli run --uniprot <id> --out <dir> [--resume <dir>] ...`（それ以外のオプションは無視する）
+func parseArgs(argv []string) (*args, error) {
+	a := &args{}
+	for i := 0; i < len(argv); i++ {
+		if i+1 >= len(argv) {
+			break
+		}
+		switch argv[i] {
+		case "--uniprot":
+			a.uniprotID = argv[i+1]
+			i++
+		case "--out":
+			a.outDir = argv[i+1]
+			i++
+		case "--resume":
+			a.resumeDir = argv[i+1]
+			i++
+		}
+	}
+	if a.uniprotID == "" || a.outDir == "" {
+		return nil, errors.New("--uniprot and --out are required")
+	}
+	return a, nil
+}
+
+// loadBehavior 設定ファイルからUniProt IDの動作を読み込む（なければデフォルト、設定ファイルがなければ即座に成功する）
+func loadBehavior(path, uniprotID string) (Behavior, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Behavior{}, nil
+	}
+	if err != nil {
+		return Behavior{}, err
+	}
+	var behaviors map[string]Behavior
+	if err := json.Unmarshal(data, &behaviors); err != nil {
+		return Behavior{}, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if b, ok := behaviors[uniprotID]; ok {
+		return b, nil
+	}
+	return behaviors[""], nil
+}
+
+func run(a *args, b Behavior, stdout, stderr io.Writer) error {
+	steps := b.Steps
+	if steps <= 0 {
+		steps = 5
+	}
+	residues := b.Residues
+	if residues <= 0 {
+		residues = 20
+	}
+
+	// 本物と同じく途中で失敗しても再開できるように先にチェックポイントを記録する
+	workDir := filepath.Join(a.outDir, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(workDir, "checkpoint.json"), map[string]interface{}{"key": a.uniprotID, "stages": []string{}, "resumed": a.resumeDir != ""}); err != nil {
+		return err
+	}
+
+	for i := 0; i < steps; i++ {
+		fmt.Fprintf(stderr, "STEP %d/%d: Fake stage...\n", i+1, steps)
+		fmt.Fprintf(stdout, "PROGRESS %d Fake stage %d/%d\n", i*100/steps, i+1, steps)
+		time.Sleep(b.Duration / time.Duration(steps))
+	}
+
+	switch b.Failure {
+	case FailureExit:
+		return errors.New("simulated crash")
+	case FailureMissingResult:
+		return nil
+	case FailureResult:
+		category := b.ErrorCategory
+		if category == "" {
+			category = "analysis"
+		}
+		if err := writeJSON(filepath.Join(a.outDir, "result.json"), map[string]interface{}{
+			"status":         "failed",
+			"error":          "Simulated analysis failure",
+			"error_category": category,
+			"uniprot_id":     a.uniprotID,
+		}); err != nil {
+			return err
+		}
+		return errors.New("simulated analysis failure")
+	case "":
+	default:
+		return fmt.Errorf("unknown failure: %s", b.Failure)
+	}
+
+	if err := writeOutputs(a, residues); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "Analysis completed successfully\n")
+	fmt.Fprintf(stdout, "PROGRESS 100 Analysis completed\n")
+	return nil
+}
+
+// writeOutputs 成功した場合の成果物（値は残基数から決まる固定値）
+func writeOutputs(a *args, residues int) error {
+	values := make([][]interface{}, residues)
+	for i := range values {
+		values[i] = make([]interface{}, residues)
+		for j := range values[i] {
+			if i != j {
+				values[i][j] = math.Round(1/float64(1+abs(i-j))*10000) / 10000
+			}
+		}
+	}
+	if err := writeJSON(filepath.Join(a.outDir, "score_matrix.json"), map[string]interface{}{"size": residues, "values": values}); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(a.outDir, "structures.json"), []map[string]interface{}{
+		{"pdb_id": "1ABC", "method": "X-ray", "resolution": 1.8, "outlier": false},
+	}); err != nil {
+		return err
+	}
+	for _, name := range []string{"heatmap.png", "dist_score.png"} {
+		if err := writePNG(filepath.Join(a.outDir, name), residues); err != nil {
+			return err
+		}
+	}
+	if err := writeJSON(filepath.Join(a.outDir, "result.json"), map[string]interface{}{
+		"status":     "success",
+		"uniprot_id": a.uniprotID,
+		"parameters": map[string]interface{}{"method": "all"},
+		"statistics": map[string]interface{}{
+			"entries":        1,
+			"chains":         1,
+			"length":         residues,
+			"length_percent": 100.0,
+			"resolution":     1.8,
+			"umf":            0.1,
+		},
+		"score_summary": map[string]interface{}{
+			"total_pairs": residues * (residues - 1) / 2,
+			"mean_score":  0.5,
+			"std_score":   0.1,
+			"max_score":   1.0,
+			"min_score":   0.0,
+			"mean_std":    0.2,
+		},
+	}); err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(a.outDir, "status.json"), map[string]interface{}{"status": "done", "progress": 100, "message": "Analysis completed successfully"})
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// writePNG 大きさがsize×sizeのグラデーションの画像
+func writePNG(path string, size int) error {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 255 / size), G: uint8(y * 255 / size), B: 128, A: 255})
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
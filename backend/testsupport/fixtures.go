@@ -0,0 +1,60 @@
+package testsupport
+
+import (
+	"dsa-api/storage"
+	"os"
+	"testing"
+	"time"
+)
+
+// フィクスチャの接続先（docker-compose.ymlで起動した場合の値はREADMEを参照）
+const (
+	envDatabaseURL   = "TEST_DATABASE_URL"
+	envR2Endpoint    = "TEST_R2_ENDPOINT"
+	envR2AccessKeyID = "TEST_R2_ACCESS_KEY_ID"
+	envR2SecretKey   = "TEST_R2_SECRET_ACCESS_KEY"
+	envR2Bucket      = "TEST_R2_BUCKET"
+)
+
+// Postgres TEST_DATABASE_URLのDBに接続する（設定されていない場合はテストをスキップする）
+// マイグレーションはdocker-compose.ymlの起動時に適用される
+func Postgres(tb testing.TB) *storage.DB {
+	tb.Helper()
+	url := os.Getenv(envDatabaseURL)
+	if url == "" {
+		tb.Skipf("%s is not set (start the fixtures with: docker compose -f testsupport/docker-compose.yml up -d)", envDatabaseURL)
+	}
+	db, err := storage.NewDB(url)
+	if err != nil {
+		tb.Fatalf("failed to connect to %s: %v", envDatabaseURL, err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// MinIO TEST_R2_*のS3互換ストレージ（MinIO）のクライアント（設定されていない場合はテストをスキップする）
+func MinIO(tb testing.TB) *storage.GuardedR2Client {
+	tb.Helper()
+	endpoint := os.Getenv(envR2Endpoint)
+	if endpoint == "" {
+		tb.Skipf("%s is not set (start the fixtures with: docker compose -f testsupport/docker-compose.yml up -d)", envR2Endpoint)
+	}
+	return newR2(tb, endpoint, os.Getenv(envR2AccessKeyID), os.Getenv(envR2SecretKey), os.Getenv(envR2Bucket))
+}
+
+// UnreachableR2 接続できないR2のクライアント（R2停止中の縮退運転・フォールバックの確認用）
+// 1回の失敗でサーキットブレーカーが開く
+func UnreachableR2(tb testing.TB) *storage.GuardedR2Client {
+	tb.Helper()
+	return newR2(tb, "http://127.0.0.1:1", "test", "test", "unreachable")
+}
+
+func newR2(tb testing.TB, endpoint, accessKeyID, secretAccessKey, bucket string) *storage.GuardedR2Client {
+	tb.Helper()
+	client, err := storage.NewR2Client("test", accessKeyID, secretAccessKey, bucket, endpoint, "")
+	if err != nil {
+		tb.Fatalf("failed to create R2 client for %s: %v", endpoint, err)
+	}
+	breaker := storage.NewCircuitBreaker("r2", 1, time.Minute)
+	return storage.NewGuardedR2Client(client, breaker, "")
+}
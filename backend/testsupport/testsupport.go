@@ -0,0 +1,246 @@
+// Package testsupport Manager・Routesの結合テスト用の環境
+// 偽のPython CLI（cmd/fake-dsa-cli）で解析を実行し、キャンセル・削除・永続化のフォールバックなどを実際のコードパスで確認する
+// PostgresとMinIOはdocker-compose.ymlで起動し、環境変数が設定されていない場合はテストをスキップする（fixtures.go）
+package testsupport
+
+import (
+	"bytes"
+	"dsa-api/api"
+	"dsa-api/jobs"
+	"dsa-api/storage"
+	"dsa-api/testsupport/fakedsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKey Doで送る管理用のAPIキー（セッションを持たないテストから他のセッションの解析をキャンセル・削除できるように）
+const APIKey = "testsupport-admin"
+
+// Options 環境の設定（DB・R2はnilの場合は使わない）
+type Options struct {
+	DB *storage.DB
+	R2 *storage.GuardedR2Client
+	// 並列実行数（0は2）
+	MaxConcurrent int
+	// 設定していないUniProt IDの偽のCLIの動作
+	Default fakedsa.Behavior
+}
+
+// Env 結合テスト用の環境（テストごとに一時ディレクトリを作成する）
+type Env struct {
+	// storage/（STORAGE_DIR）とpython/（偽のCLIの作業ディレクトリ）を含むディレクトリ
+	Dir        string
+	StorageDir string
+	Manager    *jobs.Manager
+	Routes     *api.Routes
+	App        *fiber.App
+
+	pythonDir string
+	mu        sync.Mutex
+	behaviors map[string]fakedsa.Behavior
+}
+
+// NewEnv 偽のCLIで解析を実行するManager・Routesを作成する
+func NewEnv(tb testing.TB, opts Options) *Env {
+	tb.Helper()
+	cli := BuildFakeCLI(tb)
+
+	dir := tb.TempDir()
+	e := &Env{
+		Dir:        dir,
+		StorageDir: filepath.Join(dir, "storage"),
+		pythonDir:  filepath.Join(dir, "python"),
+		behaviors:  map[string]fakedsa.Behavior{"": opts.Default},
+	}
+	for _, d := range []string{e.StorageDir, e.pythonDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			tb.Fatalf("failed to create %s: %v", d, err)
+		}
+	}
+	// LocalPythonExecutorはstorageの親のpythonディレクトリにdsa_cli.pyがあることを確認してから起動する
+	if err := os.WriteFile(filepath.Join(e.pythonDir, "dsa_cli.py"), nil, 0644); err != nil {
+		tb.Fatalf("failed to create dsa_cli.py: %v", err)
+	}
+	e.writeBehaviors(tb)
+
+	if opts.DB != nil || opts.R2 != nil {
+		e.Manager = jobs.NewManagerWithPersistence(e.StorageDir, cli, opts.MaxConcurrent, opts.DB, opts.R2)
+	} else {
+		e.Manager = jobs.NewManager(e.StorageDir, cli, opts.MaxConcurrent)
+	}
+	e.Manager.StartUploadWorkers(time.Minute)
+
+	e.Routes = api.NewRoutes(e.Manager, opts.DB, opts.R2)
+	e.Routes.SetAPIKeys([]string{APIKey})
+	e.App = fiber.New()
+	e.Routes.SetupRoutes(e.App)
+	return e
+}
+
+// SetBehavior UniProt IDの偽のCLIの動作を設定する（""はデフォルト、以降に起動した解析に反映される）
+func (e *Env) SetBehavior(tb testing.TB, uniprotID string, b fakedsa.Behavior) {
+	tb.Helper()
+	e.mu.Lock()
+	e.behaviors[uniprotID] = b
+	e.mu.Unlock()
+	e.writeBehaviors(tb)
+}
+
+func (e *Env) writeBehaviors(tb testing.TB) {
+	tb.Helper()
+	e.mu.Lock()
+	data, err := json.Marshal(e.behaviors)
+	e.mu.Unlock()
+	if err != nil {
+		tb.Fatalf("failed to encode behaviors: %v", err)
+	}
+	// 起動中のCLIが書き込み途中のファイルを読まないように置き換える
+	path := filepath.Join(e.pythonDir, fakedsa.ConfigFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		tb.Fatalf("failed to write behaviors: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		tb.Fatalf("failed to write behaviors: %v", err)
+	}
+}
+
+// Do APIを管理用のAPIキー（APIKey）で呼び出してステータスコードとレスポンスボディを返す（bodyはnilでなければJSONにする）
+func (e *Env) Do(tb testing.TB, method, path string, body interface{}) (int, []byte) {
+	tb.Helper()
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			tb.Fatalf("failed to encode request body: %v", err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("X-API-Key", APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := e.App.Test(req, -1)
+	if err != nil {
+		tb.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tb.Fatalf("failed to read response of %s %s: %v", method, path, err)
+	}
+	return resp.StatusCode, data
+}
+
+// DoJSON APIを呼び出してレスポンスをoutにデコードし、ステータスコードを返す
+func (e *Env) DoJSON(tb testing.TB, method, path string, body, out interface{}) int {
+	tb.Helper()
+	status, data := e.Do(tb, method, path, body)
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			tb.Fatalf("failed to decode response of %s %s (status %d): %v: %s", method, path, status, err, data)
+		}
+	}
+	return status
+}
+
+// CreateJob 解析を作成してジョブIDを返す（paramsはPOST /api/jobsのparamsと同じ形式、nilはデフォルト）
+func (e *Env) CreateJob(tb testing.TB, uniprotID string, params map[string]interface{}) string {
+	tb.Helper()
+	parsed, err := jobs.ParseAnalysisParams(params)
+	if err != nil {
+		tb.Fatalf("invalid params for %s: %v", uniprotID, err)
+	}
+	job, err := e.Manager.CreateJob(uniprotID, parsed)
+	if err != nil {
+		tb.Fatalf("failed to create job for %s: %v", uniprotID, err)
+	}
+	return job.ID
+}
+
+// WaitForStatus ジョブがstatusesのいずれかになるまで待つ（timeoutを過ぎた場合はテストを失敗させる）
+func (e *Env) WaitForStatus(tb testing.TB, jobID string, timeout time.Duration, statuses ...jobs.JobStatus) *jobs.Job {
+	tb.Helper()
+	deadline := time.Now().Add(timeout)
+	var last *jobs.Job
+	for {
+		job, err := e.Manager.GetJob(jobID)
+		if err == nil {
+			last = job
+			for _, s := range statuses {
+				if job.Status == s {
+					return job
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			if last != nil {
+				tb.Fatalf("job %s did not reach %v within %s (status: %s, message: %s)", jobID, statuses, timeout, last.Status, last.Message)
+			}
+			tb.Fatalf("job %s did not reach %v within %s: %v", jobID, statuses, timeout, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+var (
+	fakeCLIOnce sync.Once
+	fakeCLIPath string
+	fakeCLIErr  error
+)
+
+// BuildFakeCLI 偽のPython CLIをビルドしてパスを返す（テストのプロセスごとに1回だけビルドする）
+// FAKE_DSA_CLIが設定されている場合はビルド済みのものを使う
+func BuildFakeCLI(tb testing.TB) string {
+	tb.Helper()
+	if path := os.Getenv("FAKE_DSA_CLI"); path != "" {
+		return path
+	}
+	fakeCLIOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "fake-dsa-cli")
+		if err != nil {
+			fakeCLIErr = err
+			return
+		}
+		name := "fake-dsa-cli"
+		if filepath.Separator == '\\' {
+			name += ".exe"
+		}
+		path := filepath.Join(dir, name)
+		out, err := exec.Command("go", "build", "-o", path, "dsa-api/cmd/fake-dsa-cli").CombinedOutput()
+		if err != nil {
+			fakeCLIErr = fmt.Errorf("go build failed: %v: %s", err, out)
+			return
+		}
+		fakeCLIPath = path
+	})
+	if fakeCLIErr != nil {
+		tb.Fatalf("failed to build fake dsa_cli: %v", fakeCLIErr)
+	}
+	return fakeCLIPath
+}
+
+// StatusOf GET /api/analyses/:idのsummary.status（取得できない場合は空文字列）
+func (e *Env) StatusOf(tb testing.TB, id string) string {
+	tb.Helper()
+	var resp struct {
+		Summary struct {
+			Status string `json:"status"`
+		} `json:"summary"`
+	}
+	if status := e.DoJSON(tb, http.MethodGet, "/api/analyses/"+id, nil, &resp); status != http.StatusOK {
+		return ""
+	}
+	return resp.Summary.Status
+}
@@ -0,0 +1,159 @@
+package webhooks
+
+import (
+	"dsa-api/logging"
+	"encoding/json"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// 期間が終わったダイジェストを確認する間隔
+	digestCheckInterval = time.Minute
+	// 1つのダイジェストに含めるイベントの上限（超えた分は件数のみ数える）
+	digestEventLimit = 500
+)
+
+// digestEvent ダイジェストにまとめる1つのイベント
+type digestEvent struct {
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// pendingDigest 期間中に溜めているイベント（Webhookごとに1つ、digests.jsonに保存する）
+type pendingDigest struct {
+	EndpointID  string         `json:"endpoint_id"`
+	Period      string         `json:"period"`
+	PeriodStart time.Time      `json:"period_start"`
+	PeriodEnd   time.Time      `json:"period_end"`
+	Counts      map[string]int `json:"counts"`
+	Events      []digestEvent  `json:"events"`
+	// digestEventLimitを超えてEventsに含めなかったイベントの数
+	Omitted int `json:"omitted,omitempty"`
+}
+
+// SetDigestLocation ダイジェストの期間の区切り（毎時0分・毎日0時）のタイムゾーンを設定する（デフォルトはUTC）
+func (d *Dispatcher) SetDigestLocation(loc *time.Location) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if loc != nil {
+		d.location = loc
+	}
+}
+
+// digestPeriod tを含む期間の開始と終了（d.muを保持して呼ぶ）
+func (d *Dispatcher) digestPeriod(period string, t time.Time) (time.Time, time.Time) {
+	local := t.In(d.location)
+	if period == DigestHourly {
+		start := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, d.location)
+		return start, start.Add(time.Hour)
+	}
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, d.location)
+	return start, start.AddDate(0, 0, 1)
+}
+
+func (d *Dispatcher) digestsPath() string {
+	return filepath.Join(d.dir, "digests.json")
+}
+
+// saveDigests d.muを保持して呼ぶ
+func (d *Dispatcher) saveDigests() {
+	pending := make([]*pendingDigest, 0, len(d.digests))
+	for _, digest := range d.digests {
+		pending = append(pending, digest)
+	}
+	if err := writeJSON(d.digestsPath(), pending); err != nil {
+		logging.Warnf("Failed to save webhook digests: %v", err)
+	}
+}
+
+// addToDigest イベントをWebhookのダイジェストに追加する（d.muを保持して呼ぶ）
+// 期間の途中でダイジェストの期間を変更した場合は、溜めているダイジェストの期間が終わってから新しい期間で溜める
+func (d *Dispatcher) addToDigest(endpoint *Endpoint, event string, data json.RawMessage, now time.Time) {
+	digest, ok := d.digests[endpoint.ID]
+	if !ok {
+		start, end := d.digestPeriod(endpoint.Digest, now)
+		digest = &pendingDigest{
+			EndpointID:  endpoint.ID,
+			Period:      endpoint.Digest,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			Counts:      make(map[string]int),
+		}
+		d.digests[endpoint.ID] = digest
+	}
+	digest.Counts[event]++
+	if len(digest.Events) >= digestEventLimit {
+		digest.Omitted++
+		return
+	}
+	digest.Events = append(digest.Events, digestEvent{Event: event, Data: data, CreatedAt: now})
+}
+
+// digestScheduler 期間が終わったダイジェストを定期的に配信待ちにする
+func (d *Dispatcher) digestScheduler() {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		d.FlushDigests(now)
+	}
+}
+
+// FlushDigests nowまでに期間が終わったダイジェストを配信待ちにし、配信待ちにした数を返す
+func (d *Dispatcher) FlushDigests(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	flushed := make([]*Delivery, 0)
+	removed := 0
+	for id, digest := range d.digests {
+		if now.Before(digest.PeriodEnd) {
+			continue
+		}
+		delete(d.digests, id)
+		removed++
+		if _, ok := d.endpoints[id]; !ok {
+			continue
+		}
+		deliveryID := randomID("whd_", 12)
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":         deliveryID,
+			"event":      EventDigest,
+			"created_at": now.Format(time.RFC3339),
+			"data": map[string]interface{}{
+				"period":       digest.Period,
+				"period_start": digest.PeriodStart.Format(time.RFC3339),
+				"period_end":   digest.PeriodEnd.Format(time.RFC3339),
+				"counts":       digest.Counts,
+				"events":       digest.Events,
+				"omitted":      digest.Omitted,
+			},
+		})
+		if err != nil {
+			logging.Warnf("Failed to encode webhook digest: %v", err)
+			continue
+		}
+		next := now
+		delivery := &Delivery{
+			ID:            deliveryID,
+			EndpointID:    id,
+			Event:         EventDigest,
+			Payload:       payload,
+			Status:        DeliveryPending,
+			NextAttemptAt: &next,
+			CreatedAt:     now,
+		}
+		d.deliveries = append(d.deliveries, delivery)
+		flushed = append(flushed, delivery)
+	}
+	if removed > 0 {
+		d.saveDigests()
+	}
+	if len(flushed) == 0 {
+		return 0
+	}
+	d.saveDeliveries(flushed, d.pruneDeliveries())
+	d.notify()
+	return len(flushed)
+}
@@ -0,0 +1,654 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dsa-api/jobs"
+	"dsa-api/logging"
+	"dsa-api/storage"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// デフォルトの再試行ポリシー（10秒, 20秒, 40秒, ... 最大8回）
+const (
+	defaultMaxAttempts = 8
+	defaultBaseDelay   = 10 * time.Second
+	maxDelay           = 6 * time.Hour
+	// エンドポイントごとに保持する配信履歴の件数（配信待ちは除く）
+	deliveryHistoryLimit = 100
+	deliveryTimeout      = 10 * time.Second
+)
+
+// Dispatcher Webhookの登録と配信を管理する
+// 配信はジョブのgoroutineから切り離し、配信記録（DBのwebhook_deliveries、DBがない場合はdeliveries.json）に基づいて専用ワーカーが送信・再試行する
+type Dispatcher struct {
+	dir         string
+	db          *storage.DB
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	// ループバック・プライベートなどのアドレスへの送信を許可する（社内ネットワークの受信側を使う場合）
+	allowPrivate bool
+
+	mu         sync.Mutex
+	endpoints  map[string]*Endpoint
+	deliveries []*Delivery
+	inFlight   map[string]bool
+	wake       chan struct{}
+	started    bool
+	// ダイジェストを指定したWebhookの期間中のイベント（WebhookのIDごと）
+	digests  map[string]*pendingDigest
+	location *time.Location
+}
+
+// storedEndpoint 保存用（セッションIDも保存する）
+type storedEndpoint struct {
+	Endpoint
+	SessionID string `json:"session_id"`
+}
+
+// NewDispatcher dirに保存されたWebhookと配信記録を読み込む（dbを指定した場合、配信記録はDBから読み込む）
+func NewDispatcher(dir string, db *storage.DB) (*Dispatcher, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create webhook directory: %w", err)
+	}
+
+	d := &Dispatcher{
+		dir:         dir,
+		db:          db,
+		client:      newHTTPClient(false),
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		endpoints:   make(map[string]*Endpoint),
+		inFlight:    make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+		digests:     make(map[string]*pendingDigest),
+		location:    time.UTC,
+	}
+
+	var stored []storedEndpoint
+	if err := readJSON(d.endpointsPath(), &stored); err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	for _, s := range stored {
+		endpoint := s.Endpoint
+		endpoint.SessionID = s.SessionID
+		d.endpoints[endpoint.ID] = &endpoint
+	}
+	if err := d.loadDeliveries(); err != nil {
+		return nil, fmt.Errorf("failed to load webhook deliveries: %w", err)
+	}
+	var digests []*pendingDigest
+	if err := readJSON(d.digestsPath(), &digests); err != nil {
+		return nil, fmt.Errorf("failed to load webhook digests: %w", err)
+	}
+	for _, digest := range digests {
+		d.digests[digest.EndpointID] = digest
+	}
+	return d, nil
+}
+
+// SetRetryPolicy 再試行回数の上限と初回の再試行間隔を設定する（0以下はデフォルト値）
+func (d *Dispatcher) SetRetryPolicy(maxAttempts int, baseDelay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if maxAttempts > 0 {
+		d.maxAttempts = maxAttempts
+	}
+	if baseDelay > 0 {
+		d.baseDelay = baseDelay
+	}
+}
+
+// SetAllowPrivateNetworks ループバック・プライベート・リンクローカルのアドレスへの送信を許可するか（デフォルトは拒否）
+func (d *Dispatcher) SetAllowPrivateNetworks(allow bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.allowPrivate = allow
+	d.client = newHTTPClient(allow)
+}
+
+// Start 配信ワーカーを起動する
+func (d *Dispatcher) Start(workers int) {
+	d.mu.Lock()
+	if d.started {
+		d.mu.Unlock()
+		return
+	}
+	d.started = true
+	d.mu.Unlock()
+
+	if workers <= 0 {
+		workers = 2
+	}
+	for i := 0; i < workers; i++ {
+		go d.worker()
+	}
+	// 停止中に期間が終わったダイジェストは起動時に配信する
+	d.FlushDigests(time.Now())
+	go d.digestScheduler()
+}
+
+func (d *Dispatcher) endpointsPath() string {
+	return filepath.Join(d.dir, "endpoints.json")
+}
+
+func (d *Dispatcher) deliveriesPath() string {
+	return filepath.Join(d.dir, "deliveries.json")
+}
+
+// saveEndpoints d.muを保持して呼ぶ
+func (d *Dispatcher) saveEndpoints() {
+	stored := make([]storedEndpoint, 0, len(d.endpoints))
+	for _, endpoint := range d.endpoints {
+		stored = append(stored, storedEndpoint{Endpoint: *endpoint, SessionID: endpoint.SessionID})
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].CreatedAt.Before(stored[j].CreatedAt)
+	})
+	if err := writeJSON(d.endpointsPath(), stored); err != nil {
+		logging.Warnf("Failed to save webhooks: %v", err)
+	}
+}
+
+// loadDeliveries 配信記録を読み込む
+func (d *Dispatcher) loadDeliveries() error {
+	if d.db == nil {
+		return readJSON(d.deliveriesPath(), &d.deliveries)
+	}
+	rows, err := d.db.ListWebhookDeliveries(context.Background())
+	if err != nil {
+		return fmt.Errorf("%w (apply migrations/021_create_webhook_deliveries.sql)", err)
+	}
+	for _, row := range rows {
+		d.deliveries = append(d.deliveries, &Delivery{
+			ID:             row.ID,
+			EndpointID:     row.EndpointID,
+			Event:          row.Event,
+			Payload:        row.Payload,
+			Status:         DeliveryStatus(row.Status),
+			Attempts:       row.Attempts,
+			NextAttemptAt:  row.NextAttemptAt,
+			LastError:      row.LastError,
+			ResponseStatus: row.ResponseStatus,
+			CreatedAt:      row.CreatedAt,
+			DeliveredAt:    row.DeliveredAt,
+		})
+	}
+	return nil
+}
+
+// saveDeliveries 配信記録を保存する（d.muを保持して呼ぶ）
+// DBの場合はchangedの配信を書き込み、removedの配信を削除する。deliveries.jsonの場合はすべての配信を書き込む
+func (d *Dispatcher) saveDeliveries(changed []*Delivery, removed []string) {
+	if d.db == nil {
+		if err := writeJSON(d.deliveriesPath(), d.deliveries); err != nil {
+			logging.Warnf("Failed to save webhook deliveries: %v", err)
+		}
+		return
+	}
+
+	ctx := context.Background()
+	if len(removed) > 0 {
+		if err := d.db.DeleteWebhookDeliveries(ctx, removed); err != nil {
+			logging.Warnf("Failed to delete webhook deliveries: %v", err)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	rows := make([]*storage.WebhookDelivery, 0, len(changed))
+	for _, delivery := range changed {
+		rows = append(rows, &storage.WebhookDelivery{
+			ID:             delivery.ID,
+			EndpointID:     delivery.EndpointID,
+			Event:          delivery.Event,
+			Payload:        delivery.Payload,
+			Status:         string(delivery.Status),
+			Attempts:       delivery.Attempts,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			LastError:      delivery.LastError,
+			ResponseStatus: delivery.ResponseStatus,
+			CreatedAt:      delivery.CreatedAt,
+			DeliveredAt:    delivery.DeliveredAt,
+		})
+	}
+	if err := d.db.SaveWebhookDeliveries(ctx, rows); err != nil {
+		logging.Warnf("Failed to save webhook deliveries: %v", err)
+	}
+}
+
+// CreateEndpoint Webhookを登録する（返り値には署名用の鍵が含まれる）
+// digestにhourly・dailyを指定すると、イベントを期間ごとに1つの配信（jobs.digest）にまとめる
+// 送信先のホストがループバック・プライベートなどのアドレスに解決される場合はErrPrivateAddress（SetAllowPrivateNetworksで許可した場合を除く）
+func (d *Dispatcher) CreateEndpoint(ctx context.Context, sessionID, rawURL string, events []string, digest string) (*Endpoint, error) {
+	if err := ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	allowPrivate := d.allowPrivate
+	d.mu.Unlock()
+	if !allowPrivate {
+		u, _ := url.Parse(rawURL)
+		if err := checkHost(ctx, u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateEvents(events); err != nil {
+		return nil, err
+	}
+	digest, err := NormalizeDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []string{}
+	}
+
+	endpoint := &Endpoint{
+		ID:        randomID("wh_", 12),
+		URL:       rawURL,
+		Events:    events,
+		Digest:    digest,
+		SessionID: sessionID,
+		Secret:    randomID("whsec_", 24),
+		CreatedAt: time.Now(),
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.endpoints[endpoint.ID] = endpoint
+	d.saveEndpoints()
+
+	created := *endpoint
+	return &created, nil
+}
+
+// SetEndpointDigest Webhookのダイジェストの期間を変更する（immediateでイベントごとの配信に戻す）
+// 変更前に溜めていたイベントは、その期間が終わったときに配信する
+func (d *Dispatcher) SetEndpointDigest(sessionID, id, digest string) (*Endpoint, error) {
+	digest, err := NormalizeDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	endpoint, err := d.ownedEndpoint(sessionID, id)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Digest = digest
+	d.saveEndpoints()
+
+	updated := endpoint.public()
+	return &updated, nil
+}
+
+// ListEndpoints セッションのWebhookの一覧（鍵を含まない）
+func (d *Dispatcher) ListEndpoints(sessionID string) []Endpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	endpoints := make([]Endpoint, 0)
+	for _, endpoint := range d.endpoints {
+		if endpoint.SessionID == sessionID {
+			endpoints = append(endpoints, endpoint.public())
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt)
+	})
+	return endpoints
+}
+
+// ownedEndpoint セッションが所有するWebhookを返す（d.muを保持して呼ぶ）
+func (d *Dispatcher) ownedEndpoint(sessionID, id string) (*Endpoint, error) {
+	endpoint, ok := d.endpoints[id]
+	if !ok || endpoint.SessionID != sessionID {
+		return nil, ErrEndpointNotFound
+	}
+	return endpoint, nil
+}
+
+// DeleteEndpoint Webhookと配信記録を削除する
+func (d *Dispatcher) DeleteEndpoint(sessionID, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.ownedEndpoint(sessionID, id); err != nil {
+		return err
+	}
+	delete(d.endpoints, id)
+	kept := d.deliveries[:0]
+	removed := make([]string, 0)
+	for _, delivery := range d.deliveries {
+		if delivery.EndpointID != id {
+			kept = append(kept, delivery)
+		} else {
+			removed = append(removed, delivery.ID)
+		}
+	}
+	d.deliveries = kept
+	d.saveEndpoints()
+	d.saveDeliveries(nil, removed)
+	if _, ok := d.digests[id]; ok {
+		delete(d.digests, id)
+		d.saveDigests()
+	}
+	return nil
+}
+
+// Deliveries Webhookの配信記録（新しい順）
+func (d *Dispatcher) Deliveries(sessionID, id string) ([]Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.ownedEndpoint(sessionID, id); err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0)
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if d.deliveries[i].EndpointID == id {
+			deliveries = append(deliveries, *d.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+// Redeliver 配信を再送する（デッドレターになった配信の手動再送用）
+func (d *Dispatcher) Redeliver(sessionID, id, deliveryID string) (*Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.ownedEndpoint(sessionID, id); err != nil {
+		return nil, err
+	}
+	for _, delivery := range d.deliveries {
+		if delivery.ID != deliveryID || delivery.EndpointID != id {
+			continue
+		}
+		if delivery.Status != DeliveryPending {
+			now := time.Now()
+			delivery.Status = DeliveryPending
+			delivery.Attempts = 0
+			delivery.NextAttemptAt = &now
+			d.saveDeliveries([]*Delivery{delivery}, nil)
+			d.notify()
+		}
+		copied := *delivery
+		return &copied, nil
+	}
+	return nil, ErrDeliveryNotFound
+}
+
+// Enqueue イベントを購読しているセッションのWebhookへの配信を登録する
+// ダイジェストを指定したWebhookにはすぐに配信せず、期間の終わりにまとめて配信する
+func (d *Dispatcher) Enqueue(event, sessionID string, data interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	enqueued := make([]*Delivery, 0)
+	digested := 0
+	for _, endpoint := range d.endpoints {
+		if endpoint.SessionID != sessionID || !endpoint.subscribes(event) {
+			continue
+		}
+		if endpoint.Digest != "" {
+			encoded, err := json.Marshal(data)
+			if err != nil {
+				logging.Warnf("Failed to encode webhook payload: %v", err)
+				return
+			}
+			d.addToDigest(endpoint, event, encoded, now)
+			digested++
+			continue
+		}
+		deliveryID := randomID("whd_", 12)
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":         deliveryID,
+			"event":      event,
+			"created_at": now.Format(time.RFC3339),
+			"data":       data,
+		})
+		if err != nil {
+			logging.Warnf("Failed to encode webhook payload: %v", err)
+			return
+		}
+		next := now
+		delivery := &Delivery{
+			ID:            deliveryID,
+			EndpointID:    endpoint.ID,
+			Event:         event,
+			Payload:       payload,
+			Status:        DeliveryPending,
+			NextAttemptAt: &next,
+			CreatedAt:     now,
+		}
+		d.deliveries = append(d.deliveries, delivery)
+		enqueued = append(enqueued, delivery)
+	}
+	if digested > 0 {
+		d.saveDigests()
+	}
+	if len(enqueued) == 0 {
+		return
+	}
+	d.saveDeliveries(enqueued, d.pruneDeliveries())
+	d.notify()
+}
+
+// JobListener ジョブの終了（完了・失敗・キャンセル）をWebhookで通知するリスナー
+func (d *Dispatcher) JobListener() func(jobs.JobUpdate) {
+	// キャンセル時など同じ終了状態が複数回通知されることがあるため、ジョブごとに最後のイベントを覚えておく
+	var mu sync.Mutex
+	last := make(map[string]string)
+
+	return func(update jobs.JobUpdate) {
+		var event string
+		switch update.Status {
+		case jobs.StatusDone:
+			event = EventJobCompleted
+		case jobs.StatusFailed:
+			event = EventJobFailed
+		case jobs.StatusCancelled:
+			event = EventJobCancelled
+		default:
+			return
+		}
+		if update.SessionID == "" {
+			return
+		}
+		mu.Lock()
+		if last[update.JobID] == event {
+			mu.Unlock()
+			return
+		}
+		last[update.JobID] = event
+		mu.Unlock()
+		d.Enqueue(event, update.SessionID, update)
+	}
+}
+
+// pruneDeliveries エンドポイントごとに古い配信記録を削除し、削除した配信のIDを返す（d.muを保持して呼ぶ）
+func (d *Dispatcher) pruneDeliveries() []string {
+	counts := make(map[string]int)
+	keep := make([]bool, len(d.deliveries))
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		delivery := d.deliveries[i]
+		if delivery.Status == DeliveryPending || d.inFlight[delivery.ID] {
+			keep[i] = true
+			continue
+		}
+		counts[delivery.EndpointID]++
+		keep[i] = counts[delivery.EndpointID] <= deliveryHistoryLimit
+	}
+	kept := d.deliveries[:0]
+	removed := make([]string, 0)
+	for i, delivery := range d.deliveries {
+		if keep[i] {
+			kept = append(kept, delivery)
+		} else {
+			removed = append(removed, delivery.ID)
+		}
+	}
+	d.deliveries = kept
+	return removed
+}
+
+// notify 待機中のワーカーを起こす（d.muを保持して呼ぶ）
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) worker() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		delivery, endpoint := d.claimDue()
+		if delivery == nil {
+			select {
+			case <-d.wake:
+			case <-ticker.C:
+			}
+			continue
+		}
+		statusCode, err := d.send(endpoint, delivery)
+		d.finish(delivery.ID, statusCode, err)
+	}
+}
+
+// claimDue 送信時刻を過ぎた配信を1件取り出す（コピーを返す）
+func (d *Dispatcher) claimDue() (*Delivery, *Endpoint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for _, delivery := range d.deliveries {
+		if delivery.Status != DeliveryPending || d.inFlight[delivery.ID] {
+			continue
+		}
+		if delivery.NextAttemptAt != nil && delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		endpoint, ok := d.endpoints[delivery.EndpointID]
+		if !ok {
+			continue
+		}
+		d.inFlight[delivery.ID] = true
+		copiedDelivery := *delivery
+		copiedEndpoint := *endpoint
+		return &copiedDelivery, &copiedEndpoint
+	}
+	return nil, nil
+}
+
+// send 署名付きでPOSTする
+// X-DSA-Signature: t=<UNIX時刻>,v1=<HMAC-SHA256("<UNIX時刻>.<ボディ>")の16進数>
+func (d *Dispatcher) send(endpoint *Endpoint, delivery *Delivery) (int, error) {
+	timestamp := fmt.Sprintf("%d", time.Now().Unix())
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DSA-Webhook/1.0")
+	req.Header.Set("X-DSA-Event", delivery.Event)
+	req.Header.Set("X-DSA-Delivery", delivery.ID)
+	req.Header.Set("X-DSA-Signature", fmt.Sprintf("t=%s,v1=%s", timestamp, Sign(endpoint.Secret, timestamp, delivery.Payload)))
+
+	d.mu.Lock()
+	client := d.client
+	d.mu.Unlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign 署名を計算する（受信側の検証用にも公開する）
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// finish 送信結果を記録し、失敗した場合は指数バックオフで再試行を予約する
+func (d *Dispatcher) finish(deliveryID string, statusCode int, sendErr error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inFlight, deliveryID)
+	var delivery *Delivery
+	for _, dl := range d.deliveries {
+		if dl.ID == deliveryID {
+			delivery = dl
+			break
+		}
+	}
+	if delivery == nil {
+		// 送信中にWebhookが削除された
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.ResponseStatus = statusCode
+	if sendErr == nil {
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = sendErr.Error()
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = DeliveryDead
+			delivery.NextAttemptAt = nil
+			logging.Warnf("Webhook delivery %s moved to dead letter after %d attempts: %v", delivery.ID, delivery.Attempts, sendErr)
+		} else {
+			next := now.Add(d.backoff(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+			logging.Warnf("Webhook delivery %s failed (attempt %d/%d), retrying at %s: %v", delivery.ID, delivery.Attempts, d.maxAttempts, next.Format(time.RFC3339), sendErr)
+		}
+	}
+	d.saveDeliveries([]*Delivery{delivery}, nil)
+}
+
+// backoff attempts回目の失敗後の待機時間（d.muを保持して呼ぶ）
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.baseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
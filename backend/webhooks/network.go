@@ -0,0 +1,59 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress 送信先がループバック・プライベート・リンクローカル・未指定のアドレス（サーバー内部のサービスへの送信を防ぐ）
+var ErrPrivateAddress = errors.New("url must not resolve to a loopback, private, link-local or unspecified address")
+
+// blockedIP 送信を許可しないアドレスか（クラウドのメタデータの169.254.169.254はリンクローカルに含まれる）
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkHost ホスト名を解決し、いずれかのアドレスが送信を許可しないアドレスの場合はErrPrivateAddress
+func checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve host: %w", err)
+	}
+	for _, addr := range addrs {
+		if blockedIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// dialControl 接続する直前に解決済みのアドレスを検証する（登録後のDNSの変更・リダイレクト先にも適用する）
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// newHTTPClient 配信用のクライアント（allowPrivateがfalseの場合は送信を許可しないアドレスへの接続を拒否する）
+func newHTTPClient(allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = dialControl
+		// プロキシ経由では送信先のアドレスを検証できないため使わない
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: deliveryTimeout, Transport: transport}
+}
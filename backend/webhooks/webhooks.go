@@ -0,0 +1,180 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// イベント種別
+const (
+	EventJobCompleted = "job.completed"
+	EventJobFailed    = "job.failed"
+	EventJobCancelled = "job.cancelled"
+	// アラートのルールが条件を満たした（ALERTS_ENABLED、解析の完了時に評価する）
+	EventAlertTriggered = "alert.triggered"
+)
+
+// Events 購読できるイベントの一覧
+var Events = []string{EventJobCompleted, EventJobFailed, EventJobCancelled, EventAlertTriggered}
+
+// EventDigest 期間中のイベントをまとめた配信（ダイジェストを指定したWebhookのみ、購読の指定は不要）
+const EventDigest = "jobs.digest"
+
+// ダイジェストの期間（Endpoint.Digestが空の場合はイベントごとに配信する）
+const (
+	DigestImmediate = "immediate"
+	DigestHourly    = "hourly"
+	DigestDaily     = "daily"
+)
+
+// Digests 指定できるダイジェストの期間
+var Digests = []string{DigestImmediate, DigestHourly, DigestDaily}
+
+// 配信状態
+type DeliveryStatus string
+
+const (
+	// DeliveryPending 配信待ち（再試行待ちを含む）
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded 2xxが返された
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead 再試行回数の上限に達した（デッドレター、手動で再配信可能）
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+var (
+	ErrEndpointNotFound = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Endpoint Webhookの送信先
+// セッション（dsa_session_id）ごとに登録し、そのセッションのジョブのイベントのみ送信する
+type Endpoint struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// ダイジェストの期間（hourly・daily、空の場合はイベントごとに配信する）
+	Digest    string `json:"digest,omitempty"`
+	SessionID string `json:"-"`
+	// 署名用の鍵（作成時のレスポンスでのみ返す）
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery 1回のイベント送信（再試行の状態を含む）
+type Delivery struct {
+	ID             string          `json:"id"`
+	EndpointID     string          `json:"endpoint_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// subscribes エンドポイントがイベントを購読しているか（Eventsが空の場合はすべて）
+func (e *Endpoint) subscribes(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+// public 一覧表示用（鍵を含めない）
+func (e *Endpoint) public() Endpoint {
+	copied := *e
+	copied.Secret = ""
+	return copied
+}
+
+// ValidateURL 送信先URLを検証する
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("url must use http or https")
+	}
+	if u.Host == "" {
+		return errors.New("url must have a host")
+	}
+	return nil
+}
+
+// ValidateEvents 購読するイベントを検証する
+func ValidateEvents(events []string) error {
+	for _, event := range events {
+		known := false
+		for _, ev := range Events {
+			if ev == event {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event: %s", event)
+		}
+	}
+	return nil
+}
+
+// NormalizeDigest ダイジェストの期間を検証し、保存する値（immediateは空）に変換する
+func NormalizeDigest(digest string) (string, error) {
+	switch digest {
+	case "", DigestImmediate:
+		return "", nil
+	case DigestHourly, DigestDaily:
+		return digest, nil
+	}
+	return "", fmt.Errorf("unknown digest: %s", digest)
+}
+
+func randomID(prefix string, n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate random id: %v", err))
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+// readJSON ファイルが存在しない場合は何もしない
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON 一時ファイルに書き込んでからリネームする（書き込み途中で読まれないように）
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,266 @@
+// Package xlsx 表形式のデータをExcelのブック（.xlsx、Office Open XML）として書き出す
+// レポート用の最小限の実装で、文字列はインライン文字列として書き込み、見出し行は太字・固定表示にする
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// シート名の最大文字数（Excelの制限）
+const maxSheetName = 31
+
+// ContentType .xlsxのContent-Type
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Sheet 1枚のシート（Headerは1行目に太字で書き込む）
+// Rowsの値はstring・数値（int・int64・float64）・bool・nil（空のセル）で、それ以外はfmt.Sprintで文字列にする
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Workbook シートの並び
+type Workbook struct {
+	Sheets []*Sheet
+}
+
+// AddSheet シートを追加して返す
+func (wb *Workbook) AddSheet(name string, header ...string) *Sheet {
+	sheet := &Sheet{Name: name, Header: header}
+	wb.Sheets = append(wb.Sheets, sheet)
+	return sheet
+}
+
+// AddRow 行を追加する
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.Rows = append(s.Rows, values)
+}
+
+// Write ブックをwに書き込む
+func (wb *Workbook) Write(w io.Writer) error {
+	if len(wb.Sheets) == 0 {
+		return errors.New("workbook has no sheets")
+	}
+	seen := make(map[string]bool)
+	for _, sheet := range wb.Sheets {
+		if err := validSheetName(sheet.Name); err != nil {
+			return err
+		}
+		key := strings.ToLower(sheet.Name)
+		if seen[key] {
+			return fmt.Errorf("duplicate sheet name: %s", sheet.Name)
+		}
+		seen[key] = true
+	}
+
+	zw := zip.NewWriter(w)
+	modTime := time.Now()
+	parts := []part{
+		{"[Content_Types].xml", wb.writeContentTypes},
+		{"_rels/.rels", writeRootRels},
+		{"xl/workbook.xml", wb.writeWorkbook},
+		{"xl/_rels/workbook.xml.rels", wb.writeWorkbookRels},
+		{"xl/styles.xml", writeStyles},
+	}
+	for i, sheet := range wb.Sheets {
+		parts = append(parts, part{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.write})
+	}
+	for _, p := range parts {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: p.name, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(fw)
+		if err := p.write(bw); err != nil {
+			return fmt.Errorf("failed to write %s: %w", p.name, err)
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// part ブックを構成する1ファイル
+type part struct {
+	name  string
+	write func(io.Writer) error
+}
+
+// validSheetName Excelで使えるシート名か（1〜31文字、[]:*?/\を含まない）
+func validSheetName(name string) error {
+	if name == "" || len([]rune(name)) > maxSheetName || strings.ContainsAny(name, `[]:*?/\`) {
+		return fmt.Errorf("invalid sheet name: %q", name)
+	}
+	return nil
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+func (wb *Workbook) writeContentTypes(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range wb.Sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeRootRels(w io.Writer) error {
+	_, err := io.WriteString(w, xmlHeader+
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>`+
+		`</Relationships>`)
+	return err
+}
+
+func (wb *Workbook) writeWorkbook(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range wb.Sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheet.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeWorkbookRels シートはrId1〜、スタイルはシートの後
+func (wb *Workbook) writeWorkbookRels(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(xmlHeader)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range wb.Sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(wb.Sheets)+1)
+	b.WriteString(`</Relationships>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeStyles セルの書式（0: 標準、1: 太字（見出し））
+func writeStyles(w io.Writer) error {
+	_, err := io.WriteString(w, xmlHeader+
+		`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>`+
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>`+
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`+
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`+
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>`+
+		`</styleSheet>`)
+	return err
+}
+
+// styleHeader 見出し行のセルの書式
+const styleHeader = 1
+
+func (s *Sheet) write(w io.Writer) error {
+	if _, err := io.WriteString(w, xmlHeader+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`); err != nil {
+		return err
+	}
+	if len(s.Header) > 0 {
+		// 見出し行をスクロールしても表示されるように固定する
+		if _, err := io.WriteString(w, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, `<sheetData>`); err != nil {
+		return err
+	}
+	row := 1
+	if len(s.Header) > 0 {
+		values := make([]interface{}, len(s.Header))
+		for i, h := range s.Header {
+			values[i] = h
+		}
+		if err := writeRow(w, row, values, styleHeader); err != nil {
+			return err
+		}
+		row++
+	}
+	for _, values := range s.Rows {
+		if err := writeRow(w, row, values, 0); err != nil {
+			return err
+		}
+		row++
+	}
+	_, err := io.WriteString(w, `</sheetData></worksheet>`)
+	return err
+}
+
+func writeRow(w io.Writer, row int, values []interface{}, style int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, row)
+	for i, v := range values {
+		ref := ColumnName(i) + strconv.Itoa(row)
+		styleAttr := ""
+		if style != 0 {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+		switch v := v.(type) {
+		case nil:
+			continue
+		case float64:
+			// NaN・Infは数値として書き込めないため空のセルにする
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr, strconv.FormatFloat(v, 'g', -1, 64))
+		case int:
+			fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case int64:
+			fmt.Fprintf(&b, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case bool:
+			n := 0
+			if v {
+				n = 1
+			}
+			fmt.Fprintf(&b, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, styleAttr, n)
+		default:
+			text, ok := v.(string)
+			if !ok {
+				text = fmt.Sprint(v)
+			}
+			fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, escape(text))
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ColumnName 0始まりの列番号の列名（0: A, 25: Z, 26: AA）
+func ColumnName(i int) string {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A'+i%26)) + name
+		i = i/26 - 1
+	}
+	return name
+}
+
+// escape XMLのテキスト・属性値として書き込めるようにする（XMLで使えない制御文字はU+FFFDになる）
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -476,6 +476,28 @@ function CompareContent() {
                         </td>
                       ))}
                     </tr>
+                    <tr>
+                      <td className="px-2 sm:px-4 py-2 sm:py-3 font-medium border-r text-xs sm:text-sm sticky left-0 bg-white z-10">
+                        手法別内訳
+                      </td>
+                      {analyses.map((analysis) => (
+                        <td
+                          key={analysis.id}
+                          className="px-2 sm:px-4 py-2 sm:py-3 text-xs sm:text-sm"
+                        >
+                          {analysis.metrics?.method_breakdown
+                            ? Object.entries(
+                                analysis.metrics.method_breakdown
+                              ).map(([method, stats]) => (
+                                <div key={method} className="whitespace-nowrap">
+                                  {method}: {stats.entries}件 / UMF{" "}
+                                  {stats.umf ?? "-"}
+                                </div>
+                              ))
+                            : "-"}
+                        </td>
+                      ))}
+                    </tr>
                   </tbody>
                 </table>
               </div>
@@ -504,7 +526,7 @@ function CompareContent() {
                     </div>
                     <div className="flex justify-center">
                       <img
-                        src={getResultUrl(analysis.id, "heatmap.png")}
+                        src={getResultUrl(analysis.id, "heatmap.png", "medium")}
                         alt={`Heatmap for ${analysis.uniprot_id}`}
                         className="w-full h-auto rounded-lg shadow-md"
                         onError={(e) => {
@@ -548,7 +570,7 @@ function CompareContent() {
                     </div>
                     <div className="flex justify-center">
                       <img
-                        src={getResultUrl(analysis.id, "dist_score.png")}
+                        src={getResultUrl(analysis.id, "dist_score.png", "medium")}
                         alt={`Distance-Score Plot for ${analysis.uniprot_id}`}
                         className="w-full h-auto rounded-lg shadow-md"
                         onError={(e) => {
@@ -3,7 +3,7 @@
 import { useEffect, useState, Suspense, useCallback, useMemo } from "react";
 import { useRouter, useSearchParams } from "next/navigation";
 import Link from "next/link";
-import { createJob, type JobParams } from "@/lib/api";
+import { createJob, getJobDraft, type JobParams } from "@/lib/api";
 import {
   getAnalysis,
   listAnalyses,
@@ -64,6 +64,20 @@ function AnalysisContent() {
     }
   }, [searchParams, loadingPrefill]);
 
+  // ディープリンク: 外部サイトからのクエリ（uniprot_id、パラメータ）でフォームを初期化
+  useEffect(() => {
+    if (!searchParams.get("uniprot_id") || searchParams.get("prefill")) return;
+    getJobDraft(searchParams.toString())
+      .then((draft) => {
+        setUniprotId(draft.uniprot_id);
+        setParams((prev) => ({ ...prev, ...draft.params }));
+      })
+      .catch((err) => {
+        console.error("Failed to load job draft:", err);
+        setError(err instanceof Error ? err.message : "Invalid link parameters");
+      });
+  }, [searchParams]);
+
   // 進行中の解析を取得
   const fetchRunningAnalyses = useCallback(async () => {
     setLoadingAnalyses(true);
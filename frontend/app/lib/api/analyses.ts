@@ -1,10 +1,12 @@
 import type {
   Analysis,
+  AnalysisPage,
   AnalysisSummary,
   AnalysisParams,
 } from "@/app/lib/types/analysis";
+import { fetchWithCSRF } from "@/lib/csrf";
 
-export type { AnalysisSummary };
+export type { AnalysisPage, AnalysisSummary };
 
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
 
@@ -16,6 +18,12 @@ export interface ListAnalysesFilters {
   to?: string; // ISO date
   limit?: number;
   offset?: number;
+  // UniProt ID・タンパク質名の部分一致
+  q?: string;
+  // 指標の範囲（{ mean_score_gt: 10, entries_gte: 5 }）
+  metric_ranges?: Record<string, number>;
+  // 並べ替えの列（カンマ区切り、-で降順、例: "-mean_score,created_at"）
+  sort?: string;
 }
 
 export interface RerunOverrides {
@@ -41,11 +49,60 @@ export async function listAnalyses(
   if (filters?.to) params.append("to", filters.to);
   if (filters?.limit) params.append("limit", filters.limit.toString());
   if (filters?.offset) params.append("offset", filters.offset.toString());
+  if (filters?.q) params.append("q", filters.q);
+  for (const [key, value] of Object.entries(filters?.metric_ranges ?? {})) {
+    params.append(key, value.toString());
+  }
+  if (filters?.sort) params.append("sort", filters.sort);
 
   const url = `${API_BASE_URL}/api/analyses${
     params.toString() ? `?${params.toString()}` : ""
   }`;
-  const response = await fetch(url);
+  // 一覧はセッション（Cookie）の解析に絞り込まれる
+  const response = await fetch(url, { credentials: "include" });
+
+  if (!response.ok) {
+    const error = await response
+      .json()
+      .catch(() => ({ error: "Failed to list analyses" }));
+    throw new Error(error.error || "Failed to list analyses");
+  }
+
+  return response.json();
+}
+
+export interface ListAnalysesPageOptions
+  extends Omit<ListAnalysesFilters, "limit" | "offset" | "sort"> {
+  page_size?: number;
+  // 前のページのnext_cursor（省略時は先頭のページ）
+  cursor?: string;
+  include_total?: boolean;
+}
+
+/**
+ * List analyses one page at a time (cursor pagination)
+ */
+export async function listAnalysesPage(
+  options?: ListAnalysesPageOptions
+): Promise<AnalysisPage> {
+  const params = new URLSearchParams();
+  if (options?.uniprot_id) params.append("uniprot_id", options.uniprot_id);
+  if (options?.method) params.append("method", options.method);
+  if (options?.status) params.append("status", options.status);
+  if (options?.from) params.append("from", options.from);
+  if (options?.to) params.append("to", options.to);
+  if (options?.q) params.append("q", options.q);
+  for (const [key, value] of Object.entries(options?.metric_ranges ?? {})) {
+    params.append(key, value.toString());
+  }
+  params.append("page_size", (options?.page_size ?? 50).toString());
+  if (options?.cursor) params.append("cursor", options.cursor);
+  if (options?.include_total) params.append("include_total", "true");
+
+  const response = await fetch(
+    `${API_BASE_URL}/api/analyses?${params.toString()}`,
+    { credentials: "include" }
+  );
 
   if (!response.ok) {
     const error = await response
@@ -80,7 +137,7 @@ export async function rerunAnalysis(
   id: string,
   overrides?: RerunOverrides
 ): Promise<{ analysis_id: string }> {
-  const response = await fetch(`${API_BASE_URL}/api/analyses/${id}/rerun`, {
+  const response = await fetchWithCSRF(`${API_BASE_URL}/api/analyses/${id}/rerun`, {
     method: "POST",
     headers: {
       "Content-Type": "application/json",
@@ -128,7 +185,7 @@ export async function compareAnalyses(
 export async function cancelAnalysis(
   id: string
 ): Promise<{ message: string; analysis_id: string }> {
-  const response = await fetch(`${API_BASE_URL}/api/analyses/${id}/cancel`, {
+  const response = await fetchWithCSRF(`${API_BASE_URL}/api/analyses/${id}/cancel`, {
     method: "POST",
     headers: {
       "Content-Type": "application/json",
@@ -159,7 +216,7 @@ export async function deleteAnalysis(
     const controller = new AbortController();
     const timeoutId = setTimeout(() => controller.abort(), 30000); // 30秒タイムアウト
 
-    const response = await fetch(url, {
+    const response = await fetchWithCSRF(url, {
       method: "DELETE",
       headers: {
         "Content-Type": "application/json",
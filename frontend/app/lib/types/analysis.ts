@@ -29,6 +29,16 @@ export interface Metrics {
   cis_num?: number;
   cis_dist_mean?: number;
   cis_dist_std?: number;
+  // method=all の場合の構造決定手法ごとの内訳
+  method_breakdown?: Record<string, MethodBreakdown>;
+}
+
+export interface MethodBreakdown {
+  entries: number;
+  chains: number;
+  resolution: number | null;
+  umf: number | null;
+  mean_std: number | null;
 }
 
 export interface AnalysisSummary {
@@ -40,6 +50,10 @@ export interface AnalysisSummary {
   progress?: number;
   metrics?: Metrics;
   error_message?: string;
+  // 保持期間を過ぎて成果物が削除された（サマリーとメトリクスのみ残る）
+  artifacts_expired?: boolean;
+  // 保持期間による自動削除の対象外
+  pinned?: boolean;
 }
 
 export interface AnalysisArtifacts {
@@ -57,8 +71,43 @@ export interface Analysis {
   started_at?: string;
   finished_at?: string;
   error_message?: string;
+  artifacts_expired?: boolean;
+  artifacts_expired_at?: string;
+  pinned?: boolean;
+  // 実行中の解析の残り時間の推定（秒）
+  eta_seconds?: number;
+}
+
+// GET /api/analyses?page_size=50&cursor=...
+export interface AnalysisPage {
+  analyses: AnalysisSummary[];
+  has_more: boolean;
+  // 次のページのカーソル（最後のページではなし）
+  next_cursor?: string;
+  // include_total=true の場合のみ
+  total?: number;
 }
 
 export interface CompareResponse {
   analyses: AnalysisSummary[];
 }
+
+// GET /api/analyses?group_by=uniprot_id
+export interface AnalysisGroup {
+  uniprot_id: string;
+  run_count: number;
+  status_counts: Partial<Record<AnalysisStatus, number>>;
+  latest: {
+    id: string;
+    method: string;
+    status: AnalysisStatus;
+    created_at: string;
+  };
+  // 完了した解析のうちエントリ数が最も多いもの（完了した解析がない場合はなし）
+  best?: {
+    id: string;
+    method: string;
+    created_at: string;
+    metrics: Metrics;
+  };
+}
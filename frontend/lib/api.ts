@@ -1,3 +1,5 @@
+import { fetchWithCSRF } from "./csrf";
+
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
 
 export interface JobParams {
@@ -29,7 +31,8 @@ export async function createJob(
   uniprotId: string,
   params: JobParams = {}
 ): Promise<{ job_id: string; status: string }> {
-  const response = await fetch(`${API_BASE_URL}/api/jobs`, {
+  // セッションCookie（解析の所有者の記録に使う）を送受信し、CSRFトークンを付ける
+  const response = await fetchWithCSRF(`${API_BASE_URL}/api/jobs`, {
     method: "POST",
     headers: {
       "Content-Type": "application/json",
@@ -48,6 +51,28 @@ export async function createJob(
   return response.json();
 }
 
+export interface JobDraft {
+  uniprot_id: string;
+  params: JobParams;
+}
+
+// ディープリンク（/analysis?uniprot_id=...&xray_only=true）のクエリを検証済みのドラフトに変換
+export async function getJobDraft(query: string): Promise<JobDraft> {
+  const response = await fetch(`${API_BASE_URL}/api/jobs/new?${query}`, {
+    headers: {
+      Accept: "application/json",
+    },
+  });
+
+  if (!response.ok) {
+    const error = await response.json();
+    throw new Error(error.error || "Failed to load job draft");
+  }
+
+  const data = await response.json();
+  return data.draft;
+}
+
 export async function getJob(jobId: string): Promise<Job> {
   const response = await fetch(`${API_BASE_URL}/api/jobs/${jobId}`);
 
@@ -59,6 +84,12 @@ export async function getJob(jobId: string): Promise<Job> {
   return response.json();
 }
 
-export function getResultUrl(jobId: string, filename: string): string {
-  return `${API_BASE_URL}/api/jobs/${jobId}/${filename}`;
+// 画像の成果物はsizeを指定するとサーバー側で縮小したもの（small: 幅320px, medium: 幅800px）を取得する
+export function getResultUrl(
+  jobId: string,
+  filename: string,
+  size?: "small" | "medium" | "full"
+): string {
+  const url = `${API_BASE_URL}/api/jobs/${jobId}/${filename}`;
+  return size ? `${url}?size=${size}` : url;
 }
@@ -0,0 +1,54 @@
+const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
+
+let csrfToken: Promise<string> | null = null;
+
+// CSRFトークン（GET /api/csrf、Cookieにも設定される）を取得する（取得済みの場合は再利用）
+async function getCSRFToken(): Promise<string> {
+  if (!csrfToken) {
+    csrfToken = fetch(`${API_BASE_URL}/api/csrf`, { credentials: "include" })
+      .then(async (response) => {
+        if (!response.ok) {
+          throw new Error("Failed to get CSRF token");
+        }
+        const data = await response.json();
+        return data.token as string;
+      })
+      .catch((error) => {
+        csrfToken = null;
+        throw error;
+      });
+  }
+  return csrfToken;
+}
+
+// 状態を変更するリクエスト（POST・PATCH・DELETE）に付けるヘッダー
+export async function csrfHeaders(): Promise<Record<string, string>> {
+  return { "X-CSRF-Token": await getCSRFToken() };
+}
+
+// CSRFトークンの不一致（Cookieの期限切れ等）の場合はトークンを取り直して1回だけ再送する
+export async function fetchWithCSRF(
+  input: string,
+  init: RequestInit
+): Promise<Response> {
+  const send = async () =>
+    fetch(input, {
+      ...init,
+      credentials: "include",
+      headers: {
+        ...(init.headers as Record<string, string>),
+        ...(await csrfHeaders()),
+      },
+    });
+
+  const response = await send();
+  if (response.status !== 403) {
+    return response;
+  }
+  const body = await response.clone().json().catch(() => null);
+  if (!body?.csrf_required) {
+    return response;
+  }
+  csrfToken = null;
+  return send();
+}
@@ -11,13 +11,39 @@ from Bio.PDB import PDBList
 from Bio.PDB.MMCIF2Dict import MMCIF2Dict
 
 
+class FetchError(Exception):
+    """外部データベースからの取得の失敗
+
+    category は result.json の error_category としてバックエンドに渡され、
+    取得元の障害（uniprot, pdbe）と入力の誤り（invalid_input）を区別するために使われる
+    """
+
+    def __init__(self, category, message):
+        super().__init__(message)
+        self.category = category
+
+
 class UniprotData:
-    """UniProt XMLデータにアクセスし、情報を取得"""
+    """UniProt XMLデータにアクセスし、情報を取得
 
-    def __init__(self, uniprot_id: str):
+    isoform を指定した場合、PDBの参照は正規のエントリから取得し、配列のみアイソフォームのものを使う
+    """
+
+    def __init__(self, uniprot_id: str, isoform=None):
+        self.uniprot_id = uniprot_id
+        self.isoform = isoform
+        self._isoform_fasta = None
         url = f"https://www.uniprot.org/uniprot/{uniprot_id}.xml"
-        response = requests.get(url)
-        response.raise_for_status()
+        try:
+            response = requests.get(url)
+            response.raise_for_status()
+        except requests.HTTPError as e:
+            # 存在しないIDは入力の誤り、それ以外（5xx・429）はUniProt側の障害として扱う
+            status = e.response.status_code if e.response is not None else None
+            category = "invalid_input" if status in (400, 404, 410) else "uniprot"
+            raise FetchError(category, str(e)) from e
+        except requests.RequestException as e:
+            raise FetchError("uniprot", str(e)) from e
         self.xml = etree.fromstring(response.content)
         self.nsmap = self.xml.nsmap
         TF = self.xml.find("./", self.nsmap).text
@@ -32,9 +58,32 @@ class UniprotData:
         ]
 
     def fasta(self) -> str:
-        """FASTA 配列の取得"""
+        """FASTA 配列の取得（アイソフォームを指定した場合はそのアイソフォームの配列）"""
+        if self.isoform:
+            return self.isoform_fasta()
         return self.xml.find("./entry/sequence", self.nsmap).text
 
+    def isoform_fasta(self) -> str:
+        """アイソフォームの配列をUniProt REST APIから取得"""
+        if self._isoform_fasta is not None:
+            return self._isoform_fasta
+        accession = f"{self.uniprot_id}-{self.isoform}"
+        url = f"https://rest.uniprot.org/uniprotkb/{accession}.fasta"
+        try:
+            response = requests.get(url)
+            response.raise_for_status()
+        except requests.HTTPError as e:
+            status = e.response.status_code if e.response is not None else None
+            category = "invalid_input" if status in (400, 404, 410) else "uniprot"
+            raise FetchError(category, f"Isoform {accession}: {e}") from e
+        except requests.RequestException as e:
+            raise FetchError("uniprot", str(e)) from e
+        lines = response.text.strip().splitlines()
+        if not lines or not lines[0].startswith(">"):
+            raise FetchError("invalid_input", f"Isoform {accession} not found")
+        self._isoform_fasta = "".join(line.strip() for line in lines[1:])
+        return self._isoform_fasta
+
     def get_fullname(self):
         """fullName取得"""
         return self.xml.find("./entry/protein/*/fullName", self.nsmap).text
@@ -134,7 +183,10 @@ def downloadpdb(pdbid, pdb_dir="pdb_files/"):
     """Download PDB File"""
     if not os.path.exists(pdb_dir):
         os.makedirs(pdb_dir)
-    pdb_list.retrieve_pdb_file(pdbid, pdir=pdb_dir, file_format="mmCif")
+    # 取得に失敗してもBiopythonは例外を送出しないため、ファイルの有無で判定する
+    path = pdb_list.retrieve_pdb_file(pdbid, pdir=pdb_dir, file_format="mmCif")
+    if not os.path.exists(path):
+        raise FetchError("pdbe", f"Failed to download structure file for {pdbid}")
 
 
 def _open(pdbid, pdb_dir="pdb_files/"):
@@ -8,6 +8,7 @@ import numpy as np
 from itertools import combinations
 from decimal import Decimal, ROUND_HALF_UP
 from .fetch import UniprotData, CifData, convert_three, downloadpdb
+from .structures import parse_resolution, structure_report
 
 # numbaを条件付きインポート（オプショナル）
 try:
@@ -190,6 +191,45 @@ def getscore(distance, ddof=0):
     )
 
 
+def method_breakdown(distance, pdbdata):
+    """構造決定手法ごとのエントリ数・チェーン数・平均分解能・スコア（手法を混ぜた解析の交絡の確認用）
+
+    スコアは同じ手法のチェーンのみで計算する（2チェーン未満の手法はNone）
+    """
+    id_cols = distance.columns.values.tolist()[:2]
+    groups = {}
+    for col in distance.columns.values.tolist()[2:]:
+        pdbid = col.split(" ")[0]
+        method = pdbdata.at["method", pdbid] if pdbid in pdbdata.columns else None
+        groups.setdefault(method or "unknown", []).append(col)
+
+    breakdown = {}
+    for method, cols in groups.items():
+        pdbids = sorted(set(col.split(" ")[0] for col in cols))
+        resos = [
+            reso
+            for reso in (
+                parse_resolution(pdbdata.at["resolution", pdbid])
+                for pdbid in pdbids
+                if pdbid in pdbdata.columns
+            )
+            if reso is not None
+        ]
+        stats = {
+            "entries": len(pdbids),
+            "chains": len(cols),
+            "resolution": round(float(np.mean(resos)), 2) if resos else None,
+            "umf": None,
+            "mean_std": None,
+        }
+        if len(cols) >= 2:
+            score = getscore(distance[id_cols + cols], 0)
+            stats["umf"] = round(float(score["score"].mean()), 1)
+            stats["mean_std"] = round(float(score["distance std"].mean()), 4)
+        breakdown[method] = stats
+    return breakdown
+
+
 def count_pdb(uniprotid, method="X-ray", negative_pdbid=""):
     """PDB数をカウント"""
     unidata = UniprotData(uniprotid)
@@ -208,9 +248,15 @@ def prep(
     pdb_dir="pdb_files/",
     atom_coord_dir="atom_coord/",
     verbose=False,
+    on_progress=None,
+    isoform=None,
 ):
-    """データ準備"""
-    unidata = UniprotData(uniprotid)
+    """データ準備
+
+    on_progress: 各PDBエントリの処理後に (処理済み数, 総数, PDB ID) で呼ばれるコールバック
+    isoform: アイソフォーム番号（指定した場合はそのアイソフォームの配列と比較する）
+    """
+    unidata = UniprotData(uniprotid, isoform)
     uniprotids = unidata.get_id()
     id = str(uniprotids)
     fasta = unidata.fasta()
@@ -239,6 +285,8 @@ def prep(
             print(
                 f" ({n+1}/{len(pdblist)}) judge: {pdbid} {mut_judge}", file=sys.stderr
             )
+        if on_progress is not None:
+            on_progress(n + 1, len(pdblist), pdbid)
 
         if mut_judge == "normal":
             nor_pdblist.append(pdbid)
@@ -290,9 +338,10 @@ def run_DSA(
     cis_threshold=3.3,
     proc_cis=True,
     verbose=False,
+    isoform=None,
 ):
     """DSA解析実行"""
-    unidata = UniprotData(uniprotid)
+    unidata = UniprotData(uniprotid, isoform)
     uniprotids = unidata.get_id()
     str_ids = str(uniprotids)
     fasta = unidata.fasta()
@@ -322,6 +371,13 @@ def run_DSA(
             "umf": round((score["distance mean"] / score["distance std"]).mean(), 1),
         }
 
+        # タンパク質名・生物種（共有リンクの表示用、取得できない場合は省略）
+        try:
+            log_data["protein_name"] = unidata.get_fullname()
+            log_data["organism"] = unidata.get_organism()
+        except AttributeError:
+            pass
+
         # 分解能の計算
         pdbids = [i.split(" ")[0] for i in trimseqcol]
         reso_list = []
@@ -405,6 +461,13 @@ def run_DSA(
         # 使用PDB IDリスト
         log_data["pdb_ids"] = sorted(list(set(pdbids)))
 
+        # PDBエントリごとの外れ値レポート（dsa_cliがstructures.jsonに書き出す）
+        log_data["structures"] = structure_report(distance, unidata.pdbdata)
+
+        # 全手法で解析した場合は手法ごとの内訳（X-ray・EM・NMRを混ぜたことによる影響の確認用）
+        if method == "":
+            log_data["method_breakdown"] = method_breakdown(distance, unidata.pdbdata)
+
         # Cis情報を追加
         if cis_info:
             log_data["cis_analysis"] = cis_info
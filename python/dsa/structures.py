@@ -0,0 +1,94 @@
+"""Per-structure outlier report for DSA analysis"""
+
+import numpy as np
+
+# 修正Zスコアがこの値を超えたエントリを外れ値とする（Iglewicz & Hoaglin）
+OUTLIER_THRESHOLD = 3.5
+# 外れ値判定に必要な最小エントリ数
+MIN_ENTRIES = 3
+
+
+def parse_resolution(value):
+    """分解能の文字列（例: "1.80 A"）を数値に変換（NMR等で値がない場合はNone）"""
+    if value is None:
+        return None
+    reso = "".join(char for char in value if char.isdigit() or char == ".")
+    try:
+        return float(reso)
+    except ValueError:
+        return None
+
+
+def _mean(values):
+    """NaNを除いた平均（すべてNaNの場合は0、JSONにNaNを書き出さないため）"""
+    values = [v for v in values if not np.isnan(v)]
+    return float(np.mean(values)) if values else 0.0
+
+
+def chain_deviation(distance):
+    """各チェーンの残基ペア距離が全体の平均からどれだけ離れているか
+
+    deviation: |距離 - 平均| / 標準偏差 の全ペア平均（DSAスコアへの寄与の大きさ）
+    mean_abs_diff: |距離 - 平均| の全ペア平均（Å）
+    """
+    dis = distance.iloc[:, 2:]
+    means = dis.mean(axis="columns")
+    stds = dis.std(axis="columns", ddof=0).replace(0, np.nan)
+    diff = dis.sub(means, axis="index").abs()
+    return diff.div(stds, axis="index").mean(), diff.mean()
+
+
+def structure_report(distance, pdbdata):
+    """解析に使用したPDBエントリごとの分解能・手法・ずれの大きさ・外れ値フラグ"""
+    deviation, mean_abs_diff = chain_deviation(distance)
+
+    entries = {}
+    for col in deviation.index:
+        pdbid, chain = col.split(" ")
+        entry = entries.setdefault(pdbid, {"chains": [], "dev": [], "diff": []})
+        entry["chains"].append(chain)
+        entry["dev"].append(deviation[col])
+        entry["diff"].append(mean_abs_diff[col])
+
+    structures = []
+    for pdbid, entry in entries.items():
+        method = None
+        resolution = None
+        if pdbid in pdbdata.columns:
+            method = pdbdata.at["method", pdbid]
+            resolution = parse_resolution(pdbdata.at["resolution", pdbid])
+        structures.append(
+            {
+                "pdb_id": pdbid,
+                "method": method,
+                "resolution": resolution,
+                "chains": entry["chains"],
+                "deviation": round(_mean(entry["dev"]), 4),
+                "mean_abs_diff": round(_mean(entry["diff"]), 4),
+            }
+        )
+
+    # 修正Zスコア（中央値・MADを使うため外れ値自身に引きずられにくい）
+    devs = np.array([s["deviation"] for s in structures], dtype=float)
+    scores = np.zeros(len(devs))
+    if len(devs) >= MIN_ENTRIES:
+        median = np.median(devs)
+        mad = np.median(np.abs(devs - median))
+        if mad > 0:
+            scores = 0.6745 * (devs - median) / mad
+        else:
+            # 半数以上が同じ値の場合は平均絶対偏差で代用する
+            meanad = np.mean(np.abs(devs - median))
+            if meanad > 0:
+                scores = (devs - median) / (1.253314 * meanad)
+
+    for s, z in zip(structures, scores):
+        s["outlier_score"] = round(float(z), 2)
+        s["outlier"] = bool(z > OUTLIER_THRESHOLD)
+
+    structures.sort(key=lambda s: s["deviation"], reverse=True)
+    return {
+        "threshold": OUTLIER_THRESHOLD,
+        "structures": structures,
+        "outliers": [s["pdb_id"] for s in structures if s["outlier"]],
+    }
@@ -5,11 +5,106 @@ import sys
 import json
 import argparse
 import re
+import shutil
+import pickle
 from pathlib import Path
 import pandas as pd
 from dsa.fetch import UniprotData
 from dsa.pipeline import count_pdb, prep, run_DSA
-from dsa.plotting import plot_heatmap, plot_distance_score
+from dsa.plotting import generate_heatmap_data, plot_heatmap, plot_distance_score
+
+
+def report_progress(percent, message):
+    """構造化された進捗行を標準出力に出力（バックエンドが解析してジョブの進捗に反映）"""
+    print(f"PROGRESS {int(percent)} {message}", flush=True)
+
+
+def checkpoint_key(args, method):
+    """チェックポイントを再利用できる条件（データ準備の結果に影響するパラメータ）"""
+    return {
+        "uniprot": args.uniprot,
+        "method": method,
+        "negative_pdbid": args.negative_pdbid,
+        "isoform": args.isoform,
+    }
+
+
+def parse_chains(chain):
+    """--chain の値（"A" や "A,B"）をチェーンIDの集合に変換（空の場合はNone = すべてのチェーン）"""
+    chains = {c for c in re.split(r"[,\s]+", chain.strip()) if c}
+    return chains or None
+
+
+def select_chains(seqdata, chains):
+    """配列データの列（先頭はUniProtの配列、以降は "PDBID チェーンID"）を指定したチェーンに絞り込む"""
+    columns = [seqdata.columns[0]] + [
+        col for col in seqdata.columns[1:] if col.split(" ")[-1] in chains
+    ]
+    return seqdata[columns]
+
+
+def variant_label(args):
+    """解析対象の表示名（アイソフォーム・チェーンを含む、例: P69905-2:A）"""
+    label = args.uniprot
+    if args.isoform:
+        label += f"-{args.isoform}"
+    if args.chain:
+        label += f":{args.chain}"
+    return label
+
+
+def restore_checkpoint(resume_dir, work_dir, key):
+    """再開元の作業ディレクトリからPDBファイル等をコピーし、データ準備の結果があれば返す
+
+    条件（UniProt ID・method・除外PDB ID）が異なる場合はダウンロード済みのファイルのみ再利用する
+    """
+    resume_dir = Path(resume_dir).resolve()
+    if not resume_dir.is_dir():
+        print(f"Checkpoint not found: {resume_dir}", file=sys.stderr, flush=True)
+        return None
+
+    if resume_dir != work_dir:
+        for sub in ("pdb_files", "atom_coord"):
+            src = resume_dir / sub
+            if not src.is_dir():
+                continue
+            dst = work_dir / sub
+            dst.mkdir(parents=True, exist_ok=True)
+            for f in src.iterdir():
+                if f.is_file() and not (dst / f.name).exists():
+                    shutil.copy2(f, dst / f.name)
+
+    try:
+        with open(resume_dir / "checkpoint.json", encoding="utf-8") as f:
+            meta = json.load(f)
+    except (OSError, ValueError):
+        return None
+    if meta.get("key") != key or "prep" not in meta.get("stages", []):
+        return None
+    try:
+        with open(resume_dir / "prep.pkl", "rb") as f:
+            return pickle.load(f)
+    except (OSError, pickle.UnpicklingError, EOFError):
+        return None
+
+
+def save_checkpoint(work_dir, key, stage=None, prep_result=None):
+    """完了したステージを作業ディレクトリに記録する（--resumeで再利用される）"""
+    meta_path = work_dir / "checkpoint.json"
+    try:
+        with open(meta_path, encoding="utf-8") as f:
+            meta = json.load(f)
+    except (OSError, ValueError):
+        meta = {}
+    if meta.get("key") != key:
+        meta = {"key": key, "stages": []}
+    if prep_result is not None:
+        with open(work_dir / "prep.pkl", "wb") as f:
+            pickle.dump(prep_result, f)
+    if stage is not None and stage not in meta["stages"]:
+        meta["stages"].append(stage)
+    with open(meta_path, "w", encoding="utf-8") as f:
+        json.dump(meta, f, indent=2, ensure_ascii=False)
 
 
 def main():
@@ -53,7 +148,23 @@ def main():
         default=True,
         help="Process cis analysis (default: True)",
     )
+    parser.add_argument(
+        "--isoform",
+        type=int,
+        default=0,
+        help="UniProt isoform number to compare against (default: canonical sequence)",
+    )
+    parser.add_argument(
+        "--chain",
+        default="",
+        help="Comma separated chain IDs to analyze (default: all chains)",
+    )
     parser.add_argument("--verbose", action="store_true", help="Verbose output")
+    parser.add_argument(
+        "--resume",
+        default="",
+        help="Previous work directory to resume from (reuses downloaded PDB files and completed stages)",
+    )
 
     args = parser.parse_args()
 
@@ -71,9 +182,18 @@ def main():
     method = args.method if args.method else ""
     seq_ratio = args.sequence_ratio * 100  # パーセントに変換
 
+    # チェックポイントからの再開（ダウンロード済みのファイルはprep内でスキップされる）
+    key = checkpoint_key(args, method)
+    resumed_prep = None
+    if args.resume:
+        resumed_prep = restore_checkpoint(args.resume, work_dir, key)
+    # 途中で失敗した場合もダウンロード済みのファイルを再利用できるように先に記録する
+    save_checkpoint(work_dir, key)
+
     try:
         # 進捗出力
         print("STEP 1/5: Checking PDB availability...", file=sys.stderr, flush=True)
+        report_progress(0, "Checking PDB availability...")
         
         # まず全メソッドで確認（エラーメッセージ用）
         unidata = UniprotData(args.uniprot)
@@ -147,6 +267,7 @@ def main():
             result = {
                 "status": "failed",
                 "error": error_msg,
+                "error_category": "no_structures",
                 "uniprot_id": args.uniprot,
                 "method": method if method else "all",
                 "pdb_counts": method_counts,
@@ -167,19 +288,34 @@ def main():
         if not count_pdb(args.uniprot, method, args.negative_pdbid):
             # 上記のエラーハンドリングで既に処理されているので、ここには来ないはず
             pass
+        # 解析対象の構造数（バックエンドが残り時間の推定に使う）
+        report_progress(5, f"Found {len(pdblist)} PDB entries")
 
         print("STEP 2/5: Preparing data...", file=sys.stderr, flush=True)
+        report_progress(10, "Preparing data...")
         # 絶対パスに変換
         pdb_dir_str = str(pdb_dir.resolve())
         atom_coord_dir_str = str(atom_coord_dir.resolve())
-        seqdata, all_pdblist = prep(
-            args.uniprot,
-            method,
-            args.negative_pdbid,
-            pdb_dir_str,
-            atom_coord_dir_str,
-            args.verbose,
-        )
+        if resumed_prep is not None:
+            print("Resumed data preparation from checkpoint", file=sys.stderr, flush=True)
+            report_progress(50, "Resumed from checkpoint")
+            seqdata, all_pdblist = resumed_prep
+        else:
+            seqdata, all_pdblist = prep(
+                args.uniprot,
+                method,
+                args.negative_pdbid,
+                pdb_dir_str,
+                atom_coord_dir_str,
+                args.verbose,
+                # PDBダウンロード・判定の進捗を 10〜50% に割り当てる
+                on_progress=lambda done, total, pdbid: report_progress(
+                    10 + 40 * done / max(total, 1),
+                    f"Downloading PDB {done}/{total} ({pdbid})",
+                ),
+                isoform=args.isoform or None,
+            )
+            save_checkpoint(work_dir, key, "prep", (seqdata, all_pdblist))
 
         # UniProt配列のみを抽出
         unidata = UniprotData(args.uniprot)
@@ -225,6 +361,7 @@ def main():
             result = {
                 "status": "failed",
                 "error": error_msg,
+                "error_category": "no_structures",
                 "uniprot_id": args.uniprot,
                 "found_structures": len(pdbtuple),
                 "required_structures": args.min_structures,
@@ -245,10 +382,21 @@ def main():
             file=sys.stderr,
             flush=True,
         )
+        report_progress(50, f"Processing {len(pdbtuple)} PDB entries...")
         seqdata2 = seqdata.loc[:, seqdata.columns.str.startswith(pdbtuple)]
         norsub_seqdata = pd.concat([seqdata1, seqdata2], axis=1)
+        # チェーンの指定（データ準備の結果は共通のため、チェックポイントは絞り込む前のものを使う）
+        chains = parse_chains(args.chain)
+        if chains is not None:
+            norsub_seqdata = select_chains(norsub_seqdata, chains)
+            print(
+                f"Selected {len(norsub_seqdata.columns) - 1} chains ({args.chain})",
+                file=sys.stderr,
+                flush=True,
+            )
 
         print("STEP 4/5: Running DSA analysis...", file=sys.stderr, flush=True)
+        report_progress(55, "Running DSA analysis...")
         score, log_data, distance = run_DSA(
             args.uniprot,
             norsub_seqdata,
@@ -261,6 +409,7 @@ def main():
             cis_threshold=args.cis_threshold,
             proc_cis=args.proc_cis,
             verbose=args.verbose,
+            isoform=args.isoform or None,
         )
 
         if score.empty or "error" in log_data:
@@ -268,6 +417,8 @@ def main():
             result = {
                 "status": "failed",
                 "error": error_msg,
+                # 鎖の数が足りない等、データが不十分で解析できない
+                "error_category": "no_structures" if "error" in log_data else "analysis",
                 "uniprot_id": args.uniprot,
             }
             with open(out_dir / "result.json", "w", encoding="utf-8") as f:
@@ -281,18 +432,37 @@ def main():
                 )
             sys.exit(1)
 
+        # PDBエントリごとの外れ値レポート（次回の除外PDB IDの選択用）
+        structures = log_data.pop("structures", None)
+        if structures is not None:
+            with open(out_dir / "structures.json", "w", encoding="utf-8") as f:
+                json.dump(structures, f, indent=2, ensure_ascii=False)
+
         print("STEP 5/5: Generating plots...", file=sys.stderr, flush=True)
+        report_progress(90, "Generating plots...")
 
         # ヒートマップ生成
         heatmap_path = out_dir / "heatmap.png"
-        plot_heatmap(score, str(heatmap_path), f"DSA Score Heatmap - {args.uniprot}")
+        plot_heatmap(score, str(heatmap_path), f"DSA Score Heatmap - {variant_label(args)}")
+
+        # スコア行列の保存（解析間の差分ヒートマップ用、欠損はnull）
+        hm = generate_heatmap_data(score)
+        matrix = {
+            "size": len(hm),
+            "values": [
+                [None if pd.isna(v) else round(float(v), 4) for v in row]
+                for row in hm.values.tolist()
+            ],
+        }
+        with open(out_dir / "score_matrix.json", "w", encoding="utf-8") as f:
+            json.dump(matrix, f)
 
         # 散布図生成
         scatter_path = out_dir / "dist_score.png"
         plot_distance_score(
             score,
             str(scatter_path),
-            f"Distance vs Score - {args.uniprot}",
+            f"Distance vs Score - {variant_label(args)}",
             args.uniprot,
         )
 
@@ -307,6 +477,8 @@ def main():
                 "negative_pdbid": args.negative_pdbid,
                 "cis_threshold": args.cis_threshold,
                 "proc_cis": args.proc_cis,
+                "isoform": args.isoform or None,
+                "chain": args.chain or None,
             },
             "statistics": log_data,
             "score_summary": {
@@ -336,10 +508,17 @@ def main():
             )
 
         print("Analysis completed successfully", file=sys.stderr, flush=True)
+        report_progress(100, "Analysis completed")
 
     except Exception as e:
         error_msg = str(e)
-        result = {"status": "failed", "error": error_msg, "uniprot_id": args.uniprot}
+        result = {
+            "status": "failed",
+            "error": error_msg,
+            # FetchErrorは取得元（uniprot, pdbe）または入力の誤り、それ以外は解析中のエラー
+            "error_category": getattr(e, "category", "analysis"),
+            "uniprot_id": args.uniprot,
+        }
         with open(out_dir / "result.json", "w", encoding="utf-8") as f:
             json.dump(result, f, indent=2, ensure_ascii=False)
         with open(out_dir / "status.json", "w", encoding="utf-8") as f: